@@ -18,33 +18,76 @@ package ast
 
 import (
 	"fmt"
+	"log"
 
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/types"
 	"github.com/llir/llvm/ir/value"
+	"github.com/mhelmich/plsqlc/runtime"
 )
 
-func NewAssignment(varName string, expr Expression) *Assignment {
+func NewAssignment(target *Variable, expr Expression) *Assignment {
 	return &Assignment{
-		VarName: varName,
+		Target: target,
+		Expr:   expr,
+	}
+}
+
+// NewElementAssignment creates an assignment to an element of a collection ('list(i) := x')
+func NewElementAssignment(element *CollectionElement, expr Expression) *Assignment {
+	return &Assignment{
+		Element: element,
 		Expr:    expr,
 	}
 }
 
+// Assignment stores a value in a variable or in an element of a collection
+// exactly one of Target and Element is set
 type Assignment struct {
-	VarName string
+	Target  *Variable
+	Element *CollectionElement
 	Expr    Expression
 }
 
 func (a *Assignment) GenIR(cc *CompilerContext) value.Value {
-	varValue, ok := cc.scopes.findMember(a.VarName)
-	if !ok {
+	if a.Element != nil {
+		// the element is created after the value, an error in the value doesn't leave an empty element behind
+		exprValue := a.Expr.GenIR(cc)
+		if a.Expr.expressionType() == stringExpression {
+			exprValue = cc.currentLlvmBlock.NewLoad(exprValue)
+		}
+		cc.currentLlvmBlock.NewStore(exprValue, a.Element.address(cc, true))
 		return nil
 	}
 
+	varValue := a.Target.address(cc)
+	if g, ok := varValue.(*ir.Global); ok && g.Immutable {
+		log.Panicf("PLS-00363: expression '%s' cannot be used as an assignment target", a.Target.Name)
+	}
+
 	exprValue := a.Expr.GenIR(cc)
+	if a.Expr.expressionType() == stringExpression {
+		exprValue = cc.currentLlvmBlock.NewLoad(exprValue)
+	}
+	if a.Target.Type() != nil && a.Target.Type().IsCollection() {
+		// collections are copied, the two variables don't share their elements
+		_, size := cc.elementType(a.Target.Type())
+		exprValue = cc.currentLlvmBlock.NewCall(cc.getFuncByName(runtime.CollectionCopyFuncName), exprValue, size)
+	}
+
+	// the checker converts values into the type of the target
+	// anything else would overwrite memory with a value of a different layout
+	targetType := varValue.Type().(*types.PointerType).ElemType
+	if !types.Equal(exprValue.Type(), targetType) {
+		log.Panicf("Can't assign a value of type '%s' to '%s' of type '%s'", exprValue.Type().String(), a.Target.Name, targetType.String())
+	}
 	cc.currentLlvmBlock.NewStore(exprValue, varValue)
 	return nil
 }
 
 func (a *Assignment) String() string {
-	return fmt.Sprintf("%s := %s", a.VarName, a.Expr.String())
+	if a.Element != nil {
+		return fmt.Sprintf("%s := %s", a.Element.String(), a.Expr.String())
+	}
+	return fmt.Sprintf("%s := %s", a.Target.String(), a.Expr.String())
 }
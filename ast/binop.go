@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"log"
 
+	"github.com/llir/llvm/ir/constant"
 	"github.com/llir/llvm/ir/enum"
 	"github.com/llir/llvm/ir/types"
 	"github.com/llir/llvm/ir/value"
@@ -35,50 +36,193 @@ func NewBinOp(left Expression, op string, right Expression) *BinOp {
 }
 
 type BinOp struct {
+	typed
 	Left  Expression
 	Op    string
 	Right Expression
+	// set by the checker if strings are compared ignoring trailing blanks
+	blankPadded bool
 }
 
 func (bo *BinOp) expressionType() expressionType {
 	return binOpExpression
 }
 
+// maps comparison operators to the predicates of integer comparisons
+var intPredicates = map[string]enum.IPred{
+	"=":  enum.IPredEQ,
+	"<>": enum.IPredNE,
+	"!=": enum.IPredNE,
+	"<":  enum.IPredSLT,
+	">":  enum.IPredSGT,
+	"<=": enum.IPredSLE,
+	">=": enum.IPredSGE,
+}
+
+// maps comparison operators to the predicates of (ordered) floating point comparisons
+var numberPredicates = map[string]enum.FPred{
+	"=":  enum.FPredOEQ,
+	"<>": enum.FPredONE,
+	"!=": enum.FPredONE,
+	"<":  enum.FPredOLT,
+	">":  enum.FPredOGT,
+	"<=": enum.FPredOLE,
+	">=": enum.FPredOGE,
+}
+
+// GenIR relies on the checker having annotated both operands with their types
 func (bo *BinOp) GenIR(cc *CompilerContext) value.Value {
 	l := bo.Left.GenIR(cc)
 	r := bo.Right.GenIR(cc)
 
+	lt := bo.Left.Type()
+	rt := bo.Right.Type()
+	if lt.isDatetime() || lt.isInterval() || rt.isDatetime() || rt.isInterval() {
+		return bo.genIRForDatetimes(cc, l, r)
+	} else if bo.Left.Type().Equal(IntType) && bo.Right.Type().Equal(IntType) {
+		return bo.genIRForInts(cc, l, r)
+	} else if bo.Left.Type().isDouble() && bo.Right.Type().isDouble() {
+		return bo.genIRForNumbers(cc, l, r)
+	} else if bo.Left.Type().IsString() && bo.Right.Type().IsString() {
+		return bo.genIRForStrings(cc, l, r)
+	}
+
+	log.Panicf("Not implemented yet - '%s' '%s' '%s'", bo.Op, bo.Left.Type().String(), bo.Right.Type().String())
+	return nil
+}
+
+func (bo *BinOp) genIRForInts(cc *CompilerContext, l value.Value, r value.Value) value.Value {
 	switch bo.Op {
-	case ">":
-		if bo.Left.expressionType() == numberExpression || bo.Right.expressionType() == numberExpression {
-			return cc.currentLlvmBlock.NewICmp(enum.IPredSGT, l, r)
-		} else {
-			log.Panicf("Not implemented yet - '>' '%v' '%v'", bo.Left.expressionType(), bo.Right.expressionType())
-		}
+	case "+":
+		return cc.currentLlvmBlock.NewAdd(l, r)
 	case "-":
-		if bo.Left.expressionType() == numberExpression || bo.Right.expressionType() == numberExpression {
-			return cc.currentLlvmBlock.NewSub(l, r)
-		} else {
-			log.Panicf("Not implemented yet - '-' '%v' '%v'", bo.Left.expressionType(), bo.Right.expressionType())
+		return cc.currentLlvmBlock.NewSub(l, r)
+	case "*":
+		return cc.currentLlvmBlock.NewMul(l, r)
+	case "/":
+		return cc.currentLlvmBlock.NewSDiv(l, r)
+	}
+
+	pred, ok := intPredicates[bo.Op]
+	if !ok {
+		log.Panicf("Operation '%s' hasn't been implemented yet for '%s'", bo.Op, IntType.String())
+	}
+	return cc.currentLlvmBlock.NewICmp(pred, l, r)
+}
+
+// genIRForDatetimes relies on the checker having converted the operands into the types it supports
+// numbers are days, dates and timestamps are microseconds and so are INTERVAL DAY TO SECOND values
+func (bo *BinOp) genIRForDatetimes(cc *CompilerContext, l value.Value, r value.Value) value.Value {
+	b := cc.currentLlvmBlock
+	lt := bo.Left.Type()
+	rt := bo.Right.Type()
+	if _, ok := intPredicates[bo.Op]; ok {
+		return bo.genIRForInts(cc, l, r)
+	}
+
+	switch {
+	case lt.Equal(DateType) && rt.IsNumeric():
+		if bo.Op == "-" {
+			r = b.NewFNeg(r)
 		}
-	case "=":
-		if bo.Left.expressionType() == numberExpression || bo.Right.expressionType() == numberExpression {
-			return cc.currentLlvmBlock.NewICmp(enum.IPredEQ, l, r)
-		} else if bo.Left.expressionType() == stringExpression || bo.Right.expressionType() == stringExpression {
-			if types.IsPointer(l.Type()) {
-				l = cc.currentLlvmBlock.NewLoad(l)
-			}
-
-			if types.IsPointer(r.Type()) {
-				r = cc.currentLlvmBlock.NewLoad(r)
-			}
-
-			return cc.currentLlvmBlock.NewCall(runtime.EqualStringFunc, l, r)
+		return b.NewCall(cc.getFuncByName(runtime.AddDaysFuncName), l, r)
+
+	case lt.IsNumeric() && rt.Equal(DateType):
+		return b.NewCall(cc.getFuncByName(runtime.AddDaysFuncName), r, l)
+
+	case lt.Equal(DateType) && rt.Equal(DateType):
+		return b.NewCall(cc.getFuncByName(runtime.DaysBetweenFuncName), l, r)
+
+	case lt.isInterval() && rt.IsNumeric(), lt.IsNumeric() && rt.isInterval():
+		interval, n := l, r
+		if rt.isInterval() {
+			interval, n = r, l
+		}
+		var scaled value.Value
+		if bo.Op == "/" {
+			scaled = b.NewFDiv(b.NewSIToFP(interval, types.Double), n)
 		} else {
-			log.Panicf("Not implemented yet - '-' '%v' '%v'", bo.Left.expressionType(), bo.Right.expressionType())
+			scaled = b.NewFMul(b.NewSIToFP(interval, types.Double), n)
 		}
+		return b.NewCall(cc.getFuncByName(runtime.NumberToIntFuncName), scaled)
+	}
+
+	// a datetime and an interval or two values of the same type
+	datetime, interval, intervalType := l, r, rt
+	if lt.isInterval() && rt.isDatetime() {
+		datetime, interval, intervalType = r, l, lt
+	}
+	if bo.Op == "-" && !(lt.isDatetime() && rt.isDatetime()) {
+		interval = b.NewSub(constant.NewInt(types.I64, 0), interval)
+	}
+
+	var result value.Value
+	if intervalType.Equal(YearToMonthType) && bo.Type().isDatetime() {
+		result = b.NewCall(cc.getFuncByName(runtime.AddIntervalMonthsFuncName), datetime, interval)
+	} else if bo.Op == "-" && lt.isDatetime() && rt.isDatetime() {
+		result = b.NewSub(l, r)
+	} else {
+		result = b.NewAdd(datetime, interval)
+	}
+
+	if bo.Type().Equal(DateType) {
+		// dates don't have fractions of a second
+		return b.NewCall(cc.getFuncByName(runtime.TimestampToDateFuncName), result)
+	}
+	return result
+}
+
+func (bo *BinOp) genIRForNumbers(cc *CompilerContext, l value.Value, r value.Value) value.Value {
+	switch bo.Op {
+	case "+":
+		return cc.currentLlvmBlock.NewFAdd(l, r)
+	case "-":
+		return cc.currentLlvmBlock.NewFSub(l, r)
+	case "*":
+		return cc.currentLlvmBlock.NewFMul(l, r)
+	case "/":
+		return cc.currentLlvmBlock.NewFDiv(l, r)
+	}
+
+	pred, ok := numberPredicates[bo.Op]
+	if !ok {
+		log.Panicf("Operation '%s' hasn't been implemented yet for '%s'", bo.Op, NumberType.String())
+	}
+	if !bo.Left.Type().Equal(BinaryDoubleType) && !bo.Right.Type().Equal(BinaryDoubleType) {
+		// NUMBERs are compared by their decimal digits, BINARY_DOUBLEs by their bits
+		normalize := cc.getFuncByName(runtime.NormalizeNumberFuncName)
+		l = cc.currentLlvmBlock.NewCall(normalize, l)
+		r = cc.currentLlvmBlock.NewCall(normalize, r)
+	}
+	return cc.currentLlvmBlock.NewFCmp(pred, l, r)
+}
+
+func (bo *BinOp) genIRForStrings(cc *CompilerContext, l value.Value, r value.Value) value.Value {
+	if types.IsPointer(l.Type()) {
+		l = cc.currentLlvmBlock.NewLoad(l)
+	}
+
+	if types.IsPointer(r.Type()) {
+		r = cc.currentLlvmBlock.NewLoad(r)
+	}
+
+	if bo.Op == "||" {
+		return cc.currentLlvmBlock.NewCall(cc.getFuncByName(runtime.ConcatStringFuncName), l, r)
+	}
+
+	equalStr := runtime.EqualStringFunc
+	if bo.blankPadded {
+		equalStr = cc.getFuncByName(runtime.EqualPaddedStringFuncName)
+	}
+
+	switch bo.Op {
+	case "=":
+		return cc.currentLlvmBlock.NewCall(equalStr, l, r)
+	case "<>", "!=":
+		equal := cc.currentLlvmBlock.NewCall(equalStr, l, r)
+		return cc.currentLlvmBlock.NewXor(equal, constant.True)
 	default:
-		log.Panicf("Operation '%s' hasn't been implemented yet", bo.Op)
+		log.Panicf("Operation '%s' hasn't been implemented yet for '%s'", bo.Op, VarcharType.String())
 	}
 	return nil
 }
@@ -21,6 +21,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/llir/llvm/ir/types"
 	"github.com/llir/llvm/ir/value"
 )
 
@@ -38,28 +39,68 @@ type Block struct {
 	Name         string
 	Instructions []Instruction
 	Terminator   Instruction
+	// source positions of the instructions, the terminator is at the position of the last statement
+	lines   []int
+	columns []int
+	line    int
+	column  int
+}
+
+// SetPosition sets the source line and column of the statement that is parsed next
+// all instructions that are added to the block from then on are at that position
+func (b *Block) SetPosition(line int, column int) {
+	b.line = line
+	b.column = column
 }
 
 func (b *Block) AddInstruction(i Instruction) {
 	b.Instructions = append(b.Instructions, i)
+	b.lines = append(b.lines, b.line)
+	b.columns = append(b.columns, b.column)
+}
+
+// position returns the source line and column of an instruction of the block
+// both are 0 if the position isn't known
+func (b *Block) position(idx int) (int, int) {
+	if idx < len(b.lines) {
+		return b.lines[idx], b.columns[idx]
+	}
+	return b.line, b.column
 }
 
 func (b *Block) GenIR(cc *CompilerContext) value.Value {
 	cc.currentLlvmBlock = cc.functionBlocks[b]
 	for idx := range b.Instructions {
+		if idx < len(b.lines) {
+			cc.setDebugLine(b.lines[idx])
+		}
 		b.Instructions[idx].GenIR(cc)
+		cc.attachDebugLocations()
+		if cc.currentLlvmBlock.Term != nil {
+			// a 'RETURN' ends the block, everything after it is unreachable
+			break
+		}
 	}
 
-	if cc.currentLlvmBlock.Term == nil && b.Terminator == nil {
-		log.Printf("Didn't find a terminator in block '%s'! Filled in empty return.\n", b.Name)
-		cc.currentLlvmBlock.NewRet(nil)
-	} else {
-		b.Terminator.GenIR(cc)
+	cc.setDebugLine(b.line)
+	if cc.currentLlvmBlock.Term == nil {
+		if b.Terminator != nil {
+			b.Terminator.GenIR(cc)
+		} else if types.Equal(cc.currentLlvmFunc.Sig.RetType, types.Void) {
+			log.Printf("Didn't find a terminator in block '%s'! Filled in empty return.\n", b.Name)
+			endAutonomousTransaction(cc)
+			cc.currentLlvmBlock.NewRet(nil)
+		} else {
+			// functions that run off their end without returning a value
+			log.Printf("Didn't find a terminator in block '%s'! Filled in unreachable.\n", b.Name)
+			cc.currentLlvmBlock.NewUnreachable()
+		}
 	}
 
 	if cc.currentLlvmBlock.Term == nil {
 		log.Panicf("%s has no terminator!\n", b.Name)
 	}
+	cc.attachDebugLocations()
 
 	return cc.currentLlvmBlock
 }
@@ -22,7 +22,8 @@ import (
 	"github.com/llir/llvm/ir/value"
 )
 
-func NewConditionalBranch(condition *BinOp, trueTarget *Block, falseTarget *Block) *ConditionalBranch {
+// the condition is anything the checker finds to be a BOOLEAN ('a > b', 'SQL%FOUND')
+func NewConditionalBranch(condition Expression, trueTarget *Block, falseTarget *Block) *ConditionalBranch {
 	return &ConditionalBranch{
 		Condition:   condition,
 		TrueTarget:  trueTarget,
@@ -31,7 +32,7 @@ func NewConditionalBranch(condition *BinOp, trueTarget *Block, falseTarget *Bloc
 }
 
 type ConditionalBranch struct {
-	Condition   *BinOp
+	Condition   Expression
 	TrueTarget  *Block
 	FalseTarget *Block
 }
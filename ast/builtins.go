@@ -0,0 +1,285 @@
+/*
+ * Copyright 2019 Marco Helmich
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ast
+
+import (
+	"strconv"
+
+	"github.com/mhelmich/plsqlc/runtime"
+)
+
+// the longest string a position or length can refer to
+var maxStringLength = strconv.Itoa(int(^uint32(0) >> 1))
+
+// builtinFunction describes a function of the STANDARD package that is implemented by the runtime
+// procedures of packages like DBMS_OUTPUT are described the same way, they don't have a return type
+type builtinFunction struct {
+	runtimeName string
+	params      []*Type
+	returnType  *Type
+	// the indexes of OUT parameters, the runtime gets the address of the variable that is passed in
+	outParams []int
+	// literals for the trailing parameters that can be left out
+	defaults []string
+	// the last parameter can be repeated ('GREATEST(a, b, c)')
+	// the runtime function takes two arguments and is applied to all of them in turn
+	variadic bool
+}
+
+func newBuiltin(runtimeName string, returnType *Type, params ...*Type) *builtinFunction {
+	return &builtinFunction{
+		runtimeName: runtimeName,
+		params:      params,
+		returnType:  returnType,
+	}
+}
+
+func (bf *builtinFunction) withDefaults(defaults ...string) *builtinFunction {
+	bf.defaults = defaults
+	return bf
+}
+
+func (bf *builtinFunction) asVariadic() *builtinFunction {
+	bf.variadic = true
+	return bf
+}
+
+func (bf *builtinFunction) withOutParams(idxs ...int) *builtinFunction {
+	bf.outParams = idxs
+	return bf
+}
+
+func (bf *builtinFunction) isOutParam(idx int) bool {
+	for _, outIdx := range bf.outParams {
+		if outIdx == idx {
+			return true
+		}
+	}
+	return false
+}
+
+// builtinFunctions holds the overloads of all built-in functions
+// the overloads of numeric functions are ordered from INT over NUMBER to BINARY_DOUBLE
+var builtinFunctions = map[string][]*builtinFunction{
+	"LENGTH":    {newBuiltin(runtime.LengthFuncName, IntType, VarcharType)},
+	"SUBSTR":    {newBuiltin(runtime.SubstrFuncName, VarcharType, VarcharType, IntType, IntType).withDefaults(maxStringLength)},
+	"INSTR":     {newBuiltin(runtime.InstrFuncName, IntType, VarcharType, VarcharType, IntType, IntType).withDefaults("1", "1")},
+	"UPPER":     {newBuiltin(runtime.UpperFuncName, VarcharType, VarcharType)},
+	"LOWER":     {newBuiltin(runtime.LowerFuncName, VarcharType, VarcharType)},
+	"INITCAP":   {newBuiltin(runtime.InitcapFuncName, VarcharType, VarcharType)},
+	"TRIM":      {newBuiltin(runtime.TrimFuncName, VarcharType, VarcharType)},
+	"LTRIM":     {newBuiltin(runtime.LtrimFuncName, VarcharType, VarcharType, VarcharType).withDefaults("' '")},
+	"RTRIM":     {newBuiltin(runtime.RtrimFuncName, VarcharType, VarcharType, VarcharType).withDefaults("' '")},
+	"LPAD":      {newBuiltin(runtime.LpadFuncName, VarcharType, VarcharType, IntType, VarcharType).withDefaults("' '")},
+	"RPAD":      {newBuiltin(runtime.RpadFuncName, VarcharType, VarcharType, IntType, VarcharType).withDefaults("' '")},
+	"REPLACE":   {newBuiltin(runtime.ReplaceFuncName, VarcharType, VarcharType, VarcharType, VarcharType).withDefaults("''")},
+	"TRANSLATE": {newBuiltin(runtime.TranslateFuncName, VarcharType, VarcharType, VarcharType, VarcharType)},
+	"CONCAT":    {newBuiltin(runtime.ConcatStringFuncName, VarcharType, VarcharType, VarcharType)},
+	"ASCII":     {newBuiltin(runtime.AsciiFuncName, IntType, VarcharType)},
+	"CHR":       {newBuiltin(runtime.ChrFuncName, VarcharType, IntType)},
+
+	"ABS": {
+		newBuiltin(runtime.AbsIntFuncName, IntType, IntType),
+		newBuiltin(runtime.AbsFuncName, NumberType, NumberType),
+		newBuiltin(runtime.AbsFuncName, BinaryDoubleType, BinaryDoubleType),
+	},
+	"MOD": {
+		newBuiltin(runtime.ModIntFuncName, IntType, IntType, IntType),
+		newBuiltin(runtime.ModFuncName, NumberType, NumberType, NumberType),
+		newBuiltin(runtime.ModFuncName, BinaryDoubleType, BinaryDoubleType, BinaryDoubleType),
+	},
+	"REMAINDER": {
+		newBuiltin(runtime.RemainderFuncName, NumberType, NumberType, NumberType),
+		newBuiltin(runtime.RemainderFuncName, BinaryDoubleType, BinaryDoubleType, BinaryDoubleType),
+	},
+	// NUMBER rounds its decimal digits and halves away from zero
+	// BINARY_DOUBLE rounds its binary value and halves to the even neighbour
+	"ROUND": {
+		newBuiltin(runtime.RoundIntFuncName, IntType, IntType, IntType).withDefaults("0"),
+		newBuiltin(runtime.RoundNumberFuncName, NumberType, NumberType, IntType).withDefaults("0"),
+		newBuiltin(runtime.RoundDoubleFuncName, BinaryDoubleType, BinaryDoubleType, IntType).withDefaults("0"),
+	},
+	"TRUNC": {
+		newBuiltin(runtime.TruncIntFuncName, IntType, IntType, IntType).withDefaults("0"),
+		newBuiltin(runtime.TruncNumberFuncName, NumberType, NumberType, IntType).withDefaults("0"),
+		newBuiltin(runtime.TruncDoubleFuncName, BinaryDoubleType, BinaryDoubleType, IntType).withDefaults("0"),
+		newBuiltin(runtime.TruncDateFuncName, DateType, DateType),
+	},
+	"CEIL": {
+		newBuiltin(runtime.IntIdentityFuncName, IntType, IntType),
+		newBuiltin(runtime.CeilNumberFuncName, NumberType, NumberType),
+		newBuiltin(runtime.CeilFuncName, BinaryDoubleType, BinaryDoubleType),
+	},
+	"FLOOR": {
+		newBuiltin(runtime.IntIdentityFuncName, IntType, IntType),
+		newBuiltin(runtime.FloorNumberFuncName, NumberType, NumberType),
+		newBuiltin(runtime.FloorFuncName, BinaryDoubleType, BinaryDoubleType),
+	},
+	"POWER": {
+		newBuiltin(runtime.PowerFuncName, NumberType, NumberType, NumberType),
+		newBuiltin(runtime.PowerFuncName, BinaryDoubleType, BinaryDoubleType, BinaryDoubleType),
+	},
+	// the square root and logarithms of invalid NUMBERs raise an error, BINARY_DOUBLEs result in NaN
+	"SQRT": {
+		newBuiltin(runtime.SqrtNumberFuncName, NumberType, NumberType),
+		newBuiltin(runtime.SqrtDoubleFuncName, BinaryDoubleType, BinaryDoubleType),
+	},
+	"SIGN": {
+		newBuiltin(runtime.SignIntFuncName, IntType, IntType),
+		newBuiltin(runtime.SignFuncName, IntType, NumberType),
+		newBuiltin(runtime.SignFuncName, IntType, BinaryDoubleType),
+	},
+	"GREATEST": {
+		newBuiltin(runtime.GreatestIntFuncName, IntType, IntType).asVariadic(),
+		newBuiltin(runtime.GreatestFuncName, NumberType, NumberType).asVariadic(),
+		newBuiltin(runtime.GreatestFuncName, BinaryDoubleType, BinaryDoubleType).asVariadic(),
+	},
+	"LEAST": {
+		newBuiltin(runtime.LeastIntFuncName, IntType, IntType).asVariadic(),
+		newBuiltin(runtime.LeastFuncName, NumberType, NumberType).asVariadic(),
+		newBuiltin(runtime.LeastFuncName, BinaryDoubleType, BinaryDoubleType).asVariadic(),
+	},
+	"EXP": {
+		newBuiltin(runtime.ExpFuncName, NumberType, NumberType),
+		newBuiltin(runtime.ExpFuncName, BinaryDoubleType, BinaryDoubleType),
+	},
+	"LN": {
+		newBuiltin(runtime.LnNumberFuncName, NumberType, NumberType),
+		newBuiltin(runtime.LnDoubleFuncName, BinaryDoubleType, BinaryDoubleType),
+	},
+	"LOG": {
+		newBuiltin(runtime.LogNumberFuncName, NumberType, NumberType, NumberType),
+		newBuiltin(runtime.LogDoubleFuncName, BinaryDoubleType, BinaryDoubleType, BinaryDoubleType),
+	},
+
+	"TO_CHAR": {
+		newBuiltin(runtime.IntToStringFuncName, VarcharType, IntType),
+		newBuiltin(runtime.NumberToStringFuncName, VarcharType, NumberType),
+		newBuiltin(runtime.NumberToStringFuncName, VarcharType, BinaryDoubleType),
+		newBuiltin(runtime.FormatNumberFuncName, VarcharType, NumberType, VarcharType),
+		newBuiltin(runtime.FormatNumberFuncName, VarcharType, BinaryDoubleType, VarcharType),
+		newBuiltin(runtime.DateToStringFuncName, VarcharType, DateType),
+		newBuiltin(runtime.TimestampToStringFuncName, VarcharType, TimestampType),
+		newBuiltin(runtime.DSIntervalToStringFuncName, VarcharType, DayToSecondType),
+		newBuiltin(runtime.YMIntervalToStringFuncName, VarcharType, YearToMonthType),
+		newBuiltin(runtime.FormatDateFuncName, VarcharType, DateType, VarcharType),
+		newBuiltin(runtime.FormatDateFuncName, VarcharType, TimestampType, VarcharType),
+	},
+	"TO_NUMBER": {
+		newBuiltin(runtime.StringToNumberFuncName, NumberType, VarcharType),
+		newBuiltin(runtime.ParseNumberFuncName, NumberType, VarcharType, VarcharType),
+	},
+
+	// timestamps that are passed to functions of dates are converted to dates
+	"SYSDATE":        {newBuiltin(runtime.SysdateFuncName, DateType)},
+	"SYSTIMESTAMP":   {newBuiltin(runtime.SystimestampFuncName, TimestampType)},
+	"ADD_MONTHS":     {newBuiltin(runtime.AddMonthsFuncName, DateType, DateType, NumberType)},
+	"LAST_DAY":       {newBuiltin(runtime.LastDayFuncName, DateType, DateType)},
+	"MONTHS_BETWEEN": {newBuiltin(runtime.MonthsBetweenFuncName, NumberType, DateType, DateType)},
+	// the parser turns 'EXTRACT(YEAR FROM d)' into 'EXTRACT('YEAR', d)'
+	"EXTRACT": {
+		newBuiltin(runtime.ExtractDatetimeFuncName, NumberType, VarcharType, TimestampType),
+		newBuiltin(runtime.ExtractDayToSecondFuncName, NumberType, VarcharType, DayToSecondType),
+		newBuiltin(runtime.ExtractYearToMonthFuncName, NumberType, VarcharType, YearToMonthType),
+	},
+	"TO_DATE": {
+		newBuiltin(runtime.StringToDateFuncName, DateType, VarcharType),
+		newBuiltin(runtime.ToDateFuncName, DateType, VarcharType, VarcharType),
+	},
+	"TO_TIMESTAMP": {
+		newBuiltin(runtime.StringToTimestampFuncName, TimestampType, VarcharType),
+		newBuiltin(runtime.ToTimestampFuncName, TimestampType, VarcharType, VarcharType),
+	},
+	"NUMTODSINTERVAL": {newBuiltin(runtime.NumToDSIntervalFuncName, DayToSecondType, NumberType, VarcharType)},
+	"NUMTOYMINTERVAL": {newBuiltin(runtime.NumToYMIntervalFuncName, YearToMonthType, NumberType, VarcharType)},
+}
+
+// builtinProcedures holds the overloads of the procedures of the packages that are implemented by the runtime
+var builtinProcedures = map[string]map[string][]*builtinFunction{
+	"DBMS_OUTPUT": {
+		"PUT":      {newBuiltin(runtime.DbmsOutputPutFuncName, nil, VarcharType)},
+		"PUT_LINE": {newBuiltin(runtime.DbmsOutputPutLineFuncName, nil, VarcharType)},
+		"NEW_LINE": {newBuiltin(runtime.DbmsOutputNewLineFuncName, nil)},
+		"ENABLE":   {newBuiltin(runtime.DbmsOutputEnableFuncName, nil, IntType).withDefaults(strconv.Itoa(runtime.DbmsOutputDefaultBufferSize))},
+		"DISABLE":  {newBuiltin(runtime.DbmsOutputDisableFuncName, nil)},
+		"GET_LINE": {newBuiltin(runtime.DbmsOutputGetLineFuncName, nil, VarcharType, IntType).withOutParams(0, 1)},
+		// numlines is IN OUT, it is passed by reference like an OUT parameter
+		"GET_LINES": {
+			newBuiltin(runtime.DbmsOutputGetLinesFuncName, nil, CharArrType, IntType).withOutParams(0, 1),
+			newBuiltin(runtime.DbmsOutputGetLinesFuncName, nil, LinesArrayType, IntType).withOutParams(0, 1),
+		},
+	},
+}
+
+// param returns the type of the parameter at idx
+func (bf *builtinFunction) param(idx int) *Type {
+	if bf.variadic && idx >= len(bf.params) {
+		return bf.params[len(bf.params)-1]
+	}
+	return bf.params[idx]
+}
+
+// acceptsArgCount returns true if the function can be called with n arguments
+func (bf *builtinFunction) acceptsArgCount(n int) bool {
+	minArgs := len(bf.params) - len(bf.defaults)
+	return n >= minArgs && (bf.variadic || n <= len(bf.params))
+}
+
+// accepts returns true if the arguments can be passed without losing precision
+// numbers are only ever converted into types with a higher precedence
+func (bf *builtinFunction) accepts(argTypes []*Type) bool {
+	if !bf.acceptsArgCount(len(argTypes)) {
+		return false
+	}
+
+	for idx, t := range argTypes {
+		param := bf.param(idx)
+		if t == nil {
+			continue
+		} else if t.Equal(TimestampType) && param.Equal(DateType) {
+			// timestamps would lose their fractions of a second
+			return false
+		} else if param.IsNumeric() && numericPrecedence(t) > 0 {
+			if numericPrecedence(t) > numericPrecedence(param) {
+				return false
+			}
+		} else if !canConvert(t, param) {
+			return false
+		}
+	}
+	return true
+}
+
+// resolveBuiltin picks the first overload that accepts the arguments
+// if none does, the last one is picked so that the checker can report what doesn't fit
+func resolveBuiltin(overloads []*builtinFunction, argTypes []*Type) *builtinFunction {
+	for _, bf := range overloads {
+		if bf.accepts(argTypes) {
+			return bf
+		}
+	}
+	return overloads[len(overloads)-1]
+}
+
+// defaultArg creates the expression for a parameter that has been left out
+func (bf *builtinFunction) defaultArg(idx int) Expression {
+	literal := bf.defaults[idx-(len(bf.params)-len(bf.defaults))]
+	if literal[0] == '\'' {
+		return NewStringLiteral(literal)
+	}
+	return NewNumericLiteral(literal)
+}
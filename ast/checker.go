@@ -0,0 +1,1864 @@
+/*
+ * Copyright 2019 Marco Helmich
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ast
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Diagnostic is an error the checker found in a program
+type Diagnostic struct {
+	Package  string
+	Function string
+	Message  string
+	// source position of the statement or declaration with the error
+	// line and column are 0 for errors outside of functions
+	File   string
+	Line   int
+	Column int
+}
+
+func (d *Diagnostic) String() string {
+	var s string
+	if d.Line > 0 {
+		s = fmt.Sprintf("%s:%d:%d: ", d.File, d.Line, d.Column)
+	}
+	if d.Function == "" {
+		return s + fmt.Sprintf("%s: %s", d.Package, d.Message)
+	}
+	return s + fmt.Sprintf("%s.%s: %s", d.Package, d.Function, d.Message)
+}
+
+// symbol is a variable, constant or parameter that can be referenced by name
+type symbol struct {
+	typ *Type
+	// constants and IN parameters can't be assigned to
+	readOnly bool
+}
+
+type checker struct {
+	pkgs            map[string]*Package
+	currentPackage  *Package
+	currentFunction *Function
+	// source position of what is checked right now
+	line   int
+	column int
+	// local scopes of the current function, the innermost scope comes last
+	scopes []map[string]*symbol
+	// maps qualified names to package variables and constants
+	globals map[string]*symbol
+	// maps qualified names to declared types and records
+	types map[string]*Type
+	// qualified names of types that are declared in a package body
+	privateTypes map[string]bool
+	// the tables embedded SQL runs against, nil if the schema of the database isn't known
+	schema      *Schema
+	diagnostics []*Diagnostic
+}
+
+// Check resolves all names in a program and annotates every expression with its type
+// it runs before any llvm ir is generated and returns all errors it finds
+func Check(pkgs map[string]*Package) []*Diagnostic {
+	return CheckWithSchema(pkgs, nil)
+}
+
+// CheckWithSchema checks a program like Check and the tables and columns of its embedded SQL against a schema
+func CheckWithSchema(pkgs map[string]*Package, schema *Schema) []*Diagnostic {
+	c := &checker{
+		pkgs:         pkgs,
+		globals:      make(map[string]*symbol),
+		types:        make(map[string]*Type),
+		privateTypes: make(map[string]bool),
+		schema:       schema,
+		diagnostics:  make([]*Diagnostic, 0),
+	}
+
+	sorted := SortPackages(pkgs)
+	// declarations of all packages need to be known before any code can be checked
+	for _, p := range sorted {
+		c.currentPackage = p
+		c.checkDeclarations(p)
+	}
+	for _, p := range sorted {
+		c.currentPackage = p
+		c.checkPackage(p)
+	}
+	c.currentPackage = nil
+	return c.diagnostics
+}
+
+func (c *checker) errorf(format string, args ...interface{}) {
+	d := &Diagnostic{
+		Package: c.currentPackage.Name,
+		Message: fmt.Sprintf(format, args...),
+	}
+	if c.currentFunction != nil {
+		d.Function = c.currentFunction.Proto.Name
+		d.File = c.currentFunction.File
+		d.Line, d.Column = c.line, c.column
+	}
+	c.diagnostics = append(c.diagnostics, d)
+}
+
+func (c *checker) checkDeclarations(p *Package) {
+	if p.Spec != nil {
+		for idx := range p.Spec.Types {
+			c.declareType(p.Spec.Types[idx], false)
+		}
+		for idx := range p.Spec.Variables {
+			c.declareVariable(p.Spec.Variables[idx])
+		}
+	}
+	for idx := range p.types {
+		c.declareType(p.types[idx], true)
+	}
+	for idx := range p.variables {
+		c.declareVariable(p.variables[idx])
+	}
+}
+
+func (c *checker) declareType(td TypeDeclaration, isPrivate bool) {
+	var name string
+	var t *Type
+	switch x := td.(type) {
+	case *Subtype:
+		name = x.Name
+		t = c.resolveType(x.BaseType)
+	case *RecordType:
+		name = x.Name
+		for idx := range x.Fields {
+			c.resolveType(x.Fields[idx].Type)
+		}
+		t = &Type{Name: c.currentPackage.Name + "." + x.Name, Record: x}
+	case *CollectionType:
+		name = x.Name
+		x.element = c.resolveType(x.ElementType)
+		if x.element != nil && (x.element.IsCollection() || x.element.isCursor()) {
+			c.errorf("Collections of '%s' are not implemented yet", x.element.String())
+		} else if x.element != nil {
+			t = &Type{Name: c.currentPackage.Name + "." + x.Name, Collection: x}
+		}
+	case *RefCursor:
+		name = x.Name
+		t = &Type{Name: c.currentPackage.Name + "." + x.Name, refCursor: true}
+		if x.ReturnType != "" {
+			t.Returns = c.resolveType(x.ReturnType)
+			if t.Returns != nil && !t.Returns.IsRecord() {
+				c.errorf("PLS-00362: invalid cursor return type, '%s' must be a record type", t.Returns.String())
+			}
+		}
+	}
+
+	qualifiedName := c.currentPackage.Name + "." + name
+	if _, ok := c.types[qualifiedName]; ok {
+		c.errorf("PLS-00371: at most one declaration for '%s' is permitted", name)
+		return
+	}
+
+	if t != nil {
+		c.types[qualifiedName] = t
+		c.privateTypes[qualifiedName] = isPrivate
+	}
+}
+
+func (c *checker) declareVariable(pv *PackageVariable) {
+	qualifiedName := c.currentPackage.Name + "." + pv.Name
+	if _, ok := c.globals[qualifiedName]; ok {
+		c.errorf("PLS-00371: at most one declaration for '%s' is permitted", pv.Name)
+		return
+	}
+
+	t := c.resolveType(pv.Typ)
+	if pv.IsConstant && pv.Value == "" {
+		c.errorf("PLS-00322: declaration of constant '%s' must contain an initialization assignment", pv.Name)
+	}
+	c.checkInitialValue(pv.Name, t, pv.Value)
+	if t != nil {
+		c.globals[qualifiedName] = &symbol{typ: t, readOnly: pv.IsConstant}
+	}
+}
+
+// checkInitialValue makes sure that the literal a variable is initialized with can be converted into its type
+func (c *checker) checkInitialValue(name string, t *Type, value string) {
+	if t == nil || value == "" {
+		return
+	}
+
+	// collections can only be initialized empty ('list list_t := list_t()')
+	if strings.HasSuffix(value, "()") {
+		if ct := c.lookupType(c.currentPackage.Name, strings.TrimSuffix(value, "()")); ct == nil || !ct.Equal(t) {
+			c.errorf("PLS-00382: expression is of wrong type, '%s' is '%s' but is initialized with '%s'", name, t.String(), value)
+		}
+		return
+	} else if t.IsCollection() {
+		c.errorf("PLS-00382: expression is of wrong type, '%s' is '%s' but is initialized with '%s'", name, t.String(), value)
+		return
+	}
+
+	valueType := literalType(value)
+	if !canConvert(valueType, t) {
+		c.errorf("PLS-00382: expression is of wrong type, '%s' is '%s' but is initialized with '%s'", name, t.String(), valueType.String())
+	} else if _, err := convertLiteral(t, value); err != nil {
+		c.errorf("%s, '%s' can't be initialized with %s", err.Error(), name, value)
+	}
+}
+
+// convert returns an expression that converts expr from its type into type to
+// it returns nil if there is no implicit conversion between the two types
+func (c *checker) convert(expr Expression, from *Type, to *Type) Expression {
+	if from.isRefCursor() || to.isRefCursor() {
+		// cursor variables point to their cursors, they are copied as they are
+		if refCursorsMatch(from, to) {
+			return expr
+		}
+		return nil
+	}
+	// values of constrained types are checked (and padded) even if the type doesn't change
+	if from.Equal(to) && (to.Length == 0 || to.Length == from.Length) {
+		return expr
+	} else if !canConvert(from, to) {
+		return nil
+	}
+	return newConversion(expr, to)
+}
+
+func (c *checker) checkPackage(p *Package) {
+	// the types of parameters are compared once they are resolved
+	sameType := func(a string, b string) bool {
+		ta, tb := c.lookupType(p.Name, a), c.lookupType(p.Name, b)
+		if ta == nil || tb == nil {
+			return a == b
+		}
+		return ta.Equal(tb)
+	}
+	if err := p.checkSpecConformance(sameType); err != nil {
+		c.errorf("%s", err.Error())
+	}
+
+	if p.Spec != nil {
+		for idx := range p.Spec.Protos {
+			c.checkProto(p.Spec.Protos[idx])
+		}
+	}
+
+	for idx := range p.functions {
+		c.checkFunction(p.functions[idx])
+	}
+	if p.initFunction != nil {
+		c.checkFunction(p.initFunction)
+	}
+}
+
+func (c *checker) checkProto(fp *FunctionProto) {
+	for idx := range fp.Params {
+		c.resolveType(fp.Params[idx].Type)
+	}
+	if !fp.IsProcedure() {
+		c.resolveType(fp.ReturnType)
+	}
+}
+
+func (c *checker) checkFunction(f *Function) {
+	c.currentFunction = f
+	c.scopes = []map[string]*symbol{make(map[string]*symbol)}
+	c.line, c.column = f.Line, f.Column
+	defer func() {
+		c.currentFunction = nil
+		c.scopes = nil
+		c.line, c.column = 0, 0
+	}()
+
+	if !f.Proto.IsProcedure() {
+		c.resolveType(f.Proto.ReturnType)
+	}
+	for idx := range f.Proto.Params {
+		fp := f.Proto.Params[idx]
+		if t := c.resolveType(fp.Type); t != nil {
+			c.declareLocal(fp.Name, &symbol{typ: t, readOnly: fp.Ownership == "IN"})
+		}
+	}
+
+	for idx := range f.Locals {
+		fl := f.Locals[idx]
+		if fl.Line > 0 {
+			c.line, c.column = fl.Line, fl.Column
+		}
+		t := c.resolveType(fl.Typ)
+		c.checkInitialValue(fl.Name, t, fl.Value)
+		if t != nil {
+			c.declareLocal(fl.Name, &symbol{typ: t})
+		}
+	}
+
+	for idx := range f.Cursors {
+		c.checkCursor(f.Cursors[idx])
+	}
+
+	for _, blk := range f.Blocks {
+		for idx, i := range blk.Instructions {
+			c.line, c.column = blk.position(idx)
+			c.checkInstruction(i)
+		}
+		if blk.Terminator != nil {
+			c.line, c.column = blk.position(len(blk.Instructions))
+			c.checkInstruction(blk.Terminator)
+		}
+	}
+}
+
+func (c *checker) declareLocal(name string, sym *symbol) {
+	s := c.scopes[len(c.scopes)-1]
+	if _, ok := s[name]; ok {
+		c.errorf("PLS-00371: at most one declaration for '%s' is permitted", name)
+		return
+	}
+	s[name] = sym
+}
+
+func (c *checker) checkInstruction(i Instruction) {
+	switch x := i.(type) {
+	case *Assignment:
+		c.checkAssignment(x)
+
+	case *FunctionCall:
+		c.checkCall(x, true)
+
+	case *Retrn:
+		c.checkReturn(x)
+
+	case *ConditionalBranch:
+		if t := c.checkExpression(x.Condition); t != nil && !t.Equal(BooleanType) {
+			c.errorf("PLS-00382: expression is of wrong type, condition is '%s' but needs to be '%s'", t.String(), BooleanType.String())
+		}
+
+	case *SqlStatement:
+		c.checkSqlStatement(x)
+
+	case *OpenCursor:
+		c.checkOpenCursor(x)
+
+	case *FetchCursor:
+		if x.Limit != nil {
+			x.Limit = c.convertToInt(x.Limit, "the limit")
+		}
+		if cursor := c.resolveCursor(x.Cursor); cursor != nil {
+			into := x.Into
+			if x.Bulk {
+				if x.bulk, into = c.checkBulkCollect(x.Into); x.bulk == nil {
+					break
+				}
+			} else {
+				x.Into = c.expandRecordInto(x.Into)
+				into = x.Into
+			}
+			x.columns, x.intoValues = c.checkSqlIntoList(into, x.Bulk)
+			if cursor.Cursor != nil {
+				c.checkSelectList(cursor.Cursor.Query.queries[0], into)
+			} else if cursor.Returns != nil {
+				c.checkIntoColumns(c.recordColumns(cursor.Returns), into)
+			}
+		}
+
+	case *CloseCursor:
+		c.resolveCursor(x.Cursor)
+
+	case *CursorForLoop:
+		c.checkCursorForLoop(x)
+
+	case *ExecuteImmediate:
+		c.checkDynamicSql(x.Statement, x.Using)
+		x.columns, x.intoValues = c.checkSqlIntoList(x.Into, false)
+
+	case *OpenCursorFor:
+		c.checkOpenCursorFor(x)
+
+	case *ForAll:
+		c.checkForAll(x)
+
+	case *EndCursorForLoop:
+		c.scopes = c.scopes[:len(c.scopes)-1]
+
+	case *Commit, *Rollback, *Savepoint:
+		// nothing to check
+
+	case *Branch:
+		// nothing to check
+
+	default:
+		c.errorf("Can't check instruction '%s'", i.String())
+	}
+}
+
+func (c *checker) checkAssignment(a *Assignment) {
+	if a.Element != nil {
+		c.checkElementAssignment(a)
+		return
+	}
+
+	target := c.resolveVariable(a.Target)
+	exprType := c.checkExpression(a.Expr)
+	if target == nil {
+		return
+	}
+
+	a.Target.setType(target.typ)
+	if target.readOnly {
+		c.errorf("PLS-00363: expression '%s' cannot be used as an assignment target", a.Target.Name)
+	}
+
+	if exprType == nil {
+		return
+	}
+
+	if converted := c.convert(a.Expr, exprType, target.typ); converted != nil {
+		a.Expr = converted
+	} else {
+		c.errorf("PLS-00382: expression is of wrong type, can't assign '%s' to '%s' of type '%s'", exprType.String(), a.Target.Name, target.typ.String())
+	}
+}
+
+// checkElementAssignment checks an assignment to an element of a collection ('list(i) := x')
+func (c *checker) checkElementAssignment(a *Assignment) {
+	sym := c.resolveVariable(a.Element.Collection)
+	a.Element.Index = c.convertToInt(a.Element.Index, "the index of a collection")
+	target := c.checkElement(a.Element, sym, true)
+	exprType := c.checkExpression(a.Expr)
+	if target == nil || exprType == nil {
+		return
+	}
+
+	if converted := c.convert(a.Expr, exprType, target); converted != nil {
+		a.Expr = converted
+	} else {
+		c.errorf("PLS-00382: expression is of wrong type, can't assign '%s' to an element of '%s' of type '%s'", exprType.String(), a.Element.Collection.Name, target.String())
+	}
+}
+
+func (c *checker) checkReturn(r *Retrn) {
+	proto := c.currentFunction.Proto
+	if proto.IsProcedure() {
+		if r.expr != nil {
+			c.errorf("PLS-00372: In a procedure, RETURN statement cannot contain an expression")
+			c.checkExpression(r.expr)
+		}
+		return
+	}
+
+	if r.expr == nil {
+		c.errorf("PLS-00503: RETURN <value> statement required for this return from function")
+		return
+	}
+
+	t := c.checkExpression(r.expr)
+	retType := c.resolveType(proto.ReturnType)
+	if t == nil || retType == nil {
+		return
+	}
+
+	if converted := c.convert(r.expr, t, retType); converted != nil {
+		r.expr = converted
+	} else {
+		c.errorf("PLS-00382: expression is of wrong type, '%s' returns '%s' but got '%s'", proto.Name, retType.String(), t.String())
+	}
+}
+
+// checkExpression determines the type of an expression and annotates the expression with it
+// it returns nil if the type can't be determined, the reason has been reported already
+func (c *checker) checkExpression(e Expression) *Type {
+	var t *Type
+	switch x := e.(type) {
+	case *NumericLiteral:
+		t = IntType
+		if x.IsDecimal {
+			t = NumberType
+		}
+		if x.outOfRange {
+			c.errorf("ORA-01426: numeric overflow, literal '%s' doesn't fit into %s", x.text, t.String())
+		}
+
+	case *StringLiteral:
+		t = VarcharType
+
+	case *Variable:
+		// 'list.COUNT' is a method of a collection
+		if sym, ok := c.findSymbol(x.Qualifier); ok && x.Qualifier != "" && sym.typ.IsCollection() {
+			x.method, t = c.checkCollectionMethod(NewVariable(x.Qualifier), sym, x.Name, nil, nil, false)
+		} else if sym := c.resolveVariable(x); sym != nil {
+			t = sym.typ
+		}
+
+	case *CollectionElement:
+		sym := c.resolveVariable(x.Collection)
+		x.Index = c.convertToInt(x.Index, "the index of a collection")
+		t = c.checkElement(x, sym, false)
+
+	case *FunctionCall:
+		t = c.checkCall(x, false)
+
+	case *BinOp:
+		t = c.checkBinOp(x)
+
+	case *CursorAttribute:
+		t = c.checkCursorAttribute(x)
+
+	case *sqlColumn:
+		// columns are created by the checker with their type
+		t = x.typ
+
+	default:
+		c.errorf("Can't check expression '%s'", e.String())
+	}
+
+	if t != nil {
+		e.setType(t)
+	}
+	return t
+}
+
+func (c *checker) checkBinOp(bo *BinOp) *Type {
+	l := c.checkExpression(bo.Left)
+	r := c.checkExpression(bo.Right)
+	if l == nil || r == nil {
+		return nil
+	}
+
+	if bo.Op != "||" && (l.isDatetime() || l.isInterval() || r.isDatetime() || r.isInterval()) {
+		if t := c.checkDatetimeOperation(bo, l, r); t != nil {
+			return t
+		}
+		c.errorf("PLS-00306: wrong number or types of arguments in call to '%s', got '%s' and '%s'", bo.Op, l.String(), r.String())
+		return nil
+	}
+
+	switch bo.Op {
+	case "+", "-", "*":
+		if t := widestNumericType(l, r); t != nil && c.convertOperands(bo, l, r, t) {
+			return t
+		}
+
+	case "/":
+		// dividing integers results in a number ('7 / 2' is 3.5)
+		if t := widestNumericType(l, r, NumberType); t != nil && c.convertOperands(bo, l, r, t) {
+			return t
+		}
+
+	case "=", "<>", "!=":
+		if l.IsString() && r.IsString() {
+			// CHAR values are compared blank-padded, as soon as one side is a VARCHAR they aren't
+			bo.blankPadded = isBlankPadded(bo.Left) && isBlankPadded(bo.Right)
+			return BooleanType
+		} else if l.Equal(r) && l.IsNumeric() {
+			return BooleanType
+		} else if l.IsNumeric() || r.IsNumeric() {
+			// strings are compared to numbers as numbers
+			if t := widestNumericType(l, r, NumberType); t != nil && c.convertOperands(bo, l, r, t) {
+				return BooleanType
+			}
+		}
+
+	case "<", ">", "<=", ">=":
+		if l.Equal(r) && l.IsNumeric() {
+			return BooleanType
+		} else if l.IsNumeric() || r.IsNumeric() {
+			if t := widestNumericType(l, r, NumberType); t != nil && c.convertOperands(bo, l, r, t) {
+				return BooleanType
+			}
+		}
+
+	case "||":
+		if c.convertOperands(bo, l, r, VarcharType) {
+			return VarcharType
+		}
+
+	default:
+		c.errorf("Operator '%s' is not supported", bo.Op)
+		return nil
+	}
+
+	c.errorf("PLS-00306: wrong number or types of arguments in call to '%s', got '%s' and '%s'", bo.Op, l.String(), r.String())
+	return nil
+}
+
+// checkDatetimeOperation checks arithmetic and comparisons with dates, timestamps and intervals
+// numbers added to dates and timestamps are days and result in a date
+// subtracting two dates results in the number of days between them
+// and subtracting timestamps results in an INTERVAL DAY TO SECOND
+// it returns nil if the operation isn't supported for the two types
+func (c *checker) checkDatetimeOperation(bo *BinOp, l *Type, r *Type) *Type {
+	switch bo.Op {
+	case "+":
+		switch {
+		case l.isDatetime() && r.IsNumeric():
+			return c.convertOperandsTo(bo, l, r, DateType, NumberType, DateType)
+		case l.IsNumeric() && r.isDatetime():
+			return c.convertOperandsTo(bo, l, r, NumberType, DateType, DateType)
+		case l.isDatetime() && r.isInterval():
+			return l
+		case l.isInterval() && r.isDatetime():
+			return r
+		case l.isInterval() && l.Equal(r):
+			return l
+		}
+
+	case "-":
+		switch {
+		case l.isDatetime() && r.IsNumeric():
+			return c.convertOperandsTo(bo, l, r, DateType, NumberType, DateType)
+		case l.Equal(DateType) && r.Equal(DateType):
+			return NumberType
+		case l.isDatetime() && r.isDatetime():
+			return c.convertOperandsTo(bo, l, r, TimestampType, TimestampType, DayToSecondType)
+		case l.isDatetime() && r.isInterval():
+			return l
+		case l.isInterval() && l.Equal(r):
+			return l
+		}
+
+	case "*":
+		if l.isInterval() && r.IsNumeric() {
+			return c.convertOperandsTo(bo, l, r, l, NumberType, l)
+		} else if l.IsNumeric() && r.isInterval() {
+			return c.convertOperandsTo(bo, l, r, NumberType, r, r)
+		}
+
+	case "/":
+		if l.isInterval() && r.IsNumeric() {
+			return c.convertOperandsTo(bo, l, r, l, NumberType, l)
+		}
+
+	case "=", "<>", "!=", "<", ">", "<=", ">=":
+		// dates are compared to timestamps as timestamps and strings are converted
+		var t *Type
+		switch {
+		case l.isDatetime() && r.isDatetime():
+			t = DateType
+			if l.Equal(TimestampType) || r.Equal(TimestampType) {
+				t = TimestampType
+			}
+		case (l.isDatetime() || l.isInterval()) && (r.Equal(l) || r.IsString()):
+			t = l
+		case l.IsString() && (r.isDatetime() || r.isInterval()):
+			t = r
+		default:
+			return nil
+		}
+		return c.convertOperandsTo(bo, l, r, t, t, BooleanType)
+	}
+	return nil
+}
+
+// convertOperandsTo converts the left operand of bo into lt and the right one into rt
+// it returns the type of the result or nil if one of the operands can't be converted
+func (c *checker) convertOperandsTo(bo *BinOp, l *Type, r *Type, lt *Type, rt *Type, result *Type) *Type {
+	left := c.convert(bo.Left, l, lt)
+	right := c.convert(bo.Right, r, rt)
+	if left == nil || right == nil {
+		return nil
+	}
+
+	bo.Left = left
+	bo.Right = right
+	return result
+}
+
+// isBlankPadded returns true for expressions that are compared with blank-padding semantics
+// which are values of CHAR types and string literals
+func isBlankPadded(e Expression) bool {
+	if _, ok := e.(*StringLiteral); ok {
+		return true
+	}
+	return e.Type().Equal(CharType)
+}
+
+// convertOperands converts both operands of bo into type to
+// it returns false if one of them can't be converted
+func (c *checker) convertOperands(bo *BinOp, l *Type, r *Type, to *Type) bool {
+	left := c.convert(bo.Left, l, to)
+	right := c.convert(bo.Right, r, to)
+	if left == nil || right == nil {
+		return false
+	}
+
+	bo.Left = left
+	bo.Right = right
+	return true
+}
+
+// checkCall checks a call of a subprogram and returns the type of its result
+// procedures can only be called as statements and functions only as part of an expression
+func (c *checker) checkCall(fc *FunctionCall, isStatement bool) *Type {
+	argTypes := make([]*Type, len(fc.Args))
+	for idx := range fc.Args {
+		argTypes[idx] = c.checkExpression(fc.Args[idx])
+	}
+
+	if t, ok := c.checkCollectionCall(fc, argTypes, isStatement); ok {
+		return t
+	}
+
+	pkgName := fc.ModuleName
+	if pkgName == "" {
+		pkgName = c.currentPackage.Name
+	}
+
+	if pkgName == "DBMS" {
+		return c.checkRuntimeCall(fc, argTypes, isStatement)
+	} else if procedures, ok := builtinProcedures[pkgName]; ok && fc.ModuleName != "" {
+		overloads, ok := procedures[fc.FunctionName]
+		if !ok {
+			c.errorf("PLS-00302: component '%s' must be declared", fc.FunctionName)
+			return nil
+		}
+		return c.checkBuiltinCall(fc, resolveBuiltin(overloads, argTypes), argTypes, isStatement)
+	}
+
+	// subprograms of the current package shadow built-in functions
+	if overloads, ok := builtinFunctions[fc.FunctionName]; ok && fc.ModuleName == "" && c.currentPackage.findProto(fc.FunctionName) == nil {
+		return c.checkBuiltinCall(fc, resolveBuiltin(overloads, argTypes), argTypes, isStatement)
+	}
+
+	pkg, ok := c.pkgs[pkgName]
+	if !ok {
+		c.errorf("PLS-00201: identifier '%s.%s' must be declared", pkgName, fc.FunctionName)
+		return nil
+	}
+
+	proto := pkg.findProto(fc.FunctionName)
+	if proto == nil {
+		if fc.ModuleName == "" {
+			c.errorf("PLS-00201: identifier '%s' must be declared", fc.FunctionName)
+		} else {
+			c.errorf("PLS-00302: component '%s' must be declared", fc.FunctionName)
+		}
+		return nil
+	}
+
+	if pkgName != c.currentPackage.Name && !pkg.isPublicFunction(fc.FunctionName) {
+		c.errorf("PLS-00302: component '%s' must be declared, it is private to package '%s'", fc.FunctionName, pkgName)
+		return nil
+	}
+
+	if isStatement && !proto.IsProcedure() {
+		c.errorf("PLS-00221: '%s' is not a procedure or is undefined", fc.FunctionName)
+	} else if !isStatement && proto.IsProcedure() {
+		c.errorf("PLS-00222: no function with name '%s' exists in this scope", fc.FunctionName)
+		return nil
+	}
+
+	if len(fc.Args) != len(proto.Params) {
+		c.errorf("PLS-00306: wrong number or types of arguments in call to '%s', expected %d but got %d", fc.FunctionName, len(proto.Params), len(fc.Args))
+		return nil
+	}
+
+	// the types of the callee are declared in the package of the callee
+	paramTypes := make([]*Type, len(proto.Params))
+	for idx := range proto.Params {
+		paramTypes[idx] = c.lookupType(pkgName, proto.Params[idx].Type)
+	}
+	var retType *Type
+	if !proto.IsProcedure() {
+		retType = c.lookupType(pkgName, proto.ReturnType)
+	}
+
+	for idx := range fc.Args {
+		if proto.Params[idx].isByReference() {
+			variable, ok := fc.Args[idx].(*Variable)
+			if !ok {
+				c.errorf("PLS-00363: argument %d of '%s' cannot be used as an assignment target", idx+1, fc.FunctionName)
+				continue
+			}
+			if sym := c.resolveVariable(variable); sym != nil && sym.readOnly {
+				c.errorf("PLS-00363: expression '%s' cannot be used as an assignment target", variable.Name)
+			}
+		}
+
+		if argTypes[idx] == nil || paramTypes[idx] == nil {
+			continue
+		}
+
+		// arguments that are passed by reference can't be converted
+		var converted Expression
+		if proto.Params[idx].isByReference() {
+			if paramTypes[idx].Equal(argTypes[idx]) || (refCursorsMatch(argTypes[idx], paramTypes[idx]) && refCursorsMatch(paramTypes[idx], argTypes[idx])) {
+				converted = fc.Args[idx]
+			}
+		} else {
+			converted = c.convert(fc.Args[idx], argTypes[idx], paramTypes[idx])
+		}
+
+		if converted == nil {
+			c.errorf("PLS-00306: wrong number or types of arguments in call to '%s', argument %d is '%s' but needs to be '%s'", fc.FunctionName, idx+1, argTypes[idx].String(), paramTypes[idx].String())
+			continue
+		}
+		fc.Args[idx] = converted
+	}
+
+	return retType
+}
+
+// checkCollectionCall checks calls that are elements ('list(i)'), methods ('list.EXTEND(2)')
+// or constructors ('list_t(1, 2)') of collections, it returns false for calls that are none of them
+// names of variables shadow names of subprograms
+func (c *checker) checkCollectionCall(fc *FunctionCall, argTypes []*Type, isStatement bool) (*Type, bool) {
+	v := NewVariable(fc.FunctionName)
+	if fc.ModuleName != "" {
+		v = NewQualifiedVariable(fc.ModuleName, fc.FunctionName)
+	}
+	if sym := c.lookupVariable(v); sym != nil && sym.typ.IsCollection() {
+		if isStatement {
+			c.errorf("PLS-00221: '%s' is not a procedure or is undefined", fc.FunctionName)
+			return nil, true
+		} else if len(fc.Args) != 1 {
+			c.errorf("PLS-00306: wrong number or types of arguments in call to '%s'", fc.FunctionName)
+			return nil, true
+		}
+
+		index := fc.Args[0]
+		if argTypes[0] != nil {
+			if index = c.convert(fc.Args[0], argTypes[0], IntType); index == nil {
+				c.errorf("PLS-00382: expression is of wrong type, the index of a collection is '%s' but needs to be '%s'", argTypes[0].String(), IntType.String())
+				return nil, true
+			}
+		}
+		fc.element = NewCollectionElement(v, index, "")
+		return c.checkElement(fc.element, c.resolveVariable(v), false), true
+	}
+
+	if sym, ok := c.findSymbol(fc.ModuleName); ok && fc.ModuleName != "" && sym.typ.IsCollection() {
+		var t *Type
+		fc.method, t = c.checkCollectionMethod(NewVariable(fc.ModuleName), sym, fc.FunctionName, fc.Args, argTypes, isStatement)
+		return t, true
+	}
+
+	name := fc.FunctionName
+	if fc.ModuleName != "" {
+		name = fc.ModuleName + "." + fc.FunctionName
+	}
+	if t := c.lookupType(c.currentPackage.Name, name); t != nil && t.IsCollection() {
+		return c.checkConstructor(fc, c.resolveType(name), argTypes, isStatement), true
+	}
+	return nil, false
+}
+
+// checkConstructor checks a call of the constructor of a collection type
+// associative arrays don't have one
+func (c *checker) checkConstructor(fc *FunctionCall, t *Type, argTypes []*Type, isStatement bool) *Type {
+	if t == nil {
+		return nil
+	} else if t.Collection.IsAssociative {
+		c.errorf("PLS-00222: no function with name '%s' exists in this scope", fc.FunctionName)
+		return nil
+	} else if isStatement {
+		c.errorf("PLS-00221: '%s' is not a procedure or is undefined", fc.FunctionName)
+		return nil
+	} else if t.Collection.IsVarray && int64(len(fc.Args)) > t.Collection.Limit {
+		c.errorf("ORA-06532: Subscript outside of limit, '%s' holds at most %d element(s) but got %d", fc.FunctionName, t.Collection.Limit, len(fc.Args))
+		return nil
+	}
+
+	element := t.Collection.element
+	for idx := range fc.Args {
+		if argTypes[idx] == nil {
+			continue
+		}
+		if converted := c.convert(fc.Args[idx], argTypes[idx], element); converted != nil {
+			fc.Args[idx] = converted
+		} else {
+			c.errorf("PLS-00306: wrong number or types of arguments in call to '%s', argument %d is '%s' but needs to be '%s'", fc.FunctionName, idx+1, argTypes[idx].String(), element.String())
+		}
+	}
+	fc.constructor = t
+	return t
+}
+
+// checkCollectionMethod checks a method of a collection and returns the type of its result
+// COUNT, FIRST, LAST, LIMIT, NEXT, PRIOR and EXISTS are functions, EXTEND, TRIM and DELETE are procedures
+// varrays can only be deleted as a whole and associative arrays can't be extended or trimmed
+func (c *checker) checkCollectionMethod(v *Variable, sym *symbol, name string, args []Expression, argTypes []*Type, isStatement bool) (*collectionMethod, *Type) {
+	v.setType(sym.typ)
+	ct := sym.typ.Collection
+	var returnType *Type
+	minArgs, maxArgs := 0, 0
+	switch name {
+	case "COUNT", "FIRST", "LAST", "LIMIT":
+		returnType = IntType
+	case "NEXT", "PRIOR":
+		returnType = IntType
+		minArgs, maxArgs = 1, 1
+	case "EXISTS":
+		returnType = BooleanType
+		minArgs, maxArgs = 1, 1
+	case "EXTEND", "TRIM":
+		maxArgs = 1
+		if ct.IsAssociative {
+			maxArgs = -1
+		}
+	case "DELETE":
+		maxArgs = 2
+		if ct.IsVarray {
+			maxArgs = 0
+		}
+	default:
+		c.errorf("PLS-00302: component '%s' must be declared", name)
+		return nil, nil
+	}
+
+	if isStatement && returnType != nil {
+		c.errorf("PLS-00221: '%s' is not a procedure or is undefined", name)
+		return nil, nil
+	} else if !isStatement && returnType == nil {
+		c.errorf("PLS-00222: no function with name '%s' exists in this scope", name)
+		return nil, nil
+	} else if len(args) < minArgs || len(args) > maxArgs {
+		c.errorf("PLS-00306: wrong number or types of arguments in call to '%s'", name)
+		return nil, nil
+	} else if isStatement && sym.readOnly {
+		c.errorf("PLS-00363: expression '%s' cannot be used as an assignment target", v.Name)
+		return nil, nil
+	}
+
+	for idx := range args {
+		if argTypes[idx] == nil {
+			continue
+		}
+		if converted := c.convert(args[idx], argTypes[idx], IntType); converted != nil {
+			args[idx] = converted
+		} else {
+			c.errorf("PLS-00306: wrong number or types of arguments in call to '%s', argument %d is '%s' but needs to be '%s'", name, idx+1, argTypes[idx].String(), IntType.String())
+		}
+	}
+	return &collectionMethod{collection: v, name: name, args: args}, returnType
+}
+
+// checkElement checks an element of a collection that has been resolved to sym and returns the type of the element
+// or the type of its field
+func (c *checker) checkElement(ce *CollectionElement, sym *symbol, isTarget bool) *Type {
+	if sym == nil {
+		return nil
+	}
+	ce.Collection.setType(sym.typ)
+	if !sym.typ.IsCollection() {
+		c.errorf("PLS-00487: Invalid reference to variable '%s'", ce.Collection.Name)
+		return nil
+	} else if isTarget && sym.readOnly {
+		c.errorf("PLS-00363: expression '%s' cannot be used as an assignment target", ce.Collection.Name)
+	}
+
+	t := sym.typ.Collection.element
+	if ce.Field == "" {
+		return t
+	} else if !t.IsRecord() {
+		c.errorf("PLS-00487: Invalid reference to an element of '%s', it isn't a record", ce.Collection.Name)
+		return nil
+	}
+
+	idx := t.Record.fieldIndex(ce.Field)
+	if idx < 0 {
+		c.errorf("PLS-00302: component '%s' must be declared in record '%s'", ce.Field, t.Record.Name)
+		return nil
+	}
+	return c.lookupType(t.packageName(), t.Record.Fields[idx].Type)
+}
+
+// checkBuiltinCall checks calls of built-in functions
+// parameters that have been left out are filled in with their defaults
+func (c *checker) checkBuiltinCall(fc *FunctionCall, bf *builtinFunction, argTypes []*Type, isStatement bool) *Type {
+	if isStatement && bf.returnType != nil {
+		c.errorf("PLS-00221: '%s' is not a procedure or is undefined", fc.FunctionName)
+		return nil
+	} else if !isStatement && bf.returnType == nil {
+		c.errorf("PLS-00222: no function with name '%s' exists in this scope", fc.FunctionName)
+		return nil
+	}
+
+	if !bf.acceptsArgCount(len(fc.Args)) {
+		c.errorf("PLS-00306: wrong number or types of arguments in call to '%s'", fc.FunctionName)
+		return nil
+	}
+
+	for idx := len(fc.Args); idx < len(bf.params); idx++ {
+		arg := bf.defaultArg(idx)
+		fc.AddArg(arg)
+		argTypes = append(argTypes, c.checkExpression(arg))
+	}
+
+	for idx := range fc.Args {
+		if argTypes[idx] == nil {
+			continue
+		}
+
+		var converted Expression
+		if bf.isOutParam(idx) {
+			converted = c.checkOutArg(fc, idx, argTypes[idx], bf.param(idx))
+		} else {
+			converted = c.convert(fc.Args[idx], argTypes[idx], bf.param(idx))
+		}
+		if converted == nil {
+			c.errorf("PLS-00306: wrong number or types of arguments in call to '%s', argument %d is '%s' but needs to be '%s'", fc.FunctionName, idx+1, argTypes[idx].String(), bf.param(idx).String())
+			continue
+		}
+		fc.Args[idx] = converted
+	}
+
+	fc.builtin = bf
+	return bf.returnType
+}
+
+// checkOutArg checks that the argument at idx can be passed to an OUT parameter of type t
+// it has to be a variable of exactly that type
+func (c *checker) checkOutArg(fc *FunctionCall, idx int, argType *Type, t *Type) Expression {
+	variable, ok := fc.Args[idx].(*Variable)
+	if !ok {
+		c.errorf("PLS-00363: argument %d of '%s' cannot be used as an assignment target", idx+1, fc.FunctionName)
+		return fc.Args[idx]
+	}
+	if sym := c.resolveVariable(variable); sym != nil && sym.readOnly {
+		c.errorf("PLS-00363: expression '%s' cannot be used as an assignment target", variable.Name)
+		return fc.Args[idx]
+	}
+	if !argType.Equal(t) {
+		return nil
+	}
+	return fc.Args[idx]
+}
+
+// checkRuntimeCall checks calls of functions that are provided by the runtime
+func (c *checker) checkRuntimeCall(fc *FunctionCall, argTypes []*Type, isStatement bool) *Type {
+	switch fc.FunctionName {
+	case "PRINT":
+		if !isStatement {
+			c.errorf("PLS-00222: no function with name '%s' exists in this scope", fc.FunctionName)
+			return nil
+		}
+		if len(fc.Args) != 1 {
+			c.errorf("PLS-00306: wrong number or types of arguments in call to '%s', expected 1 but got %d", fc.FunctionName, len(fc.Args))
+			return nil
+		}
+		if argTypes[0] != nil && (argTypes[0].isDatetime() || argTypes[0].isInterval()) {
+			// dates and intervals are printed in the default format
+			fc.Args[0] = c.convert(fc.Args[0], argTypes[0], VarcharType)
+		} else if argTypes[0] != nil && !argTypes[0].IsNumeric() && !argTypes[0].IsString() {
+			c.errorf("PLS-00306: wrong number or types of arguments in call to '%s', can't print '%s'", fc.FunctionName, argTypes[0].String())
+		}
+
+	default:
+		c.errorf("PLS-00302: component '%s' must be declared", fc.FunctionName)
+	}
+	return nil
+}
+
+// formats dates are stored in, they sort like the points in time they stand for
+const (
+	sqlDateFormat      = "YYYY-MM-DD HH24:MI:SS"
+	sqlTimestampFormat = "YYYY-MM-DD HH24:MI:SS.FF6"
+)
+
+// checkSqlStatement turns the tokens of an embedded SQL statement into the text that is sent to the database
+// the schema of the database might not be known, that's why PL/SQL variables take precedence over columns of the same name
+func (c *checker) checkSqlStatement(s *SqlStatement) {
+	if s.Kind == "SELECT" && len(s.Into) == 0 {
+		c.errorf("PLS-00428: an INTO clause is expected in this SELECT statement")
+		return
+	}
+	if !s.Bulk {
+		s.Into = c.expandRecordInto(s.Into)
+	}
+
+	statements := [][]SqlToken{s.Tokens}
+	if s.Kind == "MERGE" {
+		var err error
+		if statements, err = translateMerge(s.Tokens); err != nil {
+			c.errorf("%s", err.Error())
+			return
+		}
+	}
+
+	s.queries = make([]*sqlQuery, len(statements))
+	for idx := range statements {
+		s.queries[idx] = c.bindSqlVariables(removeDual(statements[idx]))
+	}
+
+	into := s.Into
+	if s.Bulk {
+		if s.bulk, into = c.checkBulkCollect(s.Into); s.bulk == nil {
+			return
+		}
+	}
+	s.columns, s.intoValues = c.checkSqlIntoList(into, s.Bulk)
+	if s.Kind == "SELECT" {
+		c.checkSelectList(s.queries[0], into)
+	}
+}
+
+// checkSqlIntoList checks the variables of an INTO clause
+// for BULK COLLECT they are the ones checkBulkCollect returned, their types are known already
+func (c *checker) checkSqlIntoList(into []*Variable, isBulk bool) ([]*sqlColumn, []Expression) {
+	columns := make([]*sqlColumn, len(into))
+	values := make([]Expression, len(into))
+	for idx := range into {
+		if isBulk {
+			columns[idx], values[idx] = c.sqlColumnValue(into[idx].Name, into[idx].Type(), idx)
+		} else {
+			columns[idx], values[idx] = c.checkSqlInto(into[idx], idx)
+		}
+	}
+	return columns, values
+}
+
+// checkBulkCollect checks the collections of 'BULK COLLECT INTO' and returns what the rows are read with
+// as well as a variable for every column that has the type of the element or field the column is read into
+// a single collection of records gets a column for every field
+func (c *checker) checkBulkCollect(into []*Variable) (*bulkCollect, []*Variable) {
+	targets := make([]*Variable, 0, len(into))
+	for _, v := range into {
+		sym := c.resolveVariable(v)
+		if sym == nil {
+			return nil, nil
+		} else if !sym.typ.IsCollection() {
+			c.errorf("PLS-00497: cannot mix between single row and multi-row (BULK) in INTO list, '%s' isn't a collection", v.Name)
+			return nil, nil
+		} else if sym.readOnly {
+			c.errorf("PLS-00363: expression '%s' cannot be used as an assignment target", v.Name)
+		}
+
+		v.setType(sym.typ)
+		target := NewVariable(v.Name)
+		target.setType(sym.typ.Collection.element)
+		targets = append(targets, target)
+	}
+
+	bc := &bulkCollect{collections: into}
+	if len(targets) != 1 || !targets[0].Type().IsRecord() {
+		return bc, targets
+	}
+
+	rec := targets[0].Type()
+	targets = make([]*Variable, len(rec.Record.Fields))
+	for idx, field := range rec.Record.Fields {
+		bc.fields = append(bc.fields, field.Name)
+		targets[idx] = NewQualifiedVariable(into[0].Name, field.Name)
+		targets[idx].setType(c.lookupType(rec.packageName(), field.Type))
+	}
+	return bc, targets
+}
+
+// expandRecordInto selects into all fields of a record if it is the only target ('SELECT * INTO emp_rec')
+func (c *checker) expandRecordInto(into []*Variable) []*Variable {
+	if len(into) != 1 || into[0].Qualifier != "" {
+		return into
+	}
+	sym, ok := c.findSymbol(into[0].Name)
+	if !ok || !sym.typ.IsRecord() {
+		return into
+	}
+
+	fields := make([]*Variable, len(sym.typ.Record.Fields))
+	for idx, field := range sym.typ.Record.Fields {
+		fields[idx] = NewQualifiedVariable(into[0].Name, field.Name)
+	}
+	return fields
+}
+
+// checkSelectList compares the columns a query selects with the variables it selects into
+// the types of columns are only known for the tables of a schema
+func (c *checker) checkSelectList(q *sqlQuery, into []*Variable) {
+	if c.schema == nil {
+		return
+	}
+	columns, err := resolveSqlNames(c.schema, q.tokens).selectColumns(q.tokens)
+	if err != nil {
+		// the columns of views and subqueries aren't known
+		return
+	}
+	c.checkIntoColumns(columns, into)
+}
+
+// recordColumns returns the fields of a record as the columns a cursor returns
+func (c *checker) recordColumns(t *Type) []*selectColumn {
+	columns := make([]*selectColumn, len(t.Record.Fields))
+	for idx, field := range t.Record.Fields {
+		columns[idx] = &selectColumn{name: field.Name, typ: c.lookupType(t.packageName(), field.Type)}
+	}
+	return columns
+}
+
+// checkCursorReturn makes sure that a query selects the fields of the record its cursor returns
+// the types of columns are only known with a schema, the columns of '*' too
+func (c *checker) checkCursorReturn(query *SqlStatement, row *Type) {
+	var columns []*selectColumn
+	if c.schema != nil {
+		q := query.queries[0]
+		var err error
+		if columns, err = resolveSqlNames(c.schema, q.tokens).selectColumns(q.tokens); err != nil {
+			return
+		}
+	} else {
+		names, err := selectListNames(query.Tokens)
+		if err != nil {
+			return
+		}
+		for _, name := range names {
+			columns = append(columns, &selectColumn{name: name})
+		}
+	}
+
+	fields := c.recordColumns(row)
+	if len(columns) != len(fields) {
+		c.errorf("PLS-00382: expression is of wrong type, the query selects %d column(s) but '%s' has %d field(s)", len(columns), row.String(), len(fields))
+		return
+	}
+	for idx, col := range columns {
+		if col.typ != nil && fields[idx].typ != nil && !sqlTypesMatch(col.typ, fields[idx].typ) {
+			c.errorf("PLS-00382: expression is of wrong type, column '%s' of type '%s' can't be fetched into field '%s' of type '%s'", col.name, col.typ.String(), fields[idx].name, fields[idx].typ.String())
+		}
+	}
+}
+
+// checkOpenCursorFor checks the query a cursor variable is opened for
+// strong ref cursors can only be opened for queries that select their record
+func (c *checker) checkOpenCursorFor(o *OpenCursorFor) {
+	if o.Query != nil {
+		o.Query.queries = []*sqlQuery{c.bindSqlVariables(removeDual(o.Query.Tokens))}
+	} else {
+		c.checkDynamicSql(o.Statement, o.Using)
+	}
+
+	t := c.resolveCursor(o.Cursor)
+	if t == nil {
+		return
+	} else if !t.isRefCursor() {
+		c.errorf("PLS-00382: expression is of wrong type, '%s' is '%s' but needs to be '%s'", o.Cursor, t.String(), RefCursorType.String())
+		return
+	}
+	if sym, _ := c.findSymbol(o.Cursor); sym.readOnly {
+		c.errorf("PLS-00363: expression '%s' cannot be used as an assignment target", o.Cursor)
+	}
+	if t.Returns != nil && o.Query != nil {
+		c.checkCursorReturn(o.Query, t.Returns)
+	}
+}
+
+// checkIntoColumns compares columns with the variables they are fetched into
+func (c *checker) checkIntoColumns(columns []*selectColumn, into []*Variable) {
+	if len(columns) < len(into) {
+		c.errorf("ORA-00947: not enough values, the query selects %d column(s) into %d variable(s)", len(columns), len(into))
+		return
+	} else if len(columns) > len(into) {
+		c.errorf("ORA-00913: too many values, the query selects %d column(s) into %d variable(s)", len(columns), len(into))
+		return
+	}
+
+	for idx, col := range columns {
+		target := into[idx].Type()
+		if col.typ != nil && target != nil && !sqlTypesMatch(col.typ, target) {
+			c.errorf("PLS-00386: type mismatch found at '%s' between column '%s' of type '%s' and INTO variable of type '%s'", into[idx].Name, col.name, col.typ.String(), target.String())
+		}
+	}
+}
+
+// checkSqlInto checks a variable a query selects into
+// and returns the column it is read from as well as the value of the column converted into the type of the variable
+func (c *checker) checkSqlInto(v *Variable, idx int) (*sqlColumn, Expression) {
+	sym := c.resolveVariable(v)
+	if sym == nil {
+		col := newSqlColumn(idx, VarcharType)
+		return col, col
+	}
+
+	v.setType(sym.typ)
+	if sym.readOnly {
+		c.errorf("PLS-00363: expression '%s' cannot be used as an assignment target", v.Name)
+	}
+	return c.sqlColumnValue(v.Name, sym.typ, idx)
+}
+
+// sqlColumnValue returns the column at idx as well as its value converted into type t of the variable called name
+func (c *checker) sqlColumnValue(name string, t *Type, idx int) (*sqlColumn, Expression) {
+	col := newSqlColumn(idx, VarcharType)
+	if t == nil {
+		return col, col
+	}
+
+	var value Expression
+	switch {
+	case t.Equal(IntType):
+		col.setType(IntType)
+		value = col
+	case t.isDouble():
+		col.setType(NumberType)
+		value = c.convert(col, NumberType, t)
+	case t.isDatetime():
+		// dates are stored as text
+		value = c.convert(c.callBuiltin("TO_TIMESTAMP", col, NewStringLiteral("'"+sqlTimestampFormat+"'")), TimestampType, t)
+	case t.IsString(), t.isInterval():
+		value = c.convert(col, VarcharType, t)
+	default:
+		c.errorf("PLS-00382: expression is of wrong type, can't select into '%s' of type '%s'", name, t.String())
+		value = col
+	}
+	return col, value
+}
+
+// bindSqlVariables replaces the PL/SQL variables in a statement with bind parameters ('?')
+// names of tables, names of functions, qualified columns and the columns that are SET aren't variables
+// like in Oracle names of columns win over names of variables ('SET salary = salary * 2' doubles the column)
+// columns are known from the schema, without one only the tables and aliases the statement declares are
+// that's why variables are reported without a schema if the statement uses their names as columns too
+func (c *checker) bindSqlVariables(tokens []SqlToken) *sqlQuery {
+	q := &sqlQuery{binds: make([]Expression, 0)}
+	names := resolveSqlNames(c.schema, tokens)
+	ambiguous := make(map[string]bool)
+	if c.schema == nil {
+		ambiguous = names.usedAsColumns(tokens)
+	}
+	result := make([]SqlToken, 0, len(tokens))
+	// whether the tokens name tables, one entry per level of parentheses
+	isTableClause := []bool{false}
+	setDepth := -1
+	bind := func(e Expression) {
+		result = append(result, SqlToken{Text: "?"})
+		q.binds = append(q.binds, c.sqlBindValue(e))
+	}
+
+	for idx := 0; idx < len(tokens); idx++ {
+		t := tokens[idx]
+		depth := len(isTableClause) - 1
+		switch t.Text {
+		case "(":
+			isTableClause = append(isTableClause, isTableClause[depth])
+		case ")":
+			if depth > 0 {
+				isTableClause = isTableClause[:depth]
+			}
+		case "FROM", "JOIN", "INTO", "UPDATE", "USING", "INSERT":
+			isTableClause[depth] = true
+		case "WHERE", "SET", "VALUES", "SELECT", "ON", "GROUP", "ORDER", "HAVING", "WHEN", "UNION", "INTERSECT", "EXCEPT", "MINUS":
+			isTableClause[depth] = false
+		}
+		if t.Text == "SET" {
+			setDepth = depth
+		} else if depth == setDepth && (t.Text == "WHERE" || t.Text == "FROM") {
+			setDepth = -1
+		}
+
+		if t.IsIdentifier && !isTableClause[depth] && !names.declared[idx] {
+			if ce, n := c.sqlCollectionElement(tokens[idx:]); ce != nil {
+				bind(ce)
+				idx += n - 1
+				continue
+			}
+		}
+
+		if !t.IsIdentifier || isTableClause[depth] || names.declared[idx] || (idx+1 < len(tokens) && tokens[idx+1].Text == "(") {
+			result = append(result, t)
+			continue
+		}
+
+		// columns that are SET come right after SET or a comma
+		if depth == setDepth && idx > 0 && (tokens[idx-1].Text == "SET" || tokens[idx-1].Text == ",") {
+			result = append(result, t)
+			continue
+		}
+
+		if idx+2 < len(tokens) && tokens[idx+1].Text == "." && tokens[idx+2].IsIdentifier {
+			v := NewQualifiedVariable(t.Text, tokens[idx+2].Text)
+			if _, isTable := names.tables[t.Text]; !isTable && c.isVariable(v) {
+				bind(v)
+			} else {
+				// a column of a table ('e.salary')
+				result = append(result, tokens[idx:idx+3]...)
+			}
+			idx += 2
+			continue
+		}
+
+		v := NewVariable(t.Text)
+		switch {
+		case names.isColumn(t.Text):
+			// a column of a table ('salary')
+			result = append(result, t)
+		case c.isVariable(v):
+			if ambiguous[t.Text] {
+				c.errorf("ORA-00918: column ambiguously defined, '%s' is a PL/SQL variable and might be a column too, rename the variable or check the statement against a schema", t.Text)
+				delete(ambiguous, t.Text)
+			}
+			bind(v)
+		case t.Text == "SYSDATE" || t.Text == "SYSTIMESTAMP":
+			// the clock of the program is the clock of the database
+			bind(NewFunctionCall("", t.Text))
+		default:
+			result = append(result, t)
+		}
+	}
+
+	q.tokens = result
+	q.text = sqlText(result)
+	if c.schema != nil {
+		for _, err := range resolveSqlNames(c.schema, result).check(result) {
+			c.errorf("%s", err.Error())
+		}
+	}
+	return q
+}
+
+// sqlCollectionElement returns the element of a collection the tokens start with ('list(i)', 'list(1)' or 'list(i).field')
+// together with the number of tokens it takes up, it returns nil if they don't start with one
+func (c *checker) sqlCollectionElement(tokens []SqlToken) (*CollectionElement, int) {
+	if len(tokens) < 4 || tokens[1].Text != "(" || tokens[3].Text != ")" || tokens[2].IsString {
+		return nil, 0
+	}
+	sym, ok := c.findSymbol(tokens[0].Text)
+	if !ok || !sym.typ.IsCollection() {
+		return nil, 0
+	}
+
+	var index Expression
+	if tokens[2].IsIdentifier {
+		index = NewVariable(tokens[2].Text)
+	} else {
+		index = NewNumericLiteral(tokens[2].Text)
+	}
+	if len(tokens) >= 6 && tokens[4].Text == "." && tokens[5].IsIdentifier {
+		return NewCollectionElement(NewVariable(tokens[0].Text), index, tokens[5].Text), 6
+	}
+	return NewCollectionElement(NewVariable(tokens[0].Text), index, ""), 4
+}
+
+// isVariable returns true if v refers to a PL/SQL variable, a field of a record or a variable of a package
+func (c *checker) isVariable(v *Variable) bool {
+	if v.Qualifier == "" {
+		_, ok := c.findSymbol(v.Name)
+		return ok
+	}
+
+	if rec, ok := c.findSymbol(v.Qualifier); ok {
+		if rec.typ.IsCollection() {
+			// methods of collections that don't take arguments ('list.COUNT')
+			return v.Name == "COUNT" || v.Name == "FIRST" || v.Name == "LAST" || v.Name == "LIMIT"
+		}
+		return rec.typ.IsRecord() && rec.typ.Record.fieldIndex(v.Name) >= 0
+	}
+	_, ok := c.globals[v.Qualifier+"."+v.Name]
+	return ok
+}
+
+// sqlBindValue converts the value of a bind parameter into one of the types SQLite stores
+// numbers are bound as they are, dates and intervals as text
+func (c *checker) sqlBindValue(e Expression) Expression {
+	t := c.checkExpression(e)
+	switch {
+	case t == nil, t.IsNumeric(), t.IsString():
+		return e
+	case t.Equal(DateType):
+		return c.callBuiltin("TO_CHAR", e, NewStringLiteral("'"+sqlDateFormat+"'"))
+	case t.Equal(TimestampType):
+		return c.callBuiltin("TO_CHAR", e, NewStringLiteral("'"+sqlTimestampFormat+"'"))
+	case t.isInterval():
+		return c.convert(e, t, VarcharType)
+	}
+	c.errorf("PLS-00457: expressions have to be of SQL types, '%s' is '%s'", e.String(), t.String())
+	return e
+}
+
+// checkDynamicSql checks the text of a statement that is built at runtime and its bind arguments
+func (c *checker) checkDynamicSql(statement Expression, using []Expression) {
+	if t := c.checkExpression(statement); t != nil && !t.IsString() {
+		c.errorf("PLS-00382: expression is of wrong type, the statement is '%s' but needs to be '%s'", t.String(), VarcharType.String())
+	}
+	for idx := range using {
+		using[idx] = c.sqlBindValue(using[idx])
+	}
+}
+
+// callBuiltin creates a checked call of a built-in function
+// it is used for conversions that have no implicit counterpart
+func (c *checker) callBuiltin(name string, args ...Expression) Expression {
+	fc := NewFunctionCall("", name)
+	argTypes := make([]*Type, len(args))
+	for idx := range args {
+		fc.AddArg(args[idx])
+		argTypes[idx] = c.checkExpression(args[idx])
+	}
+	if t := c.checkBuiltinCall(fc, resolveBuiltin(builtinFunctions[name], argTypes), argTypes, false); t != nil {
+		fc.setType(t)
+	}
+	return fc
+}
+
+func (c *checker) checkCursorAttribute(ca *CursorAttribute) *Type {
+	if ca.Cursor != implicitCursorName && c.resolveCursor(ca.Cursor) == nil {
+		return nil
+	}
+
+	if ca.Attribute == "BULK_ROWCOUNT" && ca.Cursor == implicitCursorName {
+		if ca.Index == nil {
+			c.errorf("PLS-00306: wrong number or types of arguments in call to 'BULK_ROWCOUNT'")
+			return IntType
+		}
+		ca.Index = c.convertToInt(ca.Index, "the index of BULK_ROWCOUNT")
+		return IntType
+	} else if ca.Index != nil {
+		c.errorf("PLS-00208: identifier '%s' is not a legal cursor attribute with an index", ca.Attribute)
+		return nil
+	}
+
+	switch ca.Attribute {
+	case "ROWCOUNT":
+		return IntType
+	case "FOUND", "NOTFOUND", "ISOPEN":
+		return BooleanType
+	}
+	c.errorf("PLS-00208: identifier '%s' is not a legal cursor attribute", ca.Attribute)
+	return nil
+}
+
+// checkForAll checks the statement of a FORALL, the index is only visible inside of it
+func (c *checker) checkForAll(fa *ForAll) {
+	fa.Lower = c.convertToInt(fa.Lower, "the lower bound")
+	fa.Upper = c.convertToInt(fa.Upper, "the upper bound")
+
+	c.scopes = append(c.scopes, map[string]*symbol{fa.Index: {typ: IntType, readOnly: true}})
+	c.checkSqlStatement(fa.Statement)
+	c.scopes = c.scopes[:len(c.scopes)-1]
+}
+
+// convertToInt converts an expression that is used as an index into an INT
+func (c *checker) convertToInt(e Expression, what string) Expression {
+	t := c.checkExpression(e)
+	if t == nil {
+		return e
+	}
+	if converted := c.convert(e, t, IntType); converted != nil {
+		return converted
+	}
+	c.errorf("PLS-00382: expression is of wrong type, %s is '%s' but needs to be '%s'", what, t.String(), IntType.String())
+	return e
+}
+
+// checkCursor checks the query of an explicit cursor and declares the cursor
+// the parameters of the cursor are only visible inside its query
+func (c *checker) checkCursor(cursor *Cursor) {
+	params := make(map[string]*symbol)
+	for _, param := range cursor.Proto.Params {
+		if t := c.resolveType(param.Type); t != nil {
+			params[param.Name] = &symbol{typ: t, readOnly: true}
+		}
+	}
+
+	c.scopes = append(c.scopes, params)
+	cursor.Query.queries = []*sqlQuery{c.bindSqlVariables(removeDual(cursor.Query.Tokens))}
+	c.scopes = c.scopes[:len(c.scopes)-1]
+
+	if cursor.Proto.ReturnType != "" {
+		if t := c.resolveType(cursor.Proto.ReturnType); t != nil && !t.IsRecord() {
+			c.errorf("PLS-00362: invalid cursor return type, '%s' must be a record type", t.String())
+		} else if t != nil {
+			c.checkCursorReturn(cursor.Query, t)
+		}
+	}
+
+	c.declareLocal(cursor.Name(), &symbol{typ: &Type{Name: "CURSOR", Cursor: cursor}, readOnly: true})
+}
+
+// resolveCursor finds the type of an explicit cursor or a cursor variable
+// the type of an explicit cursor holds its declaration
+func (c *checker) resolveCursor(name string) *Type {
+	sym, ok := c.findSymbol(name)
+	if !ok {
+		c.errorf("PLS-00201: identifier '%s' must be declared", name)
+		return nil
+	} else if !sym.typ.isCursor() {
+		c.errorf("PLS-00456: item '%s' is not a cursor", name)
+		return nil
+	}
+	return sym.typ
+}
+
+func (c *checker) checkOpenCursor(o *OpenCursor) {
+	argTypes := make([]*Type, len(o.Args))
+	for idx := range o.Args {
+		argTypes[idx] = c.checkExpression(o.Args[idx])
+	}
+
+	t := c.resolveCursor(o.Cursor)
+	if t == nil {
+		return
+	} else if t.Cursor == nil {
+		c.errorf("PLS-00382: expression is of wrong type, cursor variable '%s' needs to be opened FOR a query", o.Cursor)
+		return
+	}
+	o.decl = t.Cursor
+
+	params := o.decl.Proto.Params
+	if len(o.Args) != len(params) {
+		c.errorf("PLS-00306: wrong number or types of arguments in call to '%s', expected %d but got %d", o.Cursor, len(params), len(o.Args))
+		return
+	}
+
+	for idx := range o.Args {
+		paramType := c.resolveType(params[idx].Type)
+		if argTypes[idx] == nil || paramType == nil {
+			continue
+		}
+		if converted := c.convert(o.Args[idx], argTypes[idx], paramType); converted != nil {
+			o.Args[idx] = converted
+		} else {
+			c.errorf("PLS-00306: wrong number or types of arguments in call to '%s', argument %d is '%s' but needs to be '%s'", o.Cursor, idx+1, argTypes[idx].String(), paramType.String())
+		}
+	}
+}
+
+// checkCursorForLoop opens the scope of a cursor FOR loop that ends with its EndCursorForLoop
+// the record of the loop has the fields of the return type of its cursor, or the columns of its query
+func (c *checker) checkCursorForLoop(l *CursorForLoop) {
+	c.scopes = append(c.scopes, make(map[string]*symbol))
+	if l.cursor != nil {
+		c.checkCursor(l.cursor)
+	}
+	c.checkOpenCursor(l.Open)
+	if l.Open.decl == nil {
+		return
+	}
+
+	l.recordType = c.cursorRecordType(l)
+	if l.recordType == nil {
+		return
+	}
+	c.declareLocal(l.Record, &symbol{typ: l.recordType})
+
+	l.Fetch.Into = make([]*Variable, 0, len(l.recordType.Record.Fields))
+	for _, field := range l.recordType.Record.Fields {
+		l.Fetch.AddInto(NewQualifiedVariable(l.Record, field.Name))
+	}
+}
+
+// cursorRecordType returns the type of the record of a cursor FOR loop
+// the types of columns aren't known without a schema, they are fetched as strings
+// so are expressions, only columns of tables have the types of the schema
+func (c *checker) cursorRecordType(l *CursorForLoop) *Type {
+	cursor := l.Open.decl
+	if cursor.Proto.ReturnType != "" {
+		if t := c.resolveType(cursor.Proto.ReturnType); t != nil && t.IsRecord() {
+			return t
+		}
+		return nil
+	}
+
+	names, err := selectListNames(cursor.Query.Tokens)
+	var columnTypes []*Type
+	if c.schema != nil {
+		q := cursor.Query.queries[0]
+		columns, schemaErr := resolveSqlNames(c.schema, q.tokens).selectColumns(q.tokens)
+		if schemaErr == nil && err != nil {
+			// the columns of '*' are known with a schema
+			names = make([]string, len(columns))
+			for idx := range columns {
+				names[idx] = columns[idx].name
+			}
+		}
+		err = schemaErr
+		for idx := range columns {
+			columnTypes = append(columnTypes, columns[idx].typ)
+		}
+	}
+	if err != nil {
+		c.errorf("%s", err.Error())
+		return nil
+	}
+
+	rt := NewRecordType("FOR-RECORD-" + l.id)
+	for idx, name := range names {
+		if rt.fieldIndex(name) >= 0 {
+			c.errorf("PLS-00402: alias required in SELECT list of cursor to avoid duplicate column names, '%s' is selected twice", name)
+			return nil
+		}
+		typ := VarcharType
+		if idx < len(columnTypes) && columnTypes[idx] != nil {
+			typ = columnTypes[idx]
+		}
+		rt.AddField(name, typ.String())
+	}
+	return &Type{Name: c.currentPackage.Name + "." + rt.Name, Record: rt}
+}
+
+// resolveVariable finds the symbol a variable refers to
+// unqualified names are looked up in the local scopes first and the current package second
+// qualified names are either fields of records ('rec.field') or variables of packages ('pkg.var')
+func (c *checker) resolveVariable(v *Variable) *symbol {
+	if v.Qualifier == "" {
+		sym, ok := c.findSymbol(v.Name)
+		if !ok {
+			c.errorf("PLS-00201: identifier '%s' must be declared", v.Name)
+			return nil
+		}
+		return sym
+	}
+
+	// names of variables shadow names of packages
+	if rec, ok := c.findSymbol(v.Qualifier); ok {
+		return c.resolveField(v, rec)
+	}
+
+	pkg, ok := c.pkgs[v.Qualifier]
+	if !ok {
+		c.errorf("PLS-00201: identifier '%s.%s' must be declared", v.Qualifier, v.Name)
+		return nil
+	}
+
+	sym, ok := c.globals[v.Qualifier+"."+v.Name]
+	if !ok {
+		c.errorf("PLS-00302: component '%s' must be declared", v.Name)
+		return nil
+	}
+
+	if v.Qualifier != c.currentPackage.Name && !pkg.isPublicVariable(v.Name) {
+		c.errorf("PLS-00302: component '%s' must be declared, it is private to package '%s'", v.Name, v.Qualifier)
+		return nil
+	}
+	return sym
+}
+
+// lookupVariable finds the symbol a variable refers to like resolveVariable does but doesn't report errors
+// it returns nil if there is no such variable
+func (c *checker) lookupVariable(v *Variable) *symbol {
+	if v.Qualifier == "" {
+		sym, _ := c.findSymbol(v.Name)
+		return sym
+	}
+	if rec, ok := c.findSymbol(v.Qualifier); ok {
+		if rec.typ.IsRecord() && rec.typ.Record.fieldIndex(v.Name) >= 0 {
+			return c.resolveField(v, rec)
+		}
+		return nil
+	}
+	return c.globals[v.Qualifier+"."+v.Name]
+}
+
+func (c *checker) resolveField(v *Variable, rec *symbol) *symbol {
+	if !rec.typ.IsRecord() {
+		c.errorf("PLS-00487: Invalid reference to variable '%s'", v.Qualifier)
+		return nil
+	}
+
+	idx := rec.typ.Record.fieldIndex(v.Name)
+	if idx < 0 {
+		c.errorf("PLS-00302: component '%s' must be declared in record '%s'", v.Name, rec.typ.Record.Name)
+		return nil
+	}
+
+	// field types are declared in the package of the record
+	t := c.lookupType(rec.typ.packageName(), rec.typ.Record.Fields[idx].Type)
+	if t == nil {
+		return nil
+	}
+	return &symbol{typ: t, readOnly: rec.readOnly}
+}
+
+func (c *checker) findSymbol(name string) (*symbol, bool) {
+	for idx := len(c.scopes) - 1; idx >= 0; idx-- {
+		if sym, ok := c.scopes[idx][name]; ok {
+			return sym, true
+		}
+	}
+
+	sym, ok := c.globals[c.currentPackage.Name+"."+name]
+	return sym, ok
+}
+
+// resolveType resolves a type name as it is used in the current package
+// names of built-in types aren't qualified, types of other packages are ('pkg.type')
+func (c *checker) resolveType(name string) *Type {
+	if strings.HasSuffix(name, rowTypeSuffix) {
+		return c.resolveRowType(name)
+	}
+	if idx := strings.Index(name, "."); idx >= 0 {
+		t, ok := c.types[name]
+		if !ok {
+			// the types of packages the runtime implements ('DBMS_OUTPUT.CHARARR')
+			if t, ok := builtinType(name); ok {
+				return t
+			}
+			c.errorf("PLS-00201: identifier '%s' must be declared", name)
+			return nil
+		}
+		if name[:idx] != c.currentPackage.Name && c.privateTypes[name] {
+			c.errorf("PLS-00302: component '%s' must be declared, it is private to package '%s'", name[idx+1:], name[:idx])
+			return nil
+		}
+		return t
+	}
+
+	if t := c.lookupType(c.currentPackage.Name, name); t != nil {
+		return t
+	}
+
+	c.errorf("PLS-00201: identifier '%s' must be declared", name)
+	return nil
+}
+
+// resolveRowType resolves the record of a row of a table ('emp%ROWTYPE')
+func (c *checker) resolveRowType(name string) *Type {
+	if t := c.rowType(c.currentPackage.Name, name); t != nil {
+		return t
+	}
+
+	tableName := strings.TrimSuffix(name, rowTypeSuffix)
+	if c.schema == nil {
+		c.errorf("PLS-00201: identifier '%s' must be declared, %%ROWTYPE needs the schema of the database", tableName)
+	} else if _, ok := c.schema.Table(tableName); !ok {
+		c.errorf("ORA-00942: table or view '%s' does not exist", tableName)
+	} else {
+		c.errorf("PLS-00310: with %%ROWTYPE attribute, '%s' must name a table whose columns are known", tableName)
+	}
+	return nil
+}
+
+// rowType returns the record of a row of a table without reporting errors
+// there is one record per table, every package that uses it declares it for itself
+func (c *checker) rowType(pkgName string, name string) *Type {
+	if c.schema == nil {
+		return nil
+	}
+	table, ok := c.schema.Table(strings.TrimSuffix(name, rowTypeSuffix))
+	if !ok || table.Columns == nil {
+		return nil
+	}
+
+	t, ok := c.types[name]
+	if !ok {
+		t = table.rowType()
+		c.types[name] = t
+	}
+	c.pkgs[pkgName].addRowType(t.Record)
+	return t
+}
+
+// lookupType resolves a type name as it is used in package pkgName without reporting errors
+// it is used for declarations of other packages that are checked on their own
+func (c *checker) lookupType(pkgName string, name string) *Type {
+	if strings.HasSuffix(name, rowTypeSuffix) {
+		return c.rowType(pkgName, name)
+	} else if t, ok := c.types[name]; ok && strings.Contains(name, ".") {
+		return t
+	} else if t, ok := c.types[pkgName+"."+name]; ok && !strings.Contains(name, ".") {
+		return t
+	}
+	// built-in types include those of packages the runtime implements ('DBMS_OUTPUT.CHARARR')
+	t, _ := builtinType(name)
+	return t
+}
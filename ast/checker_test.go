@@ -0,0 +1,873 @@
+/*
+ * Copyright 2019 Marco Helmich
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ast
+
+import (
+	"strings"
+	"testing"
+	"unicode"
+
+	"github.com/mhelmich/plsqlc/runtime"
+	"github.com/stretchr/testify/assert"
+)
+
+// newCheckerTestPackages builds
+//
+//	PACKAGE lib: TYPE point IS RECORD (x INT); limit CONSTANT INT := 10; FUNCTION twice(i IN INT) RETURN INT; PROCEDURE hidden;
+//	PACKAGE BODY main: PROCEDURE main IS v INT; p lib.point; BEGIN <main body> END;
+func newCheckerTestPackages(mainBody ...Instruction) map[string]*Package {
+	spec := NewPackageSpec("LIB")
+	point := NewRecordType("POINT")
+	point.AddField("X", "INT")
+	spec.AddType(point)
+	spec.AddVariable(NewPackageVariable("LIMIT", "INT", "10", true))
+	twiceProto := NewFunctionProto("TWICE")
+	twiceProto.AddParam("I", "IN", "INT")
+	twiceProto.ReturnType = "INT"
+	spec.AddProto(twiceProto)
+
+	lib := NewPackageFromSpec(spec)
+	lib.hasBody = true
+	twice := NewFunction("TWICE", false)
+	twice.AddParam("I", "IN", "INT")
+	twice.Proto.ReturnType = "INT"
+	twiceBlock := NewBlock("TWICE-entry")
+	twiceBlock.AddInstruction(NewRetrn(NewBinOp(NewVariable("I"), "+", NewVariable("I"))))
+	twice.AddBlock(twiceBlock)
+	lib.AddFunction(twice)
+	lib.AddFunction(NewFunction("HIDDEN", true))
+
+	main := NewPackage("MAIN")
+	mainFunc := NewFunction("MAIN", true)
+	mainFunc.AddLocal("V", "INT", "")
+	mainFunc.AddLocal("P", "LIB.POINT", "")
+	mainBlock := NewBlock("MAIN-entry")
+	for _, i := range mainBody {
+		mainBlock.AddInstruction(i)
+	}
+	mainFunc.AddBlock(mainBlock)
+	main.AddFunction(mainFunc)
+
+	return map[string]*Package{
+		"LIB":  lib,
+		"MAIN": main,
+	}
+}
+
+func newCall(moduleName string, functionName string, args ...Expression) *FunctionCall {
+	fc := NewFunctionCall(moduleName, functionName)
+	for _, arg := range args {
+		fc.AddArg(arg)
+	}
+	return fc
+}
+
+func TestCheckerAnnotatesTypes(t *testing.T) {
+	twice := newCall("LIB", "TWICE", NewVariable("V"))
+	field := NewQualifiedVariable("P", "X")
+	sum := NewBinOp(twice, "+", field)
+	cond := NewBinOp(sum, ">", NewQualifiedVariable("LIB", "LIMIT"))
+	branch := NewConditionalBranch(cond, NewBlock("t"), NewBlock("f"))
+	pkgs := newCheckerTestPackages(
+		NewAssignment(NewVariable("V"), NewNumericLiteral("1")),
+		newCall("DBMS", "PRINT", sum),
+		newCall("DBMS", "PRINT", NewStringLiteral("'narf'")),
+		branch,
+	)
+
+	diagnostics := Check(pkgs)
+	assert.Equal(t, 0, len(diagnostics), "%v", diagnostics)
+	assert.Equal(t, IntType, twice.Type())
+	assert.Equal(t, IntType, field.Type())
+	assert.Equal(t, IntType, sum.Type())
+	assert.Equal(t, BooleanType, cond.Type())
+}
+
+func TestCheckerDiagnostics(t *testing.T) {
+	pkgs := newCheckerTestPackages(
+		// undeclared name
+		NewAssignment(NewVariable("NARF"), NewNumericLiteral("1")),
+		// type mismatch, booleans are never converted
+		NewAssignment(NewVariable("V"), NewBinOp(NewNumericLiteral("1"), "=", NewNumericLiteral("1"))),
+		// wrong arity
+		NewAssignment(NewVariable("V"), newCall("LIB", "TWICE")),
+		// private function
+		newCall("LIB", "HIDDEN"),
+		// constant as assignment target
+		NewAssignment(NewQualifiedVariable("LIB", "LIMIT"), NewNumericLiteral("1")),
+		// unknown field
+		newCall("DBMS", "PRINT", NewQualifiedVariable("P", "Y")),
+		// function called as statement
+		newCall("LIB", "TWICE", NewNumericLiteral("1")),
+		// operands of different types
+		newCall("DBMS", "PRINT", NewBinOp(NewVariable("V"), "+", NewBinOp(NewVariable("V"), ">", NewNumericLiteral("1")))),
+		// literal out of range
+		NewAssignment(NewVariable("V"), NewNumericLiteral("99999999999999999999")),
+	)
+
+	diagnostics := Check(pkgs)
+	messages := make([]string, len(diagnostics))
+	for idx := range diagnostics {
+		assert.Equal(t, "MAIN", diagnostics[idx].Package)
+		assert.Equal(t, "MAIN", diagnostics[idx].Function)
+		messages[idx] = diagnostics[idx].Message
+	}
+
+	assert.Equal(t, []string{
+		"PLS-00201: identifier 'NARF' must be declared",
+		"PLS-00382: expression is of wrong type, can't assign 'BOOLEAN' to 'V' of type 'INT'",
+		"PLS-00306: wrong number or types of arguments in call to 'TWICE', expected 1 but got 0",
+		"PLS-00302: component 'HIDDEN' must be declared, it is private to package 'LIB'",
+		"PLS-00363: expression 'LIMIT' cannot be used as an assignment target",
+		"PLS-00302: component 'Y' must be declared in record 'POINT'",
+		"PLS-00221: 'TWICE' is not a procedure or is undefined",
+		"PLS-00306: wrong number or types of arguments in call to '+', got 'INT' and 'BOOLEAN'",
+		"ORA-01426: numeric overflow, literal '99999999999999999999' doesn't fit into INT",
+	}, messages)
+}
+
+func TestCheckerReturns(t *testing.T) {
+	pkgs := newCheckerTestPackages(NewRetrn(NewNumericLiteral("1")))
+	twice := pkgs["LIB"].findFunction("TWICE")
+	twice.Blocks[0].Instructions = []Instruction{NewRetrn(NewBinOp(NewVariable("I"), "=", NewNumericLiteral("1")))}
+	twice.AddLocal("W", "NARF_T", "")
+
+	diagnostics := Check(pkgs)
+	assert.Equal(t, 3, len(diagnostics))
+	assert.Equal(t, "LIB.TWICE: PLS-00201: identifier 'NARF_T' must be declared", diagnostics[0].String())
+	assert.Equal(t, "LIB.TWICE: PLS-00382: expression is of wrong type, 'TWICE' returns 'INT' but got 'BOOLEAN'", diagnostics[1].String())
+	assert.Equal(t, "MAIN.MAIN: PLS-00372: In a procedure, RETURN statement cannot contain an expression", diagnostics[2].String())
+}
+
+func TestCheckerSpecConformance(t *testing.T) {
+	pkgs := newCheckerTestPackages()
+	lib := pkgs["LIB"]
+	for _, bodyType := range []string{"LIB.POINT", "INT"} {
+		specProto := NewFunctionProto("MOVE_" + strings.Split(bodyType, ".")[0])
+		specProto.AddParam("P", "IN", "POINT")
+		lib.Spec.AddProto(specProto)
+		move := NewFunction(specProto.Name, true)
+		move.AddParam("P", "IN", bodyType)
+		move.AddBlock(NewBlock("MOVE-entry"))
+		lib.AddFunction(move)
+	}
+
+	// 'point' and 'lib.point' are the same type
+	diagnostics := Check(pkgs)
+	assert.Equal(t, 1, len(diagnostics))
+	assert.Equal(t, "PLS-00323: subprogram 'MOVE_INT' of package 'LIB' doesn't match its declaration: parameter 1 of 'MOVE_INT' is 'P IN POINT' in the specification but 'P IN INT' in the body", diagnostics[0].Message)
+}
+
+func TestCheckerConversions(t *testing.T) {
+	// v := '12' * 2.5;
+	product := NewBinOp(NewStringLiteral("'12'"), "*", NewNumericLiteral("2.5"))
+	assignment := NewAssignment(NewVariable("V"), product)
+	// dbms.print('v is ' || v);
+	concat := NewBinOp(NewStringLiteral("'v is '"), "||", NewVariable("V"))
+	// v := lib.twice('21');
+	twice := newCall("LIB", "TWICE", NewStringLiteral("'21'"))
+	pkgs := newCheckerTestPackages(
+		assignment,
+		newCall("DBMS", "PRINT", concat),
+		NewAssignment(NewVariable("V"), twice),
+	)
+
+	diagnostics := Check(pkgs)
+	assert.Equal(t, 0, len(diagnostics), "%v", diagnostics)
+
+	assert.Equal(t, NumberType, product.Type())
+	assert.Equal(t, NumberType, product.Left.(*Conversion).Type())
+	assert.Equal(t, VarcharType, product.Left.(*Conversion).Expr.Type())
+	assert.Equal(t, IntType, assignment.Expr.(*Conversion).Type())
+
+	assert.Equal(t, VarcharType, concat.Type())
+	assert.Equal(t, VarcharType, concat.Right.(*Conversion).Type())
+
+	assert.Equal(t, IntType, twice.Args[0].(*Conversion).Type())
+}
+
+func TestConvertLiteral(t *testing.T) {
+	text, err := convertLiteral(IntType, "2.5")
+	assert.Nil(t, err)
+	assert.Equal(t, "3", text)
+	text, err = convertLiteral(IntType, "-2.5")
+	assert.Nil(t, err)
+	assert.Equal(t, "-3", text)
+	text, err = convertLiteral(NumberType, "' 12.5 '")
+	assert.Nil(t, err)
+	assert.Equal(t, "12.5", text)
+	text, err = convertLiteral(VarcharType, "0.5")
+	assert.Nil(t, err)
+	assert.Equal(t, ".5", text)
+	text, err = convertLiteral(VarcharType, "'it''s'")
+	assert.Nil(t, err)
+	assert.Equal(t, "it's", text)
+	assert.Equal(t, "it's", NewStringLiteral("'it''s'").Value)
+	_, err = convertLiteral(IntType, "'narf'")
+	assert.NotNil(t, err)
+	assert.False(t, canConvert(BooleanType, VarcharType))
+}
+
+func TestBuiltinTypes(t *testing.T) {
+	typ, ok := builtinType("VARCHAR2(10 CHAR)")
+	assert.True(t, ok)
+	assert.Equal(t, "VARCHAR(10)", typ.String())
+	typ, ok = builtinType("NCHAR")
+	assert.True(t, ok)
+	assert.Equal(t, "CHAR(1)", typ.String())
+	typ, ok = builtinType("NUMBER(10,2)")
+	assert.True(t, ok)
+	assert.Equal(t, NumberType, typ)
+	_, ok = builtinType("VARCHAR2(0)")
+	assert.False(t, ok)
+
+	text, err := convertLiteral(&Type{Name: "CHAR", Length: 4}, "'ab'")
+	assert.Nil(t, err)
+	assert.Equal(t, "ab  ", text)
+	_, err = convertLiteral(&Type{Name: "VARCHAR", Length: 2}, "'abc'")
+	assert.NotNil(t, err)
+}
+
+func TestCheckerConstrainedStrings(t *testing.T) {
+	// c := 'ab'; IF c = 'ab' ...; IF c = v ...
+	assignment := NewAssignment(NewVariable("C"), NewStringLiteral("'ab'"))
+	padded := NewBinOp(NewVariable("C"), "=", NewStringLiteral("'ab'"))
+	notPadded := NewBinOp(NewVariable("C"), "=", NewVariable("S"))
+	pkgs := newCheckerTestPackages(
+		assignment,
+		NewConditionalBranch(padded, NewBlock("t"), NewBlock("f")),
+		NewConditionalBranch(notPadded, NewBlock("t"), NewBlock("f")),
+	)
+	mainFunc := pkgs["MAIN"].findFunction("MAIN")
+	mainFunc.AddLocal("C", "CHAR(4)", "")
+	mainFunc.AddLocal("S", "VARCHAR2(2)", "'abc'")
+
+	diagnostics := Check(pkgs)
+	assert.Equal(t, 1, len(diagnostics))
+	assert.Equal(t, "MAIN.MAIN: ORA-06502: PL/SQL: numeric or value error: character string buffer too small, 'S' can't be initialized with 'abc'", diagnostics[0].String())
+
+	// the value is padded even though it already is a string
+	assert.Equal(t, "CHAR(4)", assignment.Expr.(*Conversion).Type().String())
+	assert.True(t, padded.blankPadded)
+	assert.False(t, notPadded.blankPadded)
+}
+
+func TestCheckerBuiltinFunctions(t *testing.T) {
+	// v := length(substr('narf', 2));
+	substr := newCall("", "SUBSTR", NewStringLiteral("'narf'"), NewNumericLiteral("2"))
+	length := newCall("", "LENGTH", substr)
+	// dbms.print(lpad(v, 5));
+	lpad := newCall("", "LPAD", NewVariable("V"), NewNumericLiteral("5"))
+	pkgs := newCheckerTestPackages(
+		NewAssignment(NewVariable("V"), length),
+		newCall("DBMS", "PRINT", lpad),
+		// wrong arity
+		NewAssignment(NewVariable("V"), newCall("", "INSTR", NewStringLiteral("'narf'"))),
+		// built-in functions aren't procedures
+		newCall("", "UPPER", NewStringLiteral("'narf'")),
+		// built-in functions can't be qualified with another package
+		NewAssignment(NewVariable("V"), newCall("LIB", "LENGTH", NewStringLiteral("'narf'"))),
+	)
+
+	diagnostics := Check(pkgs)
+	messages := make([]string, len(diagnostics))
+	for idx := range diagnostics {
+		messages[idx] = diagnostics[idx].Message
+	}
+	assert.Equal(t, []string{
+		"PLS-00306: wrong number or types of arguments in call to 'INSTR'",
+		"PLS-00221: 'UPPER' is not a procedure or is undefined",
+		"PLS-00302: component 'LENGTH' must be declared",
+	}, messages)
+
+	assert.Equal(t, IntType, length.Type())
+	assert.Equal(t, VarcharType, substr.Type())
+	// the length of the substring was filled in
+	assert.Equal(t, 3, len(substr.Args))
+	assert.Equal(t, IntType, substr.Args[2].Type())
+
+	assert.Equal(t, VarcharType, lpad.Type())
+	assert.Equal(t, VarcharType, lpad.Args[0].(*Conversion).Type())
+	assert.Equal(t, " ", lpad.Args[2].(*StringLiteral).Value)
+}
+
+func TestCheckerNumericOverloads(t *testing.T) {
+	absInt := newCall("", "ABS", NewVariable("V"))
+	absNumber := newCall("", "ABS", NewNumericLiteral("2.5"))
+	// strings are passed as numbers
+	absString := newCall("", "ABS", NewStringLiteral("'-2'"))
+	modMixed := newCall("", "MOD", NewVariable("V"), NewNumericLiteral("1.5"))
+	// integers are widened, there is no integer square root
+	sqrt := newCall("", "SQRT", NewVariable("V"))
+	sign := newCall("", "SIGN", NewNumericLiteral("2.5"))
+	greatest := newCall("", "GREATEST", NewVariable("V"), NewNumericLiteral("1"), NewNumericLiteral("2.5"))
+	round := newCall("", "ROUND", NewVariable("V"))
+	pkgs := newCheckerTestPackages(
+		newCall("DBMS", "PRINT", absInt),
+		newCall("DBMS", "PRINT", absNumber),
+		newCall("DBMS", "PRINT", absString),
+		newCall("DBMS", "PRINT", modMixed),
+		newCall("DBMS", "PRINT", sqrt),
+		newCall("DBMS", "PRINT", sign),
+		newCall("DBMS", "PRINT", greatest),
+		newCall("DBMS", "PRINT", round),
+		// booleans aren't numbers
+		newCall("DBMS", "PRINT", newCall("", "ABS", NewBinOp(NewVariable("V"), "=", NewNumericLiteral("1")))),
+	)
+
+	diagnostics := Check(pkgs)
+	assert.Equal(t, 1, len(diagnostics), "%v", diagnostics)
+	assert.Equal(t, "PLS-00306: wrong number or types of arguments in call to 'ABS', argument 1 is 'BOOLEAN' but needs to be 'BINARY_DOUBLE'", diagnostics[0].Message)
+
+	assert.Equal(t, IntType, absInt.Type())
+	assert.Equal(t, NumberType, absNumber.Type())
+	assert.Equal(t, NumberType, absString.Type())
+	assert.Equal(t, NumberType, modMixed.Type())
+	assert.Equal(t, NumberType, modMixed.Args[0].(*Conversion).Type())
+	assert.Equal(t, NumberType, sqrt.Type())
+	assert.Equal(t, IntType, sign.Type())
+	assert.Equal(t, NumberType, greatest.Type())
+	assert.Equal(t, 3, len(greatest.Args))
+	assert.Equal(t, IntType, round.Type())
+	assert.Equal(t, 2, len(round.Args))
+}
+
+func TestBinaryDoubleArithmetic(t *testing.T) {
+	assert.Equal(t, BinaryDoubleType, widestNumericType(IntType, BinaryDoubleType))
+	assert.Equal(t, BinaryDoubleType, widestNumericType(NumberType, BinaryDoubleType))
+	assert.Equal(t, NumberType, widestNumericType(IntType, VarcharType))
+	assert.Equal(t, IntType, widestNumericType(IntType, IntType))
+	assert.Nil(t, widestNumericType(IntType, BooleanType))
+}
+
+func TestCheckerDatetimeArithmetic(t *testing.T) {
+	nextDay := NewBinOp(NewVariable("D"), "+", NewNumericLiteral("1"))
+	days := NewBinOp(NewVariable("D"), "-", NewVariable("D"))
+	elapsed := NewBinOp(NewVariable("TS"), "-", NewVariable("D"))
+	later := NewBinOp(NewVariable("TS"), "+", NewVariable("DS"))
+	// strings are compared as dates
+	cond := NewBinOp(NewVariable("D"), "<", NewStringLiteral("'01-JAN-19'"))
+	trunc := newCall("", "TRUNC", NewVariable("TS"))
+	toChar := newCall("", "TO_CHAR", NewVariable("TS"), NewStringLiteral("'YYYY'"))
+	pkgs := newCheckerTestPackages(
+		NewAssignment(NewVariable("D"), nextDay),
+		newCall("DBMS", "PRINT", days),
+		newCall("DBMS", "PRINT", elapsed),
+		newCall("DBMS", "PRINT", later),
+		NewConditionalBranch(cond, NewBlock("t"), NewBlock("f")),
+		NewAssignment(NewVariable("D"), trunc),
+		newCall("DBMS", "PRINT", toChar),
+		// dates can't be multiplied
+		newCall("DBMS", "PRINT", NewBinOp(NewVariable("D"), "*", NewNumericLiteral("2"))),
+	)
+	mainFunc := pkgs["MAIN"].findFunction("MAIN")
+	mainFunc.AddLocal("D", "DATE", "'15-MAR-19'")
+	mainFunc.AddLocal("TS", "TIMESTAMP", "")
+	mainFunc.AddLocal("DS", "INTERVAL DAY TO SECOND", "'1 12:00:00'")
+
+	diagnostics := Check(pkgs)
+	assert.Equal(t, 1, len(diagnostics), "%v", diagnostics)
+	assert.Equal(t, "PLS-00306: wrong number or types of arguments in call to '*', got 'DATE' and 'INT'", diagnostics[0].Message)
+
+	assert.Equal(t, DateType, nextDay.Type())
+	assert.Equal(t, NumberType, nextDay.Right.(*Conversion).Type())
+	assert.Equal(t, NumberType, days.Type())
+	assert.Equal(t, DayToSecondType, elapsed.Type())
+	assert.Equal(t, TimestampType, elapsed.Right.(*Conversion).Type())
+	assert.Equal(t, TimestampType, later.Type())
+	assert.Equal(t, DateType, cond.Right.(*Conversion).Type())
+	assert.Equal(t, DateType, trunc.Type())
+	assert.Equal(t, DateType, trunc.Args[0].(*Conversion).Type())
+	assert.Equal(t, runtime.FormatDateFuncName, toChar.builtin.runtimeName)
+	assert.Equal(t, TimestampType, toChar.builtin.params[0])
+}
+
+func TestConvertDatetimeLiteral(t *testing.T) {
+	text, err := convertLiteral(DateType, "'15-MAR-2019'")
+	assert.Nil(t, err)
+	assert.Equal(t, "1552608000000000", text)
+	text, err = convertLiteral(TimestampType, "'15-mar-2019 01.45.30.25 PM'")
+	assert.Nil(t, err)
+	assert.Equal(t, "1552657530250000", text)
+	text, err = convertLiteral(DayToSecondType, "'-1 12:00:00'")
+	assert.Nil(t, err)
+	assert.Equal(t, "-129600000000", text)
+	text, err = convertLiteral(YearToMonthType, "'2-03'")
+	assert.Nil(t, err)
+	assert.Equal(t, "27", text)
+
+	assert.Equal(t, 2019, roundYear(19, 2026))
+	assert.Equal(t, 1999, roundYear(99, 2026))
+	assert.Equal(t, 2049, roundYear(49, 1999))
+
+	_, err = convertLiteral(DateType, "'30-FEB-2019'")
+	assert.NotNil(t, err)
+	_, err = convertLiteral(DateType, "'15-NARF-2019'")
+	assert.NotNil(t, err)
+	_, err = convertLiteral(DayToSecondType, "'1 24:00:00'")
+	assert.NotNil(t, err)
+}
+
+func TestCheckerDbmsOutput(t *testing.T) {
+	putLine := newCall("DBMS_OUTPUT", "PUT_LINE", NewVariable("V"))
+	enable := newCall("DBMS_OUTPUT", "ENABLE")
+	getLine := newCall("DBMS_OUTPUT", "GET_LINE", NewVariable("S"), NewVariable("V"))
+	getLines := newCall("DBMS_OUTPUT", "GET_LINES", NewVariable("A"), NewVariable("V"))
+	pkgs := newCheckerTestPackages(
+		putLine,
+		enable,
+		getLine,
+		newCall("DBMS_OUTPUT", "GET_LINES", NewVariable("L"), NewVariable("V")),
+		getLines,
+		// procedures don't return anything
+		NewAssignment(NewVariable("V"), newCall("DBMS_OUTPUT", "NEW_LINE")),
+		// OUT parameters need variables
+		newCall("DBMS_OUTPUT", "GET_LINE", NewStringLiteral("'narf'"), NewVariable("V")),
+		newCall("DBMS_OUTPUT", "GET_LINE", NewVariable("S"), NewVariable("S")),
+		newCall("DBMS_OUTPUT", "GET_LINES", NewVariable("S"), NewVariable("V")),
+		newCall("DBMS_OUTPUT", "NARF"),
+	)
+	mainFunc := pkgs["MAIN"].findFunction("MAIN")
+	mainFunc.AddLocal("S", "VARCHAR2(100)", "")
+	mainFunc.AddLocal("L", "DBMS_OUTPUT.CHARARR", "")
+	mainFunc.AddLocal("A", "DBMSOUTPUT_LINESARRAY", "")
+
+	diagnostics := Check(pkgs)
+	messages := make([]string, len(diagnostics))
+	for idx := range diagnostics {
+		messages[idx] = diagnostics[idx].Message
+	}
+	assert.Equal(t, []string{
+		"PLS-00222: no function with name 'NEW_LINE' exists in this scope",
+		"PLS-00363: argument 1 of 'GET_LINE' cannot be used as an assignment target",
+		"PLS-00306: wrong number or types of arguments in call to 'GET_LINE', argument 2 is 'VARCHAR(100)' but needs to be 'INT'",
+		"PLS-00306: wrong number or types of arguments in call to 'GET_LINES', argument 1 is 'VARCHAR(100)' but needs to be 'DBMSOUTPUT_LINESARRAY'",
+		"PLS-00302: component 'NARF' must be declared",
+	}, messages)
+
+	// numbers are printed as strings and the buffer size was filled in
+	assert.Equal(t, VarcharType, putLine.Args[0].(*Conversion).Type())
+	assert.Equal(t, 1, len(enable.Args))
+	assert.True(t, getLine.builtin.isOutParam(0))
+	assert.Equal(t, "S", getLine.Args[0].(*Variable).Name)
+	// the overload is picked by the type of the collection
+	assert.Equal(t, LinesArrayType, getLines.builtin.params[0])
+}
+
+// newTestSqlStatement creates a statement out of words, INTO variables have to be added separately
+func newTestSqlStatement(words ...string) *SqlStatement {
+	s := NewSqlStatement(words[0])
+	for _, w := range words {
+		isString := strings.HasPrefix(w, "'")
+		isIdentifier := !isString && w != words[0] && unicode.IsLetter(rune(w[0])) && w != "AS" && w != "IN"
+		s.AddToken(w, isIdentifier, isString)
+	}
+	return s
+}
+
+func TestCheckerSql(t *testing.T) {
+	update := newTestSqlStatement(strings.Fields("UPDATE T SET X = V , Y = P . X WHERE ID = LIB . LIMIT AND T . V = V")...)
+	query := newTestSqlStatement(strings.Fields("SELECT X FROM T A WHERE X IN ( SELECT V FROM V ) AND D < SYSDATE AND NAME = 'it' 's'")...)
+	query.AddInto(NewVariable("V"))
+	query.AddInto(NewVariable("S"))
+	query.AddInto(NewVariable("D"))
+	rowcount := NewAssignment(NewVariable("V"), NewCursorAttribute("SQL", "ROWCOUNT"))
+	found := NewConditionalBranch(NewCursorAttribute("SQL", "NOTFOUND"), nil, nil)
+	pkgs := newCheckerTestPackages(
+		update,
+		query,
+		rowcount,
+		found,
+		// queries need a target
+		newTestSqlStatement("SELECT", "X", "FROM", "T"),
+		NewAssignment(NewVariable("V"), NewCursorAttribute("SQL", "NARF")),
+		NewAssignment(NewVariable("V"), NewCursorAttribute("C", "FOUND")),
+		// without a schema variables are reported if the statement uses their names as columns
+		newTestSqlStatement(strings.Fields("UPDATE T SET S = S || 'x' WHERE ID = V")...),
+		newTestSqlStatement(strings.Fields("DELETE FROM T WHERE D = D")...),
+	)
+	mainFunc := pkgs["MAIN"].findFunction("MAIN")
+	mainFunc.AddLocal("S", "CHAR(3)", "")
+	mainFunc.AddLocal("D", "DATE", "")
+
+	diagnostics := Check(pkgs)
+	messages := make([]string, len(diagnostics))
+	for idx := range diagnostics {
+		messages[idx] = diagnostics[idx].Message
+	}
+	assert.Equal(t, []string{
+		"ORA-00918: column ambiguously defined, 'V' is a PL/SQL variable and might be a column too, rename the variable or check the statement against a schema",
+		"PLS-00428: an INTO clause is expected in this SELECT statement",
+		"PLS-00208: identifier 'NARF' is not a legal cursor attribute",
+		"PLS-00201: identifier 'C' must be declared",
+		"ORA-00918: column ambiguously defined, 'S' is a PL/SQL variable and might be a column too, rename the variable or check the statement against a schema",
+		"ORA-00918: column ambiguously defined, 'D' is a PL/SQL variable and might be a column too, rename the variable or check the statement against a schema",
+	}, messages)
+
+	// variables are bound, columns and tables aren't
+	assert.Equal(t, 1, len(update.queries))
+	assert.Equal(t, "UPDATE T SET X = ? , Y = ? WHERE ID = ? AND T . V = ?", update.queries[0].text)
+	assert.Equal(t, 4, len(update.queries[0].binds))
+	assert.Equal(t, "LIMIT", update.queries[0].binds[2].(*Variable).Name)
+
+	// dates are bound as text and columns are converted into the types of their targets
+	assert.Equal(t, "SELECT X FROM T A WHERE X IN ( SELECT ? FROM V ) AND ? < ? AND NAME = 'it''s'", query.queries[0].text)
+	assert.Equal(t, "TO_CHAR", query.queries[0].binds[1].(*FunctionCall).FunctionName)
+	assert.Equal(t, IntType, query.intoValues[0].Type())
+	assert.Equal(t, 3, query.intoValues[1].(*Conversion).Type().Length)
+	assert.Equal(t, DateType, query.intoValues[2].Type())
+
+	assert.Equal(t, IntType, rowcount.Expr.Type())
+	assert.Equal(t, BooleanType, found.Condition.Type())
+}
+
+func TestTranslateMerge(t *testing.T) {
+	merge := newTestSqlStatement(strings.Fields("MERGE INTO T A USING ( SELECT ID , X FROM U ) S ON ( A . ID = S . ID ) " +
+		"WHEN MATCHED THEN UPDATE SET A . X = S . X WHERE S . X > 0 " +
+		"WHEN NOT MATCHED THEN INSERT ( A . ID , A . X ) VALUES ( S . ID , S . X )")...)
+	statements, err := translateMerge(merge.Tokens)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(statements))
+	assert.Equal(t, "UPDATE T AS A SET X = S . X FROM ( SELECT ID , X FROM U ) AS S WHERE ( A . ID = S . ID ) AND ( S . X > 0 )", sqlText(statements[0]))
+	assert.Equal(t, "INSERT INTO T ( ID , X ) SELECT S . ID , S . X FROM ( SELECT ID , X FROM U ) AS S "+
+		"WHERE NOT EXISTS ( SELECT 1 FROM T AS A WHERE ( A . ID = S . ID ) )", sqlText(statements[1]))
+
+	_, err = translateMerge(newTestSqlStatement(strings.Fields("MERGE INTO T USING U ON ( T . ID = U . ID )")...).Tokens)
+	assert.NotNil(t, err)
+	_, err = translateMerge(newTestSqlStatement(strings.Fields("MERGE INTO T USING U ON ( T . ID = U . ID ) WHEN MATCHED THEN UPDATE SET X = 1 DELETE WHERE X = 1")...).Tokens)
+	assert.NotNil(t, err)
+}
+
+func TestCheckerCursors(t *testing.T) {
+	open := NewOpenCursor("C")
+	open.AddArg(NewNumericLiteral("1"))
+	fetch := NewFetchCursor("C")
+	fetch.AddInto(NewVariable("S"))
+	wrongArgs := NewOpenCursor("C")
+	wrongArgs.AddArg(NewVariable("P"))
+	pkgs := newCheckerTestPackages(
+		open,
+		fetch,
+		NewCloseCursor("C"),
+		NewAssignment(NewVariable("V"), NewCursorAttribute("C", "ROWCOUNT")),
+		wrongArgs,
+		NewOpenCursor("C"),
+		NewCloseCursor("V"),
+		NewCloseCursor("NARF"),
+	)
+	mainFunc := pkgs["MAIN"].findFunction("MAIN")
+	mainFunc.AddLocal("S", "VARCHAR2(10)", "")
+	proto := NewFunctionProto("C")
+	proto.AddParam("P_MIN", "IN", "NUMBER")
+	mainFunc.AddCursor(NewCursor(proto, newTestSqlStatement(strings.Fields("SELECT NAME FROM T WHERE X > P_MIN AND Y = V")...)))
+
+	diagnostics := Check(pkgs)
+	messages := make([]string, len(diagnostics))
+	for idx := range diagnostics {
+		messages[idx] = diagnostics[idx].Message
+	}
+	assert.Equal(t, []string{
+		"PLS-00306: wrong number or types of arguments in call to 'C', argument 1 is 'LIB.POINT' but needs to be 'NUMBER'",
+		"PLS-00306: wrong number or types of arguments in call to 'C', expected 1 but got 0",
+		"PLS-00456: item 'V' is not a cursor",
+		"PLS-00201: identifier 'NARF' must be declared",
+	}, messages)
+
+	// parameters and locals are bound, arguments are converted into the types of the parameters
+	cursor := mainFunc.Cursors[0]
+	assert.Equal(t, "SELECT NAME FROM T WHERE X > ? AND Y = ?", cursor.Query.queries[0].text)
+	assert.Equal(t, NumberType, open.Args[0].Type())
+	assert.Equal(t, cursor, open.decl)
+	assert.Equal(t, 1, len(fetch.columns))
+	assert.Equal(t, "VARCHAR", fetch.intoValues[0].Type().Name)
+}
+
+func TestSelectListNames(t *testing.T) {
+	query := newTestSqlStatement(strings.Fields("SELECT DISTINCT E . NAME , SAL * 2 AS DOUBLED , SAL BONUS , COUNT ( * ) , ID FROM EMP E")...)
+	names, err := selectListNames(query.Tokens)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"NAME", "DOUBLED", "BONUS", "COUNT ( * )", "ID"}, names)
+
+	_, err = selectListNames(newTestSqlStatement(strings.Fields("SELECT * FROM T")...).Tokens)
+	assert.NotNil(t, err)
+}
+
+func TestCheckerCursorForLoops(t *testing.T) {
+	loop := NewQueryForLoop("REC", newTestSqlStatement(strings.Fields("SELECT X , Y AS W FROM T WHERE Z = V")...))
+	duplicates := NewQueryForLoop("R", newTestSqlStatement(strings.Fields("SELECT X , X FROM T")...))
+	star := NewQueryForLoop("R", newTestSqlStatement(strings.Fields("SELECT * FROM T")...))
+	notCursor := NewCursorForLoop("R", NewOpenCursor("V"))
+	pkgs := newCheckerTestPackages(
+		loop,
+		loop.Fetch,
+		NewAssignment(NewVariable("V"), NewQualifiedVariable("REC", "W")),
+		loop.End(),
+		// the record isn't visible after the loop
+		NewAssignment(NewVariable("V"), NewQualifiedVariable("REC", "X")),
+		duplicates,
+		duplicates.End(),
+		star,
+		star.End(),
+		notCursor,
+		notCursor.End(),
+	)
+
+	diagnostics := Check(pkgs)
+	messages := make([]string, len(diagnostics))
+	for idx := range diagnostics {
+		messages[idx] = diagnostics[idx].Message
+	}
+	assert.Equal(t, []string{
+		"PLS-00201: identifier 'REC.X' must be declared",
+		"PLS-00402: alias required in SELECT list of cursor to avoid duplicate column names, 'X' is selected twice",
+		"ORA-03001: unimplemented feature, the columns of '*' aren't known without a schema",
+		"PLS-00456: item 'V' is not a cursor",
+	}, messages)
+
+	// the columns are fetched into the fields of the record
+	assert.Equal(t, "SELECT X , Y AS W FROM T WHERE Z = ?", loop.cursor.Query.queries[0].text)
+	assert.Equal(t, 2, len(loop.Fetch.Into))
+	assert.Equal(t, "REC", loop.Fetch.Into[1].Qualifier)
+	assert.Equal(t, "W", loop.Fetch.Into[1].Name)
+	assert.Equal(t, "VARCHAR", loop.Fetch.intoValues[1].Type().Name)
+}
+
+func TestCheckerDynamicSql(t *testing.T) {
+	ei := NewExecuteImmediate(NewBinOp(NewStringLiteral("'SELECT X FROM T WHERE ID = :1 AND D < '"), "||", NewVariable("V")))
+	ei.AddInto(NewVariable("S"))
+	ei.AddUsing(NewVariable("D"))
+	notString := NewExecuteImmediate(NewVariable("V"))
+	notSqlType := NewExecuteImmediate(NewStringLiteral("'DELETE FROM T WHERE X = :1'"))
+	notSqlType.AddUsing(NewVariable("P"))
+	pkgs := newCheckerTestPackages(
+		ei,
+		notString,
+		notSqlType,
+		NewOpenCursorFor("RC", NewStringLiteral("'SELECT 1'")),
+		NewOpenCursorFor("C", NewStringLiteral("'SELECT 1'")),
+		NewOpenCursor("RC"),
+	)
+	mainFunc := pkgs["MAIN"].findFunction("MAIN")
+	mainFunc.AddLocal("S", "VARCHAR2(10)", "")
+	mainFunc.AddLocal("D", "DATE", "")
+	mainFunc.AddLocal("RC", "SYS_REFCURSOR", "")
+	mainFunc.AddCursor(NewCursor(NewFunctionProto("C"), newTestSqlStatement(strings.Fields("SELECT X FROM T")...)))
+
+	diagnostics := Check(pkgs)
+	messages := make([]string, len(diagnostics))
+	for idx := range diagnostics {
+		messages[idx] = diagnostics[idx].Message
+	}
+	assert.Equal(t, []string{
+		"PLS-00382: expression is of wrong type, the statement is 'INT' but needs to be 'VARCHAR'",
+		"PLS-00457: expressions have to be of SQL types, '<variable> P' is 'LIB.POINT'",
+		"PLS-00382: expression is of wrong type, 'C' is 'CURSOR' but needs to be 'SYS_REFCURSOR'",
+		"PLS-00382: expression is of wrong type, cursor variable 'RC' needs to be opened FOR a query",
+	}, messages)
+
+	// dates are bound as text
+	_, ok := ei.Using[0].(*FunctionCall)
+	assert.True(t, ok)
+	assert.Equal(t, "VARCHAR", ei.intoValues[0].Type().Name)
+}
+
+func TestCheckerRefCursors(t *testing.T) {
+	fetch := NewFetchCursor("E")
+	fetch.AddInto(NewVariable("V"))
+	fetch.AddInto(NewVariable("V"))
+	reopen := NewFunction("REOPEN", false)
+	reopen.AddParam("C", "IN", "SYS_REFCURSOR")
+	reopenBlock := NewBlock("REOPEN-entry")
+	reopenBlock.AddInstruction(NewOpenCursorForQuery("C", newTestSqlStatement(strings.Fields("SELECT 1 FROM DUAL")...)))
+	reopen.AddBlock(reopenBlock)
+	pkgs := newCheckerTestPackages(
+		NewAssignment(NewVariable("S"), NewVariable("E")),
+		NewAssignment(NewVariable("E"), NewVariable("S")),
+		NewAssignment(NewVariable("E"), NewVariable("O")),
+		NewOpenCursorForQuery("E", newTestSqlStatement(strings.Fields("SELECT ID , NAME FROM T")...)),
+		NewOpenCursorForQuery("E", newTestSqlStatement(strings.Fields("SELECT ID FROM T")...)),
+		NewOpenCursorForQuery("V", newTestSqlStatement(strings.Fields("SELECT ID FROM T")...)),
+		fetch,
+	)
+	main := pkgs["MAIN"]
+	emp := NewRecordType("EMP_T")
+	emp.AddField("ID", "INT")
+	emp.AddField("NAME", "VARCHAR")
+	main.AddType(emp)
+	main.AddType(NewRefCursor("EMP_CUR", "EMP_T"))
+	main.AddType(NewRefCursor("POINT_CUR", "LIB.POINT"))
+	main.AddType(NewRefCursor("BAD_CUR", "INT"))
+	main.AddFunction(reopen)
+	mainFunc := main.findFunction("MAIN")
+	mainFunc.AddLocal("S", "SYS_REFCURSOR", "")
+	mainFunc.AddLocal("E", "EMP_CUR", "")
+	mainFunc.AddLocal("O", "POINT_CUR", "")
+
+	diagnostics := Check(pkgs)
+	messages := make([]string, len(diagnostics))
+	for idx := range diagnostics {
+		messages[idx] = diagnostics[idx].Message
+	}
+	assert.Equal(t, []string{
+		"PLS-00362: invalid cursor return type, 'INT' must be a record type",
+		"PLS-00382: expression is of wrong type, can't assign 'MAIN.POINT_CUR' to 'E' of type 'MAIN.EMP_CUR'",
+		"PLS-00382: expression is of wrong type, the query selects 1 column(s) but 'MAIN.EMP_T' has 2 field(s)",
+		"PLS-00456: item 'V' is not a cursor",
+		"PLS-00386: type mismatch found at 'V' between column 'NAME' of type 'VARCHAR' and INTO variable of type 'INT'",
+		"PLS-00363: expression 'C' cannot be used as an assignment target",
+	}, messages)
+
+	// the types of the columns are checked with a schema
+	pkgs = newCheckerTestPackages(
+		NewOpenCursorForQuery("E", newTestSqlStatement(strings.Fields("SELECT ID , NAME FROM EMP")...)),
+		NewOpenCursorForQuery("E", newTestSqlStatement(strings.Fields("SELECT NAME , ID FROM EMP")...)),
+	)
+	pkgs["MAIN"].AddType(emp)
+	pkgs["MAIN"].AddType(NewRefCursor("EMP_CUR", "EMP_T"))
+	pkgs["MAIN"].findFunction("MAIN").AddLocal("E", "EMP_CUR", "")
+	diagnostics = CheckWithSchema(pkgs, newTestSchema())
+	assert.Equal(t, 1, len(diagnostics))
+	assert.Equal(t, "PLS-00382: expression is of wrong type, column 'NAME' of type 'VARCHAR(20)' can't be fetched into field 'ID' of type 'INT'", diagnostics[0].Message)
+}
+
+func TestCheckerForAll(t *testing.T) {
+	insert := newTestSqlStatement(strings.Fields("INSERT INTO T VALUES ( I , V )")...)
+	forall := NewForAll("I", NewNumericLiteral("1"), NewVariable("V"), insert)
+	bulkRowcount := NewCursorAttribute("SQL", "BULK_ROWCOUNT")
+	bulkRowcount.Index = NewVariable("V")
+	rowcountWithIndex := NewCursorAttribute("SQL", "ROWCOUNT")
+	rowcountWithIndex.Index = NewVariable("V")
+	pkgs := newCheckerTestPackages(
+		forall,
+		NewForAll("I", NewVariable("D"), NewVariable("V"), newTestSqlStatement(strings.Fields("DELETE FROM T")...)),
+		NewAssignment(NewVariable("V"), bulkRowcount),
+		NewAssignment(NewVariable("V"), NewCursorAttribute("SQL", "BULK_ROWCOUNT")),
+		NewAssignment(NewVariable("V"), rowcountWithIndex),
+		NewAssignment(NewVariable("V"), NewVariable("I")),
+	)
+	pkgs["MAIN"].findFunction("MAIN").AddLocal("D", "DATE", "")
+
+	diagnostics := Check(pkgs)
+	messages := make([]string, len(diagnostics))
+	for idx := range diagnostics {
+		messages[idx] = diagnostics[idx].Message
+	}
+	assert.Equal(t, []string{
+		"PLS-00382: expression is of wrong type, the lower bound is 'DATE' but needs to be 'INT'",
+		"PLS-00306: wrong number or types of arguments in call to 'BULK_ROWCOUNT'",
+		"PLS-00208: identifier 'ROWCOUNT' is not a legal cursor attribute with an index",
+		"PLS-00201: identifier 'I' must be declared",
+	}, messages)
+
+	// the index is bound like a variable
+	assert.Equal(t, 2, len(insert.queries[0].binds))
+}
+
+func newTestSchema() *Schema {
+	emp := NewTable("EMP")
+	emp.AddColumn("ID", "INTEGER")
+	emp.AddColumn("NAME", "VARCHAR2(20)")
+	emp.AddColumn("SALARY", "NUMBER(8,2)")
+	schema := NewSchema()
+	schema.AddTable(emp)
+	schema.AddTable(NewView("EMP_V"))
+	return schema
+}
+
+func TestCheckerSchema(t *testing.T) {
+	query := newTestSqlStatement(strings.Fields("SELECT E . NAME , SALARY * 2 BONUS FROM EMP E WHERE E . ID = V ORDER BY BONUS")...)
+	query.AddInto(NewVariable("S"))
+	query.AddInto(NewVariable("V"))
+	row := newTestSqlStatement(strings.Fields("SELECT * FROM EMP WHERE ID = 1")...)
+	row.AddInto(NewVariable("R"))
+	tooMany := newTestSqlStatement(strings.Fields("SELECT * FROM EMP")...)
+	tooMany.AddInto(NewVariable("S"))
+	mismatch := newTestSqlStatement(strings.Fields("SELECT NAME FROM EMP")...)
+	mismatch.AddInto(NewVariable("V"))
+	view := newTestSqlStatement(strings.Fields("SELECT X FROM EMP_V")...)
+	view.AddInto(NewVariable("V"))
+	raise := newTestSqlStatement(strings.Fields("UPDATE EMP SET SALARY = SALARY * 2 WHERE ID = V")...)
+	pkgs := newCheckerTestPackages(
+		query,
+		raise,
+		row,
+		newTestSqlStatement(strings.Fields("DELETE FROM EMPS WHERE ID = 1")...),
+		newTestSqlStatement(strings.Fields("UPDATE EMP SET SALRY = 1 WHERE ID = V")...),
+		newTestSqlStatement(strings.Fields("INSERT INTO EMP ( ID , NAM ) VALUES ( V , 'x' )")...),
+		tooMany,
+		mismatch,
+		view,
+	)
+	mainFunc := pkgs["MAIN"].findFunction("MAIN")
+	mainFunc.AddLocal("S", "VARCHAR2(20)", "")
+	mainFunc.AddLocal("R", "EMP%ROWTYPE", "")
+	mainFunc.AddLocal("X", "NARF%ROWTYPE", "")
+	mainFunc.AddLocal("SALARY", "NUMBER", "")
+
+	diagnostics := CheckWithSchema(pkgs, newTestSchema())
+	messages := make([]string, len(diagnostics))
+	for idx := range diagnostics {
+		messages[idx] = diagnostics[idx].Message
+	}
+	assert.Equal(t, []string{
+		"ORA-00942: table or view 'NARF' does not exist",
+		"ORA-00942: table or view 'EMPS' does not exist",
+		"ORA-00904: 'SALRY': invalid identifier",
+		"ORA-00904: 'NAM': invalid identifier",
+		"ORA-00913: too many values, the query selects 3 column(s) into 1 variable(s)",
+		"PLS-00386: type mismatch found at 'V' between column 'NAME' of type 'VARCHAR(20)' and INTO variable of type 'INT'",
+	}, messages)
+
+	// a record of a row has the columns of its table
+	r := pkgs["MAIN"].rowTypes[0]
+	assert.Equal(t, "EMP%ROWTYPE", r.Name)
+	assert.Equal(t, []*RecordField{{Name: "ID", Type: "INT"}, {Name: "NAME", Type: "VARCHAR(20)"}, {Name: "SALARY", Type: "NUMBER"}}, r.Fields)
+	assert.Equal(t, 3, len(row.Into))
+	assert.Equal(t, "SELECT E . NAME , SALARY * 2 BONUS FROM EMP E WHERE E . ID = ? ORDER BY BONUS", query.queries[0].text)
+	// with a schema columns win over variables of the same name and nothing is ambiguous
+	assert.Equal(t, "UPDATE EMP SET SALARY = SALARY * 2 WHERE ID = ?", raise.queries[0].text)
+
+	// the columns of tables aren't known without a schema
+	pkgs = newCheckerTestPackages()
+	pkgs["MAIN"].findFunction("MAIN").AddLocal("R", "EMP%ROWTYPE", "")
+	diagnostics = Check(pkgs)
+	assert.Equal(t, 1, len(diagnostics))
+	assert.Equal(t, "PLS-00201: identifier 'EMP' must be declared, %ROWTYPE needs the schema of the database", diagnostics[0].Message)
+}
+
+func TestSqlColumnType(t *testing.T) {
+	for name, expected := range map[string]string{
+		"INTEGER":      "INT",
+		"NUMBER(10)":   "INT",
+		"NUMBER(10,0)": "INT",
+		"NUMBER(10,2)": "NUMBER",
+		"REAL":         "NUMBER",
+		"TEXT":         "VARCHAR",
+		"VARCHAR2(10)": "VARCHAR(10)",
+		"DATE":         "DATE",
+	} {
+		typ, ok := sqlColumnType(name)
+		assert.True(t, ok, name)
+		assert.Equal(t, expected, typ.String(), name)
+	}
+
+	_, ok := sqlColumnType("BLOB")
+	assert.False(t, ok)
+	emp := NewTable("EMP")
+	assert.Nil(t, emp.AddColumn("ID", "INTEGER"))
+	assert.NotNil(t, emp.AddColumn("ID", "TEXT"))
+}
@@ -0,0 +1,276 @@
+/*
+ * Copyright 2019 Marco Helmich
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ast
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+	"github.com/mhelmich/plsqlc/runtime"
+)
+
+// NewCollectionElement creates a reference to an element of a collection ('list(i)')
+// or to a field of an element that is a record ('list(i).field')
+func NewCollectionElement(collection *Variable, index Expression, field string) *CollectionElement {
+	return &CollectionElement{
+		Collection: collection,
+		Index:      index,
+		Field:      field,
+	}
+}
+
+// CollectionElement reads an element of a collection, elements that don't exist raise NO_DATA_FOUND
+// calls with a single argument are elements too if they name a collection ('list(i)'), the checker tells them apart
+type CollectionElement struct {
+	typed
+	Collection *Variable
+	Index      Expression
+	// the field of an element that is a record, empty for the whole element
+	Field string
+}
+
+func (ce *CollectionElement) expressionType() expressionType {
+	return collectionElementExpression
+}
+
+// address returns a pointer to the element
+// elements that are assigned to are created if they don't exist, nested tables and varrays have to be extended first
+func (ce *CollectionElement) address(cc *CompilerContext, isAssignment bool) value.Value {
+	ct := ce.Collection.Type().Collection
+	et, size := cc.elementType(ce.Collection.Type())
+	slot := ce.Collection.address(cc)
+	key := ce.Index.GenIR(cc)
+	kind := constant.NewInt(types.I64, ct.kind())
+
+	b := cc.currentLlvmBlock
+	var elem value.Value
+	if isAssignment {
+		elem = b.NewCall(cc.getFuncByName(runtime.CollectionAssignFuncName), slot, key, size, kind)
+	} else {
+		elem = b.NewCall(cc.getFuncByName(runtime.CollectionElementFuncName), b.NewLoad(slot), key, size, kind)
+	}
+	elem = b.NewBitCast(elem, types.NewPointer(et))
+	if ce.Field != "" {
+		return cc.fieldAddress(elem, ce.Field)
+	}
+	return elem
+}
+
+func (ce *CollectionElement) GenIR(cc *CompilerContext) value.Value {
+	return cc.currentLlvmBlock.NewLoad(ce.address(cc, false))
+}
+
+func (ce *CollectionElement) String() string {
+	s := fmt.Sprintf("<element> %s(%s)", ce.Collection.String(), ce.Index.String())
+	if ce.Field != "" {
+		s += "." + ce.Field
+	}
+	return s
+}
+
+// collectionMethod is a method of a collection, either one that returns a value ('list.COUNT', 'list.NEXT(i)')
+// or one that changes the collection ('list.EXTEND', 'list.DELETE(i)')
+// indexes that don't exist are returned as 0, there is no NULL to return
+type collectionMethod struct {
+	collection *Variable
+	name       string
+	args       []Expression
+}
+
+func (m *collectionMethod) genIR(cc *CompilerContext) value.Value {
+	t := m.collection.Type()
+	_, size := cc.elementType(t)
+	slot := m.collection.address(cc)
+	args := make([]value.Value, len(m.args))
+	for idx := range m.args {
+		args[idx] = m.args[idx].GenIR(cc)
+	}
+
+	b := cc.currentLlvmBlock
+	coll := b.NewLoad(slot)
+	switch m.name {
+	case "COUNT":
+		return b.NewCall(cc.getFuncByName(runtime.CollectionCountFuncName), coll)
+	case "FIRST":
+		return b.NewCall(cc.getFuncByName(runtime.CollectionFirstFuncName), coll)
+	case "LAST":
+		return b.NewCall(cc.getFuncByName(runtime.CollectionLastFuncName), coll)
+	case "NEXT":
+		return b.NewCall(cc.getFuncByName(runtime.CollectionNextFuncName), coll, args[0])
+	case "PRIOR":
+		return b.NewCall(cc.getFuncByName(runtime.CollectionPriorFuncName), coll, args[0])
+	case "EXISTS":
+		return b.NewCall(cc.getFuncByName(runtime.CollectionExistsFuncName), coll, args[0])
+	case "LIMIT":
+		return constant.NewInt(types.I64, t.Collection.Limit)
+	case "EXTEND":
+		var n value.Value = constant.NewInt(types.I64, 1)
+		if len(args) > 0 {
+			n = args[0]
+		}
+		b.NewCall(cc.getFuncByName(runtime.CollectionExtendFuncName), slot, n, size, constant.NewInt(types.I64, t.Collection.Limit))
+	case "TRIM":
+		var n value.Value = constant.NewInt(types.I64, 1)
+		if len(args) > 0 {
+			n = args[0]
+		}
+		b.NewCall(cc.getFuncByName(runtime.CollectionTrimFuncName), coll, n)
+	case "DELETE":
+		switch len(args) {
+		case 0:
+			b.NewCall(cc.getFuncByName(runtime.CollectionDeleteFuncName), coll)
+		case 1:
+			b.NewCall(cc.getFuncByName(runtime.CollectionDeleteRangeFuncName), coll, args[0], args[0], size)
+		default:
+			b.NewCall(cc.getFuncByName(runtime.CollectionDeleteRangeFuncName), coll, args[0], args[1], size)
+		}
+	}
+	return nil
+}
+
+func (m *collectionMethod) String() string {
+	args := make([]string, len(m.args))
+	for idx := range m.args {
+		args[idx] = m.args[idx].String()
+	}
+	return fmt.Sprintf("<method> %s.%s(%s)", m.collection.String(), m.name, strings.Join(args, ","))
+}
+
+// genIRForConstructor creates a collection with the arguments of a call of its type as elements ('list_t(1, 2, 3)')
+func genIRForConstructor(cc *CompilerContext, t *Type, args []Expression) value.Value {
+	et, size := cc.elementType(t)
+	slot := cc.newEntryAlloca(types.NewPointer(runtime.CollectionType))
+	cc.currentLlvmBlock.NewStore(constant.NewNull(types.NewPointer(runtime.CollectionType)), slot)
+	limit := constant.NewInt(types.I64, t.Collection.Limit)
+	for idx := range args {
+		v := args[idx].GenIR(cc)
+		if args[idx].expressionType() == stringExpression {
+			v = cc.currentLlvmBlock.NewLoad(v)
+		}
+		b := cc.currentLlvmBlock
+		elem := b.NewCall(cc.getFuncByName(runtime.CollectionExtendFuncName), slot, constant.NewInt(types.I64, 1), size, limit)
+		b.NewStore(v, b.NewBitCast(elem, types.NewPointer(et)))
+	}
+	return cc.currentLlvmBlock.NewLoad(slot)
+}
+
+// bulkCollect reads rows into collections with one element per row ('BULK COLLECT INTO a, b')
+// either every column goes into a collection of its own or all of them into the fields of a collection of records
+type bulkCollect struct {
+	collections []*Variable
+	// the field every column is read into if the elements are records
+	fields []string
+}
+
+// clear deletes the elements the collections had before the first row
+func (bc *bulkCollect) clear(cc *CompilerContext) {
+	for _, coll := range bc.collections {
+		b := cc.currentLlvmBlock
+		b.NewCall(cc.getFuncByName(runtime.CollectionDeleteFuncName), b.NewLoad(coll.address(cc)))
+	}
+}
+
+// extend adds an element to a collection and returns a pointer to it
+func (bc *bulkCollect) extend(cc *CompilerContext, coll *Variable) value.Value {
+	t := coll.Type()
+	et, size := cc.elementType(t)
+	limit := constant.NewInt(types.I64, t.Collection.Limit)
+	b := cc.currentLlvmBlock
+	elem := b.NewCall(cc.getFuncByName(runtime.CollectionExtendFuncName), coll.address(cc), constant.NewInt(types.I64, 1), size, limit)
+	return b.NewBitCast(elem, types.NewPointer(et))
+}
+
+// genIR reads the columns of the current row of stmt into a new element of every collection
+func (bc *bulkCollect) genIR(cc *CompilerContext, stmt value.Value, columns []*sqlColumn, values []Expression) {
+	for idx := range columns {
+		columns[idx].stmt = stmt
+	}
+
+	if len(bc.fields) > 0 {
+		elem := bc.extend(cc, bc.collections[0])
+		for idx := range values {
+			v := values[idx].GenIR(cc)
+			cc.currentLlvmBlock.NewStore(v, cc.fieldAddress(elem, bc.fields[idx]))
+		}
+		return
+	}
+
+	for idx := range values {
+		v := values[idx].GenIR(cc)
+		cc.currentLlvmBlock.NewStore(v, bc.extend(cc, bc.collections[idx]))
+	}
+}
+
+// genIRForRows reads all rows of a query, the number of rows is returned
+func (bc *bulkCollect) genIRForRows(cc *CompilerContext, stmt value.Value, columns []*sqlColumn, values []Expression) value.Value {
+	cc.currentLlvmBlock.NewCall(cc.getFuncByName(runtime.SqlCheckColumnsFuncName), stmt, constant.NewInt(types.I64, int64(len(columns))))
+	bc.clear(cc)
+	count := cc.newEntryAlloca(types.I64)
+	cc.currentLlvmBlock.NewStore(constant.NewInt(types.I64, 0), count)
+	condBlock := cc.currentLlvmFunc.NewBlock("")
+	rowBlock := cc.currentLlvmFunc.NewBlock("")
+	doneBlock := cc.currentLlvmFunc.NewBlock("")
+	cc.currentLlvmBlock.NewBr(condBlock)
+
+	found := condBlock.NewCall(cc.getFuncByName(runtime.SqlNextRowFuncName), stmt)
+	condBlock.NewCondBr(found, rowBlock, doneBlock)
+
+	cc.currentLlvmBlock = rowBlock
+	bc.genIR(cc, stmt, columns, values)
+	b := cc.currentLlvmBlock
+	b.NewStore(b.NewAdd(b.NewLoad(count), constant.NewInt(types.I64, 1)), count)
+	b.NewBr(condBlock)
+
+	cc.currentLlvmBlock = doneBlock
+	return doneBlock.NewLoad(count)
+}
+
+// genIRForFetch fetches the rows of a cursor, all that are left or at most limit if limit isn't nil
+func (bc *bulkCollect) genIRForFetch(cc *CompilerContext, cursor value.Value, limit value.Value, columns []*sqlColumn, values []Expression) {
+	bc.clear(cc)
+	count := cc.newEntryAlloca(types.I64)
+	cc.currentLlvmBlock.NewStore(constant.NewInt(types.I64, 0), count)
+	condBlock := cc.currentLlvmFunc.NewBlock("")
+	fetchBlock := cc.currentLlvmFunc.NewBlock("")
+	rowBlock := cc.currentLlvmFunc.NewBlock("")
+	doneBlock := cc.currentLlvmFunc.NewBlock("")
+	cc.currentLlvmBlock.NewBr(condBlock)
+
+	if limit != nil {
+		condBlock.NewCondBr(condBlock.NewICmp(enum.IPredSLT, condBlock.NewLoad(count), limit), fetchBlock, doneBlock)
+	} else {
+		condBlock.NewBr(fetchBlock)
+	}
+
+	n := constant.NewInt(types.I64, int64(len(columns)))
+	found := fetchBlock.NewCall(cc.getFuncByName(runtime.SqlFetchFuncName), cursor, n)
+	fetchBlock.NewCondBr(found, rowBlock, doneBlock)
+
+	cc.currentLlvmBlock = rowBlock
+	// the statement is the first field of a cursor
+	stmt := rowBlock.NewLoad(rowBlock.NewGetElementPtr(cursor, llvmZero, llvmZero))
+	bc.genIR(cc, stmt, columns, values)
+	b := cc.currentLlvmBlock
+	b.NewStore(b.NewAdd(b.NewLoad(count), constant.NewInt(types.I64, 1)), count)
+	b.NewBr(condBlock)
+
+	cc.currentLlvmBlock = doneBlock
+}
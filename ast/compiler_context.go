@@ -18,11 +18,13 @@ package ast
 
 import (
 	"log"
+	"strings"
 
 	"github.com/llir/llvm/ir"
 	"github.com/llir/llvm/ir/constant"
 	"github.com/llir/llvm/ir/types"
 	"github.com/llir/llvm/ir/value"
+	"github.com/mhelmich/plsqlc/runtime"
 )
 
 var (
@@ -37,15 +39,38 @@ type CompilerContext struct {
 	functionBlocks     map[*Block]*ir.Block
 	currentLlvmBlock   *ir.Block
 	scopes             *scope
+	packages           map[string]*Package
+	// maps qualified subtype names to the (qualified) name of their base type
+	subtypes    map[string]string
+	records     map[string]*RecordType
+	recordTypes map[string]types.Type
+	collections map[string]*CollectionType
+	// the cursors of the cursor FOR loops the current block is in, the innermost loop comes last
+	loopCursors []value.Value
+	// the connection of the caller of an autonomous function, nil in all other functions
+	autonomousCaller value.Value
+	// nil unless debug info is enabled
+	debug *debugInfo
 }
 
 func NewCompilerContext(mod *ir.Module) *CompilerContext {
 	return &CompilerContext{
-		llvmModule: mod,
-		scopes:     newScope(),
+		llvmModule:  mod,
+		scopes:      newScope(),
+		packages:    make(map[string]*Package),
+		subtypes:    make(map[string]string),
+		records:     make(map[string]*RecordType),
+		recordTypes: make(map[string]types.Type),
+		collections: make(map[string]*CollectionType),
 	}
 }
 
+// SetPackages makes all packages of a program known to the context
+// so that calls across package boundaries can be checked
+func (cc *CompilerContext) SetPackages(pkgs map[string]*Package) {
+	cc.packages = pkgs
+}
+
 func (cc *CompilerContext) getFuncByName(n string) *ir.Func {
 	for idx := range cc.llvmModule.Funcs {
 		if cc.llvmModule.Funcs[idx].Name() == n {
@@ -57,15 +82,31 @@ func (cc *CompilerContext) getFuncByName(n string) *ir.Func {
 	return nil
 }
 
+// findFunctionProto returns the prototype of a user defined function
+// or nil if there is no such function
+func (cc *CompilerContext) findFunctionProto(packageName string, functionName string) *FunctionProto {
+	pkg, ok := cc.packages[packageName]
+	if !ok {
+		return nil
+	}
+	return pkg.findProto(functionName)
+}
+
 func (cc *CompilerContext) getGlobalByName(n string) *ir.Global {
+	g, ok := cc.findGlobalByName(n)
+	if !ok {
+		log.Panicf("Can't find global %s", n)
+	}
+	return g
+}
+
+func (cc *CompilerContext) findGlobalByName(n string) (*ir.Global, bool) {
 	for idx := range cc.llvmModule.Globals {
 		if cc.llvmModule.Globals[idx].Name() == n {
-			return cc.llvmModule.Globals[idx]
+			return cc.llvmModule.Globals[idx], true
 		}
 	}
-
-	log.Panicf("Can't find global %s", n)
-	return nil
+	return nil, false
 }
 
 func (cc *CompilerContext) getTypeByName(n string) types.Type {
@@ -79,6 +120,110 @@ func (cc *CompilerContext) getTypeByName(n string) types.Type {
 	return nil
 }
 
+// qualifyTypeName prefixes user defined types of the current package
+// with the package name, built-in types are returned as they are
+func (cc *CompilerContext) qualifyTypeName(t string) string {
+	if strings.Contains(t, ".") {
+		return t
+	}
+
+	qualifiedName := cc.currentPackageName + "." + t
+	if _, ok := cc.subtypes[qualifiedName]; ok {
+		return qualifiedName
+	} else if _, ok := cc.records[qualifiedName]; ok {
+		return qualifiedName
+	} else if _, ok := cc.collections[qualifiedName]; ok {
+		return qualifiedName
+	}
+	return t
+}
+
+// baseTypeName resolves all subtypes until it hits a built-in or record type
+func (cc *CompilerContext) baseTypeName(t string) string {
+	n := cc.qualifyTypeName(t)
+	for {
+		base, ok := cc.subtypes[n]
+		if !ok {
+			return n
+		}
+		n = base
+	}
+}
+
+func (cc *CompilerContext) llvmTypeFor(t string) types.Type {
+	n := cc.baseTypeName(t)
+	if rt, ok := cc.recordTypes[n]; ok {
+		return rt
+	} else if _, ok := cc.collections[n]; ok {
+		return types.NewPointer(runtime.CollectionType)
+	}
+	return plsqlTypeToLLVMType(n)
+}
+
+// elementType returns the llvm type of the elements of a collection and their size in bytes
+func (cc *CompilerContext) elementType(t *Type) (types.Type, constant.Constant) {
+	et := cc.llvmTypeFor(t.Collection.element.Name)
+	size := constant.NewPtrToInt(constant.NewGetElementPtr(constant.NewNull(types.NewPointer(et)), llvmOne), types.I64)
+	return et, size
+}
+
+// literalText converts the literal a variable of a built-in type is initialized with
+// into the text of a value of that type
+func (cc *CompilerContext) literalText(baseType string, literal string) string {
+	t, ok := builtinType(baseType)
+	if !ok {
+		log.Panicf("Variables of type '%s' can't be initialized with '%s'", baseType, literal)
+	}
+
+	text, err := convertLiteral(t, literal)
+	if err != nil {
+		log.Panicf("%s", err.Error())
+	}
+	return text
+}
+
+// findVariable looks up a variable by name in the local scopes first
+// names that aren't local are looked up in the variables of the current package
+func (cc *CompilerContext) findVariable(name string) (value.Value, bool) {
+	if mem, ok := cc.scopes.findMember(name); ok {
+		return mem, true
+	}
+
+	if g, ok := cc.findGlobalByName(cc.currentPackageName + "." + name); ok {
+		return g, true
+	}
+	return nil, false
+}
+
+// fieldAddress returns a pointer to a field of a record
+func (cc *CompilerContext) fieldAddress(rec value.Value, field string) value.Value {
+	ptrType, ok := rec.Type().(*types.PointerType)
+	if !ok {
+		log.Panicf("Can't access field '%s' of '%s'", field, rec.Ident())
+	}
+
+	rt, ok := cc.records[ptrType.ElemType.Name()]
+	if !ok {
+		log.Panicf("Can't access field '%s' of '%s' because it isn't a record", field, rec.Ident())
+	}
+
+	idx := rt.fieldIndex(field)
+	if idx < 0 {
+		log.Panicf("PLS-00302: component '%s' must be declared in record '%s'", field, rt.Name)
+	}
+
+	return cc.currentLlvmBlock.NewGetElementPtr(rec, llvmZero, constant.NewInt(types.I32, int64(idx)))
+}
+
+// newEntryAlloca allocates memory in the first block of the current function
+// memory that is used inside of loops is allocated once that way instead of on every iteration
+func (cc *CompilerContext) newEntryAlloca(t types.Type) *ir.InstAlloca {
+	alloca := ir.NewAlloca(t)
+	entry := cc.currentLlvmFunc.Blocks[0]
+	entry.Insts = append([]ir.Instruction{alloca}, entry.Insts...)
+	return alloca
+}
+
 func (cc *CompilerContext) GetIRModule() *ir.Module {
 	return cc.llvmModule
 }
@@ -0,0 +1,274 @@
+/*
+ * Copyright 2019 Marco Helmich
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ast
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+	"github.com/mhelmich/plsqlc/runtime"
+)
+
+// implicitConversions lists which types are converted into which other types implicitly
+// BOOLEAN and records are never converted
+var implicitConversions = map[string]map[string]bool{
+	IntType.Name: {
+		NumberType.Name:       true,
+		BinaryDoubleType.Name: true,
+		VarcharType.Name:      true,
+		CharType.Name:         true,
+	},
+	NumberType.Name: {
+		IntType.Name:          true,
+		BinaryDoubleType.Name: true,
+		VarcharType.Name:      true,
+		CharType.Name:         true,
+	},
+	BinaryDoubleType.Name: {
+		IntType.Name:     true,
+		NumberType.Name:  true,
+		VarcharType.Name: true,
+		CharType.Name:    true,
+	},
+	VarcharType.Name: {
+		IntType.Name:          true,
+		NumberType.Name:       true,
+		BinaryDoubleType.Name: true,
+		CharType.Name:         true,
+		DateType.Name:         true,
+		TimestampType.Name:    true,
+		DayToSecondType.Name:  true,
+		YearToMonthType.Name:  true,
+	},
+	CharType.Name: {
+		IntType.Name:          true,
+		NumberType.Name:       true,
+		BinaryDoubleType.Name: true,
+		VarcharType.Name:      true,
+		DateType.Name:         true,
+		TimestampType.Name:    true,
+		DayToSecondType.Name:  true,
+		YearToMonthType.Name:  true,
+	},
+	DateType.Name: {
+		TimestampType.Name: true,
+		VarcharType.Name:   true,
+		CharType.Name:      true,
+	},
+	TimestampType.Name: {
+		DateType.Name:    true,
+		VarcharType.Name: true,
+		CharType.Name:    true,
+	},
+	DayToSecondType.Name: {
+		VarcharType.Name: true,
+		CharType.Name:    true,
+	},
+	YearToMonthType.Name: {
+		VarcharType.Name: true,
+		CharType.Name:    true,
+	},
+}
+
+// the runtime functions that convert dates, timestamps and intervals from and to strings
+// they use the formats Oracle uses by default
+var (
+	datetimeToString = map[string]string{
+		DateType.Name:        runtime.DateToStringFuncName,
+		TimestampType.Name:   runtime.TimestampToStringFuncName,
+		DayToSecondType.Name: runtime.DSIntervalToStringFuncName,
+		YearToMonthType.Name: runtime.YMIntervalToStringFuncName,
+	}
+	stringToDatetime = map[string]string{
+		DateType.Name:        runtime.StringToDateFuncName,
+		TimestampType.Name:   runtime.StringToTimestampFuncName,
+		DayToSecondType.Name: runtime.StringToDSIntervalFuncName,
+		YearToMonthType.Name: runtime.StringToYMIntervalFuncName,
+	}
+)
+
+func canConvert(from *Type, to *Type) bool {
+	return from.Equal(to) || implicitConversions[from.Name][to.Name]
+}
+
+// newConversion wraps an expression so that its value is converted into another type
+func newConversion(expr Expression, to *Type) *Conversion {
+	c := &Conversion{
+		Expr: expr,
+	}
+	c.setType(to)
+	return c
+}
+
+// Conversion is inserted by the checker wherever a value needs to be converted implicitly
+// strings that don't contain a number raise VALUE_ERROR at runtime
+// so do strings that are too long for a constrained type, CHAR values are blank-padded
+type Conversion struct {
+	typed
+	Expr Expression
+}
+
+func (c *Conversion) expressionType() expressionType {
+	return conversionExpression
+}
+
+func (c *Conversion) GenIR(cc *CompilerContext) value.Value {
+	v := c.Expr.GenIR(cc)
+	if c.Expr.expressionType() == stringExpression {
+		v = cc.currentLlvmBlock.NewLoad(v)
+	}
+
+	v = c.convertValue(cc, v)
+	if c.typ.Length == 0 {
+		return v
+	}
+
+	length := constant.NewInt(types.I64, int64(c.typ.Length))
+	if c.typ.Equal(CharType) {
+		return cc.currentLlvmBlock.NewCall(cc.getFuncByName(runtime.PadStringFuncName), v, length)
+	}
+	return cc.currentLlvmBlock.NewCall(cc.getFuncByName(runtime.CheckStringLengthFuncName), v, length)
+}
+
+func (c *Conversion) convertValue(cc *CompilerContext, v value.Value) value.Value {
+	from := c.Expr.Type()
+	b := cc.currentLlvmBlock
+	switch {
+	case from.Equal(c.typ), from.IsString() && c.typ.IsString(), from.isDouble() && c.typ.isDouble():
+		// CHAR and VARCHAR share their representation, so do NUMBER and BINARY_DOUBLE
+		return v
+	case from.Equal(IntType) && c.typ.isDouble():
+		return b.NewSIToFP(v, types.Double)
+	case from.Equal(IntType) && c.typ.IsString():
+		return b.NewCall(cc.getFuncByName(runtime.IntToStringFuncName), v)
+	case from.isDouble() && c.typ.Equal(IntType):
+		return b.NewCall(cc.getFuncByName(runtime.NumberToIntFuncName), v)
+	case from.isDouble() && c.typ.IsString():
+		return b.NewCall(cc.getFuncByName(runtime.NumberToStringFuncName), v)
+	case from.IsString() && c.typ.isDouble():
+		return b.NewCall(cc.getFuncByName(runtime.StringToNumberFuncName), v)
+	case from.IsString() && c.typ.Equal(IntType):
+		d := b.NewCall(cc.getFuncByName(runtime.StringToNumberFuncName), v)
+		return b.NewCall(cc.getFuncByName(runtime.NumberToIntFuncName), d)
+	case from.Equal(DateType) && c.typ.Equal(TimestampType):
+		// dates are timestamps without fractions of a second
+		return v
+	case from.Equal(TimestampType) && c.typ.Equal(DateType):
+		return b.NewCall(cc.getFuncByName(runtime.TimestampToDateFuncName), v)
+	case (from.isDatetime() || from.isInterval()) && c.typ.IsString():
+		return b.NewCall(cc.getFuncByName(datetimeToString[from.Name]), v)
+	case from.IsString() && (c.typ.isDatetime() || c.typ.isInterval()):
+		return b.NewCall(cc.getFuncByName(stringToDatetime[c.typ.Name]), v)
+	}
+
+	log.Panicf("Can't convert '%s' to '%s'", from.String(), c.typ.String())
+	return nil
+}
+
+func (c *Conversion) String() string {
+	return fmt.Sprintf("<conversion> %s(%s)", c.typ.String(), c.Expr.String())
+}
+
+// literalType returns the type of a literal in a declaration
+func literalType(literal string) *Type {
+	if literal[0] == '\'' {
+		return VarcharType
+	} else if _, err := strconv.ParseInt(literal, 10, 64); err == nil {
+		return IntType
+	}
+	return NumberType
+}
+
+// convertLiteral converts the literal in a declaration into the text of a value of type t
+// integers are rounded, strings are unquoted and numbers are written without a leading zero ('.5')
+// the conversion happens at compile time and fails for strings that don't contain a number
+func convertLiteral(t *Type, literal string) (string, error) {
+	from := literalType(literal)
+	text := literal
+	if from.Equal(VarcharType) {
+		// chop off the 's on both ends, doubled quotes stand for one
+		text = strings.Replace(literal[1:len(literal)-1], "''", "'", -1)
+	}
+
+	switch t.Name {
+	case VarcharType.Name, CharType.Name:
+		if from.Equal(NumberType) {
+			f, _ := strconv.ParseFloat(text, 64)
+			text = formatNumber(f)
+		}
+		return constrainString(t, text)
+
+	case IntType.Name, NumberType.Name, BinaryDoubleType.Name:
+		f, err := strconv.ParseFloat(strings.Trim(text, " "), 64)
+		if err != nil {
+			return "", fmt.Errorf("%s", runtime.ValueErrorMessage)
+		}
+		if t.isDouble() {
+			return strconv.FormatFloat(f, 'g', -1, 64), nil
+		}
+		if from.Equal(IntType) {
+			return text, nil
+		}
+		return strconv.FormatInt(roundHalfAwayFromZero(f), 10), nil
+
+	case DateType.Name, TimestampType.Name, DayToSecondType.Name, YearToMonthType.Name:
+		if !from.Equal(VarcharType) {
+			break
+		}
+		v, err := parseDatetimeLiteral(t, text)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatInt(v, 10), nil
+	}
+
+	return "", fmt.Errorf("Can't convert '%s' to '%s'", literal, t.String())
+}
+
+// constrainString checks that a string fits into a constrained type and blank-pads CHAR values
+func constrainString(t *Type, s string) (string, error) {
+	if t.Length == 0 {
+		return s, nil
+	} else if len(s) > t.Length {
+		return "", fmt.Errorf("%s", runtime.BufferTooSmallMessage)
+	} else if t.Equal(CharType) {
+		return s + strings.Repeat(" ", t.Length-len(s)), nil
+	}
+	return s, nil
+}
+
+func roundHalfAwayFromZero(f float64) int64 {
+	if f < 0 {
+		return int64(f - 0.5)
+	}
+	return int64(f + 0.5)
+}
+
+func formatNumber(f float64) string {
+	s := strconv.FormatFloat(f, 'f', -1, 64)
+	if strings.HasPrefix(s, "0.") {
+		return s[1:]
+	} else if strings.HasPrefix(s, "-0.") {
+		return "-" + s[2:]
+	}
+	return s
+}
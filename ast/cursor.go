@@ -0,0 +1,212 @@
+/*
+ * Copyright 2019 Marco Helmich
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ast
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+	"github.com/mhelmich/plsqlc/runtime"
+)
+
+// NewCursor creates a cursor out of its signature ('c(p INT)') and its query
+func NewCursor(proto *FunctionProto, query *SqlStatement) *Cursor {
+	return &Cursor{
+		Proto: proto,
+		Query: query,
+	}
+}
+
+// Cursor is the declaration of an explicit cursor ('CURSOR c(p INT) IS SELECT ...')
+// the parameters can only be used inside the query, their values are bound when the cursor is opened
+type Cursor struct {
+	Proto *FunctionProto
+	Query *SqlStatement
+}
+
+func (c *Cursor) Name() string {
+	return c.Proto.Name
+}
+
+// GenIR creates the state of the cursor, it is closed to begin with
+func (c *Cursor) GenIR(cc *CompilerContext) value.Value {
+	runtime.GenerateSQLInModule(cc.llvmModule)
+	cursorType := cc.getTypeByName(runtime.CursorTypeName)
+	alloca := cc.currentLlvmBlock.NewAlloca(cursorType)
+	cc.currentLlvmBlock.NewStore(constant.NewZeroInitializer(cursorType), alloca)
+	cc.scopes.addMember(c.Name(), alloca)
+	return alloca
+}
+
+func (c *Cursor) String() string {
+	return fmt.Sprintf("<cursor> %s IS %s", c.Proto.String(), sqlText(c.Query.Tokens))
+}
+
+// cursorAddress returns a pointer to the state of a cursor
+// cursor variables hold that pointer, it is null if they were never opened
+func cursorAddress(cc *CompilerContext, name string) value.Value {
+	mem, ok := cc.findVariable(name)
+	if !ok {
+		log.Panicf("Can't find cursor '%s' in scope", name)
+	}
+	if ptr := mem.Type().(*types.PointerType); types.Equal(ptr.ElemType, types.NewPointer(cc.getTypeByName(runtime.CursorTypeName))) {
+		return cc.currentLlvmBlock.NewLoad(mem)
+	}
+	return mem
+}
+
+func NewOpenCursor(cursor string) *OpenCursor {
+	return &OpenCursor{
+		Cursor: cursor,
+		Args:   make([]Expression, 0),
+	}
+}
+
+// OpenCursor runs the query of a cursor ('OPEN c(42)')
+type OpenCursor struct {
+	Cursor string
+	Args   []Expression
+	// set by the checker
+	decl *Cursor
+}
+
+func (o *OpenCursor) AddArg(expr Expression) {
+	o.Args = append(o.Args, expr)
+}
+
+func (o *OpenCursor) GenIR(cc *CompilerContext) value.Value {
+	cursor := cursorAddress(cc, o.Cursor)
+
+	// the parameters are visible to the binds of the query only
+	cc.pushScope()
+	for idx, param := range o.decl.Proto.Params {
+		v := o.Args[idx].GenIR(cc)
+		if o.Args[idx].expressionType() == stringExpression {
+			v = cc.currentLlvmBlock.NewLoad(v)
+		}
+		alloca := cc.newEntryAlloca(cc.llvmTypeFor(param.Type))
+		cc.currentLlvmBlock.NewStore(v, alloca)
+		cc.scopes.addMember(param.Name, alloca)
+	}
+	stmt := o.decl.Query.queries[0].genIR(cc)
+	cc.popScope()
+
+	cc.currentLlvmBlock.NewCall(cc.getFuncByName(runtime.SqlOpenFuncName), cursor, stmt)
+	return nil
+}
+
+func (o *OpenCursor) String() string {
+	return fmt.Sprintf("<open> %s", o.Cursor)
+}
+
+func NewFetchCursor(cursor string) *FetchCursor {
+	return &FetchCursor{
+		Cursor: cursor,
+		Into:   make([]*Variable, 0),
+	}
+}
+
+// FetchCursor reads the next row of a cursor into variables ('FETCH c INTO a, b')
+// the variables keep their values if there is no row left
+type FetchCursor struct {
+	Cursor string
+	Into   []*Variable
+	// set for 'BULK COLLECT INTO', the rows that are left are fetched into collections
+	Bulk bool
+	// the maximum number of rows a bulk fetch reads ('LIMIT n'), nil if there is none
+	Limit Expression
+	// set by the checker
+	intoValues []Expression
+	columns    []*sqlColumn
+	bulk       *bulkCollect
+}
+
+func (fc *FetchCursor) AddInto(v *Variable) {
+	fc.Into = append(fc.Into, v)
+}
+
+func (fc *FetchCursor) GenIR(cc *CompilerContext) value.Value {
+	cursor := cursorAddress(cc, fc.Cursor)
+	if fc.bulk != nil {
+		var limit value.Value
+		if fc.Limit != nil {
+			limit = fc.Limit.GenIR(cc)
+		}
+		fc.bulk.genIRForFetch(cc, cursor, limit, fc.columns, fc.intoValues)
+		return nil
+	}
+
+	n := constant.NewInt(types.I64, int64(len(fc.columns)))
+	found := cc.currentLlvmBlock.NewCall(cc.getFuncByName(runtime.SqlFetchFuncName), cursor, n)
+
+	rowBlock := cc.currentLlvmFunc.NewBlock("")
+	doneBlock := cc.currentLlvmFunc.NewBlock("")
+	cc.currentLlvmBlock.NewCondBr(found, rowBlock, doneBlock)
+
+	cc.currentLlvmBlock = rowBlock
+	// the statement is the first field of a cursor
+	stmt := rowBlock.NewLoad(rowBlock.NewGetElementPtr(cursor, llvmZero, llvmZero))
+	genIRForInto(cc, stmt, fc.Into, fc.columns, fc.intoValues)
+	cc.currentLlvmBlock.NewBr(doneBlock)
+
+	cc.currentLlvmBlock = doneBlock
+	return nil
+}
+
+func (fc *FetchCursor) String() string {
+	names := make([]string, len(fc.Into))
+	for idx := range fc.Into {
+		names[idx] = fc.Into[idx].String()
+	}
+	if fc.Bulk {
+		return fmt.Sprintf("<fetch> %s BULK COLLECT INTO %s", fc.Cursor, strings.Join(names, ", "))
+	}
+	return fmt.Sprintf("<fetch> %s INTO %s", fc.Cursor, strings.Join(names, ", "))
+}
+
+func NewCloseCursor(cursor string) *CloseCursor {
+	return &CloseCursor{
+		Cursor: cursor,
+	}
+}
+
+// CloseCursor releases the query of a cursor ('CLOSE c')
+type CloseCursor struct {
+	Cursor string
+}
+
+func (c *CloseCursor) GenIR(cc *CompilerContext) value.Value {
+	cc.currentLlvmBlock.NewCall(cc.getFuncByName(runtime.SqlCloseFuncName), cursorAddress(cc, c.Cursor))
+	return nil
+}
+
+func (c *CloseCursor) String() string {
+	return fmt.Sprintf("<close> %s", c.Cursor)
+}
+
+// genIRForInto reads the columns of the current row of stmt into variables
+func genIRForInto(cc *CompilerContext, stmt value.Value, into []*Variable, columns []*sqlColumn, values []Expression) {
+	for idx := range into {
+		columns[idx].stmt = stmt
+		v := values[idx].GenIR(cc)
+		cc.currentLlvmBlock.NewStore(v, into[idx].address(cc))
+	}
+}
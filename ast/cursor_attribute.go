@@ -0,0 +1,104 @@
+/*
+ * Copyright 2019 Marco Helmich
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ast
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+	"github.com/mhelmich/plsqlc/runtime"
+)
+
+// the implicit cursor that describes the last embedded SQL statement
+const implicitCursorName = "SQL"
+
+func NewCursorAttribute(cursor string, attribute string) *CursorAttribute {
+	return &CursorAttribute{
+		Cursor:    cursor,
+		Attribute: attribute,
+	}
+}
+
+// CursorAttribute is an attribute of a cursor ('SQL%ROWCOUNT')
+type CursorAttribute struct {
+	typed
+	Cursor    string
+	Attribute string
+	// the index of the run of a FORALL ('SQL%BULK_ROWCOUNT(i)'), nil for all other attributes
+	Index Expression
+}
+
+func (ca *CursorAttribute) expressionType() expressionType {
+	return cursorAttributeExpression
+}
+
+// GenIR derives all attributes of the implicit cursor from the number of rows the last statement touched
+// the implicit cursor is closed as soon as a statement is done
+func (ca *CursorAttribute) GenIR(cc *CompilerContext) value.Value {
+	b := cc.currentLlvmBlock
+	if ca.Cursor != implicitCursorName {
+		return ca.genIRForExplicitCursor(cc)
+	} else if ca.Attribute == "ISOPEN" {
+		return constant.False
+	} else if ca.Attribute == "BULK_ROWCOUNT" {
+		runtime.GenerateSQLInModule(cc.llvmModule)
+		i := ca.Index.GenIR(cc)
+		return cc.currentLlvmBlock.NewCall(cc.getFuncByName(runtime.SqlBulkRowcountFuncName), i)
+	}
+
+	rowcount := b.NewLoad(cc.getGlobalByName(runtime.SqlRowcountName))
+	zero := constant.NewInt(types.I64, 0)
+	switch ca.Attribute {
+	case "ROWCOUNT":
+		return rowcount
+	case "FOUND":
+		return b.NewICmp(enum.IPredSGT, rowcount, zero)
+	case "NOTFOUND":
+		return b.NewICmp(enum.IPredEQ, rowcount, zero)
+	}
+
+	log.Panicf("PLS-00208: identifier '%s' is not a legal cursor attribute", ca.Attribute)
+	return nil
+}
+
+// genIRForExplicitCursor asks the runtime about the state of a cursor
+// all attributes but ISOPEN raise INVALID_CURSOR if the cursor isn't open
+func (ca *CursorAttribute) genIRForExplicitCursor(cc *CompilerContext) value.Value {
+	b := cc.currentLlvmBlock
+	cursor := cursorAddress(cc, ca.Cursor)
+	switch ca.Attribute {
+	case "ROWCOUNT":
+		return b.NewCall(cc.getFuncByName(runtime.SqlCursorRowcountFuncName), cursor)
+	case "FOUND":
+		return b.NewCall(cc.getFuncByName(runtime.SqlCursorFoundFuncName), cursor)
+	case "NOTFOUND":
+		return b.NewXor(b.NewCall(cc.getFuncByName(runtime.SqlCursorFoundFuncName), cursor), constant.True)
+	case "ISOPEN":
+		return b.NewCall(cc.getFuncByName(runtime.SqlCursorIsOpenFuncName), cursor)
+	}
+
+	log.Panicf("PLS-00208: identifier '%s' is not a legal cursor attribute", ca.Attribute)
+	return nil
+}
+
+func (ca *CursorAttribute) String() string {
+	return fmt.Sprintf("<cursor attribute> %s%%%s", ca.Cursor, ca.Attribute)
+}
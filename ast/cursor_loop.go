@@ -0,0 +1,137 @@
+/*
+ * Copyright 2019 Marco Helmich
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ast
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/value"
+	"github.com/mhelmich/plsqlc/runtime"
+)
+
+var forLoopCounter int64
+
+// NewCursorForLoop creates a loop over the rows of an explicit cursor ('FOR rec IN c(42) LOOP')
+func NewCursorForLoop(record string, open *OpenCursor) *CursorForLoop {
+	id := strconv.FormatInt(forLoopCounter, 10)
+	forLoopCounter++
+	return &CursorForLoop{
+		Record: record,
+		Open:   open,
+		Fetch:  NewFetchCursor(open.Cursor),
+		id:     id,
+	}
+}
+
+// NewQueryForLoop creates a loop over the rows of a query ('FOR rec IN (SELECT ...) LOOP')
+// the query is run by a cursor that has no name in the program
+func NewQueryForLoop(record string, query *SqlStatement) *CursorForLoop {
+	l := NewCursorForLoop(record, NewOpenCursor(""))
+	name := "FOR-CURSOR-" + l.id
+	l.Open.Cursor = name
+	l.Fetch.Cursor = name
+	l.Query = query
+	l.cursor = NewCursor(NewFunctionProto(name), query)
+	return l
+}
+
+// CursorForLoop opens a cursor and declares the record the rows are fetched into
+// the blocks of a loop are
+//
+//	<block with the loop>  opens the cursor
+//	fetch-block            fetches the next row, leaves the loop if there is none
+//	loop-block ...         the body, branches back to the fetch-block
+//	merge-block            starts with the end of the loop that closes the cursor
+//
+// the record and an implicit cursor are only visible between the loop and its end
+type CursorForLoop struct {
+	Record string
+	Open   *OpenCursor
+	Fetch  *FetchCursor
+	// the query of the loop, nil if it loops over an explicit cursor
+	Query *SqlStatement
+	// the cursor that runs the query
+	cursor *Cursor
+	// makes the names of the implicit cursor and record unique
+	id string
+	// set by the checker
+	recordType *Type
+}
+
+// Found is the condition to run the body of the loop once more
+func (l *CursorForLoop) Found() Expression {
+	return NewCursorAttribute(l.Open.Cursor, "FOUND")
+}
+
+// End creates the instruction that leaves the loop
+func (l *CursorForLoop) End() *EndCursorForLoop {
+	return &EndCursorForLoop{Loop: l}
+}
+
+func (l *CursorForLoop) GenIR(cc *CompilerContext) value.Value {
+	cc.pushScope()
+	if l.cursor != nil {
+		runtime.GenerateSQLInModule(cc.llvmModule)
+		cursorType := cc.getTypeByName(runtime.CursorTypeName)
+		state := cc.newEntryAlloca(cursorType)
+		cc.currentLlvmBlock.NewStore(constant.NewZeroInitializer(cursorType), state)
+		cc.scopes.addMember(l.cursor.Name(), state)
+	}
+
+	if _, ok := cc.recordTypes[l.recordType.Name]; !ok {
+		// the record of a query is declared by the loop
+		l.recordType.Record.GenIR(cc)
+	}
+	cc.scopes.addMember(l.Record, cc.newEntryAlloca(cc.llvmTypeFor(l.recordType.Name)))
+
+	l.Open.GenIR(cc)
+	cc.loopCursors = append(cc.loopCursors, cursorAddress(cc, l.Open.Cursor))
+	return nil
+}
+
+func (l *CursorForLoop) String() string {
+	if l.Query != nil {
+		return fmt.Sprintf("<for> %s IN (%s)", l.Record, sqlText(l.Query.Tokens))
+	}
+	return fmt.Sprintf("<for> %s IN %s", l.Record, l.Open.Cursor)
+}
+
+// EndCursorForLoop closes the cursor of a loop once all rows are fetched
+type EndCursorForLoop struct {
+	Loop *CursorForLoop
+}
+
+func (e *EndCursorForLoop) GenIR(cc *CompilerContext) value.Value {
+	cursor := cc.loopCursors[len(cc.loopCursors)-1]
+	cc.loopCursors = cc.loopCursors[:len(cc.loopCursors)-1]
+	cc.currentLlvmBlock.NewCall(cc.getFuncByName(runtime.SqlCloseFuncName), cursor)
+	cc.popScope()
+	return nil
+}
+
+func (e *EndCursorForLoop) String() string {
+	return fmt.Sprintf("<end for> %s", e.Loop.Record)
+}
+
+// closeLoopCursors closes the cursors of all loops a 'RETURN' leaves
+func closeLoopCursors(cc *CompilerContext) {
+	for idx := len(cc.loopCursors) - 1; idx >= 0; idx-- {
+		cc.currentLlvmBlock.NewCall(cc.getFuncByName(runtime.SqlCloseFuncName), cc.loopCursors[idx])
+	}
+}
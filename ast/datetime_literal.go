@@ -0,0 +1,160 @@
+/*
+ * Copyright 2019 Marco Helmich
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ast
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mhelmich/plsqlc/runtime"
+)
+
+// string literals that are assigned to dates, timestamps and intervals are converted at compile time
+// they use the formats Oracle uses by default ('DD-MON-RR', 'DD-MON-RR HH.MI.SSXFF AM', '+01 12:00:00' and '+01-06')
+var (
+	dateLiteralRegexp      = regexp.MustCompile(`^\s*(\d{1,2})\W([A-Za-z]+)\W(\d{1,4})\s*$`)
+	timestampLiteralRegexp = regexp.MustCompile(`^\s*(\d{1,2})\W([A-Za-z]+)\W(\d{1,4})(?:\s+(\d{1,2})\W(\d{1,2})\W(\d{1,2})(?:[.,](\d{1,9}))?(?:\s*([AaPp])\.?[Mm]\.?)?)?\s*$`)
+	dsLiteralRegexp        = regexp.MustCompile(`^\s*([+-]?)\s*(\d+)\s+(\d+):(\d+):(\d+(?:\.\d*)?)\s*$`)
+	ymLiteralRegexp        = regexp.MustCompile(`^\s*([+-]?)\s*(\d+)-(\d+)\s*$`)
+)
+
+// parseDatetimeLiteral returns the value of a date, timestamp or interval literal
+func parseDatetimeLiteral(t *Type, text string) (int64, error) {
+	switch t.Name {
+	case DayToSecondType.Name:
+		return parseDSIntervalLiteral(text)
+	case YearToMonthType.Name:
+		return parseYMIntervalLiteral(text)
+	}
+
+	re := dateLiteralRegexp
+	if t.Equal(TimestampType) {
+		re = timestampLiteralRegexp
+	}
+	m := re.FindStringSubmatch(text)
+	if m == nil {
+		if t.Equal(DateType) && timestampLiteralRegexp.MatchString(text) {
+			return 0, fmt.Errorf("%s", runtime.FormatEndsBeforeInputMessage)
+		}
+		return 0, fmt.Errorf("%s", runtime.NonNumericCharacterMessage)
+	}
+
+	day, _ := strconv.Atoi(m[1])
+	month := monthFromName(m[2])
+	if month == 0 {
+		return 0, fmt.Errorf("%s", runtime.NotAValidMonthMessage)
+	}
+	year, _ := strconv.Atoi(m[3])
+	if len(m[3]) <= 2 {
+		year = roundYear(year, time.Now().Year())
+	}
+	if day < 1 || day > daysIn(year, month) {
+		return 0, fmt.Errorf("%s", runtime.DayOfMonthMessage)
+	}
+
+	hour, minute, second, micros := 0, 0, 0, 0
+	if len(m) > 4 && m[4] != "" {
+		hour, _ = strconv.Atoi(m[4])
+		minute, _ = strconv.Atoi(m[5])
+		second, _ = strconv.Atoi(m[6])
+		if m[7] != "" {
+			fraction := (m[7] + "00000")[:6]
+			micros, _ = strconv.Atoi(fraction)
+		}
+		if hour < 1 || hour > 12 {
+			return 0, fmt.Errorf("%s", runtime.Hour12Message)
+		} else if minute > 59 {
+			return 0, fmt.Errorf("%s", runtime.MinutesMessage)
+		} else if second > 59 {
+			return 0, fmt.Errorf("%s", runtime.SecondsMessage)
+		}
+		hour = hour % 12
+		if strings.EqualFold(m[8], "P") {
+			hour += 12
+		}
+	}
+
+	ts := time.Date(year, time.Month(month), day, hour, minute, second, micros*1000, time.UTC)
+	return ts.Unix()*runtime.MicrosPerSecond + int64(micros), nil
+}
+
+// roundYear applies the RR rule: two-digit years are put into the century that is closest to the current year
+func roundYear(yy int, currentYear int) int {
+	century := currentYear / 100 * 100
+	current := currentYear % 100
+	if yy < 50 && current >= 50 {
+		return century + 100 + yy
+	} else if yy >= 50 && current < 50 {
+		return century - 100 + yy
+	}
+	return century + yy
+}
+
+// monthFromName returns the month (1 to 12) a full or abbreviated English name stands for or 0
+func monthFromName(name string) int {
+	for month := time.January; month <= time.December; month++ {
+		full := month.String()
+		if strings.EqualFold(name, full) || strings.EqualFold(name, full[:3]) {
+			return int(month)
+		}
+	}
+	return 0
+}
+
+func daysIn(year int, month int) int {
+	return time.Date(year, time.Month(month)+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+func parseDSIntervalLiteral(text string) (int64, error) {
+	m := dsLiteralRegexp.FindStringSubmatch(text)
+	if m == nil {
+		return 0, fmt.Errorf("%s", runtime.InvalidIntervalMessage)
+	}
+	days, err1 := strconv.ParseInt(m[2], 10, 64)
+	hours, err2 := strconv.ParseInt(m[3], 10, 64)
+	minutes, err3 := strconv.ParseInt(m[4], 10, 64)
+	seconds, err4 := strconv.ParseFloat(m[5], 64)
+	if err1 != nil || err2 != nil || err3 != nil || err4 != nil || hours > 23 || minutes > 59 || seconds >= 60 {
+		return 0, fmt.Errorf("%s", runtime.InvalidIntervalMessage)
+	}
+	v := days*runtime.MicrosPerDay + hours*runtime.MicrosPerHour + minutes*runtime.MicrosPerMinute + int64(math.Round(seconds*runtime.MicrosPerSecond))
+	if m[1] == "-" {
+		return -v, nil
+	}
+	return v, nil
+}
+
+func parseYMIntervalLiteral(text string) (int64, error) {
+	m := ymLiteralRegexp.FindStringSubmatch(text)
+	if m == nil {
+		return 0, fmt.Errorf("%s", runtime.InvalidIntervalMessage)
+	}
+	years, err1 := strconv.ParseInt(m[2], 10, 64)
+	months, err2 := strconv.ParseInt(m[3], 10, 64)
+	if err1 != nil || err2 != nil || months > 11 {
+		return 0, fmt.Errorf("%s", runtime.InvalidIntervalMessage)
+	}
+	v := years*12 + months
+	if m[1] == "-" {
+		return -v, nil
+	}
+	return v, nil
+}
@@ -0,0 +1,337 @@
+/*
+ * Copyright 2019 Marco Helmich
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ast
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/metadata"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// debugInfo is the DWARF metadata that maps the llvm ir of a program back to its PL/SQL source
+// names are lower case because debuggers look them up case sensitively
+type debugInfo struct {
+	mod     *ir.Module
+	unit    *metadata.DICompileUnit
+	files   map[string]*metadata.DIFile
+	types   map[string]metadata.Field
+	declare *ir.Func
+	// the function that is compiled and the location of the statement that is compiled
+	subprogram *metadata.DISubprogram
+	location   *metadata.DILocation
+	locations  map[int]*metadata.DILocation
+}
+
+// EnableDebugInfo makes the context describe all functions, statements and variables in DWARF metadata
+// the compile unit is named after mainFile
+func (cc *CompilerContext) EnableDebugInfo(mainFile string) {
+	d := &debugInfo{
+		mod:   cc.llvmModule,
+		files: make(map[string]*metadata.DIFile),
+		types: make(map[string]metadata.Field),
+	}
+	d.unit = &metadata.DICompileUnit{
+		Distinct:     true,
+		Language:     enum.DwarfLangC99,
+		File:         d.file(mainFile),
+		Producer:     "plsqlc",
+		EmissionKind: enum.EmissionKindFullDebug,
+	}
+	d.define(d.unit)
+	d.mod.NamedMetadataDefs["llvm.dbg.cu"] = &metadata.NamedDef{
+		Name:  "llvm.dbg.cu",
+		Nodes: []metadata.Node{d.unit},
+	}
+	// the behavior of both flags is 'warning' (2) and 'max' (7)
+	d.mod.NamedMetadataDefs["llvm.module.flags"] = &metadata.NamedDef{
+		Name: "llvm.module.flags",
+		Nodes: []metadata.Node{
+			d.tuple(constant.NewInt(types.I32, 7), &metadata.String{Value: "Dwarf Version"}, constant.NewInt(types.I32, 4)),
+			d.tuple(constant.NewInt(types.I32, 2), &metadata.String{Value: "Debug Info Version"}, constant.NewInt(types.I32, 3)),
+		},
+	}
+	d.declare = d.mod.NewFunc("llvm.dbg.declare", types.Void, ir.NewParam("addr", types.Metadata), ir.NewParam("var", types.Metadata), ir.NewParam("expr", types.Metadata))
+	cc.debug = d
+}
+
+// define adds a node to the metadata of the module, nodes are referred to by their id
+func (d *debugInfo) define(md metadata.Definition) {
+	md.SetID(-1)
+	d.mod.MetadataDefs = append(d.mod.MetadataDefs, md)
+}
+
+func (d *debugInfo) tuple(fields ...metadata.Field) *metadata.Tuple {
+	t := &metadata.Tuple{Fields: fields}
+	d.define(t)
+	return t
+}
+
+// file returns the description of a source file, files without a name belong to the compile unit
+func (d *debugInfo) file(path string) *metadata.DIFile {
+	if path == "" {
+		return d.unit.File
+	}
+	if f, ok := d.files[path]; ok {
+		return f
+	}
+
+	dir := filepath.Dir(path)
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	f := &metadata.DIFile{
+		Filename:  filepath.Base(path),
+		Directory: dir,
+	}
+	d.define(f)
+	d.files[path] = f
+	return f
+}
+
+// startDebugFunction describes a function, the instructions that are generated from now on are located in it
+func (cc *CompilerContext) startDebugFunction(f *Function, llvmFunc *ir.Func) {
+	d := cc.debug
+	if d == nil {
+		return
+	}
+
+	signature := []metadata.Field{&metadata.NullLit{}}
+	if !f.IsProcedure() {
+		signature[0] = d.typeFor(cc, f.Proto.ReturnType)
+	}
+	for idx := range f.Proto.Params {
+		signature = append(signature, d.typeFor(cc, f.Proto.Params[idx].Type))
+	}
+	for idx := range signature {
+		if signature[idx] == nil {
+			signature[idx] = &metadata.NullLit{}
+		}
+	}
+	subroutine := &metadata.DISubroutineType{Types: d.tuple(signature...)}
+	d.define(subroutine)
+
+	d.subprogram = &metadata.DISubprogram{
+		Distinct:    true,
+		Scope:       d.file(f.File),
+		Name:        strings.ToLower(llvmFunc.Name()),
+		LinkageName: llvmFunc.Name(),
+		File:        d.file(f.File),
+		Line:        int64(f.Line),
+		Type:        subroutine,
+		ScopeLine:   int64(f.Line),
+		SPFlags:     enum.DISPFlagDefinition,
+		Unit:        d.unit,
+	}
+	d.define(d.subprogram)
+	llvmFunc.Metadata = append(llvmFunc.Metadata, &metadata.Attachment{Name: "dbg", Node: d.subprogram})
+	d.locations = make(map[int]*metadata.DILocation)
+	d.location = d.locationFor(f.Line)
+}
+
+// endDebugFunction stops locating instructions in the function that was compiled last
+func (cc *CompilerContext) endDebugFunction() {
+	if cc.debug != nil {
+		cc.debug.subprogram = nil
+		cc.debug.location = nil
+	}
+}
+
+func (d *debugInfo) locationFor(line int) *metadata.DILocation {
+	if loc, ok := d.locations[line]; ok {
+		return loc
+	}
+	loc := &metadata.DILocation{
+		Line:  int64(line),
+		Scope: d.subprogram,
+	}
+	d.define(loc)
+	d.locations[line] = loc
+	return loc
+}
+
+// setDebugLine locates the instructions that are generated from now on on a line of the source
+// instructions of statements without a line stay on the line of the statement before
+func (cc *CompilerContext) setDebugLine(line int) {
+	if cc.debug == nil || cc.debug.subprogram == nil || line <= 0 {
+		return
+	}
+	cc.debug.location = cc.debug.locationFor(line)
+}
+
+// attachDebugLocations locates all instructions of the current function that aren't located yet
+func (cc *CompilerContext) attachDebugLocations() {
+	if cc.debug == nil || cc.debug.location == nil {
+		return
+	}
+	for _, blk := range cc.currentLlvmFunc.Blocks {
+		for _, inst := range blk.Insts {
+			attachDebugLocation(inst, cc.debug.location)
+		}
+		if blk.Term != nil {
+			attachDebugLocation(blk.Term, cc.debug.location)
+		}
+	}
+}
+
+// attachDebugLocation adds a '!dbg' attachment unless there is one already
+// all instructions embed ir.Metadata but there is no interface to change it
+func attachDebugLocation(inst interface{}, loc *metadata.DILocation) {
+	field := reflect.ValueOf(inst).Elem().FieldByName("Metadata")
+	if !field.IsValid() {
+		return
+	}
+	mds := field.Interface().(ir.Metadata)
+	for idx := range mds {
+		if mds[idx].Name == "dbg" {
+			return
+		}
+	}
+	field.Set(reflect.ValueOf(append(mds, &metadata.Attachment{Name: "dbg", Node: loc})))
+}
+
+// declareDebugVariable describes a variable whose value is stored at addr
+// argNo is the position of a parameter starting at 1 and 0 for local variables
+func (cc *CompilerContext) declareDebugVariable(name string, typ string, addr value.Value, argNo int, line int) {
+	d := cc.debug
+	if d == nil {
+		return
+	}
+	t := d.typeFor(cc, typ)
+	if t == nil {
+		return
+	}
+
+	v := &metadata.DILocalVariable{
+		Name:  strings.ToLower(name),
+		Arg:   uint64(argNo),
+		Scope: d.subprogram,
+		File:  d.subprogram.File,
+		Line:  int64(line),
+		Type:  t,
+	}
+	d.define(v)
+	cc.currentLlvmBlock.NewCall(d.declare, &metadata.Value{Value: addr}, &metadata.Value{Value: v}, &metadata.Value{Value: &metadata.DIExpression{MetadataID: -1}})
+}
+
+// typeFor describes a PL/SQL type, it returns nil for types that can't be described
+func (d *debugInfo) typeFor(cc *CompilerContext, typ string) metadata.Field {
+	n := cc.baseTypeName(typ)
+	rt, isRecord := cc.records[n]
+	if builtin, ok := builtinType(n); ok && !isRecord {
+		// constrained types are described by their base type
+		n = builtin.Name
+	}
+	if t, ok := d.types[n]; ok {
+		return t
+	}
+
+	var t metadata.Field
+	if isRecord {
+		t = d.recordType(cc, n, rt)
+	} else if builtin, ok := builtinType(n); ok {
+		t = d.builtinType(cc, builtin)
+	}
+	if t != nil {
+		d.types[n] = t
+	}
+	return t
+}
+
+func (d *debugInfo) builtinType(cc *CompilerContext, t *Type) metadata.Field {
+	switch {
+	case t.isInt64():
+		return d.basicType(strings.ToLower(t.Name), enum.DwarfAttEncodingSigned)
+	case t.isDouble():
+		return d.basicType(strings.ToLower(t.Name), enum.DwarfAttEncodingFloat)
+	case t.IsString():
+		// strings are a pointer to their characters and their length
+		char := &metadata.DIBasicType{Tag: enum.DwarfTagBaseType, Name: "char", Size: 8, Encoding: enum.DwarfAttEncodingSignedChar}
+		d.define(char)
+		data := &metadata.DIDerivedType{Tag: enum.DwarfTagPointerType, BaseType: char, Size: 64}
+		d.define(data)
+		length := d.typeFor(cc, IntType.Name)
+		return d.structType(strings.ToLower(t.Name), []string{"data", "length"}, []metadata.Field{data, length})
+	case t.Equal(RefCursorType):
+		cursor := &metadata.DIDerivedType{Tag: enum.DwarfTagPointerType, Name: "sys_refcursor", BaseType: &metadata.NullLit{}, Size: 64}
+		d.define(cursor)
+		return cursor
+	}
+	return nil
+}
+
+func (d *debugInfo) basicType(name string, encoding enum.DwarfAttEncoding) *metadata.DIBasicType {
+	t := &metadata.DIBasicType{Tag: enum.DwarfTagBaseType, Name: name, Size: 64, Encoding: encoding}
+	d.define(t)
+	return t
+}
+
+// recordType describes the fields of a record
+// the types of the fields are resolved in the package the record is declared in
+func (d *debugInfo) recordType(cc *CompilerContext, name string, rt *RecordType) metadata.Field {
+	currentPackageName := cc.currentPackageName
+	cc.currentPackageName = (&Type{Name: name}).packageName()
+	defer func() {
+		cc.currentPackageName = currentPackageName
+	}()
+
+	names := make([]string, len(rt.Fields))
+	fields := make([]metadata.Field, len(rt.Fields))
+	for idx := range rt.Fields {
+		names[idx] = strings.ToLower(rt.Fields[idx].Name)
+		if fields[idx] = d.typeFor(cc, rt.Fields[idx].Type); fields[idx] == nil {
+			return nil
+		}
+	}
+	return d.structType(strings.ToLower(name), names, fields)
+}
+
+// structType describes a struct of members that are all 8 byte aligned
+func (d *debugInfo) structType(name string, names []string, fields []metadata.Field) *metadata.DICompositeType {
+	var offset uint64
+	members := make([]metadata.Field, len(fields))
+	for idx := range fields {
+		size := debugTypeSize(fields[idx])
+		member := &metadata.DIDerivedType{Tag: enum.DwarfTagMember, Name: names[idx], BaseType: fields[idx], Size: size, Offset: offset}
+		d.define(member)
+		members[idx] = member
+		offset += size
+	}
+
+	t := &metadata.DICompositeType{Tag: enum.DwarfTagStructureType, Name: name, Size: offset, Elements: d.tuple(members...)}
+	d.define(t)
+	return t
+}
+
+// debugTypeSize returns the size of a described type in bits
+func debugTypeSize(t metadata.Field) uint64 {
+	switch x := t.(type) {
+	case *metadata.DIBasicType:
+		return x.Size
+	case *metadata.DIDerivedType:
+		return x.Size
+	case *metadata.DICompositeType:
+		return x.Size
+	}
+	return 0
+}
@@ -0,0 +1,369 @@
+/*
+ * Copyright 2019 Marco Helmich
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ast
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// walkFunction calls visit for every expression and instruction in the body of f
+func walkFunction(f *Function, visit func(node Node)) {
+	for idx := range f.Cursors {
+		walkNode(f.Cursors[idx].Query, visit)
+	}
+	for idx := range f.Blocks {
+		for _, i := range f.Blocks[idx].Instructions {
+			walkNode(i, visit)
+		}
+		if f.Blocks[idx].Terminator != nil {
+			walkNode(f.Blocks[idx].Terminator, visit)
+		}
+	}
+}
+
+func walkNode(n Node, visit func(node Node)) {
+	if n == nil {
+		return
+	}
+
+	visit(n)
+	switch x := n.(type) {
+	case *FunctionCall:
+		for idx := range x.Args {
+			walkNode(x.Args[idx], visit)
+		}
+	case *BinOp:
+		walkNode(x.Left, visit)
+		walkNode(x.Right, visit)
+	case *Assignment:
+		if x.Target != nil {
+			walkNode(x.Target, visit)
+		} else {
+			walkNode(x.Element, visit)
+		}
+		walkNode(x.Expr, visit)
+	case *CollectionElement:
+		walkNode(x.Collection, visit)
+		walkNode(x.Index, visit)
+	case *Retrn:
+		if x.expr != nil {
+			walkNode(x.expr, visit)
+		}
+	case *ConditionalBranch:
+		walkNode(x.Condition, visit)
+	case *OpenCursor:
+		for idx := range x.Args {
+			walkNode(x.Args[idx], visit)
+		}
+	case *FetchCursor:
+		for idx := range x.Into {
+			walkNode(x.Into[idx], visit)
+		}
+		if x.Limit != nil {
+			walkNode(x.Limit, visit)
+		}
+	case *ExecuteImmediate:
+		walkNode(x.Statement, visit)
+		for idx := range x.Into {
+			walkNode(x.Into[idx], visit)
+		}
+		for idx := range x.Using {
+			walkNode(x.Using[idx], visit)
+		}
+	case *OpenCursorFor:
+		walkNode(x.Statement, visit)
+		for idx := range x.Using {
+			walkNode(x.Using[idx], visit)
+		}
+	case *ForAll:
+		walkNode(x.Lower, visit)
+		walkNode(x.Upper, visit)
+		walkNode(x.Statement, visit)
+	case *CursorAttribute:
+		walkNode(x.Index, visit)
+	case *CursorForLoop:
+		walkNode(x.Open, visit)
+		if x.Query != nil {
+			walkNode(x.Query, visit)
+		}
+	case *SqlStatement:
+		for idx := range x.Into {
+			walkNode(x.Into[idx], visit)
+		}
+		// the variables of packages a statement binds aren't known before it is checked
+		// every qualified name might be one ('pkg.var')
+		for idx := 0; idx+2 < len(x.Tokens); idx++ {
+			if x.Tokens[idx].IsIdentifier && x.Tokens[idx+1].Text == "." && x.Tokens[idx+2].IsIdentifier {
+				walkNode(NewQualifiedVariable(x.Tokens[idx].Text, x.Tokens[idx+2].Text), visit)
+			}
+		}
+	}
+}
+
+// referencedPackage returns the name of the package a node refers to
+// or an empty string if it doesn't refer to another package
+func referencedPackage(pkgs map[string]*Package, pkgName string, n Node) string {
+	var name string
+	switch x := n.(type) {
+	case *FunctionCall:
+		name = x.ModuleName
+	case *Variable:
+		name = x.Qualifier
+	}
+
+	if _, ok := pkgs[name]; !ok || name == pkgName {
+		return ""
+	}
+	return name
+}
+
+// typePackage returns the package name of a qualified type name ('pkg.type')
+func typePackage(pkgs map[string]*Package, typeName string) string {
+	idx := strings.Index(typeName, ".")
+	if idx < 0 {
+		return ""
+	}
+
+	if _, ok := pkgs[typeName[:idx]]; !ok {
+		return ""
+	}
+	return typeName[:idx]
+}
+
+// dependencies returns the names of all packages p uses
+// in its declarations, prototypes or code
+func (p *Package) dependencies(pkgs map[string]*Package) []string {
+	deps := make(map[string]bool)
+	addType := func(typeName string) {
+		if n := typePackage(pkgs, typeName); n != "" && n != p.Name {
+			deps[n] = true
+		}
+	}
+
+	typeDecls := append([]TypeDeclaration{}, p.types...)
+	variables := append([]*PackageVariable{}, p.variables...)
+	protos := make([]*FunctionProto, 0)
+	if p.Spec != nil {
+		typeDecls = append(typeDecls, p.Spec.Types...)
+		variables = append(variables, p.Spec.Variables...)
+		protos = append(protos, p.Spec.Protos...)
+	}
+
+	for idx := range typeDecls {
+		switch td := typeDecls[idx].(type) {
+		case *Subtype:
+			addType(td.BaseType)
+		case *RecordType:
+			for _, field := range td.Fields {
+				addType(field.Type)
+			}
+		case *CollectionType:
+			addType(td.ElementType)
+		case *RefCursor:
+			addType(td.ReturnType)
+		}
+	}
+
+	for idx := range variables {
+		addType(variables[idx].Typ)
+	}
+
+	functions := append([]*Function{}, p.functions...)
+	if p.initFunction != nil {
+		functions = append(functions, p.initFunction)
+	}
+
+	for _, f := range functions {
+		protos = append(protos, f.Proto)
+		for _, cursor := range f.Cursors {
+			protos = append(protos, cursor.Proto)
+		}
+		for _, local := range f.Locals {
+			addType(local.Typ)
+		}
+		walkFunction(f, func(n Node) {
+			if n := referencedPackage(pkgs, p.Name, n); n != "" {
+				deps[n] = true
+			}
+		})
+	}
+
+	for _, fp := range protos {
+		addType(fp.ReturnType)
+		for _, param := range fp.Params {
+			addType(param.Type)
+		}
+	}
+
+	return sortedKeys(deps)
+}
+
+// initDependencies returns the names of all packages whose state can be
+// touched while the initialization section of p runs
+// it follows calls into other functions, no matter which package they live in
+func (p *Package) initDependencies(pkgs map[string]*Package) []string {
+	deps := make(map[string]bool)
+	if p.initFunction == nil {
+		return nil
+	}
+
+	type pendingFunction struct {
+		pkg *Package
+		f   *Function
+	}
+
+	visited := make(map[*Function]bool)
+	pending := []pendingFunction{{p, p.initFunction}}
+	for len(pending) > 0 {
+		current := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if visited[current.f] {
+			continue
+		}
+		visited[current.f] = true
+
+		walkFunction(current.f, func(n Node) {
+			if n := referencedPackage(pkgs, current.pkg.Name, n); n != "" {
+				deps[n] = true
+			}
+
+			fc, ok := n.(*FunctionCall)
+			if !ok {
+				return
+			}
+
+			calleePkg := current.pkg
+			if fc.ModuleName != "" {
+				calleePkg = pkgs[fc.ModuleName]
+			}
+			if calleePkg == nil {
+				return
+			}
+
+			if callee := calleePkg.findFunction(fc.FunctionName); callee != nil {
+				pending = append(pending, pendingFunction{calleePkg, callee})
+			}
+		})
+	}
+
+	delete(deps, p.Name)
+	return sortedKeys(deps)
+}
+
+// SortPackages orders packages so that every package comes after the packages it depends on
+// cycles are broken by the name of the package, declarations don't care about them
+func SortPackages(pkgs map[string]*Package) []*Package {
+	sorted := make([]*Package, 0, len(pkgs))
+	visited := make(map[string]bool)
+
+	var visit func(name string)
+	visit = func(name string) {
+		if visited[name] {
+			return
+		}
+		visited[name] = true
+
+		pkg := pkgs[name]
+		for _, dep := range pkg.dependencies(pkgs) {
+			visit(dep)
+		}
+		sorted = append(sorted, pkg)
+	}
+
+	for _, name := range sortedPackageNames(pkgs) {
+		visit(name)
+	}
+	return sorted
+}
+
+// InitOrder returns the packages that have an initialization section
+// in the order in which the sections need to run
+// a package is initialized after all packages its initialization section uses
+// a cycle between initialization sections is an error
+func InitOrder(pkgs map[string]*Package) ([]*Package, error) {
+	const (
+		unvisited = iota
+		inProgress
+		done
+	)
+
+	order := make([]*Package, 0)
+	state := make(map[string]int)
+	path := make([]string, 0)
+
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case done:
+			return nil
+		case inProgress:
+			idx := 0
+			for path[idx] != name {
+				idx++
+			}
+			cycle := append(path[idx:], name)
+			return fmt.Errorf("Cyclic dependency between package initialization sections: %s", strings.Join(cycle, " -> "))
+		}
+
+		state[name] = inProgress
+		path = append(path, name)
+		pkg := pkgs[name]
+		for _, dep := range pkg.initDependencies(pkgs) {
+			// packages without initialization section can't take part in a cycle that matters
+			if pkgs[dep].initFunction == nil {
+				continue
+			}
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = done
+		order = append(order, pkg)
+		return nil
+	}
+
+	for _, name := range sortedPackageNames(pkgs) {
+		if pkgs[name].initFunction == nil {
+			continue
+		}
+		if err := visit(name); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+func sortedPackageNames(pkgs map[string]*Package) []string {
+	names := make([]string, 0, len(pkgs))
+	for name := range pkgs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,97 @@
+/*
+ * Copyright 2019 Marco Helmich
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ast
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestPackage creates a package with one procedure 'PROC' that calls procCallee
+// and (if initCallee isn't empty) an initialization section that calls initCallee
+func newTestPackage(name string, procCallee string, initCallee string) *Package {
+	pkg := NewPackage(name)
+	f := NewFunction("PROC", true)
+	blk := NewBlock("entry")
+	if procCallee != "" {
+		blk.AddInstruction(NewFunctionCall(procCallee, "PROC"))
+	}
+	f.AddBlock(blk)
+	pkg.AddFunction(f)
+
+	if initCallee != "" {
+		initFunc := pkg.NewInitFunction()
+		initBlk := NewBlock("init")
+		initBlk.AddInstruction(NewFunctionCall(initCallee, "PROC"))
+		initFunc.AddBlock(initBlk)
+	}
+	return pkg
+}
+
+func TestSortPackages(t *testing.T) {
+	pkgs := map[string]*Package{
+		"A": newTestPackage("A", "B", ""),
+		"B": newTestPackage("B", "C", ""),
+		"C": newTestPackage("C", "", ""),
+	}
+
+	sorted := SortPackages(pkgs)
+	assert.Equal(t, 3, len(sorted))
+	assert.Equal(t, "C", sorted[0].Name)
+	assert.Equal(t, "B", sorted[1].Name)
+	assert.Equal(t, "A", sorted[2].Name)
+}
+
+func TestInitOrder(t *testing.T) {
+	// A's initialization calls B.PROC which reads state of C
+	pkgs := map[string]*Package{
+		"A": newTestPackage("A", "", "B"),
+		"B": newTestPackage("B", "C", ""),
+		"C": newTestPackage("C", "", "D"),
+		"D": newTestPackage("D", "", ""),
+	}
+
+	order, err := InitOrder(pkgs)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(order))
+	assert.Equal(t, "C", order[0].Name)
+	assert.Equal(t, "A", order[1].Name)
+}
+
+func TestInitOrderCycle(t *testing.T) {
+	// the initialization sections of A and C depend on each other through B
+	pkgs := map[string]*Package{
+		"A": newTestPackage("A", "", "B"),
+		"B": newTestPackage("B", "C", ""),
+		"C": newTestPackage("C", "", "A"),
+	}
+
+	_, err := InitOrder(pkgs)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "A -> C -> A")
+
+	// cycles between packages without initialization sections don't matter
+	pkgs = map[string]*Package{
+		"A": newTestPackage("A", "B", ""),
+		"B": newTestPackage("B", "A", ""),
+	}
+	order, err := InitOrder(pkgs)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(order))
+	assert.Equal(t, 2, len(SortPackages(pkgs)))
+}
@@ -0,0 +1,158 @@
+/*
+ * Copyright 2019 Marco Helmich
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ast
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+	"github.com/mhelmich/plsqlc/runtime"
+)
+
+// NewExecuteImmediate creates a statement whose text is built at runtime ('EXECUTE IMMEDIATE text INTO a USING b')
+func NewExecuteImmediate(statement Expression) *ExecuteImmediate {
+	return &ExecuteImmediate{
+		Statement: statement,
+		Into:      make([]*Variable, 0),
+		Using:     make([]Expression, 0),
+	}
+}
+
+// ExecuteImmediate runs the text of a statement as it is, placeholders (':1', ':name') are bound to the USING arguments
+// SQLite numbers the placeholders, a name that appears more than once is bound once
+type ExecuteImmediate struct {
+	Statement Expression
+	// the variables a query fetches its row into
+	Into  []*Variable
+	Using []Expression
+	// set by the checker
+	intoValues []Expression
+	columns    []*sqlColumn
+}
+
+func (ei *ExecuteImmediate) AddInto(v *Variable) {
+	ei.Into = append(ei.Into, v)
+}
+
+func (ei *ExecuteImmediate) AddUsing(expr Expression) {
+	ei.Using = append(ei.Using, expr)
+}
+
+func (ei *ExecuteImmediate) GenIR(cc *CompilerContext) value.Value {
+	stmt := genIRForDynamicSql(cc, ei.Statement, ei.Using)
+
+	var rowcount value.Value
+	if len(ei.Into) == 0 {
+		rowcount = cc.currentLlvmBlock.NewCall(cc.getFuncByName(runtime.SqlExecuteFuncName), stmt)
+	} else {
+		// a query that is selected into variables returns exactly one row
+		cc.currentLlvmBlock.NewCall(cc.getFuncByName(runtime.SqlFetchOneFuncName), stmt, constant.NewInt(types.I64, int64(len(ei.columns))))
+		genIRForInto(cc, stmt, ei.Into, ei.columns, ei.intoValues)
+		cc.currentLlvmBlock.NewCall(cc.getFuncByName(runtime.SqlFetchDoneFuncName), stmt)
+		rowcount = constant.NewInt(types.I64, 1)
+	}
+
+	cc.currentLlvmBlock.NewStore(rowcount, cc.getGlobalByName(runtime.SqlRowcountName))
+	return nil
+}
+
+func (ei *ExecuteImmediate) String() string {
+	return fmt.Sprintf("<execute immediate> %s%s", ei.Statement.String(), usingString(ei.Using))
+}
+
+func NewOpenCursorFor(cursor string, statement Expression) *OpenCursorFor {
+	return &OpenCursorFor{
+		Cursor:    cursor,
+		Statement: statement,
+		Using:     make([]Expression, 0),
+	}
+}
+
+// NewOpenCursorForQuery opens a cursor variable for a query that is known at compile time ('OPEN rc FOR SELECT ...')
+func NewOpenCursorForQuery(cursor string, query *SqlStatement) *OpenCursorFor {
+	return &OpenCursorFor{
+		Cursor: cursor,
+		Query:  query,
+		Using:  make([]Expression, 0),
+	}
+}
+
+// OpenCursorFor opens a cursor variable for a query whose text is built at runtime ('OPEN rc FOR text USING a')
+// or for a query that is part of the program, its variables are bound like the ones of an explicit cursor
+type OpenCursorFor struct {
+	Cursor    string
+	Statement Expression
+	Using     []Expression
+	// the query of the cursor, nil if its text is built at runtime
+	Query *SqlStatement
+}
+
+func (o *OpenCursorFor) AddUsing(expr Expression) {
+	o.Using = append(o.Using, expr)
+}
+
+func (o *OpenCursorFor) GenIR(cc *CompilerContext) value.Value {
+	var stmt value.Value
+	if o.Query != nil {
+		runtime.GenerateSQLInModule(cc.llvmModule)
+		stmt = o.Query.queries[0].genIR(cc)
+	} else {
+		stmt = genIRForDynamicSql(cc, o.Statement, o.Using)
+	}
+	slot, ok := cc.findVariable(o.Cursor)
+	if !ok {
+		log.Panicf("Can't find cursor variable '%s' in scope", o.Cursor)
+	}
+	cc.currentLlvmBlock.NewCall(cc.getFuncByName(runtime.SqlOpenRefFuncName), slot, stmt)
+	return nil
+}
+
+func (o *OpenCursorFor) String() string {
+	if o.Query != nil {
+		return fmt.Sprintf("<open> %s FOR %s", o.Cursor, sqlText(o.Query.Tokens))
+	}
+	return fmt.Sprintf("<open> %s FOR %s%s", o.Cursor, o.Statement.String(), usingString(o.Using))
+}
+
+// genIRForDynamicSql prepares the text of a statement and binds its arguments
+func genIRForDynamicSql(cc *CompilerContext, statement Expression, using []Expression) value.Value {
+	runtime.GenerateSQLInModule(cc.llvmModule)
+	text := statement.GenIR(cc)
+	if statement.expressionType() == stringExpression {
+		text = cc.currentLlvmBlock.NewLoad(text)
+	}
+
+	stmt := cc.currentLlvmBlock.NewCall(cc.getFuncByName(runtime.SqlPrepareFuncName), text)
+	cc.currentLlvmBlock.NewCall(cc.getFuncByName(runtime.SqlCheckBindsFuncName), stmt, constant.NewInt(types.I64, int64(len(using))))
+	genIRForBinds(cc, stmt, using)
+	return stmt
+}
+
+func usingString(using []Expression) string {
+	if len(using) == 0 {
+		return ""
+	}
+	args := make([]string, len(using))
+	for idx := range using {
+		args[idx] = using[idx].String()
+	}
+	return " USING " + strings.Join(args, ", ")
+}
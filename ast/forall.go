@@ -0,0 +1,114 @@
+/*
+ * Copyright 2019 Marco Helmich
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ast
+
+import (
+	"fmt"
+
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+	"github.com/mhelmich/plsqlc/runtime"
+)
+
+// NewForAll creates a DML statement that runs once for every index of a range ('FORALL i IN 1..10 INSERT ...')
+func NewForAll(index string, lower Expression, upper Expression, statement *SqlStatement) *ForAll {
+	return &ForAll{
+		Index:     index,
+		Lower:     lower,
+		Upper:     upper,
+		Statement: statement,
+	}
+}
+
+// ForAll prepares its statement once and binds it anew for every index
+// the index is bound like any other variable, so are the elements of collections it subscripts ('list(i)')
+// SQL%ROWCOUNT is the sum of the rows of all runs, SQL%BULK_ROWCOUNT(i) the rows of the run for index i
+// with SAVE EXCEPTIONS the runs that fail are skipped, there are no handlers to catch the error
+// that is raised for them once all runs are done
+type ForAll struct {
+	Index          string
+	Lower          Expression
+	Upper          Expression
+	Statement      *SqlStatement
+	SaveExceptions bool
+}
+
+func (fa *ForAll) GenIR(cc *CompilerContext) value.Value {
+	runtime.GenerateSQLInModule(cc.llvmModule)
+	lower := fa.Lower.GenIR(cc)
+	upper := fa.Upper.GenIR(cc)
+	cc.currentLlvmBlock.NewCall(cc.getFuncByName(runtime.SqlBulkStartFuncName), lower, upper)
+
+	stmts := make([]value.Value, len(fa.Statement.queries))
+	for idx, q := range fa.Statement.queries {
+		text := runtime.NewConstantString(cc.llvmModule, fmt.Sprintf("_sql.%d", len(cc.llvmModule.Globals)), q.text)
+		stmts[idx] = cc.currentLlvmBlock.NewCall(cc.getFuncByName(runtime.SqlPrepareFuncName), text)
+	}
+
+	index := cc.newEntryAlloca(types.I64)
+	total := cc.newEntryAlloca(types.I64)
+	cc.currentLlvmBlock.NewStore(lower, index)
+	cc.currentLlvmBlock.NewStore(constant.NewInt(types.I64, 0), total)
+	condBlock := cc.currentLlvmFunc.NewBlock("")
+	bodyBlock := cc.currentLlvmFunc.NewBlock("")
+	doneBlock := cc.currentLlvmFunc.NewBlock("")
+	cc.currentLlvmBlock.NewBr(condBlock)
+
+	i := condBlock.NewLoad(index)
+	condBlock.NewCondBr(condBlock.NewICmp(enum.IPredSLE, i, upper), bodyBlock, doneBlock)
+
+	cc.currentLlvmBlock = bodyBlock
+	cc.pushScope()
+	cc.scopes.addMember(fa.Index, index)
+	var rows value.Value = constant.NewInt(types.I64, 0)
+	for idx, q := range fa.Statement.queries {
+		genIRForBinds(cc, stmts[idx], q.binds)
+		var n value.Value
+		if fa.SaveExceptions {
+			n = cc.currentLlvmBlock.NewCall(cc.getFuncByName(runtime.SqlRunSavingFuncName), stmts[idx], i)
+		} else {
+			n = cc.currentLlvmBlock.NewCall(cc.getFuncByName(runtime.SqlRunFuncName), stmts[idx])
+		}
+		rows = cc.currentLlvmBlock.NewAdd(rows, n)
+	}
+	cc.popScope()
+
+	b := cc.currentLlvmBlock
+	b.NewCall(cc.getFuncByName(runtime.SqlBulkSetFuncName), i, rows)
+	b.NewStore(b.NewAdd(b.NewLoad(total), rows), total)
+	b.NewStore(b.NewAdd(i, constant.NewInt(types.I64, 1)), index)
+	b.NewBr(condBlock)
+
+	cc.currentLlvmBlock = doneBlock
+	for idx := range stmts {
+		doneBlock.NewCall(cc.getFuncByName(runtime.SqlFinalizeFuncName), stmts[idx])
+	}
+	doneBlock.NewStore(doneBlock.NewLoad(total), cc.getGlobalByName(runtime.SqlRowcountName))
+	if fa.SaveExceptions {
+		doneBlock.NewCall(cc.getFuncByName(runtime.SqlRaiseSavedFuncName))
+	}
+	return nil
+}
+
+func (fa *ForAll) String() string {
+	if fa.SaveExceptions {
+		return fmt.Sprintf("<forall> %s IN %s..%s SAVE EXCEPTIONS %s", fa.Index, fa.Lower.String(), fa.Upper.String(), sqlText(fa.Statement.Tokens))
+	}
+	return fmt.Sprintf("<forall> %s IN %s..%s %s", fa.Index, fa.Lower.String(), fa.Upper.String(), sqlText(fa.Statement.Tokens))
+}
@@ -33,29 +33,46 @@ func NewFunction(fn string, isProcedure bool) *Function {
 	return &Function{
 		Proto:       NewFunctionProto(fn),
 		Locals:      make([]*FunctionLocal, 0),
+		Cursors:     make([]*Cursor, 0),
 		Blocks:      make([]*Block, 0),
 		isProcedure: isProcedure,
 	}
 }
 
 type Function struct {
-	Proto       *FunctionProto
-	Locals      []*FunctionLocal
-	Blocks      []*Block
+	Proto   *FunctionProto
+	Locals  []*FunctionLocal
+	Cursors []*Cursor
+	Blocks  []*Block
+	// 'PRAGMA AUTONOMOUS_TRANSACTION', the function runs in a transaction of its own
+	Autonomous bool
+	// source position of the definition, it ends up in the debug info and diagnostics
+	File        string
+	Line        int
+	Column      int
 	isProcedure bool
 }
 
+func (f *Function) IsProcedure() bool {
+	return f.isProcedure
+}
+
 func (f *Function) AddParam(name string, ownership string, t string) {
 	f.Proto.AddParam(name, ownership, t)
 }
 
-func (f *Function) AddLocal(name string, typ string, value string) {
+func (f *Function) AddLocal(name string, typ string, value string) *FunctionLocal {
 	fl := &FunctionLocal{
 		Name:  name,
 		Typ:   typ,
 		Value: value,
 	}
 	f.Locals = append(f.Locals, fl)
+	return fl
+}
+
+func (f *Function) AddCursor(c *Cursor) {
+	f.Cursors = append(f.Cursors, c)
 }
 
 func (f *Function) AddBlock(b *Block) {
@@ -69,48 +86,78 @@ func (f *Function) GenIRForProtos(cc *CompilerContext) value.Value {
 func (f *Function) GenIR(cc *CompilerContext) value.Value {
 	llvmFunc := cc.getFuncByName(cc.currentPackageName + "." + f.Proto.Name)
 	cc.currentLlvmFunc = llvmFunc
+	cc.startDebugFunction(f, llvmFunc)
 	cc.pushScope()
 	defer cc.popScope()
 
-	if len(f.Locals) > 0 {
-		localsBlock := cc.currentLlvmFunc.NewBlock("locals")
+	var localsBlock *ir.Block
+	if len(f.Locals) > 0 || len(f.Proto.Params) > 0 || len(f.Cursors) > 0 || f.Autonomous {
+		localsBlock = cc.currentLlvmFunc.NewBlock("locals")
 		cc.currentLlvmBlock = localsBlock
-		// locals have their own block
-		for idx := range f.Locals {
-			f.Locals[idx].GenIR(cc)
+		if f.Autonomous {
+			runtime.GenerateSQLInModule(cc.llvmModule)
+			cc.autonomousCaller = localsBlock.NewCall(cc.getFuncByName(runtime.SqlBeginAutonomousFuncName))
+		}
+		// params and locals have their own block
+		for idx, param := range f.Proto.Params {
+			addr := genIRForParam(cc, param, llvmFunc.Params[idx])
+			cc.declareDebugVariable(param.Name, param.Type, addr, idx+1, f.Line)
 		}
 
-		// create all llvm blocks ahead of time
-		cc.functionBlocks = make(map[*Block]*ir.Block)
-		for idx := range f.Blocks {
-			cc.functionBlocks[f.Blocks[idx]] = cc.currentLlvmFunc.NewBlock(f.Blocks[idx].Name)
+		for _, local := range f.Locals {
+			addr := local.GenIR(cc)
+			cc.declareDebugVariable(local.Name, local.Typ, addr, 0, local.Line)
 		}
 
-		// generate llvm ir for all blocks
-		for idx := range f.Blocks {
-			f.Blocks[idx].GenIR(cc)
+		for idx := range f.Cursors {
+			f.Cursors[idx].GenIR(cc)
 		}
+		cc.attachDebugLocations()
+	}
+
+	// create all llvm blocks ahead of time
+	cc.functionBlocks = make(map[*Block]*ir.Block)
+	for idx := range f.Blocks {
+		cc.functionBlocks[f.Blocks[idx]] = cc.currentLlvmFunc.NewBlock(f.Blocks[idx].Name)
+	}
 
+	// generate llvm ir for all blocks
+	for idx := range f.Blocks {
+		f.Blocks[idx].GenIR(cc)
+	}
+
+	if localsBlock != nil {
 		// link locals block to method entry block
 		// that is the first block in the list
 		localsBlock.NewBr(cc.functionBlocks[f.Blocks[0]])
-	} else {
-		cc.functionBlocks = make(map[*Block]*ir.Block)
-		for idx := range f.Blocks {
-			cc.functionBlocks[f.Blocks[idx]] = cc.currentLlvmFunc.NewBlock(f.Blocks[idx].Name)
-		}
-
-		for idx := range f.Blocks {
-			f.Blocks[idx].GenIR(cc)
-		}
+		cc.setDebugLine(f.Line)
+		cc.attachDebugLocations()
 	}
+	cc.endDebugFunction()
 
 	cc.currentLlvmBlock = nil
 	cc.currentLlvmFunc = nil
 	cc.functionBlocks = nil
+	cc.loopCursors = nil
+	cc.autonomousCaller = nil
 	return llvmFunc
 }
 
+// genIRForParam makes a parameter addressable by name inside the function body
+// params passed by reference already are pointers and can be used as they are
+// params passed by value are copied into a stack slot
+func genIRForParam(cc *CompilerContext, fp *FunctionParam, param *ir.Param) value.Value {
+	if fp.isByReference() {
+		cc.scopes.addMember(fp.Name, param)
+		return param
+	}
+
+	alloca := cc.currentLlvmBlock.NewAlloca(param.Type())
+	cc.currentLlvmBlock.NewStore(param, alloca)
+	cc.scopes.addMember(fp.Name, alloca)
+	return alloca
+}
+
 func (f *Function) String() string {
 	var sb strings.Builder
 	sb.WriteString("<func definition> ")
@@ -138,30 +185,48 @@ type FunctionLocal struct {
 	Name  string
 	Typ   string
 	Value string
+	// position of the declaration
+	Line   int
+	Column int
 }
 
 func (fl *FunctionLocal) GenIR(cc *CompilerContext) value.Value {
-	alloca := cc.currentLlvmBlock.NewAlloca(plsqlTypeToLLVMType(fl.Typ))
+	t := cc.llvmTypeFor(fl.Typ)
+	alloca := cc.currentLlvmBlock.NewAlloca(t)
 	cc.scopes.addMember(fl.Name, alloca)
-	if fl.Value != "" {
-		switch fl.Typ {
+	if _, ok := t.(*types.PointerType); ok {
+		// cursor variables don't point to a cursor until they are opened
+		// and collections don't point to memory until elements are added ('list_t()' is empty too)
+		cc.currentLlvmBlock.NewStore(constant.NewNull(t.(*types.PointerType)), alloca)
+	} else if fl.Value == "" {
+		// the stack slot might still hold the value of an earlier call, locals start out as zero like package variables do
+		cc.currentLlvmBlock.NewStore(constant.NewZeroInitializer(t), alloca)
+	} else {
+		baseType := cc.baseTypeName(fl.Typ)
+		text := cc.literalText(baseType, fl.Value)
+		builtin, _ := builtinType(baseType)
+		switch builtin.Name {
 
-		case "INT":
-			i, err := strconv.ParseInt(fl.Value, 10, 64)
+		case "INT", "DATE", "TIMESTAMP", "INTERVAL DAY TO SECOND", "INTERVAL YEAR TO MONTH":
+			i, err := strconv.ParseInt(text, 10, 64)
 			if err != nil {
 				log.Panicf("%s", err.Error())
 			}
 			cc.currentLlvmBlock.NewStore(constant.NewInt(types.I64, i), alloca)
 
-		case "VARCHAR":
-			// chop off the 's on both ends
-			s := fl.Value[1 : len(fl.Value)-1]
-			runtime.MakeString(s, cc.currentLlvmBlock, alloca)
+		case "NUMBER", "BINARY_DOUBLE":
+			f, err := strconv.ParseFloat(text, 64)
+			if err != nil {
+				log.Panicf("%s", err.Error())
+			}
+			cc.currentLlvmBlock.NewStore(constant.NewFloat(types.Double, f), alloca)
+
+		case "VARCHAR", "CHAR":
+			runtime.MakeString(cc.llvmModule, text, cc.currentLlvmBlock, alloca)
 
 		default:
 			log.Panicf("Local for type '%s' not implemented", fl.Typ)
 		}
-
 	}
 	return alloca
 }
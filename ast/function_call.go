@@ -21,7 +21,6 @@ import (
 	"strings"
 
 	"github.com/llir/llvm/ir"
-	"github.com/llir/llvm/ir/types"
 	"github.com/llir/llvm/ir/value"
 	"github.com/mhelmich/plsqlc/runtime"
 )
@@ -35,9 +34,17 @@ func NewFunctionCall(moduleName string, functionName string) *FunctionCall {
 }
 
 type FunctionCall struct {
+	typed
 	ModuleName   string
 	FunctionName string
 	Args         []Expression
+	// set by the checker if a built-in function is called
+	builtin *builtinFunction
+	// set by the checker if the call is an element of a collection ('list(i)'),
+	// a method of a collection ('list.EXTEND') or the constructor of a collection type ('list_t(1, 2)')
+	element     *CollectionElement
+	method      *collectionMethod
+	constructor *Type
 }
 
 func (fc *FunctionCall) AddArg(expr Expression) {
@@ -48,45 +55,70 @@ func (fc *FunctionCall) expressionType() expressionType {
 	return functionCallExpression
 }
 
+// packageName returns the name of the package the called function lives in
+// unqualified calls go to the current package
+func (fc *FunctionCall) packageName(cc *CompilerContext) string {
+	if fc.ModuleName == "" {
+		return cc.currentPackageName
+	}
+	return fc.ModuleName
+}
+
 func (fc *FunctionCall) GenIR(cc *CompilerContext) value.Value {
+	if fc.element != nil {
+		return fc.element.GenIR(cc)
+	} else if fc.method != nil {
+		return fc.method.genIR(cc)
+	} else if fc.constructor != nil {
+		return genIRForConstructor(cc, fc.constructor, fc.Args)
+	}
+
+	moduleName := fc.packageName(cc)
 	var fn *ir.Func
-	if fc.ModuleName == "DBMS" {
+	var proto *FunctionProto
+	if fc.builtin != nil {
+		fn = cc.getFuncByName(fc.builtin.runtimeName)
+	} else if moduleName == "DBMS" {
 		// aha!
 		switch fc.FunctionName {
 		case "PRINT":
-			switch fc.Args[0].expressionType() {
-			case stringExpression:
-				fn = cc.getFuncByName(runtime.PrintStringFuncName)
-			case numberExpression:
+			// the checker made sure that there is exactly one argument of a printable type
+			if fc.Args[0].Type().Equal(IntType) {
 				fn = cc.getFuncByName(runtime.PrintIntFuncName)
-			case variableExpression:
-				variable := fc.Args[0].(*Variable)
-				v, ok := cc.scopes.findMember(variable.Name)
-				if !ok {
-					log.Panicf("Can't find variable '%s' in scope", variable.Name)
-				}
-
-				if v.Type().Equal(types.I64Ptr) {
-					fn = cc.getFuncByName(runtime.PrintIntFuncName)
-				} else if v.Type().Equal(runtime.StringPointerType) {
-					fn = cc.getFuncByName(runtime.PrintStringFuncName)
-				} else {
-					log.Panicf("Can't find type '%s'", v.Type().String())
-				}
-
-			default:
-				log.Panicf("Can't find type '%d'", fc.Args[0].expressionType())
+			} else if fc.Args[0].Type().isDouble() {
+				fn = cc.getFuncByName(runtime.PrintNumberFuncName)
+			} else {
+				fn = cc.getFuncByName(runtime.PrintStringFuncName)
 			}
 
 		default:
 			log.Panicf("Don't recognize runtime function '%s'", fc.FunctionName)
 		}
 	} else {
-		fn = cc.getFuncByName(fc.ModuleName + "." + fc.FunctionName)
+		if moduleName != cc.currentPackageName {
+			if pkg, ok := cc.packages[moduleName]; ok && !pkg.isPublicFunction(fc.FunctionName) {
+				log.Panicf("PLS-00302: component '%s' must be declared, it is private to package '%s'", fc.FunctionName, moduleName)
+			}
+		}
+
+		fn = cc.getFuncByName(moduleName + "." + fc.FunctionName)
+		proto = cc.findFunctionProto(moduleName, fc.FunctionName)
 	}
 
 	args := make([]value.Value, 0)
 	for idx := range fc.Args {
+		isByReference := proto != nil && idx < len(proto.Params) && proto.Params[idx].isByReference()
+		if isByReference || (fc.builtin != nil && fc.builtin.isOutParam(idx)) {
+			// OUT params get the address of the variable that is passed in
+			variable, ok := fc.Args[idx].(*Variable)
+			if !ok {
+				log.Panicf("Argument %d of '%s.%s' must be a variable", idx+1, moduleName, fc.FunctionName)
+			}
+
+			args = append(args, variable.address(cc))
+			continue
+		}
+
 		v := fc.Args[idx].GenIR(cc)
 
 		if fc.Args[idx].expressionType() == stringExpression {
@@ -96,6 +128,15 @@ func (fc *FunctionCall) GenIR(cc *CompilerContext) value.Value {
 		args = append(args, v)
 	}
 
+	if fc.builtin != nil && fc.builtin.variadic {
+		// 'GREATEST(a, b, c)' is 'GREATEST(GREATEST(a, b), c)'
+		result := args[0]
+		for idx := 1; idx < len(args); idx++ {
+			result = cc.currentLlvmBlock.NewCall(fn, result, args[idx])
+		}
+		return result
+	}
+
 	funcCall := cc.currentLlvmBlock.NewCall(fn, args...)
 	return funcCall
 }
@@ -103,8 +144,10 @@ func (fc *FunctionCall) GenIR(cc *CompilerContext) value.Value {
 func (fc *FunctionCall) String() string {
 	var sb strings.Builder
 	sb.WriteString("<func call> ")
-	sb.WriteString(fc.ModuleName)
-	sb.WriteString(".")
+	if fc.ModuleName != "" {
+		sb.WriteString(fc.ModuleName)
+		sb.WriteString(".")
+	}
 	sb.WriteString(fc.FunctionName)
 	sb.WriteString("(")
 	for idx := range fc.Args {
@@ -32,8 +32,9 @@ func NewFunctionProto(name string) *FunctionProto {
 }
 
 type FunctionProto struct {
-	Name   string
-	Params []*FunctionParam
+	Name       string
+	Params     []*FunctionParam
+	ReturnType string // empty for procedures
 }
 
 func (fp *FunctionProto) AddParam(name string, ownership string, t string) {
@@ -44,18 +45,49 @@ func (fp *FunctionProto) AddParam(name string, ownership string, t string) {
 	})
 }
 
+func (fp *FunctionProto) IsProcedure() bool {
+	return fp.ReturnType == ""
+}
+
 func (fp *FunctionProto) GenIR(cc *CompilerContext) *ir.Func {
 	params := make([]*ir.Param, 0)
 	for idx := range fp.Params {
 		params = append(params, fp.Params[idx].GenIR(cc))
 	}
 
+	var retType types.Type = types.Void
+	if !fp.IsProcedure() {
+		retType = cc.llvmTypeFor(fp.ReturnType)
+	}
+
 	qualifiedFuncName := cc.currentPackageName + "." + fp.Name
-	llvmFunc := cc.llvmModule.NewFunc(qualifiedFuncName, types.Void, params...)
+	llvmFunc := cc.llvmModule.NewFunc(qualifiedFuncName, retType, params...)
 	cc.scopes.addMember(qualifiedFuncName, llvmFunc)
 	return llvmFunc
 }
 
+// conformsTo checks whether a subprogram defined in a package body
+// matches its declaration in the package specification
+// types can be named differently ('pt', 'pkg.pt'), sameType tells whether two names denote the same type
+func (fp *FunctionProto) conformsTo(spec *FunctionProto, sameType func(string, string) bool) error {
+	if fp.IsProcedure() != spec.IsProcedure() || (!fp.IsProcedure() && !sameType(fp.ReturnType, spec.ReturnType)) {
+		return fmt.Errorf("'%s' returns '%s' in the specification but '%s' in the body", fp.Name, spec.ReturnType, fp.ReturnType)
+	}
+
+	if len(fp.Params) != len(spec.Params) {
+		return fmt.Errorf("'%s' has %d parameters in the specification but %d in the body", fp.Name, len(spec.Params), len(fp.Params))
+	}
+
+	for idx := range fp.Params {
+		param, specParam := fp.Params[idx], spec.Params[idx]
+		if param.Name != specParam.Name || param.Ownership != specParam.Ownership || !sameType(param.Type, specParam.Type) {
+			return fmt.Errorf("parameter %d of '%s' is '%s' in the specification but '%s' in the body", idx+1, fp.Name, spec.Params[idx].String(), fp.Params[idx].String())
+		}
+	}
+
+	return nil
+}
+
 func (fp *FunctionProto) String() string {
 	var sb strings.Builder
 	sb.WriteString(fp.Name)
@@ -65,17 +97,31 @@ func (fp *FunctionProto) String() string {
 		sb.WriteString(" ")
 	}
 	sb.WriteString(")")
+	if !fp.IsProcedure() {
+		sb.WriteString(" RETURN ")
+		sb.WriteString(fp.ReturnType)
+	}
 	return sb.String()
 }
 
 type FunctionParam struct {
 	Name      string
-	Ownership string // IN, OUT, INOUT
+	Ownership string // IN, OUT, IN OUT
 	Type      string
 }
 
+// isByReference returns true for parameters that are passed as pointers
+// so that the callee can write back into the callers variable
+func (fp *FunctionParam) isByReference() bool {
+	return fp.Ownership == "OUT" || fp.Ownership == "IN OUT"
+}
+
 func (fp *FunctionParam) GenIR(cc *CompilerContext) *ir.Param {
-	return nil
+	t := cc.llvmTypeFor(fp.Type)
+	if fp.isByReference() {
+		t = types.NewPointer(t)
+	}
+	return ir.NewParam(fp.Name, t)
 }
 
 func (fp *FunctionParam) String() string {
@@ -28,6 +28,10 @@ const (
 	functionCallExpression
 	variableExpression
 	binOpExpression
+	conversionExpression
+	sqlColumnExpression
+	cursorAttributeExpression
+	collectionElementExpression
 )
 
 type Node interface {
@@ -42,5 +46,8 @@ type Instruction interface {
 type Expression interface {
 	GenIR(cc *CompilerContext) value.Value
 	expressionType() expressionType
+	// Type returns the type the checker annotated the expression with
+	Type() *Type
+	setType(t *Type)
 	String() string
 }
@@ -18,26 +18,44 @@ package ast
 
 import (
 	"fmt"
-	"log"
 	"strconv"
+	"strings"
 
 	"github.com/llir/llvm/ir/constant"
 	"github.com/llir/llvm/ir/types"
 	"github.com/llir/llvm/ir/value"
 )
 
+// NewNumericLiteral parses the digits of a literal
+// literals that are out of range for their type are reported by the checker
 func NewNumericLiteral(value string) *NumericLiteral {
-	i, err := strconv.ParseInt(value, 10, 64)
-	if err != nil {
-		log.Panicf("Can't convert '%s' into number!", value)
+	if strings.Contains(value, ".") {
+		f, err := strconv.ParseFloat(value, 64)
+		return &NumericLiteral{
+			Decimal:    f,
+			IsDecimal:  true,
+			outOfRange: err != nil,
+			text:       value,
+		}
 	}
+
+	i, err := strconv.ParseInt(value, 10, 64)
 	return &NumericLiteral{
-		Value: i,
+		Value:      i,
+		outOfRange: err != nil,
+		text:       value,
 	}
 }
 
+// NumericLiteral is an integer (INT) or a number with a decimal point (NUMBER)
 type NumericLiteral struct {
-	Value int64
+	typed
+	Value     int64
+	Decimal   float64
+	IsDecimal bool
+	// the literal doesn't fit into an INT or a NUMBER
+	outOfRange bool
+	text       string
 }
 
 func (nl *NumericLiteral) expressionType() expressionType {
@@ -45,9 +63,15 @@ func (nl *NumericLiteral) expressionType() expressionType {
 }
 
 func (nl *NumericLiteral) GenIR(cc *CompilerContext) value.Value {
+	if nl.IsDecimal {
+		return constant.NewFloat(types.Double, nl.Decimal)
+	}
 	return constant.NewInt(types.I64, nl.Value)
 }
 
 func (nl *NumericLiteral) String() string {
+	if nl.IsDecimal {
+		return fmt.Sprintf("<numeric literal> %s", strconv.FormatFloat(nl.Decimal, 'g', -1, 64))
+	}
 	return fmt.Sprintf("<numeric literal> %d", nl.Value)
 }
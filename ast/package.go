@@ -17,39 +17,166 @@
 package ast
 
 import (
+	"fmt"
 	"strings"
 )
 
 func NewPackage(name string) *Package {
 	return &Package{
-		Name: name,
+		Name:    name,
+		hasBody: true,
 	}
 }
 
+// NewPackageFromSpec creates a package for a specification
+// that (so far) doesn't have a body
+func NewPackageFromSpec(spec *PackageSpec) *Package {
+	return &Package{
+		Name: spec.Name,
+		Spec: spec,
+	}
+}
+
+const initFunctionName = "_init"
+
 type Package struct {
-	Name      string
-	functions []*Function
+	Name         string
+	Spec         *PackageSpec
+	types        []TypeDeclaration
+	variables    []*PackageVariable
+	functions    []*Function
+	initFunction *Function
+	hasBody      bool
+	// records of rows of tables ('emp%ROWTYPE') the package uses, they are added by the checker
+	rowTypes []*RecordType
 }
 
-func (p *Package) GenIR(cc *CompilerContext) error {
+// GenIRForDeclarations declares all types and package variables
+// it has to run for all packages before any prototypes are generated
+func (p *Package) GenIRForDeclarations(cc *CompilerContext) error {
+	if err := p.checkSpecConformance(p.sameTypeName); err != nil {
+		return err
+	}
+
+	cc.currentPackageName = p.Name
+	for idx := range p.rowTypes {
+		p.rowTypes[idx].genIRForRow(cc)
+	}
+	if p.Spec != nil {
+		for idx := range p.Spec.Types {
+			p.Spec.Types[idx].GenIR(cc)
+		}
+		for idx := range p.Spec.Variables {
+			p.Spec.Variables[idx].GenIR(cc)
+		}
+	}
+	for idx := range p.types {
+		p.types[idx].GenIR(cc)
+	}
+	for idx := range p.variables {
+		p.variables[idx].GenIR(cc)
+	}
+	cc.currentPackageName = ""
+	return nil
+}
+
+// GenIRForProtos declares all functions of the package
+// it has to run for all packages before any code is generated
+// so that calls resolve regardless of the order of packages
+func (p *Package) GenIRForProtos(cc *CompilerContext) {
 	cc.currentPackageName = p.Name
-	// TDOD: first declare all types
-	// secondly declare all functions
 	for idx := range p.functions {
 		p.functions[idx].GenIRForProtos(cc)
 	}
-	// thirdly compile all the code
+	if p.initFunction != nil {
+		p.initFunction.GenIRForProtos(cc)
+	}
+	cc.currentPackageName = ""
+}
+
+func (p *Package) GenIR(cc *CompilerContext) error {
+	cc.currentPackageName = p.Name
 	for idx := range p.functions {
 		p.functions[idx].GenIR(cc)
 	}
+	if p.initFunction != nil {
+		p.initFunction.GenIR(cc)
+	}
 	cc.currentPackageName = ""
 	return nil
 }
 
+func (p *Package) AddType(td TypeDeclaration) {
+	p.types = append(p.types, td)
+}
+
+func (p *Package) AddVariable(pv *PackageVariable) {
+	p.variables = append(p.variables, pv)
+}
+
 func (p *Package) AddFunction(f *Function) {
 	p.functions = append(p.functions, f)
 }
 
+// Merge links the specification or body in other into p
+// specs and bodies of the same package can come from different files
+func (p *Package) Merge(other *Package) error {
+	if p.Name != other.Name {
+		return fmt.Errorf("Can't merge package '%s' into package '%s'", other.Name, p.Name)
+	}
+
+	if other.Spec != nil {
+		if p.Spec != nil {
+			return fmt.Errorf("Package specification '%s' is defined more than once", p.Name)
+		}
+		p.Spec = other.Spec
+	}
+
+	if other.hasBody {
+		if p.hasBody {
+			return fmt.Errorf("Package body '%s' is defined more than once", p.Name)
+		}
+		p.hasBody = true
+		p.types = other.types
+		p.variables = other.variables
+		p.functions = other.functions
+		p.initFunction = other.initFunction
+	}
+	return nil
+}
+
+// MergePackages links all packages in pkgs into the packages in into
+func MergePackages(into map[string]*Package, pkgs map[string]*Package) error {
+	for name, pkg := range pkgs {
+		existing, ok := into[name]
+		if !ok {
+			into[name] = pkg
+			continue
+		}
+
+		if err := existing.Merge(pkg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NewInitFunction creates the function holding the statements
+// of the initialization section ('BEGIN ... END name;') of the package body
+func (p *Package) NewInitFunction() *Function {
+	p.initFunction = NewFunction(initFunctionName, true)
+	return p.initFunction
+}
+
+// GetInitFunctionName returns the name of the llvm function
+// that initializes the package or an empty string if there is none
+func (p *Package) GetInitFunctionName() string {
+	if p.initFunction == nil {
+		return ""
+	}
+	return p.Name + "." + initFunctionName
+}
+
 func (p *Package) HasMainFunction() bool {
 	for idx := range p.functions {
 		if p.functions[idx].Proto.Name == "MAIN" {
@@ -59,10 +186,121 @@ func (p *Package) HasMainFunction() bool {
 	return false
 }
 
+// SetSourceFile records the file the functions of the package are defined in
+func (p *Package) SetSourceFile(path string) {
+	for idx := range p.functions {
+		p.functions[idx].File = path
+	}
+	if p.initFunction != nil {
+		p.initFunction.File = path
+	}
+}
+
+// addRowType declares the record of a row of a table in the package unless it is declared already
+func (p *Package) addRowType(rt *RecordType) {
+	for idx := range p.rowTypes {
+		if p.rowTypes[idx] == rt {
+			return
+		}
+	}
+	p.rowTypes = append(p.rowTypes, rt)
+}
+
+func (p *Package) findFunction(name string) *Function {
+	for idx := range p.functions {
+		if p.functions[idx].Proto.Name == name {
+			return p.functions[idx]
+		}
+	}
+	return nil
+}
+
+// findProto returns the prototype of a subprogram defined in the body
+// or declared in the specification of the package
+func (p *Package) findProto(name string) *FunctionProto {
+	if f := p.findFunction(name); f != nil {
+		return f.Proto
+	} else if p.Spec != nil {
+		return p.Spec.findProto(name)
+	}
+	return nil
+}
+
+// isPublicFunction returns true if a subprogram can be called from other packages
+// packages without a specification expose all of their subprograms
+func (p *Package) isPublicFunction(name string) bool {
+	if p.Spec == nil {
+		return true
+	}
+	return p.Spec.findProto(name) != nil
+}
+
+// isPublicVariable returns true if a package variable can be used by other packages
+// packages without a specification expose all of their variables
+func (p *Package) isPublicVariable(name string) bool {
+	if p.Spec == nil {
+		return true
+	}
+
+	for idx := range p.Spec.Variables {
+		if p.Spec.Variables[idx].Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// checkSpecConformance makes sure that every subprogram declared
+// in the specification is defined with the same signature in the body
+func (p *Package) checkSpecConformance(sameType func(string, string) bool) error {
+	if p.Spec == nil {
+		return nil
+	}
+
+	for idx := range p.Spec.Protos {
+		specProto := p.Spec.Protos[idx]
+		f := p.findFunction(specProto.Name)
+		if f == nil {
+			return fmt.Errorf("PLS-00323: subprogram '%s' is declared in package specification '%s' and must be defined in the package body", specProto.Name, p.Name)
+		}
+
+		if err := f.Proto.conformsTo(specProto, sameType); err != nil {
+			return fmt.Errorf("PLS-00323: subprogram '%s' of package '%s' doesn't match its declaration: %s", specProto.Name, p.Name, err.Error())
+		}
+	}
+
+	return nil
+}
+
+// sameTypeName compares type names after qualifying the names of user defined types with the package name
+func (p *Package) sameTypeName(a string, b string) bool {
+	return p.qualifyTypeName(a) == p.qualifyTypeName(b)
+}
+
+func (p *Package) qualifyTypeName(t string) string {
+	if _, ok := builtinType(t); ok || strings.Contains(t, ".") {
+		return t
+	}
+	return p.Name + "." + t
+}
+
 func (p *Package) String() string {
 	var sb strings.Builder
 	sb.WriteString(p.Name)
 	sb.WriteString("\n")
+	if p.Spec != nil {
+		sb.WriteString(p.Spec.String())
+	}
+	for idx := range p.types {
+		sb.WriteString("  ")
+		sb.WriteString(p.types[idx].String())
+		sb.WriteString("\n")
+	}
+	for idx := range p.variables {
+		sb.WriteString("  ")
+		sb.WriteString(p.variables[idx].String())
+		sb.WriteString("\n")
+	}
 	for idx := range p.functions {
 		sb.WriteString("  ")
 		sb.WriteString(p.Name)
@@ -70,5 +308,12 @@ func (p *Package) String() string {
 		sb.WriteString(p.functions[idx].String())
 		sb.WriteString("\n")
 	}
+	if p.initFunction != nil {
+		sb.WriteString("  ")
+		sb.WriteString(p.Name)
+		sb.WriteString(".")
+		sb.WriteString(p.initFunction.String())
+		sb.WriteString("\n")
+	}
 	return sb.String()
 }
@@ -0,0 +1,83 @@
+/*
+ * Copyright 2019 Marco Helmich
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ast
+
+import (
+	"strings"
+)
+
+func NewPackageSpec(name string) *PackageSpec {
+	return &PackageSpec{
+		Name:      name,
+		Protos:    make([]*FunctionProto, 0),
+		Types:     make([]TypeDeclaration, 0),
+		Variables: make([]*PackageVariable, 0),
+	}
+}
+
+// PackageSpec holds everything that is declared in 'CREATE PACKAGE name AS ...'
+// all of these declarations are public and can be used by other packages
+type PackageSpec struct {
+	Name      string
+	Protos    []*FunctionProto
+	Types     []TypeDeclaration
+	Variables []*PackageVariable
+}
+
+func (ps *PackageSpec) AddProto(fp *FunctionProto) {
+	ps.Protos = append(ps.Protos, fp)
+}
+
+func (ps *PackageSpec) AddType(td TypeDeclaration) {
+	ps.Types = append(ps.Types, td)
+}
+
+func (ps *PackageSpec) AddVariable(pv *PackageVariable) {
+	ps.Variables = append(ps.Variables, pv)
+}
+
+func (ps *PackageSpec) findProto(name string) *FunctionProto {
+	for idx := range ps.Protos {
+		if ps.Protos[idx].Name == name {
+			return ps.Protos[idx]
+		}
+	}
+	return nil
+}
+
+func (ps *PackageSpec) String() string {
+	var sb strings.Builder
+	sb.WriteString("<spec> ")
+	sb.WriteString(ps.Name)
+	sb.WriteString("\n")
+	for idx := range ps.Types {
+		sb.WriteString("  ")
+		sb.WriteString(ps.Types[idx].String())
+		sb.WriteString("\n")
+	}
+	for idx := range ps.Variables {
+		sb.WriteString("  ")
+		sb.WriteString(ps.Variables[idx].String())
+		sb.WriteString("\n")
+	}
+	for idx := range ps.Protos {
+		sb.WriteString("  ")
+		sb.WriteString(ps.Protos[idx].String())
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,66 @@
+/*
+ * Copyright 2019 Marco Helmich
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ast
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpecConformance(t *testing.T) {
+	spec := NewPackageSpec("PKG")
+	specProto := NewFunctionProto("F")
+	specProto.AddParam("I", "IN", "INT")
+	specProto.ReturnType = "VARCHAR"
+	spec.AddProto(specProto)
+
+	pkg := NewPackageFromSpec(spec)
+	assert.NotNil(t, pkg.checkSpecConformance(pkg.sameTypeName))
+
+	f := NewFunction("F", false)
+	f.AddParam("I", "IN", "INT")
+	f.Proto.ReturnType = "VARCHAR"
+	pkg.AddFunction(f)
+	assert.Nil(t, pkg.checkSpecConformance(pkg.sameTypeName))
+
+	f.Proto.Params[0].Ownership = "IN OUT"
+	assert.NotNil(t, pkg.checkSpecConformance(pkg.sameTypeName))
+
+	f.Proto.Params[0].Ownership = "IN"
+	f.Proto.ReturnType = "INT"
+	assert.NotNil(t, pkg.checkSpecConformance(pkg.sameTypeName))
+
+	// a type of the package can be named with or without the package name
+	f.Proto.ReturnType = "VARCHAR"
+	specProto.AddParam("P", "IN", "PT")
+	f.AddParam("P", "IN", "PKG.PT")
+	assert.Nil(t, pkg.checkSpecConformance(pkg.sameTypeName))
+}
+
+func TestPrivateFunctions(t *testing.T) {
+	pkg := NewPackage("PKG")
+	pkg.AddFunction(NewFunction("PUBLIC_PROC", true))
+	pkg.AddFunction(NewFunction("PRIVATE_PROC", true))
+	// without a spec everything is public
+	assert.True(t, pkg.isPublicFunction("PRIVATE_PROC"))
+
+	pkg.Spec = NewPackageSpec("PKG")
+	pkg.Spec.AddProto(NewFunctionProto("PUBLIC_PROC"))
+	assert.True(t, pkg.isPublicFunction("PUBLIC_PROC"))
+	assert.False(t, pkg.isPublicFunction("PRIVATE_PROC"))
+}
@@ -0,0 +1,102 @@
+/*
+ * Copyright 2019 Marco Helmich
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ast
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/types"
+	"github.com/mhelmich/plsqlc/runtime"
+)
+
+func NewPackageVariable(name string, typ string, value string, isConstant bool) *PackageVariable {
+	return &PackageVariable{
+		Name:       name,
+		Typ:        typ,
+		Value:      value,
+		IsConstant: isConstant,
+	}
+}
+
+// PackageVariable is a variable or constant declared at package level
+// it lives as long as the program does and is lowered to an llvm global
+type PackageVariable struct {
+	Name       string
+	Typ        string
+	Value      string
+	IsConstant bool
+}
+
+func (pv *PackageVariable) GenIR(cc *CompilerContext) *ir.Global {
+	qualifiedName := cc.currentPackageName + "." + pv.Name
+	t := cc.llvmTypeFor(pv.Typ)
+
+	var init constant.Constant
+	if _, ok := t.(*types.PointerType); ok {
+		// collections are empty until elements are added, no matter whether they are initialized with 'list_t()'
+		init = constant.NewZeroInitializer(t)
+	} else if pv.Value == "" {
+		if pv.IsConstant {
+			log.Panicf("PLS-00322: declaration of constant '%s' must contain an initialization assignment", pv.Name)
+		}
+		init = constant.NewZeroInitializer(t)
+	} else {
+		baseType := cc.baseTypeName(pv.Typ)
+		text := cc.literalText(baseType, pv.Value)
+		builtin, _ := builtinType(baseType)
+		switch builtin.Name {
+
+		case "INT", "DATE", "TIMESTAMP", "INTERVAL DAY TO SECOND", "INTERVAL YEAR TO MONTH":
+			i, err := strconv.ParseInt(text, 10, 64)
+			if err != nil {
+				log.Panicf("%s", err.Error())
+			}
+			init = constant.NewInt(types.I64, i)
+
+		case "NUMBER", "BINARY_DOUBLE":
+			f, err := strconv.ParseFloat(text, 64)
+			if err != nil {
+				log.Panicf("%s", err.Error())
+			}
+			init = constant.NewFloat(types.Double, f)
+
+		case "VARCHAR", "CHAR":
+			data := cc.llvmModule.NewGlobalDef(qualifiedName+".data", constant.NewCharArrayFromString(text))
+			data.Immutable = true
+			dataPtr := constant.NewGetElementPtr(data, llvmZero, llvmZero)
+			init = constant.NewStruct(runtime.StringType.(*types.StructType), dataPtr, constant.NewInt(types.I64, int64(len(text))))
+
+		default:
+			log.Panicf("Package variable for type '%s' not implemented", pv.Typ)
+		}
+	}
+
+	g := cc.llvmModule.NewGlobalDef(qualifiedName, init)
+	g.Immutable = pv.IsConstant
+	return g
+}
+
+func (pv *PackageVariable) String() string {
+	if pv.IsConstant {
+		return fmt.Sprintf("%s CONSTANT %s %s", pv.Name, pv.Typ, pv.Value)
+	}
+	return fmt.Sprintf("%s %s %s", pv.Name, pv.Typ, pv.Value)
+}
@@ -0,0 +1,247 @@
+/*
+ * Copyright 2019 Marco Helmich
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ast
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	IntType          = &Type{Name: "INT"}
+	NumberType       = &Type{Name: "NUMBER"}
+	BinaryDoubleType = &Type{Name: "BINARY_DOUBLE"}
+	VarcharType      = &Type{Name: "VARCHAR"}
+	CharType         = &Type{Name: "CHAR"}
+	BooleanType      = &Type{Name: "BOOLEAN"}
+	DateType         = &Type{Name: "DATE"}
+	TimestampType    = &Type{Name: "TIMESTAMP"}
+	DayToSecondType  = &Type{Name: "INTERVAL DAY TO SECOND"}
+	YearToMonthType  = &Type{Name: "INTERVAL YEAR TO MONTH"}
+	RefCursorType    = &Type{Name: "SYS_REFCURSOR", refCursor: true}
+
+	// the collections DBMS_OUTPUT.GET_LINES fills
+	CharArrType    = newBuiltinCollection("DBMS_OUTPUT.CHARARR", &CollectionType{Name: "CHARARR", ElementType: "VARCHAR2(32767)", IsAssociative: true})
+	LinesArrayType = newBuiltinCollection("DBMSOUTPUT_LINESARRAY", &CollectionType{Name: "DBMSOUTPUT_LINESARRAY", ElementType: "VARCHAR2(32767)", IsVarray: true, Limit: 2147483647})
+)
+
+// types that can be used in declarations without being declared first
+// the national character set types behave exactly like their counterparts
+var builtinTypes = map[string]*Type{
+	"INT":            IntType,
+	"INTEGER":        IntType,
+	"PLS_INTEGER":    IntType,
+	"BINARY_INTEGER": IntType,
+	"NUMBER":         NumberType,
+	"BINARY_DOUBLE":  BinaryDoubleType,
+	"VARCHAR":        VarcharType,
+	"VARCHAR2":       VarcharType,
+	"NVARCHAR2":      VarcharType,
+	"CHAR":           CharType,
+	"NCHAR":          CharType,
+	"DATE":           DateType,
+	"TIMESTAMP":      TimestampType,
+	// the precision of intervals is dropped by the parser
+	"INTERVAL DAY TO SECOND": DayToSecondType,
+	"INTERVAL YEAR TO MONTH": YearToMonthType,
+	// a cursor variable can be opened for any query
+	"SYS_REFCURSOR":         RefCursorType,
+	"DBMS_OUTPUT.CHARARR":   CharArrType,
+	"DBMSOUTPUT_LINESARRAY": LinesArrayType,
+}
+
+func newBuiltinCollection(name string, ct *CollectionType) *Type {
+	ct.element = &Type{Name: VarcharType.Name, Length: 32767}
+	return &Type{Name: name, Collection: ct}
+}
+
+// builtinType resolves the name of a built-in type including its constraint ('VARCHAR2(10)')
+// the precision and scale of numbers are ignored
+func builtinType(name string) (*Type, bool) {
+	base := name
+	var constraint string
+	if idx := strings.Index(name, "("); idx >= 0 {
+		base = name[:idx]
+		constraint = strings.TrimSuffix(name[idx+1:], ")")
+	}
+
+	t, ok := builtinTypes[base]
+	if !ok || !t.IsString() {
+		return t, ok
+	}
+
+	// 'VARCHAR2(10 CHAR)' and 'VARCHAR2(10 BYTE)' are the same for us
+	length := 0
+	if fields := strings.Fields(constraint); len(fields) > 0 {
+		n, err := strconv.Atoi(fields[0])
+		if err != nil || n < 1 {
+			return nil, false
+		}
+		length = n
+	} else if t.Equal(CharType) {
+		// CHAR without a length holds exactly one character
+		length = 1
+	}
+
+	if length == 0 {
+		return t, true
+	}
+	return &Type{Name: t.Name, Length: length}, true
+}
+
+// Type is a PL/SQL type as resolved by the checker
+// subtypes are always resolved to the type they are based on
+type Type struct {
+	// name of a built-in type or the qualified name of a record ('PKG.NAME')
+	Name string
+	// the declaration of a record type, nil for all other types
+	Record *RecordType
+	// the declaration of an explicit cursor, nil for all other types
+	Cursor *Cursor
+	// the maximum number of characters of a string type, 0 if it isn't constrained
+	// values of CHAR types are blank-padded to exactly this length
+	Length int
+	// the record a strong ref cursor type returns ('REF CURSOR RETURN rec'), nil for all other types
+	Returns *Type
+	// the declaration of a collection type, nil for all other types
+	Collection *CollectionType
+	// set for the types of cursor variables, SYS_REFCURSOR and declared ref cursor types
+	refCursor bool
+}
+
+func (t *Type) IsNumeric() bool {
+	return t.Equal(IntType) || t.isDouble()
+}
+
+// isDouble returns true for the types that are represented as doubles
+func (t *Type) isDouble() bool {
+	return t.Equal(NumberType) || t.Equal(BinaryDoubleType)
+}
+
+// numericPrecedence orders the numeric types, values are converted into the type
+// with the higher precedence when they are mixed
+// strings are converted to numbers and everything else can't take part
+func numericPrecedence(t *Type) int {
+	switch {
+	case t.Equal(IntType):
+		return 1
+	case t.Equal(NumberType), t.IsString():
+		return 2
+	case t.Equal(BinaryDoubleType):
+		return 3
+	}
+	return 0
+}
+
+// widestNumericType returns the numeric type with the highest precedence among ts
+// nil is returned if any of them isn't a number or a string
+func widestNumericType(ts ...*Type) *Type {
+	widest := IntType
+	for _, t := range ts {
+		p := numericPrecedence(t)
+		if p == 0 {
+			return nil
+		}
+		if p > numericPrecedence(widest) {
+			widest = t
+		}
+	}
+	if widest.IsString() {
+		return NumberType
+	}
+	return widest
+}
+
+// isDatetime returns true for the types that hold a point in time
+func (t *Type) isDatetime() bool {
+	return t.Equal(DateType) || t.Equal(TimestampType)
+}
+
+func (t *Type) isInterval() bool {
+	return t.Equal(DayToSecondType) || t.Equal(YearToMonthType)
+}
+
+// isInt64 returns true for the types that are represented as 64 bit integers
+// dates and timestamps are microseconds since 1970, intervals are microseconds or months
+func (t *Type) isInt64() bool {
+	return t.Equal(IntType) || t.isDatetime() || t.isInterval()
+}
+
+func (t *Type) IsString() bool {
+	return t.Equal(VarcharType) || t.Equal(CharType)
+}
+
+// isCursor returns true for explicit cursors and cursor variables
+func (t *Type) isCursor() bool {
+	return t.Cursor != nil || t.refCursor
+}
+
+// isRefCursor returns true for the types of cursor variables
+func (t *Type) isRefCursor() bool {
+	return t.refCursor
+}
+
+// refCursorsMatch returns true if a cursor variable of type from can be assigned to one of type to
+// weak ref cursors take any cursor, strong ones need the same record
+func refCursorsMatch(from *Type, to *Type) bool {
+	if !from.isRefCursor() || !to.isRefCursor() {
+		return false
+	}
+	return from.Returns == nil || to.Returns == nil || from.Returns.Equal(to.Returns)
+}
+
+func (t *Type) IsRecord() bool {
+	return t.Record != nil
+}
+
+func (t *Type) IsCollection() bool {
+	return t.Collection != nil
+}
+
+func (t *Type) Equal(other *Type) bool {
+	return t.Name == other.Name
+}
+
+// packageName returns the name of the package a record type is declared in
+func (t *Type) packageName() string {
+	idx := strings.Index(t.Name, ".")
+	if idx < 0 {
+		return ""
+	}
+	return t.Name[:idx]
+}
+
+func (t *Type) String() string {
+	if t.Length > 0 {
+		return fmt.Sprintf("%s(%d)", t.Name, t.Length)
+	}
+	return t.Name
+}
+
+// typed holds the type the checker determined for an expression
+type typed struct {
+	typ *Type
+}
+
+func (t *typed) Type() *Type {
+	return t.typ
+}
+
+func (t *typed) setType(typ *Type) {
+	t.typ = typ
+}
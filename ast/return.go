@@ -16,10 +16,45 @@
 
 package ast
 
-func NewRetrn() *Retrn {
-	return &Retrn{}
+import (
+	"fmt"
+
+	"github.com/llir/llvm/ir/value"
+)
+
+// NewRetrn creates a 'RETURN' statement, expr is nil for procedures
+func NewRetrn(expr Expression) *Retrn {
+	return &Retrn{
+		expr: expr,
+	}
 }
 
 type Retrn struct {
 	expr Expression
 }
+
+func (r *Retrn) GenIR(cc *CompilerContext) value.Value {
+	if r.expr == nil {
+		closeLoopCursors(cc)
+		endAutonomousTransaction(cc)
+		cc.currentLlvmBlock.NewRet(nil)
+		return nil
+	}
+
+	v := r.expr.GenIR(cc)
+	if r.expr.expressionType() == stringExpression {
+		v = cc.currentLlvmBlock.NewLoad(v)
+	}
+
+	closeLoopCursors(cc)
+	endAutonomousTransaction(cc)
+	cc.currentLlvmBlock.NewRet(v)
+	return nil
+}
+
+func (r *Retrn) String() string {
+	if r.expr == nil {
+		return "<return>"
+	}
+	return fmt.Sprintf("<return> %s", r.expr.String())
+}
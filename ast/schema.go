@@ -0,0 +1,172 @@
+/*
+ * Copyright 2019 Marco Helmich
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ast
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// NewSchema creates a schema without any tables
+func NewSchema() *Schema {
+	return &Schema{
+		tables: make(map[string]*Table),
+	}
+}
+
+// Schema holds the tables of the database that embedded SQL runs against
+// it is read from the DDL of the database ('CREATE TABLE ...') and lets the checker
+// find misspelled tables and columns before a program runs
+type Schema struct {
+	tables map[string]*Table
+}
+
+func (s *Schema) AddTable(t *Table) error {
+	if _, ok := s.tables[t.Name]; ok {
+		return fmt.Errorf("ORA-00955: name '%s' is already used by an existing object", t.Name)
+	}
+	s.tables[t.Name] = t
+	return nil
+}
+
+func (s *Schema) Table(name string) (*Table, bool) {
+	t, ok := s.tables[name]
+	return t, ok
+}
+
+func NewTable(name string) *Table {
+	return &Table{
+		Name:    name,
+		Columns: make([]*Column, 0),
+	}
+}
+
+// NewView creates a table whose columns aren't known
+// the columns of views are whatever their queries select, any column is accepted
+func NewView(name string) *Table {
+	return &Table{
+		Name: name,
+	}
+}
+
+// Table is a table or view of a schema, Columns is nil if its columns aren't known
+type Table struct {
+	Name    string
+	Columns []*Column
+}
+
+// Column is a column of a table, Type is nil for columns that are declared without a type
+type Column struct {
+	Name string
+	Type *Type
+}
+
+// AddColumn adds a column with the SQL type typeName ('VARCHAR2(10)', 'INTEGER', 'TEXT', ...)
+func (t *Table) AddColumn(name string, typeName string) error {
+	if t.column(name) != nil {
+		return fmt.Errorf("ORA-00957: duplicate column name '%s' in table '%s'", name, t.Name)
+	}
+
+	var typ *Type
+	if typeName != "" {
+		var ok bool
+		if typ, ok = sqlColumnType(typeName); !ok {
+			return fmt.Errorf("ORA-00902: invalid datatype '%s' of column '%s.%s'", typeName, t.Name, name)
+		}
+	}
+	t.Columns = append(t.Columns, &Column{Name: name, Type: typ})
+	return nil
+}
+
+func (t *Table) column(name string) *Column {
+	for idx := range t.Columns {
+		if t.Columns[idx].Name == name {
+			return t.Columns[idx]
+		}
+	}
+	return nil
+}
+
+// hasColumn returns true if the table has a column name or its columns aren't known
+func (t *Table) hasColumn(name string) bool {
+	return t.Columns == nil || t.column(name) != nil
+}
+
+// rowTypeSuffix turns the name of a table into the name of the record of its rows
+const rowTypeSuffix = "%ROWTYPE"
+
+// SQL types that aren't PL/SQL types, SQLite names its types like this
+var sqlTypeAliases = map[string]*Type{
+	"BIGINT":   IntType,
+	"SMALLINT": IntType,
+	"TINYINT":  IntType,
+	"BOOLEAN":  IntType,
+	"REAL":     NumberType,
+	"FLOAT":    NumberType,
+	"DOUBLE":   NumberType,
+	"DECIMAL":  NumberType,
+	"NUMERIC":  NumberType,
+	"TEXT":     VarcharType,
+	"CLOB":     VarcharType,
+	"NCLOB":    VarcharType,
+}
+
+// sqlColumnType resolves the type of a column into the PL/SQL type its values are selected as
+// numbers without a scale ('NUMBER(10)', 'NUMBER(10, 0)') hold integers
+func sqlColumnType(name string) (*Type, bool) {
+	base := name
+	var constraint string
+	if idx := strings.Index(name, "("); idx >= 0 {
+		base = name[:idx]
+		constraint = strings.TrimSuffix(name[idx+1:], ")")
+	}
+
+	if t, ok := sqlTypeAliases[base]; ok {
+		return t, true
+	}
+	if base == "NUMBER" && constraint != "" {
+		parts := strings.Split(constraint, ",")
+		if len(parts) == 1 {
+			return IntType, true
+		} else if scale, err := strconv.Atoi(strings.TrimSpace(parts[1])); err == nil && scale == 0 {
+			return IntType, true
+		}
+		return NumberType, true
+	}
+
+	t, ok := builtinType(name)
+	if !ok || t.Equal(BooleanType) || t.Equal(RefCursorType) || t.IsCollection() {
+		return nil, false
+	}
+	return t, true
+}
+
+// rowType creates the record that holds a row of a table ('emp%ROWTYPE')
+// the fields are named and typed like the columns, columns without a type are read as strings
+func (t *Table) rowType() *Type {
+	name := t.Name + rowTypeSuffix
+	rt := NewRecordType(name)
+	for _, col := range t.Columns {
+		typ := VarcharType
+		if col.Type != nil {
+			typ = col.Type
+		}
+		rt.AddField(col.Name, typ.String())
+	}
+	return &Type{Name: name, Record: rt}
+}
@@ -0,0 +1,401 @@
+/*
+ * Copyright 2019 Marco Helmich
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ast
+
+import (
+	"fmt"
+)
+
+// embedded SQL is checked against the schema of the database if there is one
+// every name of a statement that isn't a PL/SQL variable has to be a table, a column or an alias
+
+// words of SQL that the lexer doesn't know as keywords, they aren't names of columns
+var sqlKeywords = map[string]bool{
+	"ALL": true, "AND": true, "ANY": true, "AS": true, "ASC": true, "BETWEEN": true, "BY": true,
+	"CASE": true, "COLLATE": true, "CROSS": true, "CURRENT_DATE": true, "CURRENT_TIMESTAMP": true,
+	"DATE": true, "DAY": true, "DEFAULT": true, "DELETE": true, "DESC": true, "DISTINCT": true, "ELSE": true,
+	"END": true, "ESCAPE": true, "EXCEPT": true, "EXISTS": true, "FALSE": true, "FETCH": true, "FIRST": true,
+	"FROM": true, "FULL": true, "GROUP": true, "HAVING": true, "HOUR": true, "IN": true, "INNER": true,
+	"INSERT": true, "INTERSECT": true, "INTERVAL": true, "INTO": true, "IS": true, "JOIN": true, "LAST": true,
+	"LEFT": true, "LIKE": true, "LIMIT": true, "MATCHED": true, "MERGE": true, "MINUS": true, "MINUTE": true,
+	"MONTH": true, "NATURAL": true, "NEXT": true, "NOT": true, "NULL": true, "NULLS": true, "OFFSET": true,
+	"ON": true, "ONLY": true, "OR": true, "ORDER": true, "OUTER": true, "RIGHT": true, "ROW": true,
+	"ROWID": true, "ROWNUM": true, "ROWS": true, "SECOND": true, "SELECT": true, "SET": true, "SOME": true,
+	"SYSDATE": true, "SYSTIMESTAMP": true, "THEN": true, "TIMESTAMP": true, "TO": true, "TRUE": true,
+	"UNION": true, "UPDATE": true, "USING": true, "VALUES": true, "WHEN": true, "WHERE": true, "YEAR": true,
+}
+
+// sqlNames are the tables of a statement and the aliases it declares
+type sqlNames struct {
+	schema *Schema
+	// tables by the name they are referred to by, their alias or their name
+	tables map[string]*Table
+	// all tables in the order they appear
+	list []*Table
+	// the tables of the outermost query in the order they appear, '*' selects their columns
+	outer []*Table
+	// aliases of the columns of select lists, ORDER BY can refer to them
+	aliases map[string]bool
+	// positions of the tokens that name tables or declare aliases
+	declared map[int]bool
+	// names of the tables the schema doesn't know
+	missing []string
+	// tables that rows are inserted into, their columns aren't names of the statement
+	inserted map[*Table]bool
+}
+
+func isSqlName(t SqlToken) bool {
+	return t.IsIdentifier && !sqlKeywords[t.Text]
+}
+
+// endsExpression returns true if an alias can follow the token without 'AS' ('sal * 2 bonus')
+func endsExpression(t SqlToken) bool {
+	return isSqlName(t) || t.IsString || t.Text == ")" || t.Text == "END" || t.Text == "?" ||
+		(t.Text != "" && t.Text[0] >= '0' && t.Text[0] <= '9')
+}
+
+// resolveSqlNames finds the tables and aliases of a statement
+// tables follow FROM, JOIN, INTO, UPDATE and the commas between tables
+// subqueries in FROM ('(SELECT ...) s') are tables whose columns aren't known
+// names are shared by all levels of a statement, correlated subqueries can refer to the tables of the query they are part of
+func resolveSqlNames(schema *Schema, tokens []SqlToken) *sqlNames {
+	n := &sqlNames{
+		schema:   schema,
+		tables:   make(map[string]*Table),
+		list:     make([]*Table, 0),
+		outer:    make([]*Table, 0),
+		aliases:  make(map[string]bool),
+		declared: make(map[int]bool),
+		missing:  make([]string, 0),
+		inserted: make(map[*Table]bool),
+	}
+
+	// whether the tokens name tables and whether they are a select list, one entry per level of parentheses
+	isTableClause := []bool{false}
+	isSelectList := []bool{false}
+	// the levels of parentheses that hold subqueries in FROM
+	isSubqueryTable := []bool{false}
+	expectTable := false
+	isInsert := false
+	var lastTable *Table
+	for idx := 0; idx < len(tokens); idx++ {
+		t := tokens[idx]
+		depth := len(isTableClause) - 1
+		switch t.Text {
+		case "(":
+			// columns of INSERT INTO ('t (a, b)') aren't tables, subqueries start with SELECT
+			isTableClause = append(isTableClause, false)
+			isSelectList = append(isSelectList, false)
+			isSubqueryTable = append(isSubqueryTable, expectTable)
+			expectTable = false
+			lastTable = nil
+			continue
+		case ")":
+			if depth == 0 {
+				continue
+			}
+			lastTable = nil
+			if isSubqueryTable[depth] {
+				lastTable = NewView("")
+				n.list = append(n.list, lastTable)
+				if depth == 1 {
+					n.outer = append(n.outer, lastTable)
+				}
+			}
+			isTableClause = isTableClause[:depth]
+			isSelectList = isSelectList[:depth]
+			isSubqueryTable = isSubqueryTable[:depth]
+			continue
+		case "FROM", "JOIN", "INTO", "UPDATE":
+			// MERGE is translated before its names are resolved, USING only names columns of joins
+			isTableClause[depth] = true
+			isSelectList[depth] = false
+			expectTable = true
+			isInsert = t.Text == "INTO" && idx > 0 && tokens[idx-1].Text == "INSERT"
+			lastTable = nil
+			continue
+		case "SELECT":
+			isSelectList[depth] = true
+			isTableClause[depth] = false
+		case "WHERE", "SET", "VALUES", "ON", "GROUP", "ORDER", "HAVING", "WHEN", "UNION", "INTERSECT", "EXCEPT", "MINUS":
+			isTableClause[depth] = false
+		case ",":
+			expectTable = isTableClause[depth]
+			lastTable = nil
+			continue
+		}
+
+		switch {
+		case t.Text == "AS":
+			// the alias of a table or column follows
+			if idx+1 < len(tokens) && tokens[idx+1].IsIdentifier {
+				n.declared[idx+1] = true
+				if lastTable != nil {
+					n.declareAlias(tokens[idx+1].Text, lastTable)
+				} else if isSelectList[depth] {
+					n.aliases[tokens[idx+1].Text] = true
+				}
+				idx++
+			}
+			lastTable = nil
+
+		case expectTable && isSqlName(t):
+			// names of tables can be qualified by their schema ('hr.emp')
+			for idx+2 < len(tokens) && tokens[idx+1].Text == "." && tokens[idx+2].IsIdentifier {
+				n.declared[idx] = true
+				idx += 2
+				t = tokens[idx]
+			}
+			n.declared[idx] = true
+			lastTable = n.declareTable(t.Text)
+			if depth == 0 {
+				n.outer = append(n.outer, lastTable)
+			}
+			if isInsert {
+				n.inserted[lastTable] = true
+			}
+			expectTable = false
+			isInsert = false
+
+		case lastTable != nil && isSqlName(t):
+			n.declared[idx] = true
+			n.declareAlias(t.Text, lastTable)
+			lastTable = nil
+
+		case isSelectList[depth] && isSqlName(t) && idx > 0 && endsExpression(tokens[idx-1]) &&
+			(idx+1 == len(tokens) || tokens[idx+1].Text == "," || tokens[idx+1].Text == "FROM"):
+			n.declared[idx] = true
+			n.aliases[t.Text] = true
+
+		default:
+			lastTable = nil
+			expectTable = false
+		}
+	}
+	return n
+}
+
+func (n *sqlNames) declareTable(name string) *Table {
+	if n.schema == nil {
+		// without a schema the statement is all there is to know about its tables
+		table := NewView(name)
+		n.tables[name] = table
+		n.list = append(n.list, table)
+		return table
+	}
+
+	table, ok := n.schema.Table(name)
+	if !ok {
+		if !contains(n.missing, name) {
+			n.missing = append(n.missing, name)
+		}
+		// the columns of missing tables aren't reported on top of the tables
+		table = NewView(name)
+	}
+	n.tables[name] = table
+	n.list = append(n.list, table)
+	return table
+}
+
+func (n *sqlNames) declareAlias(alias string, table *Table) {
+	if table.Name == "" {
+		// the alias is the only name of a subquery
+		table.Name = alias
+	}
+	n.tables[alias] = table
+}
+
+// check returns an error for every table and column of a statement that the schema doesn't know
+func (n *sqlNames) check(tokens []SqlToken) []error {
+	errs := make([]error, 0)
+	for _, name := range n.missing {
+		errs = append(errs, fmt.Errorf("ORA-00942: table or view '%s' does not exist", name))
+	}
+
+	for idx := 0; idx < len(tokens); idx++ {
+		t := tokens[idx]
+		if !isSqlName(t) || n.declared[idx] || (idx+1 < len(tokens) && tokens[idx+1].Text == "(") {
+			continue
+		}
+
+		if idx+2 < len(tokens) && tokens[idx+1].Text == "." {
+			// a qualified column ('e.name') or all columns of a table ('e.*')
+			column := tokens[idx+2]
+			table, ok := n.tables[t.Text]
+			switch {
+			case column.Text == "NEXTVAL" || column.Text == "CURRVAL":
+				// sequences aren't part of the schema
+			case !ok:
+				errs = append(errs, fmt.Errorf("ORA-00904: '%s': invalid identifier", t.Text))
+			case column.IsIdentifier && !table.hasColumn(column.Text):
+				errs = append(errs, fmt.Errorf("ORA-00904: '%s.%s': invalid identifier", t.Text, column.Text))
+			}
+			idx += 2
+			continue
+		}
+
+		if !n.aliases[t.Text] && n.columnTable(t.Text) == nil {
+			errs = append(errs, fmt.Errorf("ORA-00904: '%s': invalid identifier", t.Text))
+		}
+	}
+	return errs
+}
+
+// columnTable returns the first table of the statement that has a column name
+// tables whose columns aren't known have every column, they come last
+func (n *sqlNames) columnTable(name string) *Table {
+	var unknown *Table
+	for _, table := range n.list {
+		if table.Columns == nil && unknown == nil {
+			unknown = table
+		} else if table.column(name) != nil {
+			return table
+		}
+	}
+	return unknown
+}
+
+// isColumn returns true if name is a known column of a table the statement reads or changes
+// the columns of the table rows are inserted into aren't, 'VALUES (salary)' can't refer to them
+func (n *sqlNames) isColumn(name string) bool {
+	for _, table := range n.list {
+		if !n.inserted[table] && table.column(name) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// usedAsColumns returns the names the statement itself uses as columns, whether a schema knows them or not
+// those are the columns that are SET, columns qualified by a table of the statement ('e.salary')
+// and names that are compared with themselves ('id = id' can't compare a variable with itself)
+func (n *sqlNames) usedAsColumns(tokens []SqlToken) map[string]bool {
+	columns := make(map[string]bool)
+	isQualified := func(idx int) bool {
+		return (idx > 0 && tokens[idx-1].Text == ".") || (idx+1 < len(tokens) && tokens[idx+1].Text == ".")
+	}
+	depth := 0
+	setDepth := -1
+	for idx, t := range tokens {
+		switch t.Text {
+		case "(":
+			depth++
+		case ")":
+			depth--
+		case "SET":
+			setDepth = depth
+		case "WHERE", "FROM":
+			if depth == setDepth {
+				setDepth = -1
+			}
+		}
+		if !isSqlName(t) || n.declared[idx] {
+			continue
+		}
+
+		switch {
+		case depth == setDepth && idx > 0 && (tokens[idx-1].Text == "SET" || tokens[idx-1].Text == ",") && !isQualified(idx):
+			columns[t.Text] = true
+		case idx > 1 && tokens[idx-1].Text == ".":
+			if table, ok := n.tables[tokens[idx-2].Text]; ok && !n.inserted[table] {
+				columns[t.Text] = true
+			}
+		case idx+2 < len(tokens) && tokens[idx+1].Text == "=" && tokens[idx+2].Text == t.Text && !isQualified(idx) && !isQualified(idx+2):
+			columns[t.Text] = true
+		}
+	}
+	return columns
+}
+
+// selectColumn is a column of the result of a query, its type is nil if it isn't known
+type selectColumn struct {
+	name string
+	typ  *Type
+}
+
+// selectColumns returns the columns a query selects, '*' selects all columns of the tables of the query
+// only columns of tables have a type, the types of expressions aren't known
+func (n *sqlNames) selectColumns(tokens []SqlToken) ([]*selectColumn, error) {
+	items, err := selectListItems(tokens)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make([]*selectColumn, 0, len(items))
+	for _, item := range items {
+		if !item.isStar() {
+			columns = append(columns, &selectColumn{name: item.name, typ: n.columnType(item.expr)})
+			continue
+		}
+
+		tables := n.outer
+		if len(item.expr) == 3 {
+			table, ok := n.tables[item.expr[0].Text]
+			if !ok {
+				return nil, fmt.Errorf("ORA-00904: '%s': invalid identifier", item.expr[0].Text)
+			}
+			tables = []*Table{table}
+		}
+		for _, table := range tables {
+			if table.Columns == nil {
+				return nil, fmt.Errorf("ORA-03001: unimplemented feature, the columns of '%s' aren't known", table.Name)
+			}
+			for _, col := range table.Columns {
+				columns = append(columns, &selectColumn{name: col.Name, typ: col.Type})
+			}
+		}
+	}
+	return columns, nil
+}
+
+// columnType returns the type of an expression that is a plain column ('name', 'e.name')
+func (n *sqlNames) columnType(expr []SqlToken) *Type {
+	var table *Table
+	var name string
+	switch {
+	case len(expr) == 1 && isSqlName(expr[0]):
+		name = expr[0].Text
+		table = n.columnTable(name)
+	case len(expr) == 3 && expr[1].Text == ".":
+		name = expr[2].Text
+		table = n.tables[expr[0].Text]
+	}
+
+	if table == nil || table.Columns == nil {
+		return nil
+	}
+	if col := table.column(name); col != nil {
+		return col.Type
+	}
+	return nil
+}
+
+// sqlTypesMatch returns true if a column of type col can be selected into a variable of type target
+// strings take any value, dates and intervals are stored as strings
+func sqlTypesMatch(col *Type, target *Type) bool {
+	switch {
+	case target.IsString():
+		return true
+	case target.IsNumeric():
+		return col.IsNumeric()
+	case target.isDatetime():
+		return col.isDatetime() || col.IsString()
+	case target.isInterval():
+		return col.isInterval() || col.IsString()
+	}
+	return false
+}
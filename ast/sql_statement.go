@@ -0,0 +1,197 @@
+/*
+ * Copyright 2019 Marco Helmich
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ast
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+	"github.com/mhelmich/plsqlc/runtime"
+)
+
+func NewSqlStatement(kind string) *SqlStatement {
+	return &SqlStatement{
+		Kind:   kind,
+		Tokens: make([]SqlToken, 0),
+		Into:   make([]*Variable, 0),
+	}
+}
+
+// SqlToken is a word, literal or symbol of an embedded SQL statement as the lexer found it
+type SqlToken struct {
+	Text string
+	// identifiers can refer to PL/SQL variables, those are passed to the database as bind parameters
+	IsIdentifier bool
+	IsString     bool
+}
+
+// SqlStatement is a SELECT INTO, INSERT, UPDATE, DELETE or MERGE inside a block
+// the statement is kept as a list of tokens, the checker turns them into the text that is sent to the database
+type SqlStatement struct {
+	// SELECT, INSERT, UPDATE, DELETE or MERGE
+	Kind   string
+	Tokens []SqlToken
+	// the variables a query fetches its row into
+	Into []*Variable
+	// set for 'BULK COLLECT INTO', the query fetches all its rows into collections
+	Bulk bool
+	// set by the checker, a MERGE is executed as more than one statement
+	queries []*sqlQuery
+	// the values that are assigned to the INTO variables, they are read from columns
+	intoValues []Expression
+	columns    []*sqlColumn
+	bulk       *bulkCollect
+}
+
+func (s *SqlStatement) AddToken(text string, isIdentifier bool, isString bool) {
+	s.Tokens = append(s.Tokens, SqlToken{
+		Text:         text,
+		IsIdentifier: isIdentifier,
+		IsString:     isString,
+	})
+}
+
+func (s *SqlStatement) AddInto(v *Variable) {
+	s.Into = append(s.Into, v)
+}
+
+func (s *SqlStatement) GenIR(cc *CompilerContext) value.Value {
+	runtime.GenerateSQLInModule(cc.llvmModule)
+
+	var rowcount value.Value = constant.NewInt(types.I64, 0)
+	for _, q := range s.queries {
+		stmt := q.genIR(cc)
+		if s.Kind != "SELECT" {
+			n := cc.currentLlvmBlock.NewCall(cc.getFuncByName(runtime.SqlExecuteFuncName), stmt)
+			rowcount = cc.currentLlvmBlock.NewAdd(rowcount, n)
+			continue
+		}
+
+		if s.bulk != nil {
+			rowcount = s.bulk.genIRForRows(cc, stmt, s.columns, s.intoValues)
+			cc.currentLlvmBlock.NewCall(cc.getFuncByName(runtime.SqlFinalizeFuncName), stmt)
+			continue
+		}
+
+		// a query that is selected into variables returns exactly one row
+		cc.currentLlvmBlock.NewCall(cc.getFuncByName(runtime.SqlFetchOneFuncName), stmt, constant.NewInt(types.I64, int64(len(s.columns))))
+		genIRForInto(cc, stmt, s.Into, s.columns, s.intoValues)
+		cc.currentLlvmBlock.NewCall(cc.getFuncByName(runtime.SqlFetchDoneFuncName), stmt)
+		rowcount = constant.NewInt(types.I64, 1)
+	}
+
+	cc.currentLlvmBlock.NewStore(rowcount, cc.getGlobalByName(runtime.SqlRowcountName))
+	return nil
+}
+
+func (s *SqlStatement) String() string {
+	return fmt.Sprintf("<sql> %s", sqlText(s.Tokens))
+}
+
+// sqlQuery is the text of a single statement as it is sent to the database
+// together with the values of its bind parameters in the order they appear
+type sqlQuery struct {
+	text  string
+	binds []Expression
+	// the tokens of the text, bind parameters replace the variables
+	tokens []SqlToken
+}
+
+// genIR prepares the statement and binds its parameters
+func (q *sqlQuery) genIR(cc *CompilerContext) value.Value {
+	text := runtime.NewConstantString(cc.llvmModule, fmt.Sprintf("_sql.%d", len(cc.llvmModule.Globals)), q.text)
+	stmt := cc.currentLlvmBlock.NewCall(cc.getFuncByName(runtime.SqlPrepareFuncName), text)
+	genIRForBinds(cc, stmt, q.binds)
+	return stmt
+}
+
+// genIRForBinds binds values to the parameters of a statement in the order they appear
+func genIRForBinds(cc *CompilerContext, stmt value.Value, binds []Expression) {
+	for idx, bind := range binds {
+		v := bind.GenIR(cc)
+		if bind.expressionType() == stringExpression {
+			v = cc.currentLlvmBlock.NewLoad(v)
+		}
+
+		// parameters are numbered from 1
+		pos := constant.NewInt(types.I32, int64(idx+1))
+		var bindFuncName string
+		switch t := bind.Type(); {
+		case t.IsString():
+			bindFuncName = runtime.SqlBindStringFuncName
+		case t.isDouble():
+			bindFuncName = runtime.SqlBindNumberFuncName
+		default:
+			bindFuncName = runtime.SqlBindIntFuncName
+		}
+		cc.currentLlvmBlock.NewCall(cc.getFuncByName(bindFuncName), stmt, pos, v)
+	}
+}
+
+// sqlColumn reads a column of the row a query fetched
+// columns are read as INT, NUMBER or VARCHAR, the checker converts them into the type of their target
+type sqlColumn struct {
+	typed
+	index int
+	// the statement the row belongs to, set right before the column is read
+	stmt value.Value
+}
+
+func newSqlColumn(index int, t *Type) *sqlColumn {
+	col := &sqlColumn{
+		index: index,
+	}
+	col.setType(t)
+	return col
+}
+
+func (col *sqlColumn) expressionType() expressionType {
+	return sqlColumnExpression
+}
+
+func (col *sqlColumn) GenIR(cc *CompilerContext) value.Value {
+	var funcName string
+	switch {
+	case col.typ.IsString():
+		funcName = runtime.SqlColumnStringFuncName
+	case col.typ.isDouble():
+		funcName = runtime.SqlColumnNumberFuncName
+	default:
+		funcName = runtime.SqlColumnIntFuncName
+	}
+	return cc.currentLlvmBlock.NewCall(cc.getFuncByName(funcName), col.stmt, constant.NewInt(types.I32, int64(col.index)))
+}
+
+func (col *sqlColumn) String() string {
+	return fmt.Sprintf("<sql column> %d", col.index)
+}
+
+// sqlText joins tokens into the text of a statement
+// adjacent strings are joined without a blank, that keeps quotes that are doubled inside a literal intact
+func sqlText(tokens []SqlToken) string {
+	var sb strings.Builder
+	for idx := range tokens {
+		if idx > 0 && !(tokens[idx].IsString && tokens[idx-1].IsString) {
+			sb.WriteString(" ")
+		}
+		sb.WriteString(tokens[idx].Text)
+	}
+	return sb.String()
+}
@@ -0,0 +1,305 @@
+/*
+ * Copyright 2019 Marco Helmich
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ast
+
+import (
+	"errors"
+)
+
+// Oracle SQL that SQLite doesn't understand is rewritten before it's sent to the database
+
+// sqlWords creates tokens for the keywords and symbols of rewritten statements
+func sqlWords(words ...string) []SqlToken {
+	tokens := make([]SqlToken, len(words))
+	for idx := range words {
+		tokens[idx] = SqlToken{Text: words[idx]}
+	}
+	return tokens
+}
+
+func joinTokens(parts ...[]SqlToken) []SqlToken {
+	tokens := make([]SqlToken, 0)
+	for idx := range parts {
+		tokens = append(tokens, parts[idx]...)
+	}
+	return tokens
+}
+
+// removeDual drops 'FROM DUAL', SQLite selects expressions without a table
+func removeDual(tokens []SqlToken) []SqlToken {
+	result := make([]SqlToken, 0, len(tokens))
+	for idx := 0; idx < len(tokens); idx++ {
+		if tokens[idx].Text == "FROM" && idx+1 < len(tokens) && tokens[idx+1].Text == "DUAL" {
+			idx++
+			continue
+		}
+		result = append(result, tokens[idx])
+	}
+	return result
+}
+
+// sqlTokenReader walks the tokens of a statement
+type sqlTokenReader struct {
+	tokens []SqlToken
+	pos    int
+}
+
+func (r *sqlTokenReader) peekIs(text string) bool {
+	return r.pos < len(r.tokens) && r.tokens[r.pos].Text == text
+}
+
+func (r *sqlTokenReader) accept(text string) bool {
+	if r.peekIs(text) {
+		r.pos++
+		return true
+	}
+	return false
+}
+
+func (r *sqlTokenReader) expect(words ...string) error {
+	for _, w := range words {
+		if !r.accept(w) {
+			return errors.New("ORA-00905: missing keyword " + w)
+		}
+	}
+	return nil
+}
+
+// until returns the tokens up to the first of words that isn't nested in parentheses
+func (r *sqlTokenReader) until(words ...string) []SqlToken {
+	start := r.pos
+	depth := 0
+	for ; r.pos < len(r.tokens); r.pos++ {
+		switch t := r.tokens[r.pos].Text; t {
+		case "(":
+			depth++
+		case ")":
+			depth--
+		default:
+			if depth == 0 && contains(words, t) {
+				return r.tokens[start:r.pos]
+			}
+		}
+	}
+	return r.tokens[start:]
+}
+
+func contains(words []string, word string) bool {
+	for idx := range words {
+		if words[idx] == word {
+			return true
+		}
+	}
+	return false
+}
+
+// splitAlias splits a table or a subquery with an optional alias ('emp e', '(SELECT ...) s')
+// the name of the table is its alias if there isn't one
+func splitAlias(tokens []SqlToken) (table []SqlToken, alias []SqlToken) {
+	if len(tokens) < 2 || tokens[len(tokens)-1].Text == ")" || tokens[len(tokens)-2].Text == "." {
+		return tokens, nil
+	}
+	table = tokens[:len(tokens)-1]
+	if table[len(table)-1].Text == "AS" {
+		table = table[:len(table)-1]
+	}
+	return table, tokens[len(tokens)-1:]
+}
+
+// withAlias appends ' AS alias' to a table if it has one
+func withAlias(table []SqlToken, alias []SqlToken) []SqlToken {
+	if alias == nil {
+		return table
+	}
+	return joinTokens(table, sqlWords("AS"), alias)
+}
+
+// stripQualifiers removes the qualifiers of the column names in a list
+// the columns are the first names of every comma separated element ('a.x = 1, a.y = 2' becomes 'x = 1, y = 2')
+func stripQualifiers(tokens []SqlToken) []SqlToken {
+	result := make([]SqlToken, 0, len(tokens))
+	depth := 0
+	isStart := true
+	for idx := 0; idx < len(tokens); idx++ {
+		switch tokens[idx].Text {
+		case "(":
+			depth++
+		case ")":
+			depth--
+		}
+
+		if isStart && depth == 0 && idx+2 < len(tokens) && tokens[idx+1].Text == "." {
+			idx++
+			continue
+		}
+		result = append(result, tokens[idx])
+		isStart = depth == 0 && tokens[idx].Text == ","
+	}
+	return result
+}
+
+// unwrap removes the parentheses around a list ('(a, b)')
+func unwrap(tokens []SqlToken) []SqlToken {
+	if len(tokens) >= 2 && tokens[0].Text == "(" && tokens[len(tokens)-1].Text == ")" {
+		return tokens[1 : len(tokens)-1]
+	}
+	return tokens
+}
+
+// selectItem is an element of a select list, the expression it selects and the name of its column
+type selectItem struct {
+	name string
+	expr []SqlToken
+}
+
+// isStar returns true for the items that select all columns of the tables of a query ('*', 'e.*')
+func (i *selectItem) isStar() bool {
+	return i.expr[len(i.expr)-1].Text == "*" && (len(i.expr) == 1 || i.expr[len(i.expr)-2].Text == ".")
+}
+
+// selectListItems splits the select list of a query into its items ('SELECT e.name, sal * 2 AS bonus FROM ...' selects NAME and BONUS)
+// expressions without an alias are named by their text, they can't be referred to
+func selectListItems(tokens []SqlToken) ([]*selectItem, error) {
+	r := &sqlTokenReader{tokens: tokens}
+	if err := r.expect("SELECT"); err != nil {
+		return nil, err
+	}
+	if !r.accept("DISTINCT") {
+		r.accept("ALL")
+	}
+
+	items := make([]*selectItem, 0)
+	for {
+		item := r.until(",", "FROM")
+		n := len(item)
+		switch {
+		case n == 0:
+			return nil, errors.New("ORA-00936: missing expression")
+		case item[n-1].Text == "*":
+			items = append(items, &selectItem{name: "*", expr: item})
+		case !item[n-1].IsIdentifier || n == 1:
+			items = append(items, &selectItem{name: sqlText(item), expr: item})
+		case item[n-2].Text == "." && n == 3:
+			// a qualified column ('e.name')
+			items = append(items, &selectItem{name: item[n-1].Text, expr: item})
+		case item[n-2].Text == "AS":
+			items = append(items, &selectItem{name: item[n-1].Text, expr: item[:n-2]})
+		case item[n-2].IsIdentifier, item[n-2].IsString, item[n-2].Text == ")", item[n-2].Text == "END":
+			// an alias ('sal * 2 bonus')
+			items = append(items, &selectItem{name: item[n-1].Text, expr: item[:n-1]})
+		default:
+			items = append(items, &selectItem{name: sqlText(item), expr: item})
+		}
+
+		if !r.accept(",") {
+			return items, nil
+		}
+	}
+}
+
+// selectListNames returns the names of the columns a query selects
+// the columns of '*' aren't known without a schema
+func selectListNames(tokens []SqlToken) ([]string, error) {
+	items, err := selectListItems(tokens)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, len(items))
+	for idx, item := range items {
+		if item.isStar() {
+			return nil, errors.New("ORA-03001: unimplemented feature, the columns of '*' aren't known without a schema")
+		}
+		names[idx] = item.name
+	}
+	return names, nil
+}
+
+// translateMerge rewrites a MERGE into an UPDATE of the rows that match and an INSERT of the rows that don't
+//
+//	MERGE INTO t a USING s b ON (cond)
+//	WHEN MATCHED THEN UPDATE SET ... [WHERE ...]
+//	WHEN NOT MATCHED THEN INSERT (cols) VALUES (vals) [WHERE ...]
+//
+// becomes
+//
+//	UPDATE t AS a SET ... FROM s AS b WHERE (cond) [AND (...)]
+//	INSERT INTO t (cols) SELECT vals FROM s AS b WHERE NOT EXISTS (SELECT 1 FROM t AS a WHERE (cond)) [AND (...)]
+//
+// rows that are inserted don't match the update as it runs first
+func translateMerge(tokens []SqlToken) ([][]SqlToken, error) {
+	r := &sqlTokenReader{tokens: tokens}
+	if err := r.expect("MERGE", "INTO"); err != nil {
+		return nil, err
+	}
+	target, targetAlias := splitAlias(r.until("USING"))
+	if err := r.expect("USING"); err != nil {
+		return nil, err
+	}
+	source, sourceAlias := splitAlias(r.until("ON"))
+	if err := r.expect("ON"); err != nil {
+		return nil, err
+	}
+	cond := r.until("WHEN")
+	if len(target) == 0 || len(source) == 0 || len(cond) == 0 {
+		return nil, errors.New("ORA-00900: invalid SQL statement, MERGE needs a target, a source and a condition")
+	}
+
+	statements := make([][]SqlToken, 0, 2)
+	for r.accept("WHEN") {
+		if r.accept("MATCHED") {
+			if err := r.expect("THEN", "UPDATE", "SET"); err != nil {
+				return nil, err
+			}
+			set := stripQualifiers(r.until("WHERE", "DELETE", "WHEN"))
+			update := joinTokens(sqlWords("UPDATE"), withAlias(target, targetAlias), sqlWords("SET"), set,
+				sqlWords("FROM"), withAlias(source, sourceAlias), sqlWords("WHERE"), cond)
+			if r.accept("WHERE") {
+				update = joinTokens(update, sqlWords("AND", "("), r.until("DELETE", "WHEN"), sqlWords(")"))
+			}
+			if r.peekIs("DELETE") {
+				return nil, errors.New("ORA-03001: unimplemented feature, DELETE in MERGE")
+			}
+			statements = append(statements, update)
+			continue
+		}
+
+		if err := r.expect("NOT", "MATCHED", "THEN", "INSERT"); err != nil {
+			return nil, err
+		}
+		var columns []SqlToken
+		if r.peekIs("(") {
+			columns = joinTokens(sqlWords("("), stripQualifiers(unwrap(r.until("VALUES"))), sqlWords(")"))
+		}
+		if err := r.expect("VALUES"); err != nil {
+			return nil, err
+		}
+		values := unwrap(r.until("WHERE", "WHEN"))
+		insert := joinTokens(sqlWords("INSERT", "INTO"), target, columns, sqlWords("SELECT"), values,
+			sqlWords("FROM"), withAlias(source, sourceAlias), sqlWords("WHERE", "NOT", "EXISTS", "(", "SELECT", "1", "FROM"),
+			withAlias(target, targetAlias), sqlWords("WHERE"), cond, sqlWords(")"))
+		if r.accept("WHERE") {
+			insert = joinTokens(insert, sqlWords("AND", "("), r.until("WHEN"), sqlWords(")"))
+		}
+		statements = append(statements, insert)
+	}
+
+	if r.pos < len(tokens) || len(statements) == 0 {
+		return nil, errors.New("ORA-00905: missing keyword WHEN")
+	}
+	return statements, nil
+}
@@ -18,21 +18,22 @@ package ast
 
 import (
 	"fmt"
+	"strings"
 
-	"github.com/llir/llvm/ir/constant"
-	"github.com/llir/llvm/ir/types"
 	"github.com/llir/llvm/ir/value"
 	"github.com/mhelmich/plsqlc/runtime"
 )
 
+// NewStringLiteral creates a literal from its quoted text, two quotes in a row stand for one
 func NewStringLiteral(value string) *StringLiteral {
-	value = value[1 : len(value)-1]
+	value = strings.Replace(value[1:len(value)-1], "''", "'", -1)
 	return &StringLiteral{
 		Value: value,
 	}
 }
 
 type StringLiteral struct {
+	typed
 	Value string
 }
 
@@ -42,18 +43,9 @@ func (sl *StringLiteral) expressionType() expressionType {
 
 func (sl *StringLiteral) GenIR(cc *CompilerContext) value.Value {
 	stringType := cc.getTypeByName(runtime.StringTypeName)
-
-	strStruct := cc.currentLlvmBlock.NewAlloca(stringType)
-	dataPtr := cc.currentLlvmBlock.NewGetElementPtr(strStruct, llvmZero, llvmZero)
-	lenPtr := cc.currentLlvmBlock.NewGetElementPtr(strStruct, llvmZero, llvmOne)
-	cc.currentLlvmBlock.NewStore(constant.NewInt(types.I64, int64(len(sl.Value))), lenPtr)
-
-	a := cc.currentLlvmBlock.NewAlloca(types.NewArray(uint64(len(sl.Value)), types.I8))
-	cc.currentLlvmBlock.NewStore(constant.NewCharArrayFromString(sl.Value), a)
-	strPtr := cc.currentLlvmBlock.NewBitCast(a, types.NewPointer(types.I8))
-	cc.currentLlvmBlock.NewStore(strPtr, dataPtr)
-
-	return strStruct
+	// in the entry block, a literal in a loop would grow the stack with every iteration otherwise
+	strStruct := cc.newEntryAlloca(stringType)
+	return runtime.MakeString(cc.llvmModule, sl.Value, cc.currentLlvmBlock, strStruct)
 }
 
 func (sl *StringLiteral) String() string {
@@ -0,0 +1,105 @@
+/*
+ * Copyright 2019 Marco Helmich
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ast
+
+import (
+	"fmt"
+
+	"github.com/llir/llvm/ir/value"
+	"github.com/mhelmich/plsqlc/runtime"
+)
+
+// NewCommit creates a 'COMMIT' statement
+func NewCommit() *Commit {
+	return &Commit{}
+}
+
+// Commit makes the changes of the current transaction permanent and starts a new one
+type Commit struct{}
+
+func (c *Commit) GenIR(cc *CompilerContext) value.Value {
+	runtime.GenerateSQLInModule(cc.llvmModule)
+	cc.currentLlvmBlock.NewCall(cc.getFuncByName(runtime.SqlCommitFuncName))
+	return nil
+}
+
+func (c *Commit) String() string {
+	return "<commit>"
+}
+
+// NewRollback creates a 'ROLLBACK' statement, savepoint is empty if the whole transaction is rolled back
+func NewRollback(savepoint string) *Rollback {
+	return &Rollback{
+		Savepoint: savepoint,
+	}
+}
+
+// Rollback undoes the changes of the current transaction ('ROLLBACK')
+// or the changes since a savepoint ('ROLLBACK TO sp'), the savepoint stays in place
+type Rollback struct {
+	Savepoint string
+}
+
+func (r *Rollback) GenIR(cc *CompilerContext) value.Value {
+	runtime.GenerateSQLInModule(cc.llvmModule)
+	if r.Savepoint == "" {
+		cc.currentLlvmBlock.NewCall(cc.getFuncByName(runtime.SqlRollbackFuncName))
+		return nil
+	}
+
+	text := runtime.NewConstantString(cc.llvmModule, fmt.Sprintf("_sql.%d", len(cc.llvmModule.Globals)), fmt.Sprintf("ROLLBACK TO \"%s\"", r.Savepoint))
+	message := runtime.NewConstantString(cc.llvmModule, fmt.Sprintf("_sql.%d", len(cc.llvmModule.Globals)), fmt.Sprintf(runtime.NoSuchSavepointMessage, r.Savepoint))
+	cc.currentLlvmBlock.NewCall(cc.getFuncByName(runtime.SqlRollbackToFuncName), text, message)
+	return nil
+}
+
+func (r *Rollback) String() string {
+	if r.Savepoint == "" {
+		return "<rollback>"
+	}
+	return fmt.Sprintf("<rollback> TO %s", r.Savepoint)
+}
+
+// NewSavepoint creates a 'SAVEPOINT name' statement
+func NewSavepoint(name string) *Savepoint {
+	return &Savepoint{
+		Name: name,
+	}
+}
+
+// Savepoint marks a point in the current transaction that can be rolled back to
+type Savepoint struct {
+	Name string
+}
+
+func (s *Savepoint) GenIR(cc *CompilerContext) value.Value {
+	runtime.GenerateSQLInModule(cc.llvmModule)
+	text := runtime.NewConstantString(cc.llvmModule, fmt.Sprintf("_sql.%d", len(cc.llvmModule.Globals)), fmt.Sprintf("SAVEPOINT \"%s\"", s.Name))
+	cc.currentLlvmBlock.NewCall(cc.getFuncByName(runtime.SqlSavepointFuncName), text)
+	return nil
+}
+
+func (s *Savepoint) String() string {
+	return fmt.Sprintf("<savepoint> %s", s.Name)
+}
+
+// endAutonomousTransaction leaves the autonomous transaction of the current function before it returns
+func endAutonomousTransaction(cc *CompilerContext) {
+	if cc.autonomousCaller != nil {
+		cc.currentLlvmBlock.NewCall(cc.getFuncByName(runtime.SqlEndAutonomousFuncName), cc.autonomousCaller)
+	}
+}
@@ -0,0 +1,204 @@
+/*
+ * Copyright 2019 Marco Helmich
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ast
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/llir/llvm/ir/types"
+	"github.com/mhelmich/plsqlc/runtime"
+)
+
+type TypeDeclaration interface {
+	GenIR(cc *CompilerContext) types.Type
+	String() string
+}
+
+func NewSubtype(name string, baseType string) *Subtype {
+	return &Subtype{
+		Name:     name,
+		BaseType: baseType,
+	}
+}
+
+// Subtype is an alias for another type ('SUBTYPE name IS base')
+type Subtype struct {
+	Name     string
+	BaseType string
+}
+
+func (st *Subtype) GenIR(cc *CompilerContext) types.Type {
+	qualifiedName := cc.currentPackageName + "." + st.Name
+	cc.subtypes[qualifiedName] = cc.qualifyTypeName(st.BaseType)
+	return cc.llvmTypeFor(qualifiedName)
+}
+
+func (st *Subtype) String() string {
+	return fmt.Sprintf("<subtype> %s IS %s", st.Name, st.BaseType)
+}
+
+func NewRefCursor(name string, returnType string) *RefCursor {
+	return &RefCursor{
+		Name:       name,
+		ReturnType: returnType,
+	}
+}
+
+// RefCursor is a type of cursor variables ('TYPE name IS REF CURSOR [RETURN rec]')
+// strong ref cursors have a return type, they can only be opened for queries that select their record
+// variables of all ref cursor types are pointers to cursors like SYS_REFCURSOR
+type RefCursor struct {
+	Name       string
+	ReturnType string
+}
+
+func (rc *RefCursor) GenIR(cc *CompilerContext) types.Type {
+	qualifiedName := cc.currentPackageName + "." + rc.Name
+	cc.subtypes[qualifiedName] = RefCursorType.Name
+	return cc.llvmTypeFor(qualifiedName)
+}
+
+func (rc *RefCursor) String() string {
+	if rc.ReturnType == "" {
+		return fmt.Sprintf("<ref cursor> %s", rc.Name)
+	}
+	return fmt.Sprintf("<ref cursor> %s RETURN %s", rc.Name, rc.ReturnType)
+}
+
+func NewRecordType(name string) *RecordType {
+	return &RecordType{
+		Name:   name,
+		Fields: make([]*RecordField, 0),
+	}
+}
+
+// RecordType is a user defined record ('TYPE name IS RECORD (...)')
+// that is lowered to a named llvm struct
+type RecordType struct {
+	Name   string
+	Fields []*RecordField
+}
+
+type RecordField struct {
+	Name string
+	Type string
+}
+
+func (rt *RecordType) AddField(name string, t string) {
+	rt.Fields = append(rt.Fields, &RecordField{
+		Name: name,
+		Type: t,
+	})
+}
+
+func (rt *RecordType) fieldIndex(name string) int {
+	for idx := range rt.Fields {
+		if rt.Fields[idx].Name == name {
+			return idx
+		}
+	}
+	return -1
+}
+
+func (rt *RecordType) GenIR(cc *CompilerContext) types.Type {
+	return rt.declare(cc, cc.currentPackageName+"."+rt.Name)
+}
+
+// genIRForRow declares the record of a row of a table ('emp%ROWTYPE')
+// all packages share it, it isn't qualified by the name of a package
+func (rt *RecordType) genIRForRow(cc *CompilerContext) types.Type {
+	if t, ok := cc.recordTypes[rt.Name]; ok {
+		return t
+	}
+	return rt.declare(cc, rt.Name)
+}
+
+func (rt *RecordType) declare(cc *CompilerContext, name string) types.Type {
+	fieldTypes := make([]types.Type, len(rt.Fields))
+	for idx := range rt.Fields {
+		fieldTypes[idx] = cc.llvmTypeFor(rt.Fields[idx].Type)
+	}
+
+	t := cc.llvmModule.NewTypeDef(name, types.NewStruct(fieldTypes...))
+	cc.records[name] = rt
+	cc.recordTypes[name] = t
+	return t
+}
+
+func (rt *RecordType) String() string {
+	var sb strings.Builder
+	sb.WriteString("<record> ")
+	sb.WriteString(rt.Name)
+	sb.WriteString(" (")
+	for idx := range rt.Fields {
+		sb.WriteString(rt.Fields[idx].Name)
+		sb.WriteString(" ")
+		sb.WriteString(rt.Fields[idx].Type)
+		sb.WriteString(",")
+	}
+	sb.WriteString(")")
+	return sb.String()
+}
+
+func NewCollectionType(name string, elementType string) *CollectionType {
+	return &CollectionType{
+		Name:        name,
+		ElementType: elementType,
+	}
+}
+
+// CollectionType is a nested table ('TYPE name IS TABLE OF t'), a varray ('TYPE name IS VARRAY(n) OF t')
+// or an associative array ('TYPE name IS TABLE OF t INDEX BY PLS_INTEGER')
+// variables of all collection types are pointers to the collections of the runtime
+type CollectionType struct {
+	Name        string
+	ElementType string
+	// the maximum size of a varray
+	Limit         int64
+	IsVarray      bool
+	IsAssociative bool
+	// the type of the elements, set by the checker
+	element *Type
+}
+
+func (ct *CollectionType) GenIR(cc *CompilerContext) types.Type {
+	qualifiedName := cc.currentPackageName + "." + ct.Name
+	cc.collections[qualifiedName] = ct
+	return cc.llvmTypeFor(qualifiedName)
+}
+
+// kind tells the runtime which indexes the collection has
+func (ct *CollectionType) kind() int64 {
+	switch {
+	case ct.IsAssociative:
+		return runtime.CollectionAssociative
+	case ct.IsVarray:
+		return runtime.CollectionVarray
+	}
+	return runtime.CollectionNestedTable
+}
+
+func (ct *CollectionType) String() string {
+	switch {
+	case ct.IsAssociative:
+		return fmt.Sprintf("<collection> %s TABLE OF %s INDEX BY PLS_INTEGER", ct.Name, ct.ElementType)
+	case ct.IsVarray:
+		return fmt.Sprintf("<collection> %s VARRAY(%d) OF %s", ct.Name, ct.Limit, ct.ElementType)
+	}
+	return fmt.Sprintf("<collection> %s TABLE OF %s", ct.Name, ct.ElementType)
+}
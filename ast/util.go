@@ -24,11 +24,22 @@ import (
 )
 
 func plsqlTypeToLLVMType(t string) types.Type {
-	switch t {
-	case "INT":
+	builtin, ok := builtinType(t)
+	if !ok {
+		log.Panicf("Type '%s' is not implemented yet", t)
+	}
+
+	switch {
+	case builtin.isInt64():
 		return types.I64
-	case "VARCHAR":
+	case builtin.isDouble():
+		return types.Double
+	case builtin.IsString():
 		return runtime.StringType
+	case builtin.Equal(RefCursorType):
+		return types.NewPointer(runtime.CursorType)
+	case builtin.IsCollection():
+		return types.NewPointer(runtime.CollectionType)
 	default:
 		log.Panicf("Type '%s' is not implemented yet", t)
 	}
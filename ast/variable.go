@@ -29,22 +29,60 @@ func NewVariable(name string) *Variable {
 	}
 }
 
+// NewQualifiedVariable creates a reference to a field of a record ('rec.field')
+// or a variable of a package ('pkg.var')
+func NewQualifiedVariable(qualifier string, name string) *Variable {
+	return &Variable{
+		Qualifier: qualifier,
+		Name:      name,
+	}
+}
+
 type Variable struct {
-	Name string
+	typed
+	Qualifier string
+	Name      string
+	// set by the checker if the variable is a method of a collection ('list.COUNT')
+	method *collectionMethod
 }
 
 func (v *Variable) expressionType() expressionType {
 	return variableExpression
 }
 
+// address returns a pointer to the memory the variable lives in
+func (v *Variable) address(cc *CompilerContext) value.Value {
+	if v.Qualifier == "" {
+		mem, ok := cc.findVariable(v.Name)
+		if !ok {
+			log.Panicf("Can't find '%s' in scope", v.Name)
+		}
+		return mem
+	}
+
+	// names of variables shadow names of packages
+	if rec, ok := cc.findVariable(v.Qualifier); ok {
+		return cc.fieldAddress(rec, v.Name)
+	}
+
+	if v.Qualifier != cc.currentPackageName {
+		if pkg, ok := cc.packages[v.Qualifier]; ok && !pkg.isPublicVariable(v.Name) {
+			log.Panicf("PLS-00302: component '%s' must be declared, it is private to package '%s'", v.Name, v.Qualifier)
+		}
+	}
+	return cc.getGlobalByName(v.Qualifier + "." + v.Name)
+}
+
 func (v *Variable) GenIR(cc *CompilerContext) value.Value {
-	mem, ok := cc.scopes.findMember(v.Name)
-	if !ok {
-		log.Panicf("Can't find '%s' in scope", v.Name)
+	if v.method != nil {
+		return v.method.genIR(cc)
 	}
-	return cc.currentLlvmBlock.NewLoad(mem)
+	return cc.currentLlvmBlock.NewLoad(v.address(cc))
 }
 
 func (v *Variable) String() string {
+	if v.Qualifier != "" {
+		return fmt.Sprintf("<variable> %s.%s", v.Qualifier, v.Name)
+	}
 	return fmt.Sprintf("<variable> %s", v.Name)
 }
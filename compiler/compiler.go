@@ -17,10 +17,14 @@
 package compiler
 
 import (
+	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/llir/llvm/ir"
 	"github.com/mhelmich/plsqlc/ast"
@@ -29,43 +33,207 @@ import (
 	"github.com/mhelmich/plsqlc/runtime"
 )
 
-func Compile(inputPath string, outputPath string, printIR bool, deleteLlvmIR bool) {
-	mod := ir.NewModule()
-	runtime.GenerateInModule(mod)
-	mod = compileCode(inputPath, mod)
-	runtime.GenerateMain(mod)
+// file extensions of source files that are picked up when compiling a directory
+var sourceFileExtensions = map[string]bool{
+	".sql": true,
+	".pks": true,
+	".pkb": true,
+	".pls": true,
+	".plb": true,
+	".pck": true,
+}
 
-	// tmpFile, err := ioutil.TempFile("", "_plsqlc")
-	tmpFile, err := os.Create("_temp_llvm_.ll")
-	if err != nil {
-		log.Panicf("%s", err.Error())
+// ExpandInputPaths turns a list of files, directories and glob patterns
+// into a sorted list of source files
+// directories are searched recursively for files with a known extension
+func ExpandInputPaths(paths []string) ([]string, error) {
+	files := make(map[string]bool)
+	for _, path := range paths {
+		matches := []string{path}
+		if strings.ContainsAny(path, "*?[") {
+			var err error
+			matches, err = filepath.Glob(path)
+			if err != nil {
+				return nil, err
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("Pattern '%s' doesn't match any file", path)
+			}
+		}
+
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			if err != nil {
+				return nil, fmt.Errorf("File '%s' doesn't exist", match)
+			}
+
+			if !info.IsDir() {
+				files[match] = true
+				continue
+			}
+
+			err = filepath.Walk(match, func(p string, info os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				if !info.IsDir() && sourceFileExtensions[strings.ToLower(filepath.Ext(p))] {
+					files[p] = true
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
 	}
 
-	fileName := tmpFile.Name()
-	tmpFile.Close()
-	if deleteLlvmIR {
-		defer os.Remove(fileName)
+	sorted := make([]string, 0, len(files))
+	for f := range files {
+		sorted = append(sorted, f)
 	}
+	sort.Strings(sorted)
+	return sorted, nil
+}
 
-	ir := mod.String()
+// Options control how a program is built
+type Options struct {
+	// all source files of the program
+	InputPaths []string
+	OutputPath string
+	// name of the package whose MAIN procedure is the entry point of the program
+	Entry string
+	// optimization level passed to clang (0, 1, 2, 3, s or z)
+	OptLevel string
+	// libraries the program is linked against
+	Libraries []string
+	// the database driver embedded SQL runs on
+	// either a driver that ships with plsqlc ("sqlite", "stub")
+	// or an object file, archive or llvm ir file that implements the driver interface of the runtime
+	Driver string
+	// path of the DDL of the database ('CREATE TABLE ...'), embedded SQL is checked against its tables if it is set
+	Schema string
+	// values of inquiry directives such as $$DEBUG
+	Defines map[string]string
+	// whether or not the program carries DWARF debug info that maps it back to its PL/SQL source
+	Debug        bool
+	PrintIR      bool
+	DeleteLlvmIR bool
+}
 
-	if printIR {
-		log.Printf("%s", ir)
+// NewOptions returns options with the defaults for all optional settings
+func NewOptions(inputPaths []string, outputPath string) *Options {
+	return &Options{
+		InputPaths:   inputPaths,
+		OutputPath:   outputPath,
+		Entry:        defaultEntry,
+		OptLevel:     defaultOptLevel,
+		Driver:       runtime.SqliteDriver,
+		Defines:      make(map[string]string),
+		DeleteLlvmIR: true,
 	}
+}
+
+// Compile builds one program out of all input files that runs its embedded SQL on driver
+// specs and bodies of a package can live in different files
+func Compile(inputPaths []string, outputPath string, driver string, printIR bool, deleteLlvmIR bool) {
+	opts := NewOptions(inputPaths, outputPath)
+	opts.Driver = driver
+	opts.PrintIR = printIR
+	opts.DeleteLlvmIR = deleteLlvmIR
+	Build(opts)
+}
 
-	err = ioutil.WriteFile(fileName, []byte(ir), 0644)
+// Parse reads all input files and merges them into one package per name
+func Parse(opts *Options) map[string]*ast.Package {
+	// map[string]*ast.Package
+	namesToPackages := make(map[string]*ast.Package)
+	for _, in := range opts.InputPaths {
+		if err := ast.MergePackages(namesToPackages, parseFile(in, opts.Defines)); err != nil {
+			log.Panicf("%s: %s", in, err.Error())
+		}
+	}
+	return namesToPackages
+}
+
+// Check runs the semantic analysis of the program without producing any output
+func Check(opts *Options) {
+	checkProgram(opts)
+}
+
+// checkProgram parses the program and resolves all of its names and types
+// errors are reported before any llvm ir is generated
+func checkProgram(opts *Options) map[string]*ast.Package {
+	namesToPackages := Parse(opts)
+	mainPkg, ok := namesToPackages[opts.Entry]
+	if !ok {
+		log.Panicf("Can't find '%s' package", strings.ToLower(opts.Entry))
+	}
+	if !mainPkg.HasMainFunction() {
+		log.Panicf("Can't find 'main' function")
+	}
+
+	diagnostics := ast.CheckWithSchema(namesToPackages, loadSchema(opts.Schema))
+	if len(diagnostics) > 0 {
+		for idx := range diagnostics {
+			log.Printf("%s", diagnostics[idx].String())
+		}
+		log.Panicf("Found %d error(s)", len(diagnostics))
+	}
+	return namesToPackages
+}
+
+// GenerateIR compiles the program into a llvm module
+func GenerateIR(opts *Options) *ir.Module {
+	mod := ir.NewModule()
+	runtime.GenerateInModule(mod)
+	mod, initFuncNames := compileCode(opts, mod)
+	runtime.GenerateMain(mod, opts.Entry+".MAIN", initFuncNames)
+	return mod
+}
+
+// EmitIR writes the llvm ir of the program to the output path
+func EmitIR(opts *Options) {
+	writeIR(opts, GenerateIR(opts), opts.OutputPath)
+}
+
+// Build compiles and links a program as described by the options
+func Build(opts *Options) {
+	mod := GenerateIR(opts)
+
+	// builds that run at the same time don't share their intermediate files
+	tmpDir, err := ioutil.TempDir("", "plsqlc")
 	if err != nil {
 		log.Panicf("%s", err.Error())
 	}
+	if opts.DeleteLlvmIR {
+		defer os.RemoveAll(tmpDir)
+	} else {
+		log.Printf("LLVM IR is kept in %s\n", tmpDir)
+	}
+
+	fileName := filepath.Join(tmpDir, "program.ll")
+	writeIR(opts, mod, fileName)
 
 	clangArgs := []string{
 		fileName,               // input path of temp file
 		"-Wno-override-module", // disable override target triple warnings
-		"-o", outputPath,       // output path
-		"-O3",
+		"-o", opts.OutputPath,  // output path
+		"-O" + opts.OptLevel,
+	}
+	if opts.Debug {
+		clangArgs = append(clangArgs, "-g")
+	}
+	for idx := range opts.Libraries {
+		clangArgs = append(clangArgs, "-l"+opts.Libraries[idx])
+	}
+	// the runtime uses the math library
+	clangArgs = append(clangArgs, "-lm")
+	// embedded SQL runs on the driver that is linked into the program
+	if runtime.UsesSQL(mod) {
+		clangArgs = append(clangArgs, driverArgs(opts, tmpDir)...)
 	}
 
-	if printIR {
+	if opts.PrintIR {
 		log.Printf("clang %v\n", clangArgs)
 	}
 
@@ -81,7 +249,101 @@ func Compile(inputPath string, outputPath string, printIR bool, deleteLlvmIR boo
 	}
 }
 
-func compileCode(in string, mod *ir.Module) *ir.Module {
+// driverArgs returns the clang arguments that link the driver into the program
+// the llvm ir of drivers that ship with plsqlc is written to a file in tmpDir
+func driverArgs(opts *Options, tmpDir string) []string {
+	if !runtime.IsBuiltinDriver(opts.Driver) {
+		if _, err := os.Stat(opts.Driver); err != nil {
+			log.Panicf("Can't find driver '%s'", opts.Driver)
+		}
+		return []string{opts.Driver}
+	}
+
+	fileName := filepath.Join(tmpDir, "driver.ll")
+	writeIR(opts, runtime.GenerateDriver(opts.Driver), fileName)
+
+	args := []string{fileName}
+	libraries := runtime.DriverLibraries(opts.Driver)
+	for idx := range libraries {
+		args = append(args, "-l"+libraries[idx])
+	}
+	return args
+}
+
+func writeIR(opts *Options, mod *ir.Module, fileName string) {
+	ir := mod.String()
+
+	if opts.PrintIR {
+		log.Printf("%s", ir)
+	}
+
+	err := ioutil.WriteFile(fileName, []byte(ir), 0644)
+	if err != nil {
+		log.Panicf("%s", err.Error())
+	}
+}
+
+// compileCode generates llvm ir for the program in the input files
+// and returns the names of all package initialization functions
+func compileCode(opts *Options, mod *ir.Module) (*ir.Module, []string) {
+	namesToPackages := checkProgram(opts)
+
+	cc := ast.NewCompilerContext(mod)
+	cc.SetPackages(namesToPackages)
+	if opts.Debug {
+		cc.EnableDebugInfo(opts.InputPaths[0])
+	}
+	pkgs := ast.SortPackages(namesToPackages)
+	// first declare the types and variables of all packages
+	for idx := range pkgs {
+		if err := pkgs[idx].GenIRForDeclarations(cc); err != nil {
+			log.Panicf("%s", err.Error())
+		}
+	}
+	// secondly declare the functions of all packages
+	// that way calls resolve regardless of the order of packages
+	for idx := range pkgs {
+		pkgs[idx].GenIRForProtos(cc)
+	}
+	// thirdly compile all the code
+	for idx := range pkgs {
+		if err := pkgs[idx].GenIR(cc); err != nil {
+			log.Panicf("%s", err.Error())
+		}
+	}
+
+	initPkgs, err := ast.InitOrder(namesToPackages)
+	if err != nil {
+		log.Panicf("%s", err.Error())
+	}
+
+	initFuncNames := make([]string, len(initPkgs))
+	for idx := range initPkgs {
+		initFuncNames[idx] = initPkgs[idx].GetInitFunctionName()
+	}
+	return cc.GetIRModule(), initFuncNames
+}
+
+// loadSchema reads the tables of the database from a file of DDL statements
+// there is no schema if path is empty
+func loadSchema(path string) *ast.Schema {
+	if path == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		log.Panicf("failed reading schema: %s", err)
+	}
+
+	_, items := lexer.NewLexer(path, string(data))
+	schema, err := parser.ParseSchema(items)
+	if err != nil {
+		log.Panicf("%s:%s", path, err.Error())
+	}
+	return schema
+}
+
+func parseFile(in string, defines map[string]string) map[string]*ast.Package {
 	file, err := os.Open(in)
 	if err != nil {
 		log.Panicf("failed reading file: %s", err)
@@ -92,21 +354,14 @@ func compileCode(in string, mod *ir.Module) *ir.Module {
 		log.Panicf("%s", err.Error())
 	}
 
-	_, items := lexer.NewLexer("", string(data))
-	p := parser.NewParser(items)
-	// map[string]*ast.Package
-	namesToPackages := p.GetPackageAsts()
-	for name, pkg := range namesToPackages {
-		if name == "MAIN" {
-			if !pkg.HasMainFunction() {
-				log.Panicf("Can't find 'main' function")
-			}
-			cc := ast.NewCompilerContext(mod)
-			pkg.GenIR(cc)
-			return cc.GetIRModule()
-		}
+	_, items := lexer.NewLexer(in, string(data))
+	p := parser.NewParser(resolveInquiryDirectives(items, defines))
+	pkgs, err := p.GetPackageAsts()
+	if err != nil {
+		log.Panicf("%s:%s", in, err.Error())
 	}
-
-	log.Panicf("Can't find 'main' package")
-	return nil
+	for _, pkg := range pkgs {
+		pkg.SetSourceFile(in)
+	}
+	return pkgs
 }
@@ -17,10 +17,17 @@
 package compiler
 
 import (
+	"io/ioutil"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/mhelmich/plsqlc/ast"
+	"github.com/mhelmich/plsqlc/lexer"
+	"github.com/mhelmich/plsqlc/runtime"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -28,14 +35,14 @@ var printIR = false
 var deleteTmpFile = true
 
 func TestBasic(t *testing.T) {
-	Compile("../examples/test.sql", "./test", false, false)
+	Compile([]string{"../examples/test.sql"}, "./test", runtime.SqliteDriver, false, false)
 	defer os.Remove("test")
 }
 
 var fixture1Output = "Hello World!\n99\n"
 
 func TestFixture1(t *testing.T) {
-	Compile("./test01.sql", "./test", printIR, deleteTmpFile)
+	Compile([]string{"./test01.sql"}, "./test", runtime.SqliteDriver, printIR, deleteTmpFile)
 	output, err := executeBinary("./test")
 	assert.Equal(t, fixture1Output, output)
 	assert.Nil(t, err)
@@ -48,7 +55,7 @@ func TestFixture1(t *testing.T) {
 var fixture2Output = "is_narf\n"
 
 func TestFixture2(t *testing.T) {
-	Compile("./test02.sql", "./test", printIR, deleteTmpFile)
+	Compile([]string{"./test02.sql"}, "./test", runtime.SqliteDriver, printIR, deleteTmpFile)
 	output, err := executeBinary("./test")
 	assert.Equal(t, fixture2Output, output)
 	assert.Nil(t, err)
@@ -61,7 +68,7 @@ func TestFixture2(t *testing.T) {
 var fixture3Output = "15\n14\n13\n12\n11\n"
 
 func TestFixture3(t *testing.T) {
-	Compile("./test03.sql", "./test", printIR, deleteTmpFile)
+	Compile([]string{"./test03.sql"}, "./test", runtime.SqliteDriver, printIR, deleteTmpFile)
 	output, err := executeBinary("./test")
 	assert.Equal(t, fixture3Output, output)
 	assert.Nil(t, err)
@@ -74,7 +81,7 @@ func TestFixture3(t *testing.T) {
 var fixture4Output = "10\n"
 
 func TestFixture4(t *testing.T) {
-	Compile("./test04.sql", "./test", printIR, deleteTmpFile)
+	Compile([]string{"./test04.sql"}, "./test", runtime.SqliteDriver, printIR, deleteTmpFile)
 	output, err := executeBinary("./test")
 	assert.Equal(t, fixture4Output, output)
 	assert.Nil(t, err)
@@ -87,7 +94,7 @@ func TestFixture4(t *testing.T) {
 var fixture5Output = "is_15\nend\n"
 
 func TestFixture5(t *testing.T) {
-	Compile("./test05.sql", "./test", printIR, deleteTmpFile)
+	Compile([]string{"./test05.sql"}, "./test", runtime.SqliteDriver, printIR, deleteTmpFile)
 	output, err := executeBinary("./test")
 	assert.Equal(t, fixture5Output, output)
 	assert.Nil(t, err)
@@ -100,7 +107,7 @@ func TestFixture5(t *testing.T) {
 var fixture6Output = "Hello_from_P1!\n"
 
 func TestFixture6(t *testing.T) {
-	Compile("./test06.sql", "./test", printIR, deleteTmpFile)
+	Compile([]string{"./test06.sql"}, "./test", runtime.SqliteDriver, printIR, deleteTmpFile)
 	output, err := executeBinary("./test")
 	assert.Equal(t, fixture6Output, output)
 	assert.Nil(t, err)
@@ -110,8 +117,645 @@ func TestFixture6(t *testing.T) {
 	assert.Nil(t, err)
 }
 
+var fixture7Output = "42\n42\n"
+
+func TestFixture7(t *testing.T) {
+	Compile([]string{"./test07.sql"}, "./test", runtime.SqliteDriver, printIR, deleteTmpFile)
+	output, err := executeBinary("./test")
+	assert.Equal(t, fixture7Output, output)
+	assert.Nil(t, err)
+	err = os.Remove("./test")
+	assert.Nil(t, err)
+}
+
+var fixture8Output = "12\n3\nhello\n3\nconfigured\n"
+
+func TestFixture8(t *testing.T) {
+	Compile([]string{"./test08.sql"}, "./test", runtime.SqliteDriver, printIR, deleteTmpFile)
+	output, err := executeBinary("./test")
+	assert.Equal(t, fixture8Output, output)
+	assert.Nil(t, err)
+	err = os.Remove("./test")
+	assert.Nil(t, err)
+}
+
+var fixture9Output = "log:\nstart\n49\nlog:\ndone\n2\n"
+
+func TestFixture9(t *testing.T) {
+	Compile([]string{"./test09.sql"}, "./test", runtime.SqliteDriver, printIR, deleteTmpFile)
+	output, err := executeBinary("./test")
+	assert.Equal(t, fixture9Output, output)
+	assert.Nil(t, err)
+	err = os.Remove("./test")
+	assert.Nil(t, err)
+}
+
+func TestFixture10(t *testing.T) {
+	assert.Panics(t, func() {
+		Compile([]string{"./test10.sql"}, "./test", runtime.SqliteDriver, printIR, deleteTmpFile)
+	})
+}
+
+var fixture11Output = "hello\nworld\n"
+
+func TestFixture11(t *testing.T) {
+	paths, err := ExpandInputPaths([]string{"./test11"})
+	assert.Nil(t, err)
+	Compile(paths, "./test", runtime.SqliteDriver, printIR, deleteTmpFile)
+	output, err := executeBinary("./test")
+	assert.Equal(t, fixture11Output, output)
+	assert.Nil(t, err)
+	err = os.Remove("./test")
+	assert.Nil(t, err)
+}
+
+func TestExpandInputPaths(t *testing.T) {
+	paths, err := ExpandInputPaths([]string{"./test11"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"test11/greeter/greeter.pkb", "test11/greeter/greeter.pks", "test11/main.pkb"}, paths)
+
+	paths, err = ExpandInputPaths([]string{"./test11/greeter/*.pk?", "./test11/main.pkb", "./test01.sql"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"./test01.sql", "./test11/main.pkb", "test11/greeter/greeter.pkb", "test11/greeter/greeter.pks"}, paths)
+
+	_, err = ExpandInputPaths([]string{"./narf.sql"})
+	assert.NotNil(t, err)
+	_, err = ExpandInputPaths([]string{"./narf/*.sql"})
+	assert.NotNil(t, err)
+}
+
+var fixture12Output = "hello from the manifest\n9\n"
+
+func TestFixture12(t *testing.T) {
+	m, err := LoadManifest("./test12/plsqlc.json")
+	assert.Nil(t, err)
+	opts, err := m.Options()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"test12/src/app.pkb"}, opts.InputPaths)
+	assert.Equal(t, "test12/app", opts.OutputPath)
+	assert.Equal(t, "APP", opts.Entry)
+	assert.Equal(t, "2", opts.OptLevel)
+	assert.Equal(t, []string{"m"}, opts.Libraries)
+	Build(opts)
+	output, err := executeBinary("./test12/app")
+	assert.Equal(t, fixture12Output, output)
+	assert.Nil(t, err)
+	err = os.Remove("./test12/app")
+	assert.Nil(t, err)
+}
+
+func TestManifestDefaults(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "plsqlc")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	path := filepath.Join(tmpDir, ManifestFileName)
+	err = ioutil.WriteFile(path, []byte(`{"sources": ["src"]}`), 0644)
+	assert.Nil(t, err)
+	m, err := LoadManifest(path)
+	assert.Nil(t, err)
+	assert.Equal(t, "MAIN", m.Entry)
+	assert.Equal(t, "out", m.Output)
+	assert.Equal(t, "3", m.Optimization)
+	assert.Equal(t, "", m.Driver)
+	assert.Equal(t, "", m.Schema)
+	// drivers that don't ship with plsqlc are paths relative to the manifest
+	assert.Equal(t, runtime.StubDriver, m.resolveDriver(runtime.StubDriver))
+	assert.Equal(t, filepath.Join(tmpDir, "lib", "driver.o"), m.resolveDriver("lib/driver.o"))
+
+	err = ioutil.WriteFile(path, []byte(`{"sources": ["src"], "optimization": "9"}`), 0644)
+	assert.Nil(t, err)
+	_, err = LoadManifest(path)
+	assert.NotNil(t, err)
+
+	err = ioutil.WriteFile(path, []byte(`{"entry": "main"}`), 0644)
+	assert.Nil(t, err)
+	_, err = LoadManifest(path)
+	assert.NotNil(t, err)
+}
+
+func TestResolveInquiryDirective(t *testing.T) {
+	defines := map[string]string{"LEVEL": "2", "RATE": "0.25", "OWNER": "O'Brien", "LIMIT": "Inf"}
+	for name, expected := range map[string]*lexer.Item{
+		"$$LEVEL": {Typ: lexer.NumericType, Value: "2"},
+		"$$RATE":  {Typ: lexer.NumericType, Value: "0.25"},
+		// quotes are doubled so that the literal doesn't end early
+		"$$OWNER": {Typ: lexer.StringType, Value: "'O''Brien'"},
+		// floats Go can parse but PL/SQL can't stay strings
+		"$$LIMIT": {Typ: lexer.StringType, Value: "'Inf'"},
+		"$$NARF":  {Typ: lexer.ErrorType, Value: "Inquiry directive '$$NARF' isn't defined"},
+	} {
+		i := resolveInquiryDirective(&lexer.Item{Typ: lexer.InquiryDirectiveType, Value: name}, defines)
+		assert.Equal(t, expected.Typ, i.Typ, name)
+		assert.Equal(t, expected.Value, i.Value, name)
+	}
+}
+
+var fixture13Output = "3.5\n42\n8\n2.5\n.25\n8\nequal\n4\n-.5\n"
+
+func TestFixture13(t *testing.T) {
+	Compile([]string{"./test13.sql"}, "./test", runtime.SqliteDriver, printIR, deleteTmpFile)
+	output, err := executeBinary("./test")
+	assert.Equal(t, fixture13Output, output)
+	assert.Nil(t, err)
+	err = os.Remove("./test")
+	assert.Nil(t, err)
+}
+
+var fixture14Output = "before\nORA-06502: PL/SQL: numeric or value error: character to number conversion error\n"
+
+func TestFixture14(t *testing.T) {
+	Compile([]string{"./test14.sql"}, "./test", runtime.SqliteDriver, printIR, deleteTmpFile)
+	output, err := executeBinary("./test")
+	assert.Equal(t, fixture14Output, output)
+	assert.NotNil(t, err)
+	err = os.Remove("./test")
+	assert.Nil(t, err)
+}
+
+var fixture15Output = "padded equal\nchar equal\nnot equal\nabcde\nORA-06502: PL/SQL: numeric or value error: character string buffer too small\n"
+
+func TestFixture15(t *testing.T) {
+	Compile([]string{"./test15.sql"}, "./test", runtime.SqliteDriver, printIR, deleteTmpFile)
+	output, err := executeBinary("./test")
+	assert.Equal(t, fixture15Output, output)
+	assert.NotNil(t, err)
+	err = os.Remove("./test")
+	assert.Nil(t, err)
+}
+
+var fixture16Output = "v is 42\nvalue of n is 2.5\nvalue of x is -.5\n01234\n[ab ]\ndone\n01234\n"
+
+func TestFixture16(t *testing.T) {
+	Compile([]string{"./test16.sql"}, "./test", runtime.SqliteDriver, printIR, deleteTmpFile)
+	output, err := executeBinary("./test")
+	assert.Nil(t, err)
+	assert.Equal(t, fixture16Output, output)
+	err = os.Remove("./test")
+	assert.Nil(t, err)
+}
+
+var fixture17Output = "11\n5\nWorld\nHello\nWor\n[]\n5\n8\n8\n0\n2\nHELLO WORLD\nhello world\nThe Quick Brown-Fox\n[padded]\nhixy\nxxyhi\n[ab]\n007\nab*-*-*\ntrunc\nHell0 W0rld\nba\nhippo\nAbc\nconcat\n65\n<65>\nORA-01428: argument is out of range\n"
+
+func TestFixture17(t *testing.T) {
+	Compile([]string{"./test17.sql"}, "./test", runtime.SqliteDriver, printIR, deleteTmpFile)
+	output, err := executeBinary("./test")
+	assert.Equal(t, fixture17Output, output)
+	assert.NotNil(t, err)
+	err = os.Remove("./test")
+	assert.Nil(t, err)
+}
+
+var fixture18Output = "5\n2.5\n1\n-1\n7\n1.5\n-1\n3\n-3\n3.14\n1300\n2\n3.7\n1200\n3\n-3\n5\n1024\n4\n-1\n1\n7\n1.5\n1\n0\n3\n1.01\n29\n3\n1.01\n1\n8\n5\nORA-01428: argument is out of range\n"
+
+func TestFixture18(t *testing.T) {
+	Compile([]string{"./test18.sql"}, "./test", runtime.SqliteDriver, printIR, deleteTmpFile)
+	output, err := executeBinary("./test")
+	assert.Equal(t, fixture18Output, output)
+	assert.NotNil(t, err)
+	err = os.Remove("./test")
+	assert.Nil(t, err)
+}
+
+var fixture19Output = "[ 1,234.50]\n[-1,234.50]\n[  .50]\n[ 0.50]\n[ 0042]\n[    42]\n[1,234.5]\n[1,234.50]\n[$12.35]\n[ +12]\n[ -12]\n[ 12+]\n[ 12-]\n[ 12 ]\n[12]\n[####]\n[7]\n1234.5\n-11.5\n42\nORA-01481: invalid number format model\n"
+
+func TestFixture19(t *testing.T) {
+	Compile([]string{"./test19.sql"}, "./test", runtime.SqliteDriver, printIR, deleteTmpFile)
+	output, err := executeBinary("./test")
+	assert.Equal(t, fixture19Output, output)
+	assert.NotNil(t, err)
+	err = os.Remove("./test")
+	assert.Nil(t, err)
+}
+
+var fixture20Output = "15-Mar-2019 01:45:30 PM\nMarch 15, 2019\nFRIDAY    FRI 6 074 1\n15-MAR-19\n2019-03-16 13:45:30\n2019-03-15 01:45:30\n2019-03-15 00:00:00\n2019-02-28\n2020-02-29\n2\n43.5732638888889\n2019\n3\n15\n15-MAR-19 01.45.30.250000 PM\n30.25\n+01 01:45:30.250000\n+02 03:31:00.500000\n1\n2019-03-16 15:31:00\n+01-06\n2020-07-31\n+01 12:00:00.000000\n-01-02\nlater\nequal\nORA-01847: day of month must be between 1 and last day of month\n"
+
+func TestFixture20(t *testing.T) {
+	Compile([]string{"./test20.sql"}, "./test", runtime.SqliteDriver, printIR, deleteTmpFile)
+	output, err := executeBinary("./test")
+	assert.Equal(t, fixture20Output, output)
+	assert.NotNil(t, err)
+	err = os.Remove("./test")
+	assert.Nil(t, err)
+}
+
+// the buffer of 2000 bytes overflows in the loop, the lines up to then are still written
+var fixture21Output = "Hello World!\nab\n42\n15-MAR-19\nprinted in order\ngot first 0, second 0, [] 1\ngot 2 one two, 1 three\nshown\nnot finished\nnever finished0123456789\n" +
+	strings.Repeat("0123456789\n", 177) + "ORA-20000: ORU-10027: buffer overflow, limit of 2000 bytes\n"
+
+func TestFixture21(t *testing.T) {
+	Compile([]string{"./test21.sql"}, "./test", runtime.SqliteDriver, printIR, deleteTmpFile)
+	output, err := executeBinary("./test")
+	assert.Equal(t, fixture21Output, output)
+	assert.NotNil(t, err)
+	err = os.Remove("./test")
+	assert.Nil(t, err)
+}
+
+var fixture22Output = "KING earns 5000\nrows 1\ninserted 1\n2020-02-01 [OB   ]\nupdated 3\ncount 2\nmerged 2\ntotal 10800\ndeleted 2\nnothing deleted\nORA-01403: no data found\n"
+
+var fixture22Schema = `
+CREATE TABLE emp (id INTEGER PRIMARY KEY, name TEXT NOT NULL, salary REAL, hired TEXT, code TEXT);
+INSERT INTO emp VALUES (1, 'KING', 5000, '1981-11-17 00:00:00', 'KG');
+INSERT INTO emp VALUES (2, 'SMITH', 800, '1980-12-17 00:00:00', 'SM');
+INSERT INTO emp VALUES (3, 'ALLEN', 1600, '1981-02-20 00:00:00', 'AL');
+CREATE TABLE bonus (emp_id INTEGER, amount REAL);
+INSERT INTO bonus VALUES (1, 100);
+INSERT INTO bonus VALUES (5, 300);
+`
+
+func TestFixture22(t *testing.T) {
+	defer newTestDatabase(t, fixture22Schema)()
+
+	Compile([]string{"./test22.sql"}, "./test", runtime.SqliteDriver, printIR, deleteTmpFile)
+	output, err := executeBinary("./test")
+	assert.Equal(t, fixture22Output, output)
+	assert.NotNil(t, err)
+
+	// the program doesn't run without a database
+	os.Unsetenv("PLSQLC_DB")
+	output, err = executeBinary("./test")
+	assert.True(t, strings.HasPrefix(output, "ORA-12154"), output)
+	assert.NotNil(t, err)
+	err = os.Remove("./test")
+	assert.Nil(t, err)
+}
+
+var fixture23Output = "1 KING 5000\n2 SMITH 800\n3 ALLEN 1600\nfetched 3\nno more rows\nstill ALLEN\nopen\nALLEN\n0 ALLEN\nORA-01001: invalid cursor\n"
+
+func TestFixture23(t *testing.T) {
+	defer newTestDatabase(t, fixture22Schema)()
+
+	Compile([]string{"./test23.sql"}, "./test", runtime.SqliteDriver, printIR, deleteTmpFile)
+	output, err := executeBinary("./test")
+	assert.Equal(t, fixture23Output, output)
+	assert.NotNil(t, err)
+	err = os.Remove("./test")
+	assert.Nil(t, err)
+}
+
+var fixture24Output = "1 KING 5000\n3 ALLEN 1600\ntotal 7400\nSMITH 1600\nALLEN 3200\nKING 10000\n  bonus 100\ntotal 0\nKING\nKING\nnobody\nORA-06511: PL/SQL: cursor already open\n"
+
+func TestFixture24(t *testing.T) {
+	defer newTestDatabase(t, fixture22Schema)()
+
+	Compile([]string{"./test24.sql"}, "./test", runtime.SqliteDriver, printIR, deleteTmpFile)
+	output, err := executeBinary("./test")
+	assert.Equal(t, fixture24Output, output)
+	assert.NotNil(t, err)
+	err = os.Remove("./test")
+	assert.Nil(t, err)
+}
+
+var fixture25Output = "1\nupdated 2\naudit_log has 2 rows\nALLEN 1700\n1 ALLEN\n2 KING\nraised salaries\nORA-01008: not all variables bound\n"
+
+func TestFixture25(t *testing.T) {
+	defer newTestDatabase(t, fixture22Schema)()
+
+	Compile([]string{"./test25.sql"}, "./test", runtime.SqliteDriver, printIR, deleteTmpFile)
+	output, err := executeBinary("./test")
+	assert.Equal(t, fixture25Output, output)
+	assert.NotNil(t, err)
+	err = os.Remove("./test")
+	assert.Nil(t, err)
+}
+
+var fixture26Output = "inserted 3\nrun 2 inserted 1\nupdated 5\nrun 1 updated 2\nrun 4 updated 0\nrun 5 updated 1\ntotal 465\ndeleted 0\nORA-01403: no data found\n"
+
+func TestFixture26(t *testing.T) {
+	defer newTestDatabase(t, fixture22Schema)()
+
+	Compile([]string{"./test26.sql"}, "./test", runtime.SqliteDriver, printIR, deleteTmpFile)
+	output, err := executeBinary("./test")
+	assert.Equal(t, fixture26Output, output)
+	assert.NotNil(t, err)
+	err = os.Remove("./test")
+	assert.Nil(t, err)
+}
+
+var fixture27Output = "800 3\nrolled back 2\nzeroed 3\n7400 3\nORA-06519: active autonomous transaction detected and rolled back\n"
+
+func TestFixture27(t *testing.T) {
+	defer newTestDatabase(t, fixture22Schema)()
+
+	Compile([]string{"./test27.sql"}, "./test", runtime.SqliteDriver, printIR, deleteTmpFile)
+	output, err := executeBinary("./test")
+	assert.Equal(t, fixture27Output, output)
+	assert.NotNil(t, err)
+	err = os.Remove("./test")
+	assert.Nil(t, err)
+
+	// the error rolled back the delete, everything before it was committed
+	rows, err := exec.Command("sqlite3", os.Getenv("PLSQLC_DB"), "SELECT emp_id FROM bonus ORDER BY emp_id").Output()
+	assert.Nil(t, err)
+	assert.Equal(t, "1\n3\n5\n7\n", string(rows))
+}
+
+var fixture28Output = "updated 0\nORA-01403: no data found\n"
+
+var fixture28Statements = `BEGIN
+UPDATE EMP SET SALARY = SALARY + ? WHERE NAME = ?
+  :1 = 2.5
+  :2 = 'KING'
+INSERT INTO BONUS VALUES ( ? , 100 )
+  :1 = 1
+  :1 = 2
+DELETE FROM bonus WHERE emp_id = :1
+  :1 = 7
+COMMIT
+BEGIN
+SELECT SALARY FROM EMP WHERE NAME = ?
+  :1 = 'KING'
+ROLLBACK
+`
+
+func TestFixture28(t *testing.T) {
+	// the stub driver writes the statements to the file the program connects to
+	tmpDir, err := ioutil.TempDir("", "plsqlc")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tmpDir)
+	logPath := filepath.Join(tmpDir, "statements.log")
+	os.Setenv("PLSQLC_DB", logPath)
+	defer os.Unsetenv("PLSQLC_DB")
+
+	Compile([]string{"./test28.sql"}, "./test", runtime.StubDriver, printIR, deleteTmpFile)
+	output, err := executeBinary("./test")
+	assert.Equal(t, fixture28Output, output)
+	assert.NotNil(t, err)
+	err = os.Remove("./test")
+	assert.Nil(t, err)
+
+	statements, err := ioutil.ReadFile(logPath)
+	assert.Nil(t, err)
+	assert.Equal(t, fixture28Statements, string(statements))
+}
+
+func TestBuildIntermediateFiles(t *testing.T) {
+	// the llvm ir of the program and the driver is written to a directory of its own
+	tmpDir, err := ioutil.TempDir("", "plsqlc")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tmpDir)
+	os.Setenv("TMPDIR", tmpDir)
+	defer os.Unsetenv("TMPDIR")
+
+	Compile([]string{"./test28.sql"}, "./test", runtime.StubDriver, printIR, true)
+	files, err := ioutil.ReadDir(tmpDir)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(files))
+
+	// the llvm ir is kept if it isn't deleted
+	Compile([]string{"./test28.sql"}, "./test", runtime.StubDriver, printIR, false)
+	files, err = ioutil.ReadDir(tmpDir)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(files))
+	kept, err := filepath.Glob(filepath.Join(tmpDir, files[0].Name(), "*.ll"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{filepath.Join(tmpDir, files[0].Name(), "driver.ll"), filepath.Join(tmpDir, files[0].Name(), "program.ll")}, kept)
+	err = os.Remove("./test")
+	assert.Nil(t, err)
+}
+
+var fixture29Output = "2 SMITH 800\n2 100\n1 KING 5000\n3 ALLEN 1600\n10\ntotal 5100\n"
+
+func TestFixture29(t *testing.T) {
+	defer newTestDatabase(t, fixture22Schema)()
+	tmpDir, err := ioutil.TempDir("", "plsqlc")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tmpDir)
+	schemaPath := filepath.Join(tmpDir, "schema.sql")
+	err = ioutil.WriteFile(schemaPath, []byte(fixture22Schema), 0644)
+	assert.Nil(t, err)
+
+	opts := NewOptions([]string{"./test29.sql"}, "./test")
+	opts.PrintIR = printIR
+	opts.DeleteLlvmIR = deleteTmpFile
+	opts.Schema = schemaPath
+	Build(opts)
+	output, err := executeBinary("./test")
+	assert.Equal(t, fixture29Output, output)
+	assert.Nil(t, err)
+	err = os.Remove("./test")
+	assert.Nil(t, err)
+
+	// tables that aren't part of the schema are found before the program runs
+	err = ioutil.WriteFile(schemaPath, []byte("CREATE TABLE emp (id INTEGER, name TEXT, salary REAL, hired TEXT, code TEXT);"), 0644)
+	assert.Nil(t, err)
+	assert.Panics(t, func() {
+		Check(opts)
+	})
+}
+
+var fixture30Output = "1 KING\n3 ALLEN\n2 rows\n1 100\nopen\n"
+
+func TestFixture30(t *testing.T) {
+	defer newTestDatabase(t, fixture22Schema)()
+
+	Compile([]string{"./test30.sql"}, "./test", runtime.SqliteDriver, printIR, deleteTmpFile)
+	output, err := executeBinary("./test")
+	assert.Equal(t, fixture30Output, output)
+	assert.Nil(t, err)
+	err = os.Remove("./test")
+	assert.Nil(t, err)
+}
+
+var fixture36Output = "2 KING ALLEN\n1 1\n10 1000\n30 3000\n2 of 3 5\nselected 2 KING ALLEN\nfetched 2 SMITH\nfetched 1 3 ALLEN\ninserted 2\nORA-24381: error(s) in array DML, 1 error(s), the first for index 3: ORA-00001: unique constraint violated, UNIQUE constraint failed: emp.id\n"
+
+func TestFixture36(t *testing.T) {
+	defer newTestDatabase(t, fixture22Schema)()
+
+	Compile([]string{"./test36.sql"}, "./test", runtime.SqliteDriver, printIR, deleteTmpFile)
+	output, err := executeBinary("./test")
+	assert.Equal(t, fixture36Output, output)
+	assert.NotNil(t, err)
+	err = os.Remove("./test")
+	assert.Nil(t, err)
+}
+
+var fixture31Output = "equal\n.3\n100000000000000000000\n-123456789012346000\n1E+80\n1E-71\n-.5\n0\nnot equal\n"
+
+func TestFixture31(t *testing.T) {
+	Compile([]string{"./test31.sql"}, "./test", runtime.SqliteDriver, printIR, deleteTmpFile)
+	output, err := executeBinary("./test")
+	assert.Equal(t, fixture31Output, output)
+	assert.Nil(t, err)
+	err = os.Remove("./test")
+	assert.Nil(t, err)
+}
+
+var fixture32Output = "2000000\n"
+
+func TestFixture32(t *testing.T) {
+	// without optimizations nothing moves the allocations of the loop out of it
+	opts := NewOptions([]string{"./test32.sql"}, "./test")
+	opts.PrintIR = printIR
+	opts.DeleteLlvmIR = deleteTmpFile
+	opts.OptLevel = "0"
+	Build(opts)
+	output, err := executeBinary("./test")
+	assert.Equal(t, fixture32Output, output)
+	assert.Nil(t, err)
+	err = os.Remove("./test")
+	assert.Nil(t, err)
+}
+
+var fixture33Output = "row 99999\n"
+
+func TestFixture33(t *testing.T) {
+	Compile([]string{"./test33.sql"}, "./test", runtime.SqliteDriver, printIR, deleteTmpFile)
+	defer os.Remove("./test")
+	output, err := executeBinary("./test")
+	assert.Equal(t, fixture33Output, output)
+	assert.Nil(t, err)
+
+	os.Setenv(runtime.HeapLimitEnvVar, "200000")
+	defer os.Unsetenv(runtime.HeapLimitEnvVar)
+	output, err = executeBinary("./test")
+	assert.Equal(t, runtime.HeapLimitMessage+"\n", output)
+	assert.NotNil(t, err)
+}
+
+var fixture34Output = "1 200\n2 400\ntotal 600\ninserted 150\n"
+
+var fixture34Schema = `
+CREATE TABLE emp (id INTEGER PRIMARY KEY, salary REAL);
+INSERT INTO emp VALUES (1, 100);
+INSERT INTO emp VALUES (2, 200);
+`
+
+func TestFixture34(t *testing.T) {
+	defer newTestDatabase(t, fixture34Schema)()
+	tmpDir, err := ioutil.TempDir("", "plsqlc")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tmpDir)
+	schemaPath := filepath.Join(tmpDir, "schema.sql")
+	err = ioutil.WriteFile(schemaPath, []byte(fixture34Schema), 0644)
+	assert.Nil(t, err)
+
+	opts := NewOptions([]string{"./test34.sql"}, "./test")
+	opts.PrintIR = printIR
+	opts.DeleteLlvmIR = deleteTmpFile
+	opts.Schema = schemaPath
+	Build(opts)
+	output, err := executeBinary("./test")
+	assert.Equal(t, fixture34Output, output)
+	assert.Nil(t, err)
+	err = os.Remove("./test")
+	assert.Nil(t, err)
+}
+
+var fixture35Output = "3 2\nORA-00060: deadlock detected while waiting for resource, database is locked\n"
+
+func TestFixture35(t *testing.T) {
+	defer newTestDatabase(t, fixture22Schema)()
+
+	Compile([]string{"./test35.sql"}, "./test", runtime.SqliteDriver, printIR, deleteTmpFile)
+	start := time.Now()
+	output, err := executeBinary("./test")
+	assert.Equal(t, fixture35Output, output)
+	assert.NotNil(t, err)
+	// the deadlock is raised without waiting for the lock
+	assert.True(t, time.Since(start) < 2*time.Second)
+	err = os.Remove("./test")
+	assert.Nil(t, err)
+
+	rows, err := exec.Command("sqlite3", os.Getenv("PLSQLC_DB"), "SELECT emp_id FROM bonus ORDER BY emp_id").Output()
+	assert.Nil(t, err)
+	assert.Equal(t, "1\n5\n", string(rows))
+}
+
+var fixture37Output = "0 0 [] 0 []\n0 0 [] 0 []\n"
+
+func TestFixture37(t *testing.T) {
+	Compile([]string{"./test37.sql"}, "./test", runtime.SqliteDriver, printIR, deleteTmpFile)
+	output, err := executeBinary("./test")
+	assert.Equal(t, fixture37Output, output)
+	assert.Nil(t, err)
+	err = os.Remove("./test")
+	assert.Nil(t, err)
+}
+
+var fixture38Output = "30000\n23890 4998,4999,\n23557.25 999.5 999.75\n"
+
+func TestFixture38(t *testing.T) {
+	Compile([]string{"./test38.sql"}, "./test", runtime.SqliteDriver, printIR, deleteTmpFile)
+	defer os.Remove("./test")
+	os.Setenv(runtime.HeapLimitEnvVar, "1000000")
+	defer os.Unsetenv(runtime.HeapLimitEnvVar)
+	output, err := executeBinary("./test")
+	assert.Equal(t, fixture38Output, output)
+	assert.Nil(t, err)
+}
+
+func TestDebugInfo(t *testing.T) {
+	opts := NewOptions([]string{"./test09.sql"}, "./test")
+	opts.PrintIR = printIR
+	opts.DeleteLlvmIR = deleteTmpFile
+	opts.Debug = true
+
+	// functions, statements and variables are mapped back to the source
+	ir := GenerateIR(opts).String()
+	assert.Contains(t, ir, `!DIFile(filename: "test09.sql"`)
+	assert.Contains(t, ir, `!DISubprogram(name: "util.square", linkageName: "UTIL.SQUARE", scope: !0, file: !0, line: 61`)
+	assert.Contains(t, ir, `!DILocalVariable(name: "i", arg: 1`)
+	assert.Contains(t, ir, `!DILocation(line: 63`)
+	assert.Regexp(t, `call void @LOGGER.LOG_LINE\(%_runtime._string %\d+\), !dbg`, ir)
+
+	// the program does the same with debug info
+	Build(opts)
+	output, err := executeBinary("./test")
+	assert.Equal(t, fixture9Output, output)
+	assert.Nil(t, err)
+	err = os.Remove("./test")
+	assert.Nil(t, err)
+
+	opts.Debug = false
+	assert.NotContains(t, GenerateIR(opts).String(), "!dbg")
+}
+
+// newTestDatabase creates a SQLite database with the sqlite3 shell and points PLSQLC_DB at it
+// the test is skipped if the shell isn't installed, the returned function removes the database
+func newTestDatabase(t *testing.T, schema string) func() {
+	sqlite, err := exec.LookPath("sqlite3")
+	if err != nil {
+		t.Skip("sqlite3 isn't installed")
+	}
+
+	tmpDir, err := ioutil.TempDir("", "plsqlc")
+	assert.Nil(t, err)
+	dbPath := filepath.Join(tmpDir, "test.db")
+	output, err := exec.Command(sqlite, dbPath, schema).CombinedOutput()
+	assert.Nil(t, err, string(output))
+
+	os.Setenv("PLSQLC_DB", dbPath)
+	return func() {
+		os.Unsetenv("PLSQLC_DB")
+		os.RemoveAll(tmpDir)
+	}
+}
+
 func executeBinary(file string) (string, error) {
 	cmd := exec.Command(file)
 	output, err := cmd.CombinedOutput()
 	return string(output), err
 }
+
+func TestDiagnosticPositions(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "plsqlc")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	path := filepath.Join(tmpDir, "main.sql")
+	src := "CREATE OR REPLACE PACKAGE BODY main AS\n  PROCEDURE main IS\n    v INT := 'x';\n  BEGIN\n    dbms.print(v);\n      narf := 1;\n  END;\nEND main;\n/\n"
+	err = ioutil.WriteFile(path, []byte(src), 0644)
+	assert.Nil(t, err)
+
+	diagnostics := ast.Check(parseFile(path, nil))
+	messages := make([]string, len(diagnostics))
+	for idx := range diagnostics {
+		messages[idx] = diagnostics[idx].String()
+	}
+	assert.Equal(t, []string{
+		path + ":3:5: MAIN.MAIN: ORA-06502: PL/SQL: numeric or value error: character to number conversion error, 'V' can't be initialized with 'x'",
+		path + ":6:7: MAIN.MAIN: PLS-00201: identifier 'NARF' must be declared",
+	}, messages)
+}
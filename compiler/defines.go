@@ -0,0 +1,90 @@
+/*
+ * Copyright 2019 Marco Helmich
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compiler
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/mhelmich/plsqlc/lexer"
+)
+
+// resolveInquiryDirectives replaces inquiry directives like $$DEBUG
+// with the literal values that are defined for them
+// numbers become numeric literals, everything else a string literal
+// quotes in strings are doubled like in the source, otherwise they would end the literal
+func resolveInquiryDirectives(items <-chan *lexer.Item, defines map[string]string) <-chan *lexer.Item {
+	out := make(chan *lexer.Item)
+	go func() {
+		defer close(out)
+		for i := range items {
+			if i.Typ == lexer.InquiryDirectiveType {
+				i = resolveInquiryDirective(i, defines)
+			}
+			out <- i
+			if i.Typ == lexer.ErrorType {
+				// the parser stops at the error, the lexer still needs to run to completion
+				for range items {
+				}
+				return
+			}
+		}
+	}()
+	return out
+}
+
+func resolveInquiryDirective(i *lexer.Item, defines map[string]string) *lexer.Item {
+	name := strings.TrimPrefix(i.Value, "$$")
+	value, ok := defines[name]
+	if !ok {
+		return &lexer.Item{
+			Typ:      lexer.ErrorType,
+			Value:    fmt.Sprintf("Inquiry directive '%s' isn't defined", i.Value),
+			StartPos: i.StartPos,
+			EndPos:   i.EndPos,
+			Line:     i.Line,
+			Column:   i.Column,
+		}
+	}
+
+	resolved := &lexer.Item{
+		StartPos: i.StartPos,
+		EndPos:   i.EndPos,
+		Line:     i.Line,
+		Column:   i.Column,
+	}
+	if isNumericDefine(value) {
+		resolved.Typ = lexer.NumericType
+		resolved.Value = value
+	} else {
+		resolved.Typ = lexer.StringType
+		resolved.Value = "'" + strings.Replace(value, "'", "''", -1) + "'"
+	}
+	return resolved
+}
+
+// isNumericDefine returns true if the value of a define is a number ('1', '-2' or '2.5')
+// 'Inf', 'NaN' and hex numbers parse as floats too but aren't numbers in PL/SQL
+func isNumericDefine(value string) bool {
+	if _, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return true
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	return err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) && !strings.ContainsAny(value, "xX_")
+}
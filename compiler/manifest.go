@@ -0,0 +1,144 @@
+/*
+ * Copyright 2019 Marco Helmich
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compiler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/mhelmich/plsqlc/runtime"
+)
+
+const (
+	// name of the project file plsqlc looks for in the current directory
+	ManifestFileName = "plsqlc.json"
+
+	defaultEntry    = "MAIN"
+	defaultOptLevel = "3"
+)
+
+var validOptLevels = map[string]bool{
+	"0": true,
+	"1": true,
+	"2": true,
+	"3": true,
+	"s": true,
+	"z": true,
+}
+
+// Manifest describes a plsqlc project
+// all paths are relative to the directory the manifest lives in
+//
+//	{
+//	  "sources": ["src", "vendor/*.pks"],
+//	  "entry": "main",
+//	  "output": "app",
+//	  "optimization": "2",
+//	  "libraries": ["m"],
+//	  "driver": "sqlite",
+//	  "schema": "db/schema.sql",
+//	  "defines": {"DEBUG": "1"}
+//	}
+type Manifest struct {
+	Sources      []string          `json:"sources"`
+	Entry        string            `json:"entry"`
+	Output       string            `json:"output"`
+	Optimization string            `json:"optimization"`
+	Libraries    []string          `json:"libraries"`
+	Driver       string            `json:"driver"`
+	Schema       string            `json:"schema"`
+	Defines      map[string]string `json:"defines"`
+
+	dir string
+}
+
+// LoadManifest reads and validates the project file at path
+func LoadManifest(path string) (*Manifest, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Manifest{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("Can't read manifest '%s': %s", path, err.Error())
+	}
+	m.dir = filepath.Dir(path)
+
+	if len(m.Sources) == 0 {
+		return nil, fmt.Errorf("Manifest '%s' doesn't list any sources", path)
+	}
+	if m.Entry == "" {
+		m.Entry = defaultEntry
+	}
+	if m.Output == "" {
+		m.Output = "out"
+	}
+	if m.Optimization == "" {
+		m.Optimization = defaultOptLevel
+	}
+	if !validOptLevels[m.Optimization] {
+		return nil, fmt.Errorf("Unknown optimization level '%s' in manifest '%s'", m.Optimization, path)
+	}
+	return m, nil
+}
+
+// Options resolves the manifest into options for a build
+func (m *Manifest) Options() (*Options, error) {
+	sources := make([]string, len(m.Sources))
+	for idx := range m.Sources {
+		sources[idx] = m.resolve(m.Sources[idx])
+	}
+
+	inputPaths, err := ExpandInputPaths(sources)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := NewOptions(inputPaths, m.resolve(m.Output))
+	opts.Entry = strings.ToUpper(m.Entry)
+	opts.OptLevel = m.Optimization
+	opts.Libraries = m.Libraries
+	if m.Driver != "" {
+		opts.Driver = m.resolveDriver(m.Driver)
+	}
+	if m.Schema != "" {
+		opts.Schema = m.resolve(m.Schema)
+	}
+	for name, value := range m.Defines {
+		opts.Defines[strings.ToUpper(name)] = value
+	}
+	return opts, nil
+}
+
+// resolveDriver resolves the path of a driver that doesn't ship with plsqlc
+func (m *Manifest) resolveDriver(driver string) string {
+	if runtime.IsBuiltinDriver(driver) {
+		return driver
+	}
+	return m.resolve(driver)
+}
+
+func (m *Manifest) resolve(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(m.dir, path)
+}
@@ -0,0 +1,47 @@
+--
+-- Copyright 2019 Marco Helmich
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+--     http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+--
+
+CREATE OR REPLACE PACKAGE main AS
+    SUBTYPE counter IS INT;
+
+    PROCEDURE main;
+    FUNCTION twice(i IN INT) RETURN counter;
+END main;
+/
+
+CREATE OR REPLACE PACKAGE BODY main AS
+
+    PROCEDURE main IS
+      c counter := 41;
+    BEGIN
+      dbms.print(twice(21));
+      add_one(c);
+      dbms.print(c);
+    END;
+
+    FUNCTION twice(i IN INT) RETURN counter IS
+    BEGIN
+      RETURN i + i;
+    END;
+
+    -- private, not declared in the spec
+    PROCEDURE add_one(i IN OUT INT) IS
+    BEGIN
+      i := i + 1;
+    END;
+
+END main;
+/
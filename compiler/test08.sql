@@ -0,0 +1,52 @@
+--
+-- Copyright 2019 Marco Helmich
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+--     http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+--
+
+CREATE OR REPLACE PACKAGE main AS
+    TYPE config_t IS RECORD (retries INT, label VARCHAR);
+    max_retries CONSTANT INT := 3;
+    greeting VARCHAR := 'hello';
+
+    PROCEDURE main;
+END main;
+/
+
+CREATE OR REPLACE PACKAGE BODY main AS
+    -- private package state
+    calls INT := 0;
+    config config_t;
+
+    PROCEDURE count_call IS
+    BEGIN
+      calls := calls + 1;
+    END;
+
+    PROCEDURE main IS
+    BEGIN
+      count_call();
+      count_call();
+      dbms.print(calls);
+      dbms.print(main.max_retries);
+      dbms.print(greeting);
+      dbms.print(config.retries);
+      dbms.print(config.label);
+    END main;
+
+BEGIN
+    config.retries := max_retries;
+    config.label := 'configured';
+    calls := 10;
+END main;
+/
@@ -0,0 +1,67 @@
+--
+-- Copyright 2019 Marco Helmich
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+--     http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+--
+
+-- main comes first and calls into packages that are defined further down
+CREATE OR REPLACE PACKAGE BODY main AS
+
+    PROCEDURE main IS
+    BEGIN
+      logger.log_line('start');
+      dbms.print(util.square(7));
+      logger.log_line('done');
+      dbms.print(logger.lines);
+    END;
+
+END main;
+/
+
+CREATE OR REPLACE PACKAGE logger AS
+    lines INT;
+
+    PROCEDURE log_line(msg IN VARCHAR);
+END logger;
+/
+
+CREATE OR REPLACE PACKAGE BODY logger AS
+    prefix VARCHAR;
+
+    PROCEDURE write(msg IN VARCHAR) IS
+    BEGIN
+      dbms.print(prefix);
+      dbms.print(msg);
+    END;
+
+    PROCEDURE log_line(msg IN VARCHAR) IS
+    BEGIN
+      lines := lines + 1;
+      write(msg);
+    END;
+
+BEGIN
+    prefix := 'log:';
+    lines := util.square(0);
+END logger;
+/
+
+CREATE OR REPLACE PACKAGE BODY util AS
+
+    FUNCTION square(i IN INT) RETURN INT IS
+    BEGIN
+      RETURN i * i;
+    END;
+
+END util;
+/
@@ -0,0 +1,46 @@
+--
+-- Copyright 2019 Marco Helmich
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+--     http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+--
+
+CREATE OR REPLACE PACKAGE logger AS
+    PROCEDURE log_line(msg IN VARCHAR);
+END logger;
+/
+
+CREATE OR REPLACE PACKAGE BODY logger AS
+
+    PROCEDURE write(msg IN VARCHAR) IS
+    BEGIN
+      dbms.print(msg);
+    END;
+
+    PROCEDURE log_line(msg IN VARCHAR) IS
+    BEGIN
+      write(msg);
+    END;
+
+END logger;
+/
+
+CREATE OR REPLACE PACKAGE BODY main AS
+
+    PROCEDURE main IS
+    BEGIN
+      -- write is private to logger
+      logger.write('narf');
+    END;
+
+END main;
+/
@@ -0,0 +1,51 @@
+--
+-- Copyright 2019 Marco Helmich
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+--     http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+--
+
+CREATE OR REPLACE PACKAGE BODY main AS
+
+    FUNCTION half(x IN NUMBER) RETURN NUMBER IS
+    BEGIN
+      RETURN x / 2;
+    END;
+
+    PROCEDURE main IS
+      i INT := 7;
+      n NUMBER := 2.5;
+      s VARCHAR := '40';
+      r VARCHAR;
+    BEGIN
+      -- dividing integers results in a number
+      dbms.print(i / 2);
+      -- strings are converted to numbers in arithmetic
+      dbms.print(s + 2);
+      -- numbers are rounded when assigned to integers
+      i := n * 3;
+      dbms.print(i);
+      r := n;
+      dbms.print(r);
+      r := 0.25;
+      dbms.print(r);
+      r := i;
+      dbms.print(r);
+      IF s = 40 THEN
+        dbms.print('equal');
+      END IF;
+      dbms.print(half(i));
+      dbms.print(-0.5);
+    END;
+
+END main;
+/
@@ -0,0 +1,49 @@
+--
+-- Copyright 2019 Marco Helmich
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+--     http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+--
+
+CREATE OR REPLACE PACKAGE BODY main AS
+    code CHAR(5) := 'AB';
+
+    PROCEDURE main IS
+      name VARCHAR2(5) := 'abc';
+      short NVARCHAR2(3);
+      c CHAR(5);
+      v VARCHAR2(10);
+    BEGIN
+      -- CHAR values and literals are compared blank-padded
+      IF code = 'AB' THEN
+        dbms.print('padded equal');
+      END IF;
+      c := 'AB ';
+      IF c = code THEN
+        dbms.print('char equal');
+      END IF;
+      -- as soon as a VARCHAR is involved trailing blanks count
+      v := 'AB';
+      IF code = v THEN
+        dbms.print('equal');
+      END IF;
+      IF code <> v THEN
+        dbms.print('not equal');
+      END IF;
+      name := 'abcde';
+      dbms.print(name);
+      short := 'abcd';
+      dbms.print('unreachable');
+    END;
+
+END main;
+/
@@ -0,0 +1,56 @@
+--
+-- Copyright 2019 Marco Helmich
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+--     http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+--
+
+CREATE OR REPLACE PACKAGE BODY main AS
+    greeting VARCHAR;
+
+    FUNCTION describe(name IN VARCHAR, n IN NUMBER) RETURN VARCHAR IS
+      prefix VARCHAR := 'value of ';
+    BEGIN
+      RETURN prefix || name || ' is ' || n;
+    END;
+
+    PROCEDURE main IS
+      i INT := 0;
+      s VARCHAR := '';
+      big VARCHAR := '';
+      code CHAR(3) := 'ab';
+    BEGIN
+      dbms.print('v is ' || 42);
+      dbms.print(describe('n', 2.5));
+      -- strings outlive the function that built them
+      greeting := describe('x', -0.5);
+      dbms.print(greeting);
+      WHILE i < 5 LOOP
+        s := s || i;
+        i := i + 1;
+      END LOOP;
+      dbms.print(s);
+      dbms.print('[' || code || ']');
+      -- long strings keep growing past the size of a chunk
+      i := 0;
+      WHILE i < 50000 LOOP
+        big := big || 'abcd';
+        i := i + 1;
+      END LOOP;
+      IF big || 'x' <> big THEN
+        dbms.print('done');
+      END IF;
+      dbms.print(s);
+    END;
+
+END main;
+/
@@ -0,0 +1,61 @@
+--
+-- Copyright 2019 Marco Helmich
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+--     http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+--
+
+CREATE OR REPLACE PACKAGE BODY main AS
+
+    -- subprograms of the package shadow built-in functions
+    FUNCTION chr(n IN INT) RETURN VARCHAR IS
+    BEGIN
+      RETURN '<' || n || '>';
+    END;
+
+    PROCEDURE main IS
+      s VARCHAR := 'Hello World';
+      code CHAR(5) := 'ab';
+    BEGIN
+      dbms.print(length(s));
+      dbms.print(length(code));
+      dbms.print(substr(s, 7));
+      dbms.print(substr(s, 1, 5));
+      dbms.print(substr(s, -5, 3));
+      dbms.print('[' || substr(s, 20) || ']');
+      dbms.print(instr(s, 'o'));
+      dbms.print(instr(s, 'o', 1, 2));
+      dbms.print(instr(s, 'o', -1));
+      dbms.print(instr(s, 'xyz'));
+      dbms.print(instr('aaaa', 'aa', 1, 2));
+      dbms.print(upper(s));
+      dbms.print(lower(s));
+      dbms.print(initcap('the qUICK brown-fox'));
+      dbms.print('[' || trim('  padded  ') || ']');
+      dbms.print(ltrim('xxyhixy', 'xy'));
+      dbms.print(rtrim('xxyhixy', 'xy'));
+      dbms.print('[' || rtrim(code) || ']');
+      dbms.print(lpad('7', 3, '0'));
+      dbms.print(rpad('ab', 7, '*-'));
+      dbms.print(lpad('truncated', 5));
+      dbms.print(replace(s, 'o', '0'));
+      dbms.print(replace('banana', 'an'));
+      dbms.print(translate('hello', 'el', 'ip'));
+      dbms.print(translate('a-b-c', 'a-', 'A'));
+      dbms.print(concat('con', 'cat'));
+      dbms.print(ascii('A'));
+      dbms.print(chr(65));
+      dbms.print(instr(s, 'o', 1, 0));
+    END;
+
+END main;
+/
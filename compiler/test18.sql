@@ -0,0 +1,64 @@
+--
+-- Copyright 2019 Marco Helmich
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+--     http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+--
+
+CREATE OR REPLACE PACKAGE BODY main AS
+
+    PROCEDURE main IS
+      i INT;
+      b BINARY_DOUBLE := 2.5;
+      d BINARY_DOUBLE := 1.005;
+    BEGIN
+      dbms.print(abs(-5));
+      dbms.print(abs(-2.5));
+      dbms.print(mod(7, 3));
+      dbms.print(mod(-7, 3));
+      dbms.print(mod(7, 0));
+      dbms.print(mod(7.5, 2));
+      dbms.print(remainder(7, 4));
+      dbms.print(round(2.5));
+      dbms.print(round(-2.5));
+      dbms.print(round(3.14159, 2));
+      dbms.print(round(1250, -2));
+      -- binary doubles round halves to the even neighbour
+      dbms.print(round(b));
+      dbms.print(trunc(3.789, 1));
+      dbms.print(trunc(1299, -2));
+      dbms.print(ceil(2.1));
+      dbms.print(floor(-2.1));
+      dbms.print(ceil(5));
+      dbms.print(power(2, 10));
+      dbms.print(sqrt(16));
+      dbms.print(sign(-3));
+      dbms.print(sign(0.5));
+      dbms.print(greatest(3, 7, 5));
+      dbms.print(least(3, 1.5, 2));
+      dbms.print(exp(0));
+      dbms.print(ln(1));
+      dbms.print(log(10, 1000));
+      -- numbers round their decimal digits, binary doubles their binary value
+      dbms.print(round(1.005, 2));
+      dbms.print(trunc(0.29 * 100));
+      dbms.print(floor(0.3 / 0.1));
+      dbms.print(to_char(1.005, 'FM9.99'));
+      dbms.print(round(d, 2));
+      i := round(7.6);
+      dbms.print(i);
+      dbms.print(b * 2);
+      dbms.print(sqrt(-1));
+    END;
+
+END main;
+/
@@ -0,0 +1,47 @@
+--
+-- Copyright 2019 Marco Helmich
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+--     http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+--
+
+CREATE OR REPLACE PACKAGE BODY main AS
+
+    PROCEDURE main IS
+      n NUMBER;
+    BEGIN
+      dbms.print('[' || to_char(1234.5, '9,999.99') || ']');
+      dbms.print('[' || to_char(-1234.5, '9G999D99') || ']');
+      dbms.print('[' || to_char(0.5, '9.99') || ']');
+      dbms.print('[' || to_char(0.5, '0.99') || ']');
+      dbms.print('[' || to_char(42, '0000') || ']');
+      dbms.print('[' || to_char(42, '9,999') || ']');
+      dbms.print('[' || to_char(1234.5, 'FM9,999.99') || ']');
+      dbms.print('[' || to_char(1234.5, 'FM9,999.00') || ']');
+      dbms.print('[' || to_char(12.345, 'FM$999.99') || ']');
+      dbms.print('[' || to_char(12, 'S999') || ']');
+      dbms.print('[' || to_char(-12, 'S999') || ']');
+      dbms.print('[' || to_char(12, '999S') || ']');
+      dbms.print('[' || to_char(-12, '999MI') || ']');
+      dbms.print('[' || to_char(12, '999MI') || ']');
+      dbms.print('[' || to_char(12, 'FM999MI') || ']');
+      dbms.print('[' || to_char(12345, '999') || ']');
+      dbms.print('[' || to_char(7) || ']');
+      n := to_number('$1,234.50', '$9,999.99');
+      dbms.print(n);
+      dbms.print(to_number('-12.5', '99.9') + 1);
+      dbms.print(to_number('42'));
+      dbms.print(to_char(1, '99X'));
+    END;
+
+END main;
+/
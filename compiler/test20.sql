@@ -0,0 +1,66 @@
+--
+-- Copyright 2019 Marco Helmich
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+--     http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+--
+
+CREATE OR REPLACE PACKAGE BODY main AS
+
+    PROCEDURE main IS
+      d DATE;
+      e DATE := '31-JAN-2019';
+      ts TIMESTAMP;
+      ds INTERVAL DAY TO SECOND;
+      ym INTERVAL YEAR(2) TO MONTH := '1-6';
+    BEGIN
+      d := to_date('2019-03-15 13:45:30', 'YYYY-MM-DD HH24:MI:SS');
+      dbms.print(to_char(d, 'DD-Mon-YYYY HH:MI:SS AM'));
+      dbms.print(to_char(d, 'FMMonth DD, YYYY'));
+      dbms.print(to_char(d, 'DAY DY D DDD Q'));
+      dbms.print(d);
+      dbms.print(to_char(d + 1, 'YYYY-MM-DD HH24:MI:SS'));
+      dbms.print(to_char(d - 0.5, 'YYYY-MM-DD HH24:MI:SS'));
+      dbms.print(to_char(trunc(d), 'YYYY-MM-DD HH24:MI:SS'));
+      dbms.print(to_char(add_months(e, 1), 'YYYY-MM-DD'));
+      dbms.print(to_char(last_day(to_date('2020-02-10', 'YYYY-MM-DD')), 'YYYY-MM-DD'));
+      dbms.print(months_between(to_date('2019-03-31', 'YYYY-MM-DD'), e));
+      dbms.print(d - e);
+      dbms.print(extract(YEAR FROM d));
+      dbms.print(extract(MONTH FROM d));
+      dbms.print(extract(DAY FROM d));
+      ts := to_timestamp('2019-03-15 13:45:30.25', 'YYYY-MM-DD HH24:MI:SS.FF');
+      dbms.print(ts);
+      dbms.print(extract(SECOND FROM ts));
+      ds := ts - to_timestamp('2019-03-14 12:00:00', 'YYYY-MM-DD HH24:MI:SS');
+      dbms.print(ds);
+      dbms.print(ds * 2);
+      dbms.print(extract(HOUR FROM ds));
+      dbms.print(to_char(d + ds, 'YYYY-MM-DD HH24:MI:SS'));
+      dbms.print(ym);
+      dbms.print(to_char(e + ym, 'YYYY-MM-DD'));
+      dbms.print(numtodsinterval(36, 'HOUR'));
+      dbms.print(numtoyminterval(-14, 'MONTH'));
+      IF sysdate > d THEN
+        dbms.print('later');
+      END IF;
+      IF d = '15-MAR-2019' THEN
+        dbms.print('equal');
+      END IF;
+      IF trunc(d) = '15-MAR-2019' THEN
+        dbms.print('equal');
+      END IF;
+      dbms.print(to_date('30-FEB-2019', 'DD-MON-YYYY'));
+    END;
+
+END main;
+/
@@ -0,0 +1,67 @@
+--
+-- Copyright 2019 Marco Helmich
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+--     http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+--
+
+CREATE OR REPLACE PACKAGE BODY main AS
+
+    PROCEDURE main IS
+      line VARCHAR2(100);
+      got VARCHAR2(200);
+      status INTEGER;
+      i INT := 0;
+      lines DBMS_OUTPUT.CHARARR;
+      rest DBMSOUTPUT_LINESARRAY;
+      numlines INTEGER := 2;
+    BEGIN
+      dbms_output.put_line('Hello World!');
+      dbms_output.put('a');
+      dbms_output.put('b');
+      dbms_output.new_line;
+      dbms_output.put_line(42);
+      dbms_output.put_line(to_date('15-MAR-2019'));
+      -- the lines collected so far are written before
+      dbms.print('printed in order');
+      dbms_output.put_line('first');
+      dbms_output.put_line('second');
+      dbms_output.get_line(line, status);
+      got := line || ' ' || status;
+      dbms_output.get_line(line, status);
+      got := got || ', ' || line || ' ' || status;
+      dbms_output.get_line(line, status);
+      dbms.print('got ' || got || ', [' || line || '] ' || status);
+      dbms_output.put_line('one');
+      dbms_output.put_line('two');
+      dbms_output.put_line('three');
+      dbms_output.get_lines(lines, numlines);
+      status := numlines;
+      numlines := 10;
+      dbms_output.get_lines(rest, numlines);
+      dbms.print('got ' || status || ' ' || lines(1) || ' ' || lines(2) || ', ' || numlines || ' ' || rest(rest.LAST));
+      dbms_output.disable;
+      dbms_output.put_line('never shown');
+      dbms_output.enable;
+      dbms_output.put_line('shown');
+      dbms_output.put('not finished');
+      dbms_output.new_line;
+      dbms_output.put('never finished');
+      dbms_output.enable(2000);
+      WHILE i < 200 LOOP
+        dbms_output.put_line('0123456789');
+        i := i + 1;
+      END LOOP;
+    END;
+
+END main;
+/
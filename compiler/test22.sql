@@ -0,0 +1,65 @@
+--
+-- Copyright 2019 Marco Helmich
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+--     http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+--
+
+CREATE OR REPLACE PACKAGE BODY main AS
+
+    PROCEDURE main IS
+      v_name VARCHAR2(20);
+      v_salary NUMBER;
+      emp_count INT;
+      new_id INT := 4;
+      raise_by NUMBER := 1.5;
+      v_hired DATE;
+      v_code CHAR(5);
+    BEGIN
+      SELECT name, salary INTO v_name, v_salary FROM emp WHERE id = 1;
+      dbms.print(v_name || ' earns ' || v_salary);
+      dbms.print('rows ' || SQL%ROWCOUNT);
+
+      INSERT INTO emp (id, name, salary, hired, code) VALUES (new_id, 'O''Brien', 1200, '2020-02-01', 'OB');
+      IF SQL%FOUND THEN
+        dbms.print('inserted ' || SQL%ROWCOUNT);
+      END IF;
+      SELECT hired, code INTO v_hired, v_code FROM emp WHERE name = 'O''Brien';
+      dbms.print(to_char(v_hired, 'YYYY-MM-DD') || ' [' || v_code || ']');
+
+      UPDATE emp SET salary = salary * raise_by WHERE salary < 2000;
+      dbms.print('updated ' || SQL%ROWCOUNT);
+
+      SELECT COUNT(*) INTO emp_count FROM emp e WHERE e.salary > 1500 AND e.id <> new_id;
+      dbms.print('count ' || emp_count);
+
+      MERGE INTO emp e USING bonus b ON (e.id = b.emp_id)
+      WHEN MATCHED THEN UPDATE SET e.salary = e.salary + b.amount
+      WHEN NOT MATCHED THEN INSERT (e.id, e.name, e.salary, e.hired, e.code) VALUES (b.emp_id, 'NEW', b.amount, '2021-01-01', 'NW');
+      dbms.print('merged ' || SQL%ROWCOUNT);
+
+      SELECT SUM(salary) INTO v_salary FROM emp;
+      dbms.print('total ' || v_salary);
+
+      DELETE FROM emp WHERE id > 3;
+      dbms.print('deleted ' || SQL%ROWCOUNT);
+      DELETE FROM emp WHERE id > 3;
+      IF SQL%NOTFOUND THEN
+        dbms.print('nothing deleted');
+      END IF;
+
+      SELECT name INTO v_name FROM emp WHERE id = 99;
+      dbms.print('not reached');
+    END main;
+
+END main;
+/
@@ -0,0 +1,57 @@
+--
+-- Copyright 2019 Marco Helmich
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+--     http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+--
+
+CREATE OR REPLACE PACKAGE BODY main AS
+
+    PROCEDURE main IS
+      v_name VARCHAR2(20);
+      v_salary NUMBER;
+      min_salary NUMBER := 1000;
+      CURSOR all_emps IS SELECT name, salary FROM emp ORDER BY id;
+      CURSOR rich(p_min NUMBER, p_name VARCHAR2) IS
+        SELECT name FROM emp WHERE salary > p_min AND name <> p_name ORDER BY name;
+    BEGIN
+      OPEN all_emps;
+      FETCH all_emps INTO v_name, v_salary;
+      WHILE all_emps%FOUND LOOP
+        dbms.print(all_emps%ROWCOUNT || ' ' || v_name || ' ' || v_salary);
+        FETCH all_emps INTO v_name, v_salary;
+      END LOOP;
+      dbms.print('fetched ' || all_emps%ROWCOUNT);
+      IF all_emps%NOTFOUND THEN
+        dbms.print('no more rows');
+      END IF;
+      FETCH all_emps INTO v_name, v_salary;
+      dbms.print('still ' || v_name);
+      CLOSE all_emps;
+
+      OPEN rich(min_salary, 'KING');
+      IF rich%ISOPEN THEN
+        dbms.print('open');
+      END IF;
+      FETCH rich INTO v_name;
+      dbms.print(v_name);
+      CLOSE rich;
+      OPEN rich(5000, 'NOBODY');
+      FETCH rich INTO v_name;
+      dbms.print(rich%ROWCOUNT || ' ' || v_name);
+      CLOSE rich;
+      CLOSE rich;
+      dbms.print('not reached');
+    END main;
+
+END main;
+/
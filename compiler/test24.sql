@@ -0,0 +1,72 @@
+--
+-- Copyright 2019 Marco Helmich
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+--     http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+--
+
+CREATE OR REPLACE PACKAGE BODY main AS
+    TYPE emp_t IS RECORD (name VARCHAR2(20), salary NUMBER);
+
+    FUNCTION first_above(p_min NUMBER) RETURN VARCHAR2 IS
+    BEGIN
+      FOR rec IN (SELECT name FROM emp WHERE salary > p_min ORDER BY id) LOOP
+        RETURN rec.name;
+      END LOOP;
+      RETURN 'nobody';
+    END first_above;
+
+    PROCEDURE main IS
+      total NUMBER := 0;
+      CURSOR by_salary(p_min NUMBER) IS
+        SELECT e.name, salary * 2 AS doubled FROM emp e WHERE salary > p_min ORDER BY salary;
+      CURSOR typed RETURN emp_t IS SELECT name, salary FROM emp ORDER BY id;
+    BEGIN
+      FOR rec IN (SELECT id, name, salary FROM emp ORDER BY id) LOOP
+        total := total + rec.salary;
+        IF rec.salary > 1000 THEN
+          dbms.print(rec.id || ' ' || rec.name || ' ' || rec.salary);
+        END IF;
+      END LOOP;
+      dbms.print('total ' || total);
+
+      FOR rec IN by_salary(700) LOOP
+        dbms.print(rec.name || ' ' || rec.doubled);
+        FOR b IN (SELECT amount FROM bonus WHERE emp_id = (SELECT id FROM emp WHERE name = rec.name)) LOOP
+          dbms.print('  bonus ' || b.amount);
+        END LOOP;
+      END LOOP;
+
+      FOR rec IN typed LOOP
+        total := total - rec.salary;
+      END LOOP;
+      dbms.print('total ' || total);
+      IF typed%ISOPEN THEN
+        dbms.print('not reached');
+      END IF;
+
+      dbms.print(first_above(1000));
+      dbms.print(first_above(1000));
+      dbms.print(first_above(9000));
+
+      FOR rec IN (SELECT name FROM emp WHERE salary > 9000) LOOP
+        dbms.print('not reached');
+      END LOOP;
+
+      OPEN typed;
+      FOR rec IN typed LOOP
+        dbms.print('not reached');
+      END LOOP;
+    END main;
+
+END main;
+/
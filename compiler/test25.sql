@@ -0,0 +1,63 @@
+--
+-- Copyright 2019 Marco Helmich
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+--     http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+--
+
+CREATE OR REPLACE PACKAGE BODY main AS
+
+    PROCEDURE main IS
+      v_sql VARCHAR2(200);
+      v_count INT;
+      v_name VARCHAR2(20);
+      v_salary NUMBER;
+      tbl VARCHAR2(30) := 'audit_log';
+      rc SYS_REFCURSOR;
+    BEGIN
+      EXECUTE IMMEDIATE 'CREATE TABLE ' || tbl || ' (id INTEGER, msg TEXT)';
+      EXECUTE IMMEDIATE 'INSERT INTO ' || tbl || ' VALUES (:1, :2)' USING 1, 'created';
+      EXECUTE IMMEDIATE 'INSERT INTO ' || tbl || ' VALUES (:1, :2)' USING IN 2, 'raised ' || 'salaries';
+      dbms.print(SQL%ROWCOUNT);
+
+      EXECUTE IMMEDIATE 'UPDATE emp SET salary = salary + :amount WHERE salary < :limit' USING 100, 2000;
+      dbms.print('updated ' || SQL%ROWCOUNT);
+
+      v_sql := 'SELECT COUNT(*) FROM ' || tbl;
+      EXECUTE IMMEDIATE v_sql INTO v_count;
+      dbms.print(tbl || ' has ' || v_count || ' rows');
+      EXECUTE IMMEDIATE 'SELECT name, salary FROM emp WHERE id = :id' INTO v_name, v_salary USING 3;
+      dbms.print(v_name || ' ' || v_salary);
+
+      IF rc%ISOPEN THEN
+        dbms.print('not reached');
+      END IF;
+      OPEN rc FOR 'SELECT name FROM emp WHERE salary > :1 ORDER BY name' USING 1000;
+      FETCH rc INTO v_name;
+      WHILE rc%FOUND LOOP
+        dbms.print(rc%ROWCOUNT || ' ' || v_name);
+        FETCH rc INTO v_name;
+      END LOOP;
+
+      -- opening the cursor variable again closes the first query
+      OPEN rc FOR 'SELECT msg FROM ' || tbl || ' ORDER BY id DESC';
+      FETCH rc INTO v_name;
+      dbms.print(v_name);
+      CLOSE rc;
+
+      EXECUTE IMMEDIATE 'DROP TABLE ' || tbl;
+      EXECUTE IMMEDIATE 'DELETE FROM emp WHERE id = :1';
+      dbms.print('not reached');
+    END main;
+
+END main;
+/
@@ -0,0 +1,46 @@
+--
+-- Copyright 2019 Marco Helmich
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+--     http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+--
+
+CREATE OR REPLACE PACKAGE BODY main AS
+
+    PROCEDURE main IS
+      n INT := 3;
+      v_total NUMBER;
+    BEGIN
+      FORALL i IN 1..n
+        INSERT INTO bonus VALUES (i, i * 10);
+      dbms.print('inserted ' || SQL%ROWCOUNT);
+      dbms.print('run 2 inserted ' || SQL%BULK_ROWCOUNT(2));
+
+      FORALL i IN 1..n + 2
+        UPDATE bonus SET amount = amount + 1 WHERE emp_id = i;
+      dbms.print('updated ' || SQL%ROWCOUNT);
+      dbms.print('run 1 updated ' || SQL%BULK_ROWCOUNT(1));
+      dbms.print('run 4 updated ' || SQL%BULK_ROWCOUNT(4));
+      dbms.print('run 5 updated ' || SQL%BULK_ROWCOUNT(5));
+
+      SELECT SUM(amount) INTO v_total FROM bonus;
+      dbms.print('total ' || v_total);
+
+      FORALL i IN 2..1
+        DELETE FROM bonus;
+      dbms.print('deleted ' || SQL%ROWCOUNT);
+
+      dbms.print(SQL%BULK_ROWCOUNT(9));
+    END main;
+
+END main;
+/
@@ -0,0 +1,74 @@
+--
+-- Copyright 2019 Marco Helmich
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+--     http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+--
+
+CREATE OR REPLACE PACKAGE BODY main AS
+
+    PROCEDURE log_bonus(p_id INT, p_amount NUMBER) IS
+      PRAGMA AUTONOMOUS_TRANSACTION;
+    BEGIN
+      INSERT INTO bonus VALUES (p_id, p_amount);
+      COMMIT;
+    END log_bonus;
+
+    FUNCTION zero_salaries RETURN INT IS
+      PRAGMA AUTONOMOUS_TRANSACTION;
+      v_count INT;
+    BEGIN
+      UPDATE emp SET salary = 0;
+      v_count := SQL%ROWCOUNT;
+      ROLLBACK;
+      RETURN v_count;
+    END zero_salaries;
+
+    PROCEDURE forget_bonus IS
+      PRAGMA AUTONOMOUS_TRANSACTION;
+    BEGIN
+      DELETE FROM bonus;
+    END forget_bonus;
+
+    PROCEDURE main IS
+      v_count INT;
+      v_salary NUMBER;
+    BEGIN
+      INSERT INTO bonus VALUES (2, 50);
+      SAVEPOINT before_raise;
+      UPDATE emp SET salary = salary * 2;
+      ROLLBACK TO SAVEPOINT before_raise;
+      SELECT salary INTO v_salary FROM emp WHERE id = 2;
+      SELECT COUNT(*) INTO v_count FROM bonus;
+      dbms.print(v_salary || ' ' || v_count);
+
+      ROLLBACK;
+      SELECT COUNT(*) INTO v_count FROM bonus;
+      dbms.print('rolled back ' || v_count);
+
+      log_bonus(7, 70);
+      dbms.print('zeroed ' || zero_salaries());
+      INSERT INTO bonus VALUES (3, 10);
+      ROLLBACK;
+      -- what the autonomous transactions committed survives the rollback of the caller
+      SELECT SUM(salary) INTO v_salary FROM emp;
+      SELECT COUNT(*) INTO v_count FROM bonus;
+      dbms.print(v_salary || ' ' || v_count);
+
+      INSERT INTO bonus VALUES (3, 10);
+      COMMIT WORK;
+      forget_bonus;
+      dbms.print('not reached');
+    END main;
+
+END main;
+/
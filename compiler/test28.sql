@@ -0,0 +1,34 @@
+--
+-- Copyright 2019 Marco Helmich
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+--     http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+--
+
+CREATE OR REPLACE PACKAGE BODY main AS
+
+    PROCEDURE main IS
+      v_name VARCHAR2(20) := 'KING';
+      v_raise NUMBER := 2.5;
+      v_salary NUMBER;
+    BEGIN
+      UPDATE emp SET salary = salary + v_raise WHERE name = v_name;
+      dbms.print('updated ' || SQL%ROWCOUNT);
+      FORALL i IN 1..2
+        INSERT INTO bonus VALUES (i, 100);
+      EXECUTE IMMEDIATE 'DELETE FROM bonus WHERE emp_id = :1' USING 7;
+      COMMIT;
+      SELECT salary INTO v_salary FROM emp WHERE name = v_name;
+    END main;
+
+END main;
+/
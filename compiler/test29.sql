@@ -0,0 +1,49 @@
+--
+-- Copyright 2019 Marco Helmich
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+--     http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+--
+
+CREATE OR REPLACE PACKAGE BODY main AS
+
+    FUNCTION describe(r IN emp%ROWTYPE) RETURN VARCHAR2 IS
+    BEGIN
+      RETURN r.id || ' ' || r.name || ' ' || r.salary;
+    END describe;
+
+    PROCEDURE main IS
+      one emp%ROWTYPE;
+      bon bonus%ROWTYPE;
+      total NUMBER := 0;
+      CURSOR high_paid RETURN emp%ROWTYPE IS
+        SELECT * FROM emp WHERE salary > 1000 ORDER BY id;
+    BEGIN
+      SELECT * INTO one FROM emp WHERE id = 2;
+      dbms.print(describe(one));
+
+      SELECT emp_id, amount INTO bon FROM bonus WHERE emp_id = 1;
+      dbms.print(bon.emp_id + 1 || ' ' || bon.amount);
+
+      FOR r IN high_paid LOOP
+        dbms.print(describe(r));
+      END LOOP;
+
+      FOR r IN (SELECT e.id, e.salary, b.amount FROM emp e JOIN bonus b ON b.emp_id = e.id) LOOP
+        total := total + r.salary + r.amount;
+        dbms.print(r.id * 10);
+      END LOOP;
+      dbms.print('total ' || total);
+    END main;
+
+END main;
+/
@@ -0,0 +1,79 @@
+--
+-- Copyright 2019 Marco Helmich
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+--     http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+--
+
+CREATE OR REPLACE PACKAGE report AS
+    TYPE emp_t IS RECORD (id INT, name VARCHAR2(20));
+    TYPE emp_cur IS REF CURSOR RETURN emp_t;
+
+    FUNCTION paid_over(min_salary IN NUMBER) RETURN emp_cur;
+    PROCEDURE open_bonus(rc OUT SYS_REFCURSOR);
+END report;
+/
+
+CREATE OR REPLACE PACKAGE BODY report AS
+
+    FUNCTION paid_over(min_salary IN NUMBER) RETURN emp_cur IS
+      rc emp_cur;
+    BEGIN
+      OPEN rc FOR SELECT id, name FROM emp WHERE salary > min_salary ORDER BY id;
+      RETURN rc;
+    END paid_over;
+
+    PROCEDURE open_bonus(rc OUT SYS_REFCURSOR) IS
+    BEGIN
+      OPEN rc FOR 'SELECT emp_id, amount FROM bonus ORDER BY emp_id';
+    END open_bonus;
+
+END report;
+/
+
+CREATE OR REPLACE PACKAGE BODY main AS
+
+    PROCEDURE print_emps(rc IN report.emp_cur) IS
+      e report.emp_t;
+    BEGIN
+      FETCH rc INTO e;
+      WHILE rc%FOUND LOOP
+        dbms.print(e.id || ' ' || e.name);
+        FETCH rc INTO e;
+      END LOOP;
+      dbms.print(rc%ROWCOUNT || ' rows');
+      CLOSE rc;
+    END print_emps;
+
+    PROCEDURE main IS
+      emps report.emp_cur;
+      bonuses SYS_REFCURSOR;
+      id INT;
+      amount NUMBER;
+    BEGIN
+      emps := report.paid_over(1000);
+      print_emps(emps);
+
+      report.open_bonus(bonuses);
+      IF bonuses%ISOPEN THEN
+        FETCH bonuses INTO id, amount;
+        dbms.print(id || ' ' || amount);
+        dbms.print('open');
+      END IF;
+      CLOSE bonuses;
+      IF bonuses%ISOPEN THEN
+        dbms.print('not closed');
+      END IF;
+    END main;
+
+END main;
+/
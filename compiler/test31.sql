@@ -0,0 +1,41 @@
+--
+-- Copyright 2019 Marco Helmich
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+--     http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+--
+
+-- NUMBERs are doubles that are compared and printed by their first 15 significant digits
+CREATE OR REPLACE PACKAGE BODY main AS
+  PROCEDURE main IS
+    a NUMBER := 0.1;
+    b NUMBER := 0.2;
+    big NUMBER := 100000000000000000000.0;
+    d BINARY_DOUBLE := 0.1;
+  BEGIN
+    IF a + b = 0.3 THEN
+      dbms.print('equal');
+    END IF;
+    dbms.print(a + b);
+    dbms.print(big);
+    dbms.print(-123456789012345678.0);
+    dbms.print(big * big * big * big);
+    dbms.print(0.00000000000000000000000000000000000000000000000000000000000000000000001);
+    dbms.print(-0.5);
+    dbms.print(0.0);
+    -- BINARY_DOUBLEs are compared bit by bit
+    IF d + d + d <> 0.3 THEN
+      dbms.print('not equal');
+    END IF;
+  END;
+END main;
+/
@@ -0,0 +1,30 @@
+--
+-- Copyright 2019 Marco Helmich
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+--     http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+--
+
+-- the characters of strings are never freed, a program can only take up so much memory for them
+CREATE OR REPLACE PACKAGE BODY main AS
+  PROCEDURE main IS
+    i INT := 0;
+    s VARCHAR2(100);
+  BEGIN
+    WHILE i < 100000 LOOP
+      s := 'row ' || to_char(i);
+      i := i + 1;
+    END LOOP;
+    dbms.print(s);
+  END;
+END main;
+/
@@ -0,0 +1,38 @@
+--
+-- Copyright 2019 Marco Helmich
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+--     http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+--
+
+CREATE OR REPLACE PACKAGE BODY main AS
+
+    PROCEDURE main IS
+      salary NUMBER := 150;
+      total NUMBER;
+    BEGIN
+      -- the column wins over the local of the same name
+      UPDATE emp SET salary = salary * 2;
+      FOR r IN (SELECT id, salary FROM emp ORDER BY id) LOOP
+        dbms.print(r.id || ' ' || r.salary);
+      END LOOP;
+      SELECT SUM(salary) INTO total FROM emp;
+      dbms.print('total ' || total);
+
+      -- the columns of the table rows are inserted into aren't names of VALUES
+      INSERT INTO emp (id, salary) VALUES (3, salary);
+      SELECT e.salary INTO total FROM emp e WHERE e.id = 3;
+      dbms.print('inserted ' || total);
+    END main;
+
+END main;
+/
@@ -0,0 +1,48 @@
+--
+-- Copyright 2019 Marco Helmich
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+--     http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+--
+
+CREATE OR REPLACE PACKAGE BODY main AS
+
+    FUNCTION committed_bonuses RETURN INT IS
+      PRAGMA AUTONOMOUS_TRANSACTION;
+      v_count INT;
+    BEGIN
+      SELECT COUNT(*) INTO v_count FROM bonus;
+      RETURN v_count;
+    END committed_bonuses;
+
+    PROCEDURE log_bonus(p_id INT, p_amount NUMBER) IS
+      PRAGMA AUTONOMOUS_TRANSACTION;
+    BEGIN
+      INSERT INTO bonus VALUES (p_id, p_amount);
+      COMMIT;
+    END log_bonus;
+
+    PROCEDURE main IS
+      v_count INT;
+    BEGIN
+      INSERT INTO bonus VALUES (9, 90);
+      SELECT COUNT(*) INTO v_count FROM bonus;
+      -- the autonomous transaction doesn't see what its caller hasn't committed
+      dbms.print(v_count || ' ' || committed_bonuses());
+      -- but it can't write while its caller holds the lock, SQLite has a single writer per file
+      -- the caller would have to commit first, so this raises ORA-00060 instead of waiting forever
+      log_bonus(8, 80);
+      dbms.print('not reached');
+    END main;
+
+END main;
+/
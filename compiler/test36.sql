@@ -0,0 +1,82 @@
+--
+-- Copyright 2019 Marco Helmich
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+--     http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+--
+
+CREATE OR REPLACE PACKAGE BODY main AS
+    TYPE name_list IS TABLE OF VARCHAR2(20);
+    TYPE salary_map IS TABLE OF NUMBER INDEX BY PLS_INTEGER;
+    TYPE id_array IS VARRAY(3) OF INT;
+    TYPE emp_t IS RECORD (id INT, name VARCHAR2(20));
+    TYPE emp_list IS TABLE OF emp_t;
+
+    g_names name_list;
+
+    PROCEDURE main IS
+      v_names name_list := name_list();
+      v_copy name_list;
+      v_salaries salary_map;
+      v_ids id_array;
+      v_emps emp_list;
+      v_idx INT;
+      CURSOR by_id IS SELECT id, name FROM emp ORDER BY id;
+    BEGIN
+      v_names.EXTEND(2);
+      v_names(1) := 'KING';
+      v_names(2) := 'SMITH';
+      v_copy := v_names;
+      v_copy(1) := 'ALLEN';
+      dbms.print(v_names.COUNT || ' ' || v_names(1) || ' ' || v_copy(1));
+      v_names.TRIM;
+      dbms.print(v_names.COUNT || ' ' || v_names.LAST);
+
+      v_salaries(30) := 3000;
+      v_salaries(10) := 1000;
+      v_salaries(20) := 2000;
+      v_salaries.DELETE(20);
+      v_idx := v_salaries.FIRST;
+      WHILE v_idx > 0 LOOP
+        dbms.print(v_idx || ' ' || v_salaries(v_idx));
+        v_idx := v_salaries.NEXT(v_idx);
+      END LOOP;
+      IF v_salaries.EXISTS(20) THEN
+        dbms.print('not reached');
+      END IF;
+
+      v_ids := id_array(4, 5);
+      dbms.print(v_ids.COUNT || ' of ' || v_ids.LIMIT || ' ' || v_ids(2));
+
+      SELECT name BULK COLLECT INTO g_names FROM emp WHERE salary > 1000 ORDER BY id;
+      dbms.print('selected ' || SQL%ROWCOUNT || ' ' || g_names(1) || ' ' || g_names(2));
+
+      OPEN by_id;
+      FETCH by_id BULK COLLECT INTO v_emps LIMIT 2;
+      dbms.print('fetched ' || v_emps.COUNT || ' ' || v_emps(2).name);
+      FETCH by_id BULK COLLECT INTO v_emps LIMIT 2;
+      dbms.print('fetched ' || v_emps.COUNT || ' ' || v_emps(1).id || ' ' || v_emps(1).name);
+      CLOSE by_id;
+
+      FORALL i IN 1..v_ids.COUNT
+        INSERT INTO bonus VALUES (v_ids(i), v_ids(i) * 100);
+      dbms.print('inserted ' || SQL%ROWCOUNT);
+
+      v_ids.EXTEND;
+      v_ids(3) := 1;
+      FORALL i IN 1..v_ids.COUNT SAVE EXCEPTIONS
+        INSERT INTO emp (id, name) VALUES (v_ids(i) + 1, 'NEW');
+      dbms.print('not reached');
+    END main;
+
+END main;
+/
@@ -0,0 +1,43 @@
+--
+-- Copyright 2019 Marco Helmich
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+--     http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+--
+
+CREATE OR REPLACE PACKAGE BODY main AS
+
+    TYPE pair IS RECORD (id INT, name VARCHAR2(10));
+
+    -- the second call must not see what the first one left on the stack
+    PROCEDURE show IS
+      n INT;
+      d NUMBER;
+      s VARCHAR2(10);
+      p pair;
+    BEGIN
+      dbms.print(n || ' ' || d || ' [' || s || '] ' || p.id || ' [' || p.name || ']');
+      n := 42;
+      d := 2.5;
+      s := 'left over';
+      p.id := 7;
+      p.name := 'KING';
+    END show;
+
+    PROCEDURE main IS
+    BEGIN
+      show;
+      show;
+    END main;
+
+END main;
+/
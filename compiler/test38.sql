@@ -0,0 +1,49 @@
+--
+-- Copyright 2019 Marco Helmich
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+--     http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+--
+
+-- strings that are built by concatenation grow in place, they only take up their final length
+-- the test runs it with a heap of 1 MB, copying the strings in every iteration would take more than 50 MB
+CREATE OR REPLACE PACKAGE BODY main AS
+
+    PROCEDURE main IS
+      i INT := 0;
+      s VARCHAR2(32767);
+      ids VARCHAR2(32767);
+      amounts VARCHAR2(32767);
+    BEGIN
+      WHILE i < 30000 LOOP
+        s := s || 'x';
+        i := i + 1;
+      END LOOP;
+      dbms.print(length(s));
+
+      i := 0;
+      WHILE i < 5000 LOOP
+        ids := ids || i || ',';
+        i := i + 1;
+      END LOOP;
+      dbms.print(length(ids) || ' ' || substr(ids, length(ids) - 9));
+
+      i := 0;
+      WHILE i < 4000 LOOP
+        amounts := amounts || ' ' || i / 4;
+        i := i + 1;
+      END LOOP;
+      dbms.print(length(amounts) || substr(amounts, length(amounts) - 15));
+    END main;
+
+END main;
+/
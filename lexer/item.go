@@ -31,13 +31,39 @@ const (
 	SeparatorType
 	TextType
 	CommentType
+	InquiryDirectiveType
 )
 
+var itemTypeNames = map[ItemType]string{
+	EofType:              "EOF",
+	ErrorType:            "ERROR",
+	IdentifierType:       "IDENTIFIER",
+	KeywordType:          "KEYWORD",
+	NumericType:          "NUMERIC",
+	StringType:           "STRING",
+	OperatorType:         "OPERATOR",
+	SeparatorType:        "SEPARATOR",
+	TextType:             "TEXT",
+	CommentType:          "COMMENT",
+	InquiryDirectiveType: "INQUIRY_DIRECTIVE",
+}
+
+func (t ItemType) String() string {
+	if name, ok := itemTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("ItemType(%d)", uint8(t))
+}
+
 type Item struct {
 	Typ      ItemType
 	Value    string
 	StartPos int
 	EndPos   int
+	// line the item starts on, the first line is 1
+	Line int
+	// column the item starts at in bytes, the first column is 1
+	Column int
 }
 
 func (i *Item) String() string {
@@ -28,13 +28,18 @@ type Lexer struct {
 	start int
 	pos   int
 	width int
-	items chan *Item
+	// line of start
+	line int
+	// offset of the first character of the line of start
+	lineStart int
+	items     chan *Item
 }
 
 func NewLexer(name string, input string) (*Lexer, <-chan *Item) {
 	l := &Lexer{
 		name:  name,
 		input: input,
+		line:  1,
 		items: make(chan *Item),
 	}
 
@@ -61,7 +66,7 @@ func (l *Lexer) lastLexed() rune {
 
 func (l *Lexer) emit(t ItemType) {
 	txt := l.input[l.start:l.pos]
-	if t == KeywordType || t == IdentifierType {
+	if t == KeywordType || t == IdentifierType || t == InquiryDirectiveType {
 		txt = strings.ToUpper(txt)
 	}
 	l.items <- &Item{
@@ -69,8 +74,10 @@ func (l *Lexer) emit(t ItemType) {
 		Value:    txt,
 		StartPos: l.start,
 		EndPos:   l.pos,
+		Line:     l.line,
+		Column:   l.column(),
 	}
-	l.start = l.pos
+	l.ignore()
 }
 
 func (l *Lexer) next() (rune rune) {
@@ -85,9 +92,19 @@ func (l *Lexer) next() (rune rune) {
 }
 
 func (l *Lexer) ignore() {
+	skipped := l.input[l.start:l.pos]
+	if n := strings.LastIndex(skipped, "\n"); n >= 0 {
+		l.line += strings.Count(skipped, "\n")
+		l.lineStart = l.start + n + 1
+	}
 	l.start = l.pos
 }
 
+// column of start, the first column is 1
+func (l *Lexer) column() int {
+	return l.start - l.lineStart + 1
+}
+
 func (l *Lexer) backup() {
 	l.pos -= l.width
 }
@@ -128,6 +145,8 @@ func (l *Lexer) errorf(format string, args ...interface{}) stateFunc {
 		Typ:      ErrorType,
 		Value:    fmt.Sprintf(format, args...),
 		StartPos: l.start,
+		Line:     l.line,
+		Column:   l.column(),
 	}
 	return nil
 }
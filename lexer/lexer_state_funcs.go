@@ -17,21 +17,35 @@
 package lexer
 
 import (
-	"log"
 	"strings"
 )
 
 const (
 	commentToken = "--"
+	// prefix of inquiry directives such as $$DEBUG
+	inquiryToken = "$$"
 
 	alphaChars   = "qwertyuiopasdfghjklzxcvbnmQWERTYUIOPASDFGHJKLZXCVBNM"
 	numericChars = "1234567890"
 	specialChars = "_"
 
 	separatorChars = ";(),/"
-	operatorChars  = "<>:.=-" // contains ':' so that ':=' can be found
+	operatorChars  = "<>:.=-+*|!%" // contains ':' so that ':=' can be found
 )
 
+// maps the first character of an operator to all characters
+// that can follow it to form a two-character operator
+var compoundOperators = map[rune]string{
+	':': "=",
+	'<': "=>",
+	'>': "=",
+	'=': ">",
+	'!': "=",
+	'|': "|",
+	'.': ".",
+	'*': "*",
+}
+
 // if types are keywords, the parser gets more complicated
 // as it might need to parse a keywords (primitive types) or
 // identifiers (custom types)
@@ -51,6 +65,36 @@ var keywords = map[string]bool{
 	"ELSE":      true,
 	"LOOP":      true,
 	"WHILE":     true,
+	"FOR":       true,
+	"FUNCTION":  true,
+	"RETURN":    true,
+	"CONSTANT":  true,
+	"TYPE":      true,
+	"SUBTYPE":   true,
+	"RECORD":    true,
+	"IN":        true,
+	"OUT":       true,
+	"DEFAULT":   true,
+	// statements of embedded SQL
+	"SELECT": true,
+	"INSERT": true,
+	"UPDATE": true,
+	"DELETE": true,
+	"MERGE":  true,
+	// explicit cursors
+	"CURSOR": true,
+	"OPEN":   true,
+	"FETCH":  true,
+	"CLOSE":  true,
+	// dynamic SQL
+	"EXECUTE": true,
+	// bulk SQL
+	"FORALL": true,
+	// transaction control
+	"COMMIT":    true,
+	"ROLLBACK":  true,
+	"SAVEPOINT": true,
+	"PRAGMA":    true,
 }
 
 type stateFunc func(*Lexer) stateFunc
@@ -64,33 +108,36 @@ func lexText(l *Lexer) stateFunc {
 			continue
 		}
 
-		for {
-			switch r := l.next(); {
-			case r == int32(EofType):
-				l.emit(EofType)
-				return nil
-			case isSpace(r):
-				l.ignore()
-			case contains(separatorChars, r):
-				return lexSeparator
-			case contains(operatorChars, r):
-				return lexOperator
-			case r == '\'':
-				return lexString
-			case contains(alphaChars, r):
-				l.backup()
-				return lexIdentifier
-			case contains(numericChars+"+-", r):
-				return lexNumeric
-			default:
-				log.Panicf("Found %s but can't match a rule", string(r))
-			}
+		switch r := l.next(); {
+		case r == int32(EofType):
+			l.emit(EofType)
+			return nil
+		case isSpace(r):
+			l.ignore()
+		case contains(separatorChars, r):
+			return lexSeparator
+		case contains(operatorChars, r):
+			l.backup()
+			return lexOperator
+		case r == '\'':
+			return lexString
+		case r == '$':
+			l.backup()
+			return lexInquiryDirective
+		case contains(alphaChars, r):
+			l.backup()
+			return lexIdentifier
+		case contains(numericChars, r):
+			return lexNumeric
+		default:
+			return l.errorf("Found %s but can't match a rule", string(r))
 		}
 	}
 }
 
 func lexOperator(l *Lexer) stateFunc {
-	l.accept("=") // try accepting a '=' to complete a probable ':='
+	// try accepting a second character to complete a probable ':=', '<>', '||', ...
+	l.accept(compoundOperators[l.next()])
 	l.emit(OperatorType)
 	return lexText
 }
@@ -111,6 +158,17 @@ func lexIdentifier(l *Lexer) stateFunc {
 	return lexText
 }
 
+func lexInquiryDirective(l *Lexer) stateFunc {
+	if !strings.HasPrefix(l.input[l.pos:], inquiryToken) {
+		return l.errorf("Inquiry directives need to start with '%s'", inquiryToken)
+	}
+
+	l.pos += len(inquiryToken)
+	l.acceptMany(alphaChars + numericChars + specialChars)
+	l.emit(InquiryDirectiveType)
+	return lexText
+}
+
 func lexString(l *Lexer) stateFunc {
 	for {
 		l.acceptUntilOneOf("'")
@@ -126,6 +184,13 @@ func lexString(l *Lexer) stateFunc {
 
 func lexNumeric(l *Lexer) stateFunc {
 	l.acceptMany(numericChars)
+	// a '.' followed by a digit continues a decimal number
+	// while '..' is the range operator ('1..10')
+	rest := l.input[l.pos:]
+	if len(rest) > 1 && rest[0] == '.' && contains(numericChars, rune(rest[1])) {
+		l.next()
+		l.acceptMany(numericChars)
+	}
 	l.emit(NumericType)
 	return lexText
 }
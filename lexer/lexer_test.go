@@ -85,6 +85,22 @@ func TestNumeric(t *testing.T) {
 	assert.Equal(t, "9876", i.Value)
 }
 
+func TestDecimalNumeric(t *testing.T) {
+	_, items := NewLexer("test-decimal", "3.25 1..10")
+	i := <-items
+	assert.Equal(t, NumericType, i.Typ)
+	assert.Equal(t, "3.25", i.Value)
+	i = <-items
+	assert.Equal(t, NumericType, i.Typ)
+	assert.Equal(t, "1", i.Value)
+	i = <-items
+	assert.Equal(t, OperatorType, i.Typ)
+	assert.Equal(t, "..", i.Value)
+	i = <-items
+	assert.Equal(t, NumericType, i.Typ)
+	assert.Equal(t, "10", i.Value)
+}
+
 func TestCommentAndThenString(t *testing.T) {
 	_, items := NewLexer("", "-- narf narf narf \n' 9876'")
 	i := <-items
@@ -115,6 +131,21 @@ func TestStringEscapedQuote(t *testing.T) {
 	assert.Equal(t, EofType, i.Typ, i.String())
 }
 
+func TestInquiryDirective(t *testing.T) {
+	_, items := NewLexer("", "x := $$debug;")
+	i := <-items
+	assert.Equal(t, IdentifierType, i.Typ, i.String())
+	i = <-items
+	assert.Equal(t, OperatorType, i.Typ, i.String())
+	i = <-items
+	assert.Equal(t, InquiryDirectiveType, i.Typ, i.String())
+	assert.Equal(t, "$$DEBUG", i.Value)
+	i = <-items
+	assert.Equal(t, SeparatorType, i.Typ, i.String())
+	i = <-items
+	assert.Equal(t, EofType, i.Typ, i.String())
+}
+
 func TestNarf(t *testing.T) {
 	assert.True(t, strings.IndexRune(alphaChars, 'N') >= 0)
 }
@@ -268,3 +299,34 @@ func runTest(itemTypes []ItemType, items <-chan *Item, t *testing.T) {
 		assert.Equal(t, itemTypes[idx], i.Typ, fmt.Sprintf("idx: %d item: %s", idx, i.String()))
 	}
 }
+
+func TestCompoundOperators(t *testing.T) {
+	_, items := NewLexer("", "a <> b || c <= 1 -- trailing comment\n  -- indented comment\n+")
+	expected := []string{"A", "<>", "B", "||", "C", "<=", "1", "+"}
+	for idx := range expected {
+		i := <-items
+		assert.Equal(t, expected[idx], i.Value, i.String())
+	}
+	i := <-items
+	assert.Equal(t, EofType, i.Typ, i.String())
+}
+
+func TestLines(t *testing.T) {
+	_, items := NewLexer("", "a := 'x\ny';\n-- comment\n\n  b\n")
+	expected := map[string]int{"A": 1, ":=": 1, "'x\ny'": 1, ";": 2, "B": 5}
+	for i := range items {
+		if i.Typ == EofType {
+			break
+		}
+		assert.Equal(t, expected[i.Value], i.Line, i.String())
+	}
+
+	_, items = NewLexer("", "a := 'x\ny';\n  b\n")
+	columns := map[string]int{"A": 1, ":=": 3, "'x\ny'": 6, ";": 3, "B": 3}
+	for i := range items {
+		if i.Typ == EofType {
+			break
+		}
+		assert.Equal(t, columns[i.Value], i.Column, i.String())
+	}
+}
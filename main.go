@@ -18,54 +18,222 @@ package main
 
 import (
 	"flag"
+	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
 
 	"github.com/mhelmich/plsqlc/compiler"
+	"github.com/mhelmich/plsqlc/lexer"
+	"github.com/mhelmich/plsqlc/runtime"
 )
 
+type command struct {
+	name        string
+	description string
+	run         func(args []string)
+}
+
+var commands = []*command{
+	{"lex", "print the tokens of the input files", lex},
+	{"parse", "print the syntax tree of the program", parse},
+	{"check", "run the semantic analysis of the program", check},
+	{"emit-ir", "write the LLVM IR of the program", emitIR},
+	{"build", "compile the program into a binary", build},
+	{"run", "compile the program into a temporary binary and execute it", run},
+}
+
 func main() {
-	inFilePath, outFilePath, printIR, deleteIR := parseArgs()
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
 
-	if !fileExists(inFilePath) {
-		log.Panicf("File '%s' doesn't exist", inFilePath)
-		return
+	for _, cmd := range commands {
+		if cmd.name == os.Args[1] {
+			// the compiler reports errors by panicking with the message it just logged
+			// therefore a stack trace doesn't add anything for the user
+			// anything else is a bug in the compiler and keeps its stack trace
+			defer func() {
+				if r := recover(); r != nil {
+					if _, ok := r.(string); ok {
+						os.Exit(1)
+					}
+					panic(r)
+				}
+			}()
+			cmd.run(os.Args[2:])
+			return
+		}
 	}
 
-	compiler.Compile(inFilePath, outFilePath, printIR, deleteIR)
+	usage()
+	os.Exit(2)
 }
 
-func parseArgs() (string, string, bool, bool) {
-	inFilePathPtr := flag.String("i", "", "path to input sql file")
-	outFilePathPtr := flag.String("o", "", "path to the output binary")
-	printIRPtr := flag.Bool("pir", false, "whether or not to print LLVM IR onto the terminal")
-	deleteIRPtr := flag.Bool("dir", true, "whether or not to delete intermediate files")
-	flag.Parse()
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: plsqlc <command> [flags] [files, directories or globs]\n\n")
+	fmt.Fprintf(os.Stderr, "Without inputs the sources are read from the project manifest (%s).\n\n", compiler.ManifestFileName)
+	fmt.Fprintf(os.Stderr, "Commands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-8s %s\n", cmd.name, cmd.description)
+	}
+	fmt.Fprintf(os.Stderr, "\n")
+	printDatabaseUsage()
+}
 
-	var inFilePath string
-	if *inFilePathPtr == "" {
-		inFilePath = flag.Arg(0)
-	} else {
-		inFilePath = *inFilePathPtr
+// printDatabaseUsage explains where the binaries that build and run make find their database
+func printDatabaseUsage() {
+	fmt.Fprintf(os.Stderr, "Embedded SQL runs against the SQLite database file that %s names when the program runs.\n", runtime.DatabaseEnvVar)
+	fmt.Fprintf(os.Stderr, "  %s=app.db plsqlc run src/\n", runtime.DatabaseEnvVar)
+}
+
+// flags that are shared by all commands that compile a program
+type compileFlags struct {
+	flags        *flag.FlagSet
+	manifestPath *string
+	outFilePath  *string
+	printIR      *bool
+	deleteIR     *bool
+	driver       *string
+	schema       *string
+	debug        *bool
+}
+
+func newCompileFlags(name string) *compileFlags {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	if name == "build" || name == "run" {
+		flags.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage of %s:\n", name)
+			flags.PrintDefaults()
+			fmt.Fprintf(os.Stderr, "\n")
+			printDatabaseUsage()
+		}
+	}
+	return &compileFlags{
+		flags:        flags,
+		manifestPath: flags.String("f", compiler.ManifestFileName, "path to the project manifest, used when no inputs are given"),
+		outFilePath:  flags.String("o", "", "path to the output file"),
+		printIR:      flags.Bool("pir", false, "whether or not to print LLVM IR onto the terminal"),
+		deleteIR:     flags.Bool("dir", true, "whether or not to delete intermediate files, they are written to a temporary directory"),
+		driver:       flags.String("driver", "", "database driver of embedded SQL: sqlite, stub or the path of an object file that implements the driver interface"),
+		schema:       flags.String("schema", "", "path to the DDL of the database, embedded SQL is checked against its tables"),
+		debug:        flags.Bool("g", false, "whether or not to emit debug info, it turns off optimizations"),
 	}
+}
+
+// options reads the build options either from the inputs on the command line
+// or from the project manifest
+func (cf *compileFlags) options(args []string, defaultOutFilePath string) *compiler.Options {
+	cf.flags.Parse(args)
 
-	var outFilePath string
-	if *outFilePathPtr == "" {
-		outFilePath = "out"
+	var opts *compiler.Options
+	if cf.flags.NArg() > 0 {
+		files, err := compiler.ExpandInputPaths(cf.flags.Args())
+		if err != nil {
+			log.Panicf("%s", err.Error())
+		}
+		opts = compiler.NewOptions(files, defaultOutFilePath)
 	} else {
-		outFilePath = *outFilePathPtr
+		m, err := compiler.LoadManifest(*cf.manifestPath)
+		if err != nil {
+			log.Panicf("%s", err.Error())
+		}
+		opts, err = m.Options()
+		if err != nil {
+			log.Panicf("%s", err.Error())
+		}
 	}
 
-	return inFilePath, outFilePath, *printIRPtr, *deleteIRPtr
+	if len(opts.InputPaths) == 0 {
+		log.Panicf("No input files")
+	}
+
+	if *cf.outFilePath != "" {
+		opts.OutputPath = *cf.outFilePath
+	}
+	if *cf.driver != "" {
+		opts.Driver = *cf.driver
+	}
+	if *cf.schema != "" {
+		opts.Schema = *cf.schema
+	}
+	if *cf.debug {
+		// locals only survive in registers and memory a debugger can find without optimizations
+		opts.Debug = true
+		opts.OptLevel = "0"
+	}
+	opts.PrintIR = *cf.printIR
+	opts.DeleteLlvmIR = *cf.deleteIR
+	return opts
+}
+
+func lex(args []string) {
+	opts := newCompileFlags("lex").options(args, "")
+	for _, path := range opts.InputPaths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			log.Panicf("%s", err.Error())
+		}
+
+		_, items := lexer.NewLexer(path, string(data))
+		for i := range items {
+			fmt.Printf("%s:%d-%d %s %q\n", path, i.StartPos, i.EndPos, i.Typ.String(), i.Value)
+		}
+	}
+}
+
+func parse(args []string) {
+	opts := newCompileFlags("parse").options(args, "")
+	namesToPackages := compiler.Parse(opts)
+	names := make([]string, 0, len(namesToPackages))
+	for name := range namesToPackages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Print(namesToPackages[name].String())
+	}
+}
+
+func check(args []string) {
+	opts := newCompileFlags("check").options(args, "")
+	compiler.Check(opts)
+}
+
+func emitIR(args []string) {
+	opts := newCompileFlags("emit-ir").options(args, "out.ll")
+	compiler.EmitIR(opts)
 }
 
-func fileExists(path string) bool {
-	_, err := os.Stat(path)
-	if err == nil {
-		return true
+func build(args []string) {
+	opts := newCompileFlags("build").options(args, "out")
+	compiler.Build(opts)
+}
+
+func run(args []string) {
+	opts := newCompileFlags("run").options(args, "")
+	tmpDir, err := ioutil.TempDir("", "plsqlc")
+	if err != nil {
+		log.Panicf("%s", err.Error())
 	}
-	if os.IsNotExist(err) {
-		return false
+	defer os.RemoveAll(tmpDir)
+
+	opts.OutputPath = filepath.Join(tmpDir, "out")
+	compiler.Build(opts)
+
+	cmd := exec.Command(opts.OutputPath)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	err = cmd.Run()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		os.RemoveAll(tmpDir)
+		os.Exit(exitErr.ExitCode())
+	} else if err != nil {
+		log.Panicf("%s", err.Error())
 	}
-	return true
 }
@@ -29,6 +29,28 @@ type parser struct {
 	packages     map[string]*ast.Package
 	peekableItem *lexer.Item
 	sem          chan interface{}
+	// line of the last item read from the input
+	line int
+	// what the parser goroutine panicked with
+	failure interface{}
+}
+
+// ParseError is a syntax error at a line of the parsed source
+type ParseError struct {
+	Line    int
+	Message string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%d: %s", e.Line, e.Message)
+}
+
+// parseFailure is what the parser panics with when it can't make sense of its input
+// it's recovered where parsing started and turned into a *ParseError
+type parseFailure string
+
+func panicf(format string, args ...interface{}) {
+	panic(parseFailure(fmt.Sprintf(format, args...)))
 }
 
 func NewParser(input <-chan *lexer.Item) *parser {
@@ -47,23 +69,49 @@ func newParser(input <-chan *lexer.Item) *parser {
 	return p
 }
 
-func (p *parser) GetPackageAsts() map[string]*ast.Package {
+// GetPackageAsts waits for the parser to finish and returns the packages it found
+// syntax errors come back as *ParseError, anything else the parser goroutine panicked with is re-panicked here
+func (p *parser) GetPackageAsts() (map[string]*ast.Package, error) {
 	if p.sem != nil {
 		<-p.sem
 		p.sem = nil
 	}
 
-	return p.packages
+	if p.failure != nil {
+		if err := p.parseError(p.failure); err != nil {
+			return nil, err
+		}
+		panic(p.failure)
+	}
+	return p.packages, nil
 }
 
 func (p *parser) run() {
+	defer close(p.sem)
+	defer func() {
+		if r := recover(); r != nil {
+			p.failure = r
+			// let the lexer run to completion instead of blocking on a channel nobody reads
+			for range p.input {
+			}
+		}
+	}()
+
 	state := parseText
 	pc := &parserContext{}
 	for state != nil {
 		state, pc = state(p, pc)
 	}
+}
 
-	close(p.sem)
+// parseError turns what the parser panicked with into a *ParseError
+// it returns nil for anything that isn't a syntax error
+func (p *parser) parseError(r interface{}) *ParseError {
+	msg, ok := r.(parseFailure)
+	if !ok {
+		return nil
+	}
+	return &ParseError{Line: p.line, Message: string(msg)}
 }
 
 func (p *parser) next() *lexer.Item {
@@ -72,18 +120,43 @@ func (p *parser) next() *lexer.Item {
 		p.peekableItem = nil
 		return tmp
 	}
-	return <-p.input
+	return p.read()
 }
 
 func (p *parser) peek() *lexer.Item {
 	if p.peekableItem == nil {
-		p.peekableItem = <-p.input
+		p.peekableItem = p.read()
 	}
 	return p.peekableItem
 }
 
-func (p *parser) addPackage(pkg *ast.Package) {
-	p.packages[pkg.Name] = pkg
+// read takes the next item off the input
+// errors the lexer or an earlier stage reports become syntax errors
+func (p *parser) read() *lexer.Item {
+	i := <-p.input
+	if i == nil {
+		return i
+	}
+	p.line = i.Line
+	if i.Typ == lexer.ErrorType {
+		panicf("%s", i.Value)
+	}
+	return i
+}
+
+// addPackage links a package spec or body with what has been parsed for the package so far
+// it returns the package that further declarations should be added to
+func (p *parser) addPackage(pkg *ast.Package) *ast.Package {
+	existing, ok := p.packages[pkg.Name]
+	if !ok {
+		p.packages[pkg.Name] = pkg
+		return pkg
+	}
+
+	if err := existing.Merge(pkg); err != nil {
+		panicf("%s", err.Error())
+	}
+	return existing
 }
 
 func (p *parser) acceptValue(valid string) bool {
@@ -108,6 +181,7 @@ func (p *parser) errorf(format string, args ...interface{}) stateFunc {
 }
 
 type parserContext struct {
+	spec     *ast.PackageSpec
 	pkg      *ast.Package
 	function *ast.Function
 	block    *ast.Block
@@ -17,37 +17,57 @@
 package parser
 
 import (
-	"log"
+	"strconv"
+	"strings"
 
 	"github.com/mhelmich/plsqlc/ast"
 	"github.com/mhelmich/plsqlc/lexer"
 )
 
 func parseInsideBlock(p *parser, pc *parserContext) {
-	pkg := pc.pkg
 	f := pc.function
 	blk := pc.block
 	for {
-		switch i := p.next(); i.Typ {
+		i := p.next()
+		blk.SetPosition(i.Line, i.Column)
+		switch i.Typ {
 		case lexer.IdentifierType:
-			// could be a qualified function call ('package.func()'), a local function call ('func()') or an assignment ('a:=12')
+			// could be a qualified function call ('package.func()'), a local function call ('func()'),
+			// an assignment ('a:=12', 'rec.field:=12', 'package.var:=12', 'list(i):=12')
+			// or a method of a collection ('list.EXTEND', 'list.DELETE(i)')
 			if p.acceptValue(".") {
-				fc := parseQualifiedFunctionCall(p, i.Value)
-				blk.AddInstruction(fc)
+				ok, name := p.acceptType(lexer.IdentifierType)
+				if !ok && p.acceptValue("DELETE") {
+					ok, name = true, "DELETE"
+				}
+				if !ok {
+					panicf("Can't find identifier after '%s.'", i.Value)
+				}
+
+				if p.acceptValue(":=") {
+					a := parseAssignment(p, ast.NewQualifiedVariable(i.Value, name))
+					blk.AddInstruction(a)
+					continue
+				}
+
+				blk.AddInstruction(parseQualifiedFunctionCall(p, i.Value, name))
 				continue
 
 			} else if p.acceptValue("(") {
-				fc := parseLocalFunctionCall(p, pkg.Name, i.Value)
-				blk.AddInstruction(fc)
+				blk.AddInstruction(parseLocalFunctionCall(p, i.Value))
 				continue
 
 			} else if p.acceptValue(":=") {
-				a := parseAssignment(p, i.Value)
+				a := parseAssignment(p, ast.NewVariable(i.Value))
 				blk.AddInstruction(a)
 				continue
+			} else if p.acceptValue(";") {
+				// local procedure call without arguments
+				blk.AddInstruction(ast.NewFunctionCall("", i.Value))
+				continue
 			}
 
-			log.Panicf("Shouldn't reach here!")
+			panicf("Shouldn't reach here!")
 
 		case lexer.KeywordType:
 			switch i.Value {
@@ -56,17 +76,20 @@ func parseInsideBlock(p *parser, pc *parserContext) {
 				p.acceptValue("IF")
 				// eat a potential 'LOOP'
 				p.acceptValue("LOOP")
+				// eat a potential label ('END proc_name;')
+				p.acceptType(lexer.IdentifierType)
 
 				if ok := p.acceptValue(";"); !ok {
-					log.Panicf("Can't find ';' lex item")
+					panicf("Can't find ';' lex item")
 				}
-				pc.block = nil
+				// the block the body ends in, it is followed by whatever comes after the body
+				pc.block = blk
 				return
 
 			case "IF":
-				cond := parseBinOp(p)
+				cond := parseExpression(p)
 				if ok := p.acceptValue("THEN"); !ok {
-					log.Panicf("Can't find 'THEN' lex item")
+					panicf("Can't find 'THEN' lex item")
 				}
 
 				// put if block into context (the old one is still saved in 'blk')
@@ -75,22 +98,120 @@ func parseInsideBlock(p *parser, pc *parserContext) {
 				f.AddBlock(ifBlk)
 
 				parseInsideBlock(p, pc)
+				ifTail := pc.block
 				// create new merge block for after if branches
 				// and prime pointers
 				mergeBlk := ast.NewBlock("merge-block")
 				pc.block = mergeBlk
 				f.AddBlock(mergeBlk)
 
-				ifBlk.Terminator = ast.NewBranch(mergeBlk)
+				ifTail.Terminator = ast.NewBranch(mergeBlk)
 				blk.Terminator = ast.NewConditionalBranch(cond, ifBlk, mergeBlk)
 
 				blk = mergeBlk
 				continue
 
+			case "RETURN":
+				var expr ast.Expression
+				if p.peek().Value != ";" {
+					expr = parseExpression(p)
+				}
+				if ok := p.acceptValue(";"); !ok {
+					panicf("Can't find ';' lex item")
+				}
+				blk.AddInstruction(ast.NewRetrn(expr))
+				continue
+
+			case "SELECT", "INSERT", "UPDATE", "DELETE", "MERGE":
+				blk.AddInstruction(parseSqlStatement(p, i))
+				continue
+
+			case "OPEN":
+				blk.AddInstruction(parseOpenCursor(p))
+				continue
+
+			case "EXECUTE":
+				if ok := p.acceptValue("IMMEDIATE"); !ok {
+					panicf("Can't find 'IMMEDIATE' lex item")
+				}
+				ei := ast.NewExecuteImmediate(parseExpression(p))
+				if p.peek().Value == "BULK" {
+					panicf("BULK COLLECT in EXECUTE IMMEDIATE is not implemented yet")
+				}
+				if p.acceptValue("INTO") {
+					parseSqlInto(p, ei)
+				}
+				parseUsing(p, ei)
+				if ok := p.acceptValue(";"); !ok {
+					panicf("Can't find ';' lex item")
+				}
+				blk.AddInstruction(ei)
+				continue
+
+			case "FORALL":
+				blk.AddInstruction(parseForAll(p))
+				continue
+
+			case "COMMIT":
+				p.acceptValue("WORK")
+				blk.AddInstruction(ast.NewCommit())
+				if ok := p.acceptValue(";"); !ok {
+					panicf("Can't find ';' lex item")
+				}
+				continue
+
+			case "ROLLBACK":
+				p.acceptValue("WORK")
+				savepoint := ""
+				if p.acceptValue("TO") {
+					p.acceptValue("SAVEPOINT")
+					savepoint = parseSavepointName(p)
+				}
+				blk.AddInstruction(ast.NewRollback(savepoint))
+				if ok := p.acceptValue(";"); !ok {
+					panicf("Can't find ';' lex item")
+				}
+				continue
+
+			case "SAVEPOINT":
+				blk.AddInstruction(ast.NewSavepoint(parseSavepointName(p)))
+				if ok := p.acceptValue(";"); !ok {
+					panicf("Can't find ';' lex item")
+				}
+				continue
+
+			case "FETCH":
+				fc := ast.NewFetchCursor(parseCursorName(p))
+				if p.acceptValue("BULK") {
+					if ok := p.acceptValue("COLLECT"); !ok {
+						panicf("Can't find 'COLLECT' after 'BULK'")
+					}
+					fc.Bulk = true
+				}
+				if ok := p.acceptValue("INTO"); !ok {
+					panicf("Can't find 'INTO' after 'FETCH %s'", fc.Cursor)
+				}
+				parseSqlInto(p, fc)
+				if fc.Bulk && p.acceptValue("LIMIT") {
+					fc.Limit = parseExpression(p)
+				}
+				if ok := p.acceptValue(";"); !ok {
+					panicf("Can't find ';' lex item")
+				}
+				blk.AddInstruction(fc)
+				continue
+
+			case "CLOSE":
+				blk.AddInstruction(ast.NewCloseCursor(parseCursorName(p)))
+				if ok := p.acceptValue(";"); !ok {
+					panicf("Can't find ';' lex item")
+				}
+				continue
+
 			case "WHILE":
-				cond := parseBinOp(p)
+				cond := parseExpression(p)
 				if ok := p.acceptValue("LOOP"); !ok {
-					log.Panicf("Can't find 'LOOP' lex item")
+					panicf("Can't find 'LOOP' lex item")
 				}
 
 				loopBlk := ast.NewBlock("loop-block")
@@ -98,26 +219,78 @@ func parseInsideBlock(p *parser, pc *parserContext) {
 				f.AddBlock(loopBlk)
 
 				parseInsideBlock(p, pc)
+				loopTail := pc.block
 
 				mergeBlk := ast.NewBlock("merge-block")
+				pc.block = mergeBlk
 				f.AddBlock(mergeBlk)
 				blk.Terminator = ast.NewConditionalBranch(cond, loopBlk, mergeBlk)
-				loopBlk.Terminator = ast.NewConditionalBranch(cond, loopBlk, mergeBlk)
+				loopTail.Terminator = ast.NewConditionalBranch(cond, loopBlk, mergeBlk)
+
+				blk = mergeBlk
+				continue
+
+			case "FOR":
+				loop := parseCursorForLoop(p)
+				blk.AddInstruction(loop)
+
+				// every iteration starts with fetching a row
+				fetchBlk := ast.NewBlock("fetch-block")
+				f.AddBlock(fetchBlk)
+				fetchBlk.AddInstruction(loop.Fetch)
+				blk.Terminator = ast.NewBranch(fetchBlk)
+
+				loopBlk := ast.NewBlock("loop-block")
+				pc.block = loopBlk
+				f.AddBlock(loopBlk)
+
+				parseInsideBlock(p, pc)
+				pc.block.Terminator = ast.NewBranch(fetchBlk)
+
+				mergeBlk := ast.NewBlock("merge-block")
+				pc.block = mergeBlk
+				f.AddBlock(mergeBlk)
+				fetchBlk.Terminator = ast.NewConditionalBranch(loop.Found(), loopBlk, mergeBlk)
+				mergeBlk.AddInstruction(loop.End())
 
 				blk = mergeBlk
 				continue
 
 			default:
-				log.Panicf("Can't match lex item '%s'", i.Value)
+				panicf("Can't match lex item '%s'", i.Value)
 			}
 		default:
-			log.Panicf("Can't match lex item '%s'", i.Value)
+			panicf("Can't match lex item '%s'", i.Value)
 		}
 	}
 }
 
-func parseBinOp(p *parser) *ast.BinOp {
-	return parseBinOpExpression(p, p.next())
+// binary operators and their precedence, higher binds tighter
+var binOpPrecedences = map[string]int{
+	"=":  1,
+	"<>": 1,
+	"!=": 1,
+	"<":  1,
+	">":  1,
+	"<=": 1,
+	">=": 1,
+	"+":  2,
+	"-":  2,
+	"||": 2,
+	"*":  3,
+	"/":  3,
+}
+
+func binOpPrecedence(i *lexer.Item) int {
+	if i.Typ != lexer.OperatorType && i.Value != "/" {
+		return -1
+	}
+
+	prec, ok := binOpPrecedences[i.Value]
+	if !ok {
+		return -1
+	}
+	return prec
 }
 
 // an expression can be:
@@ -131,6 +304,32 @@ func parseExpression(p *parser) ast.Expression {
 }
 
 func parseExpressionFromLexItem(p *parser, i *lexer.Item) ast.Expression {
+	left := parsePrimaryExpression(p, i)
+	return parseBinOpRHS(p, 0, left)
+}
+
+// parseBinOpRHS folds all following operators with a precedence
+// of at least minPrecedence into the left hand side expression
+func parseBinOpRHS(p *parser, minPrecedence int, left ast.Expression) ast.Expression {
+	for {
+		opItem := p.peek()
+		prec := binOpPrecedence(opItem)
+		if prec < 0 || prec < minPrecedence {
+			return left
+		}
+		p.next()
+
+		right := parsePrimaryExpression(p, p.next())
+		// if the next operator binds tighter, it gets the right hand side first
+		if binOpPrecedence(p.peek()) > prec {
+			right = parseBinOpRHS(p, prec+1, right)
+		}
+
+		left = ast.NewBinOp(left, opItem.Value, right)
+	}
+}
+
+func parsePrimaryExpression(p *parser, i *lexer.Item) ast.Expression {
 	switch i.Typ {
 	case lexer.StringType:
 		return ast.NewStringLiteral(i.Value)
@@ -138,88 +337,724 @@ func parseExpressionFromLexItem(p *parser, i *lexer.Item) ast.Expression {
 	case lexer.NumericType:
 		return ast.NewNumericLiteral(i.Value)
 
+	case lexer.OperatorType:
+		if i.Value == "-" && p.peek().Typ == lexer.NumericType {
+			return ast.NewNumericLiteral("-" + p.next().Value)
+		}
+		panicf("Can't match lex item '%s'", i.Value)
+
+	case lexer.SeparatorType:
+		if i.Value == "(" {
+			expr := parseExpression(p)
+			if ok := p.acceptValue(")"); !ok {
+				panicf("Can't find ')' lex item")
+			}
+			return expr
+		}
+		panicf("Can't match lex item '%s'", i.Value)
+
 	case lexer.IdentifierType:
 		// this could be a function call or a variable
-		if p.peek().Value == "(" {
-			// function call
-			log.Panicf("not implemented yet")
-		} else if p.peek().Typ == lexer.OperatorType {
-			// bin op
-			return parseBinOpExpression(p, i)
-		} else {
-			// variable
-			return ast.NewVariable(i.Value)
+		if i.Value == "SYSDATE" || i.Value == "SYSTIMESTAMP" {
+			// functions without arguments are called without parentheses
+			return ast.NewFunctionCall("", i.Value)
+		} else if i.Value == "EXTRACT" && p.acceptValue("(") {
+			return parseExtract(p)
+		} else if p.acceptValue("%") {
+			// attribute of a cursor ('SQL%ROWCOUNT')
+			ok, attribute := p.acceptType(lexer.IdentifierType)
+			if !ok {
+				panicf("Can't find attribute after '%s%%'", i.Value)
+			}
+			ca := ast.NewCursorAttribute(i.Value, attribute)
+			if attribute == "BULK_ROWCOUNT" && p.acceptValue("(") {
+				// the rows of one run of a FORALL ('SQL%BULK_ROWCOUNT(i)')
+				ca.Index = parseExpression(p)
+				if ok := p.acceptValue(")"); !ok {
+					panicf("Can't find ')' lex item")
+				}
+			}
+			return ca
+		} else if p.acceptValue("(") {
+			// local function call or element of a collection ('list(i)')
+			fc := ast.NewFunctionCall("", i.Value)
+			parseFunctionCallArgs(p, fc)
+			return parseElementField(p, ast.NewVariable(i.Value), fc)
+		} else if p.acceptValue(".") {
+			ok, name := p.acceptType(lexer.IdentifierType)
+			if !ok {
+				panicf("Can't find identifier after '%s.'", i.Value)
+			}
+
+			if p.acceptValue("(") {
+				// qualified function call
+				fc := ast.NewFunctionCall(i.Value, name)
+				parseFunctionCallArgs(p, fc)
+				return parseElementField(p, ast.NewQualifiedVariable(i.Value, name), fc)
+			}
+			// record field or package variable
+			return ast.NewQualifiedVariable(i.Value, name)
+		}
+		// variable
+		return ast.NewVariable(i.Value)
+
+	case lexer.KeywordType:
+		// REPLACE is a keyword ('CREATE OR REPLACE') and the name of a built-in function
+		if i.Value == "REPLACE" && p.acceptValue("(") {
+			fc := ast.NewFunctionCall("", i.Value)
+			parseFunctionCallArgs(p, fc)
+			return fc
 		}
-		log.Panicf("not implemented yet")
+		panicf("Can't match lex item '%s'", i.Value)
 
 	default:
-		log.Panicf("Can't match lex item '%s'", i.Value)
+		panicf("Can't match lex item '%s'", i.Value)
 	}
 
 	return nil
 }
 
-func parseBinOpExpression(p *parser, leftItem *lexer.Item) *ast.BinOp {
-	// eat all the items from the parser before parsing them
-	opItem := p.next()
-	rightItem := p.next()
+// parseSqlStatement parses an embedded SQL statement up to and including the ';'
+// the statement is kept as a list of tokens, the variables in the INTO clause of a query are taken out
+func parseSqlStatement(p *parser, first *lexer.Item) *ast.SqlStatement {
+	return parseSqlUntil(p, first, ";")
+}
+
+// parseSqlUntil parses an embedded SQL statement up to and including end
+// end isn't part of the statement, it isn't nested in parentheses
+func parseSqlUntil(p *parser, first *lexer.Item, end string) *ast.SqlStatement {
+	stmt := ast.NewSqlStatement(first.Value)
+	stmt.AddToken(first.Value, false, false)
+	depth := 0
+	for {
+		i := p.next()
+		switch {
+		case i.Typ == lexer.EofType:
+			panicf("Can't find '%s' after '%s' statement", end, first.Value)
+		case i.Value == end && depth == 0:
+			return stmt
+		case i.Value == "(":
+			depth++
+		case i.Value == ")":
+			depth--
+		case i.Value == "BULK" && depth == 0 && first.Value == "SELECT":
+			if ok := p.acceptValue("COLLECT"); !ok {
+				panicf("Can't find 'COLLECT' after 'BULK'")
+			}
+			if ok := p.acceptValue("INTO"); !ok {
+				panicf("Can't find 'INTO' after 'BULK COLLECT'")
+			}
+			stmt.Bulk = true
+			parseSqlInto(p, stmt)
+			continue
+		case i.Value == "INTO" && depth == 0 && first.Value == "SELECT":
+			parseSqlInto(p, stmt)
+			continue
+		}
+		stmt.AddToken(i.Value, i.Typ == lexer.IdentifierType, i.Typ == lexer.StringType)
+	}
+}
+
+// parseSqlInto parses the variables ('a, rec.b') after the INTO of a query or a FETCH
+func parseSqlInto(p *parser, stmt intoTarget) {
+	for {
+		ok, name := p.acceptType(lexer.IdentifierType)
+		if !ok {
+			panicf("Can't find variable after INTO but got '%s'", p.peek().Value)
+		}
+
+		if p.acceptValue(".") {
+			ok, field := p.acceptType(lexer.IdentifierType)
+			if !ok {
+				panicf("Can't find identifier after '%s.'", name)
+			}
+			stmt.AddInto(ast.NewQualifiedVariable(name, field))
+		} else {
+			stmt.AddInto(ast.NewVariable(name))
+		}
 
-	if opItem.Typ != lexer.OperatorType {
-		log.Panicf("Lex item '%s' is not an operator\n", opItem.Value)
+		if !p.acceptValue(",") {
+			return
+		}
 	}
+}
 
-	left := parseExpressionFromLexItem(p, leftItem)
-	right := parseExpressionFromLexItem(p, rightItem)
-	return ast.NewBinOp(left, opItem.Value, right)
+// intoTarget is a statement that reads a row into variables
+type intoTarget interface {
+	AddInto(v *ast.Variable)
 }
 
-func parseQualifiedFunctionCall(p *parser, moduleName string) ast.Expression {
-	ok, funcName := p.acceptType(lexer.IdentifierType)
+// parseCursor parses 'name [(params)] [RETURN type] IS query;' after 'CURSOR'
+func parseCursor(p *parser) *ast.Cursor {
+	name := parseCursorName(p)
+	proto := ast.NewFunctionProto(name)
+	parseFunctionSignature(p, proto)
+	if ok := acceptAsOrIs(p); !ok {
+		panicf("Can't find 'IS' after cursor '%s'", name)
+	}
+
+	i := p.next()
+	if i.Value != "SELECT" {
+		panicf("Can't find query of cursor '%s' but got '%s'", name, i.Value)
+	}
+	query := parseSqlStatement(p, i)
+	if len(query.Into) > 0 {
+		panicf("PLS-00103: the query of cursor '%s' can't have an INTO clause", name)
+	}
+
+	return ast.NewCursor(proto, query)
+}
+
+func parseCursorName(p *parser) string {
+	ok, name := p.acceptType(lexer.IdentifierType)
 	if !ok {
-		log.Panicf("Can't find '%s' lex item", funcName)
+		panicf("Can't find cursor name but got '%s'", p.peek().Value)
 	}
+	return name
+}
 
-	fc := ast.NewFunctionCall(moduleName, funcName)
+// parseOpenCursor parses 'name [(args)];', 'name FOR text [USING args];' and 'name FOR query;' after 'OPEN'
+func parseOpenCursor(p *parser) ast.Instruction {
+	name := parseCursorName(p)
+	var o ast.Instruction
+	if p.acceptValue("FOR") {
+		if p.peek().Value == "SELECT" {
+			// a query that is known at compile time ends the statement
+			query := parseSqlStatement(p, p.next())
+			if len(query.Into) > 0 {
+				panicf("PLS-00103: the query cursor variable '%s' is opened for can't have an INTO clause", name)
+			}
+			return ast.NewOpenCursorForQuery(name, query)
+		}
+		ocf := ast.NewOpenCursorFor(name, parseExpression(p))
+		parseUsing(p, ocf)
+		o = ocf
+	} else {
+		o = parseCursorArgs(p, ast.NewOpenCursor(name))
+	}
 
-	if ok := p.acceptValue("("); !ok {
-		log.Panicf("Can't find '(' lex item")
+	if ok := p.acceptValue(";"); !ok {
+		panicf("Can't find ';' lex item")
+	}
+	return o
+}
+
+// parseUsing parses the optional bind arguments of dynamic SQL ('USING a, IN b')
+func parseUsing(p *parser, target usingTarget) {
+	if !p.acceptValue("USING") {
+		return
+	}
+
+	for {
+		p.acceptValue("IN")
+		if p.peek().Value == "OUT" {
+			panicf("Only IN bind arguments are implemented")
+		}
+		target.AddUsing(parseExpression(p))
+		if !p.acceptValue(",") {
+			return
+		}
 	}
+}
+
+// usingTarget is a statement with bind arguments
+type usingTarget interface {
+	AddUsing(expr ast.Expression)
+}
 
+// parseCursorArgs parses the optional arguments of a cursor ('(42, 'x')')
+func parseCursorArgs(p *parser, o *ast.OpenCursor) *ast.OpenCursor {
+	if p.acceptValue("(") {
+		for ok := p.acceptValue(")"); !ok; ok = p.acceptValue(")") {
+			o.AddArg(parseExpression(p))
+			p.acceptValue(",")
+		}
+	}
+	return o
+}
+
+// parseCursorForLoop parses 'rec IN cursor [(args)] LOOP' and 'rec IN (query) LOOP' after 'FOR'
+func parseCursorForLoop(p *parser) *ast.CursorForLoop {
+	ok, record := p.acceptType(lexer.IdentifierType)
+	if !ok {
+		panicf("Can't find record name after 'FOR' but got '%s'", p.peek().Value)
+	}
+	if ok := p.acceptValue("IN"); !ok {
+		panicf("Can't find 'IN' lex item")
+	}
+
+	var loop *ast.CursorForLoop
+	if p.acceptValue("(") {
+		i := p.next()
+		if i.Value != "SELECT" {
+			panicf("Can't find query of loop over '%s' but got '%s'", record, i.Value)
+		}
+		query := parseSqlUntil(p, i, ")")
+		if len(query.Into) > 0 {
+			panicf("PLS-00103: the query of loop over '%s' can't have an INTO clause", record)
+		}
+		loop = ast.NewQueryForLoop(record, query)
+	} else if p.peek().Typ == lexer.IdentifierType {
+		loop = ast.NewCursorForLoop(record, parseCursorArgs(p, ast.NewOpenCursor(parseCursorName(p))))
+	} else {
+		panicf("Only cursor FOR loops are implemented, can't loop over '%s'", p.peek().Value)
+	}
+
+	if ok := p.acceptValue("LOOP"); !ok {
+		panicf("Can't find 'LOOP' lex item")
+	}
+	return loop
+}
+
+// parseForAll parses 'i IN lower..upper statement;' after 'FORALL'
+func parseForAll(p *parser) *ast.ForAll {
+	ok, index := p.acceptType(lexer.IdentifierType)
+	if !ok {
+		panicf("Can't find index name after 'FORALL' but got '%s'", p.peek().Value)
+	}
+	if ok := p.acceptValue("IN"); !ok {
+		panicf("Can't find 'IN' lex item")
+	}
+	if v := p.peek().Value; v == "INDICES" || v == "VALUES" {
+		panicf("FORALL over %s OF is not implemented yet", v)
+	}
+
+	lower := parseExpression(p)
+	if ok := p.acceptValue(".."); !ok {
+		panicf("Can't find '..' lex item")
+	}
+	upper := parseExpression(p)
+	saveExceptions := p.acceptValue("SAVE")
+	if saveExceptions && !p.acceptValue("EXCEPTIONS") {
+		panicf("Can't find 'EXCEPTIONS' after 'SAVE'")
+	}
+
+	i := p.next()
+	switch i.Value {
+	case "INSERT", "UPDATE", "DELETE", "MERGE":
+	default:
+		panicf("PLS-00103: FORALL needs an INSERT, UPDATE, DELETE or MERGE statement but got '%s'", i.Value)
+	}
+	fa := ast.NewForAll(index, lower, upper, parseSqlStatement(p, i))
+	fa.SaveExceptions = saveExceptions
+	return fa
+}
+
+func parseSavepointName(p *parser) string {
+	ok, name := p.acceptType(lexer.IdentifierType)
+	if !ok {
+		panicf("Can't find savepoint name but got '%s'", p.peek().Value)
+	}
+	return name
+}
+
+// parseExtract parses 'EXTRACT(field FROM expr)' after the opening '('
+// the field is passed to the built-in function as a string ('EXTRACT('YEAR', expr)')
+func parseExtract(p *parser) ast.Expression {
+	ok, field := p.acceptType(lexer.IdentifierType)
+	if !ok {
+		panicf("Can't find field to extract but got '%s'", p.peek().Value)
+	}
+	if ok := p.acceptValue("FROM"); !ok {
+		panicf("Can't find 'FROM' lex item")
+	}
+
+	fc := ast.NewFunctionCall("", "EXTRACT")
+	fc.AddArg(ast.NewStringLiteral("'" + field + "'"))
+	fc.AddArg(parseExpression(p))
+	if ok := p.acceptValue(")"); !ok {
+		panicf("Can't find ')' lex item")
+	}
+	return fc
+}
+
+// parseFunctionCallArgs parses all args after the opening '('
+// up to and including the closing ')'
+func parseFunctionCallArgs(p *parser, fc *ast.FunctionCall) {
 	for ok := p.acceptValue(")"); !ok; ok = p.acceptValue(")") {
 		// there is moa parameterz
 		expr := parseExpression(p)
 		fc.AddArg(expr)
 		p.acceptValue(",")
 	}
+}
+
+// parseQualifiedFunctionCall parses a call of a procedure of a package or a method of a collection
+// or an assignment to an element of a collection of a package or record ('pkg.list(i) := x')
+func parseQualifiedFunctionCall(p *parser, moduleName string, funcName string) ast.Instruction {
+	fc := ast.NewFunctionCall(moduleName, funcName)
+
+	// procedures without arguments can be called without parentheses ('dbms_output.new_line;')
+	if p.acceptValue("(") {
+		parseFunctionCallArgs(p, fc)
+		if a := parseElementAssignment(p, ast.NewQualifiedVariable(moduleName, funcName), fc); a != nil {
+			return a
+		}
+	}
 
 	if ok := p.acceptValue(";"); !ok {
-		log.Panicf("Can't find ';' lex item")
+		panicf("Can't find ';' lex item")
 	}
 	return fc
 }
 
-func parseLocalFunctionCall(p *parser, moduleName string, funcName string) ast.Expression {
-	fc := ast.NewFunctionCall(moduleName, funcName)
+// parseLocalFunctionCall parses an unqualified call or an assignment to an element of a collection ('list(i) := x')
+// the function is looked up in the current package
+func parseLocalFunctionCall(p *parser, funcName string) ast.Instruction {
+	fc := ast.NewFunctionCall("", funcName)
 
-	for ok := p.acceptValue(")"); !ok; ok = p.acceptValue(")") {
-		// there is moa parameterz
-		expr := parseExpression(p)
-		fc.AddArg(expr)
-		p.acceptValue(",")
+	parseFunctionCallArgs(p, fc)
+	if a := parseElementAssignment(p, ast.NewVariable(funcName), fc); a != nil {
+		return a
 	}
 
 	if ok := p.acceptValue(";"); !ok {
-		log.Panicf("Can't find ';' lex item")
+		panicf("Can't find ';' lex item")
 	}
 	return fc
 }
 
-func parseAssignment(p *parser, identifier string) *ast.Assignment {
+// parseElementAssignment parses the rest of an assignment to an element of a collection after the index
+// ('list(i) := x' or 'list(i).field := x'), it returns nil if the call isn't followed by one
+func parseElementAssignment(p *parser, collection *ast.Variable, fc *ast.FunctionCall) *ast.Assignment {
+	var field string
+	if p.acceptValue(".") {
+		ok, name := p.acceptType(lexer.IdentifierType)
+		if !ok {
+			panicf("Can't find field after '%s(...).'", collection.Name)
+		}
+		field = name
+		if ok := p.acceptValue(":="); !ok {
+			panicf("Can't find ':=' lex item")
+		}
+	} else if !p.acceptValue(":=") {
+		return nil
+	}
+
+	if len(fc.Args) != 1 {
+		panicf("An element of '%s' needs exactly one index", collection.Name)
+	}
+	a := ast.NewElementAssignment(ast.NewCollectionElement(collection, fc.Args[0], field), parseExpression(p))
+	if ok := p.acceptValue(";"); !ok {
+		panicf("Can't find ';' lex item")
+	}
+	return a
+}
+
+// parseElementField parses the field of an element of a collection of records ('list(i).field')
+// calls that aren't followed by a field are returned as they are, the checker tells calls and elements apart
+func parseElementField(p *parser, collection *ast.Variable, fc *ast.FunctionCall) ast.Expression {
+	if p.peek().Value != "." {
+		return fc
+	}
+	p.next()
+	ok, field := p.acceptType(lexer.IdentifierType)
+	if !ok {
+		panicf("Can't find field after '%s(...).'", collection.Name)
+	}
+	if len(fc.Args) != 1 {
+		panicf("An element of '%s' needs exactly one index", collection.Name)
+	}
+	return ast.NewCollectionElement(collection, fc.Args[0], field)
+}
+
+func parseAssignment(p *parser, target *ast.Variable) *ast.Assignment {
 	expr := parseExpression(p)
-	a := ast.NewAssignment(identifier, expr)
+	a := ast.NewAssignment(target, expr)
 
 	if ok := p.acceptValue(";"); !ok {
-		log.Panicf("Can't find ';' lex item")
+		panicf("Can't find ';' lex item")
 	}
 	return a
 }
+
+func acceptAsOrIs(p *parser) bool {
+	return p.acceptValue("AS") || p.acceptValue("IS")
+}
+
+// endPackage parses the 'END name; /' at the end of a package spec or body
+func endPackage(p *parser, name string) {
+	if ok := p.acceptValue(name); !ok {
+		panicf("Can't find '%s' lex item", name)
+	}
+	if ok := p.acceptValue(";"); !ok {
+		panicf("Can't find ';' lex item")
+	}
+	if ok := p.acceptValue("/"); !ok {
+		panicf("Can't find '/' lex item")
+	}
+}
+
+// parseFunctionSignature parses the optional parameter list
+// and the optional return type of a procedure or function
+func parseFunctionSignature(p *parser, fp *ast.FunctionProto) {
+	if p.acceptValue("(") {
+		hasMore := true
+		for hasMore {
+			name := p.next().Value
+			ownership := "IN"
+			if p.acceptValue("IN") {
+				if p.acceptValue("OUT") {
+					ownership = "IN OUT"
+				}
+			} else if p.acceptValue("OUT") {
+				ownership = "OUT"
+			}
+			typ := parseTypeName(p)
+			fp.AddParam(name, ownership, typ)
+			sep := p.next().Value
+			hasMore = sep == ","
+		}
+	}
+
+	if p.acceptValue("RETURN") {
+		fp.ReturnType = parseTypeName(p)
+	}
+}
+
+// parseTypeName parses built-in types ('INT'), types of the same package ('my_type'),
+// types declared in other packages ('pkg.my_type') and rows of tables ('emp%ROWTYPE')
+func parseTypeName(p *parser) string {
+	ok, typ := p.acceptType(lexer.IdentifierType)
+	if !ok {
+		panicf("Can't find type name but got '%s'", p.peek().Value)
+	}
+
+	if typ == "INTERVAL" {
+		return parseIntervalTypeName(p)
+	} else if p.acceptValue(".") {
+		ok, name := p.acceptType(lexer.IdentifierType)
+		if !ok {
+			panicf("Can't find type name after '%s.'", typ)
+		}
+		typ = typ + "." + name
+	} else if p.acceptValue("(") {
+		typ = typ + "(" + parseTypeConstraint(p) + ")"
+	}
+
+	if p.acceptValue("%") {
+		// the record of a row of a table ('emp%ROWTYPE')
+		if attribute := p.next().Value; attribute != "ROWTYPE" {
+			panicf("Type attribute '%%%s' is not implemented yet, only %%ROWTYPE is", attribute)
+		}
+		typ = typ + "%ROWTYPE"
+	}
+	return typ
+}
+
+// parseIntervalTypeName parses the rest of 'INTERVAL DAY TO SECOND' and 'INTERVAL YEAR TO MONTH'
+// the precisions ('INTERVAL DAY(3) TO SECOND(2)') are ignored
+func parseIntervalTypeName(p *parser) string {
+	from := p.next().Value
+	if p.acceptValue("(") {
+		parseTypeConstraint(p)
+	}
+	if ok := p.acceptValue("TO"); !ok {
+		panicf("Can't find 'TO' lex item")
+	}
+	to := p.next().Value
+	if p.acceptValue("(") {
+		parseTypeConstraint(p)
+	}
+
+	typ := "INTERVAL " + from + " TO " + to
+	if typ != "INTERVAL DAY TO SECOND" && typ != "INTERVAL YEAR TO MONTH" {
+		panicf("Can't parse interval type '%s'", typ)
+	}
+	return typ
+}
+
+// parseTypeConstraint parses the constraint of a built-in type after the '('
+// e.g. the length of strings ('VARCHAR2(10 CHAR)') or the precision and scale of numbers ('NUMBER(10, 2)')
+func parseTypeConstraint(p *parser) string {
+	var sb strings.Builder
+	for !p.acceptValue(")") {
+		i := p.next()
+		if i.Typ == lexer.EofType {
+			panicf("Can't find ')' after type constraint")
+		}
+
+		if sb.Len() > 0 && i.Value != "," && !strings.HasSuffix(sb.String(), ",") {
+			sb.WriteString(" ")
+		}
+		sb.WriteString(i.Value)
+	}
+	return sb.String()
+}
+
+// parseDeclaration parses the rest of a variable declaration after the name
+// 'type [:= value | DEFAULT value];'
+func parseDeclaration(p *parser) (string, string) {
+	typ := parseTypeName(p)
+
+	var value string
+	if p.acceptValue(":=") || p.acceptValue("DEFAULT") {
+		if p.acceptValue("-") {
+			value = "-"
+		}
+		i := p.next()
+		value += i.Value
+		if i.Typ == lexer.IdentifierType {
+			// the only initializer that isn't a literal is the constructor of an empty collection ('list_t()', 'pkg.list_t()')
+			if p.acceptValue(".") {
+				ok, name := p.acceptType(lexer.IdentifierType)
+				if !ok {
+					panicf("Can't find identifier after '%s.'", i.Value)
+				}
+				value += "." + name
+			}
+			if value[0] == '-' || !p.acceptValue("(") {
+				panicf("Declarations can only be initialized with literals and empty collections ('list_t()'), not with '%s'", value)
+			}
+			if ok := p.acceptValue(")"); !ok {
+				panicf("Collections can only be initialized empty ('%s()')", value)
+			}
+			value += "()"
+		}
+	}
+
+	if ok := p.acceptValue(";"); !ok {
+		panicf("Can't find ';' lex item")
+	}
+	return typ, value
+}
+
+// parseTypeDeclaration parses the rest of 'TYPE name IS ...;', records, collections and ref cursors
+func parseTypeDeclaration(p *parser) ast.TypeDeclaration {
+	ok, name := p.acceptType(lexer.IdentifierType)
+	if !ok {
+		panicf("Can't find type name but got '%s'", p.peek().Value)
+	}
+	if ok := p.acceptValue("IS"); !ok {
+		panicf("Can't find 'is' lex item")
+	}
+
+	if p.acceptValue("RECORD") {
+		return parseRecordType(p, name)
+	} else if p.acceptValue("REF") {
+		return parseRefCursor(p, name)
+	} else if p.acceptValue("TABLE") {
+		return parseTableType(p, name)
+	} else if p.acceptValue("VARRAY") || (p.acceptValue("VARYING") && p.acceptValue("ARRAY")) {
+		return parseVarrayType(p, name)
+	}
+	panicf("Type '%s' is not implemented yet, only records, collections and ref cursors are", name)
+	return nil
+}
+
+// parseTableType parses 'OF type [NOT NULL] [INDEX BY PLS_INTEGER];' after 'name IS TABLE'
+// associative arrays can only be indexed by integers
+func parseTableType(p *parser, name string) *ast.CollectionType {
+	ct := ast.NewCollectionType(name, parseElementType(p))
+	if p.acceptValue("INDEX") {
+		if ok := p.acceptValue("BY"); !ok {
+			panicf("Can't find 'BY' after 'INDEX'")
+		}
+		switch indexType := parseTypeName(p); indexType {
+		case "PLS_INTEGER", "BINARY_INTEGER", "SIMPLE_INTEGER":
+			ct.IsAssociative = true
+		default:
+			panicf("Associative arrays indexed by '%s' are not implemented yet, only PLS_INTEGER is", indexType)
+		}
+	}
+	if ok := p.acceptValue(";"); !ok {
+		panicf("Can't find ';' lex item")
+	}
+	return ct
+}
+
+// parseVarrayType parses '(n) OF type [NOT NULL];' after 'name IS VARRAY'
+func parseVarrayType(p *parser, name string) *ast.CollectionType {
+	if ok := p.acceptValue("("); !ok {
+		panicf("Can't find '(' lex item")
+	}
+	ok, size := p.acceptType(lexer.NumericType)
+	if !ok {
+		panicf("Can't find the size of varray '%s' but got '%s'", name, p.peek().Value)
+	}
+	limit, err := strconv.ParseInt(size, 10, 64)
+	if err != nil || limit < 1 {
+		panicf("PLS-00325: non-integral numeric literal '%s' is inappropriate in this context", size)
+	}
+	if ok := p.acceptValue(")"); !ok {
+		panicf("Can't find ')' lex item")
+	}
+
+	ct := ast.NewCollectionType(name, parseElementType(p))
+	ct.IsVarray = true
+	ct.Limit = limit
+	if ok := p.acceptValue(";"); !ok {
+		panicf("Can't find ';' lex item")
+	}
+	return ct
+}
+
+// parseElementType parses 'OF type [NOT NULL]' of a collection type
+// there are no NULL values, NOT NULL doesn't change anything
+func parseElementType(p *parser) string {
+	if ok := p.acceptValue("OF"); !ok {
+		panicf("Can't find 'OF' lex item")
+	}
+	typ := parseTypeName(p)
+	if p.acceptValue("NOT") {
+		if ok := p.acceptValue("NULL"); !ok {
+			panicf("Can't find 'NULL' after 'NOT'")
+		}
+	}
+	return typ
+}
+
+// parseRefCursor parses 'CURSOR [RETURN type];' after 'name IS REF'
+func parseRefCursor(p *parser, name string) *ast.RefCursor {
+	if ok := p.acceptValue("CURSOR"); !ok {
+		panicf("Can't find 'cursor' lex item")
+	}
+
+	var returnType string
+	if p.acceptValue("RETURN") {
+		returnType = parseTypeName(p)
+	}
+	if ok := p.acceptValue(";"); !ok {
+		panicf("Can't find ';' lex item")
+	}
+	return ast.NewRefCursor(name, returnType)
+}
+
+// parseRecordType parses '(field type, ...);' after 'name IS RECORD'
+func parseRecordType(p *parser, name string) *ast.RecordType {
+	if ok := p.acceptValue("("); !ok {
+		panicf("Can't find '(' lex item")
+	}
+
+	rt := ast.NewRecordType(name)
+	hasMore := true
+	for hasMore {
+		fieldName := p.next().Value
+		fieldType := parseTypeName(p)
+		rt.AddField(fieldName, fieldType)
+		sep := p.next().Value
+		hasMore = sep == ","
+	}
+
+	if ok := p.acceptValue(";"); !ok {
+		panicf("Can't find ';' lex item")
+	}
+	return rt
+}
+
+// parseSubtype parses 'name IS type;'
+func parseSubtype(p *parser) *ast.Subtype {
+	ok, name := p.acceptType(lexer.IdentifierType)
+	if !ok {
+		panicf("Can't find type name but got '%s'", p.peek().Value)
+	}
+	if ok := p.acceptValue("IS"); !ok {
+		panicf("Can't find 'is' lex item")
+	}
+	baseType := parseTypeName(p)
+
+	if ok := p.acceptValue(";"); !ok {
+		panicf("Can't find ';' lex item")
+	}
+	return ast.NewSubtype(name, baseType)
+}
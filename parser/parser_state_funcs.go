@@ -29,115 +29,197 @@ func parseText(p *parser, pc *parserContext) (stateFunc, *parserContext) {
 	switch i := p.next(); i.Value {
 	case "CREATE":
 		if ok := p.acceptValue("OR"); !ok {
-			log.Panicf("Can't find 'or' lex item instead got %s", p.peek().Value)
+			panicf("Can't find 'or' lex item instead got %s", p.peek().Value)
 		}
 		if ok := p.acceptValue("REPLACE"); !ok {
-			log.Panicf("Can't find 'replace' lex item instead got %s", p.peek().Value)
+			panicf("Can't find 'replace' lex item instead got %s", p.peek().Value)
 		}
 		switch i2 := p.next(); i2.Value {
 		case "PACKAGE":
 			return parseCreatePackage, pc
 		default:
-			log.Panicf("Can't match item %s", i.String())
+			panicf("Can't match item %s", i.String())
 		}
 
 	default:
-		log.Panicf("Can't match item %s", i.String())
+		if i.Typ == lexer.EofType {
+			return nil, nil
+		}
+		panicf("Can't match item %s", i.String())
 	}
 	return nil, nil
 }
 
 func parseCreatePackage(p *parser, pc *parserContext) (stateFunc, *parserContext) {
-	switch i := p.next(); i.Value {
-	case "BODY":
+	switch i := p.next(); {
+	case i.Value == "BODY":
 		packageNameItem := p.next()
-		if ok := p.acceptValue("AS"); !ok {
-			log.Panicf("Can't find 'as' lex item")
+		if ok := acceptAsOrIs(p); !ok {
+			panicf("Can't find 'as' lex item")
 		}
-		pkg := ast.NewPackage(packageNameItem.Value)
-		p.packages[packageNameItem.Value] = pkg
+		// the spec might have been parsed already
+		pkg := p.addPackage(ast.NewPackage(packageNameItem.Value))
 		log.Printf("Found package: %s\n", pkg.Name)
 		pc.pkg = pkg
 		return parseInsidePackage, pc
 
+	case i.Typ == lexer.IdentifierType:
+		if ok := acceptAsOrIs(p); !ok {
+			panicf("Can't find 'as' lex item")
+		}
+		spec := ast.NewPackageSpec(i.Value)
+		// the body might have been parsed already
+		p.addPackage(ast.NewPackageFromSpec(spec))
+		log.Printf("Found package spec: %s\n", spec.Name)
+		pc.spec = spec
+		return parseInsidePackageSpec, pc
+
 	default:
-		log.Panicf("Can't match lex item '%s'", i.String())
+		panicf("Can't match lex item '%s'", i.String())
 	}
 
 	return nil, nil
 }
 
+func parseInsidePackageSpec(p *parser, pc *parserContext) (stateFunc, *parserContext) {
+	spec := pc.spec
+	switch i := p.next(); i.Value {
+	case "PROCEDURE", "FUNCTION":
+		fp := ast.NewFunctionProto(p.next().Value)
+		parseFunctionSignature(p, fp)
+		if i.Value == "FUNCTION" && fp.IsProcedure() {
+			panicf("Function '%s' doesn't have a return type", fp.Name)
+		}
+		if ok := p.acceptValue(";"); !ok {
+			panicf("Can't find ';' lex item")
+		}
+		spec.AddProto(fp)
+		return parseInsidePackageSpec, pc
+
+	case "TYPE":
+		spec.AddType(parseTypeDeclaration(p))
+		return parseInsidePackageSpec, pc
+
+	case "SUBTYPE":
+		spec.AddType(parseSubtype(p))
+		return parseInsidePackageSpec, pc
+
+	case "END":
+		endPackage(p, spec.Name)
+		pc.spec = nil
+		return parseText, pc
+
+	default:
+		if i.Typ != lexer.IdentifierType {
+			panicf("Can't match lex item '%s'", i.Value)
+		}
+		isConstant := p.acceptValue("CONSTANT")
+		typ, value := parseDeclaration(p)
+		spec.AddVariable(ast.NewPackageVariable(i.Value, typ, value, isConstant))
+		return parseInsidePackageSpec, pc
+	}
+}
+
 func parseInsidePackage(p *parser, pc *parserContext) (stateFunc, *parserContext) {
 	pkg := pc.pkg
 	switch i := p.next(); i.Value {
 	case "PROCEDURE":
 		fName := p.next().Value
 		f := ast.NewFunction(fName, true)
+		f.Line, f.Column = i.Line, i.Column
 		pkg.AddFunction(f)
 		pc.function = f
 		return parseFunction, pc
 
-	case "END":
-		if ok := p.acceptValue(pkg.Name); !ok {
-			log.Panicf("Can't find '%s' lex item", pkg.Name)
-		}
-		if ok := p.acceptValue(";"); !ok {
-			log.Panicf("Can't find ';' lex item")
-		}
+	case "FUNCTION":
+		fName := p.next().Value
+		f := ast.NewFunction(fName, false)
+		f.Line, f.Column = i.Line, i.Column
+		pkg.AddFunction(f)
+		pc.function = f
+		return parseFunction, pc
+
+	case "TYPE":
+		pkg.AddType(parseTypeDeclaration(p))
+		return parseInsidePackage, pc
+
+	case "SUBTYPE":
+		pkg.AddType(parseSubtype(p))
+		return parseInsidePackage, pc
+
+	case "BEGIN":
+		// the initialization section runs once before the package is used
+		// it ends with the 'END name;' of the package
+		f := pkg.NewInitFunction()
+		f.Line, f.Column = i.Line, i.Column
+		blk := ast.NewBlock(pkg.Name + "-init")
+		f.AddBlock(blk)
+		pc.function = f
+		pc.block = blk
+		parseInsideBlock(p, pc)
 		if ok := p.acceptValue("/"); !ok {
-			log.Panicf("Can't find '/' lex item")
+			panicf("Can't find '/' lex item")
 		}
-		return nil, nil
+		pc.function = nil
+		pc.pkg = nil
+		return parseText, pc
+
+	case "END":
+		endPackage(p, pkg.Name)
+		pc.pkg = nil
+		return parseText, pc
 
 	default:
-		log.Panicf("Can't match lex item '%s'", i.Value)
+		if i.Typ != lexer.IdentifierType {
+			panicf("Can't match lex item '%s'", i.Value)
+		}
+		isConstant := p.acceptValue("CONSTANT")
+		typ, value := parseDeclaration(p)
+		pkg.AddVariable(ast.NewPackageVariable(i.Value, typ, value, isConstant))
+		return parseInsidePackage, pc
 	}
-	return nil, nil
 }
 
 func parseFunction(p *parser, pc *parserContext) (stateFunc, *parserContext) {
 	f := pc.function
-	switch i := p.next(); i.Typ {
-	case lexer.SeparatorType:
-
-		hasMore := true
-		for hasMore {
-			name := p.next().Value
-			ownership := p.next().Value
-			typ := p.next().Value
-			f.AddParam(name, ownership, typ)
-			sep := p.next().Value
-			hasMore = sep == ","
-		}
+	parseFunctionSignature(p, f.Proto)
+	if !f.IsProcedure() && f.Proto.IsProcedure() {
+		panicf("Function '%s' doesn't have a return type", f.Proto.Name)
+	}
 
-		if ok := p.acceptValue("IS"); !ok {
-			log.Panicf("Can't find 'is' lex item")
-		}
-		return parseFunctionBody, pc
+	if ok := acceptAsOrIs(p); !ok {
+		panicf("Can't find 'is' lex item but is %s", p.next().Value)
+	}
 
-	case lexer.KeywordType:
-		if i.Value != "IS" {
-			log.Panicf("Can't find 'is' lex item but is %s", p.next().Value)
+	// parse function locals, cursors and pragmas
+	for p.peek().Typ == lexer.IdentifierType || p.peek().Value == "CURSOR" || p.peek().Value == "PRAGMA" {
+		if p.acceptValue("CURSOR") {
+			f.AddCursor(parseCursor(p))
+			continue
+		} else if p.acceptValue("PRAGMA") {
+			parsePragma(p, f)
+			continue
 		}
 
-		// parse function locals
-		for p.peek().Typ == lexer.IdentifierType {
-			localName := p.next().Value
-			localType := p.next().Value
-			p.next()
-			localValue := p.next().Value
-			p.next()
-			f.AddLocal(localName, localType, localValue)
-		}
+		local := p.next()
+		localType, localValue := parseDeclaration(p)
+		fl := f.AddLocal(local.Value, localType, localValue)
+		fl.Line, fl.Column = local.Line, local.Column
+	}
 
-		return parseFunctionBody, pc
+	return parseFunctionBody, pc
+}
 
-	default:
-		log.Panicf("Can't match token %s", i.Value)
+// parsePragma parses 'AUTONOMOUS_TRANSACTION;' after 'PRAGMA'
+func parsePragma(p *parser, f *ast.Function) {
+	ok, name := p.acceptType(lexer.IdentifierType)
+	if !ok || name != "AUTONOMOUS_TRANSACTION" {
+		panicf("Only PRAGMA AUTONOMOUS_TRANSACTION is implemented but got '%s'", name)
 	}
-
-	pc.function = nil
-	return parseInsidePackage, pc
+	if ok := p.acceptValue(";"); !ok {
+		panicf("Can't find ';' lex item")
+	}
+	f.Autonomous = true
 }
 
 func parseFunctionBody(p *parser, pc *parserContext) (stateFunc, *parserContext) {
@@ -150,7 +232,7 @@ func parseFunctionBody(p *parser, pc *parserContext) (stateFunc, *parserContext)
 		parseInsideBlock(p, pc)
 
 	default:
-		log.Panicf("Can't match lex item '%s'", i.Value)
+		panicf("Can't match lex item '%s'", i.Value)
 	}
 	log.Printf("parsed function body for %s\n", f.Proto.Name)
 	pc.function = nil
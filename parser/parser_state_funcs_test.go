@@ -90,6 +90,67 @@ const (
 	END LOOP;
 	END;
 	`
+
+	parseSqlStatements = `
+	SELECT name, COUNT(*) INTO v_name, rec.cnt FROM emp WHERE id IN (SELECT id FROM dept) GROUP BY name;
+	UPDATE emp SET name = 'it''s' WHERE id = v_id;
+	IF SQL%NOTFOUND THEN
+		dbms.print(SQL%ROWCOUNT);
+	END IF;
+	END;
+	`
+
+	parseCursorStatements = `
+	OPEN c_emps(v_min, 'KING');
+	FETCH c_emps INTO v_name, rec.salary;
+	CLOSE c_emps;
+	END;
+	`
+
+	parseCollections = `
+	names.EXTEND;
+	names(1) := 'KING';
+	emps(1).name := names(names.LAST);
+	names.DELETE(1);
+	dbms.print(emps(1).name);
+	FETCH c_emps BULK COLLECT INTO emps LIMIT v_max;
+	END;
+	`
+
+	parseDynamicSql = `
+	EXECUTE IMMEDIATE 'SELECT x FROM ' || v_table || ' WHERE id = :1' INTO v_x, rec.y USING IN v_id;
+	EXECUTE IMMEDIATE v_sql;
+	OPEN rc FOR v_sql USING 1, 'a';
+	OPEN rc FOR SELECT id, name FROM emp WHERE salary > v_min;
+	END;
+	`
+
+	parseForAllStatements = `
+	FORALL i IN 1..v_count + 1
+		UPDATE emp SET salary = salary + i WHERE id = i;
+	dbms.print(SQL%BULK_ROWCOUNT(2) + SQL%ROWCOUNT);
+	END;
+	`
+
+	parseTransactions = `
+	SAVEPOINT sp;
+	ROLLBACK TO SAVEPOINT sp;
+	ROLLBACK WORK;
+	COMMIT;
+	END;
+	`
+
+	parseCursorForLoops = `
+	FOR rec IN (SELECT name FROM emp) LOOP
+		IF rec.name = 'KING' THEN
+			dbms.print(rec.name);
+		END IF;
+	END LOOP;
+	FOR r IN c_emps(1) LOOP
+		dbms.print(r.name);
+	END LOOP;
+	END;
+	`
 )
 
 func TestParseFunction1(t *testing.T) {
@@ -241,6 +302,279 @@ func TestParseLoopBranches2(t *testing.T) {
 	assert.True(t, ok)
 }
 
+func TestParseSqlStatements(t *testing.T) {
+	_, items := lexer.NewLexer("", parseSqlStatements)
+	p := newParser(items)
+
+	pkg := ast.NewPackage("pkg_name")
+	f := ast.NewFunction("f_name", true)
+	blk := ast.NewBlock("entry-block")
+	f.AddBlock(blk)
+	pc := &parserContext{
+		pkg:      pkg,
+		function: f,
+		block:    blk,
+	}
+
+	parseInsideBlock(p, pc)
+	query, ok := f.Blocks[0].Instructions[0].(*ast.SqlStatement)
+	assert.True(t, ok)
+	assert.Equal(t, "SELECT", query.Kind)
+	assert.Equal(t, 2, len(query.Into))
+	assert.Equal(t, "REC", query.Into[1].Qualifier)
+	assert.Equal(t, "<sql> SELECT NAME , COUNT ( * ) FROM EMP WHERE ID IN ( SELECT ID FROM DEPT ) GROUP BY NAME", query.String())
+
+	update, ok := f.Blocks[0].Instructions[1].(*ast.SqlStatement)
+	assert.True(t, ok)
+	assert.Equal(t, "<sql> UPDATE EMP SET NAME = 'it''s' WHERE ID = V_ID", update.String())
+
+	cond, ok := f.Blocks[0].Terminator.(*ast.ConditionalBranch)
+	assert.True(t, ok)
+	_, ok = cond.Condition.(*ast.CursorAttribute)
+	assert.True(t, ok)
+}
+
+func TestParseCursorStatements(t *testing.T) {
+	_, items := lexer.NewLexer("", parseCursorStatements)
+	p := newParser(items)
+
+	pkg := ast.NewPackage("pkg_name")
+	f := ast.NewFunction("f_name", true)
+	blk := ast.NewBlock("entry-block")
+	f.AddBlock(blk)
+	pc := &parserContext{
+		pkg:      pkg,
+		function: f,
+		block:    blk,
+	}
+
+	parseInsideBlock(p, pc)
+	assert.Equal(t, 3, len(f.Blocks[0].Instructions))
+	open, ok := f.Blocks[0].Instructions[0].(*ast.OpenCursor)
+	assert.True(t, ok)
+	assert.Equal(t, "C_EMPS", open.Cursor)
+	assert.Equal(t, 2, len(open.Args))
+
+	fetch, ok := f.Blocks[0].Instructions[1].(*ast.FetchCursor)
+	assert.True(t, ok)
+	assert.Equal(t, "C_EMPS", fetch.Cursor)
+	assert.Equal(t, 2, len(fetch.Into))
+	assert.Equal(t, "REC", fetch.Into[1].Qualifier)
+
+	close, ok := f.Blocks[0].Instructions[2].(*ast.CloseCursor)
+	assert.True(t, ok)
+	assert.Equal(t, "C_EMPS", close.Cursor)
+}
+
+func TestParseCollections(t *testing.T) {
+	_, items := lexer.NewLexer("", parseCollections)
+	p := newParser(items)
+
+	pkg := ast.NewPackage("pkg_name")
+	f := ast.NewFunction("f_name", true)
+	blk := ast.NewBlock("entry-block")
+	f.AddBlock(blk)
+	pc := &parserContext{
+		pkg:      pkg,
+		function: f,
+		block:    blk,
+	}
+
+	parseInsideBlock(p, pc)
+	insts := f.Blocks[0].Instructions
+	assert.Equal(t, 6, len(insts))
+	extend, ok := insts[0].(*ast.FunctionCall)
+	assert.True(t, ok)
+	assert.Equal(t, "EXTEND", extend.FunctionName)
+
+	a, ok := insts[1].(*ast.Assignment)
+	assert.True(t, ok)
+	assert.Nil(t, a.Target)
+	assert.Equal(t, "NAMES", a.Element.Collection.Name)
+	a = insts[2].(*ast.Assignment)
+	assert.Equal(t, "NAME", a.Element.Field)
+	_, ok = a.Expr.(*ast.FunctionCall)
+	assert.True(t, ok)
+
+	del := insts[3].(*ast.FunctionCall)
+	assert.Equal(t, "DELETE", del.FunctionName)
+	elem, ok := insts[4].(*ast.FunctionCall).Args[0].(*ast.CollectionElement)
+	assert.True(t, ok)
+	assert.Equal(t, "EMPS", elem.Collection.Name)
+	assert.Equal(t, "NAME", elem.Field)
+
+	fetch, ok := insts[5].(*ast.FetchCursor)
+	assert.True(t, ok)
+	assert.True(t, fetch.Bulk)
+	assert.NotNil(t, fetch.Limit)
+}
+
+func TestParseCursorForLoops(t *testing.T) {
+	_, items := lexer.NewLexer("", parseCursorForLoops)
+	p := newParser(items)
+
+	pkg := ast.NewPackage("pkg_name")
+	f := ast.NewFunction("f_name", true)
+	blk := ast.NewBlock("entry-block")
+	f.AddBlock(blk)
+	pc := &parserContext{
+		pkg:      pkg,
+		function: f,
+		block:    blk,
+	}
+
+	parseInsideBlock(p, pc)
+	// entry, fetch, loop, if, merge of the if, merge of the first loop, fetch, loop, merge of the second loop
+	assert.Equal(t, 9, len(f.Blocks))
+	loop, ok := f.Blocks[0].Instructions[0].(*ast.CursorForLoop)
+	assert.True(t, ok)
+	assert.Equal(t, "REC", loop.Record)
+	assert.NotNil(t, loop.Query)
+	assert.Equal(t, loop.Fetch, f.Blocks[1].Instructions[0])
+
+	cond, ok := f.Blocks[1].Terminator.(*ast.ConditionalBranch)
+	assert.True(t, ok)
+	assert.Equal(t, f.Blocks[2], cond.TrueTarget)
+	assert.Equal(t, f.Blocks[5], cond.FalseTarget)
+	// the body ends in the merge block of the if, that one goes back to fetching
+	assert.Equal(t, f.Blocks[4], f.Blocks[3].Terminator.(*ast.Branch).Blk)
+	assert.Equal(t, f.Blocks[1], f.Blocks[4].Terminator.(*ast.Branch).Blk)
+
+	_, ok = f.Blocks[5].Instructions[0].(*ast.EndCursorForLoop)
+	assert.True(t, ok)
+	loop, ok = f.Blocks[5].Instructions[1].(*ast.CursorForLoop)
+	assert.True(t, ok)
+	assert.Nil(t, loop.Query)
+	assert.Equal(t, "C_EMPS", loop.Open.Cursor)
+	assert.Equal(t, 1, len(loop.Open.Args))
+	assert.Equal(t, f.Blocks[6], f.Blocks[7].Terminator.(*ast.Branch).Blk)
+}
+
+func TestParseDynamicSql(t *testing.T) {
+	_, items := lexer.NewLexer("", parseDynamicSql)
+	p := newParser(items)
+
+	pkg := ast.NewPackage("pkg_name")
+	f := ast.NewFunction("f_name", true)
+	blk := ast.NewBlock("entry-block")
+	f.AddBlock(blk)
+	pc := &parserContext{
+		pkg:      pkg,
+		function: f,
+		block:    blk,
+	}
+
+	parseInsideBlock(p, pc)
+	assert.Equal(t, 4, len(f.Blocks[0].Instructions))
+	ei, ok := f.Blocks[0].Instructions[0].(*ast.ExecuteImmediate)
+	assert.True(t, ok)
+	_, ok = ei.Statement.(*ast.BinOp)
+	assert.True(t, ok)
+	assert.Equal(t, 2, len(ei.Into))
+	assert.Equal(t, "REC", ei.Into[1].Qualifier)
+	assert.Equal(t, 1, len(ei.Using))
+
+	ei, ok = f.Blocks[0].Instructions[1].(*ast.ExecuteImmediate)
+	assert.True(t, ok)
+	assert.Equal(t, 0, len(ei.Into))
+	assert.Equal(t, 0, len(ei.Using))
+
+	open, ok := f.Blocks[0].Instructions[2].(*ast.OpenCursorFor)
+	assert.True(t, ok)
+	assert.Equal(t, "RC", open.Cursor)
+	assert.Equal(t, 2, len(open.Using))
+
+	open, ok = f.Blocks[0].Instructions[3].(*ast.OpenCursorFor)
+	assert.True(t, ok)
+	assert.Nil(t, open.Statement)
+	assert.Equal(t, "<sql> SELECT ID , NAME FROM EMP WHERE SALARY > V_MIN", open.Query.String())
+}
+
+func TestParseForAll(t *testing.T) {
+	_, items := lexer.NewLexer("", parseForAllStatements)
+	p := newParser(items)
+
+	pkg := ast.NewPackage("pkg_name")
+	f := ast.NewFunction("f_name", true)
+	blk := ast.NewBlock("entry-block")
+	f.AddBlock(blk)
+	pc := &parserContext{
+		pkg:      pkg,
+		function: f,
+		block:    blk,
+	}
+
+	parseInsideBlock(p, pc)
+	assert.Equal(t, 2, len(f.Blocks[0].Instructions))
+	fa, ok := f.Blocks[0].Instructions[0].(*ast.ForAll)
+	assert.True(t, ok)
+	assert.Equal(t, "I", fa.Index)
+	_, ok = fa.Upper.(*ast.BinOp)
+	assert.True(t, ok)
+	assert.Equal(t, "UPDATE", fa.Statement.Kind)
+
+	call, ok := f.Blocks[0].Instructions[1].(*ast.FunctionCall)
+	assert.True(t, ok)
+	sum, ok := call.Args[0].(*ast.BinOp)
+	assert.True(t, ok)
+	ca, ok := sum.Left.(*ast.CursorAttribute)
+	assert.True(t, ok)
+	assert.Equal(t, "BULK_ROWCOUNT", ca.Attribute)
+	assert.NotNil(t, ca.Index)
+
+	_, items = lexer.NewLexer("", "SELECT name BULK COLLECT INTO v_names FROM emp;")
+	p = newParser(items)
+	query := parseSqlStatement(p, p.next())
+	assert.True(t, query.Bulk)
+	assert.Equal(t, "V_NAMES", query.Into[0].Name)
+	assert.Equal(t, "<sql> SELECT NAME FROM EMP", query.String())
+
+	_, items = lexer.NewLexer("", "FORALL i IN 1..v_ids.COUNT SAVE EXCEPTIONS DELETE FROM emp WHERE id = v_ids(i);")
+	p = newParser(items)
+	p.next()
+	fa = parseForAll(p)
+	assert.True(t, fa.SaveExceptions)
+	assert.Equal(t, "DELETE", fa.Statement.Kind)
+}
+
+func TestParseTransactions(t *testing.T) {
+	_, items := lexer.NewLexer("", parseTransactions)
+	p := newParser(items)
+
+	pkg := ast.NewPackage("pkg_name")
+	f := ast.NewFunction("f_name", true)
+	blk := ast.NewBlock("entry-block")
+	f.AddBlock(blk)
+	pc := &parserContext{
+		pkg:      pkg,
+		function: f,
+		block:    blk,
+	}
+
+	parseInsideBlock(p, pc)
+	assert.Equal(t, 4, len(f.Blocks[0].Instructions))
+	sp, ok := f.Blocks[0].Instructions[0].(*ast.Savepoint)
+	assert.True(t, ok)
+	assert.Equal(t, "SP", sp.Name)
+	rollback, ok := f.Blocks[0].Instructions[1].(*ast.Rollback)
+	assert.True(t, ok)
+	assert.Equal(t, "SP", rollback.Savepoint)
+	rollback, ok = f.Blocks[0].Instructions[2].(*ast.Rollback)
+	assert.True(t, ok)
+	assert.Equal(t, "", rollback.Savepoint)
+	_, ok = f.Blocks[0].Instructions[3].(*ast.Commit)
+	assert.True(t, ok)
+
+	_, items = lexer.NewLexer("", "AUTONOMOUS_TRANSACTION;")
+	p = newParser(items)
+	parsePragma(p, f)
+	assert.True(t, f.Autonomous)
+
+	_, items = lexer.NewLexer("", "SERIALLY_REUSABLE;")
+	p = newParser(items)
+	assert.Panics(t, func() { parsePragma(p, f) })
+}
+
 func getFunctionNameTest(i interface{}) string {
 	name := runtime.FuncForPC(reflect.ValueOf(i).Pointer()).Name()
 	slices := strings.Split(name, ".")
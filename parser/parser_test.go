@@ -20,6 +20,7 @@ import (
 	"log"
 	"testing"
 
+	"github.com/mhelmich/plsqlc/ast"
 	"github.com/mhelmich/plsqlc/lexer"
 	"github.com/stretchr/testify/assert"
 )
@@ -53,6 +54,52 @@ const (
   END main;
   /
 `
+
+	specAndBodyExample = `
+  CREATE OR REPLACE PACKAGE pkg AS
+    TYPE point IS RECORD (x INT, y INT);
+    SUBTYPE name_t IS VARCHAR;
+    max_count CONSTANT INT := 10;
+    counter INT;
+
+    PROCEDURE run(i IN INT, o OUT pkg.point);
+    FUNCTION name(p IN OUT name_t) RETURN VARCHAR;
+  END pkg;
+  /
+
+  CREATE OR REPLACE PACKAGE BODY pkg AS
+    PROCEDURE run(i IN INT, o OUT pkg.point) IS
+    BEGIN
+      dbms.print(i);
+    END;
+
+    FUNCTION name(p IN OUT name_t) RETURN VARCHAR IS
+    BEGIN
+      RETURN p;
+    END;
+  END pkg;
+  /
+`
+
+	packageStateExample = `
+  CREATE OR REPLACE PACKAGE BODY cache AS
+    TYPE entry_t IS RECORD (key VARCHAR, hits INT);
+    max_size CONSTANT INT := 100;
+    size INT;
+    last_entry entry_t;
+
+    PROCEDURE put(k IN VARCHAR) IS
+    BEGIN
+      last_entry.key := k;
+      size := size + 1;
+    END put;
+
+  BEGIN
+    size := 0;
+    last_entry.hits := cache.max_size;
+  END cache;
+  /
+`
 )
 
 func TestParserPeek(t *testing.T) {
@@ -91,3 +138,174 @@ func TestControlFlow(t *testing.T) {
 	}
 	assert.Equal(t, 1, len(p.packages))
 }
+
+func TestSpecAndBody(t *testing.T) {
+	_, items := lexer.NewLexer("", specAndBodyExample)
+	p := newParser(items)
+	p.run()
+	assert.Equal(t, 1, len(p.packages))
+	pkg := p.packages["PKG"]
+	assert.NotNil(t, pkg.Spec)
+	assert.Equal(t, 2, len(pkg.Spec.Types))
+	assert.Equal(t, 2, len(pkg.Spec.Variables))
+	assert.True(t, pkg.Spec.Variables[0].IsConstant)
+	assert.Equal(t, "10", pkg.Spec.Variables[0].Value)
+	assert.Equal(t, 2, len(pkg.Spec.Protos))
+	assert.Equal(t, "O OUT PKG.POINT", pkg.Spec.Protos[0].Params[1].String())
+	assert.Equal(t, "IN OUT", pkg.Spec.Protos[1].Params[0].Ownership)
+	assert.Equal(t, "VARCHAR", pkg.Spec.Protos[1].ReturnType)
+	assert.False(t, pkg.HasMainFunction())
+}
+
+func TestPackageState(t *testing.T) {
+	_, items := lexer.NewLexer("", packageStateExample)
+	p := newParser(items)
+	p.run()
+	pkg := p.packages["CACHE"]
+	assert.NotNil(t, pkg)
+	assert.Nil(t, pkg.Spec)
+	assert.Equal(t, "CACHE._init", pkg.GetInitFunctionName())
+	assert.Contains(t, pkg.String(), "MAX_SIZE CONSTANT INT 100")
+	assert.Contains(t, pkg.String(), "<variable> LAST_ENTRY.HITS := <variable> CACHE.MAX_SIZE")
+}
+
+var refCursorExample = `
+CREATE OR REPLACE PACKAGE report AS
+    TYPE emp_t IS RECORD (id INT, name VARCHAR);
+    TYPE emp_cur IS REF CURSOR RETURN emp_t;
+    TYPE any_cur IS REF CURSOR;
+
+    FUNCTION emps(min_salary IN INT) RETURN emp_cur;
+    PROCEDURE open_all(rc OUT SYS_REFCURSOR);
+END report;
+/
+`
+
+func TestParseRefCursors(t *testing.T) {
+	_, items := lexer.NewLexer("", refCursorExample)
+	p := newParser(items)
+	p.run()
+	spec := p.packages["REPORT"].Spec
+	assert.Equal(t, 3, len(spec.Types))
+	strong, ok := spec.Types[1].(*ast.RefCursor)
+	assert.True(t, ok)
+	assert.Equal(t, "EMP_CUR", strong.Name)
+	assert.Equal(t, "EMP_T", strong.ReturnType)
+	weak, ok := spec.Types[2].(*ast.RefCursor)
+	assert.True(t, ok)
+	assert.Equal(t, "", weak.ReturnType)
+	assert.Equal(t, "EMP_CUR", spec.Protos[0].ReturnType)
+	assert.Equal(t, "RC OUT SYS_REFCURSOR", spec.Protos[1].Params[0].String())
+}
+
+var collectionsExample = `
+CREATE OR REPLACE PACKAGE report AS
+    TYPE name_list IS TABLE OF VARCHAR2(20) NOT NULL;
+    TYPE salary_map IS TABLE OF NUMBER INDEX BY PLS_INTEGER;
+    TYPE id_array IS VARYING ARRAY(3) OF INT;
+
+    PROCEDURE top(names OUT name_list);
+END report;
+/
+`
+
+func TestParseCollectionTypes(t *testing.T) {
+	_, items := lexer.NewLexer("", collectionsExample)
+	p := newParser(items)
+	p.run()
+	spec := p.packages["REPORT"].Spec
+	assert.Equal(t, 3, len(spec.Types))
+	names, ok := spec.Types[0].(*ast.CollectionType)
+	assert.True(t, ok)
+	assert.Equal(t, "VARCHAR2(20)", names.ElementType)
+	assert.False(t, names.IsVarray || names.IsAssociative)
+	salaries := spec.Types[1].(*ast.CollectionType)
+	assert.True(t, salaries.IsAssociative)
+	ids := spec.Types[2].(*ast.CollectionType)
+	assert.True(t, ids.IsVarray)
+	assert.Equal(t, int64(3), ids.Limit)
+	assert.Equal(t, "NAMES OUT NAME_LIST", spec.Protos[0].Params[0].String())
+
+	_, items = lexer.NewLexer("", "names report.name_list := report.name_list();")
+	p = newParser(items)
+	p.next()
+	typ, value := parseDeclaration(p)
+	assert.Equal(t, "REPORT.NAME_LIST", typ)
+	assert.Equal(t, "REPORT.NAME_LIST()", value)
+
+	// only empty collections can be declared
+	_, items = lexer.NewLexer("", "names name_list := name_list('KING');")
+	p = newParser(items)
+	p.next()
+	assert.Panics(t, func() { parseDeclaration(p) })
+
+	_, items = lexer.NewLexer("", "TYPE m IS TABLE OF INT INDEX BY VARCHAR2(10);")
+	p = newParser(items)
+	p.next()
+	assert.Panics(t, func() { parseTypeDeclaration(p) })
+}
+
+var constrainedTypesExample = `
+CREATE OR REPLACE PACKAGE BODY main AS
+    code CHAR(3) := 'AB';
+    total NUMBER(10, 2);
+
+    PROCEDURE main IS
+      name VARCHAR2(10 CHAR) := 'narf';
+    BEGIN
+      dbms.print(name);
+    END;
+END main;
+/
+`
+
+func TestConstrainedTypes(t *testing.T) {
+	_, items := lexer.NewLexer("", constrainedTypesExample)
+	p := newParser(items)
+	p.run()
+	pkg := p.packages["MAIN"]
+	assert.Contains(t, pkg.String(), "CODE CHAR(3) 'AB'")
+	assert.Contains(t, pkg.String(), "TOTAL NUMBER(10,2)")
+	assert.Contains(t, pkg.String(), "NAME VARCHAR2(10 CHAR) 'narf'")
+}
+
+var replaceExample = `
+CREATE OR REPLACE PACKAGE BODY main AS
+    PROCEDURE main IS
+      s VARCHAR := 'banana';
+    BEGIN
+      dbms.print(replace(s, 'an', 'AN'));
+    END;
+END main;
+/
+`
+
+func TestReplaceFunction(t *testing.T) {
+	_, items := lexer.NewLexer("", replaceExample)
+	p := newParser(items)
+	p.run()
+	pkg := p.packages["MAIN"]
+	assert.Contains(t, pkg.String(), "<func call> REPLACE(<variable> S,<string literal> an,<string literal> AN,)")
+}
+
+func TestParseErrors(t *testing.T) {
+	// syntax errors come back from the parser goroutine with the line they were found on
+	_, items := lexer.NewLexer("", "CREATE OR REPLACE PACKAGE BODY main AS\n  PROCEDURE main IS\n  BEGIN\n    IF 1 > 0\n      dbms.print(1);\n    END IF;\n  END;\nEND main;\n/\n")
+	pkgs, err := NewParser(items).GetPackageAsts()
+	assert.Nil(t, pkgs)
+	assert.EqualError(t, err, "5: Can't find 'THEN' lex item")
+
+	// so do the errors of the lexer
+	_, items = lexer.NewLexer("", "CREATE OR REPLACE PACKAGE BODY main AS\n  PROCEDURE main IS\n  BEGIN\n    dbms.print(1 # 2);\n")
+	_, err = NewParser(items).GetPackageAsts()
+	assert.EqualError(t, err, "4: Found # but can't match a rule")
+
+	// initializers that aren't literals are reported instead of being taken for collection constructors
+	_, items = lexer.NewLexer("", "CREATE OR REPLACE PACKAGE BODY main AS\n  PROCEDURE main IS\n    d DATE := SYSDATE;\n  BEGIN\n    dbms.print(d);\n")
+	_, err = NewParser(items).GetPackageAsts()
+	assert.EqualError(t, err, "3: Declarations can only be initialized with literals and empty collections ('list_t()'), not with 'SYSDATE'")
+
+	_, items = lexer.NewLexer("", "CREATE OR REPLACE PACKAGE BODY main AS\n  k INT := 1;\n  PROCEDURE main IS\n    x INT := k;\n  BEGIN\n    dbms.print(x);\n")
+	_, err = NewParser(items).GetPackageAsts()
+	assert.EqualError(t, err, "4: Declarations can only be initialized with literals and empty collections ('list_t()'), not with 'K'")
+}
@@ -0,0 +1,165 @@
+/*
+ * Copyright 2019 Marco Helmich
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package parser
+
+import (
+	"github.com/mhelmich/plsqlc/ast"
+	"github.com/mhelmich/plsqlc/lexer"
+)
+
+// ParseSchema reads the tables and views of a database from its DDL
+// statements other than CREATE TABLE and CREATE VIEW are skipped ('INSERT ...', 'CREATE INDEX ...')
+func ParseSchema(input <-chan *lexer.Item) (schema *ast.Schema, err error) {
+	p := newParser(input)
+	defer func() {
+		if r := recover(); r != nil {
+			perr := p.parseError(r)
+			if perr == nil {
+				panic(r)
+			}
+			for range p.input {
+			}
+			schema, err = nil, perr
+		}
+	}()
+
+	schema = ast.NewSchema()
+	for p.peek().Typ != lexer.EofType {
+		if !p.acceptValue("CREATE") {
+			skipStatement(p)
+			continue
+		}
+
+		if p.acceptValue("OR") && !p.acceptValue("REPLACE") {
+			panicf("Can't find 'replace' lex item")
+		}
+		// 'GLOBAL TEMPORARY TABLE', 'FORCE VIEW', ...
+		for p.peek().Value != "TABLE" && p.peek().Value != "VIEW" && p.peek().Value != ";" && p.peek().Typ != lexer.EofType {
+			p.next()
+		}
+
+		var t *ast.Table
+		if p.acceptValue("TABLE") {
+			t = parseCreateTable(p)
+		} else if p.acceptValue("VIEW") {
+			t = ast.NewView(parseSchemaObjectName(p))
+		}
+		if t != nil {
+			if err := schema.AddTable(t); err != nil {
+				panicf("%s", err.Error())
+			}
+		}
+		skipStatement(p)
+	}
+	return schema, nil
+}
+
+// parseSchemaObjectName parses the name of a table that might be qualified by its schema ('hr.emp')
+// the schema is dropped, all tables live in the one database a program connects to
+func parseSchemaObjectName(p *parser) string {
+	if p.acceptValue("IF") {
+		if !p.acceptValue("NOT") || !p.acceptValue("EXISTS") {
+			panicf("Can't find 'if not exists' lex items")
+		}
+	}
+
+	ok, name := p.acceptType(lexer.IdentifierType)
+	if !ok {
+		panicf("Can't find name of table but got '%s'", p.peek().Value)
+	}
+	for p.acceptValue(".") {
+		if ok, name = p.acceptType(lexer.IdentifierType); !ok {
+			panicf("Can't find name of table but got '%s'", p.peek().Value)
+		}
+	}
+	return name
+}
+
+// parseCreateTable parses 'name (column type [constraints], ..., [table constraints])'
+func parseCreateTable(p *parser) *ast.Table {
+	t := ast.NewTable(parseSchemaObjectName(p))
+	if p.acceptValue("AS") {
+		// the columns of 'CREATE TABLE name AS SELECT ...' are the columns of the query
+		return ast.NewView(t.Name)
+	}
+	if ok := p.acceptValue("("); !ok {
+		panicf("Can't find '(' lex item")
+	}
+
+	hasMore := true
+	for hasMore {
+		switch p.peek().Value {
+		case "CONSTRAINT", "PRIMARY", "UNIQUE", "FOREIGN", "CHECK":
+			// table constraints don't add columns
+		default:
+			ok, name := p.acceptType(lexer.IdentifierType)
+			if !ok {
+				panicf("Can't find name of column in table '%s' but got '%s'", t.Name, p.peek().Value)
+			}
+
+			var typeName string
+			if p.peek().Typ == lexer.IdentifierType && !isColumnConstraint(p.peek().Value) {
+				typeName = parseTypeName(p)
+			}
+			if err := t.AddColumn(name, typeName); err != nil {
+				panicf("%s", err.Error())
+			}
+		}
+
+		hasMore = skipColumnRest(p)
+	}
+	return t
+}
+
+// isColumnConstraint returns true for the words that start a constraint of a column without a type
+func isColumnConstraint(word string) bool {
+	switch word {
+	case "CONSTRAINT", "PRIMARY", "UNIQUE", "NOT", "NULL", "CHECK", "REFERENCES", "COLLATE", "GENERATED":
+		return true
+	}
+	return false
+}
+
+// skipColumnRest skips the constraints of a column up to the ',' or ')' that ends it
+// it returns true if more columns follow
+func skipColumnRest(p *parser) bool {
+	depth := 0
+	for {
+		i := p.next()
+		switch {
+		case i.Typ == lexer.EofType:
+			panicf("Can't find ')' lex item")
+		case i.Value == "(":
+			depth++
+		case i.Value == ")" && depth == 0:
+			return false
+		case i.Value == ")":
+			depth--
+		case i.Value == "," && depth == 0:
+			return true
+		}
+	}
+}
+
+// skipStatement skips everything up to and including the ';' or '/' that ends a statement
+func skipStatement(p *parser) {
+	for p.peek().Typ != lexer.EofType {
+		if i := p.next(); i.Value == ";" || i.Value == "/" {
+			return
+		}
+	}
+}
@@ -0,0 +1,69 @@
+/*
+ * Copyright 2019 Marco Helmich
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package parser
+
+import (
+	"testing"
+
+	"github.com/mhelmich/plsqlc/lexer"
+	"github.com/stretchr/testify/assert"
+)
+
+const schemaExample = `
+  -- the tables of the application
+  CREATE TABLE IF NOT EXISTS hr.emp (
+    id INTEGER PRIMARY KEY,
+    name VARCHAR2(20 CHAR) NOT NULL,
+    salary NUMBER(8, 2) DEFAULT 0 CHECK (salary >= 0),
+    dept_id NUMBER(4) REFERENCES dept (id),
+    note,
+    CONSTRAINT emp_name_uk UNIQUE (name)
+  );
+  INSERT INTO emp VALUES (1, 'KING', 5000, 10, NULL);
+  CREATE INDEX emp_dept_ix ON emp (dept_id);
+  CREATE OR REPLACE VIEW rich AS SELECT * FROM emp WHERE salary > 1000;
+  /
+`
+
+func TestParseSchema(t *testing.T) {
+	_, items := lexer.NewLexer("schema", schemaExample)
+	schema, err := ParseSchema(items)
+	assert.Nil(t, err)
+
+	emp, ok := schema.Table("EMP")
+	assert.True(t, ok)
+	assert.Equal(t, 5, len(emp.Columns))
+	types := make([]string, len(emp.Columns))
+	for idx, col := range emp.Columns {
+		types[idx] = col.Name + " "
+		if col.Type != nil {
+			types[idx] += col.Type.String()
+		}
+	}
+	assert.Equal(t, []string{"ID INT", "NAME VARCHAR(20)", "SALARY NUMBER", "DEPT_ID INT", "NOTE "}, types)
+
+	// the columns of views aren't known
+	rich, ok := schema.Table("RICH")
+	assert.True(t, ok)
+	assert.Nil(t, rich.Columns)
+	_, ok = schema.Table("EMP_DEPT_IX")
+	assert.False(t, ok)
+
+	_, items = lexer.NewLexer("schema", "CREATE TABLE t (a INT, a TEXT);")
+	_, err = ParseSchema(items)
+	assert.EqualError(t, err, "1: ORA-00957: duplicate column name 'A' in table 'T'")
+}
@@ -0,0 +1,486 @@
+/*
+ * Copyright 2019 Marco Helmich
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package runtime
+
+import (
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// a variable of a collection type points to the elements of the collection, null is an empty collection
+// the elements are kept sorted by their index, the indexes and the values live in arrays of their own
+// values are copied byte by byte, the functions only need to know the size of an element
+// memory is allocated the first time an element is added and it grows as needed
+//
+// nested tables and varrays have an index from 1 to their size, DELETE leaves holes in nested tables
+// associative arrays can have any index, their size isn't used
+const (
+	CollectionTypeName = "_runtime._collection"
+
+	CollectionElementFuncName     = "_runtime.collection.element"
+	CollectionAssignFuncName      = "_runtime.collection.assign"
+	CollectionExtendFuncName      = "_runtime.collection.extend"
+	CollectionTrimFuncName        = "_runtime.collection.trim"
+	CollectionDeleteFuncName      = "_runtime.collection.delete"
+	CollectionDeleteRangeFuncName = "_runtime.collection.deleteRange"
+	CollectionCountFuncName       = "_runtime.collection.count"
+	CollectionFirstFuncName       = "_runtime.collection.first"
+	CollectionLastFuncName        = "_runtime.collection.last"
+	CollectionNextFuncName        = "_runtime.collection.next"
+	CollectionPriorFuncName       = "_runtime.collection.prior"
+	CollectionExistsFuncName      = "_runtime.collection.exists"
+	CollectionCopyFuncName        = "_runtime.collection.copy"
+
+	collectionFindFuncName    = "_runtime._collectionFind"
+	collectionSizeFuncName    = "_runtime._collectionSize"
+	collectionReserveFuncName = "_runtime._collectionReserve"
+
+	// the kinds of collections
+	CollectionNestedTable = 0
+	CollectionVarray      = 1
+	CollectionAssociative = 2
+
+	SubscriptOutsideLimitMessage = "ORA-06532: Subscript outside of limit"
+	SubscriptBeyondCountMessage  = "ORA-06533: Subscript beyond count"
+)
+
+// the fields of a collection
+const (
+	collectionCountField = iota
+	collectionCapacityField
+	collectionSizeField
+	collectionKeysField
+	collectionValuesField
+)
+
+var i64Ptr = types.NewPointer(types.I64)
+
+func generateCollections(mod *ir.Module) {
+	generate_collectionFind(mod)
+	generate_collectionSize(mod)
+	generate_collectionReserve(mod)
+	generateCollectionElement(mod)
+	generateCollectionAssign(mod)
+	generateCollectionExtend(mod)
+	generateCollectionTrim(mod)
+	generateCollectionDelete(mod)
+	generateCollectionAttributes(mod)
+	generateCollectionCopy(mod)
+}
+
+func collectionField(b *ir.Block, coll value.Value, idx int64) value.Value {
+	return b.NewGetElementPtr(coll, llvmZeroI32, i32Constant(idx))
+}
+
+// collectionValue returns a pointer to the value at position pos
+func collectionValue(b *ir.Block, coll value.Value, pos value.Value, elemSize value.Value) value.Value {
+	values := b.NewLoad(collectionField(b, coll, collectionValuesField))
+	return b.NewGetElementPtr(values, b.NewMul(pos, elemSize))
+}
+
+// collectionKey returns a pointer to the index at position pos
+func collectionKey(b *ir.Block, coll value.Value, pos value.Value) value.Value {
+	return b.NewGetElementPtr(b.NewLoad(collectionField(b, coll, collectionKeysField)), pos)
+}
+
+// ifCollection returns the block that continues if coll isn't null
+// the function returns empty right away if it is, functions without a result just return
+func ifCollection(f *ir.Func, b *ir.Block, coll value.Value, empty value.Value) *ir.Block {
+	collBlock := f.NewBlock("collection")
+	nullBlock := f.NewBlock("null")
+	b.NewCondBr(b.NewICmp(enum.IPredEQ, coll, constant.NewNull(coll.Type().(*types.PointerType))), nullBlock, collBlock)
+	nullBlock.NewRet(empty)
+	return collBlock
+}
+
+func newCollectionParam() *ir.Param {
+	return ir.NewParam("coll", types.NewPointer(CollectionType))
+}
+
+func newSlotParam() *ir.Param {
+	return ir.NewParam("slot", types.NewPointer(types.NewPointer(CollectionType)))
+}
+
+// generate_collectionFind returns the position of the first index that isn't less than key
+// that is the number of elements if all indexes are less
+func generate_collectionFind(mod *ir.Module) {
+	coll := newCollectionParam()
+	key := ir.NewParam("key", types.I64)
+	f := mod.NewFunc(collectionFindFuncName, types.I64, coll, key)
+	entry := f.NewBlock("entry")
+	condBlock := f.NewBlock("cond")
+	bodyBlock := f.NewBlock("body")
+	rightBlock := f.NewBlock("right")
+	leftBlock := f.NewBlock("left")
+	doneBlock := f.NewBlock("done")
+
+	lo := entry.NewAlloca(types.I64)
+	hi := entry.NewAlloca(types.I64)
+	entry.NewStore(llvmZeroI64, lo)
+	entry.NewStore(entry.NewLoad(collectionField(entry, coll, collectionCountField)), hi)
+	entry.NewBr(condBlock)
+
+	l := condBlock.NewLoad(lo)
+	h := condBlock.NewLoad(hi)
+	condBlock.NewCondBr(condBlock.NewICmp(enum.IPredSLT, l, h), bodyBlock, doneBlock)
+
+	mid := bodyBlock.NewLShr(bodyBlock.NewAdd(l, h), llvmOneI64)
+	k := bodyBlock.NewLoad(collectionKey(bodyBlock, coll, mid))
+	bodyBlock.NewCondBr(bodyBlock.NewICmp(enum.IPredSLT, k, key), rightBlock, leftBlock)
+
+	rightBlock.NewStore(rightBlock.NewAdd(mid, llvmOneI64), lo)
+	rightBlock.NewBr(condBlock)
+	leftBlock.NewStore(mid, hi)
+	leftBlock.NewBr(condBlock)
+
+	doneBlock.NewRet(doneBlock.NewLoad(lo))
+}
+
+// generate_collectionSize returns the size of a nested table or varray
+func generate_collectionSize(mod *ir.Module) {
+	coll := newCollectionParam()
+	f := mod.NewFunc(collectionSizeFuncName, types.I64, coll)
+	b := ifCollection(f, f.NewBlock("entry"), coll, llvmZeroI64)
+	b.NewRet(b.NewLoad(collectionField(b, coll, collectionSizeField)))
+}
+
+// generate_collectionReserve makes room for n more elements and returns the collection
+// the collection is created if the variable doesn't point to one yet
+// the capacity at least doubles so that adding one element at a time doesn't copy all of them every time
+func generate_collectionReserve(mod *ir.Module) {
+	malloc := getFuncByName("malloc", mod)
+	realloc := getFuncByName("realloc", mod)
+	collPtr := types.NewPointer(CollectionType)
+	outOfMemory := sharedConstantString(mod, "_runtime.msg.out_of_memory", OutOfMemoryMessage)
+
+	slot := newSlotParam()
+	n := ir.NewParam("n", types.I64)
+	elemSize := ir.NewParam("elemSize", types.I64)
+	f := mod.NewFunc(collectionReserveFuncName, collPtr, slot, n, elemSize)
+	entry := f.NewBlock("entry")
+	newBlock := f.NewBlock("new")
+	checkBlock := f.NewBlock("check")
+	growBlock := f.NewBlock("grow")
+	doneBlock := f.NewBlock("done")
+
+	entry.NewCondBr(entry.NewICmp(enum.IPredEQ, entry.NewLoad(slot), constant.NewNull(collPtr)), newBlock, checkBlock)
+
+	size := constant.NewPtrToInt(constant.NewGetElementPtr(constant.NewNull(collPtr), llvmOneI32), types.I64)
+	mem := newBlock.NewCall(malloc, size)
+	b := checkOrRaise(mod, newBlock, newBlock.NewICmp(enum.IPredNE, mem, constant.NewNull(i8Ptr)), outOfMemory)
+	created := b.NewBitCast(mem, collPtr)
+	b.NewStore(constant.NewZeroInitializer(CollectionType), created)
+	b.NewStore(created, slot)
+	b.NewBr(checkBlock)
+
+	coll := checkBlock.NewLoad(slot)
+	count := checkBlock.NewLoad(collectionField(checkBlock, coll, collectionCountField))
+	capacity := checkBlock.NewLoad(collectionField(checkBlock, coll, collectionCapacityField))
+	needed := checkBlock.NewAdd(count, n)
+	checkBlock.NewCondBr(checkBlock.NewICmp(enum.IPredSGT, needed, capacity), growBlock, doneBlock)
+
+	b = growBlock
+	doubled := b.NewMul(capacity, i64Constant(2))
+	newCapacity := b.NewSelect(b.NewICmp(enum.IPredSGT, doubled, needed), doubled, needed)
+	newCapacity = b.NewSelect(b.NewICmp(enum.IPredSGT, newCapacity, i64Constant(8)), newCapacity, i64Constant(8))
+	keysField := collectionField(b, coll, collectionKeysField)
+	valuesField := collectionField(b, coll, collectionValuesField)
+	keys := b.NewCall(realloc, b.NewBitCast(b.NewLoad(keysField), i8Ptr), b.NewMul(newCapacity, i64Constant(8)))
+	values := b.NewCall(realloc, b.NewLoad(valuesField), b.NewMul(newCapacity, elemSize))
+	isNull := b.NewOr(b.NewICmp(enum.IPredEQ, keys, constant.NewNull(i8Ptr)), b.NewICmp(enum.IPredEQ, values, constant.NewNull(i8Ptr)))
+	b = checkOrRaise(mod, b, b.NewXor(isNull, constant.True), outOfMemory)
+	b.NewStore(b.NewBitCast(keys, i64Ptr), keysField)
+	b.NewStore(values, valuesField)
+	b.NewStore(newCapacity, collectionField(b, coll, collectionCapacityField))
+	b.NewBr(doneBlock)
+
+	doneBlock.NewRet(coll)
+}
+
+// generateCollectionElement returns a pointer to the element at key
+// an element that doesn't exist raises NO_DATA_FOUND
+// for nested tables and varrays only if key is one of their indexes, everything else is beyond their count
+func generateCollectionElement(mod *ir.Module) {
+	find := getFuncByName(collectionFindFuncName, mod)
+	noDataFound := sharedConstantString(mod, "_runtime.msg.no_data_found", NoDataFoundMessage)
+	beyondCount := sharedConstantString(mod, "_runtime.msg.subscript_beyond_count", SubscriptBeyondCountMessage)
+
+	coll := newCollectionParam()
+	key := ir.NewParam("key", types.I64)
+	elemSize := ir.NewParam("elemSize", types.I64)
+	kind := ir.NewParam("kind", types.I64)
+	f := mod.NewFunc(CollectionElementFuncName, i8Ptr, coll, key, elemSize, kind)
+	entry := f.NewBlock("entry")
+	searchBlock := f.NewBlock("search")
+	checkBlock := f.NewBlock("check")
+	foundBlock := f.NewBlock("found")
+	missingBlock := f.NewBlock("missing")
+	denseBlock := f.NewBlock("dense")
+	beyondBlock := f.NewBlock("beyond")
+	noDataBlock := f.NewBlock("no-data")
+
+	entry.NewCondBr(entry.NewICmp(enum.IPredEQ, coll, constant.NewNull(coll.Typ.(*types.PointerType))), missingBlock, searchBlock)
+
+	pos := searchBlock.NewCall(find, coll, key)
+	count := searchBlock.NewLoad(collectionField(searchBlock, coll, collectionCountField))
+	searchBlock.NewCondBr(searchBlock.NewICmp(enum.IPredSLT, pos, count), checkBlock, missingBlock)
+
+	k := checkBlock.NewLoad(collectionKey(checkBlock, coll, pos))
+	checkBlock.NewCondBr(checkBlock.NewICmp(enum.IPredEQ, k, key), foundBlock, missingBlock)
+
+	foundBlock.NewRet(collectionValue(foundBlock, coll, pos, elemSize))
+
+	missingBlock.NewCondBr(missingBlock.NewICmp(enum.IPredEQ, kind, i64Constant(CollectionAssociative)), noDataBlock, denseBlock)
+
+	size := denseBlock.NewCall(getFuncByName(collectionSizeFuncName, mod), coll)
+	isBeyond := denseBlock.NewOr(denseBlock.NewICmp(enum.IPredSLT, key, llvmOneI64), denseBlock.NewICmp(enum.IPredSGT, key, size))
+	denseBlock.NewCondBr(isBeyond, beyondBlock, noDataBlock)
+
+	Raise(mod, beyondBlock, beyondCount)
+	Raise(mod, noDataBlock, noDataFound)
+}
+
+// generateCollectionAssign returns a pointer to the element at key, the element is created if it doesn't exist
+// new elements are all zeros, nested tables and varrays need to be extended before an index can be assigned
+func generateCollectionAssign(mod *ir.Module) {
+	find := getFuncByName(collectionFindFuncName, mod)
+	memmove := getFuncByName("memmove", mod)
+	memset := getFuncByName("memset", mod)
+	beyondCount := sharedConstantString(mod, "_runtime.msg.subscript_beyond_count", SubscriptBeyondCountMessage)
+
+	slot := newSlotParam()
+	key := ir.NewParam("key", types.I64)
+	elemSize := ir.NewParam("elemSize", types.I64)
+	kind := ir.NewParam("kind", types.I64)
+	f := mod.NewFunc(CollectionAssignFuncName, i8Ptr, slot, key, elemSize, kind)
+	entry := f.NewBlock("entry")
+	denseBlock := f.NewBlock("dense")
+	insertBlock := f.NewBlock("insert")
+	checkBlock := f.NewBlock("check")
+	foundBlock := f.NewBlock("found")
+	newBlock := f.NewBlock("new")
+
+	entry.NewCondBr(entry.NewICmp(enum.IPredEQ, kind, i64Constant(CollectionAssociative)), insertBlock, denseBlock)
+
+	size := denseBlock.NewCall(getFuncByName(collectionSizeFuncName, mod), denseBlock.NewLoad(slot))
+	isValid := denseBlock.NewAnd(denseBlock.NewICmp(enum.IPredSGE, key, llvmOneI64), denseBlock.NewICmp(enum.IPredSLE, key, size))
+	b := checkOrRaise(mod, denseBlock, isValid, beyondCount)
+	b.NewBr(insertBlock)
+
+	// there is room for one more element, the position after the last one can be read
+	coll := insertBlock.NewCall(getFuncByName(collectionReserveFuncName, mod), slot, llvmOneI64, elemSize)
+	pos := insertBlock.NewCall(find, coll, key)
+	countField := collectionField(insertBlock, coll, collectionCountField)
+	count := insertBlock.NewLoad(countField)
+	insertBlock.NewCondBr(insertBlock.NewICmp(enum.IPredSLT, pos, count), checkBlock, newBlock)
+
+	k := checkBlock.NewLoad(collectionKey(checkBlock, coll, pos))
+	checkBlock.NewCondBr(checkBlock.NewICmp(enum.IPredEQ, k, key), foundBlock, newBlock)
+
+	foundBlock.NewRet(collectionValue(foundBlock, coll, pos, elemSize))
+
+	b = newBlock
+	moved := b.NewSub(count, pos)
+	keyPtr := collectionKey(b, coll, pos)
+	keyBytes := b.NewBitCast(keyPtr, i8Ptr)
+	nextKeyBytes := b.NewBitCast(b.NewGetElementPtr(keyPtr, llvmOneI64), i8Ptr)
+	b.NewCall(memmove, nextKeyBytes, keyBytes, b.NewMul(moved, i64Constant(8)))
+	valuePtr := collectionValue(b, coll, pos, elemSize)
+	b.NewCall(memmove, b.NewGetElementPtr(valuePtr, elemSize), valuePtr, b.NewMul(moved, elemSize))
+	b.NewStore(key, keyPtr)
+	b.NewCall(memset, valuePtr, llvmZeroI32, elemSize)
+	b.NewStore(b.NewAdd(count, llvmOneI64), countField)
+	b.NewRet(valuePtr)
+}
+
+// generateCollectionExtend appends n elements that are all zeros to a nested table or varray
+// and returns a pointer to the first of them, varrays can't grow beyond their limit
+// a limit of 0 doesn't limit the collection
+func generateCollectionExtend(mod *ir.Module) {
+	memset := getFuncByName("memset", mod)
+	outsideLimit := NewConstantString(mod, "_runtime.msg.subscript_outside_limit", SubscriptOutsideLimitMessage)
+
+	slot := newSlotParam()
+	n := ir.NewParam("n", types.I64)
+	elemSize := ir.NewParam("elemSize", types.I64)
+	limit := ir.NewParam("limit", types.I64)
+	f := mod.NewFunc(CollectionExtendFuncName, i8Ptr, slot, n, elemSize, limit)
+	entry := f.NewBlock("entry")
+	condBlock := f.NewBlock("cond")
+	keyBlock := f.NewBlock("has-key")
+	doneBlock := f.NewBlock("done")
+
+	size := entry.NewCall(getFuncByName(collectionSizeFuncName, mod), entry.NewLoad(slot))
+	newSize := entry.NewAdd(size, n)
+	isLimited := entry.NewICmp(enum.IPredSGT, limit, llvmZeroI64)
+	isOutside := entry.NewOr(entry.NewICmp(enum.IPredSLT, n, llvmZeroI64), entry.NewAnd(isLimited, entry.NewICmp(enum.IPredSGT, newSize, limit)))
+	b := checkOrRaise(mod, entry, entry.NewXor(isOutside, constant.True), outsideLimit)
+	coll := b.NewCall(getFuncByName(collectionReserveFuncName, mod), slot, n, elemSize)
+	countField := collectionField(b, coll, collectionCountField)
+	count := b.NewLoad(countField)
+	i := b.NewAlloca(types.I64)
+	b.NewStore(llvmZeroI64, i)
+	b.NewBr(condBlock)
+
+	idx := condBlock.NewLoad(i)
+	condBlock.NewCondBr(condBlock.NewICmp(enum.IPredSLT, idx, n), keyBlock, doneBlock)
+
+	// the new elements get the indexes after the old size
+	keyBlock.NewStore(keyBlock.NewAdd(size, keyBlock.NewAdd(idx, llvmOneI64)), collectionKey(keyBlock, coll, keyBlock.NewAdd(count, idx)))
+	keyBlock.NewStore(keyBlock.NewAdd(idx, llvmOneI64), i)
+	keyBlock.NewBr(condBlock)
+
+	first := collectionValue(doneBlock, coll, count, elemSize)
+	doneBlock.NewCall(memset, first, llvmZeroI32, doneBlock.NewMul(n, elemSize))
+	doneBlock.NewStore(doneBlock.NewAdd(count, n), countField)
+	doneBlock.NewStore(newSize, collectionField(doneBlock, coll, collectionSizeField))
+	doneBlock.NewRet(first)
+}
+
+// generateCollectionTrim removes the last n indexes of a nested table or varray
+// indexes that have been deleted count as well
+func generateCollectionTrim(mod *ir.Module) {
+	beyondCount := sharedConstantString(mod, "_runtime.msg.subscript_beyond_count", SubscriptBeyondCountMessage)
+
+	coll := newCollectionParam()
+	n := ir.NewParam("n", types.I64)
+	f := mod.NewFunc(CollectionTrimFuncName, types.Void, coll, n)
+	entry := f.NewBlock("entry")
+	size := entry.NewCall(getFuncByName(collectionSizeFuncName, mod), coll)
+	isBeyond := entry.NewOr(entry.NewICmp(enum.IPredSLT, n, llvmZeroI64), entry.NewICmp(enum.IPredSGT, n, size))
+	b := checkOrRaise(mod, entry, entry.NewXor(isBeyond, constant.True), beyondCount)
+	// an empty collection can only be trimmed by 0
+	b = ifCollection(f, b, coll, nil)
+	newSize := b.NewSub(size, n)
+	b.NewStore(newSize, collectionField(b, coll, collectionSizeField))
+	pos := b.NewCall(getFuncByName(collectionFindFuncName, mod), coll, b.NewAdd(newSize, llvmOneI64))
+	b.NewStore(pos, collectionField(b, coll, collectionCountField))
+	b.NewRet(nil)
+}
+
+// generateCollectionDelete creates the functions that delete all elements or the elements from lo to hi
+// deleting all elements resets the size of nested tables and varrays, deleting some leaves holes
+func generateCollectionDelete(mod *ir.Module) {
+	find := getFuncByName(collectionFindFuncName, mod)
+	memmove := getFuncByName("memmove", mod)
+
+	coll := newCollectionParam()
+	f := mod.NewFunc(CollectionDeleteFuncName, types.Void, coll)
+	b := ifCollection(f, f.NewBlock("entry"), coll, nil)
+	b.NewStore(llvmZeroI64, collectionField(b, coll, collectionCountField))
+	b.NewStore(llvmZeroI64, collectionField(b, coll, collectionSizeField))
+	b.NewRet(nil)
+
+	coll = newCollectionParam()
+	lo := ir.NewParam("lo", types.I64)
+	hi := ir.NewParam("hi", types.I64)
+	elemSize := ir.NewParam("elemSize", types.I64)
+	f = mod.NewFunc(CollectionDeleteRangeFuncName, types.Void, coll, lo, hi, elemSize)
+	b = ifCollection(f, f.NewBlock("entry"), coll, nil)
+	from := b.NewCall(find, coll, lo)
+	// a range whose bounds are the wrong way around is empty
+	end := b.NewCall(find, coll, b.NewAdd(hi, llvmOneI64))
+	to := b.NewSelect(b.NewICmp(enum.IPredSLT, end, from), from, end)
+	countField := collectionField(b, coll, collectionCountField)
+	count := b.NewLoad(countField)
+	moved := b.NewSub(count, to)
+	keys := b.NewBitCast(collectionKey(b, coll, from), i8Ptr)
+	b.NewCall(memmove, keys, b.NewBitCast(collectionKey(b, coll, to), i8Ptr), b.NewMul(moved, i64Constant(8)))
+	values := collectionValue(b, coll, from, elemSize)
+	b.NewCall(memmove, values, collectionValue(b, coll, to, elemSize), b.NewMul(moved, elemSize))
+	b.NewStore(b.NewSub(count, b.NewSub(to, from)), countField)
+	b.NewRet(nil)
+}
+
+// generateCollectionAttributes creates COUNT, FIRST, LAST, NEXT, PRIOR and EXISTS
+// indexes that don't exist are returned as 0, there are no NULL integers
+func generateCollectionAttributes(mod *ir.Module) {
+	find := getFuncByName(collectionFindFuncName, mod)
+
+	coll := newCollectionParam()
+	f := mod.NewFunc(CollectionCountFuncName, types.I64, coll)
+	b := ifCollection(f, f.NewBlock("entry"), coll, llvmZeroI64)
+	b.NewRet(b.NewLoad(collectionField(b, coll, collectionCountField)))
+	countFunc := f
+
+	// keyAt returns the index at the position pos computes, or 0 if there is no element at it
+	keyAt := func(name string, pos func(b *ir.Block, coll value.Value, key value.Value) value.Value, params ...*ir.Param) {
+		coll := newCollectionParam()
+		f := mod.NewFunc(name, types.I64, append([]*ir.Param{coll}, params...)...)
+		b := ifCollection(f, f.NewBlock("entry"), coll, llvmZeroI64)
+		keyBlock := f.NewBlock("has-key")
+		noKeyBlock := f.NewBlock("no-key")
+		var key value.Value
+		if len(params) > 0 {
+			key = params[0]
+		}
+		p := pos(b, coll, key)
+		count := b.NewLoad(collectionField(b, coll, collectionCountField))
+		b.NewCondBr(b.NewAnd(b.NewICmp(enum.IPredSGE, p, llvmZeroI64), b.NewICmp(enum.IPredSLT, p, count)), keyBlock, noKeyBlock)
+		keyBlock.NewRet(keyBlock.NewLoad(collectionKey(keyBlock, coll, p)))
+		noKeyBlock.NewRet(llvmZeroI64)
+	}
+
+	keyAt(CollectionFirstFuncName, func(b *ir.Block, coll value.Value, key value.Value) value.Value {
+		return llvmZeroI64
+	})
+	keyAt(CollectionLastFuncName, func(b *ir.Block, coll value.Value, key value.Value) value.Value {
+		return b.NewSub(b.NewCall(countFunc, coll), llvmOneI64)
+	})
+	keyAt(CollectionNextFuncName, func(b *ir.Block, coll value.Value, key value.Value) value.Value {
+		return b.NewCall(find, coll, b.NewAdd(key, llvmOneI64))
+	}, ir.NewParam("key", types.I64))
+	keyAt(CollectionPriorFuncName, func(b *ir.Block, coll value.Value, key value.Value) value.Value {
+		return b.NewSub(b.NewCall(find, coll, key), llvmOneI64)
+	}, ir.NewParam("key", types.I64))
+
+	coll = newCollectionParam()
+	key := ir.NewParam("key", types.I64)
+	f = mod.NewFunc(CollectionExistsFuncName, types.I1, coll, key)
+	b = ifCollection(f, f.NewBlock("entry"), coll, constant.False)
+	checkBlock := f.NewBlock("check")
+	missingBlock := f.NewBlock("missing")
+	pos := b.NewCall(find, coll, key)
+	count := b.NewLoad(collectionField(b, coll, collectionCountField))
+	b.NewCondBr(b.NewICmp(enum.IPredSLT, pos, count), checkBlock, missingBlock)
+	checkBlock.NewRet(checkBlock.NewICmp(enum.IPredEQ, checkBlock.NewLoad(collectionKey(checkBlock, coll, pos)), key))
+	missingBlock.NewRet(constant.False)
+}
+
+// generateCollectionCopy returns a collection with the same elements as coll
+// assigning a collection copies it, the two variables don't share their elements
+func generateCollectionCopy(mod *ir.Module) {
+	memcpy := getFuncByName("memcpy", mod)
+	collPtr := types.NewPointer(CollectionType)
+
+	coll := newCollectionParam()
+	elemSize := ir.NewParam("elemSize", types.I64)
+	f := mod.NewFunc(CollectionCopyFuncName, collPtr, coll, elemSize)
+	b := ifCollection(f, f.NewBlock("entry"), coll, constant.NewNull(collPtr))
+	slot := b.NewAlloca(collPtr)
+	b.NewStore(constant.NewNull(collPtr), slot)
+	count := b.NewLoad(collectionField(b, coll, collectionCountField))
+	copied := b.NewCall(getFuncByName(collectionReserveFuncName, mod), slot, count, elemSize)
+	b.NewStore(count, collectionField(b, copied, collectionCountField))
+	b.NewStore(b.NewLoad(collectionField(b, coll, collectionSizeField)), collectionField(b, copied, collectionSizeField))
+	b.NewCall(memcpy, b.NewBitCast(collectionKey(b, copied, llvmZeroI64), i8Ptr), b.NewBitCast(collectionKey(b, coll, llvmZeroI64), i8Ptr), b.NewMul(count, i64Constant(8)))
+	b.NewCall(memcpy, collectionValue(b, copied, llvmZeroI64, elemSize), collectionValue(b, coll, llvmZeroI64, elemSize), b.NewMul(count, elemSize))
+	b.NewRet(copied)
+}
@@ -0,0 +1,412 @@
+/*
+ * Copyright 2019 Marco Helmich
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package runtime
+
+import (
+	"math"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+const (
+	RaiseFuncName          = "_runtime._raise"
+	StringToNumberFuncName = "_runtime._strToNumber"
+	NumberToIntFuncName    = "_runtime._numberToInt"
+	IntToStringFuncName    = "_runtime._intToStr"
+	NumberToStringFuncName = "_runtime._numberToStr"
+	PrintNumberFuncName    = "_runtime.printNumber"
+	// NormalizeNumberFuncName rounds a double to the significant digits of a NUMBER
+	NormalizeNumberFuncName = "_runtime._normalizeNumber"
+
+	// characters that can appear in a number in its textual form
+	numberChars = " +-.0123456789eE"
+
+	ValueErrorMessage = "ORA-06502: PL/SQL: numeric or value error: character to number conversion error"
+
+	// a NUMBER is a double, only its first 15 significant decimal digits are exact
+	// comparisons, rounding and formatting of NUMBERs only look at those digits
+	numberDigits = 15
+	// numbers that take more characters than this in fixed notation are printed in scientific notation
+	maxNumberChars = 64
+)
+
+var (
+	i8Ptr = types.NewPointer(types.I8)
+)
+
+// declareLibc declares the functions of the c standard library the runtime is built on
+func declareLibc(mod *ir.Module) {
+	mod.NewFunc("malloc", i8Ptr, ir.NewParam("size", types.I64))
+	mod.NewFunc("free", types.Void, ir.NewParam("ptr", i8Ptr))
+	mod.NewFunc("realloc", i8Ptr, ir.NewParam("ptr", i8Ptr), ir.NewParam("size", types.I64))
+	mod.NewFunc("memcpy", i8Ptr, ir.NewParam("dest", i8Ptr), ir.NewParam("src", i8Ptr), ir.NewParam("n", types.I64))
+	mod.NewFunc("memmove", i8Ptr, ir.NewParam("dest", i8Ptr), ir.NewParam("src", i8Ptr), ir.NewParam("n", types.I64))
+	mod.NewFunc("memset", i8Ptr, ir.NewParam("s", i8Ptr), ir.NewParam("c", types.I32), ir.NewParam("n", types.I64))
+	mod.NewFunc("memcmp", types.I32, ir.NewParam("s1", i8Ptr), ir.NewParam("s2", i8Ptr), ir.NewParam("n", types.I64))
+	mod.NewFunc("memchr", i8Ptr, ir.NewParam("s", i8Ptr), ir.NewParam("c", types.I32), ir.NewParam("n", types.I64))
+	mod.NewFunc("toupper", types.I32, ir.NewParam("c", types.I32))
+	mod.NewFunc("tolower", types.I32, ir.NewParam("c", types.I32))
+	mod.NewFunc("isalnum", types.I32, ir.NewParam("c", types.I32))
+	mod.NewFunc("strspn", types.I64, ir.NewParam("s", i8Ptr), ir.NewParam("accept", i8Ptr))
+	mod.NewFunc("strtod", types.Double, ir.NewParam("s", i8Ptr), ir.NewParam("end", types.NewPointer(i8Ptr)))
+	snprintf := mod.NewFunc("snprintf", types.I32, ir.NewParam("s", i8Ptr), ir.NewParam("n", types.I64), ir.NewParam("format", i8Ptr))
+	snprintf.Sig.Variadic = true
+	sscanf := mod.NewFunc("sscanf", types.I32, ir.NewParam("s", i8Ptr), ir.NewParam("format", i8Ptr))
+	sscanf.Sig.Variadic = true
+	mod.NewFunc("write", types.I64, ir.NewParam("fd", types.I32), ir.NewParam("buf", i8Ptr), ir.NewParam("n", types.I64))
+	mod.NewFunc("exit", types.Void, ir.NewParam("status", types.I32))
+	mod.NewFunc("fflush", types.I32, ir.NewParam("stream", i8Ptr))
+	mod.NewFunc("getenv", i8Ptr, ir.NewParam("name", i8Ptr))
+	// struct timespec and struct tm are only ever passed around
+	mod.NewFunc("clock_gettime", types.I32, ir.NewParam("clock", types.I32), ir.NewParam("ts", types.NewPointer(types.NewStruct(types.I64, types.I64))))
+	mod.NewFunc("localtime", i8Ptr, ir.NewParam("t", types.NewPointer(types.I64)))
+	mod.NewFunc("timegm", types.I64, ir.NewParam("tm", i8Ptr))
+}
+
+// newConstantCString creates a zero terminated string in a global and returns a pointer to it
+func newConstantCString(mod *ir.Module, name string, s string) constant.Constant {
+	g := mod.NewGlobalDef(name, constant.NewCharArrayFromString(s+"\x00"))
+	g.Immutable = true
+	return constant.NewGetElementPtr(g, llvmZeroI32, llvmZeroI32)
+}
+
+// NewConstantString creates a '_runtime._string' whose characters live in a global
+func NewConstantString(mod *ir.Module, name string, s string) constant.Constant {
+	g := mod.NewGlobalDef(name, constant.NewCharArrayFromString(s))
+	g.Immutable = true
+	dataPtr := constant.NewGetElementPtr(g, llvmZeroI32, llvmZeroI32)
+	return constant.NewStruct(StringType.(*types.StructType), dataPtr, constant.NewInt(types.I64, int64(len(s))))
+}
+
+// sharedConstantString works like NewConstantString
+// but the characters are created only the first time a string with that name is needed
+func sharedConstantString(mod *ir.Module, name string, s string) constant.Constant {
+	for idx := range mod.Globals {
+		if mod.Globals[idx].Name() == name {
+			dataPtr := constant.NewGetElementPtr(mod.Globals[idx], llvmZeroI32, llvmZeroI32)
+			return constant.NewStruct(StringType.(*types.StructType), dataPtr, constant.NewInt(types.I64, int64(len(s))))
+		}
+	}
+	return NewConstantString(mod, name, s)
+}
+
+// sharedConstantCString works like newConstantCString
+// but the characters are created only the first time a string with that name is needed
+func sharedConstantCString(mod *ir.Module, name string, s string) constant.Constant {
+	for idx := range mod.Globals {
+		if mod.Globals[idx].Name() == name {
+			return constant.NewGetElementPtr(mod.Globals[idx], llvmZeroI32, llvmZeroI32)
+		}
+	}
+	return newConstantCString(mod, name, s)
+}
+
+// newString builds a '_runtime._string' value out of a pointer to its characters and its length
+func newString(b *ir.Block, data value.Value, len value.Value) value.Value {
+	s := b.NewInsertValue(constant.NewUndef(StringType), data, 0)
+	return b.NewInsertValue(s, len, 1)
+}
+
+// Raise prints the message of an error and terminates the program
+// it terminates the block it is called in
+func Raise(mod *ir.Module, b *ir.Block, message constant.Constant) {
+	b.NewCall(getFuncByName(RaiseFuncName, mod), message)
+	b.NewUnreachable()
+}
+
+// valueError returns the message of the error for strings that don't contain a number
+func valueError(mod *ir.Module) constant.Constant {
+	return sharedConstantString(mod, "_runtime.msg.value_error", ValueErrorMessage)
+}
+
+func generateConversions(mod *ir.Module) {
+	generate_strToNumber(mod)
+	generate_numberToInt(mod)
+	generate_normalizeNumber(mod)
+	generate_intToStr(mod)
+	generate_numberToStr(mod)
+	generateprintNumber(mod)
+}
+
+func generate_raise(mod *ir.Module) {
+	write := getFuncByName("write", mod)
+	exit := getFuncByName("exit", mod)
+	fflush := getFuncByName("fflush", mod)
+	newline := newConstantCString(mod, "_runtime.newline", "\n")
+
+	message := ir.NewParam("message", StringType)
+	raise := mod.NewFunc(RaiseFuncName, types.Void, message)
+	entry := raise.NewBlock("entry")
+	// everything that has been printed so far goes before the error
+	entry.NewCall(getFuncByName(dbmsOutputFlushFuncName, mod))
+	entry.NewCall(fflush, constant.NewNull(i8Ptr))
+	stderr := constant.NewInt(types.I32, 2)
+	entry.NewCall(write, stderr, entry.NewExtractValue(message, 0), entry.NewExtractValue(message, 1))
+	entry.NewCall(write, stderr, newline, llvmOneI64)
+	entry.NewCall(exit, llvmOneI32)
+	entry.NewUnreachable()
+}
+
+// generate_strToNumber converts a string into a number
+// leading and trailing blanks are ignored, anything else that isn't part of the number raises VALUE_ERROR
+func generate_strToNumber(mod *ir.Module) {
+	malloc := getFuncByName("malloc", mod)
+	free := getFuncByName("free", mod)
+	memcpy := getFuncByName("memcpy", mod)
+	strspn := getFuncByName("strspn", mod)
+	strtod := getFuncByName("strtod", mod)
+	numberCharsPtr := newConstantCString(mod, "_runtime.number_chars", numberChars)
+
+	s := ir.NewParam("s", StringType)
+	f := mod.NewFunc(StringToNumberFuncName, types.Double, s)
+	entry := f.NewBlock("entry")
+	copyBlock := f.NewBlock("copy")
+	parseBlock := f.NewBlock("parse")
+	skipBlankBlock := f.NewBlock("skip-blank")
+	checkEndBlock := f.NewBlock("check-end")
+	successBlock := f.NewBlock("success")
+	errorBlock := f.NewBlock("error")
+
+	data := entry.NewExtractValue(s, 0)
+	len := entry.NewExtractValue(s, 1)
+	end := entry.NewAlloca(i8Ptr)
+	entry.NewCondBr(entry.NewICmp(enum.IPredEQ, len, llvmZeroI64), errorBlock, copyBlock)
+
+	// strtod needs a zero terminated string
+	buf := copyBlock.NewCall(malloc, copyBlock.NewAdd(len, llvmOneI64))
+	copyBlock.NewCall(memcpy, buf, data, len)
+	bufEnd := copyBlock.NewGetElementPtr(buf, len)
+	copyBlock.NewStore(constant.NewInt(types.I8, 0), bufEnd)
+	validChars := copyBlock.NewCall(strspn, buf, numberCharsPtr)
+	copyBlock.NewCondBr(copyBlock.NewICmp(enum.IPredEQ, validChars, len), parseBlock, errorBlock)
+
+	d := parseBlock.NewCall(strtod, buf, end)
+	parseBlock.NewBr(checkEndBlock)
+
+	// trailing blanks are fine
+	endChar := checkEndBlock.NewLoad(checkEndBlock.NewLoad(end))
+	isBlank := checkEndBlock.NewICmp(enum.IPredEQ, endChar, constant.NewInt(types.I8, ' '))
+	checkEndBlock.NewCondBr(isBlank, skipBlankBlock, successBlock)
+
+	skipBlankBlock.NewStore(skipBlankBlock.NewGetElementPtr(skipBlankBlock.NewLoad(end), llvmOneI64), end)
+	skipBlankBlock.NewBr(checkEndBlock)
+
+	atEnd := successBlock.NewICmp(enum.IPredEQ, successBlock.NewLoad(end), bufEnd)
+	successBlock.NewCall(free, buf)
+	returnBlock := f.NewBlock("return")
+	successBlock.NewCondBr(atEnd, returnBlock, errorBlock)
+	returnBlock.NewRet(d)
+
+	Raise(mod, errorBlock, valueError(mod))
+}
+
+// generate_numberToInt rounds a number to the closest integer, halves are rounded away from zero
+func generate_numberToInt(mod *ir.Module) {
+	d := ir.NewParam("d", types.Double)
+	f := mod.NewFunc(NumberToIntFuncName, types.I64, d)
+	entry := f.NewBlock("entry")
+	half := constant.NewFloat(types.Double, 0.5)
+	isNegative := entry.NewFCmp(enum.FPredOLT, d, constant.NewFloat(types.Double, 0))
+	rounded := entry.NewSelect(isNegative, entry.NewFSub(d, half), entry.NewFAdd(d, half))
+	entry.NewRet(entry.NewFPToSI(rounded, types.I64))
+}
+
+func generate_intToStr(mod *ir.Module) {
+	allocStr := getFuncByName(AllocStringFuncName, mod)
+	snprintf := getFuncByName("snprintf", mod)
+	format := newConstantCString(mod, "_runtime.format.int", "%lld")
+
+	i := ir.NewParam("i", types.I64)
+	f := mod.NewFunc(IntToStringFuncName, StringType, i)
+	entry := f.NewBlock("entry")
+	size := constant.NewInt(types.I64, 24)
+	buf := entry.NewCall(allocStr, size)
+	n := entry.NewSExt(entry.NewCall(snprintf, buf, size, format, i), types.I64)
+	releaseTail(mod, entry, entry.NewGetElementPtr(buf, n), entry.NewGetElementPtr(buf, size))
+	entry.NewRet(newString(entry, buf, n))
+}
+
+// generate_normalizeNumber rounds a number to the significant digits a NUMBER holds
+// '0.1 + 0.2' is '0.30000000000000004' as a double but the same as '0.3' as a NUMBER
+func generate_normalizeNumber(mod *ir.Module) {
+	snprintf := getFuncByName("snprintf", mod)
+	strtod := getFuncByName("strtod", mod)
+	format := newConstantCString(mod, "_runtime.format.normalize", "%.14e")
+
+	d := ir.NewParam("d", types.Double)
+	f := mod.NewFunc(NormalizeNumberFuncName, types.Double, d)
+	entry := f.NewBlock("entry")
+	size := constant.NewInt(types.I64, 32)
+	buf := entry.NewGetElementPtr(entry.NewAlloca(types.NewArray(32, types.I8)), llvmZeroI32, llvmZeroI32)
+	entry.NewCall(snprintf, buf, size, format, d)
+	entry.NewRet(entry.NewCall(strtod, buf, constant.NewNull(types.NewPointer(i8Ptr))))
+}
+
+// generate_numberToStr formats a number the way Oracle does by default
+// that is in fixed notation with the significant digits of a NUMBER ('.3', '-.5', '100000000000000000000')
+// without a leading zero before the decimal point and without trailing zeros after it
+// numbers that take more than 64 characters that way are printed in scientific notation ('1.5E+100')
+func generate_numberToStr(mod *ir.Module) {
+	allocStr := getFuncByName(AllocStringFuncName, mod)
+	snprintf := getFuncByName("snprintf", mod)
+	strtod := getFuncByName("strtod", mod)
+	fabs := getFuncByName("fabs", mod)
+	memcpy := getFuncByName("memcpy", mod)
+	memset := getFuncByName("memset", mod)
+	scientificFormat := newConstantCString(mod, "_runtime.format.scientific", "%.14e")
+	fixedFormat := newConstantCString(mod, "_runtime.format.fixed", "%.*f")
+	exponentFormat := newConstantCString(mod, "_runtime.format.exponent", "%.*sE%+lld")
+	nan := NewConstantString(mod, "_runtime.str.nan", "Nan")
+	inf := NewConstantString(mod, "_runtime.str.inf", "Inf")
+	negInf := NewConstantString(mod, "_runtime.str.neg_inf", "-Inf")
+
+	d := ir.NewParam("d", types.Double)
+	f := mod.NewFunc(NumberToStringFuncName, StringType, d)
+	entry := f.NewBlock("entry")
+	checkInfBlock := f.NewBlock("check-inf")
+	nanBlock := f.NewBlock("nan")
+	infBlock := f.NewBlock("inf")
+	finiteBlock := f.NewBlock("finite")
+	smallBlock := f.NewBlock("small")
+	trimCheckBlock := f.NewBlock("trim-check")
+	trimZeroBlock := f.NewBlock("trim-zero")
+	trimPointBlock := f.NewBlock("trim-point")
+	leadingBlock := f.NewBlock("leading-zero")
+	stripBlock := f.NewBlock("strip-zero")
+	lengthBlock := f.NewBlock("length")
+	fixedBlock := f.NewBlock("fixed")
+	largeBlock := f.NewBlock("large")
+	largeFixedBlock := f.NewBlock("large-fixed")
+	scientificBlock := f.NewBlock("scientific")
+	mantissaCheckBlock := f.NewBlock("mantissa-check")
+	mantissaZeroBlock := f.NewBlock("mantissa-zero")
+	mantissaDoneBlock := f.NewBlock("mantissa-done")
+
+	// 'd.ddddddddddddddde+xx' holds the significant digits and the exponent
+	sci := entry.NewGetElementPtr(entry.NewAlloca(types.NewArray(32, types.I8)), llvmZeroI32, llvmZeroI32)
+	start := entry.NewAlloca(i8Ptr)
+	length := entry.NewAlloca(types.I64)
+	mantissaLen := entry.NewAlloca(types.I64)
+	entry.NewCondBr(entry.NewFCmp(enum.FPredUNO, d, d), nanBlock, checkInfBlock)
+
+	nanBlock.NewRet(nan)
+
+	isInf := checkInfBlock.NewFCmp(enum.FPredOGT, checkInfBlock.NewCall(fabs, d), constant.NewFloat(types.Double, math.MaxFloat64))
+	checkInfBlock.NewCondBr(isInf, infBlock, finiteBlock)
+
+	infBlock.NewRet(infBlock.NewSelect(infBlock.NewFCmp(enum.FPredOLT, d, llvmZeroDouble), negInf, inf))
+
+	// adding zero turns -0 into 0
+	x := finiteBlock.NewFAdd(d, llvmZeroDouble)
+	finiteBlock.NewCall(snprintf, sci, constant.NewInt(types.I64, 32), scientificFormat, x)
+	signLen := finiteBlock.NewZExt(finiteBlock.NewFCmp(enum.FPredOLT, x, llvmZeroDouble), types.I64)
+	exponentStr := finiteBlock.NewGetElementPtr(sci, finiteBlock.NewAdd(signLen, constant.NewInt(types.I64, 17)))
+	exponent := finiteBlock.NewFPToSI(finiteBlock.NewCall(strtod, exponentStr, constant.NewNull(types.NewPointer(i8Ptr))), types.I64)
+	isLarge := finiteBlock.NewICmp(enum.IPredSGE, exponent, constant.NewInt(types.I64, numberDigits))
+	finiteBlock.NewCondBr(isLarge, largeBlock, smallBlock)
+
+	// as many digits after the decimal point as there are significant digits left
+	precision := smallBlock.NewSub(constant.NewInt(types.I64, numberDigits-1), exponent)
+	size := smallBlock.NewAdd(precision, constant.NewInt(types.I64, 24))
+	buf := smallBlock.NewCall(allocStr, size)
+	n := smallBlock.NewSExt(smallBlock.NewCall(snprintf, buf, size, fixedFormat, smallBlock.NewTrunc(precision, types.I32), x), types.I64)
+	smallBlock.NewStore(buf, start)
+	smallBlock.NewStore(n, length)
+	smallBlock.NewCondBr(smallBlock.NewICmp(enum.IPredEQ, precision, llvmZeroI64), leadingBlock, trimCheckBlock)
+
+	last := trimCheckBlock.NewSub(trimCheckBlock.NewLoad(length), llvmOneI64)
+	isZero := trimCheckBlock.NewICmp(enum.IPredEQ, trimCheckBlock.NewLoad(trimCheckBlock.NewGetElementPtr(buf, last)), charConstant('0'))
+	trimCheckBlock.NewCondBr(isZero, trimZeroBlock, trimPointBlock)
+
+	trimZeroBlock.NewStore(last, length)
+	trimZeroBlock.NewBr(trimCheckBlock)
+
+	last = trimPointBlock.NewSub(trimPointBlock.NewLoad(length), llvmOneI64)
+	isPoint := trimPointBlock.NewICmp(enum.IPredEQ, trimPointBlock.NewLoad(trimPointBlock.NewGetElementPtr(buf, last)), charConstant('.'))
+	trimPointBlock.NewStore(trimPointBlock.NewSelect(isPoint, last, trimPointBlock.NewLoad(length)), length)
+	trimPointBlock.NewBr(leadingBlock)
+
+	// '0.5' becomes '.5' and '-0.5' becomes '-.5', '0' stays
+	l := leadingBlock.NewLoad(length)
+	char0 := leadingBlock.NewLoad(leadingBlock.NewGetElementPtr(buf, signLen))
+	char1 := leadingBlock.NewLoad(leadingBlock.NewGetElementPtr(buf, leadingBlock.NewAdd(signLen, llvmOneI64)))
+	hasFraction := leadingBlock.NewICmp(enum.IPredSGT, l, leadingBlock.NewAdd(signLen, llvmOneI64))
+	isLeadingZero := leadingBlock.NewAnd(leadingBlock.NewICmp(enum.IPredEQ, char0, charConstant('0')), leadingBlock.NewICmp(enum.IPredEQ, char1, charConstant('.')))
+	leadingBlock.NewCondBr(leadingBlock.NewAnd(isLeadingZero, hasFraction), stripBlock, lengthBlock)
+
+	// move the sign (if any) onto the zero and start the string one character later
+	stripBlock.NewStore(stripBlock.NewLoad(buf), stripBlock.NewGetElementPtr(buf, signLen))
+	stripBlock.NewStore(stripBlock.NewGetElementPtr(buf, llvmOneI64), start)
+	stripBlock.NewStore(stripBlock.NewSub(stripBlock.NewLoad(length), llvmOneI64), length)
+	stripBlock.NewBr(lengthBlock)
+
+	tooLong := lengthBlock.NewICmp(enum.IPredSGT, lengthBlock.NewLoad(length), constant.NewInt(types.I64, maxNumberChars))
+	lengthBlock.NewCondBr(tooLong, scientificBlock, fixedBlock)
+
+	fixedStart := fixedBlock.NewLoad(start)
+	fixedLen := fixedBlock.NewLoad(length)
+	releaseTail(mod, fixedBlock, fixedBlock.NewGetElementPtr(fixedStart, fixedLen), fixedBlock.NewGetElementPtr(buf, size))
+	fixedBlock.NewRet(newString(fixedBlock, fixedStart, fixedLen))
+
+	// the digits of large numbers are the significant digits followed by zeros
+	tooLarge := largeBlock.NewICmp(enum.IPredSGE, exponent, constant.NewInt(types.I64, maxNumberChars))
+	largeBlock.NewCondBr(tooLarge, scientificBlock, largeFixedBlock)
+
+	digitsLen := largeFixedBlock.NewAdd(signLen, largeFixedBlock.NewAdd(exponent, llvmOneI64))
+	large := largeFixedBlock.NewCall(allocStr, digitsLen)
+	// the sign and the digit before the decimal point, then the digits after it
+	largeFixedBlock.NewCall(memcpy, large, sci, largeFixedBlock.NewAdd(signLen, llvmOneI64))
+	fracDigits := constant.NewInt(types.I64, numberDigits-1)
+	largeFixedBlock.NewCall(memcpy, largeFixedBlock.NewGetElementPtr(large, largeFixedBlock.NewAdd(signLen, llvmOneI64)), largeFixedBlock.NewGetElementPtr(sci, largeFixedBlock.NewAdd(signLen, constant.NewInt(types.I64, 2))), fracDigits)
+	zeros := largeFixedBlock.NewGetElementPtr(large, largeFixedBlock.NewAdd(signLen, constant.NewInt(types.I64, numberDigits)))
+	largeFixedBlock.NewCall(memset, zeros, constant.NewInt(types.I32, '0'), largeFixedBlock.NewSub(exponent, fracDigits))
+	largeFixedBlock.NewRet(newString(largeFixedBlock, large, digitsLen))
+
+	// the mantissa without trailing zeros ('1.5' of '1.50000000000000e+100')
+	scientificBlock.NewStore(scientificBlock.NewAdd(signLen, constant.NewInt(types.I64, numberDigits+1)), mantissaLen)
+	scientificBlock.NewBr(mantissaCheckBlock)
+
+	last = mantissaCheckBlock.NewSub(mantissaCheckBlock.NewLoad(mantissaLen), llvmOneI64)
+	isZero = mantissaCheckBlock.NewICmp(enum.IPredEQ, mantissaCheckBlock.NewLoad(mantissaCheckBlock.NewGetElementPtr(sci, last)), charConstant('0'))
+	mantissaCheckBlock.NewCondBr(isZero, mantissaZeroBlock, mantissaDoneBlock)
+
+	mantissaZeroBlock.NewStore(last, mantissaLen)
+	mantissaZeroBlock.NewBr(mantissaCheckBlock)
+
+	last = mantissaDoneBlock.NewSub(mantissaDoneBlock.NewLoad(mantissaLen), llvmOneI64)
+	isPoint = mantissaDoneBlock.NewICmp(enum.IPredEQ, mantissaDoneBlock.NewLoad(mantissaDoneBlock.NewGetElementPtr(sci, last)), charConstant('.'))
+	mLen := mantissaDoneBlock.NewSelect(isPoint, last, mantissaDoneBlock.NewLoad(mantissaLen))
+	sciSize := constant.NewInt(types.I64, 32)
+	scientific := mantissaDoneBlock.NewCall(allocStr, sciSize)
+	sciLen := mantissaDoneBlock.NewCall(snprintf, scientific, sciSize, exponentFormat, mantissaDoneBlock.NewTrunc(mLen, types.I32), sci, exponent)
+	mantissaDoneBlock.NewRet(newString(mantissaDoneBlock, scientific, mantissaDoneBlock.NewSExt(sciLen, types.I64)))
+}
+
+func generateprintNumber(mod *ir.Module) {
+	numberToStr := getFuncByName(NumberToStringFuncName, mod)
+	printStr := getFuncByName(PrintStringFuncName, mod)
+
+	d := ir.NewParam("d", types.Double)
+	f := mod.NewFunc(PrintNumberFuncName, types.Void, d)
+	entry := f.NewBlock("entry")
+	entry.NewCall(printStr, entry.NewCall(numberToStr, d))
+	entry.NewRet(nil)
+}
@@ -0,0 +1,911 @@
+/*
+ * Copyright 2019 Marco Helmich
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package runtime
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// date format models ('DD-MON-YYYY HH24:MI:SS') as used by TO_CHAR, TO_DATE and TO_TIMESTAMP
+// elements are matched ignoring their case, the case of MONTH, MON, DAY, DY and AM
+// determines the case of the names ('Month' is 'January')
+// other characters and text in double quotes are copied
+const (
+	FormatDateFuncName        = "_runtime.formatDate"
+	ToDateFuncName            = "_runtime.toDate"
+	ToTimestampFuncName       = "_runtime.toTimestamp"
+	DateToStringFuncName      = "_runtime._dateToStr"
+	TimestampToStringFuncName = "_runtime._timestampToStr"
+	StringToDateFuncName      = "_runtime._strToDate"
+	StringToTimestampFuncName = "_runtime._strToTimestamp"
+
+	dateFormatElementFuncName = "_runtime._dateFormatElement"
+	matchesIgnoreCaseFuncName = "_runtime._matchesIgnoreCase"
+	nameAtFuncName            = "_runtime._nameAt"
+	readNameFuncName          = "_runtime._readName"
+	readDigitsFuncName        = "_runtime._readDigits"
+	applyCaseFuncName         = "_runtime._applyCase"
+
+	// the formats Oracle uses by default (NLS_DATE_FORMAT and NLS_TIMESTAMP_FORMAT)
+	DefaultDateFormat      = "DD-MON-RR"
+	DefaultTimestampFormat = "DD-MON-RR HH.MI.SSXFF AM"
+
+	DateFormatNotRecognizedMessage = "ORA-01821: date format not recognized"
+	FormatEndsBeforeInputMessage   = "ORA-01830: date format picture ends before converting entire input string"
+	InputTooShortMessage           = "ORA-01840: input value not long enough for date format"
+	NonNumericCharacterMessage     = "ORA-01858: a non-numeric character was found where a numeric was expected"
+	NotAValidMonthMessage          = "ORA-01843: not a valid month"
+	NotAValidDayMessage            = "ORA-01846: not a valid day of the week"
+	MeridianRequiredMessage        = "ORA-01855: AM/A.M. or PM/P.M. required"
+	DayOfMonthMessage              = "ORA-01847: day of month must be between 1 and last day of month"
+	DayOfYearMessage               = "ORA-01848: day of year must be between 1 and 365 (366 for leap year)"
+	Hour24Message                  = "ORA-01850: hour must be between 0 and 23"
+	Hour12Message                  = "ORA-01849: hour must be between 1 and 12"
+	MinutesMessage                 = "ORA-01851: minutes must be between 0 and 59"
+	SecondsMessage                 = "ORA-01852: seconds must be between 0 and 59"
+
+	// names are stored in slots of this many characters padded with blanks
+	nameWidth = 9
+)
+
+// the elements of date format models
+// elements that start with another element need to come first
+var dateFormatElements = []string{
+	"FM",
+	"YYYY", "RRRR", "YY", "RR",
+	"MONTH", "MON", "MM", "MI",
+	"DDD", "DD", "DAY", "DY", "D",
+	"HH24", "HH12", "HH", "SS",
+	"FF1", "FF2", "FF3", "FF4", "FF5", "FF6", "FF7", "FF8", "FF9", "FF",
+	"AM", "PM", "Q", "X",
+}
+
+var (
+	monthNames    = []string{"JANUARY", "FEBRUARY", "MARCH", "APRIL", "MAY", "JUNE", "JULY", "AUGUST", "SEPTEMBER", "OCTOBER", "NOVEMBER", "DECEMBER"}
+	dayNames      = []string{"SUNDAY", "MONDAY", "TUESDAY", "WEDNESDAY", "THURSDAY", "FRIDAY", "SATURDAY"}
+	meridianNames = []string{"AM", "PM"}
+)
+
+func dateFormatElement(name string) int {
+	for idx := range dateFormatElements {
+		if dateFormatElements[idx] == name {
+			return idx
+		}
+	}
+	panic("unknown date format element " + name)
+}
+
+// newNames creates a global with names in slots of nameWidth characters
+func newNames(mod *ir.Module, name string, names []string) constant.Constant {
+	var sb strings.Builder
+	for idx := range names {
+		sb.WriteString(fmt.Sprintf("%-*s", nameWidth, names[idx]))
+	}
+	return newConstantCString(mod, name, sb.String())
+}
+
+func generateDateFormats(mod *ir.Module) {
+	newNames(mod, "_runtime.names.months", monthNames)
+	newNames(mod, "_runtime.names.days", dayNames)
+	newNames(mod, "_runtime.names.meridians", meridianNames)
+	lengths := make([]constant.Constant, len(dateFormatElements))
+	for idx := range dateFormatElements {
+		lengths[idx] = i64Constant(int64(len(dateFormatElements[idx])))
+	}
+	g := mod.NewGlobalDef("_runtime.date_format.lengths", constant.NewArray(types.NewArray(uint64(len(lengths)), types.I64), lengths...))
+	g.Immutable = true
+
+	generate_matchesIgnoreCase(mod)
+	generate_dateFormatElement(mod)
+	generate_nameAt(mod)
+	generate_readName(mod)
+	generate_readDigits(mod)
+	generate_applyCase(mod)
+	generateFormatDate(mod)
+	generateToTimestamp(mod)
+	generateDefaultDateFormats(mod)
+}
+
+// names returns a pointer to the first slot of a global created by newNames
+func names(mod *ir.Module, name string) constant.Constant {
+	return constant.NewGetElementPtr(getGlobalByName(name, mod), llvmZeroI32, llvmZeroI32)
+}
+
+// generate_matchesIgnoreCase returns true if s contains prefix at pos
+// prefix needs to be upper case
+func generate_matchesIgnoreCase(mod *ir.Module) {
+	toupper := getFuncByName("toupper", mod)
+
+	s := ir.NewParam("s", StringType)
+	pos := ir.NewParam("pos", types.I64)
+	prefix := ir.NewParam("prefix", StringType)
+	f := mod.NewFunc(matchesIgnoreCaseFuncName, types.I1, s, pos, prefix)
+	entry := f.NewBlock("entry")
+	checkBlock := f.NewBlock("check")
+	compareBlock := f.NewBlock("compare")
+	nextBlock := f.NewBlock("next")
+	matchBlock := f.NewBlock("match")
+	noMatchBlock := f.NewBlock("no-match")
+
+	data := entry.NewExtractValue(s, 0)
+	prefixData := entry.NewExtractValue(prefix, 0)
+	prefixLen := entry.NewExtractValue(prefix, 1)
+	fits := entry.NewICmp(enum.IPredSLE, entry.NewAdd(pos, prefixLen), entry.NewExtractValue(s, 1))
+	idx := entry.NewAlloca(types.I64)
+	entry.NewStore(llvmZeroI64, idx)
+	entry.NewCondBr(fits, checkBlock, noMatchBlock)
+
+	i := checkBlock.NewLoad(idx)
+	checkBlock.NewCondBr(checkBlock.NewICmp(enum.IPredSLT, i, prefixLen), compareBlock, matchBlock)
+
+	c := compareBlock.NewLoad(compareBlock.NewGetElementPtr(data, compareBlock.NewAdd(pos, i)))
+	upper := compareBlock.NewTrunc(compareBlock.NewCall(toupper, compareBlock.NewZExt(c, types.I32)), types.I8)
+	expected := compareBlock.NewLoad(compareBlock.NewGetElementPtr(prefixData, i))
+	compareBlock.NewCondBr(compareBlock.NewICmp(enum.IPredEQ, upper, expected), nextBlock, noMatchBlock)
+
+	nextBlock.NewStore(nextBlock.NewAdd(i, llvmOneI64), idx)
+	nextBlock.NewBr(checkBlock)
+
+	matchBlock.NewRet(constant.True)
+	noMatchBlock.NewRet(constant.False)
+}
+
+// generate_dateFormatElement returns the index of the element that starts at pos or -1
+func generate_dateFormatElement(mod *ir.Module) {
+	matches := getFuncByName(matchesIgnoreCaseFuncName, mod)
+
+	format := ir.NewParam("format", StringType)
+	pos := ir.NewParam("pos", types.I64)
+	f := mod.NewFunc(dateFormatElementFuncName, types.I64, format, pos)
+	b := f.NewBlock("entry")
+	for idx, element := range dateFormatElements {
+		name := sharedConstantString(mod, "_runtime.date_format."+element, element)
+		foundBlock := f.NewBlock("found-" + element)
+		foundBlock.NewRet(i64Constant(int64(idx)))
+		nextBlock := f.NewBlock("not-" + element)
+		b.NewCondBr(b.NewCall(matches, format, pos, name), foundBlock, nextBlock)
+		b = nextBlock
+	}
+	b.NewRet(i64Constant(-1))
+}
+
+// generate_nameAt returns the name in slot idx without the padding
+func generate_nameAt(mod *ir.Module) {
+	memchr := getFuncByName("memchr", mod)
+
+	names := ir.NewParam("names", i8Ptr)
+	idx := ir.NewParam("idx", types.I64)
+	f := mod.NewFunc(nameAtFuncName, StringType, names, idx)
+	b := f.NewBlock("entry")
+	slot := b.NewGetElementPtr(names, b.NewMul(idx, i64Constant(nameWidth)))
+	blank := b.NewCall(memchr, slot, constant.NewInt(types.I32, ' '), i64Constant(nameWidth))
+	isPadded := b.NewICmp(enum.IPredNE, blank, constant.NewNull(i8Ptr))
+	padded := b.NewSub(b.NewPtrToInt(blank, types.I64), b.NewPtrToInt(slot, types.I64))
+	b.NewRet(newString(b, slot, b.NewSelect(isPadded, padded, i64Constant(nameWidth))))
+}
+
+// generate_readName looks for one of count names (or their first three characters) in s at *pos
+// it returns the index of the name and moves pos behind it, or -1 if there is none
+func generate_readName(mod *ir.Module) {
+	matches := getFuncByName(matchesIgnoreCaseFuncName, mod)
+	nameAt := getFuncByName(nameAtFuncName, mod)
+
+	s := ir.NewParam("s", StringType)
+	pos := ir.NewParam("pos", types.NewPointer(types.I64))
+	names := ir.NewParam("names", i8Ptr)
+	count := ir.NewParam("count", types.I64)
+	f := mod.NewFunc(readNameFuncName, types.I64, s, pos, names, count)
+	entry := f.NewBlock("entry")
+	checkBlock := f.NewBlock("check")
+	compareBlock := f.NewBlock("compare")
+	nextBlock := f.NewBlock("next")
+	foundBlock := f.NewBlock("found")
+	notFoundBlock := f.NewBlock("not-found")
+
+	// the full names are tried first and their abbreviations second
+	k := entry.NewAlloca(types.I64)
+	entry.NewStore(llvmZeroI64, k)
+	entry.NewBr(checkBlock)
+
+	i := checkBlock.NewLoad(k)
+	checkBlock.NewCondBr(checkBlock.NewICmp(enum.IPredSLT, i, checkBlock.NewMul(count, i64Constant(2))), compareBlock, notFoundBlock)
+
+	b := compareBlock
+	isAbbreviated := b.NewICmp(enum.IPredSGE, i, count)
+	idx := b.NewSelect(isAbbreviated, b.NewSub(i, count), i)
+	name := b.NewCall(nameAt, names, idx)
+	nameLen := b.NewExtractValue(name, 1)
+	isLong := b.NewICmp(enum.IPredSGT, nameLen, i64Constant(3))
+	matchLen := b.NewSelect(b.NewAnd(isAbbreviated, isLong), i64Constant(3), nameLen)
+	p := b.NewLoad(pos)
+	b.NewCondBr(b.NewCall(matches, s, p, newString(b, b.NewExtractValue(name, 0), matchLen)), foundBlock, nextBlock)
+
+	nextBlock.NewStore(nextBlock.NewAdd(i, llvmOneI64), k)
+	nextBlock.NewBr(checkBlock)
+
+	foundBlock.NewStore(foundBlock.NewAdd(p, matchLen), pos)
+	foundBlock.NewRet(idx)
+
+	notFoundBlock.NewRet(i64Constant(-1))
+}
+
+// generate_readDigits reads up to max digits from s at *pos and moves pos behind them
+// it returns -1 if there isn't a digit at pos
+func generate_readDigits(mod *ir.Module) {
+	s := ir.NewParam("s", StringType)
+	pos := ir.NewParam("pos", types.NewPointer(types.I64))
+	max := ir.NewParam("max", types.I64)
+	f := mod.NewFunc(readDigitsFuncName, types.I64, s, pos, max)
+	entry := f.NewBlock("entry")
+	checkBlock := f.NewBlock("check")
+	charBlock := f.NewBlock("char")
+	digitBlock := f.NewBlock("digit")
+	doneBlock := f.NewBlock("done")
+	digitsBlock := f.NewBlock("digits")
+	noDigitsBlock := f.NewBlock("no-digits")
+
+	data := entry.NewExtractValue(s, 0)
+	len := entry.NewExtractValue(s, 1)
+	start := entry.NewLoad(pos)
+	value := entry.NewAlloca(types.I64)
+	entry.NewStore(llvmZeroI64, value)
+	entry.NewBr(checkBlock)
+
+	p := checkBlock.NewLoad(pos)
+	isInString := checkBlock.NewICmp(enum.IPredSLT, p, len)
+	isBelowMax := checkBlock.NewICmp(enum.IPredSLT, checkBlock.NewSub(p, start), max)
+	checkBlock.NewCondBr(checkBlock.NewAnd(isInString, isBelowMax), charBlock, doneBlock)
+
+	c := charBlock.NewLoad(charBlock.NewGetElementPtr(data, p))
+	isDigit := charBlock.NewAnd(charBlock.NewICmp(enum.IPredUGE, c, charConstant('0')), charBlock.NewICmp(enum.IPredULE, c, charConstant('9')))
+	charBlock.NewCondBr(isDigit, digitBlock, doneBlock)
+
+	digit := digitBlock.NewZExt(digitBlock.NewSub(c, charConstant('0')), types.I64)
+	digitBlock.NewStore(digitBlock.NewAdd(digitBlock.NewMul(digitBlock.NewLoad(value), i64Constant(10)), digit), value)
+	digitBlock.NewStore(digitBlock.NewAdd(p, llvmOneI64), pos)
+	digitBlock.NewBr(checkBlock)
+
+	isEmpty := doneBlock.NewICmp(enum.IPredEQ, doneBlock.NewLoad(pos), start)
+	doneBlock.NewCondBr(isEmpty, noDigitsBlock, digitsBlock)
+
+	digitsBlock.NewRet(digitsBlock.NewLoad(value))
+
+	noDigitsBlock.NewRet(i64Constant(-1))
+}
+
+// generate_applyCase lowers the case of a name that has been written in upper case
+// depending on the case of the first two characters of the element in the format model
+// 'MONTH' stays 'JANUARY', 'Month' becomes 'January' and 'month' becomes 'january'
+func generate_applyCase(mod *ir.Module) {
+	tolower := getFuncByName("tolower", mod)
+
+	s := ir.NewParam("s", i8Ptr)
+	n := ir.NewParam("n", types.I64)
+	element := ir.NewParam("element", i8Ptr)
+	f := mod.NewFunc(applyCaseFuncName, types.Void, s, n, element)
+	entry := f.NewBlock("entry")
+	checkBlock := f.NewBlock("check")
+	lowerBlock := f.NewBlock("lower")
+	doneBlock := f.NewBlock("done")
+
+	isLower := func(c value.Value) value.Value {
+		return entry.NewAnd(entry.NewICmp(enum.IPredUGE, c, charConstant('a')), entry.NewICmp(enum.IPredULE, c, charConstant('z')))
+	}
+	isFirstLower := isLower(entry.NewLoad(element))
+	isSecondLower := isLower(entry.NewLoad(entry.NewGetElementPtr(element, llvmOneI64)))
+	from := entry.NewSelect(isFirstLower, llvmZeroI64, entry.NewSelect(isSecondLower, llvmOneI64, n))
+	idx := entry.NewAlloca(types.I64)
+	entry.NewStore(from, idx)
+	entry.NewBr(checkBlock)
+
+	i := checkBlock.NewLoad(idx)
+	checkBlock.NewCondBr(checkBlock.NewICmp(enum.IPredSLT, i, n), lowerBlock, doneBlock)
+
+	ptr := lowerBlock.NewGetElementPtr(s, i)
+	lower := lowerBlock.NewCall(tolower, lowerBlock.NewZExt(lowerBlock.NewLoad(ptr), types.I32))
+	lowerBlock.NewStore(lowerBlock.NewTrunc(lower, types.I8), ptr)
+	lowerBlock.NewStore(lowerBlock.NewAdd(i, llvmOneI64), idx)
+	lowerBlock.NewBr(checkBlock)
+
+	doneBlock.NewRet(nil)
+}
+
+// elementLength returns the number of characters of the element with index el
+func elementLength(mod *ir.Module, b *ir.Block, el value.Value) value.Value {
+	lengths := getGlobalByName("_runtime.date_format.lengths", mod)
+	return b.NewLoad(b.NewGetElementPtr(lengths, llvmZeroI64, el))
+}
+
+// numberElement is an element that is formatted as a number
+type numberElement struct {
+	// computes the value from the fields of the point in time
+	value func(b *ir.Block, dt value.Value) value.Value
+	// the number of digits, FM drops the leading zeros
+	width int64
+}
+
+// nameElement is an element that is formatted as a name
+type nameElement struct {
+	names string
+	// computes the index of the name from the fields of the point in time
+	index       func(b *ir.Block, dt value.Value) value.Value
+	abbreviated bool
+	// padded names are blank-padded to the length of the longest name
+	padded bool
+}
+
+func datetimeField(field uint64) func(b *ir.Block, dt value.Value) value.Value {
+	return func(b *ir.Block, dt value.Value) value.Value {
+		return b.NewExtractValue(dt, field)
+	}
+}
+
+// generateFormatDate formats a point in time according to a format model
+func generateFormatDate(mod *ir.Module) {
+	split := getFuncByName(splitDatetimeFuncName, mod)
+	element := getFuncByName(dateFormatElementFuncName, mod)
+	nameAt := getFuncByName(nameAtFuncName, mod)
+	applyCase := getFuncByName(applyCaseFuncName, mod)
+	powerOfTen := getFuncByName(powerOfTenIntFuncName, mod)
+	isalnum := getFuncByName("isalnum", mod)
+	snprintf := getFuncByName("snprintf", mod)
+	malloc := getFuncByName("malloc", mod)
+	free := getFuncByName("free", mod)
+	memcpy := getFuncByName("memcpy", mod)
+	memset := getFuncByName("memset", mod)
+	memchr := getFuncByName("memchr", mod)
+	allocStr := getFuncByName(AllocStringFuncName, mod)
+	numberFormat := newConstantCString(mod, "_runtime.format.date_number", "%0*lld")
+	notRecognized := sharedConstantString(mod, "_runtime.msg.date_format_not_recognized", DateFormatNotRecognizedMessage)
+
+	ts := ir.NewParam("ts", types.I64)
+	format := ir.NewParam("format", StringType)
+	f := mod.NewFunc(FormatDateFuncName, StringType, ts, format)
+	entry := f.NewBlock("entry")
+	checkBlock := f.NewBlock("check")
+	charBlock := f.NewBlock("char")
+	classifyBlock := f.NewBlock("classify")
+	copyBlock := f.NewBlock("copy")
+	quoteBlock := f.NewBlock("quote")
+	quoteEndBlock := f.NewBlock("quote-end")
+	elementBlock := f.NewBlock("element")
+	numberBlock := f.NewBlock("number")
+	nameBlock := f.NewBlock("name")
+	nextBlock := f.NewBlock("next")
+	doneBlock := f.NewBlock("done")
+	errorBlock := f.NewBlock("error")
+
+	dt := entry.NewCall(split, ts)
+	data := entry.NewExtractValue(format, 0)
+	len := entry.NewExtractValue(format, 1)
+	// no element results in more than three characters per character of the format model
+	buf := entry.NewCall(malloc, entry.NewAdd(entry.NewMul(len, i64Constant(4)), i64Constant(16)))
+	idx := entry.NewAlloca(types.I64)
+	entry.NewStore(llvmZeroI64, idx)
+	pos := entry.NewAlloca(types.I64)
+	entry.NewStore(llvmZeroI64, pos)
+	fillMode := entry.NewAlloca(types.I1)
+	entry.NewStore(constant.False, fillMode)
+	entry.NewBr(checkBlock)
+
+	i := checkBlock.NewLoad(idx)
+	checkBlock.NewCondBr(checkBlock.NewICmp(enum.IPredSLT, i, len), charBlock, doneBlock)
+
+	c := charBlock.NewLoad(charBlock.NewGetElementPtr(data, i))
+	charBlock.NewCondBr(charBlock.NewICmp(enum.IPredEQ, c, charConstant('"')), quoteBlock, classifyBlock)
+
+	isAlnum := classifyBlock.NewICmp(enum.IPredNE, classifyBlock.NewCall(isalnum, classifyBlock.NewZExt(c, types.I32)), llvmZeroI32)
+	classifyBlock.NewCondBr(isAlnum, elementBlock, copyBlock)
+
+	// everything that isn't part of an element is copied as is
+	p := copyBlock.NewLoad(pos)
+	copyBlock.NewStore(c, copyBlock.NewGetElementPtr(buf, p))
+	copyBlock.NewStore(copyBlock.NewAdd(p, llvmOneI64), pos)
+	copyBlock.NewStore(copyBlock.NewAdd(i, llvmOneI64), idx)
+	copyBlock.NewBr(checkBlock)
+
+	textStart := quoteBlock.NewGetElementPtr(data, quoteBlock.NewAdd(i, llvmOneI64))
+	textEnd := quoteBlock.NewCall(memchr, textStart, constant.NewInt(types.I32, '"'), quoteBlock.NewSub(len, quoteBlock.NewAdd(i, llvmOneI64)))
+	quoteBlock.NewCondBr(quoteBlock.NewICmp(enum.IPredEQ, textEnd, constant.NewNull(i8Ptr)), errorBlock, quoteEndBlock)
+
+	b := quoteEndBlock
+	textLen := b.NewSub(b.NewPtrToInt(textEnd, types.I64), b.NewPtrToInt(textStart, types.I64))
+	p = b.NewLoad(pos)
+	b.NewCall(memcpy, b.NewGetElementPtr(buf, p), textStart, textLen)
+	b.NewStore(b.NewAdd(p, textLen), pos)
+	b.NewStore(b.NewAdd(i, b.NewAdd(textLen, i64Constant(2))), idx)
+	b.NewBr(checkBlock)
+
+	el := elementBlock.NewCall(element, format, i)
+	cases := make([]*ir.Case, 0)
+	addCase := func(name string, target *ir.Block) {
+		cases = append(cases, ir.NewCase(i64Constant(int64(dateFormatElement(name))), target))
+	}
+
+	year := datetimeField(datetimeYear)
+	twoDigitYear := func(b *ir.Block, dt value.Value) value.Value {
+		return b.NewSRem(b.NewExtractValue(dt, datetimeYear), i64Constant(100))
+	}
+	hour12 := func(b *ir.Block, dt value.Value) value.Value {
+		h := b.NewAdd(b.NewExtractValue(dt, datetimeHour), i64Constant(11))
+		return b.NewAdd(b.NewSRem(h, i64Constant(12)), llvmOneI64)
+	}
+	numbers := map[string]numberElement{
+		"YYYY": {year, 4},
+		"RRRR": {year, 4},
+		"YY":   {twoDigitYear, 2},
+		"RR":   {twoDigitYear, 2},
+		"MM":   {datetimeField(datetimeMonth), 2},
+		"MI":   {datetimeField(datetimeMinute), 2},
+		"DDD":  {datetimeField(datetimeYearday), 3},
+		"DD":   {datetimeField(datetimeDay), 2},
+		"D": {func(b *ir.Block, dt value.Value) value.Value {
+			return b.NewAdd(b.NewExtractValue(dt, datetimeWeekday), llvmOneI64)
+		}, 1},
+		"HH24": {datetimeField(datetimeHour), 2},
+		"HH12": {hour12, 2},
+		"HH":   {hour12, 2},
+		"SS":   {datetimeField(datetimeSecond), 2},
+		"Q": {func(b *ir.Block, dt value.Value) value.Value {
+			return b.NewSDiv(b.NewAdd(b.NewExtractValue(dt, datetimeMonth), i64Constant(2)), i64Constant(3))
+		}, 1},
+	}
+	valueIncomings := make([]*ir.Incoming, 0)
+	widthIncomings := make([]*ir.Incoming, 0)
+	for _, name := range dateFormatElements {
+		ne, ok := numbers[name]
+		if !ok {
+			continue
+		}
+		b := f.NewBlock(name)
+		width := b.NewSelect(b.NewLoad(fillMode), llvmOneI64, i64Constant(ne.width))
+		valueIncomings = append(valueIncomings, ir.NewIncoming(ne.value(b, dt), b))
+		widthIncomings = append(widthIncomings, ir.NewIncoming(width, b))
+		b.NewBr(numberBlock)
+		addCase(name, b)
+	}
+
+	// fractions of a second always have the number of digits that is asked for
+	micro := elementBlock.NewExtractValue(dt, datetimeMicro)
+	for digits := int64(1); digits <= 9; digits++ {
+		name := fmt.Sprintf("FF%d", digits)
+		b := f.NewBlock(name)
+		var v value.Value
+		if digits <= 6 {
+			v = b.NewSDiv(micro, b.NewCall(powerOfTen, i64Constant(6-digits)))
+		} else {
+			v = b.NewMul(micro, b.NewCall(powerOfTen, i64Constant(digits-6)))
+		}
+		valueIncomings = append(valueIncomings, ir.NewIncoming(v, b))
+		widthIncomings = append(widthIncomings, ir.NewIncoming(i64Constant(digits), b))
+		b.NewBr(numberBlock)
+		addCase(name, b)
+		if digits == 6 {
+			addCase("FF", b)
+		}
+	}
+
+	b = numberBlock
+	v := b.NewPhi(valueIncomings...)
+	width := b.NewPhi(widthIncomings...)
+	p = b.NewLoad(pos)
+	n := b.NewCall(snprintf, b.NewGetElementPtr(buf, p), i64Constant(32), numberFormat, b.NewTrunc(width, types.I32), v)
+	b.NewStore(b.NewAdd(p, b.NewSExt(n, types.I64)), pos)
+	b.NewBr(nextBlock)
+
+	month := func(b *ir.Block, dt value.Value) value.Value {
+		return b.NewSub(b.NewExtractValue(dt, datetimeMonth), llvmOneI64)
+	}
+	meridian := func(b *ir.Block, dt value.Value) value.Value {
+		isPM := b.NewICmp(enum.IPredSGE, b.NewExtractValue(dt, datetimeHour), i64Constant(12))
+		return b.NewZExt(isPM, types.I64)
+	}
+	nameElements := map[string]nameElement{
+		"MONTH": {"_runtime.names.months", month, false, true},
+		"MON":   {"_runtime.names.months", month, true, false},
+		"DAY":   {"_runtime.names.days", datetimeField(datetimeWeekday), false, true},
+		"DY":    {"_runtime.names.days", datetimeField(datetimeWeekday), true, false},
+		"AM":    {"_runtime.names.meridians", meridian, false, false},
+		"PM":    {"_runtime.names.meridians", meridian, false, false},
+	}
+	namesIncomings := make([]*ir.Incoming, 0)
+	indexIncomings := make([]*ir.Incoming, 0)
+	abbreviatedIncomings := make([]*ir.Incoming, 0)
+	paddedIncomings := make([]*ir.Incoming, 0)
+	for _, name := range dateFormatElements {
+		ne, ok := nameElements[name]
+		if !ok {
+			continue
+		}
+		b := f.NewBlock(name)
+		namesIncomings = append(namesIncomings, ir.NewIncoming(names(mod, ne.names), b))
+		indexIncomings = append(indexIncomings, ir.NewIncoming(ne.index(b, dt), b))
+		abbreviatedIncomings = append(abbreviatedIncomings, ir.NewIncoming(constant.NewBool(ne.abbreviated), b))
+		paddedIncomings = append(paddedIncomings, ir.NewIncoming(constant.NewBool(ne.padded), b))
+		b.NewBr(nameBlock)
+		addCase(name, b)
+	}
+
+	b = nameBlock
+	namesPtr := b.NewPhi(namesIncomings...)
+	nameIdx := b.NewPhi(indexIncomings...)
+	abbreviated := b.NewPhi(abbreviatedIncomings...)
+	padded := b.NewPhi(paddedIncomings...)
+	name := b.NewCall(nameAt, namesPtr, nameIdx)
+	nameLen := b.NewSelect(abbreviated, i64Constant(3), b.NewExtractValue(name, 1))
+	paddedLen := b.NewSelect(b.NewAnd(padded, b.NewXor(b.NewLoad(fillMode), constant.True)), i64Constant(nameWidth), nameLen)
+	p = b.NewLoad(pos)
+	out := b.NewGetElementPtr(buf, p)
+	b.NewCall(memcpy, out, b.NewExtractValue(name, 0), nameLen)
+	b.NewCall(memset, b.NewGetElementPtr(out, nameLen), constant.NewInt(types.I32, ' '), b.NewSub(paddedLen, nameLen))
+	b.NewCall(applyCase, out, nameLen, b.NewGetElementPtr(data, i))
+	b.NewStore(b.NewAdd(p, paddedLen), pos)
+	b.NewBr(nextBlock)
+
+	// FM switches the fill mode on and off again
+	b = f.NewBlock("FM")
+	b.NewStore(b.NewXor(b.NewLoad(fillMode), constant.True), fillMode)
+	b.NewBr(nextBlock)
+	addCase("FM", b)
+
+	b = f.NewBlock("X")
+	p = b.NewLoad(pos)
+	b.NewStore(charConstant('.'), b.NewGetElementPtr(buf, p))
+	b.NewStore(b.NewAdd(p, llvmOneI64), pos)
+	b.NewBr(nextBlock)
+	addCase("X", b)
+
+	elementBlock.NewSwitch(el, errorBlock, cases...)
+
+	nextBlock.NewStore(nextBlock.NewAdd(i, elementLength(mod, nextBlock, el)), idx)
+	nextBlock.NewBr(checkBlock)
+
+	p = doneBlock.NewLoad(pos)
+	str := doneBlock.NewCall(allocStr, p)
+	doneBlock.NewCall(memcpy, str, buf, p)
+	doneBlock.NewCall(free, buf)
+	doneBlock.NewRet(newString(doneBlock, str, p))
+
+	Raise(mod, errorBlock, notRecognized)
+}
+
+// checkOrRaise raises an error unless isValid is true
+// it returns the block to continue in
+func checkOrRaise(mod *ir.Module, b *ir.Block, isValid value.Value, message constant.Constant) *ir.Block {
+	f := b.Parent
+	okBlock := f.NewBlock("")
+	errorBlock := f.NewBlock("")
+	b.NewCondBr(isValid, okBlock, errorBlock)
+	Raise(mod, errorBlock, message)
+	return okBlock
+}
+
+func isBetween(b *ir.Block, v value.Value, min int64, max value.Value) value.Value {
+	return b.NewAnd(b.NewICmp(enum.IPredSGE, v, i64Constant(min)), b.NewICmp(enum.IPredSLE, v, max))
+}
+
+// generateToTimestamp converts a string into a point in time according to a format model
+// separators in the format model match any separator in the string
+// the year and month default to the current ones, the day to the first of the month
+func generateToTimestamp(mod *ir.Module) {
+	split := getFuncByName(splitDatetimeFuncName, mod)
+	join := getFuncByName(joinDatetimeFuncName, mod)
+	daysFromCivil := getFuncByName(daysFromCivilFuncName, mod)
+	daysInMonth := getFuncByName(daysInMonthFuncName, mod)
+	sysdate := getFuncByName(SysdateFuncName, mod)
+	element := getFuncByName(dateFormatElementFuncName, mod)
+	readName := getFuncByName(readNameFuncName, mod)
+	readDigits := getFuncByName(readDigitsFuncName, mod)
+	powerOfTen := getFuncByName(powerOfTenIntFuncName, mod)
+	isalnum := getFuncByName("isalnum", mod)
+	memchr := getFuncByName("memchr", mod)
+	notRecognized := sharedConstantString(mod, "_runtime.msg.date_format_not_recognized", DateFormatNotRecognizedMessage)
+	message := func(name string, s string) constant.Constant {
+		return NewConstantString(mod, "_runtime.msg."+name, s)
+	}
+
+	s := ir.NewParam("s", StringType)
+	format := ir.NewParam("format", StringType)
+	f := mod.NewFunc(ToTimestampFuncName, types.I64, s, format)
+	entry := f.NewBlock("entry")
+	checkBlock := f.NewBlock("check")
+	charBlock := f.NewBlock("char")
+	classifyBlock := f.NewBlock("classify")
+	separatorBlock := f.NewBlock("separator")
+	separatorCharBlock := f.NewBlock("separator-char")
+	skipSeparatorBlock := f.NewBlock("skip-separator")
+	separatorDoneBlock := f.NewBlock("separator-done")
+	quoteBlock := f.NewBlock("quote")
+	quoteEndBlock := f.NewBlock("quote-end")
+	elementBlock := f.NewBlock("element")
+	digitsBlock := f.NewBlock("digits")
+	noDigitsBlock := f.NewBlock("no-digits")
+	storeBlock := f.NewBlock("store")
+	nextBlock := f.NewBlock("next")
+	finishBlock := f.NewBlock("finish")
+	trailingBlock := f.NewBlock("trailing")
+	skipTrailingBlock := f.NewBlock("skip-trailing")
+	validateBlock := f.NewBlock("validate")
+	errorBlock := f.NewBlock("error")
+
+	now := entry.NewCall(split, entry.NewCall(sysdate))
+	nowYear := entry.NewExtractValue(now, datetimeYear)
+	data := entry.NewExtractValue(format, 0)
+	len := entry.NewExtractValue(format, 1)
+	sData := entry.NewExtractValue(s, 0)
+	sLen := entry.NewExtractValue(s, 1)
+	newVar := func(init value.Value) *ir.InstAlloca {
+		v := entry.NewAlloca(init.Type())
+		entry.NewStore(init, v)
+		return v
+	}
+	idx := newVar(llvmZeroI64)
+	ip := newVar(llvmZeroI64)
+	year := newVar(nowYear)
+	month := newVar(entry.NewExtractValue(now, datetimeMonth))
+	day := newVar(llvmOneI64)
+	hour := newVar(llvmZeroI64)
+	minute := newVar(llvmZeroI64)
+	second := newVar(llvmZeroI64)
+	micro := newVar(llvmZeroI64)
+	yearday := newVar(llvmZeroI64)
+	isPM := newVar(constant.False)
+	is12 := newVar(constant.False)
+	entry.NewBr(checkBlock)
+
+	i := checkBlock.NewLoad(idx)
+	checkBlock.NewCondBr(checkBlock.NewICmp(enum.IPredSLT, i, len), charBlock, finishBlock)
+
+	c := charBlock.NewLoad(charBlock.NewGetElementPtr(data, i))
+	charBlock.NewCondBr(charBlock.NewICmp(enum.IPredEQ, c, charConstant('"')), quoteBlock, classifyBlock)
+
+	isAlnum := classifyBlock.NewICmp(enum.IPredNE, classifyBlock.NewCall(isalnum, classifyBlock.NewZExt(c, types.I32)), llvmZeroI32)
+	hasInputBlock := f.NewBlock("has-input")
+	classifyBlock.NewCondBr(isAlnum, hasInputBlock, separatorBlock)
+
+	// the rest of the format model is ignored once the whole string has been converted
+	hasInputBlock.NewCondBr(hasInputBlock.NewICmp(enum.IPredSLT, hasInputBlock.NewLoad(ip), sLen), elementBlock, finishBlock)
+
+	// a separator in the format model skips a separator in the string if there is one
+	p := separatorBlock.NewLoad(ip)
+	separatorBlock.NewCondBr(separatorBlock.NewICmp(enum.IPredSLT, p, sLen), separatorCharBlock, separatorDoneBlock)
+	sc := separatorCharBlock.NewLoad(separatorCharBlock.NewGetElementPtr(sData, p))
+	isSeparator := separatorCharBlock.NewICmp(enum.IPredEQ, separatorCharBlock.NewCall(isalnum, separatorCharBlock.NewZExt(sc, types.I32)), llvmZeroI32)
+	separatorCharBlock.NewCondBr(isSeparator, skipSeparatorBlock, separatorDoneBlock)
+	skipSeparatorBlock.NewStore(skipSeparatorBlock.NewAdd(p, llvmOneI64), ip)
+	skipSeparatorBlock.NewBr(separatorDoneBlock)
+	separatorDoneBlock.NewStore(separatorDoneBlock.NewAdd(i, llvmOneI64), idx)
+	separatorDoneBlock.NewBr(checkBlock)
+
+	// text in double quotes is skipped in the string
+	textStart := quoteBlock.NewGetElementPtr(data, quoteBlock.NewAdd(i, llvmOneI64))
+	textEnd := quoteBlock.NewCall(memchr, textStart, constant.NewInt(types.I32, '"'), quoteBlock.NewSub(len, quoteBlock.NewAdd(i, llvmOneI64)))
+	quoteBlock.NewCondBr(quoteBlock.NewICmp(enum.IPredEQ, textEnd, constant.NewNull(i8Ptr)), errorBlock, quoteEndBlock)
+
+	b := quoteEndBlock
+	textLen := b.NewSub(b.NewPtrToInt(textEnd, types.I64), b.NewPtrToInt(textStart, types.I64))
+	b.NewStore(b.NewAdd(b.NewLoad(ip), textLen), ip)
+	b.NewStore(b.NewAdd(i, b.NewAdd(textLen, i64Constant(2))), idx)
+	b.NewBr(checkBlock)
+
+	el := elementBlock.NewCall(element, format, i)
+	cases := make([]*ir.Case, 0)
+	storeCases := make([]*ir.Case, 0)
+	maxIncomings := make([]*ir.Incoming, 0)
+
+	// numeric elements read their digits first and store them second
+	maxDigits := map[string]int64{
+		"YYYY": 4, "RRRR": 4, "YY": 2, "RR": 4, "MM": 2, "MI": 2, "DDD": 3, "DD": 2, "D": 1,
+		"HH24": 2, "HH12": 2, "HH": 2, "SS": 2, "Q": 1,
+		"FF1": 1, "FF2": 2, "FF3": 3, "FF4": 4, "FF5": 5, "FF6": 6, "FF7": 7, "FF8": 8, "FF9": 9, "FF": 9,
+	}
+	for _, name := range dateFormatElements {
+		max, ok := maxDigits[name]
+		if !ok {
+			continue
+		}
+		b := f.NewBlock(name)
+		b.NewBr(digitsBlock)
+		maxIncomings = append(maxIncomings, ir.NewIncoming(i64Constant(max), b))
+		cases = append(cases, ir.NewCase(i64Constant(int64(dateFormatElement(name))), b))
+	}
+
+	b = digitsBlock
+	max := b.NewPhi(maxIncomings...)
+	start := b.NewLoad(ip)
+	v := b.NewCall(readDigits, s, ip, max)
+	count := b.NewSub(b.NewLoad(ip), start)
+	b.NewCondBr(b.NewICmp(enum.IPredSLT, v, llvmZeroI64), noDigitsBlock, storeBlock)
+
+	isAtEnd := noDigitsBlock.NewICmp(enum.IPredSGE, start, sLen)
+	tooShortBlock := f.NewBlock("too-short")
+	nonNumericBlock := f.NewBlock("non-numeric")
+	noDigitsBlock.NewCondBr(isAtEnd, tooShortBlock, nonNumericBlock)
+	Raise(mod, tooShortBlock, message("input_too_short", InputTooShortMessage))
+	Raise(mod, nonNumericBlock, message("non_numeric_character", NonNumericCharacterMessage))
+
+	// two digit years of RR are in the century that is closest to the current year
+	century := storeBlock.NewSub(nowYear, storeBlock.NewSRem(nowYear, i64Constant(100)))
+	isEarlyInCentury := storeBlock.NewICmp(enum.IPredSLT, storeBlock.NewSRem(nowYear, i64Constant(100)), i64Constant(50))
+	isEarlyYear := storeBlock.NewICmp(enum.IPredSLT, v, i64Constant(50))
+	rrCentury := storeBlock.NewSelect(isEarlyYear,
+		storeBlock.NewSelect(isEarlyInCentury, century, storeBlock.NewAdd(century, i64Constant(100))),
+		storeBlock.NewSelect(isEarlyInCentury, storeBlock.NewSub(century, i64Constant(100)), century))
+	rr := storeBlock.NewAdd(rrCentury, v)
+	isShort := storeBlock.NewICmp(enum.IPredSLE, count, i64Constant(2))
+	// fractions of a second are scaled to microseconds depending on the number of digits
+	fractionScale := storeBlock.NewCall(powerOfTen, storeBlock.NewSub(i64Constant(6), storeBlock.NewSelect(storeBlock.NewICmp(enum.IPredSLE, count, i64Constant(6)), count, i64Constant(6))))
+	fractionDivisor := storeBlock.NewCall(powerOfTen, storeBlock.NewSub(storeBlock.NewSelect(storeBlock.NewICmp(enum.IPredSGE, count, i64Constant(6)), count, i64Constant(6)), i64Constant(6)))
+	fraction := storeBlock.NewSDiv(storeBlock.NewMul(v, fractionScale), fractionDivisor)
+
+	stores := []struct {
+		elements []string
+		target   *ir.InstAlloca
+		value    value.Value
+	}{
+		{[]string{"YYYY"}, year, v},
+		{[]string{"RRRR", "RR"}, year, storeBlock.NewSelect(isShort, rr, v)},
+		{[]string{"YY"}, year, storeBlock.NewAdd(century, v)},
+		{[]string{"MM"}, month, v},
+		{[]string{"MI"}, minute, v},
+		{[]string{"DDD"}, yearday, v},
+		{[]string{"DD"}, day, v},
+		{[]string{"HH24"}, hour, v},
+		{[]string{"HH12", "HH"}, hour, v},
+		{[]string{"SS"}, second, v},
+		{[]string{"FF1", "FF2", "FF3", "FF4", "FF5", "FF6", "FF7", "FF8", "FF9", "FF"}, micro, fraction},
+	}
+	for _, st := range stores {
+		b := f.NewBlock("store-" + st.elements[0])
+		b.NewStore(st.value, st.target)
+		if st.elements[0] == "HH12" {
+			b.NewStore(constant.True, is12)
+		}
+		b.NewBr(nextBlock)
+		for _, name := range st.elements {
+			storeCases = append(storeCases, ir.NewCase(i64Constant(int64(dateFormatElement(name))), b))
+		}
+	}
+	// the day of the week and the quarter are read but don't change the date
+	storeBlock.NewSwitch(el, nextBlock, storeCases...)
+
+	// names
+	readNames := []struct {
+		elements []string
+		names    string
+		count    int64
+		message  constant.Constant
+		store    func(b *ir.Block, idx value.Value)
+	}{
+		{[]string{"MONTH", "MON"}, "_runtime.names.months", 12, message("not_a_valid_month", NotAValidMonthMessage), func(b *ir.Block, idx value.Value) {
+			b.NewStore(b.NewAdd(idx, llvmOneI64), month)
+		}},
+		{[]string{"DAY", "DY"}, "_runtime.names.days", 7, message("not_a_valid_day", NotAValidDayMessage), func(b *ir.Block, idx value.Value) {}},
+		{[]string{"AM", "PM"}, "_runtime.names.meridians", 2, message("meridian_required", MeridianRequiredMessage), func(b *ir.Block, idx value.Value) {
+			b.NewStore(b.NewICmp(enum.IPredEQ, idx, llvmOneI64), isPM)
+		}},
+	}
+	for _, rn := range readNames {
+		readBlock := f.NewBlock("read-" + rn.elements[0])
+		b := readBlock
+		nameIdx := b.NewCall(readName, s, ip, names(mod, rn.names), i64Constant(rn.count))
+		b = checkOrRaise(mod, b, b.NewICmp(enum.IPredSGE, nameIdx, llvmZeroI64), rn.message)
+		rn.store(b, nameIdx)
+		b.NewBr(nextBlock)
+		for _, name := range rn.elements {
+			cases = append(cases, ir.NewCase(i64Constant(int64(dateFormatElement(name))), readBlock))
+		}
+	}
+
+	// FM doesn't change anything and X is the decimal point
+	cases = append(cases, ir.NewCase(i64Constant(int64(dateFormatElement("FM"))), nextBlock))
+	cases = append(cases, ir.NewCase(i64Constant(int64(dateFormatElement("X"))), separatorBlock))
+	elementBlock.NewSwitch(el, errorBlock, cases...)
+
+	nextBlock.NewStore(nextBlock.NewAdd(i, elementLength(mod, nextBlock, el)), idx)
+	nextBlock.NewBr(checkBlock)
+
+	// only blanks can follow what the format model describes
+	finishBlock.NewBr(trailingBlock)
+	p = trailingBlock.NewLoad(ip)
+	hasMore := trailingBlock.NewICmp(enum.IPredSLT, p, sLen)
+	trailingCharBlock := f.NewBlock("trailing-char")
+	trailingBlock.NewCondBr(hasMore, trailingCharBlock, validateBlock)
+	isBlank := trailingCharBlock.NewICmp(enum.IPredEQ, trailingCharBlock.NewLoad(trailingCharBlock.NewGetElementPtr(sData, p)), charConstant(' '))
+	notConvertedBlock := f.NewBlock("not-converted")
+	trailingCharBlock.NewCondBr(isBlank, skipTrailingBlock, notConvertedBlock)
+	skipTrailingBlock.NewStore(skipTrailingBlock.NewAdd(p, llvmOneI64), ip)
+	skipTrailingBlock.NewBr(trailingBlock)
+	Raise(mod, notConvertedBlock, message("format_ends_before_input", FormatEndsBeforeInputMessage))
+
+	b = validateBlock
+	y := b.NewLoad(year)
+	m := b.NewLoad(month)
+	h := b.NewLoad(hour)
+	twelve := b.NewLoad(is12)
+	isValidHour12 := b.NewAnd(twelve, isBetween(b, h, 1, i64Constant(12)))
+	b = checkOrRaise(mod, b, b.NewOr(isValidHour12, b.NewXor(twelve, constant.True)), message("hour_12", Hour12Message))
+	b = checkOrRaise(mod, b, isBetween(b, h, 0, i64Constant(23)), message("hour_24", Hour24Message))
+	h12 := b.NewAdd(b.NewSRem(h, i64Constant(12)), b.NewSelect(b.NewLoad(isPM), i64Constant(12), llvmZeroI64))
+	h24 := b.NewSelect(twelve, h12, h)
+	b = checkOrRaise(mod, b, isBetween(b, m, 1, i64Constant(12)), message("not_a_valid_month_number", NotAValidMonthMessage))
+	mi := b.NewLoad(minute)
+	b = checkOrRaise(mod, b, isBetween(b, mi, 0, i64Constant(59)), message("minutes", MinutesMessage))
+	sec := b.NewLoad(second)
+	b = checkOrRaise(mod, b, isBetween(b, sec, 0, i64Constant(59)), message("seconds", SecondsMessage))
+	yd := b.NewLoad(yearday)
+	ydBlock := f.NewBlock("yearday")
+	dayBlock := f.NewBlock("day")
+	b.NewCondBr(b.NewICmp(enum.IPredNE, yd, llvmZeroI64), ydBlock, dayBlock)
+
+	// the day of the year replaces month and day
+	b = ydBlock
+	daysInYear := b.NewSub(b.NewCall(daysFromCivil, b.NewAdd(y, llvmOneI64), llvmOneI64, llvmOneI64), b.NewCall(daysFromCivil, y, llvmOneI64, llvmOneI64))
+	b = checkOrRaise(mod, b, isBetween(b, yd, 1, daysInYear), message("day_of_year", DayOfYearMessage))
+	startOfYear := b.NewCall(join, y, llvmOneI64, llvmOneI64, h24, mi, sec, b.NewLoad(micro))
+	b.NewRet(b.NewAdd(startOfYear, b.NewMul(b.NewSub(yd, llvmOneI64), i64Constant(MicrosPerDay))))
+
+	b = dayBlock
+	d := b.NewLoad(day)
+	b = checkOrRaise(mod, b, isBetween(b, d, 1, b.NewCall(daysInMonth, y, m)), message("day_of_month", DayOfMonthMessage))
+	b.NewRet(b.NewCall(join, y, m, d, h24, mi, sec, b.NewLoad(micro)))
+
+	Raise(mod, errorBlock, notRecognized)
+}
+
+// generateDefaultDateFormats creates TO_DATE and the implicit conversions
+// between dates, timestamps and strings which use the default formats
+func generateDefaultDateFormats(mod *ir.Module) {
+	formatDate := getFuncByName(FormatDateFuncName, mod)
+	toTimestamp := getFuncByName(ToTimestampFuncName, mod)
+	toDate := getFuncByName(TimestampToDateFuncName, mod)
+	dateFormat := NewConstantString(mod, "_runtime.format.default_date", DefaultDateFormat)
+	timestampFormat := NewConstantString(mod, "_runtime.format.default_timestamp", DefaultTimestampFormat)
+
+	s := ir.NewParam("s", StringType)
+	format := ir.NewParam("format", StringType)
+	f := mod.NewFunc(ToDateFuncName, types.I64, s, format)
+	b := f.NewBlock("entry")
+	b.NewRet(b.NewCall(toDate, b.NewCall(toTimestamp, s, format)))
+
+	ts := ir.NewParam("ts", types.I64)
+	f = mod.NewFunc(DateToStringFuncName, StringType, ts)
+	b = f.NewBlock("entry")
+	b.NewRet(b.NewCall(formatDate, ts, dateFormat))
+
+	ts = ir.NewParam("ts", types.I64)
+	f = mod.NewFunc(TimestampToStringFuncName, StringType, ts)
+	b = f.NewBlock("entry")
+	b.NewRet(b.NewCall(formatDate, ts, timestampFormat))
+
+	s = ir.NewParam("s", StringType)
+	f = mod.NewFunc(StringToDateFuncName, types.I64, s)
+	b = f.NewBlock("entry")
+	b.NewRet(b.NewCall(getFuncByName(ToDateFuncName, mod), s, dateFormat))
+
+	s = ir.NewParam("s", StringType)
+	f = mod.NewFunc(StringToTimestampFuncName, types.I64, s)
+	b = f.NewBlock("entry")
+	b.NewRet(b.NewCall(toTimestamp, s, timestampFormat))
+}
@@ -0,0 +1,652 @@
+/*
+ * Copyright 2019 Marco Helmich
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package runtime
+
+import (
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// DATE and TIMESTAMP values are the number of microseconds since 1970-01-01 00:00:00 local time
+// DATE values never have fractions of a second
+// INTERVAL DAY TO SECOND values are a number of microseconds
+// INTERVAL YEAR TO MONTH values are a number of months
+const (
+	SysdateFuncName            = "_runtime.sysdate"
+	SystimestampFuncName       = "_runtime.systimestamp"
+	AddMonthsFuncName          = "_runtime.addMonths"
+	LastDayFuncName            = "_runtime.lastDay"
+	MonthsBetweenFuncName      = "_runtime.monthsBetween"
+	TruncDateFuncName          = "_runtime.truncDate"
+	ExtractDatetimeFuncName    = "_runtime.extractDatetime"
+	ExtractDayToSecondFuncName = "_runtime.extractDayToSecond"
+	ExtractYearToMonthFuncName = "_runtime.extractYearToMonth"
+	NumToDSIntervalFuncName    = "_runtime.numToDSInterval"
+	NumToYMIntervalFuncName    = "_runtime.numToYMInterval"
+
+	AddDaysFuncName            = "_runtime._addDays"
+	DaysBetweenFuncName        = "_runtime._daysBetween"
+	AddIntervalMonthsFuncName  = "_runtime._addIntervalMonths"
+	TimestampToDateFuncName    = "_runtime._timestampToDate"
+	DSIntervalToStringFuncName = "_runtime._dsIntervalToStr"
+	YMIntervalToStringFuncName = "_runtime._ymIntervalToStr"
+	StringToDSIntervalFuncName = "_runtime._strToDSInterval"
+	StringToYMIntervalFuncName = "_runtime._strToYMInterval"
+
+	datetimeTypeName      = "_runtime._datetime"
+	splitDatetimeFuncName = "_runtime._splitDatetime"
+	joinDatetimeFuncName  = "_runtime._joinDatetime"
+	daysFromCivilFuncName = "_runtime._daysFromCivil"
+	daysInMonthFuncName   = "_runtime._daysInMonth"
+
+	MicrosPerSecond = 1000000
+	MicrosPerMinute = 60 * MicrosPerSecond
+	MicrosPerHour   = 60 * MicrosPerMinute
+	MicrosPerDay    = 24 * MicrosPerHour
+
+	DateNotValidForMonthMessage = "ORA-01839: date not valid for month specified"
+	InvalidExtractFieldMessage  = "ORA-30076: invalid extract field for extract source"
+	IllegalArgumentMessage      = "ORA-01760: illegal argument for function"
+	InvalidIntervalMessage      = "ORA-01867: the interval is invalid"
+)
+
+// the fields of a '_runtime._datetime'
+const (
+	datetimeYear = iota
+	datetimeMonth
+	datetimeDay
+	datetimeHour
+	datetimeMinute
+	datetimeSecond
+	datetimeMicro
+	// 0 is Sunday
+	datetimeWeekday
+	// 1 is January 1st
+	datetimeYearday
+)
+
+var datetimeType types.Type
+
+func generateDates(mod *ir.Module) {
+	t := types.NewStruct(types.I64, types.I64, types.I64, types.I64, types.I64, types.I64, types.I64, types.I64, types.I64)
+	t.SetName(datetimeTypeName)
+	datetimeType = mod.NewTypeDef(datetimeTypeName, t)
+
+	generate_daysFromCivil(mod)
+	generate_daysInMonth(mod)
+	generate_splitDatetime(mod)
+	generate_joinDatetime(mod)
+	generate_timestampToDate(mod)
+	generateSystimestamp(mod)
+	generateSysdate(mod)
+	generate_addDays(mod)
+	generate_daysBetween(mod)
+	generateAddMonths(mod)
+	generate_addIntervalMonths(mod)
+	generateLastDay(mod)
+	generateMonthsBetween(mod)
+	generateTruncDate(mod)
+	generateExtracts(mod)
+	generateNumToIntervals(mod)
+	generateIntervalConversions(mod)
+}
+
+func i64Constant(n int64) constant.Constant {
+	return constant.NewInt(types.I64, n)
+}
+
+// floorDiv divides x by the positive constant y rounding towards negative infinity
+// so that points in time before 1970 end up in the right day
+func floorDiv(b *ir.Block, x value.Value, y int64) value.Value {
+	q := b.NewSDiv(x, i64Constant(y))
+	isNegative := b.NewICmp(enum.IPredSLT, b.NewSRem(x, i64Constant(y)), llvmZeroI64)
+	return b.NewSub(q, b.NewZExt(isNegative, types.I64))
+}
+
+// generate_daysFromCivil returns the number of days between 1970-01-01 and a date of the gregorian calendar
+// the algorithm is http://howardhinnant.github.io/date_algorithms.html#days_from_civil
+func generate_daysFromCivil(mod *ir.Module) {
+	y := ir.NewParam("y", types.I64)
+	m := ir.NewParam("m", types.I64)
+	d := ir.NewParam("d", types.I64)
+	f := mod.NewFunc(daysFromCivilFuncName, types.I64, y, m, d)
+	b := f.NewBlock("entry")
+
+	// years start in March so that the leap day is the last day of the year
+	isEarly := b.NewICmp(enum.IPredSLE, m, i64Constant(2))
+	year := b.NewSub(y, b.NewZExt(isEarly, types.I64))
+	era := floorDiv(b, year, 400)
+	yoe := b.NewSub(year, b.NewMul(era, i64Constant(400)))
+	mp := b.NewSRem(b.NewAdd(m, i64Constant(9)), i64Constant(12))
+	doy := b.NewAdd(b.NewSDiv(b.NewAdd(b.NewMul(mp, i64Constant(153)), i64Constant(2)), i64Constant(5)), b.NewSub(d, llvmOneI64))
+	doe := b.NewAdd(b.NewMul(yoe, i64Constant(365)), b.NewSDiv(yoe, i64Constant(4)))
+	doe = b.NewAdd(b.NewSub(doe, b.NewSDiv(yoe, i64Constant(100))), doy)
+	b.NewRet(b.NewSub(b.NewAdd(b.NewMul(era, i64Constant(146097)), doe), i64Constant(719468)))
+}
+
+func generate_daysInMonth(mod *ir.Module) {
+	daysFromCivil := getFuncByName(daysFromCivilFuncName, mod)
+
+	y := ir.NewParam("y", types.I64)
+	m := ir.NewParam("m", types.I64)
+	f := mod.NewFunc(daysInMonthFuncName, types.I64, y, m)
+	b := f.NewBlock("entry")
+
+	isDecember := b.NewICmp(enum.IPredEQ, m, i64Constant(12))
+	nextYear := b.NewAdd(y, b.NewZExt(isDecember, types.I64))
+	nextMonth := b.NewSelect(isDecember, llvmOneI64, b.NewAdd(m, llvmOneI64))
+	next := b.NewCall(daysFromCivil, nextYear, nextMonth, llvmOneI64)
+	b.NewRet(b.NewSub(next, b.NewCall(daysFromCivil, y, m, llvmOneI64)))
+}
+
+// generate_splitDatetime breaks a point in time up into its fields
+// the algorithm is http://howardhinnant.github.io/date_algorithms.html#civil_from_days
+func generate_splitDatetime(mod *ir.Module) {
+	daysFromCivil := getFuncByName(daysFromCivilFuncName, mod)
+
+	ts := ir.NewParam("ts", types.I64)
+	f := mod.NewFunc(splitDatetimeFuncName, datetimeType, ts)
+	b := f.NewBlock("entry")
+
+	days := floorDiv(b, ts, MicrosPerDay)
+	tod := b.NewSub(ts, b.NewMul(days, i64Constant(MicrosPerDay)))
+
+	z := b.NewAdd(days, i64Constant(719468))
+	era := floorDiv(b, z, 146097)
+	doe := b.NewSub(z, b.NewMul(era, i64Constant(146097)))
+	var yoe value.Value = b.NewSub(doe, b.NewSDiv(doe, i64Constant(1460)))
+	yoe = b.NewAdd(yoe, b.NewSDiv(doe, i64Constant(36524)))
+	yoe = b.NewSDiv(b.NewSub(yoe, b.NewSDiv(doe, i64Constant(146096))), i64Constant(365))
+	doyBase := b.NewAdd(b.NewMul(yoe, i64Constant(365)), b.NewSDiv(yoe, i64Constant(4)))
+	doy := b.NewSub(doe, b.NewSub(doyBase, b.NewSDiv(yoe, i64Constant(100))))
+	mp := b.NewSDiv(b.NewAdd(b.NewMul(doy, i64Constant(5)), i64Constant(2)), i64Constant(153))
+	day := b.NewAdd(b.NewSub(doy, b.NewSDiv(b.NewAdd(b.NewMul(mp, i64Constant(153)), i64Constant(2)), i64Constant(5))), llvmOneI64)
+	isEarly := b.NewICmp(enum.IPredSGE, mp, i64Constant(10))
+	month := b.NewSelect(isEarly, b.NewSub(mp, i64Constant(9)), b.NewAdd(mp, i64Constant(3)))
+	year := b.NewAdd(b.NewAdd(yoe, b.NewMul(era, i64Constant(400))), b.NewZExt(isEarly, types.I64))
+
+	// 1970-01-01 was a Thursday
+	weekday := b.NewSub(b.NewAdd(days, i64Constant(4)), b.NewMul(floorDiv(b, b.NewAdd(days, i64Constant(4)), 7), i64Constant(7)))
+	yearday := b.NewAdd(b.NewSub(days, b.NewCall(daysFromCivil, year, llvmOneI64, llvmOneI64)), llvmOneI64)
+
+	fields := []value.Value{
+		year,
+		month,
+		day,
+		b.NewSDiv(tod, i64Constant(MicrosPerHour)),
+		b.NewSDiv(b.NewSRem(tod, i64Constant(MicrosPerHour)), i64Constant(MicrosPerMinute)),
+		b.NewSDiv(b.NewSRem(tod, i64Constant(MicrosPerMinute)), i64Constant(MicrosPerSecond)),
+		b.NewSRem(tod, i64Constant(MicrosPerSecond)),
+		weekday,
+		yearday,
+	}
+	var dt value.Value = constant.NewUndef(datetimeType)
+	for idx := range fields {
+		dt = b.NewInsertValue(dt, fields[idx], uint64(idx))
+	}
+	b.NewRet(dt)
+}
+
+// generate_joinDatetime is the reverse of _splitDatetime
+// the fields aren't checked, they need to be valid
+func generate_joinDatetime(mod *ir.Module) {
+	daysFromCivil := getFuncByName(daysFromCivilFuncName, mod)
+
+	y := ir.NewParam("y", types.I64)
+	m := ir.NewParam("m", types.I64)
+	d := ir.NewParam("d", types.I64)
+	hour := ir.NewParam("hour", types.I64)
+	minute := ir.NewParam("minute", types.I64)
+	second := ir.NewParam("second", types.I64)
+	micro := ir.NewParam("micro", types.I64)
+	f := mod.NewFunc(joinDatetimeFuncName, types.I64, y, m, d, hour, minute, second, micro)
+	b := f.NewBlock("entry")
+
+	var ts value.Value = b.NewMul(b.NewCall(daysFromCivil, y, m, d), i64Constant(MicrosPerDay))
+	ts = b.NewAdd(ts, b.NewMul(hour, i64Constant(MicrosPerHour)))
+	ts = b.NewAdd(ts, b.NewMul(minute, i64Constant(MicrosPerMinute)))
+	ts = b.NewAdd(ts, b.NewMul(second, i64Constant(MicrosPerSecond)))
+	b.NewRet(b.NewAdd(ts, micro))
+}
+
+// generate_timestampToDate drops the fractions of a second
+func generate_timestampToDate(mod *ir.Module) {
+	ts := ir.NewParam("ts", types.I64)
+	f := mod.NewFunc(TimestampToDateFuncName, types.I64, ts)
+	b := f.NewBlock("entry")
+	b.NewRet(b.NewMul(floorDiv(b, ts, MicrosPerSecond), i64Constant(MicrosPerSecond)))
+}
+
+// generateSystimestamp returns the current local time
+// the offset to UTC is what timegm makes of the broken down local time
+func generateSystimestamp(mod *ir.Module) {
+	clockGettime := getFuncByName("clock_gettime", mod)
+	localtime := getFuncByName("localtime", mod)
+	timegm := getFuncByName("timegm", mod)
+
+	f := mod.NewFunc(SystimestampFuncName, types.I64)
+	b := f.NewBlock("entry")
+	timespec := b.NewAlloca(types.NewStruct(types.I64, types.I64))
+	// CLOCK_REALTIME
+	b.NewCall(clockGettime, llvmZeroI32, timespec)
+	secs := b.NewGetElementPtr(timespec, llvmZeroI32, llvmZeroI32)
+	nanos := b.NewLoad(b.NewGetElementPtr(timespec, llvmZeroI32, llvmOneI32))
+	local := b.NewCall(timegm, b.NewCall(localtime, secs))
+	b.NewRet(b.NewAdd(b.NewMul(local, i64Constant(MicrosPerSecond)), b.NewSDiv(nanos, i64Constant(1000))))
+}
+
+func generateSysdate(mod *ir.Module) {
+	f := mod.NewFunc(SysdateFuncName, types.I64)
+	b := f.NewBlock("entry")
+	now := b.NewCall(getFuncByName(SystimestampFuncName, mod))
+	b.NewRet(b.NewCall(getFuncByName(TimestampToDateFuncName, mod), now))
+}
+
+// generate_addDays adds a (fractional) number of days to a point in time
+// the result is a DATE, so it is rounded to the second
+func generate_addDays(mod *ir.Module) {
+	round := getFuncByName("round", mod)
+	toDate := getFuncByName(TimestampToDateFuncName, mod)
+
+	ts := ir.NewParam("ts", types.I64)
+	days := ir.NewParam("days", types.Double)
+	f := mod.NewFunc(AddDaysFuncName, types.I64, ts, days)
+	b := f.NewBlock("entry")
+	seconds := b.NewFPToSI(b.NewCall(round, b.NewFMul(days, constant.NewFloat(types.Double, 86400))), types.I64)
+	b.NewRet(b.NewAdd(b.NewCall(toDate, ts), b.NewMul(seconds, i64Constant(MicrosPerSecond))))
+}
+
+// generate_daysBetween returns the (fractional) number of days from ts2 to ts1
+func generate_daysBetween(mod *ir.Module) {
+	ts1 := ir.NewParam("ts1", types.I64)
+	ts2 := ir.NewParam("ts2", types.I64)
+	f := mod.NewFunc(DaysBetweenFuncName, types.Double, ts1, ts2)
+	b := f.NewBlock("entry")
+	diff := b.NewSIToFP(b.NewSub(ts1, ts2), types.Double)
+	b.NewRet(b.NewFDiv(diff, constant.NewFloat(types.Double, MicrosPerDay)))
+}
+
+// shiftMonths moves the date in dt by n months
+// it returns the new year and month along with the number of days in the old and the new month
+func shiftMonths(mod *ir.Module, b *ir.Block, dt value.Value, n value.Value) (year value.Value, month value.Value, oldDays value.Value, newDays value.Value) {
+	daysInMonth := getFuncByName(daysInMonthFuncName, mod)
+	y := b.NewExtractValue(dt, datetimeYear)
+	m := b.NewExtractValue(dt, datetimeMonth)
+	total := b.NewAdd(b.NewAdd(b.NewMul(y, i64Constant(12)), b.NewSub(m, llvmOneI64)), n)
+	year = floorDiv(b, total, 12)
+	month = b.NewAdd(b.NewSub(total, b.NewMul(year, i64Constant(12))), llvmOneI64)
+	oldDays = b.NewCall(daysInMonth, y, m)
+	newDays = b.NewCall(daysInMonth, year, month)
+	return year, month, oldDays, newDays
+}
+
+// joinShifted builds a point in time out of dt with the date replaced
+func joinShifted(mod *ir.Module, b *ir.Block, dt value.Value, year value.Value, month value.Value, day value.Value) value.Value {
+	return b.NewCall(getFuncByName(joinDatetimeFuncName, mod), year, month, day,
+		b.NewExtractValue(dt, datetimeHour),
+		b.NewExtractValue(dt, datetimeMinute),
+		b.NewExtractValue(dt, datetimeSecond),
+		b.NewExtractValue(dt, datetimeMicro),
+	)
+}
+
+// generateAddMonths adds n months to a date
+// days that don't exist in the resulting month and last days of a month become the last day of the resulting month
+func generateAddMonths(mod *ir.Module) {
+	split := getFuncByName(splitDatetimeFuncName, mod)
+
+	ts := ir.NewParam("ts", types.I64)
+	n := ir.NewParam("n", types.Double)
+	f := mod.NewFunc(AddMonthsFuncName, types.I64, ts, n)
+	b := f.NewBlock("entry")
+
+	dt := b.NewCall(split, ts)
+	year, month, oldDays, newDays := shiftMonths(mod, b, dt, b.NewFPToSI(n, types.I64))
+	d := b.NewExtractValue(dt, datetimeDay)
+	isLastDay := b.NewICmp(enum.IPredEQ, d, oldDays)
+	isPastEnd := b.NewICmp(enum.IPredSGT, d, newDays)
+	day := b.NewSelect(b.NewOr(isLastDay, isPastEnd), newDays, d)
+	b.NewRet(joinShifted(mod, b, dt, year, month, day))
+}
+
+// generate_addIntervalMonths adds an INTERVAL YEAR TO MONTH to a point in time
+// unlike ADD_MONTHS days that don't exist in the resulting month raise an error
+func generate_addIntervalMonths(mod *ir.Module) {
+	split := getFuncByName(splitDatetimeFuncName, mod)
+	notValid := NewConstantString(mod, "_runtime.msg.date_not_valid_for_month", DateNotValidForMonthMessage)
+
+	ts := ir.NewParam("ts", types.I64)
+	n := ir.NewParam("n", types.I64)
+	f := mod.NewFunc(AddIntervalMonthsFuncName, types.I64, ts, n)
+	entry := f.NewBlock("entry")
+	okBlock := f.NewBlock("ok")
+	errorBlock := f.NewBlock("error")
+
+	dt := entry.NewCall(split, ts)
+	year, month, _, newDays := shiftMonths(mod, entry, dt, n)
+	d := entry.NewExtractValue(dt, datetimeDay)
+	entry.NewCondBr(entry.NewICmp(enum.IPredSGT, d, newDays), errorBlock, okBlock)
+
+	okBlock.NewRet(joinShifted(mod, okBlock, dt, year, month, d))
+	Raise(mod, errorBlock, notValid)
+}
+
+func generateLastDay(mod *ir.Module) {
+	split := getFuncByName(splitDatetimeFuncName, mod)
+	daysInMonth := getFuncByName(daysInMonthFuncName, mod)
+
+	ts := ir.NewParam("ts", types.I64)
+	f := mod.NewFunc(LastDayFuncName, types.I64, ts)
+	b := f.NewBlock("entry")
+
+	dt := b.NewCall(split, ts)
+	days := b.NewCall(daysInMonth, b.NewExtractValue(dt, datetimeYear), b.NewExtractValue(dt, datetimeMonth))
+	daysLeft := b.NewSub(days, b.NewExtractValue(dt, datetimeDay))
+	b.NewRet(b.NewAdd(ts, b.NewMul(daysLeft, i64Constant(MicrosPerDay))))
+}
+
+// generateMonthsBetween returns the number of months from ts2 to ts1
+// the result is a whole number if both are on the same day of the month or on the last day of a month
+// otherwise the fraction is based on months of 31 days
+func generateMonthsBetween(mod *ir.Module) {
+	split := getFuncByName(splitDatetimeFuncName, mod)
+	daysInMonth := getFuncByName(daysInMonthFuncName, mod)
+
+	ts1 := ir.NewParam("ts1", types.I64)
+	ts2 := ir.NewParam("ts2", types.I64)
+	f := mod.NewFunc(MonthsBetweenFuncName, types.Double, ts1, ts2)
+	entry := f.NewBlock("entry")
+	wholeBlock := f.NewBlock("whole")
+	fractionBlock := f.NewBlock("fraction")
+
+	dt1 := entry.NewCall(split, ts1)
+	dt2 := entry.NewCall(split, ts2)
+	y1 := entry.NewExtractValue(dt1, datetimeYear)
+	m1 := entry.NewExtractValue(dt1, datetimeMonth)
+	d1 := entry.NewExtractValue(dt1, datetimeDay)
+	y2 := entry.NewExtractValue(dt2, datetimeYear)
+	m2 := entry.NewExtractValue(dt2, datetimeMonth)
+	d2 := entry.NewExtractValue(dt2, datetimeDay)
+	months := entry.NewAdd(entry.NewMul(entry.NewSub(y1, y2), i64Constant(12)), entry.NewSub(m1, m2))
+	wholeMonths := entry.NewSIToFP(months, types.Double)
+
+	isSameDay := entry.NewICmp(enum.IPredEQ, d1, d2)
+	isLast1 := entry.NewICmp(enum.IPredEQ, d1, entry.NewCall(daysInMonth, y1, m1))
+	isLast2 := entry.NewICmp(enum.IPredEQ, d2, entry.NewCall(daysInMonth, y2, m2))
+	entry.NewCondBr(entry.NewOr(isSameDay, entry.NewAnd(isLast1, isLast2)), wholeBlock, fractionBlock)
+
+	wholeBlock.NewRet(wholeMonths)
+
+	b := fractionBlock
+	// the days and the times of day that are left over
+	tod1 := b.NewSub(ts1, b.NewMul(floorDiv(b, ts1, MicrosPerDay), i64Constant(MicrosPerDay)))
+	tod2 := b.NewSub(ts2, b.NewMul(floorDiv(b, ts2, MicrosPerDay), i64Constant(MicrosPerDay)))
+	rest := b.NewAdd(b.NewMul(b.NewSub(d1, d2), i64Constant(MicrosPerDay)), b.NewSub(tod1, tod2))
+	fraction := b.NewFDiv(b.NewSIToFP(rest, types.Double), constant.NewFloat(types.Double, 31*MicrosPerDay))
+	b.NewRet(b.NewFAdd(wholeMonths, fraction))
+}
+
+// generateTruncDate returns the start of the day
+func generateTruncDate(mod *ir.Module) {
+	ts := ir.NewParam("ts", types.I64)
+	f := mod.NewFunc(TruncDateFuncName, types.I64, ts)
+	b := f.NewBlock("entry")
+	b.NewRet(b.NewMul(floorDiv(b, ts, MicrosPerDay), i64Constant(MicrosPerDay)))
+}
+
+// extractField is a field of EXTRACT and the block that computes it
+type extractField struct {
+	name  string
+	block *ir.Block
+}
+
+// switchOnField jumps to the block of the field that is named in field
+// the names of fields have been checked by the parser already
+// they can be told apart by their first character except for MONTH and MINUTE
+func switchOnField(f *ir.Func, b *ir.Block, field value.Value, fields []extractField, invalid *ir.Block) {
+	data := b.NewExtractValue(field, 0)
+	mBlock := f.NewBlock("m")
+
+	cases := []*ir.Case{ir.NewCase(charConstant('M'), mBlock)}
+	mCases := make([]*ir.Case, 0)
+	for _, ef := range fields {
+		if ef.name[0] == 'M' {
+			mCases = append(mCases, ir.NewCase(charConstant(ef.name[1]), ef.block))
+		} else {
+			cases = append(cases, ir.NewCase(charConstant(ef.name[0]), ef.block))
+		}
+	}
+
+	b.NewSwitch(b.NewLoad(data), invalid, cases...)
+	mBlock.NewSwitch(mBlock.NewLoad(mBlock.NewGetElementPtr(data, llvmOneI64)), invalid, mCases...)
+}
+
+func generateExtracts(mod *ir.Module) {
+	invalidField := NewConstantString(mod, "_runtime.msg.invalid_extract_field", InvalidExtractFieldMessage)
+	microsPerSecond := constant.NewFloat(types.Double, MicrosPerSecond)
+
+	// the fields of dates and timestamps
+	field := ir.NewParam("field", StringType)
+	ts := ir.NewParam("ts", types.I64)
+	f := mod.NewFunc(ExtractDatetimeFuncName, types.Double, field, ts)
+	entry := f.NewBlock("entry")
+	dt := entry.NewCall(getFuncByName(splitDatetimeFuncName, mod), ts)
+	fields := make([]extractField, 0)
+	for idx, name := range []string{"YEAR", "MONTH", "DAY", "HOUR", "MINUTE"} {
+		b := f.NewBlock(name)
+		b.NewRet(b.NewSIToFP(b.NewExtractValue(dt, uint64(idx)), types.Double))
+		fields = append(fields, extractField{name, b})
+	}
+	secondBlock := f.NewBlock("SECOND")
+	seconds := secondBlock.NewSIToFP(secondBlock.NewExtractValue(dt, datetimeSecond), types.Double)
+	micros := secondBlock.NewSIToFP(secondBlock.NewExtractValue(dt, datetimeMicro), types.Double)
+	secondBlock.NewRet(secondBlock.NewFAdd(seconds, secondBlock.NewFDiv(micros, microsPerSecond)))
+	fields = append(fields, extractField{"SECOND", secondBlock})
+	errorBlock := f.NewBlock("error")
+	switchOnField(f, entry, field, fields, errorBlock)
+	Raise(mod, errorBlock, invalidField)
+
+	// the fields of day to second intervals have the sign of the interval
+	field = ir.NewParam("field", StringType)
+	x := ir.NewParam("x", types.I64)
+	f = mod.NewFunc(ExtractDayToSecondFuncName, types.Double, field, x)
+	entry = f.NewBlock("entry")
+	fields = make([]extractField, 0)
+	units := []int64{MicrosPerDay, MicrosPerHour, MicrosPerMinute, MicrosPerSecond}
+	for idx, name := range []string{"DAY", "HOUR", "MINUTE", "SECOND"} {
+		b := f.NewBlock(name)
+		var rest value.Value = x
+		if idx > 0 {
+			rest = b.NewSRem(x, i64Constant(units[idx-1]))
+		}
+		if name == "SECOND" {
+			b.NewRet(b.NewFDiv(b.NewSIToFP(rest, types.Double), microsPerSecond))
+		} else {
+			b.NewRet(b.NewSIToFP(b.NewSDiv(rest, i64Constant(units[idx])), types.Double))
+		}
+		fields = append(fields, extractField{name, b})
+	}
+	errorBlock = f.NewBlock("error")
+	switchOnField(f, entry, field, fields, errorBlock)
+	Raise(mod, errorBlock, invalidField)
+
+	field = ir.NewParam("field", StringType)
+	x = ir.NewParam("x", types.I64)
+	f = mod.NewFunc(ExtractYearToMonthFuncName, types.Double, field, x)
+	entry = f.NewBlock("entry")
+	yearBlock := f.NewBlock("YEAR")
+	yearBlock.NewRet(yearBlock.NewSIToFP(yearBlock.NewSDiv(x, i64Constant(12)), types.Double))
+	monthBlock := f.NewBlock("MONTH")
+	monthBlock.NewRet(monthBlock.NewSIToFP(monthBlock.NewSRem(x, i64Constant(12)), types.Double))
+	errorBlock = f.NewBlock("error")
+	switchOnField(f, entry, field, []extractField{{"YEAR", yearBlock}, {"MONTH", monthBlock}}, errorBlock)
+	Raise(mod, errorBlock, invalidField)
+}
+
+// generateNumToIntervals creates NUMTODSINTERVAL and NUMTOYMINTERVAL
+// the unit is named by a string ('DAY', 'hour', ...)
+func generateNumToIntervals(mod *ir.Module) {
+	toupper := getFuncByName("toupper", mod)
+	round := getFuncByName("round", mod)
+	illegalArgument := NewConstantString(mod, "_runtime.msg.illegal_argument", IllegalArgumentMessage)
+
+	generate := func(name string, units map[byte]int64, order string) {
+		n := ir.NewParam("n", types.Double)
+		unit := ir.NewParam("unit", StringType)
+		f := mod.NewFunc(name, types.I64, n, unit)
+		entry := f.NewBlock("entry")
+		checkBlock := f.NewBlock("check")
+		convertBlock := f.NewBlock("convert")
+		errorBlock := f.NewBlock("error")
+
+		isEmpty := entry.NewICmp(enum.IPredEQ, entry.NewExtractValue(unit, 1), llvmZeroI64)
+		entry.NewCondBr(isEmpty, errorBlock, checkBlock)
+
+		first := checkBlock.NewCall(toupper, checkBlock.NewZExt(checkBlock.NewLoad(checkBlock.NewExtractValue(unit, 0)), types.I32))
+		cases := make([]*ir.Case, 0)
+		incomings := make([]*ir.Incoming, 0)
+		for idx := range order {
+			b := f.NewBlock(string(order[idx]))
+			b.NewBr(convertBlock)
+			cases = append(cases, ir.NewCase(constant.NewInt(types.I32, int64(order[idx])), b))
+			incomings = append(incomings, ir.NewIncoming(constant.NewFloat(types.Double, float64(units[order[idx]])), b))
+		}
+		checkBlock.NewSwitch(first, errorBlock, cases...)
+
+		factor := convertBlock.NewPhi(incomings...)
+		convertBlock.NewRet(convertBlock.NewFPToSI(convertBlock.NewCall(round, convertBlock.NewFMul(n, factor)), types.I64))
+		Raise(mod, errorBlock, illegalArgument)
+	}
+
+	generate(NumToDSIntervalFuncName, map[byte]int64{'D': MicrosPerDay, 'H': MicrosPerHour, 'M': MicrosPerMinute, 'S': MicrosPerSecond}, "DHMS")
+	generate(NumToYMIntervalFuncName, map[byte]int64{'Y': 12, 'M': 1}, "YM")
+}
+
+// generateIntervalConversions creates the conversions of intervals from and to strings
+// the format is the one Oracle uses by default ('+01 02:03:04.000000' and '+01-02')
+func generateIntervalConversions(mod *ir.Module) {
+	allocStr := getFuncByName(AllocStringFuncName, mod)
+	snprintf := getFuncByName("snprintf", mod)
+	malloc := getFuncByName("malloc", mod)
+	free := getFuncByName("free", mod)
+	memcpy := getFuncByName("memcpy", mod)
+	sscanf := getFuncByName("sscanf", mod)
+	fabs := getFuncByName("fabs", mod)
+	round := getFuncByName("round", mod)
+	invalidInterval := NewConstantString(mod, "_runtime.msg.invalid_interval", InvalidIntervalMessage)
+	firstCharFormat := newConstantCString(mod, "_runtime.format.first_char", " %c")
+
+	toStr := func(name string, format string, units []int64) {
+		formatStr := newConstantCString(mod, "_runtime.format."+name[len("_runtime._"):], format)
+		x := ir.NewParam("x", types.I64)
+		f := mod.NewFunc(name, StringType, x)
+		b := f.NewBlock("entry")
+
+		isNegative := b.NewICmp(enum.IPredSLT, x, llvmZeroI64)
+		sign := b.NewSelect(isNegative, constant.NewInt(types.I32, '-'), constant.NewInt(types.I32, '+'))
+		abs := b.NewSelect(isNegative, b.NewSub(llvmZeroI64, x), x)
+		args := []value.Value{nil, nil, formatStr, sign}
+		for idx := range units {
+			var rest value.Value = abs
+			if idx > 0 {
+				rest = b.NewSRem(abs, i64Constant(units[idx-1]))
+			}
+			args = append(args, b.NewSDiv(rest, i64Constant(units[idx])))
+		}
+		if units[len(units)-1] != 1 {
+			args = append(args, b.NewSRem(abs, i64Constant(units[len(units)-1])))
+		}
+
+		size := i64Constant(64)
+		buf := b.NewCall(allocStr, size)
+		args[0] = buf
+		args[1] = size
+		n := b.NewCall(snprintf, args...)
+		b.NewRet(newString(b, buf, b.NewSExt(n, types.I64)))
+	}
+	toStr(DSIntervalToStringFuncName, "%c%02lld %02lld:%02lld:%02lld.%06lld", []int64{MicrosPerDay, MicrosPerHour, MicrosPerMinute, MicrosPerSecond})
+	toStr(YMIntervalToStringFuncName, "%c%02lld-%02lld", []int64{12, 1})
+
+	// fromStr scans a string with sscanf, which needs a copy of the string that is zero terminated
+	// the sign is the first character that isn't blank, so that '-0 12:00:00' stays negative
+	fromStr := func(name string, format string, fieldTypes []types.Type, join func(b *ir.Block, values []value.Value) (value.Value, value.Value)) {
+		formatStr := newConstantCString(mod, "_runtime.format."+name[len("_runtime._"):], format)
+		s := ir.NewParam("s", StringType)
+		f := mod.NewFunc(name, types.I64, s)
+		entry := f.NewBlock("entry")
+		okBlock := f.NewBlock("ok")
+		errorBlock := f.NewBlock("error")
+
+		data := entry.NewExtractValue(s, 0)
+		n := entry.NewExtractValue(s, 1)
+		buf := entry.NewCall(malloc, entry.NewAdd(n, llvmOneI64))
+		entry.NewCall(memcpy, buf, data, n)
+		entry.NewStore(constant.NewInt(types.I8, 0), entry.NewGetElementPtr(buf, n))
+		args := []value.Value{buf, formatStr}
+		values := make([]value.Value, 0)
+		for _, t := range fieldTypes {
+			v := entry.NewAlloca(t)
+			entry.NewStore(constant.NewZeroInitializer(t), v)
+			values = append(values, v)
+			args = append(args, v)
+		}
+		scanned := entry.NewCall(sscanf, args...)
+		first := entry.NewAlloca(types.I8)
+		entry.NewStore(constant.NewInt(types.I8, 0), first)
+		entry.NewCall(sscanf, buf, firstCharFormat, first)
+		entry.NewCall(free, buf)
+		isNegative := entry.NewICmp(enum.IPredEQ, entry.NewLoad(first), constant.NewInt(types.I8, '-'))
+		for idx := range values {
+			// the sign has been taken care of already
+			values[idx] = entry.NewLoad(values[idx])
+			if types.Equal(fieldTypes[idx], types.Double) {
+				values[idx] = entry.NewCall(fabs, values[idx])
+			} else {
+				isFieldNegative := entry.NewICmp(enum.IPredSLT, values[idx], llvmZeroI64)
+				values[idx] = entry.NewSelect(isFieldNegative, entry.NewSub(llvmZeroI64, values[idx]), values[idx])
+			}
+		}
+		x, isValid := join(entry, values)
+		isComplete := entry.NewICmp(enum.IPredEQ, scanned, constant.NewInt(types.I32, int64(len(fieldTypes))))
+		entry.NewCondBr(entry.NewAnd(isComplete, isValid), okBlock, errorBlock)
+
+		okBlock.NewRet(okBlock.NewSelect(isNegative, okBlock.NewSub(llvmZeroI64, x), x))
+		Raise(mod, errorBlock, invalidInterval)
+	}
+
+	isBelow := func(b *ir.Block, v value.Value, limit int64) value.Value {
+		return b.NewICmp(enum.IPredSLT, v, i64Constant(limit))
+	}
+	dsFields := []types.Type{types.I64, types.I64, types.I64, types.Double}
+	fromStr(StringToDSIntervalFuncName, " %lld %lld:%lld:%lf", dsFields, func(b *ir.Block, v []value.Value) (value.Value, value.Value) {
+		var x value.Value = b.NewMul(v[0], i64Constant(MicrosPerDay))
+		x = b.NewAdd(x, b.NewMul(v[1], i64Constant(MicrosPerHour)))
+		x = b.NewAdd(x, b.NewMul(v[2], i64Constant(MicrosPerMinute)))
+		micros := b.NewCall(round, b.NewFMul(v[3], constant.NewFloat(types.Double, MicrosPerSecond)))
+		x = b.NewAdd(x, b.NewFPToSI(micros, types.I64))
+		isValidSecond := b.NewFCmp(enum.FPredOLT, v[3], constant.NewFloat(types.Double, 60))
+		isValid := b.NewAnd(b.NewAnd(isBelow(b, v[1], 24), isBelow(b, v[2], 60)), isValidSecond)
+		return x, isValid
+	})
+	ymFields := []types.Type{types.I64, types.I64}
+	fromStr(StringToYMIntervalFuncName, " %lld-%lld", ymFields, func(b *ir.Block, v []value.Value) (value.Value, value.Value) {
+		return b.NewAdd(b.NewMul(v[0], i64Constant(12)), v[1]), isBelow(b, v[1], 12)
+	})
+}
@@ -0,0 +1,337 @@
+/*
+ * Copyright 2019 Marco Helmich
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package runtime
+
+import (
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// DBMS_OUTPUT collects lines in a buffer that is written to stdout when the program ends
+// or right before DBMS.PRINT writes something so that all output stays in order
+// text that has been PUT without finishing the line is never written
+// GET_LINE and GET_LINES take lines out of the buffer, those lines aren't written anymore
+const (
+	DbmsOutputPutFuncName      = "_runtime.dbmsOutput.put"
+	DbmsOutputPutLineFuncName  = "_runtime.dbmsOutput.putLine"
+	DbmsOutputNewLineFuncName  = "_runtime.dbmsOutput.newLine"
+	DbmsOutputEnableFuncName   = "_runtime.dbmsOutput.enable"
+	DbmsOutputDisableFuncName  = "_runtime.dbmsOutput.disable"
+	DbmsOutputGetLineFuncName  = "_runtime.dbmsOutput.getLine"
+	DbmsOutputGetLinesFuncName = "_runtime.dbmsOutput.getLines"
+
+	dbmsOutputFlushFuncName  = "_runtime._dbmsOutputFlush"
+	dbmsOutputAppendFuncName = "_runtime._dbmsOutputAppend"
+	dbmsOutputPurgeFuncName  = "_runtime._dbmsOutputPurge"
+
+	// the buffer is enabled and unlimited from the start
+	dbmsOutputEnabledName = "_runtime.dbms_output.enabled"
+	// the maximum number of bytes in the buffer, 0 is unlimited
+	dbmsOutputLimitName = "_runtime.dbms_output.limit"
+	dbmsOutputDataName  = "_runtime.dbms_output.data"
+	dbmsOutputLenName   = "_runtime.dbms_output.len"
+	dbmsOutputCapName   = "_runtime.dbms_output.cap"
+	// the position of the next line GET_LINE returns
+	dbmsOutputReadName = "_runtime.dbms_output.read"
+	// the position of the line that hasn't been finished yet
+	dbmsOutputLineName = "_runtime.dbms_output.line"
+	// set by GET_LINE, the next PUT throws away the lines that haven't been read
+	dbmsOutputReadingName = "_runtime.dbms_output.reading"
+
+	DbmsOutputDefaultBufferSize = 20000
+	dbmsOutputMinBufferSize     = 2000
+	dbmsOutputMaxBufferSize     = 1000000
+	dbmsOutputMaxLineLength     = 32767
+
+	BufferOverflowMessage     = "ORA-20000: ORU-10027: buffer overflow, limit of %lld bytes"
+	LineLengthOverflowMessage = "ORA-20000: ORU-10028: line length overflow, limit of 32767 bytes per line"
+)
+
+// generateDbmsOutputBuffer creates the buffer and the function that writes it to stdout
+// it comes before everything else as printing and raising an error write the buffer first
+func generateDbmsOutputBuffer(mod *ir.Module) {
+	mod.NewGlobalDef(dbmsOutputEnabledName, constant.True)
+	mod.NewGlobalDef(dbmsOutputLimitName, llvmZeroI64)
+	mod.NewGlobalDef(dbmsOutputDataName, constant.NewNull(i8Ptr))
+	mod.NewGlobalDef(dbmsOutputLenName, llvmZeroI64)
+	mod.NewGlobalDef(dbmsOutputCapName, llvmZeroI64)
+	mod.NewGlobalDef(dbmsOutputReadName, llvmZeroI64)
+	mod.NewGlobalDef(dbmsOutputLineName, llvmZeroI64)
+	mod.NewGlobalDef(dbmsOutputReadingName, constant.False)
+
+	generate_dbmsOutputPurge(mod)
+	generate_dbmsOutputFlush(mod)
+}
+
+func generateDbmsOutput(mod *ir.Module) {
+	generate_dbmsOutputAppend(mod)
+	generateDbmsOutputPut(mod)
+	generateDbmsOutputEnable(mod)
+	generateDbmsOutputDisable(mod)
+	generateDbmsOutputGetLine(mod)
+	generateDbmsOutputGetLines(mod)
+}
+
+// ifEnabled returns the block that continues if DBMS_OUTPUT is enabled
+// the function returns right away if it isn't
+func ifEnabled(mod *ir.Module, f *ir.Func, b *ir.Block) *ir.Block {
+	enabledBlock := f.NewBlock("enabled")
+	disabledBlock := f.NewBlock("disabled")
+	b.NewCondBr(b.NewLoad(getGlobalByName(dbmsOutputEnabledName, mod)), enabledBlock, disabledBlock)
+	disabledBlock.NewRet(nil)
+	return enabledBlock
+}
+
+// generate_dbmsOutputPurge throws away everything in the buffer
+func generate_dbmsOutputPurge(mod *ir.Module) {
+	f := mod.NewFunc(dbmsOutputPurgeFuncName, types.Void)
+	b := f.NewBlock("entry")
+	b.NewStore(llvmZeroI64, getGlobalByName(dbmsOutputLenName, mod))
+	b.NewStore(llvmZeroI64, getGlobalByName(dbmsOutputReadName, mod))
+	b.NewStore(llvmZeroI64, getGlobalByName(dbmsOutputLineName, mod))
+	b.NewStore(constant.False, getGlobalByName(dbmsOutputReadingName, mod))
+	b.NewRet(nil)
+}
+
+// generate_dbmsOutputFlush writes all finished lines that haven't been read to stdout
+// whatever has been printed with putchar goes first
+func generate_dbmsOutputFlush(mod *ir.Module) {
+	fflush := getFuncByName("fflush", mod)
+	write := getFuncByName("write", mod)
+
+	f := mod.NewFunc(dbmsOutputFlushFuncName, types.Void)
+	entry := f.NewBlock("entry")
+	writeBlock := f.NewBlock("write")
+	doneBlock := f.NewBlock("done")
+
+	read := entry.NewLoad(getGlobalByName(dbmsOutputReadName, mod))
+	line := entry.NewLoad(getGlobalByName(dbmsOutputLineName, mod))
+	entry.NewCondBr(entry.NewICmp(enum.IPredSLT, read, line), writeBlock, doneBlock)
+
+	data := writeBlock.NewLoad(getGlobalByName(dbmsOutputDataName, mod))
+	writeBlock.NewCall(fflush, constant.NewNull(i8Ptr))
+	writeBlock.NewCall(write, llvmOneI32, writeBlock.NewGetElementPtr(data, read), writeBlock.NewSub(line, read))
+	writeBlock.NewStore(line, getGlobalByName(dbmsOutputReadName, mod))
+	writeBlock.NewBr(doneBlock)
+
+	doneBlock.NewRet(nil)
+}
+
+// generate_dbmsOutputAppend adds n characters to the line that hasn't been finished yet
+// the buffer grows as needed up to the limit set by ENABLE
+func generate_dbmsOutputAppend(mod *ir.Module) {
+	realloc := getFuncByName("realloc", mod)
+	memcpy := getFuncByName("memcpy", mod)
+	snprintf := getFuncByName("snprintf", mod)
+	allocStr := getFuncByName(AllocStringFuncName, mod)
+	purge := getFuncByName(dbmsOutputPurgeFuncName, mod)
+	dataGlobal := getGlobalByName(dbmsOutputDataName, mod)
+	lenGlobal := getGlobalByName(dbmsOutputLenName, mod)
+	capGlobal := getGlobalByName(dbmsOutputCapName, mod)
+	readingGlobal := getGlobalByName(dbmsOutputReadingName, mod)
+	overflowFormat := newConstantCString(mod, "_runtime.format.buffer_overflow", BufferOverflowMessage)
+	lineOverflow := NewConstantString(mod, "_runtime.msg.line_length_overflow", LineLengthOverflowMessage)
+
+	src := ir.NewParam("src", i8Ptr)
+	n := ir.NewParam("n", types.I64)
+	f := mod.NewFunc(dbmsOutputAppendFuncName, types.Void, src, n)
+	entry := f.NewBlock("entry")
+	purgeBlock := f.NewBlock("purge")
+	checkBlock := f.NewBlock("check")
+	growBlock := f.NewBlock("grow")
+	copyBlock := f.NewBlock("copy")
+	overflowBlock := f.NewBlock("overflow")
+
+	// lines that haven't been read after GET_LINE are gone
+	entry.NewCondBr(entry.NewLoad(readingGlobal), purgeBlock, checkBlock)
+	purgeBlock.NewCall(purge)
+	purgeBlock.NewBr(checkBlock)
+
+	b := checkBlock
+	len := b.NewLoad(lenGlobal)
+	newLen := b.NewAdd(len, n)
+	lineLen := b.NewSub(newLen, b.NewLoad(getGlobalByName(dbmsOutputLineName, mod)))
+	b = checkOrRaise(mod, b, b.NewICmp(enum.IPredSLE, lineLen, i64Constant(dbmsOutputMaxLineLength)), lineOverflow)
+	limit := b.NewLoad(getGlobalByName(dbmsOutputLimitName, mod))
+	buffered := b.NewSub(newLen, b.NewLoad(getGlobalByName(dbmsOutputReadName, mod)))
+	isUnlimited := b.NewICmp(enum.IPredEQ, limit, llvmZeroI64)
+	fits := b.NewOr(isUnlimited, b.NewICmp(enum.IPredSLE, buffered, limit))
+	fitsBlock := f.NewBlock("fits")
+	b.NewCondBr(fits, fitsBlock, overflowBlock)
+
+	b = fitsBlock
+	cap := b.NewLoad(capGlobal)
+	b.NewCondBr(b.NewICmp(enum.IPredSGT, newLen, cap), growBlock, copyBlock)
+
+	// the capacity at least doubles
+	doubled := growBlock.NewMul(cap, i64Constant(2))
+	newCap := growBlock.NewSelect(growBlock.NewICmp(enum.IPredSGT, doubled, newLen), doubled, newLen)
+	newCap = growBlock.NewSelect(growBlock.NewICmp(enum.IPredSGT, newCap, i64Constant(1024)), newCap, i64Constant(1024))
+	growBlock.NewStore(growBlock.NewCall(realloc, growBlock.NewLoad(dataGlobal), newCap), dataGlobal)
+	growBlock.NewStore(newCap, capGlobal)
+	growBlock.NewBr(copyBlock)
+
+	data := copyBlock.NewLoad(dataGlobal)
+	copyBlock.NewCall(memcpy, copyBlock.NewGetElementPtr(data, len), src, n)
+	copyBlock.NewStore(newLen, lenGlobal)
+	copyBlock.NewRet(nil)
+
+	// the message contains the limit
+	size := i64Constant(128)
+	buf := overflowBlock.NewCall(allocStr, size)
+	written := overflowBlock.NewCall(snprintf, buf, size, overflowFormat, limit)
+	overflowBlock.NewCall(getFuncByName(RaiseFuncName, mod), newString(overflowBlock, buf, overflowBlock.NewSExt(written, types.I64)))
+	overflowBlock.NewUnreachable()
+}
+
+// generateDbmsOutputPut creates PUT, PUT_LINE and NEW_LINE
+func generateDbmsOutputPut(mod *ir.Module) {
+	appendFunc := getFuncByName(dbmsOutputAppendFuncName, mod)
+	newline := newConstantCString(mod, "_runtime.dbms_output.newline", "\n")
+
+	endLine := func(b *ir.Block) {
+		b.NewCall(appendFunc, newline, llvmOneI64)
+		b.NewStore(b.NewLoad(getGlobalByName(dbmsOutputLenName, mod)), getGlobalByName(dbmsOutputLineName, mod))
+	}
+	putStr := func(b *ir.Block, s value.Value) {
+		b.NewCall(appendFunc, b.NewExtractValue(s, 0), b.NewExtractValue(s, 1))
+	}
+
+	s := ir.NewParam("s", StringType)
+	f := mod.NewFunc(DbmsOutputPutFuncName, types.Void, s)
+	b := ifEnabled(mod, f, f.NewBlock("entry"))
+	putStr(b, s)
+	b.NewRet(nil)
+
+	s = ir.NewParam("s", StringType)
+	f = mod.NewFunc(DbmsOutputPutLineFuncName, types.Void, s)
+	b = ifEnabled(mod, f, f.NewBlock("entry"))
+	putStr(b, s)
+	endLine(b)
+	b.NewRet(nil)
+
+	f = mod.NewFunc(DbmsOutputNewLineFuncName, types.Void)
+	b = ifEnabled(mod, f, f.NewBlock("entry"))
+	endLine(b)
+	b.NewRet(nil)
+}
+
+// generateDbmsOutputEnable sets the limit of the buffer
+// sizes are moved into the range Oracle allows
+func generateDbmsOutputEnable(mod *ir.Module) {
+	size := ir.NewParam("size", types.I64)
+	f := mod.NewFunc(DbmsOutputEnableFuncName, types.Void, size)
+	b := f.NewBlock("entry")
+	min := i64Constant(dbmsOutputMinBufferSize)
+	max := i64Constant(dbmsOutputMaxBufferSize)
+	limit := b.NewSelect(b.NewICmp(enum.IPredSLT, size, min), min, size)
+	limit = b.NewSelect(b.NewICmp(enum.IPredSGT, limit, max), max, limit)
+	b.NewStore(limit, getGlobalByName(dbmsOutputLimitName, mod))
+	b.NewStore(constant.True, getGlobalByName(dbmsOutputEnabledName, mod))
+	b.NewRet(nil)
+}
+
+// generateDbmsOutputDisable turns off DBMS_OUTPUT and throws away the buffer
+func generateDbmsOutputDisable(mod *ir.Module) {
+	f := mod.NewFunc(DbmsOutputDisableFuncName, types.Void)
+	b := f.NewBlock("entry")
+	b.NewStore(constant.False, getGlobalByName(dbmsOutputEnabledName, mod))
+	b.NewCall(getFuncByName(dbmsOutputPurgeFuncName, mod))
+	b.NewRet(nil)
+}
+
+// generateDbmsOutputGetLine takes the next finished line out of the buffer
+// status is 0 if there was one and 1 if there wasn't, the line is empty then
+func generateDbmsOutputGetLine(mod *ir.Module) {
+	memchr := getFuncByName("memchr", mod)
+	memcpy := getFuncByName("memcpy", mod)
+	allocStr := getFuncByName(AllocStringFuncName, mod)
+	readGlobal := getGlobalByName(dbmsOutputReadName, mod)
+
+	line := ir.NewParam("line", StringPointerType)
+	status := ir.NewParam("status", types.NewPointer(types.I64))
+	f := mod.NewFunc(DbmsOutputGetLineFuncName, types.Void, line, status)
+	entry := f.NewBlock("entry")
+	checkBlock := f.NewBlock("check")
+	readBlock := f.NewBlock("read")
+	noLineBlock := f.NewBlock("no-line")
+
+	entry.NewCondBr(entry.NewLoad(getGlobalByName(dbmsOutputEnabledName, mod)), checkBlock, noLineBlock)
+
+	read := checkBlock.NewLoad(readGlobal)
+	end := checkBlock.NewLoad(getGlobalByName(dbmsOutputLineName, mod))
+	checkBlock.NewCondBr(checkBlock.NewICmp(enum.IPredSLT, read, end), readBlock, noLineBlock)
+
+	// finished lines always end with a newline
+	b := readBlock
+	start := b.NewGetElementPtr(b.NewLoad(getGlobalByName(dbmsOutputDataName, mod)), read)
+	nl := b.NewCall(memchr, start, constant.NewInt(types.I32, '\n'), b.NewSub(end, read))
+	n := b.NewSub(b.NewPtrToInt(nl, types.I64), b.NewPtrToInt(start, types.I64))
+	buf := b.NewCall(allocStr, n)
+	b.NewCall(memcpy, buf, start, n)
+	b.NewStore(newString(b, buf, n), line)
+	b.NewStore(b.NewAdd(read, b.NewAdd(n, llvmOneI64)), readGlobal)
+	b.NewStore(constant.True, getGlobalByName(dbmsOutputReadingName, mod))
+	b.NewStore(llvmZeroI64, status)
+	b.NewRet(nil)
+
+	noLineBlock.NewStore(newString(noLineBlock, constant.NewNull(i8Ptr), llvmZeroI64), line)
+	noLineBlock.NewStore(llvmOneI64, status)
+	noLineBlock.NewRet(nil)
+}
+
+// generateDbmsOutputGetLines takes up to numlines finished lines out of the buffer
+// the collection gets the lines from index 1 on and numlines is set to the number of lines it got
+func generateDbmsOutputGetLines(mod *ir.Module) {
+	getLine := getFuncByName(DbmsOutputGetLineFuncName, mod)
+	extend := getFuncByName(CollectionExtendFuncName, mod)
+	stringSize := constant.NewPtrToInt(constant.NewGetElementPtr(constant.NewNull(StringPointerType.(*types.PointerType)), llvmOneI32), types.I64)
+
+	slot := newSlotParam()
+	numlines := ir.NewParam("numlines", i64Ptr)
+	f := mod.NewFunc(DbmsOutputGetLinesFuncName, types.Void, slot, numlines)
+	entry := f.NewBlock("entry")
+	condBlock := f.NewBlock("cond")
+	readBlock := f.NewBlock("read")
+	addBlock := f.NewBlock("add")
+	doneBlock := f.NewBlock("done")
+
+	entry.NewCall(getFuncByName(CollectionDeleteFuncName, mod), entry.NewLoad(slot))
+	max := entry.NewLoad(numlines)
+	i := entry.NewAlloca(types.I64)
+	line := entry.NewAlloca(StringType)
+	status := entry.NewAlloca(types.I64)
+	entry.NewStore(llvmZeroI64, i)
+	entry.NewBr(condBlock)
+
+	n := condBlock.NewLoad(i)
+	condBlock.NewCondBr(condBlock.NewICmp(enum.IPredSLT, n, max), readBlock, doneBlock)
+
+	readBlock.NewCall(getLine, line, status)
+	readBlock.NewCondBr(readBlock.NewICmp(enum.IPredEQ, readBlock.NewLoad(status), llvmZeroI64), addBlock, doneBlock)
+
+	elem := addBlock.NewCall(extend, slot, llvmOneI64, stringSize, llvmZeroI64)
+	addBlock.NewStore(addBlock.NewLoad(line), addBlock.NewBitCast(elem, StringPointerType))
+	addBlock.NewStore(addBlock.NewAdd(n, llvmOneI64), i)
+	addBlock.NewBr(condBlock)
+
+	doneBlock.NewStore(doneBlock.NewLoad(i), numlines)
+	doneBlock.NewRet(nil)
+}
@@ -0,0 +1,124 @@
+/*
+ * Copyright 2019 Marco Helmich
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package runtime
+
+import (
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/types"
+)
+
+// compiled programs run their SQL through a driver with a C interface
+// the driver is linked into the program, the code of the program is the same for all drivers
+//
+//	int         plsqlc_db_connect(const char *name, void **db)
+//	const char *plsqlc_db_errmsg(void *db)
+//	int         plsqlc_db_close(void *db)
+//	int         plsqlc_db_busy_timeout(void *db, int ms)
+//	int         plsqlc_db_prepare(void *db, const char *sql, int n, void **stmt)
+//	int         plsqlc_db_bind_count(void *stmt)
+//	int         plsqlc_db_bind_int(void *stmt, int idx, int64_t v)
+//	int         plsqlc_db_bind_double(void *stmt, int idx, double v)
+//	int         plsqlc_db_bind_text(void *stmt, int idx, const char *v, int n)
+//	int         plsqlc_db_step(void *stmt)
+//	int         plsqlc_db_reset(void *stmt)
+//	int         plsqlc_db_finalize(void *stmt)
+//	int64_t     plsqlc_db_changes(void *db)
+//	int64_t     plsqlc_db_total_changes(void *db)
+//	int         plsqlc_db_column_count(void *stmt)
+//	int         plsqlc_db_column_type(void *stmt, int idx)
+//	int64_t     plsqlc_db_column_int(void *stmt, int idx)
+//	double      plsqlc_db_column_double(void *stmt, int idx)
+//	const char *plsqlc_db_column_text(void *stmt, int idx, int *n)
+//
+// all functions that return an int return DriverOk or one of the other result codes
+// a sql text with n < 0 ends with a 0 byte, parameters are numbered from 1 and columns from 0
+// busy_timeout sets how long statements wait for the locks of other connections, 0 doesn't wait
+// step returns DriverBusy if a statement couldn't get its locks in time
+// bind_count returns -1 if the driver can't count the parameters of a statement
+// the characters of bind_text belong to the caller, the driver needs to copy them
+// the characters of column_text belong to the driver until the next step
+const (
+	DriverOk         = 0
+	DriverError      = 1
+	DriverUnique     = 2
+	DriverNotNull    = 3
+	DriverBusy       = 4
+	DriverRow        = 100
+	DriverDone       = 101
+	DriverColumnInt  = 1
+	DriverColumnReal = 2
+	DriverColumnText = 3
+	DriverColumnNull = 5
+)
+
+// the drivers that ship with plsqlc
+const (
+	SqliteDriver = "sqlite"
+	StubDriver   = "stub"
+)
+
+// IsBuiltinDriver tells whether a driver ships with plsqlc, every other name is the path of a driver
+// it doesn't generate anything, unlike asking GenerateDriver
+func IsBuiltinDriver(name string) bool {
+	return name == SqliteDriver || name == StubDriver
+}
+
+// GenerateDriver creates the module of a driver that ships with plsqlc
+// it returns nil for every other name
+func GenerateDriver(name string) *ir.Module {
+	switch name {
+	case SqliteDriver:
+		return generateSqliteDriver()
+	case StubDriver:
+		return generateStubDriver()
+	default:
+		return nil
+	}
+}
+
+// DriverLibraries returns the libraries a driver that ships with plsqlc needs to be linked against
+func DriverLibraries(name string) []string {
+	if name == SqliteDriver {
+		return []string{"sqlite3"}
+	}
+	return nil
+}
+
+// driverFuncs declares the functions of the driver interface in a module
+// programs declare them to call them, drivers declare them to define them
+func driverFuncs(mod *ir.Module) {
+	i8PtrPtr := types.NewPointer(i8Ptr)
+	mod.NewFunc("plsqlc_db_connect", types.I32, ir.NewParam("name", i8Ptr), ir.NewParam("db", i8PtrPtr))
+	mod.NewFunc("plsqlc_db_errmsg", i8Ptr, ir.NewParam("db", i8Ptr))
+	mod.NewFunc("plsqlc_db_close", types.I32, ir.NewParam("db", i8Ptr))
+	mod.NewFunc("plsqlc_db_busy_timeout", types.I32, ir.NewParam("db", i8Ptr), ir.NewParam("ms", types.I32))
+	mod.NewFunc("plsqlc_db_prepare", types.I32, ir.NewParam("db", i8Ptr), ir.NewParam("sql", i8Ptr), ir.NewParam("n", types.I32), ir.NewParam("stmt", i8PtrPtr))
+	mod.NewFunc("plsqlc_db_bind_count", types.I32, ir.NewParam("stmt", i8Ptr))
+	mod.NewFunc("plsqlc_db_bind_int", types.I32, ir.NewParam("stmt", i8Ptr), ir.NewParam("idx", types.I32), ir.NewParam("v", types.I64))
+	mod.NewFunc("plsqlc_db_bind_double", types.I32, ir.NewParam("stmt", i8Ptr), ir.NewParam("idx", types.I32), ir.NewParam("v", types.Double))
+	mod.NewFunc("plsqlc_db_bind_text", types.I32, ir.NewParam("stmt", i8Ptr), ir.NewParam("idx", types.I32), ir.NewParam("v", i8Ptr), ir.NewParam("n", types.I32))
+	mod.NewFunc("plsqlc_db_step", types.I32, ir.NewParam("stmt", i8Ptr))
+	mod.NewFunc("plsqlc_db_reset", types.I32, ir.NewParam("stmt", i8Ptr))
+	mod.NewFunc("plsqlc_db_finalize", types.I32, ir.NewParam("stmt", i8Ptr))
+	mod.NewFunc("plsqlc_db_changes", types.I64, ir.NewParam("db", i8Ptr))
+	mod.NewFunc("plsqlc_db_total_changes", types.I64, ir.NewParam("db", i8Ptr))
+	mod.NewFunc("plsqlc_db_column_count", types.I32, ir.NewParam("stmt", i8Ptr))
+	mod.NewFunc("plsqlc_db_column_type", types.I32, ir.NewParam("stmt", i8Ptr), ir.NewParam("idx", types.I32))
+	mod.NewFunc("plsqlc_db_column_int", types.I64, ir.NewParam("stmt", i8Ptr), ir.NewParam("idx", types.I32))
+	mod.NewFunc("plsqlc_db_column_double", types.Double, ir.NewParam("stmt", i8Ptr), ir.NewParam("idx", types.I32))
+	mod.NewFunc("plsqlc_db_column_text", i8Ptr, ir.NewParam("stmt", i8Ptr), ir.NewParam("idx", types.I32), ir.NewParam("n", types.NewPointer(types.I32)))
+}
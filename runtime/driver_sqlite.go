@@ -0,0 +1,182 @@
+/*
+ * Copyright 2019 Marco Helmich
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package runtime
+
+import (
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+)
+
+const (
+	sqliteOpenReadWrite     = 2
+	sqliteBusy              = 5
+	sqliteRow               = 100
+	sqliteDone              = 101
+	sqliteConstraintNotNull = 1299
+	sqliteConstraintPrimary = 1555
+	sqliteConstraintUnique  = 2067
+)
+
+// generateSqliteDriver creates the driver that runs SQL against a SQLite database file
+// the name a program connects to is the path of the file, it has to exist already
+// connections switch the file to WAL, readers don't block the writer and the writer doesn't block readers
+// databases that can't switch keep their journal, the result of the switch is ignored
+// SQLite numbers the types of columns like the driver interface does
+func generateSqliteDriver() *ir.Module {
+	mod := ir.NewModule()
+	declareSqlite(mod)
+	driverFuncs(mod)
+	sqlite := func(name string) *ir.Func {
+		return getFuncByName("sqlite3_"+name, mod)
+	}
+	// define creates the body of a function of the interface
+	define := func(name string) (*ir.Func, *ir.Block) {
+		f := getFuncByName("plsqlc_db_"+name, mod)
+		return f, f.NewBlock("entry")
+	}
+	// result turns a result code of SQLite into DriverOk or DriverError
+	result := func(b *ir.Block, rc *ir.InstCall) {
+		isOk := b.NewICmp(enum.IPredEQ, rc, llvmZeroI32)
+		b.NewRet(b.NewSelect(isOk, i32Constant(DriverOk), i32Constant(DriverError)))
+	}
+
+	f, b := define("connect")
+	openedBlock := f.NewBlock("opened")
+	failedBlock := f.NewBlock("failed")
+	rc := b.NewCall(sqlite("open_v2"), f.Params[0], f.Params[1], i32Constant(sqliteOpenReadWrite), constant.NewNull(i8Ptr))
+	b.NewCondBr(b.NewICmp(enum.IPredEQ, rc, llvmZeroI32), openedBlock, failedBlock)
+	wal := newConstantCString(mod, "sqlite.wal", "PRAGMA journal_mode = WAL")
+	null := constant.NewNull(i8Ptr)
+	openedBlock.NewCall(sqlite("exec"), openedBlock.NewLoad(f.Params[1]), wal, null, null, null)
+	openedBlock.NewRet(i32Constant(DriverOk))
+	failedBlock.NewRet(i32Constant(DriverError))
+
+	f, b = define("errmsg")
+	b.NewRet(b.NewCall(sqlite("errmsg"), f.Params[0]))
+
+	f, b = define("close")
+	result(b, b.NewCall(sqlite("close_v2"), f.Params[0]))
+
+	f, b = define("busy_timeout")
+	result(b, b.NewCall(sqlite("busy_timeout"), f.Params[0], f.Params[1]))
+
+	f, b = define("prepare")
+	result(b, b.NewCall(sqlite("prepare_v2"), f.Params[0], f.Params[1], f.Params[2], f.Params[3], constant.NewNull(types.NewPointer(i8Ptr))))
+
+	f, b = define("bind_count")
+	b.NewRet(b.NewCall(sqlite("bind_parameter_count"), f.Params[0]))
+
+	f, b = define("bind_int")
+	result(b, b.NewCall(sqlite("bind_int64"), f.Params[0], f.Params[1], f.Params[2]))
+
+	f, b = define("bind_double")
+	result(b, b.NewCall(sqlite("bind_double"), f.Params[0], f.Params[1], f.Params[2]))
+
+	// SQLITE_TRANSIENT makes SQLite copy the characters
+	f, b = define("bind_text")
+	transient := constant.NewIntToPtr(constant.NewInt(types.I64, -1), i8Ptr)
+	result(b, b.NewCall(sqlite("bind_text"), f.Params[0], f.Params[1], f.Params[2], f.Params[3], transient))
+
+	// constraint violations are told apart by the extended result code of the connection
+	// all kinds of SQLITE_BUSY are locks the statement didn't get, a snapshot that is too old to write to is one of them
+	f, b = define("step")
+	okBlock := f.NewBlock("ok")
+	errorBlock := f.NewBlock("error")
+	uniqueBlock := f.NewBlock("unique")
+	notNullBlock := f.NewBlock("not-null")
+	checkBusyBlock := f.NewBlock("check-busy")
+	busyBlock := f.NewBlock("busy")
+	otherBlock := f.NewBlock("other")
+	rc = b.NewCall(sqlite("step"), f.Params[0])
+	isRow := b.NewICmp(enum.IPredEQ, rc, i32Constant(sqliteRow))
+	isDone := b.NewICmp(enum.IPredEQ, rc, i32Constant(sqliteDone))
+	b.NewCondBr(b.NewOr(isRow, isDone), okBlock, errorBlock)
+	okBlock.NewRet(rc)
+	code := errorBlock.NewCall(sqlite("extended_errcode"), errorBlock.NewCall(sqlite("db_handle"), f.Params[0]))
+	errorBlock.NewSwitch(code, checkBusyBlock,
+		ir.NewCase(i32Constant(sqliteConstraintUnique), uniqueBlock),
+		ir.NewCase(i32Constant(sqliteConstraintPrimary), uniqueBlock),
+		ir.NewCase(i32Constant(sqliteConstraintNotNull), notNullBlock))
+	uniqueBlock.NewRet(i32Constant(DriverUnique))
+	notNullBlock.NewRet(i32Constant(DriverNotNull))
+	isBusy := checkBusyBlock.NewICmp(enum.IPredEQ, checkBusyBlock.NewAnd(code, i32Constant(0xff)), i32Constant(sqliteBusy))
+	checkBusyBlock.NewCondBr(isBusy, busyBlock, otherBlock)
+	busyBlock.NewRet(i32Constant(DriverBusy))
+	otherBlock.NewRet(i32Constant(DriverError))
+
+	f, b = define("reset")
+	result(b, b.NewCall(sqlite("reset"), f.Params[0]))
+
+	f, b = define("finalize")
+	result(b, b.NewCall(sqlite("finalize"), f.Params[0]))
+
+	f, b = define("changes")
+	b.NewRet(b.NewSExt(b.NewCall(sqlite("changes"), f.Params[0]), types.I64))
+
+	f, b = define("total_changes")
+	b.NewRet(b.NewSExt(b.NewCall(sqlite("total_changes"), f.Params[0]), types.I64))
+
+	f, b = define("column_count")
+	b.NewRet(b.NewCall(sqlite("column_count"), f.Params[0]))
+
+	f, b = define("column_type")
+	b.NewRet(b.NewCall(sqlite("column_type"), f.Params[0], f.Params[1]))
+
+	f, b = define("column_int")
+	b.NewRet(b.NewCall(sqlite("column_int64"), f.Params[0], f.Params[1]))
+
+	f, b = define("column_double")
+	b.NewRet(b.NewCall(sqlite("column_double"), f.Params[0], f.Params[1]))
+
+	// the length is asked for after the text, SQLite might convert the value to get the text
+	f, b = define("column_text")
+	text := b.NewCall(sqlite("column_text"), f.Params[0], f.Params[1])
+	b.NewStore(b.NewCall(sqlite("column_bytes"), f.Params[0], f.Params[1]), f.Params[2])
+	b.NewRet(text)
+	return mod
+}
+
+// declareSqlite declares the parts of the SQLite c api the driver uses
+// connections and statements are opaque pointers
+func declareSqlite(mod *ir.Module) {
+	i8PtrPtr := types.NewPointer(i8Ptr)
+	mod.NewFunc("sqlite3_open_v2", types.I32, ir.NewParam("filename", i8Ptr), ir.NewParam("db", i8PtrPtr), ir.NewParam("flags", types.I32), ir.NewParam("vfs", i8Ptr))
+	mod.NewFunc("sqlite3_close_v2", types.I32, ir.NewParam("db", i8Ptr))
+	mod.NewFunc("sqlite3_errmsg", i8Ptr, ir.NewParam("db", i8Ptr))
+	mod.NewFunc("sqlite3_exec", types.I32, ir.NewParam("db", i8Ptr), ir.NewParam("sql", i8Ptr), ir.NewParam("callback", i8Ptr), ir.NewParam("arg", i8Ptr), ir.NewParam("errmsg", i8Ptr))
+	mod.NewFunc("sqlite3_busy_timeout", types.I32, ir.NewParam("db", i8Ptr), ir.NewParam("ms", types.I32))
+	mod.NewFunc("sqlite3_extended_errcode", types.I32, ir.NewParam("db", i8Ptr))
+	mod.NewFunc("sqlite3_db_handle", i8Ptr, ir.NewParam("stmt", i8Ptr))
+	mod.NewFunc("sqlite3_prepare_v2", types.I32, ir.NewParam("db", i8Ptr), ir.NewParam("sql", i8Ptr), ir.NewParam("n", types.I32), ir.NewParam("stmt", i8PtrPtr), ir.NewParam("tail", i8PtrPtr))
+	mod.NewFunc("sqlite3_bind_parameter_count", types.I32, ir.NewParam("stmt", i8Ptr))
+	mod.NewFunc("sqlite3_bind_int64", types.I32, ir.NewParam("stmt", i8Ptr), ir.NewParam("idx", types.I32), ir.NewParam("v", types.I64))
+	mod.NewFunc("sqlite3_bind_double", types.I32, ir.NewParam("stmt", i8Ptr), ir.NewParam("idx", types.I32), ir.NewParam("v", types.Double))
+	mod.NewFunc("sqlite3_bind_text", types.I32, ir.NewParam("stmt", i8Ptr), ir.NewParam("idx", types.I32), ir.NewParam("v", i8Ptr), ir.NewParam("n", types.I32), ir.NewParam("destructor", i8Ptr))
+	mod.NewFunc("sqlite3_step", types.I32, ir.NewParam("stmt", i8Ptr))
+	mod.NewFunc("sqlite3_reset", types.I32, ir.NewParam("stmt", i8Ptr))
+	mod.NewFunc("sqlite3_finalize", types.I32, ir.NewParam("stmt", i8Ptr))
+	mod.NewFunc("sqlite3_changes", types.I32, ir.NewParam("db", i8Ptr))
+	mod.NewFunc("sqlite3_total_changes", types.I32, ir.NewParam("db", i8Ptr))
+	mod.NewFunc("sqlite3_column_count", types.I32, ir.NewParam("stmt", i8Ptr))
+	mod.NewFunc("sqlite3_column_type", types.I32, ir.NewParam("stmt", i8Ptr), ir.NewParam("idx", types.I32))
+	mod.NewFunc("sqlite3_column_int64", types.I64, ir.NewParam("stmt", i8Ptr), ir.NewParam("idx", types.I32))
+	mod.NewFunc("sqlite3_column_double", types.Double, ir.NewParam("stmt", i8Ptr), ir.NewParam("idx", types.I32))
+	mod.NewFunc("sqlite3_column_text", i8Ptr, ir.NewParam("stmt", i8Ptr), ir.NewParam("idx", types.I32))
+	mod.NewFunc("sqlite3_column_bytes", types.I32, ir.NewParam("stmt", i8Ptr), ir.NewParam("idx", types.I32))
+}
@@ -0,0 +1,112 @@
+/*
+ * Copyright 2019 Marco Helmich
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package runtime
+
+import (
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+)
+
+// generateStubDriver creates a driver that doesn't run statements but records them
+// the name a program connects to is the path of a file every statement and bound value is appended to
+// statements change no rows and queries return none, the columns of queries aren't known
+func generateStubDriver() *ir.Module {
+	mod := ir.NewModule()
+	fopen := mod.NewFunc("fopen", i8Ptr, ir.NewParam("path", i8Ptr), ir.NewParam("mode", i8Ptr))
+	fclose := mod.NewFunc("fclose", types.I32, ir.NewParam("f", i8Ptr))
+	fprintf := mod.NewFunc("fprintf", types.I32, ir.NewParam("f", i8Ptr), ir.NewParam("format", i8Ptr))
+	fprintf.Sig.Variadic = true
+	driverFuncs(mod)
+	define := func(name string) (*ir.Func, *ir.Block) {
+		f := getFuncByName("plsqlc_db_"+name, mod)
+		return f, f.NewBlock("entry")
+	}
+	ok := func(b *ir.Block) {
+		b.NewRet(i32Constant(DriverOk))
+	}
+
+	// the connection and all of its statements are the file
+	f, b := define("connect")
+	file := b.NewCall(fopen, f.Params[0], newConstantCString(mod, "stub.append", "a"))
+	b.NewStore(file, f.Params[1])
+	isOpen := b.NewICmp(enum.IPredNE, file, constant.NewNull(i8Ptr))
+	b.NewRet(b.NewSelect(isOpen, i32Constant(DriverOk), i32Constant(DriverError)))
+
+	f, b = define("errmsg")
+	b.NewRet(newConstantCString(mod, "stub.errmsg", "the stub driver can't write to the file"))
+
+	f, b = define("close")
+	b.NewCall(fclose, f.Params[0])
+	ok(b)
+
+	// the file has no locks
+	_, b = define("busy_timeout")
+	ok(b)
+
+	// a negative precision prints the whole text
+	f, b = define("prepare")
+	b.NewCall(fprintf, f.Params[0], newConstantCString(mod, "stub.format.sql", "%.*s\n"), f.Params[2], f.Params[1])
+	b.NewStore(f.Params[0], f.Params[3])
+	ok(b)
+
+	f, b = define("bind_count")
+	b.NewRet(i32Constant(-1))
+
+	f, b = define("bind_int")
+	b.NewCall(fprintf, f.Params[0], newConstantCString(mod, "stub.format.int", "  :%d = %lld\n"), f.Params[1], f.Params[2])
+	ok(b)
+
+	f, b = define("bind_double")
+	b.NewCall(fprintf, f.Params[0], newConstantCString(mod, "stub.format.double", "  :%d = %g\n"), f.Params[1], f.Params[2])
+	ok(b)
+
+	f, b = define("bind_text")
+	b.NewCall(fprintf, f.Params[0], newConstantCString(mod, "stub.format.text", "  :%d = '%.*s'\n"), f.Params[1], f.Params[3], f.Params[2])
+	ok(b)
+
+	f, b = define("step")
+	b.NewRet(i32Constant(DriverDone))
+
+	for _, name := range []string{"reset", "finalize"} {
+		_, b = define(name)
+		ok(b)
+	}
+
+	for _, name := range []string{"changes", "total_changes"} {
+		_, b = define(name)
+		b.NewRet(llvmZeroI64)
+	}
+
+	f, b = define("column_count")
+	b.NewRet(i32Constant(-1))
+
+	f, b = define("column_type")
+	b.NewRet(i32Constant(DriverColumnNull))
+
+	f, b = define("column_int")
+	b.NewRet(llvmZeroI64)
+
+	f, b = define("column_double")
+	b.NewRet(constant.NewFloat(types.Double, 0))
+
+	f, b = define("column_text")
+	b.NewStore(llvmZeroI32, f.Params[2])
+	b.NewRet(newConstantCString(mod, "stub.empty", ""))
+	return mod
+}
@@ -0,0 +1,146 @@
+/*
+ * Copyright 2019 Marco Helmich
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package runtime
+
+import (
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+const (
+	AllocStringFuncName = "_runtime._allocStr"
+
+	heapNextName  = "_runtime.heap.next"
+	heapEndName   = "_runtime.heap.end"
+	heapSizeName  = "_runtime.heap.size"
+	heapLimitName = "_runtime.heap.limit"
+
+	// the characters of strings are carved out of chunks of at least this size
+	heapChunkSize = 64 * 1024
+
+	// HeapLimitEnvVar overrides how many bytes the characters of strings may take up in total
+	HeapLimitEnvVar  = "PLSQLC_HEAP_LIMIT"
+	defaultHeapLimit = 1024 * 1024 * 1024
+
+	OutOfMemoryMessage = "ORA-04030: out of process memory"
+	HeapLimitMessage   = "ORA-04030: out of process memory, strings take up more than " + HeapLimitEnvVar + " bytes (1 GiB by default)"
+)
+
+// generateHeap creates the allocator for the characters of strings
+// strings are immutable values and are never freed
+// which means that a bump pointer into chunks of memory is all it takes to allocate
+// a string is a pointer and a length that assignments, parameters, records, collections and cursors copy freely
+// nothing owns its characters, freeing them would take reference counts or a collector on every one of those copies
+// building a string by concatenation grows it in place (see generate_concatStr), that only takes up its final length
+// a program that keeps making new strings in a loop grows until it reaches the limit of the heap
+func generateHeap(mod *ir.Module) {
+	mod.NewGlobalDef(heapNextName, constant.NewNull(i8Ptr))
+	mod.NewGlobalDef(heapEndName, constant.NewNull(i8Ptr))
+	mod.NewGlobalDef(heapSizeName, llvmZeroI64)
+	// 0 until the limit is read from the environment
+	mod.NewGlobalDef(heapLimitName, llvmZeroI64)
+	generate_allocStr(mod)
+}
+
+// heapAvailable returns the number of bytes that are left in the current chunk
+func heapAvailable(mod *ir.Module, b *ir.Block) (next *ir.InstLoad, available *ir.InstSub) {
+	next = b.NewLoad(getGlobalByName(heapNextName, mod))
+	end := b.NewLoad(getGlobalByName(heapEndName, mod))
+	available = b.NewSub(b.NewPtrToInt(end, types.I64), b.NewPtrToInt(next, types.I64))
+	return next, available
+}
+
+// releaseTail gives the characters from tail to end back to the heap if nothing has been allocated after end
+// conversions allocate room for the longest result and release what they didn't need
+// that way the result is the last allocation and can be concatenated in place
+func releaseTail(mod *ir.Module, b *ir.Block, tail value.Value, end value.Value) {
+	heapNext := getGlobalByName(heapNextName, mod)
+	next := b.NewLoad(heapNext)
+	b.NewStore(b.NewSelect(b.NewICmp(enum.IPredEQ, end, next), tail, next), heapNext)
+}
+
+// generate_allocStr returns memory for n characters
+// a request that doesn't fit into a chunk gets a chunk of twice its size
+// so that a string that is built up by concatenation can keep growing in place
+// an error is raised once the chunks would take up more than the limit of the heap
+func generate_allocStr(mod *ir.Module) {
+	malloc := getFuncByName("malloc", mod)
+	getenv := getFuncByName("getenv", mod)
+	strtod := getFuncByName("strtod", mod)
+	heapNext := getGlobalByName(heapNextName, mod)
+	heapEnd := getGlobalByName(heapEndName, mod)
+	heapSize := getGlobalByName(heapSizeName, mod)
+	heapLimit := getGlobalByName(heapLimitName, mod)
+	envVar := newConstantCString(mod, "_runtime.heap.env_var", HeapLimitEnvVar)
+	outOfMemory := NewConstantString(mod, "_runtime.msg.out_of_memory", OutOfMemoryMessage)
+	limitReached := NewConstantString(mod, "_runtime.msg.heap_limit", HeapLimitMessage)
+
+	n := ir.NewParam("n", types.I64)
+	f := mod.NewFunc(AllocStringFuncName, i8Ptr, n)
+	entry := f.NewBlock("entry")
+	bumpBlock := f.NewBlock("bump")
+	refillBlock := f.NewBlock("refill")
+	readLimitBlock := f.NewBlock("read-limit")
+	parseLimitBlock := f.NewBlock("parse-limit")
+	checkLimitBlock := f.NewBlock("check-limit")
+	mallocBlock := f.NewBlock("malloc")
+	chunkBlock := f.NewBlock("chunk")
+	limitBlock := f.NewBlock("limit")
+	errorBlock := f.NewBlock("error")
+
+	next, available := heapAvailable(mod, entry)
+	entry.NewCondBr(entry.NewICmp(enum.IPredULE, n, available), bumpBlock, refillBlock)
+
+	bumpBlock.NewStore(bumpBlock.NewGetElementPtr(next, n), heapNext)
+	bumpBlock.NewRet(next)
+
+	// the rest of the old chunk is abandoned
+	doubled := refillBlock.NewMul(n, constant.NewInt(types.I64, 2))
+	minSize := constant.NewInt(types.I64, heapChunkSize)
+	size := refillBlock.NewSelect(refillBlock.NewICmp(enum.IPredUGT, doubled, minSize), doubled, minSize)
+	isUnknown := refillBlock.NewICmp(enum.IPredEQ, refillBlock.NewLoad(heapLimit), llvmZeroI64)
+	refillBlock.NewCondBr(isUnknown, readLimitBlock, checkLimitBlock)
+
+	defaultLimit := constant.NewInt(types.I64, defaultHeapLimit)
+	readLimitBlock.NewStore(defaultLimit, heapLimit)
+	s := readLimitBlock.NewCall(getenv, envVar)
+	readLimitBlock.NewCondBr(readLimitBlock.NewICmp(enum.IPredEQ, s, constant.NewNull(i8Ptr)), checkLimitBlock, parseLimitBlock)
+
+	// limits that aren't a positive number of bytes are ignored
+	value := parseLimitBlock.NewCall(strtod, s, constant.NewNull(types.NewPointer(i8Ptr)))
+	isValid := parseLimitBlock.NewAnd(parseLimitBlock.NewFCmp(enum.FPredOGE, value, llvmOneDouble), parseLimitBlock.NewFCmp(enum.FPredOLT, value, constant.NewFloat(types.Double, 9e18)))
+	parseLimitBlock.NewStore(parseLimitBlock.NewSelect(isValid, parseLimitBlock.NewFPToSI(value, types.I64), defaultLimit), heapLimit)
+	parseLimitBlock.NewBr(checkLimitBlock)
+
+	total := checkLimitBlock.NewAdd(checkLimitBlock.NewLoad(heapSize), size)
+	tooLarge := checkLimitBlock.NewICmp(enum.IPredUGT, total, checkLimitBlock.NewLoad(heapLimit))
+	checkLimitBlock.NewCondBr(tooLarge, limitBlock, mallocBlock)
+
+	chunk := mallocBlock.NewCall(malloc, size)
+	mallocBlock.NewCondBr(mallocBlock.NewICmp(enum.IPredEQ, chunk, constant.NewNull(i8Ptr)), errorBlock, chunkBlock)
+
+	chunkBlock.NewStore(total, heapSize)
+	chunkBlock.NewStore(chunkBlock.NewGetElementPtr(chunk, n), heapNext)
+	chunkBlock.NewStore(chunkBlock.NewGetElementPtr(chunk, size), heapEnd)
+	chunkBlock.NewRet(chunk)
+
+	Raise(mod, limitBlock, limitReached)
+	Raise(mod, errorBlock, outOfMemory)
+}
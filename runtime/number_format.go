@@ -0,0 +1,505 @@
+/*
+ * Copyright 2019 Marco Helmich
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package runtime
+
+import (
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// number format models ('FM$9,990.00') as used by TO_CHAR and TO_NUMBER
+// the supported elements are 9, 0, '.', ',', D, G, S, MI, FM and $
+// D and G always stand for '.' and ','
+const (
+	FormatNumberFuncName = "_runtime.formatNumber"
+	ParseNumberFuncName  = "_runtime.parseNumber"
+	NumberFormatTypeName = "_runtime._numberFormat"
+
+	parseNumberFormatFuncName = "_runtime._parseNumberFormat"
+
+	InvalidNumberFormatMessage = "ORA-01481: invalid number format model"
+)
+
+// the fields of a '_runtime._numberFormat'
+const (
+	// the number of digits left of the decimal point
+	formatIntDigits = iota
+	// the number of digits right of the decimal point
+	formatFracDigits
+	// the 1-based position of the first '0' left of the decimal point or 0
+	// all digits from there on are printed even if they are leading zeros
+	formatZeroFrom
+	// the position of the last '0' right of the decimal point or 0
+	// FM drops trailing zeros after it
+	formatLastFracZero
+	formatFillMode
+	formatDollar
+	formatSignMode
+)
+
+// where the sign of a number goes
+const (
+	// '-' or a blank left of the first digit
+	signDefault = iota
+	// S at the start, '+' or '-' left of the first digit
+	signLeading
+	// S at the end, '+' or '-' after the last digit
+	signTrailing
+	// MI at the end, '-' or a blank after the last digit
+	signMinus
+)
+
+var numberFormatType types.Type
+
+func generateNumberFormats(mod *ir.Module) {
+	t := types.NewStruct(types.I64, types.I64, types.I64, types.I64, types.I1, types.I1, types.I64)
+	t.SetName(NumberFormatTypeName)
+	numberFormatType = mod.NewTypeDef(NumberFormatTypeName, t)
+
+	generate_parseNumberFormat(mod)
+	generateFormatNumber(mod)
+	generateParseNumber(mod)
+}
+
+func charConstant(c byte) constant.Constant {
+	return constant.NewInt(types.I8, int64(c))
+}
+
+// generate_parseNumberFormat analyzes a format model
+// invalid format models raise an error
+func generate_parseNumberFormat(mod *ir.Module) {
+	toupper := getFuncByName("toupper", mod)
+	invalidFormat := NewConstantString(mod, "_runtime.msg.invalid_number_format", InvalidNumberFormatMessage)
+
+	format := ir.NewParam("format", StringType)
+	f := mod.NewFunc(parseNumberFormatFuncName, numberFormatType, format)
+	entry := f.NewBlock("entry")
+	prefixBlock := f.NewBlock("prefix")
+	fillModeBlock := f.NewBlock("fill-mode")
+	checkBlock := f.NewBlock("check")
+	elementBlock := f.NewBlock("element")
+	digitBlock := f.NewBlock("digit")
+	intDigitBlock := f.NewBlock("int-digit")
+	fracDigitBlock := f.NewBlock("frac-digit")
+	decimalBlock := f.NewBlock("decimal")
+	firstDecimalBlock := f.NewBlock("first-decimal")
+	dollarBlock := f.NewBlock("dollar")
+	signBlock := f.NewBlock("sign")
+	minusBlock := f.NewBlock("minus")
+	minusEndBlock := f.NewBlock("minus-end")
+	minusSetBlock := f.NewBlock("minus-set")
+	nextBlock := f.NewBlock("next")
+	doneBlock := f.NewBlock("done")
+	errorBlock := f.NewBlock("error")
+
+	data := entry.NewExtractValue(format, 0)
+	len := entry.NewExtractValue(format, 1)
+	upperAt := func(b *ir.Block, i value.Value) value.Value {
+		c := b.NewZExt(b.NewLoad(b.NewGetElementPtr(data, i)), types.I32)
+		return b.NewTrunc(b.NewCall(toupper, c), types.I8)
+	}
+	newVar := func(t types.Type, init value.Value) *ir.InstAlloca {
+		v := entry.NewAlloca(t)
+		entry.NewStore(init, v)
+		return v
+	}
+
+	idx := newVar(types.I64, llvmZeroI64)
+	start := newVar(types.I64, llvmZeroI64)
+	intDigits := newVar(types.I64, llvmZeroI64)
+	fracDigits := newVar(types.I64, llvmZeroI64)
+	zeroFrom := newVar(types.I64, llvmZeroI64)
+	lastFracZero := newVar(types.I64, llvmZeroI64)
+	seenDecimal := newVar(types.I1, constant.False)
+	fillMode := newVar(types.I1, constant.False)
+	dollar := newVar(types.I1, constant.False)
+	signMode := newVar(types.I64, constant.NewInt(types.I64, signDefault))
+	entry.NewCondBr(entry.NewICmp(enum.IPredSGE, len, constant.NewInt(types.I64, 2)), prefixBlock, checkBlock)
+
+	// FM can only be the first element
+	isF := prefixBlock.NewICmp(enum.IPredEQ, upperAt(prefixBlock, llvmZeroI64), charConstant('F'))
+	isM := prefixBlock.NewICmp(enum.IPredEQ, upperAt(prefixBlock, llvmOneI64), charConstant('M'))
+	prefixBlock.NewCondBr(prefixBlock.NewAnd(isF, isM), fillModeBlock, checkBlock)
+
+	two := constant.NewInt(types.I64, 2)
+	fillModeBlock.NewStore(constant.True, fillMode)
+	fillModeBlock.NewStore(two, idx)
+	fillModeBlock.NewStore(two, start)
+	fillModeBlock.NewBr(checkBlock)
+
+	i := checkBlock.NewLoad(idx)
+	checkBlock.NewCondBr(checkBlock.NewICmp(enum.IPredSLT, i, len), elementBlock, doneBlock)
+
+	c := upperAt(elementBlock, i)
+	elementBlock.NewSwitch(c, errorBlock,
+		ir.NewCase(charConstant('9'), digitBlock),
+		ir.NewCase(charConstant('0'), digitBlock),
+		ir.NewCase(charConstant('.'), decimalBlock),
+		ir.NewCase(charConstant('D'), decimalBlock),
+		ir.NewCase(charConstant(','), nextBlock),
+		ir.NewCase(charConstant('G'), nextBlock),
+		ir.NewCase(charConstant('$'), dollarBlock),
+		ir.NewCase(charConstant('S'), signBlock),
+		ir.NewCase(charConstant('M'), minusBlock),
+	)
+
+	isZero := digitBlock.NewICmp(enum.IPredEQ, c, charConstant('0'))
+	digitBlock.NewCondBr(digitBlock.NewLoad(seenDecimal), fracDigitBlock, intDigitBlock)
+
+	n := intDigitBlock.NewAdd(intDigitBlock.NewLoad(intDigits), llvmOneI64)
+	intDigitBlock.NewStore(n, intDigits)
+	oldZeroFrom := intDigitBlock.NewLoad(zeroFrom)
+	isFirstZero := intDigitBlock.NewAnd(isZero, intDigitBlock.NewICmp(enum.IPredEQ, oldZeroFrom, llvmZeroI64))
+	intDigitBlock.NewStore(intDigitBlock.NewSelect(isFirstZero, n, oldZeroFrom), zeroFrom)
+	intDigitBlock.NewBr(nextBlock)
+
+	n = fracDigitBlock.NewAdd(fracDigitBlock.NewLoad(fracDigits), llvmOneI64)
+	fracDigitBlock.NewStore(n, fracDigits)
+	fracDigitBlock.NewStore(fracDigitBlock.NewSelect(isZero, n, fracDigitBlock.NewLoad(lastFracZero)), lastFracZero)
+	fracDigitBlock.NewBr(nextBlock)
+
+	decimalBlock.NewCondBr(decimalBlock.NewLoad(seenDecimal), errorBlock, firstDecimalBlock)
+	firstDecimalBlock.NewStore(constant.True, seenDecimal)
+	firstDecimalBlock.NewBr(nextBlock)
+
+	dollarBlock.NewStore(constant.True, dollar)
+	dollarBlock.NewBr(nextBlock)
+
+	// S goes either at the start or at the end
+	isFirst := signBlock.NewICmp(enum.IPredEQ, i, signBlock.NewLoad(start))
+	isLast := signBlock.NewICmp(enum.IPredEQ, signBlock.NewAdd(i, llvmOneI64), len)
+	leadingOrTrailing := signBlock.NewSelect(isFirst, constant.NewInt(types.I64, signLeading), constant.NewInt(types.I64, signTrailing))
+	signBlock.NewStore(leadingOrTrailing, signMode)
+	signBlock.NewCondBr(signBlock.NewOr(isFirst, isLast), nextBlock, errorBlock)
+
+	// MI goes at the end
+	next := minusBlock.NewAdd(i, llvmOneI64)
+	minusBlock.NewCondBr(minusBlock.NewICmp(enum.IPredSLT, next, len), minusEndBlock, errorBlock)
+	isI := minusEndBlock.NewICmp(enum.IPredEQ, upperAt(minusEndBlock, next), charConstant('I'))
+	isEnd := minusEndBlock.NewICmp(enum.IPredEQ, minusEndBlock.NewAdd(next, llvmOneI64), len)
+	minusEndBlock.NewCondBr(minusEndBlock.NewAnd(isI, isEnd), minusSetBlock, errorBlock)
+	minusSetBlock.NewStore(constant.NewInt(types.I64, signMinus), signMode)
+	minusSetBlock.NewStore(next, idx)
+	minusSetBlock.NewBr(nextBlock)
+
+	nextBlock.NewStore(nextBlock.NewAdd(nextBlock.NewLoad(idx), llvmOneI64), idx)
+	nextBlock.NewBr(checkBlock)
+
+	var nf value.Value = constant.NewUndef(numberFormatType)
+	for field, v := range []*ir.InstAlloca{intDigits, fracDigits, zeroFrom, lastFracZero, fillMode, dollar, signMode} {
+		nf = doneBlock.NewInsertValue(nf, doneBlock.NewLoad(v), uint64(field))
+	}
+	doneBlock.NewRet(nf)
+
+	Raise(mod, errorBlock, invalidFormat)
+}
+
+// generateFormatNumber formats a number according to a format model
+// numbers with more digits left of the decimal point than the format model allows are shown as '#'s
+func generateFormatNumber(mod *ir.Module) {
+	parseFormat := getFuncByName(parseNumberFormatFuncName, mod)
+	toupper := getFuncByName("toupper", mod)
+	malloc := getFuncByName("malloc", mod)
+	free := getFuncByName("free", mod)
+	memcpy := getFuncByName("memcpy", mod)
+	memset := getFuncByName("memset", mod)
+	snprintf := getFuncByName("snprintf", mod)
+	pow := getFuncByName("pow", mod)
+	round := getFuncByName("round", mod)
+	fabs := getFuncByName("fabs", mod)
+	digitsFormat := newConstantCString(mod, "_runtime.format.digits", "%.*f")
+
+	x := ir.NewParam("x", types.Double)
+	format := ir.NewParam("format", StringType)
+	f := mod.NewFunc(FormatNumberFuncName, StringType, x, format)
+	entry := f.NewBlock("entry")
+	checkBlock := f.NewBlock("check")
+	elementBlock := f.NewBlock("element")
+	digitBlock := f.NewBlock("digit")
+	intDigitBlock := f.NewBlock("int-digit")
+	fracDigitBlock := f.NewBlock("frac-digit")
+	decimalBlock := f.NewBlock("decimal")
+	groupBlock := f.NewBlock("group")
+	skipBlock := f.NewBlock("skip")
+	emitBlock := f.NewBlock("emit")
+	nextBlock := f.NewBlock("next")
+	trimCheckBlock := f.NewBlock("trim-check")
+	trimCharBlock := f.NewBlock("trim-char")
+	trimBlock := f.NewBlock("trim")
+	blankCheckBlock := f.NewBlock("blank-check")
+	blankCharBlock := f.NewBlock("blank-char")
+	blankBlock := f.NewBlock("blank")
+	buildBlock := f.NewBlock("build")
+	overflowBlock := f.NewBlock("overflow")
+	doneBlock := f.NewBlock("done")
+
+	nf := entry.NewCall(parseFormat, format)
+	intDigits := entry.NewExtractValue(nf, formatIntDigits)
+	fracDigits := entry.NewExtractValue(nf, formatFracDigits)
+	zeroFrom := entry.NewExtractValue(nf, formatZeroFrom)
+	lastFracZero := entry.NewExtractValue(nf, formatLastFracZero)
+	fillMode := entry.NewExtractValue(nf, formatFillMode)
+	dollar := entry.NewExtractValue(nf, formatDollar)
+	signMode := entry.NewExtractValue(nf, formatSignMode)
+
+	// round the decimal digits the way Oracle does (halves away from zero) before printing them
+	scale := entry.NewCall(pow, llvmTenDouble, entry.NewSIToFP(fracDigits, types.Double))
+	scaled := entry.NewCall(getFuncByName(NormalizeNumberFuncName, mod), entry.NewFMul(entry.NewCall(fabs, x), scale))
+	rounded := entry.NewFDiv(entry.NewCall(round, scaled), scale)
+	isNegative := entry.NewAnd(entry.NewFCmp(enum.FPredOLT, x, llvmZeroDouble), entry.NewFCmp(enum.FPredONE, rounded, llvmZeroDouble))
+	precision := entry.NewTrunc(fracDigits, types.I32)
+	n := entry.NewSExt(entry.NewCall(snprintf, constant.NewNull(i8Ptr), llvmZeroI64, digitsFormat, precision, rounded), types.I64)
+	digits := entry.NewCall(malloc, entry.NewAdd(n, llvmOneI64))
+	entry.NewCall(snprintf, digits, entry.NewAdd(n, llvmOneI64), digitsFormat, precision, rounded)
+
+	// '0.5' has no digits left of the decimal point, unless there aren't any right of it
+	hasFraction := entry.NewICmp(enum.IPredSGT, fracDigits, llvmZeroI64)
+	intLen := entry.NewSelect(hasFraction, entry.NewSub(entry.NewSub(n, fracDigits), llvmOneI64), n)
+	fracStr := entry.NewGetElementPtr(digits, entry.NewAdd(intLen, llvmOneI64))
+	isZeroInt := entry.NewAnd(entry.NewICmp(enum.IPredEQ, intLen, llvmOneI64), entry.NewICmp(enum.IPredEQ, entry.NewLoad(digits), charConstant('0')))
+	intLen = entry.NewSelect(entry.NewAnd(isZeroInt, hasFraction), llvmZeroI64, intLen)
+	overflow := entry.NewICmp(enum.IPredSGT, intLen, intDigits)
+
+	formatData := entry.NewExtractValue(format, 0)
+	formatLen := entry.NewExtractValue(format, 1)
+	// every element results in at most one character
+	body := entry.NewCall(malloc, formatLen)
+	newVar := func(t types.Type, init value.Value) *ir.InstAlloca {
+		v := entry.NewAlloca(t)
+		entry.NewStore(init, v)
+		return v
+	}
+	idx := newVar(types.I64, entry.NewSelect(fillMode, constant.NewInt(types.I64, 2), llvmZeroI64))
+	intIdx := newVar(types.I64, llvmZeroI64)
+	fracIdx := newVar(types.I64, llvmZeroI64)
+	started := newVar(types.I1, constant.False)
+	seenDecimal := newVar(types.I1, constant.False)
+	bodyLen := newVar(types.I64, llvmZeroI64)
+	entry.NewBr(checkBlock)
+
+	i := checkBlock.NewLoad(idx)
+	checkBlock.NewCondBr(checkBlock.NewICmp(enum.IPredSLT, i, formatLen), elementBlock, trimCheckBlock)
+
+	c := elementBlock.NewCall(toupper, elementBlock.NewZExt(elementBlock.NewLoad(elementBlock.NewGetElementPtr(formatData, i)), types.I32))
+	elementBlock.NewSwitch(elementBlock.NewTrunc(c, types.I8), nextBlock,
+		ir.NewCase(charConstant('9'), digitBlock),
+		ir.NewCase(charConstant('0'), digitBlock),
+		ir.NewCase(charConstant('.'), decimalBlock),
+		ir.NewCase(charConstant('D'), decimalBlock),
+		ir.NewCase(charConstant(','), groupBlock),
+		ir.NewCase(charConstant('G'), groupBlock),
+		ir.NewCase(charConstant('M'), skipBlock),
+	)
+
+	digitBlock.NewCondBr(digitBlock.NewLoad(seenDecimal), fracDigitBlock, intDigitBlock)
+
+	// digits are right-aligned, k is the position of the element counted from the decimal point
+	ii := intDigitBlock.NewAdd(intDigitBlock.NewLoad(intIdx), llvmOneI64)
+	intDigitBlock.NewStore(ii, intIdx)
+	k := intDigitBlock.NewAdd(intDigitBlock.NewSub(intDigits, ii), llvmOneI64)
+	hasDigit := intDigitBlock.NewICmp(enum.IPredSLE, k, intLen)
+	digitIdx := intDigitBlock.NewSelect(hasDigit, intDigitBlock.NewSub(intLen, k), llvmZeroI64)
+	digitChar := intDigitBlock.NewLoad(intDigitBlock.NewGetElementPtr(digits, digitIdx))
+	isForcedZero := intDigitBlock.NewAnd(intDigitBlock.NewICmp(enum.IPredNE, zeroFrom, llvmZeroI64), intDigitBlock.NewICmp(enum.IPredSGE, ii, zeroFrom))
+	padChar := intDigitBlock.NewSelect(isForcedZero, charConstant('0'), charConstant(' '))
+	intChar := intDigitBlock.NewSelect(hasDigit, digitChar, padChar)
+	isStarted := intDigitBlock.NewOr(intDigitBlock.NewLoad(started), intDigitBlock.NewOr(hasDigit, isForcedZero))
+	intDigitBlock.NewStore(isStarted, started)
+	intDigitBlock.NewBr(emitBlock)
+
+	fi := fracDigitBlock.NewLoad(fracIdx)
+	fracChar := fracDigitBlock.NewLoad(fracDigitBlock.NewGetElementPtr(fracStr, fi))
+	fracDigitBlock.NewStore(fracDigitBlock.NewAdd(fi, llvmOneI64), fracIdx)
+	fracDigitBlock.NewBr(emitBlock)
+
+	decimalBlock.NewStore(constant.True, seenDecimal)
+	decimalBlock.NewBr(emitBlock)
+
+	// group separators left of the first digit are blanks
+	groupChar := groupBlock.NewSelect(groupBlock.NewLoad(started), charConstant(','), charConstant(' '))
+	groupBlock.NewBr(emitBlock)
+
+	// the I of MI
+	skipBlock.NewStore(skipBlock.NewAdd(i, llvmOneI64), idx)
+	skipBlock.NewBr(nextBlock)
+
+	ch := emitBlock.NewPhi(
+		ir.NewIncoming(intChar, intDigitBlock),
+		ir.NewIncoming(fracChar, fracDigitBlock),
+		ir.NewIncoming(charConstant('.'), decimalBlock),
+		ir.NewIncoming(groupChar, groupBlock),
+	)
+	bl := emitBlock.NewLoad(bodyLen)
+	emitBlock.NewStore(ch, emitBlock.NewGetElementPtr(body, bl))
+	emitBlock.NewStore(emitBlock.NewAdd(bl, llvmOneI64), bodyLen)
+	emitBlock.NewBr(nextBlock)
+
+	nextBlock.NewStore(nextBlock.NewAdd(nextBlock.NewLoad(idx), llvmOneI64), idx)
+	nextBlock.NewBr(checkBlock)
+
+	// FM drops the trailing zeros of the fraction that aren't there because of a '0'
+	trimCheckBlock.NewCall(free, digits)
+	trimmable := trimCheckBlock.NewSelect(fillMode, trimCheckBlock.NewSub(fracDigits, lastFracZero), llvmZeroI64)
+	toTrim := trimCheckBlock.NewAlloca(types.I64)
+	trimCheckBlock.NewStore(trimmable, toTrim)
+	trimCheckBlock.NewBr(trimCharBlock)
+
+	left := trimCharBlock.NewLoad(toTrim)
+	trimCharBlock.NewCondBr(trimCharBlock.NewICmp(enum.IPredSGT, left, llvmZeroI64), trimBlock, blankCheckBlock)
+
+	end := trimBlock.NewLoad(bodyLen)
+	last := trimBlock.NewSub(end, llvmOneI64)
+	isTrailingZero := trimBlock.NewICmp(enum.IPredEQ, trimBlock.NewLoad(trimBlock.NewGetElementPtr(body, last)), charConstant('0'))
+	trimBlock.NewStore(trimBlock.NewSelect(isTrailingZero, last, end), bodyLen)
+	trimBlock.NewStore(trimBlock.NewSelect(isTrailingZero, trimBlock.NewSub(left, llvmOneI64), llvmZeroI64), toTrim)
+	trimBlock.NewBr(trimCharBlock)
+
+	// the sign and the currency symbol go right before the first digit
+	first := blankCheckBlock.NewAlloca(types.I64)
+	blankCheckBlock.NewStore(llvmZeroI64, first)
+	blankCheckBlock.NewBr(blankCharBlock)
+
+	fn := blankCharBlock.NewLoad(first)
+	inBody := blankCharBlock.NewICmp(enum.IPredSLT, fn, blankCharBlock.NewLoad(bodyLen))
+	blankCharBlock.NewCondBr(inBody, blankBlock, buildBlock)
+
+	isBlank := blankBlock.NewICmp(enum.IPredEQ, blankBlock.NewLoad(blankBlock.NewGetElementPtr(body, fn)), charConstant(' '))
+	blankBlock.NewStore(blankBlock.NewAdd(fn, llvmOneI64), first)
+	nextChar := f.NewBlock("next-char")
+	blankBlock.NewCondBr(isBlank, nextChar, buildBlock)
+	nextChar.NewBr(blankCharBlock)
+
+	b := buildBlock
+	firstDigit := b.NewPhi(ir.NewIncoming(fn, blankCharBlock), ir.NewIncoming(fn, blankBlock))
+	bodyEnd := b.NewLoad(bodyLen)
+	out := b.NewCall(getFuncByName(AllocStringFuncName, mod), b.NewAdd(bodyEnd, constant.NewInt(types.I64, 3)))
+	// FM drops the leading blanks
+	blanks := b.NewSelect(fillMode, llvmZeroI64, firstDigit)
+	b.NewCall(memcpy, out, body, blanks)
+	var pos value.Value = blanks
+
+	isMode := func(mode int64) value.Value {
+		return b.NewICmp(enum.IPredEQ, signMode, constant.NewInt(types.I64, mode))
+	}
+	minusOr := func(c byte) value.Value {
+		return b.NewSelect(isNegative, charConstant('-'), charConstant(c))
+	}
+	// characters are always written but only kept if pos moves on
+	write := func(c value.Value, keep value.Value) {
+		b.NewStore(c, b.NewGetElementPtr(out, pos))
+		pos = b.NewAdd(pos, b.NewZExt(keep, types.I64))
+	}
+
+	blankSign := b.NewOr(isNegative, b.NewXor(fillMode, constant.True))
+	leadingSign := b.NewSelect(isMode(signLeading), minusOr('+'), minusOr(' '))
+	write(leadingSign, b.NewOr(isMode(signLeading), b.NewAnd(isMode(signDefault), blankSign)))
+	write(charConstant('$'), dollar)
+
+	digitsLen := b.NewSub(bodyEnd, firstDigit)
+	b.NewCall(memcpy, b.NewGetElementPtr(out, pos), b.NewGetElementPtr(body, firstDigit), digitsLen)
+	pos = b.NewAdd(pos, digitsLen)
+
+	trailingSign := b.NewSelect(isMode(signTrailing), minusOr('+'), minusOr(' '))
+	write(trailingSign, b.NewOr(isMode(signTrailing), b.NewAnd(isMode(signMinus), blankSign)))
+	b.NewCall(free, body)
+	b.NewCondBr(overflow, overflowBlock, doneBlock)
+
+	overflowBlock.NewCall(memset, out, constant.NewInt(types.I32, '#'), pos)
+	overflowBlock.NewBr(doneBlock)
+
+	doneBlock.NewRet(newString(doneBlock, out, pos))
+}
+
+// generateParseNumber converts a string into a number according to a format model
+// group separators and currency symbols are skipped and the sign can be anywhere
+func generateParseNumber(mod *ir.Module) {
+	parseFormat := getFuncByName(parseNumberFormatFuncName, mod)
+	strToNumber := getFuncByName(StringToNumberFuncName, mod)
+	malloc := getFuncByName("malloc", mod)
+	free := getFuncByName("free", mod)
+
+	s := ir.NewParam("s", StringType)
+	format := ir.NewParam("format", StringType)
+	f := mod.NewFunc(ParseNumberFuncName, types.Double, s, format)
+	entry := f.NewBlock("entry")
+	checkBlock := f.NewBlock("check")
+	elementBlock := f.NewBlock("element")
+	minusBlock := f.NewBlock("minus")
+	copyBlock := f.NewBlock("copy")
+	nextBlock := f.NewBlock("next")
+	finishBlock := f.NewBlock("finish")
+	parseBlock := f.NewBlock("parse")
+	errorBlock := f.NewBlock("error")
+
+	nf := entry.NewCall(parseFormat, format)
+	intDigits := entry.NewExtractValue(nf, formatIntDigits)
+	data := entry.NewExtractValue(s, 0)
+	len := entry.NewExtractValue(s, 1)
+	buf := entry.NewCall(malloc, entry.NewAdd(len, llvmOneI64))
+	newVar := func(t types.Type, init value.Value) *ir.InstAlloca {
+		v := entry.NewAlloca(t)
+		entry.NewStore(init, v)
+		return v
+	}
+	idx := newVar(types.I64, llvmZeroI64)
+	bufLen := newVar(types.I64, llvmZeroI64)
+	isNegative := newVar(types.I1, constant.False)
+	intCount := newVar(types.I64, llvmZeroI64)
+	seenDecimal := newVar(types.I1, constant.False)
+	entry.NewBr(checkBlock)
+
+	i := checkBlock.NewLoad(idx)
+	checkBlock.NewCondBr(checkBlock.NewICmp(enum.IPredSLT, i, len), elementBlock, finishBlock)
+
+	c := elementBlock.NewLoad(elementBlock.NewGetElementPtr(data, i))
+	elementBlock.NewSwitch(c, copyBlock,
+		ir.NewCase(charConstant(','), nextBlock),
+		ir.NewCase(charConstant('$'), nextBlock),
+		ir.NewCase(charConstant('+'), nextBlock),
+		ir.NewCase(charConstant('-'), minusBlock),
+	)
+
+	minusBlock.NewStore(constant.True, isNegative)
+	minusBlock.NewBr(nextBlock)
+
+	bl := copyBlock.NewLoad(bufLen)
+	copyBlock.NewStore(c, copyBlock.NewGetElementPtr(buf, bl))
+	copyBlock.NewStore(copyBlock.NewAdd(bl, llvmOneI64), bufLen)
+	isDigit := copyBlock.NewAnd(copyBlock.NewICmp(enum.IPredUGE, c, charConstant('0')), copyBlock.NewICmp(enum.IPredULE, c, charConstant('9')))
+	decimal := copyBlock.NewLoad(seenDecimal)
+	isIntDigit := copyBlock.NewAnd(isDigit, copyBlock.NewXor(decimal, constant.True))
+	copyBlock.NewStore(copyBlock.NewAdd(copyBlock.NewLoad(intCount), copyBlock.NewZExt(isIntDigit, types.I64)), intCount)
+	copyBlock.NewStore(copyBlock.NewOr(decimal, copyBlock.NewICmp(enum.IPredEQ, c, charConstant('.'))), seenDecimal)
+	copyBlock.NewBr(nextBlock)
+
+	nextBlock.NewStore(nextBlock.NewAdd(i, llvmOneI64), idx)
+	nextBlock.NewBr(checkBlock)
+
+	// the format model limits the number of digits left of the decimal point
+	tooLong := finishBlock.NewICmp(enum.IPredSGT, finishBlock.NewLoad(intCount), intDigits)
+	finishBlock.NewCondBr(tooLong, errorBlock, parseBlock)
+
+	d := parseBlock.NewCall(strToNumber, newString(parseBlock, buf, parseBlock.NewLoad(bufLen)))
+	parseBlock.NewCall(free, buf)
+	negated := parseBlock.NewFSub(llvmZeroDouble, d)
+	parseBlock.NewRet(parseBlock.NewSelect(parseBlock.NewLoad(isNegative), negated, d))
+
+	Raise(mod, errorBlock, valueError(mod))
+}
@@ -0,0 +1,307 @@
+/*
+ * Copyright 2019 Marco Helmich
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package runtime
+
+import (
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// the runtime implementations of the built-in numeric functions of PL/SQL
+// NUMBER and BINARY_DOUBLE share most of them, the ones that are simple enough
+// are called in the math library directly
+const (
+	AbsFuncName           = "fabs"
+	PowerFuncName         = "pow"
+	RemainderFuncName     = "remainder"
+	CeilFuncName          = "ceil"
+	FloorFuncName         = "floor"
+	ExpFuncName           = "exp"
+	LnDoubleFuncName      = "log"
+	SqrtDoubleFuncName    = "sqrt"
+	GreatestFuncName      = "fmax"
+	LeastFuncName         = "fmin"
+	AbsIntFuncName        = "_runtime.absInt"
+	ModIntFuncName        = "_runtime.modInt"
+	ModFuncName           = "_runtime.mod"
+	RoundIntFuncName      = "_runtime.roundInt"
+	RoundNumberFuncName   = "_runtime.roundNumber"
+	RoundDoubleFuncName   = "_runtime.roundDouble"
+	TruncIntFuncName      = "_runtime.truncInt"
+	TruncNumberFuncName   = "_runtime.truncNumber"
+	TruncDoubleFuncName   = "_runtime.truncDouble"
+	CeilNumberFuncName    = "_runtime.ceilNumber"
+	FloorNumberFuncName   = "_runtime.floorNumber"
+	IntIdentityFuncName   = "_runtime.intIdentity"
+	SignIntFuncName       = "_runtime.signInt"
+	SignFuncName          = "_runtime.sign"
+	GreatestIntFuncName   = "_runtime.greatestInt"
+	LeastIntFuncName      = "_runtime.leastInt"
+	SqrtNumberFuncName    = "_runtime.sqrtNumber"
+	LnNumberFuncName      = "_runtime.lnNumber"
+	LogNumberFuncName     = "_runtime.logNumber"
+	LogDoubleFuncName     = "_runtime.logDouble"
+	powerOfTenIntFuncName = "_runtime._powerOfTenInt"
+)
+
+var (
+	llvmZeroDouble = constant.NewFloat(types.Double, 0)
+	llvmOneDouble  = constant.NewFloat(types.Double, 1)
+	llvmTenDouble  = constant.NewFloat(types.Double, 10)
+)
+
+// declareLibm declares the functions of the math library the runtime is built on
+func declareLibm(mod *ir.Module) {
+	for _, name := range []string{"fabs", "floor", "ceil", "trunc", "round", "rint", "sqrt", "exp", "log"} {
+		mod.NewFunc(name, types.Double, ir.NewParam("x", types.Double))
+	}
+	for _, name := range []string{"fmod", "remainder", "pow", "fmax", "fmin"} {
+		mod.NewFunc(name, types.Double, ir.NewParam("x", types.Double), ir.NewParam("y", types.Double))
+	}
+}
+
+func generateNumericFunctions(mod *ir.Module) {
+	generate_powerOfTenInt(mod)
+
+	generateAbsInt(mod)
+	generateModInt(mod)
+	generateMod(mod)
+	generateRoundInt(mod)
+	generateScaled(mod, RoundNumberFuncName, "round", true)
+	generateScaled(mod, RoundDoubleFuncName, "rint", false)
+	generateTruncInt(mod)
+	generateScaled(mod, TruncNumberFuncName, "trunc", true)
+	generateScaled(mod, TruncDoubleFuncName, "trunc", false)
+	generateDecimal(mod, CeilNumberFuncName, "ceil")
+	generateDecimal(mod, FloorNumberFuncName, "floor")
+	generateIntIdentity(mod)
+	generateSignInt(mod)
+	generateSign(mod)
+	generateIntSelection(mod, GreatestIntFuncName, enum.IPredSGT)
+	generateIntSelection(mod, LeastIntFuncName, enum.IPredSLT)
+	generateCheckedUnary(mod, SqrtNumberFuncName, "sqrt", enum.FPredOLT)
+	generateCheckedUnary(mod, LnNumberFuncName, "log", enum.FPredOLE)
+	generateLog(mod, LogNumberFuncName, true)
+	generateLog(mod, LogDoubleFuncName, false)
+}
+
+func generateAbsInt(mod *ir.Module) {
+	i := ir.NewParam("i", types.I64)
+	f := mod.NewFunc(AbsIntFuncName, types.I64, i)
+	entry := f.NewBlock("entry")
+	isNegative := entry.NewICmp(enum.IPredSLT, i, llvmZeroI64)
+	entry.NewRet(entry.NewSelect(isNegative, entry.NewSub(llvmZeroI64, i), i))
+}
+
+// generateModInt returns the remainder of m divided by n, it has the sign of m
+// MOD(m, 0) is m
+func generateModInt(mod *ir.Module) {
+	m := ir.NewParam("m", types.I64)
+	n := ir.NewParam("n", types.I64)
+	f := mod.NewFunc(ModIntFuncName, types.I64, m, n)
+	entry := f.NewBlock("entry")
+	divideBlock := f.NewBlock("divide")
+	zeroBlock := f.NewBlock("zero")
+
+	entry.NewCondBr(entry.NewICmp(enum.IPredEQ, n, llvmZeroI64), zeroBlock, divideBlock)
+	divideBlock.NewRet(divideBlock.NewSRem(m, n))
+	zeroBlock.NewRet(m)
+}
+
+// generateMod returns the remainder of m divided by n, it has the sign of m
+// MOD(m, 0) is m
+func generateMod(mod *ir.Module) {
+	fmod := getFuncByName("fmod", mod)
+
+	m := ir.NewParam("m", types.Double)
+	n := ir.NewParam("n", types.Double)
+	f := mod.NewFunc(ModFuncName, types.Double, m, n)
+	entry := f.NewBlock("entry")
+	divideBlock := f.NewBlock("divide")
+	zeroBlock := f.NewBlock("zero")
+
+	entry.NewCondBr(entry.NewFCmp(enum.FPredOEQ, n, llvmZeroDouble), zeroBlock, divideBlock)
+	divideBlock.NewRet(divideBlock.NewCall(fmod, m, n))
+	zeroBlock.NewRet(m)
+}
+
+// generate_powerOfTenInt returns 10 to the power of n
+func generate_powerOfTenInt(mod *ir.Module) {
+	pow := getFuncByName("pow", mod)
+
+	n := ir.NewParam("n", types.I64)
+	f := mod.NewFunc(powerOfTenIntFuncName, types.I64, n)
+	entry := f.NewBlock("entry")
+	p := entry.NewCall(pow, llvmTenDouble, entry.NewSIToFP(n, types.Double))
+	entry.NewRet(entry.NewFPToSI(p, types.I64))
+}
+
+// generateRoundInt rounds an integer to d digits left of the decimal point if d is negative
+// halves are rounded away from zero
+func generateRoundInt(mod *ir.Module) {
+	powerOfTen := getFuncByName(powerOfTenIntFuncName, mod)
+
+	i := ir.NewParam("i", types.I64)
+	d := ir.NewParam("d", types.I64)
+	f := mod.NewFunc(RoundIntFuncName, types.I64, i, d)
+	entry := f.NewBlock("entry")
+	roundBlock := f.NewBlock("round")
+	unchangedBlock := f.NewBlock("unchanged")
+
+	entry.NewCondBr(entry.NewICmp(enum.IPredSGE, d, llvmZeroI64), unchangedBlock, roundBlock)
+	unchangedBlock.NewRet(i)
+
+	p := roundBlock.NewCall(powerOfTen, roundBlock.NewSub(llvmZeroI64, d))
+	half := roundBlock.NewSDiv(p, constant.NewInt(types.I64, 2))
+	isNegative := roundBlock.NewICmp(enum.IPredSLT, i, llvmZeroI64)
+	shifted := roundBlock.NewSelect(isNegative, roundBlock.NewSub(i, half), roundBlock.NewAdd(i, half))
+	roundBlock.NewRet(roundBlock.NewMul(roundBlock.NewSDiv(shifted, p), p))
+}
+
+// generateTruncInt cuts an integer off at d digits left of the decimal point if d is negative
+func generateTruncInt(mod *ir.Module) {
+	powerOfTen := getFuncByName(powerOfTenIntFuncName, mod)
+
+	i := ir.NewParam("i", types.I64)
+	d := ir.NewParam("d", types.I64)
+	f := mod.NewFunc(TruncIntFuncName, types.I64, i, d)
+	entry := f.NewBlock("entry")
+	truncBlock := f.NewBlock("trunc")
+	unchangedBlock := f.NewBlock("unchanged")
+
+	entry.NewCondBr(entry.NewICmp(enum.IPredSGE, d, llvmZeroI64), unchangedBlock, truncBlock)
+	unchangedBlock.NewRet(i)
+
+	p := truncBlock.NewCall(powerOfTen, truncBlock.NewSub(llvmZeroI64, d))
+	truncBlock.NewRet(truncBlock.NewMul(truncBlock.NewSDiv(i, p), p))
+}
+
+// generateScaled applies the math library function name to x scaled by 10 to the power of d
+// that way ROUND and TRUNC work on d digits right of the decimal point
+// (or left of it if d is negative)
+// NUMBERs are rounded to their decimal digits after scaling, 1.005 is 100.49999999999999 scaled as a double
+func generateScaled(mod *ir.Module, funcName string, name string, decimal bool) {
+	pow := getFuncByName("pow", mod)
+	fn := getFuncByName(name, mod)
+
+	x := ir.NewParam("x", types.Double)
+	d := ir.NewParam("d", types.I64)
+	f := mod.NewFunc(funcName, types.Double, x, d)
+	entry := f.NewBlock("entry")
+	p := entry.NewCall(pow, llvmTenDouble, entry.NewSIToFP(d, types.Double))
+	var scaled value.Value = entry.NewFMul(x, p)
+	if decimal {
+		scaled = entry.NewCall(getFuncByName(NormalizeNumberFuncName, mod), scaled)
+	}
+	entry.NewRet(entry.NewFDiv(entry.NewCall(fn, scaled), p))
+}
+
+// generateDecimal applies the math library function name to the decimal digits of a NUMBER
+// FLOOR(0.3 / 0.1) is 3 even though the double is 2.9999999999999996
+func generateDecimal(mod *ir.Module, funcName string, name string) {
+	normalize := getFuncByName(NormalizeNumberFuncName, mod)
+	fn := getFuncByName(name, mod)
+
+	x := ir.NewParam("x", types.Double)
+	f := mod.NewFunc(funcName, types.Double, x)
+	entry := f.NewBlock("entry")
+	entry.NewRet(entry.NewCall(fn, entry.NewCall(normalize, x)))
+}
+
+// generateIntIdentity returns its argument, CEIL and FLOOR of an integer are the integer itself
+func generateIntIdentity(mod *ir.Module) {
+	i := ir.NewParam("i", types.I64)
+	f := mod.NewFunc(IntIdentityFuncName, types.I64, i)
+	entry := f.NewBlock("entry")
+	entry.NewRet(i)
+}
+
+func generateSignInt(mod *ir.Module) {
+	i := ir.NewParam("i", types.I64)
+	f := mod.NewFunc(SignIntFuncName, types.I64, i)
+	entry := f.NewBlock("entry")
+	isPositive := entry.NewZExt(entry.NewICmp(enum.IPredSGT, i, llvmZeroI64), types.I64)
+	isNegative := entry.NewZExt(entry.NewICmp(enum.IPredSLT, i, llvmZeroI64), types.I64)
+	entry.NewRet(entry.NewSub(isPositive, isNegative))
+}
+
+func generateSign(mod *ir.Module) {
+	x := ir.NewParam("x", types.Double)
+	f := mod.NewFunc(SignFuncName, types.I64, x)
+	entry := f.NewBlock("entry")
+	isPositive := entry.NewZExt(entry.NewFCmp(enum.FPredOGT, x, llvmZeroDouble), types.I64)
+	isNegative := entry.NewZExt(entry.NewFCmp(enum.FPredOLT, x, llvmZeroDouble), types.I64)
+	entry.NewRet(entry.NewSub(isPositive, isNegative))
+}
+
+// generateIntSelection returns a if it compares to b with pred and b otherwise
+func generateIntSelection(mod *ir.Module, name string, pred enum.IPred) {
+	a := ir.NewParam("a", types.I64)
+	b := ir.NewParam("b", types.I64)
+	f := mod.NewFunc(name, types.I64, a, b)
+	entry := f.NewBlock("entry")
+	entry.NewRet(entry.NewSelect(entry.NewICmp(pred, a, b), a, b))
+}
+
+// generateCheckedUnary calls the math library function name
+// but raises an error for arguments that compare to 0 with pred
+// NUMBER doesn't know NaN and infinity like BINARY_DOUBLE does
+func generateCheckedUnary(mod *ir.Module, funcName string, name string, pred enum.FPred) {
+	fn := getFuncByName(name, mod)
+
+	x := ir.NewParam("x", types.Double)
+	f := mod.NewFunc(funcName, types.Double, x)
+	entry := f.NewBlock("entry")
+	okBlock := f.NewBlock("ok")
+	errorBlock := f.NewBlock("error")
+
+	entry.NewCondBr(entry.NewFCmp(pred, x, llvmZeroDouble), errorBlock, okBlock)
+	okBlock.NewRet(okBlock.NewCall(fn, x))
+	raiseOutOfRange(mod, errorBlock)
+}
+
+// generateLog returns the logarithm of x to the base b
+// for NUMBER an error is raised if the base isn't positive or 1 or if x isn't positive
+func generateLog(mod *ir.Module, funcName string, checked bool) {
+	log := getFuncByName("log", mod)
+
+	base := ir.NewParam("b", types.Double)
+	x := ir.NewParam("x", types.Double)
+	f := mod.NewFunc(funcName, types.Double, base, x)
+	entry := f.NewBlock("entry")
+	logBlock := f.NewBlock("log")
+
+	if checked {
+		errorBlock := f.NewBlock("error")
+		var invalid value.Value = entry.NewFCmp(enum.FPredOLE, base, llvmZeroDouble)
+		invalid = entry.NewOr(invalid, entry.NewFCmp(enum.FPredOEQ, base, llvmOneDouble))
+		invalid = entry.NewOr(invalid, entry.NewFCmp(enum.FPredOLE, x, llvmZeroDouble))
+		entry.NewCondBr(invalid, errorBlock, logBlock)
+		raiseOutOfRange(mod, errorBlock)
+	} else {
+		entry.NewBr(logBlock)
+	}
+
+	logBlock.NewRet(logBlock.NewFDiv(logBlock.NewCall(log, x), logBlock.NewCall(log, base)))
+}
+
+func raiseOutOfRange(mod *ir.Module, b *ir.Block) {
+	Raise(mod, b, argumentOutOfRange(mod))
+}
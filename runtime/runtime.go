@@ -18,6 +18,7 @@ package runtime
 
 import (
 	"log"
+	"strconv"
 
 	"github.com/llir/llvm/ir"
 	"github.com/llir/llvm/ir/constant"
@@ -32,6 +33,7 @@ const (
 
 const (
 	StringTypeName = "_runtime._string"
+	CursorTypeName = "_runtime._cursor"
 
 	EqualStringFuncName      = "_runtime._equalStr"
 	PrintIntFuncName         = "_runtime.printInt"
@@ -48,6 +50,8 @@ var (
 
 	StringType        types.Type
 	StringPointerType types.Type
+	CursorType        types.Type
+	CollectionType    types.Type
 
 	EqualStringFunc *ir.Func
 	PrintIntFunc    *ir.Func
@@ -56,6 +60,8 @@ var (
 
 func GenerateInModule(mod *ir.Module) {
 	mod.NewFunc("putchar", types.I32, ir.NewParam("c", types.I8))
+	declareLibc(mod)
+	declareLibm(mod)
 	mod.NewGlobalDef("_runtime.digits", constant.NewCharArrayFromString(digits))
 
 	stringStruct := types.NewStruct(types.NewPointer(types.I8), types.I64)
@@ -63,17 +69,53 @@ func GenerateInModule(mod *ir.Module) {
 	StringType = mod.NewTypeDef(StringTypeName, stringStruct)
 	StringPointerType = types.NewPointer(StringType)
 
+	// the state of an explicit cursor, a cursor that has never been opened is all zeros
+	// {statement, rows fetched, last fetch found a row, is open, all rows have been fetched}
+	cursorStruct := types.NewStruct(types.NewPointer(types.I8), types.I64, types.I1, types.I1, types.I1)
+	cursorStruct.SetName(CursorTypeName)
+	CursorType = mod.NewTypeDef(CursorTypeName, cursorStruct)
+
+	// the elements of a collection, a collection variable that is null is empty
+	// {number of elements, elements there is memory for, size of a nested table or varray, indexes, values}
+	collectionStruct := types.NewStruct(types.I64, types.I64, types.I64, types.NewPointer(types.I64), types.NewPointer(types.I8))
+	collectionStruct.SetName(CollectionTypeName)
+	CollectionType = mod.NewTypeDef(CollectionTypeName, collectionStruct)
+
+	generateDbmsOutputBuffer(mod)
 	generate_printInt(mod)
 	generateprintInt(mod)
 	generateprintStr(mod)
 	generate_equalStr(mod)
+	generate_raise(mod)
+	generateHeap(mod)
+	generateConversions(mod)
+	generateStrings(mod)
+	generateStringFunctions(mod)
+	generateNumericFunctions(mod)
+	generateNumberFormats(mod)
+	generateDates(mod)
+	generateDateFormats(mod)
+	generateCollections(mod)
+	generateDbmsOutput(mod)
+	generateSqlCursor(mod)
 }
 
-func GenerateMain(mod *ir.Module) {
-	userMain := getFuncByName("MAIN.MAIN", mod)
+// GenerateMain creates the entry point of the program
+// it runs the initialization sections of all packages before calling the entry function
+func GenerateMain(mod *ir.Module, entryFuncName string, initFuncNames []string) {
+	userMain := getFuncByName(entryFuncName, mod)
 	main := mod.NewFunc("main", types.I32)
 	b := main.NewBlock("plsql-main")
+	for idx := range initFuncNames {
+		b.NewCall(getFuncByName(initFuncNames[idx], mod))
+	}
 	b.NewCall(userMain)
+	if UsesSQL(mod) {
+		// a program that ends without an error commits its changes
+		b.NewCall(getFuncByName(SqlCommitFuncName, mod))
+	}
+	// DBMS_OUTPUT is shown once the program is done
+	b.NewCall(getFuncByName(dbmsOutputFlushFuncName, mod))
 	b.NewRet(constant.NewInt(types.I32, 0))
 }
 
@@ -87,23 +129,23 @@ func generateTestMain(mod *ir.Module) {
 	bmain.NewCall(printInt, constant.NewInt(types.I64, 5432))
 	bmain.NewCall(printInt, constant.NewInt(types.I64, 10))
 
-	strStruct := makeStringWithAlloca("\nHello World!\n", bmain)
+	strStruct := makeStringWithAlloca("\nHello World!\n", bmain, mod)
 	bmain.NewCall(printStr, bmain.NewLoad(strStruct))
 
-	s1 := bmain.NewLoad(makeStringWithAlloca("narf", bmain))
-	s2 := bmain.NewLoad(makeStringWithAlloca("moep", bmain))
+	s1 := bmain.NewLoad(makeStringWithAlloca("narf", bmain, mod))
+	s2 := bmain.NewLoad(makeStringWithAlloca("moep", bmain, mod))
 	equalI1 := bmain.NewCall(equalStr, s1, s2)
 	equalI64 := bmain.NewZExt(equalI1, types.I64)
 	bmain.NewCall(printInt, equalI64)
 
-	s1 = bmain.NewLoad(makeStringWithAlloca("narf", bmain))
-	s2 = bmain.NewLoad(makeStringWithAlloca("MrMoep", bmain))
+	s1 = bmain.NewLoad(makeStringWithAlloca("narf", bmain, mod))
+	s2 = bmain.NewLoad(makeStringWithAlloca("MrMoep", bmain, mod))
 	equalI1 = bmain.NewCall(equalStr, s1, s2)
 	equalI64 = bmain.NewZExt(equalI1, types.I64)
 	bmain.NewCall(printInt, equalI64)
 
-	s1 = bmain.NewLoad(makeStringWithAlloca("narf", bmain))
-	s2 = bmain.NewLoad(makeStringWithAlloca("narf", bmain))
+	s1 = bmain.NewLoad(makeStringWithAlloca("narf", bmain, mod))
+	s2 = bmain.NewLoad(makeStringWithAlloca("narf", bmain, mod))
 	equalI1 = bmain.NewCall(equalStr, s1, s2)
 	equalI64 = bmain.NewZExt(equalI1, types.I64)
 	bmain.NewCall(printInt, equalI64)
@@ -111,21 +153,30 @@ func generateTestMain(mod *ir.Module) {
 	bmain.NewRet(constant.NewInt(types.I32, 0))
 }
 
-func makeStringWithAlloca(s string, b *ir.Block) value.Named {
+func makeStringWithAlloca(s string, b *ir.Block, mod *ir.Module) value.Value {
 	strStruct := b.NewAlloca(StringType)
-	return MakeString(s, b, strStruct)
+	return MakeString(mod, s, b, strStruct)
 }
 
-func MakeString(s string, b *ir.Block, strStruct *ir.InstAlloca) value.Named {
+var literalCounter int64
+
+// NewLiteralData puts the characters of a literal into a global and returns a pointer to them
+// literals never change, that way they are still valid after the function returned
+func NewLiteralData(mod *ir.Module, s string) constant.Constant {
+	n := "_literal." + strconv.FormatInt(literalCounter, 10)
+	literalCounter++
+	data := mod.NewGlobalDef(n, constant.NewCharArrayFromString(s))
+	data.Immutable = true
+	return constant.NewGetElementPtr(data, llvmZeroI32, llvmZeroI32)
+}
+
+// MakeString stores the literal s into the string strStruct points to
+// all strings that are created at runtime get their characters from the heap
+func MakeString(mod *ir.Module, s string, b *ir.Block, strStruct value.Value) value.Value {
 	dataPtr := b.NewGetElementPtr(strStruct, llvmZeroI32, llvmZeroI32)
 	lenPtr := b.NewGetElementPtr(strStruct, llvmZeroI32, llvmOneI32)
 	b.NewStore(constant.NewInt(types.I64, int64(len(s))), lenPtr)
-
-	a := b.NewAlloca(types.NewArray(uint64(len(s)), types.I8))
-	b.NewStore(constant.NewCharArrayFromString(s), a)
-	strPtr := b.NewBitCast(a, types.NewPointer(types.I8))
-	b.NewStore(strPtr, dataPtr)
-
+	b.NewStore(NewLiteralData(mod, s), dataPtr)
 	return strStruct
 }
 
@@ -197,6 +248,8 @@ func generateprintInt(mod *ir.Module) {
 	input := ir.NewParam("input", types.I64)
 	printInt := mod.NewFunc(PrintIntFuncName, types.Void, input)
 	entry := printInt.NewBlock("entry")
+	// the lines DBMS_OUTPUT collected so far come first
+	entry.NewCall(getFuncByName(dbmsOutputFlushFuncName, mod))
 
 	alloca := entry.NewAlloca(types.I64)
 	cmp := entry.NewICmp(enum.IPredSGT, constant.NewInt(types.I64, 0), input)
@@ -231,6 +284,8 @@ func generateprintStr(mod *ir.Module) {
 
 	i := entryBB.NewAlloca(types.I64)
 	entryBB.NewStore(llvmZeroI64, i)
+	// the lines DBMS_OUTPUT collected so far come first
+	entryBB.NewCall(getFuncByName(dbmsOutputFlushFuncName, mod))
 	str := entryBB.NewExtractValue(strInput, 0)
 	len := entryBB.NewExtractValue(strInput, 1)
 	cmp := entryBB.NewICmp(enum.IPredSLT, entryBB.NewLoad(i), len)
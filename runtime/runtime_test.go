@@ -46,6 +46,7 @@ func TestBasic(t *testing.T) {
 		"-Wno-override-module", // Disable override target triple warnings
 		"-o", "runtime",        // Output path
 		"-O3",
+		"-lm", // the numeric functions are built on the math library
 	}
 	cmd := exec.Command("clang", clangArgs...)
 	output, err := cmd.CombinedOutput()
@@ -65,3 +66,24 @@ func TestBasic(t *testing.T) {
 	fmt.Println(soutput)
 	assert.Equal(t, basicOutput, soutput)
 }
+
+func TestDrivers(t *testing.T) {
+	program := ir.NewModule()
+	driverFuncs(program)
+	for _, name := range []string{SqliteDriver, StubDriver} {
+		assert.True(t, IsBuiltinDriver(name), name)
+		mod := GenerateDriver(name)
+		assert.NotNil(t, mod, name)
+		// a driver defines every function of the interface
+		for idx := range program.Funcs {
+			f := getFuncByName(program.Funcs[idx].Name(), mod)
+			assert.True(t, len(f.Blocks) > 0, "%s doesn't define %s", name, f.Name())
+		}
+	}
+
+	assert.True(t, IsBuiltinDriver(SqliteDriver))
+	assert.False(t, IsBuiltinDriver("./driver.o"))
+	assert.Nil(t, GenerateDriver("./driver.o"))
+	assert.Equal(t, []string{"sqlite3"}, DriverLibraries(SqliteDriver))
+	assert.Nil(t, DriverLibraries(StubDriver))
+}
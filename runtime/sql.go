@@ -0,0 +1,684 @@
+/*
+ * Copyright 2019 Marco Helmich
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package runtime
+
+import (
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// embedded SQL runs through the driver the program is linked with, see driver.go
+// the connection is opened by the first statement, see transactions.go for when its changes are committed
+const (
+	SqlPrepareFuncName      = "_runtime.sql.prepare"
+	SqlBindIntFuncName      = "_runtime.sql.bindInt"
+	SqlBindNumberFuncName   = "_runtime.sql.bindNumber"
+	SqlBindStringFuncName   = "_runtime.sql.bindString"
+	SqlExecuteFuncName      = "_runtime.sql.execute"
+	SqlFetchOneFuncName     = "_runtime.sql.fetchOne"
+	SqlFetchDoneFuncName    = "_runtime.sql.fetchDone"
+	SqlColumnIntFuncName    = "_runtime.sql.columnInt"
+	SqlColumnNumberFuncName = "_runtime.sql.columnNumber"
+	SqlColumnStringFuncName = "_runtime.sql.columnString"
+
+	SqlOpenFuncName           = "_runtime.sql.open"
+	SqlFetchFuncName          = "_runtime.sql.fetch"
+	SqlCloseFuncName          = "_runtime.sql.close"
+	SqlCursorFoundFuncName    = "_runtime.sql.cursorFound"
+	SqlCursorRowcountFuncName = "_runtime.sql.cursorRowcount"
+	SqlCursorIsOpenFuncName   = "_runtime.sql.cursorIsOpen"
+	SqlOpenRefFuncName        = "_runtime.sql.openRef"
+	SqlCheckBindsFuncName     = "_runtime.sql.checkBinds"
+	SqlCheckColumnsFuncName   = "_runtime.sql.checkColumns"
+	SqlNextRowFuncName        = "_runtime.sql.nextRow"
+
+	SqlRunFuncName          = "_runtime.sql.run"
+	SqlFinalizeFuncName     = "_runtime.sql.finalize"
+	SqlBulkStartFuncName    = "_runtime.sql.bulkStart"
+	SqlBulkSetFuncName      = "_runtime.sql.bulkSet"
+	SqlBulkRowcountFuncName = "_runtime.sql.bulkRowcount"
+	SqlRunSavingFuncName    = "_runtime.sql.runSaving"
+	SqlRaiseSavedFuncName   = "_runtime.sql.raiseSaved"
+
+	sqlConnectFuncName = "_runtime._sqlConnect"
+	sqlOpenFuncName    = "_runtime._sqlOpen"
+	sqlStepFuncName    = "_runtime._sqlStep"
+	sqlRaiseFuncName   = "_runtime._sqlRaise"
+	sqlMessageFuncName = "_runtime._sqlMessage"
+	sqlFormatFuncName  = "_runtime._sqlFormat"
+	checkOpenFuncName  = "_runtime._checkOpen"
+
+	// the number of rows the last statement touched, the implicit cursor SQL is derived from it
+	SqlRowcountName = "_runtime.sql.rowcount"
+	// the current connection, autonomous transactions replace it with one of their own while they run
+	sqlDbName = "_runtime.sql.db"
+	// whether a caller of the current autonomous transaction holds the lock to write, the transaction can't get it
+	sqlWaitsForCallerName = "_runtime.sql.waitsForCaller"
+	// the rows every execution of the last FORALL touched, SQL%BULK_ROWCOUNT is derived from them
+	sqlBulkRowcountsName = "_runtime.sql.bulkRowcounts"
+	sqlBulkLowerName     = "_runtime.sql.bulkLower"
+	sqlBulkCountName     = "_runtime.sql.bulkCount"
+	// the errors a FORALL with SAVE EXCEPTIONS saved, only the first one is kept
+	sqlBulkErrorsName       = "_runtime.sql.bulkErrors"
+	sqlBulkErrorIndexName   = "_runtime.sql.bulkErrorIndex"
+	sqlBulkErrorMessageName = "_runtime.sql.bulkErrorMessage"
+
+	// the environment variable that names the database file
+	DatabaseEnvVar = "PLSQLC_DB"
+
+	NoDatabaseMessage        = "ORA-12154: TNS:could not resolve the connect identifier specified, " + DatabaseEnvVar + " is not set"
+	ConnectFailedMessage     = "ORA-12154: TNS:could not resolve the connect identifier specified, %s"
+	InvalidSqlMessage        = "ORA-00900: invalid SQL statement, %s"
+	UniqueConstraintMessage  = "ORA-00001: unique constraint violated, %s"
+	CannotInsertNullMessage  = "ORA-01400: cannot insert NULL, %s"
+	SqlInternalErrorMessage  = "ORA-00600: internal error code, %s"
+	ResourceBusyMessage      = "ORA-00054: resource busy and acquire with NOWAIT specified or timeout expired, %s"
+	DeadlockMessage          = "ORA-00060: deadlock detected while waiting for resource, %s"
+	NoDataFoundMessage       = "ORA-01403: no data found"
+	TooManyRowsMessage       = "ORA-01422: exact fetch returns more than requested number of rows"
+	NotEnoughValuesMessage   = "ORA-00947: not enough values"
+	TooManyValuesMessage     = "ORA-00913: too many values"
+	CursorAlreadyOpenMessage = "ORA-06511: PL/SQL: cursor already open"
+	InvalidCursorMessage     = "ORA-01001: invalid cursor"
+	EmptySqlMessage          = "ORA-00900: invalid SQL statement, the statement is empty"
+	NotAllBoundMessage       = "ORA-01008: not all variables bound"
+	NoSuchBindMessage        = "ORA-01006: bind variable does not exist"
+	ArrayDmlErrorsMessage    = "ORA-24381: error(s) in array DML, %lld error(s), the first for index %lld: %.*s"
+
+	sqlErrorMessageMaxLength = 512
+	// milliseconds statements wait for the locks of other programs
+	sqlBusyTimeout = 5000
+)
+
+// generateSqlCursor creates the attributes of the implicit cursor
+// they exist in every program, whether it uses SQL or not
+func generateSqlCursor(mod *ir.Module) {
+	mod.NewGlobalDef(SqlRowcountName, llvmZeroI64)
+}
+
+// UsesSQL returns true if the program contains embedded SQL and needs to be linked against SQLite
+func UsesSQL(mod *ir.Module) bool {
+	for idx := range mod.Funcs {
+		if mod.Funcs[idx].Name() == SqlPrepareFuncName {
+			return true
+		}
+	}
+	return false
+}
+
+// GenerateSQLInModule creates the functions embedded SQL is executed with
+// they are only generated for the first statement that needs them
+func GenerateSQLInModule(mod *ir.Module) {
+	if UsesSQL(mod) {
+		return
+	}
+
+	declareDriver(mod)
+	mod.NewGlobalDef(sqlDbName, constant.NewNull(i8Ptr))
+	mod.NewGlobalDef(sqlWaitsForCallerName, constant.False)
+	mod.NewGlobalDef(sqlBulkErrorsName, llvmZeroI64)
+	mod.NewGlobalDef(sqlBulkErrorIndexName, llvmZeroI64)
+	mod.NewGlobalDef(sqlBulkErrorMessageName, constant.NewZeroInitializer(StringType))
+	generate_sqlRaise(mod)
+	generate_sqlExec(mod)
+	generate_sqlDisconnect(mod)
+	generate_sqlConnect(mod)
+	generate_sqlStep(mod)
+	generateSqlPrepare(mod)
+	generateSqlBind(mod)
+	generateSqlExecute(mod)
+	generateSqlFetch(mod)
+	generateSqlRows(mod)
+	generateSqlColumns(mod)
+	generateSqlCursors(mod)
+	generateSqlBulk(mod)
+	generateSqlTransactions(mod)
+}
+
+// declareDriver declares the driver interface and the parts of libc the connection needs
+func declareDriver(mod *ir.Module) {
+	driverFuncs(mod)
+	mod.NewFunc("atexit", types.I32, ir.NewParam("f", types.NewPointer(types.NewFunc(types.Void))))
+}
+
+func i32Constant(n int64) constant.Constant {
+	return constant.NewInt(types.I32, n)
+}
+
+// generate_sqlRaise raises an error whose message contains the message of the database
+// the format has a single '%s' for it, sqlMessage only formats the message
+func generate_sqlRaise(mod *ir.Module) {
+	snprintf := getFuncByName("snprintf", mod)
+	allocStr := getFuncByName(AllocStringFuncName, mod)
+
+	format := ir.NewParam("format", i8Ptr)
+	f := mod.NewFunc(sqlMessageFuncName, StringType, format)
+	b := f.NewBlock("entry")
+	errmsg := b.NewCall(getFuncByName("plsqlc_db_errmsg", mod), b.NewLoad(getGlobalByName(sqlDbName, mod)))
+	size := i64Constant(sqlErrorMessageMaxLength)
+	buf := b.NewCall(allocStr, size)
+	written := b.NewSExt(b.NewCall(snprintf, buf, size, format, errmsg), types.I64)
+	// snprintf returns the length the message would have had
+	maxLen := i64Constant(sqlErrorMessageMaxLength - 1)
+	len := b.NewSelect(b.NewICmp(enum.IPredSGT, written, maxLen), maxLen, written)
+	b.NewRet(newString(b, buf, len))
+	message := f
+
+	format = ir.NewParam("format", i8Ptr)
+	f = mod.NewFunc(sqlRaiseFuncName, types.Void, format)
+	b = f.NewBlock("entry")
+	b.NewCall(getFuncByName(RaiseFuncName, mod), b.NewCall(message, format))
+	b.NewUnreachable()
+}
+
+// generate_sqlConnect returns the connection to the database
+// the file named by the environment variable has to exist already
+func generate_sqlConnect(mod *ir.Module) {
+	dbGlobal := getGlobalByName(sqlDbName, mod)
+	envVar := newConstantCString(mod, "_runtime.sql.env_var", DatabaseEnvVar)
+	noDatabase := NewConstantString(mod, "_runtime.msg.no_database", NoDatabaseMessage)
+	connectFailed := newConstantCString(mod, "_runtime.format.connect_failed", ConnectFailedMessage)
+
+	// open makes a new connection the current one, it runs in a transaction right away
+	f := mod.NewFunc(sqlOpenFuncName, i8Ptr)
+	b := f.NewBlock("entry")
+	openedBlock := f.NewBlock("opened")
+	failedBlock := f.NewBlock("failed")
+	path := b.NewCall(getFuncByName("getenv", mod), envVar)
+	b = checkOrRaise(mod, b, b.NewICmp(enum.IPredNE, path, constant.NewNull(i8Ptr)), noDatabase)
+	// an empty name would open a temporary database
+	b = checkOrRaise(mod, b, b.NewICmp(enum.IPredNE, b.NewLoad(path), constant.NewInt(types.I8, 0)), noDatabase)
+	rc := b.NewCall(getFuncByName("plsqlc_db_connect", mod), path, dbGlobal)
+	b.NewCondBr(b.NewICmp(enum.IPredEQ, rc, llvmZeroI32), openedBlock, failedBlock)
+	db := openedBlock.NewLoad(dbGlobal)
+	openedBlock.NewCall(getFuncByName("plsqlc_db_busy_timeout", mod), db, i32Constant(sqlBusyTimeout))
+	b = execOrRaise(mod, openedBlock, db, "begin")
+	b.NewRet(db)
+	failedBlock.NewCall(getFuncByName(sqlRaiseFuncName, mod), connectFailed)
+	failedBlock.NewUnreachable()
+	open := f
+
+	f = mod.NewFunc(sqlConnectFuncName, i8Ptr)
+	entry := f.NewBlock("entry")
+	openBlock := f.NewBlock("open")
+	connectedBlock := f.NewBlock("connected")
+	db = entry.NewLoad(dbGlobal)
+	entry.NewCondBr(entry.NewICmp(enum.IPredEQ, db, constant.NewNull(i8Ptr)), openBlock, connectedBlock)
+	connectedBlock.NewRet(db)
+
+	// the connection rolls back what's left of its transaction when the program exits
+	opened := openBlock.NewCall(open)
+	openBlock.NewCall(getFuncByName("atexit", mod), getFuncByName(sqlDisconnectFuncName, mod))
+	openBlock.NewRet(opened)
+}
+
+// generate_sqlStep advances a statement to its next row and returns DriverRow or DriverDone
+// everything else is raised as the Oracle error that comes closest, sqlFormat picks its format
+func generate_sqlStep(mod *ir.Module) {
+	unique := newConstantCString(mod, "_runtime.format.unique_constraint", UniqueConstraintMessage)
+	notNull := newConstantCString(mod, "_runtime.format.cannot_insert_null", CannotInsertNullMessage)
+	internalError := sharedConstantCString(mod, "_runtime.format.sql_internal_error", SqlInternalErrorMessage)
+	busy := newConstantCString(mod, "_runtime.format.resource_busy", ResourceBusyMessage)
+	deadlock := newConstantCString(mod, "_runtime.format.deadlock", DeadlockMessage)
+
+	rc := ir.NewParam("rc", types.I32)
+	f := mod.NewFunc(sqlFormatFuncName, i8Ptr, rc)
+	entry := f.NewBlock("entry")
+	uniqueBlock := f.NewBlock("unique")
+	notNullBlock := f.NewBlock("not-null")
+	busyBlock := f.NewBlock("busy")
+	otherBlock := f.NewBlock("other")
+	entry.NewSwitch(rc, otherBlock,
+		ir.NewCase(i32Constant(DriverUnique), uniqueBlock),
+		ir.NewCase(i32Constant(DriverNotNull), notNullBlock),
+		ir.NewCase(i32Constant(DriverBusy), busyBlock))
+	uniqueBlock.NewRet(unique)
+	notNullBlock.NewRet(notNull)
+	// waiting for a lock of the caller would never end, the autonomous transaction doesn't wait at all
+	busyBlock.NewRet(busyBlock.NewSelect(busyBlock.NewLoad(getGlobalByName(sqlWaitsForCallerName, mod)), deadlock, busy))
+	otherBlock.NewRet(internalError)
+	format := f
+
+	stmt := ir.NewParam("stmt", i8Ptr)
+	f = mod.NewFunc(sqlStepFuncName, types.I32, stmt)
+	entry = f.NewBlock("entry")
+	okBlock := f.NewBlock("ok")
+	errorBlock := f.NewBlock("error")
+	step := entry.NewCall(getFuncByName("plsqlc_db_step", mod), stmt)
+	entry.NewSwitch(step, errorBlock,
+		ir.NewCase(i32Constant(DriverRow), okBlock),
+		ir.NewCase(i32Constant(DriverDone), okBlock))
+	okBlock.NewRet(step)
+	errorBlock.NewCall(getFuncByName(sqlRaiseFuncName, mod), errorBlock.NewCall(format, step))
+	errorBlock.NewUnreachable()
+}
+
+// generateSqlPrepare compiles the text of a statement
+func generateSqlPrepare(mod *ir.Module) {
+	invalidSql := newConstantCString(mod, "_runtime.format.invalid_sql", InvalidSqlMessage)
+
+	sql := ir.NewParam("sql", StringType)
+	f := mod.NewFunc(SqlPrepareFuncName, i8Ptr, sql)
+	entry := f.NewBlock("entry")
+	preparedBlock := f.NewBlock("prepared")
+	errorBlock := f.NewBlock("error")
+
+	db := entry.NewCall(getFuncByName(sqlConnectFuncName, mod))
+	stmt := entry.NewAlloca(i8Ptr)
+	text := entry.NewExtractValue(sql, 0)
+	len := entry.NewTrunc(entry.NewExtractValue(sql, 1), types.I32)
+	rc := entry.NewCall(getFuncByName("plsqlc_db_prepare", mod), db, text, len, stmt)
+	entry.NewCondBr(entry.NewICmp(enum.IPredEQ, rc, llvmZeroI32), preparedBlock, errorBlock)
+
+	// text without a statement ('', '  ') compiles to nothing
+	prepared := preparedBlock.NewLoad(stmt)
+	isEmpty := preparedBlock.NewICmp(enum.IPredEQ, prepared, constant.NewNull(i8Ptr))
+	b := checkOrRaise(mod, preparedBlock, preparedBlock.NewXor(isEmpty, constant.True), NewConstantString(mod, "_runtime.msg.empty_sql", EmptySqlMessage))
+	b.NewRet(prepared)
+	errorBlock.NewCall(getFuncByName(sqlRaiseFuncName, mod), invalidSql)
+	errorBlock.NewUnreachable()
+
+	// statements that are built at runtime need to get exactly the bind arguments they have placeholders for
+	// unless the driver can't count them
+	stmtParam := ir.NewParam("stmt", i8Ptr)
+	n := ir.NewParam("n", types.I64)
+	f = mod.NewFunc(SqlCheckBindsFuncName, types.Void, stmtParam, n)
+	entry = f.NewBlock("entry")
+	b = f.NewBlock("check")
+	unknownBlock := f.NewBlock("unknown")
+	count := entry.NewSExt(entry.NewCall(getFuncByName("plsqlc_db_bind_count", mod), stmtParam), types.I64)
+	entry.NewCondBr(entry.NewICmp(enum.IPredSLT, count, llvmZeroI64), unknownBlock, b)
+	unknownBlock.NewRet(nil)
+	b = checkOrRaise(mod, b, b.NewICmp(enum.IPredSLE, count, n), NewConstantString(mod, "_runtime.msg.not_all_bound", NotAllBoundMessage))
+	b = checkOrRaise(mod, b, b.NewICmp(enum.IPredSGE, count, n), NewConstantString(mod, "_runtime.msg.no_such_bind", NoSuchBindMessage))
+	b.NewRet(nil)
+}
+
+// generateSqlBind creates the functions that bind values to the parameters of a statement
+// parameters are numbered from 1
+func generateSqlBind(mod *ir.Module) {
+	stmt := ir.NewParam("stmt", i8Ptr)
+	idx := ir.NewParam("idx", types.I32)
+	v := ir.NewParam("v", types.I64)
+	f := mod.NewFunc(SqlBindIntFuncName, types.Void, stmt, idx, v)
+	b := f.NewBlock("entry")
+	b.NewCall(getFuncByName("plsqlc_db_bind_int", mod), stmt, idx, v)
+	b.NewRet(nil)
+
+	stmt = ir.NewParam("stmt", i8Ptr)
+	idx = ir.NewParam("idx", types.I32)
+	v = ir.NewParam("v", types.Double)
+	f = mod.NewFunc(SqlBindNumberFuncName, types.Void, stmt, idx, v)
+	b = f.NewBlock("entry")
+	b.NewCall(getFuncByName("plsqlc_db_bind_double", mod), stmt, idx, v)
+	b.NewRet(nil)
+
+	stmt = ir.NewParam("stmt", i8Ptr)
+	idx = ir.NewParam("idx", types.I32)
+	v = ir.NewParam("v", StringType)
+	f = mod.NewFunc(SqlBindStringFuncName, types.Void, stmt, idx, v)
+	b = f.NewBlock("entry")
+	len := b.NewTrunc(b.NewExtractValue(v, 1), types.I32)
+	b.NewCall(getFuncByName("plsqlc_db_bind_text", mod), stmt, idx, b.NewExtractValue(v, 0), len)
+	b.NewRet(nil)
+}
+
+// generateSqlExecute runs a statement that doesn't return rows to the end
+// and returns the number of rows it inserted, updated or deleted
+// run leaves the statement ready to be bound and run again, execute finalizes it
+func generateSqlExecute(mod *ir.Module) {
+	stmt := ir.NewParam("stmt", i8Ptr)
+	f := mod.NewFunc(SqlRunFuncName, types.I64, stmt)
+	entry := f.NewBlock("entry")
+	stepBlock := f.NewBlock("step")
+	doneBlock := f.NewBlock("done")
+
+	entry.NewBr(stepBlock)
+	rc := stepBlock.NewCall(getFuncByName(sqlStepFuncName, mod), stmt)
+	stepBlock.NewCondBr(stepBlock.NewICmp(enum.IPredEQ, rc, i32Constant(DriverRow)), stepBlock, doneBlock)
+
+	doneBlock.NewCall(getFuncByName("plsqlc_db_reset", mod), stmt)
+	doneBlock.NewRet(doneBlock.NewCall(getFuncByName("plsqlc_db_changes", mod), doneBlock.NewLoad(getGlobalByName(sqlDbName, mod))))
+	run := f
+
+	// a FORALL with SAVE EXCEPTIONS saves the error of a run and goes on with the next one
+	// the run didn't touch any rows, its changes are rolled back by the database
+	stmt = ir.NewParam("stmt", i8Ptr)
+	i := ir.NewParam("i", types.I64)
+	f = mod.NewFunc(SqlRunSavingFuncName, types.I64, stmt, i)
+	entry = f.NewBlock("entry")
+	stepBlock = f.NewBlock("step")
+	doneBlock = f.NewBlock("done")
+	errorBlock := f.NewBlock("error")
+	firstBlock := f.NewBlock("first")
+	savedBlock := f.NewBlock("saved")
+	entry.NewBr(stepBlock)
+	rc = stepBlock.NewCall(getFuncByName("plsqlc_db_step", mod), stmt)
+	stepBlock.NewSwitch(rc, errorBlock,
+		ir.NewCase(i32Constant(DriverRow), stepBlock),
+		ir.NewCase(i32Constant(DriverDone), doneBlock))
+
+	doneBlock.NewCall(getFuncByName("plsqlc_db_reset", mod), stmt)
+	doneBlock.NewRet(doneBlock.NewCall(getFuncByName("plsqlc_db_changes", mod), doneBlock.NewLoad(getGlobalByName(sqlDbName, mod))))
+
+	errors := getGlobalByName(sqlBulkErrorsName, mod)
+	saved := errorBlock.NewAdd(errorBlock.NewLoad(errors), llvmOneI64)
+	errorBlock.NewStore(saved, errors)
+	errorBlock.NewCondBr(errorBlock.NewICmp(enum.IPredEQ, saved, llvmOneI64), firstBlock, savedBlock)
+	message := firstBlock.NewCall(getFuncByName(sqlMessageFuncName, mod), firstBlock.NewCall(getFuncByName(sqlFormatFuncName, mod), rc))
+	firstBlock.NewStore(message, getGlobalByName(sqlBulkErrorMessageName, mod))
+	firstBlock.NewStore(i, getGlobalByName(sqlBulkErrorIndexName, mod))
+	firstBlock.NewBr(savedBlock)
+	savedBlock.NewCall(getFuncByName("plsqlc_db_reset", mod), stmt)
+	savedBlock.NewRet(llvmZeroI64)
+
+	stmt = ir.NewParam("stmt", i8Ptr)
+	f = mod.NewFunc(SqlFinalizeFuncName, types.Void, stmt)
+	b := f.NewBlock("entry")
+	b.NewCall(getFuncByName("plsqlc_db_finalize", mod), stmt)
+	b.NewRet(nil)
+
+	stmt = ir.NewParam("stmt", i8Ptr)
+	f = mod.NewFunc(SqlExecuteFuncName, types.I64, stmt)
+	b = f.NewBlock("entry")
+	n := b.NewCall(run, stmt)
+	b.NewCall(getFuncByName("plsqlc_db_finalize", mod), stmt)
+	b.NewRet(n)
+}
+
+// generateSqlBulk creates the functions that keep track of the rows every execution of a FORALL touched
+// the rows of the execution for index i are stored at i - lower
+func generateSqlBulk(mod *ir.Module) {
+	rowcounts := mod.NewGlobalDef(sqlBulkRowcountsName, constant.NewNull(i64Ptr))
+	lower := mod.NewGlobalDef(sqlBulkLowerName, llvmZeroI64)
+	count := mod.NewGlobalDef(sqlBulkCountName, llvmZeroI64)
+	errors := getGlobalByName(sqlBulkErrorsName, mod)
+
+	lo := ir.NewParam("lo", types.I64)
+	hi := ir.NewParam("hi", types.I64)
+	f := mod.NewFunc(SqlBulkStartFuncName, types.Void, lo, hi)
+	b := f.NewBlock("entry")
+	span := b.NewAdd(b.NewSub(hi, lo), llvmOneI64)
+	n := b.NewSelect(b.NewICmp(enum.IPredSLT, span, llvmZeroI64), llvmZeroI64, span)
+	// realloc doesn't need to allocate anything for 0 bytes
+	size := b.NewMul(b.NewAdd(n, llvmOneI64), i64Constant(8))
+	old := b.NewBitCast(b.NewLoad(rowcounts), i8Ptr)
+	b.NewStore(b.NewBitCast(b.NewCall(getFuncByName("realloc", mod), old, size), i64Ptr), rowcounts)
+	b.NewStore(lo, lower)
+	b.NewStore(n, count)
+	b.NewStore(llvmZeroI64, errors)
+	b.NewRet(nil)
+
+	i := ir.NewParam("i", types.I64)
+	rows := ir.NewParam("rows", types.I64)
+	f = mod.NewFunc(SqlBulkSetFuncName, types.Void, i, rows)
+	b = f.NewBlock("entry")
+	b.NewStore(rows, b.NewGetElementPtr(b.NewLoad(rowcounts), b.NewSub(i, b.NewLoad(lower))))
+	b.NewRet(nil)
+
+	// there are only rows for the indexes of the last FORALL
+	i = ir.NewParam("i", types.I64)
+	f = mod.NewFunc(SqlBulkRowcountFuncName, types.I64, i)
+	b = f.NewBlock("entry")
+	offset := b.NewSub(i, b.NewLoad(lower))
+	isValid := b.NewAnd(b.NewICmp(enum.IPredSGE, offset, llvmZeroI64), b.NewICmp(enum.IPredSLT, offset, b.NewLoad(count)))
+	b = checkOrRaise(mod, b, isValid, sharedConstantString(mod, "_runtime.msg.no_data_found", NoDataFoundMessage))
+	b.NewRet(b.NewLoad(b.NewGetElementPtr(b.NewLoad(rowcounts), offset)))
+
+	// there are no exception handlers, the errors a FORALL saved are raised once it is done
+	f = mod.NewFunc(SqlRaiseSavedFuncName, types.Void)
+	b = f.NewBlock("entry")
+	raiseBlock := f.NewBlock("raise")
+	doneBlock := f.NewBlock("done")
+	saved := b.NewLoad(errors)
+	b.NewCondBr(b.NewICmp(enum.IPredSGT, saved, llvmZeroI64), raiseBlock, doneBlock)
+	doneBlock.NewRet(nil)
+
+	b = raiseBlock
+	format := newConstantCString(mod, "_runtime.format.array_dml_errors", ArrayDmlErrorsMessage)
+	bufSize := i64Constant(sqlErrorMessageMaxLength)
+	buf := b.NewCall(getFuncByName(AllocStringFuncName, mod), bufSize)
+	message := b.NewLoad(getGlobalByName(sqlBulkErrorMessageName, mod))
+	messageLen := b.NewTrunc(b.NewExtractValue(message, 1), types.I32)
+	written := b.NewSExt(b.NewCall(getFuncByName("snprintf", mod), buf, bufSize, format, saved, b.NewLoad(getGlobalByName(sqlBulkErrorIndexName, mod)), messageLen, b.NewExtractValue(message, 0)), types.I64)
+	maxLen := i64Constant(sqlErrorMessageMaxLength - 1)
+	len := b.NewSelect(b.NewICmp(enum.IPredSGT, written, maxLen), maxLen, written)
+	b.NewCall(getFuncByName(RaiseFuncName, mod), newString(b, buf, len))
+	b.NewUnreachable()
+}
+
+// generateSqlRows creates the functions BULK COLLECT reads all rows of a query with
+// checkColumns makes sure the query returns n columns, nextRow moves to the next row and returns false after the last one
+func generateSqlRows(mod *ir.Module) {
+	stmt := ir.NewParam("stmt", i8Ptr)
+	n := ir.NewParam("n", types.I64)
+	f := mod.NewFunc(SqlCheckColumnsFuncName, types.Void, stmt, n)
+	b := checkColumnCount(mod, f.NewBlock("entry"), stmt, n)
+	b.NewRet(nil)
+
+	stmt = ir.NewParam("stmt", i8Ptr)
+	f = mod.NewFunc(SqlNextRowFuncName, types.I1, stmt)
+	b = f.NewBlock("entry")
+	rc := b.NewCall(getFuncByName(sqlStepFuncName, mod), stmt)
+	b.NewRet(b.NewICmp(enum.IPredEQ, rc, i32Constant(DriverRow)))
+}
+
+// generateSqlFetch creates the functions that make sure a query returns exactly one row
+// fetchOne moves to the first row, fetchDone checks that there isn't a second one and finalizes the statement
+func generateSqlFetch(mod *ir.Module) {
+	step := getFuncByName(sqlStepFuncName, mod)
+	finalize := getFuncByName("plsqlc_db_finalize", mod)
+	noDataFound := sharedConstantString(mod, "_runtime.msg.no_data_found", NoDataFoundMessage)
+	tooManyRows := NewConstantString(mod, "_runtime.msg.too_many_rows", TooManyRowsMessage)
+
+	stmt := ir.NewParam("stmt", i8Ptr)
+	n := ir.NewParam("n", types.I64)
+	f := mod.NewFunc(SqlFetchOneFuncName, types.Void, stmt, n)
+	b := checkColumnCount(mod, f.NewBlock("entry"), stmt, n)
+	rc := b.NewCall(step, stmt)
+	b = checkOrRaise(mod, b, b.NewICmp(enum.IPredEQ, rc, i32Constant(DriverRow)), noDataFound)
+	b.NewRet(nil)
+
+	stmt = ir.NewParam("stmt", i8Ptr)
+	f = mod.NewFunc(SqlFetchDoneFuncName, types.Void, stmt)
+	b = f.NewBlock("entry")
+	rc = b.NewCall(step, stmt)
+	b.NewCall(finalize, stmt)
+	b = checkOrRaise(mod, b, b.NewICmp(enum.IPredEQ, rc, i32Constant(DriverDone)), tooManyRows)
+	b.NewRet(nil)
+}
+
+// checkColumnCount raises an error if a query doesn't return n columns
+func checkColumnCount(mod *ir.Module, b *ir.Block, stmt value.Value, n value.Value) *ir.Block {
+	notEnoughValues := sharedConstantString(mod, "_runtime.msg.not_enough_values", NotEnoughValuesMessage)
+	tooManyValues := sharedConstantString(mod, "_runtime.msg.too_many_values", TooManyValuesMessage)
+	count := b.NewSExt(b.NewCall(getFuncByName("plsqlc_db_column_count", mod), stmt), types.I64)
+	// drivers that don't know the columns return a negative count
+	isUnknown := b.NewICmp(enum.IPredSLT, count, llvmZeroI64)
+	b = checkOrRaise(mod, b, b.NewOr(isUnknown, b.NewICmp(enum.IPredSGE, count, n)), notEnoughValues)
+	return checkOrRaise(mod, b, b.NewICmp(enum.IPredSLE, count, n), tooManyValues)
+}
+
+// generateSqlColumns creates the functions that read a column of the current row
+// columns are numbered from 0, NULL reads as 0 or as an empty string
+func generateSqlColumns(mod *ir.Module) {
+	columnDouble := getFuncByName("plsqlc_db_column_double", mod)
+
+	// decimals are rounded like any other number that is assigned to an integer
+	stmt := ir.NewParam("stmt", i8Ptr)
+	idx := ir.NewParam("idx", types.I32)
+	f := mod.NewFunc(SqlColumnIntFuncName, types.I64, stmt, idx)
+	entry := f.NewBlock("entry")
+	floatBlock := f.NewBlock("float")
+	intBlock := f.NewBlock("int")
+	colType := entry.NewCall(getFuncByName("plsqlc_db_column_type", mod), stmt, idx)
+	entry.NewCondBr(entry.NewICmp(enum.IPredEQ, colType, i32Constant(DriverColumnReal)), floatBlock, intBlock)
+	floatBlock.NewRet(floatBlock.NewCall(getFuncByName(NumberToIntFuncName, mod), floatBlock.NewCall(columnDouble, stmt, idx)))
+	intBlock.NewRet(intBlock.NewCall(getFuncByName("plsqlc_db_column_int", mod), stmt, idx))
+
+	stmt = ir.NewParam("stmt", i8Ptr)
+	idx = ir.NewParam("idx", types.I32)
+	f = mod.NewFunc(SqlColumnNumberFuncName, types.Double, stmt, idx)
+	b := f.NewBlock("entry")
+	b.NewRet(b.NewCall(columnDouble, stmt, idx))
+
+	// the characters belong to the driver until the next step, they are copied onto the heap
+	// decimals are formatted like numbers are ('5000' instead of SQLite's '5000.0')
+	stmt = ir.NewParam("stmt", i8Ptr)
+	idx = ir.NewParam("idx", types.I32)
+	f = mod.NewFunc(SqlColumnStringFuncName, StringType, stmt, idx)
+	entry = f.NewBlock("entry")
+	floatBlock = f.NewBlock("float")
+	b = f.NewBlock("text")
+	colType = entry.NewCall(getFuncByName("plsqlc_db_column_type", mod), stmt, idx)
+	entry.NewCondBr(entry.NewICmp(enum.IPredEQ, colType, i32Constant(DriverColumnReal)), floatBlock, b)
+	floatBlock.NewRet(floatBlock.NewCall(getFuncByName(NumberToStringFuncName, mod), floatBlock.NewCall(columnDouble, stmt, idx)))
+	n := entry.NewAlloca(types.I32)
+	text := b.NewCall(getFuncByName("plsqlc_db_column_text", mod), stmt, idx, n)
+	len := b.NewSExt(b.NewLoad(n), types.I64)
+	buf := b.NewCall(getFuncByName(AllocStringFuncName, mod), len)
+	b.NewCall(getFuncByName("memcpy", mod), buf, text, len)
+	b.NewRet(newString(b, buf, len))
+}
+
+// the fields of a '_runtime._cursor'
+const (
+	cursorStmtField = iota
+	cursorRowcountField
+	cursorFoundField
+	cursorIsOpenField
+	cursorDoneField
+)
+
+// cursorField returns a pointer to a field of a cursor
+func cursorField(b *ir.Block, cursor value.Value, idx int64) value.Value {
+	return b.NewGetElementPtr(cursor, llvmZeroI32, i32Constant(idx))
+}
+
+// generateSqlCursors creates the functions explicit cursors are run with
+// a cursor owns its statement from OPEN to CLOSE
+func generateSqlCursors(mod *ir.Module) {
+	cursorPtr := types.NewPointer(CursorType)
+	invalidCursor := NewConstantString(mod, "_runtime.msg.invalid_cursor", InvalidCursorMessage)
+	alreadyOpen := NewConstantString(mod, "_runtime.msg.cursor_already_open", CursorAlreadyOpenMessage)
+
+	// only open cursors can be fetched from, closed or asked about their rows
+	// cursor variables that were never opened don't point to a cursor
+	cursor := ir.NewParam("cursor", cursorPtr)
+	f := mod.NewFunc(checkOpenFuncName, types.Void, cursor)
+	b := f.NewBlock("entry")
+	b = checkOrRaise(mod, b, b.NewICmp(enum.IPredNE, cursor, constant.NewNull(cursorPtr)), invalidCursor)
+	b = checkOrRaise(mod, b, b.NewLoad(cursorField(b, cursor, cursorIsOpenField)), invalidCursor)
+	b.NewRet(nil)
+	checkOpen := f
+
+	cursor = ir.NewParam("cursor", cursorPtr)
+	stmt := ir.NewParam("stmt", i8Ptr)
+	f = mod.NewFunc(SqlOpenFuncName, types.Void, cursor, stmt)
+	b = f.NewBlock("entry")
+	isOpen := b.NewLoad(cursorField(b, cursor, cursorIsOpenField))
+	b = checkOrRaise(mod, b, b.NewXor(isOpen, constant.True), alreadyOpen)
+	b.NewStore(stmt, cursorField(b, cursor, cursorStmtField))
+	b.NewStore(llvmZeroI64, cursorField(b, cursor, cursorRowcountField))
+	b.NewStore(constant.False, cursorField(b, cursor, cursorFoundField))
+	b.NewStore(constant.True, cursorField(b, cursor, cursorIsOpenField))
+	b.NewStore(constant.False, cursorField(b, cursor, cursorDoneField))
+	b.NewRet(nil)
+
+	// fetching from a cursor whose rows are all gone doesn't start over
+	cursor = ir.NewParam("cursor", cursorPtr)
+	n := ir.NewParam("n", types.I64)
+	f = mod.NewFunc(SqlFetchFuncName, types.I1, cursor, n)
+	entry := f.NewBlock("entry")
+	stepBlock := f.NewBlock("step")
+	rowBlock := f.NewBlock("row")
+	doneBlock := f.NewBlock("done")
+	entry.NewCall(checkOpen, cursor)
+	entry.NewCondBr(entry.NewLoad(cursorField(entry, cursor, cursorDoneField)), doneBlock, stepBlock)
+
+	s := stepBlock.NewLoad(cursorField(stepBlock, cursor, cursorStmtField))
+	b = checkColumnCount(mod, stepBlock, s, n)
+	rc := b.NewCall(getFuncByName(sqlStepFuncName, mod), s)
+	b.NewCondBr(b.NewICmp(enum.IPredEQ, rc, i32Constant(DriverRow)), rowBlock, doneBlock)
+
+	rowcount := cursorField(rowBlock, cursor, cursorRowcountField)
+	rowBlock.NewStore(rowBlock.NewAdd(rowBlock.NewLoad(rowcount), llvmOneI64), rowcount)
+	rowBlock.NewStore(constant.True, cursorField(rowBlock, cursor, cursorFoundField))
+	rowBlock.NewRet(constant.True)
+
+	doneBlock.NewStore(constant.False, cursorField(doneBlock, cursor, cursorFoundField))
+	doneBlock.NewStore(constant.True, cursorField(doneBlock, cursor, cursorDoneField))
+	doneBlock.NewRet(constant.False)
+
+	cursor = ir.NewParam("cursor", cursorPtr)
+	f = mod.NewFunc(SqlCloseFuncName, types.Void, cursor)
+	b = f.NewBlock("entry")
+	b.NewCall(checkOpen, cursor)
+	b.NewCall(getFuncByName("plsqlc_db_finalize", mod), b.NewLoad(cursorField(b, cursor, cursorStmtField)))
+	b.NewStore(constant.False, cursorField(b, cursor, cursorIsOpenField))
+	b.NewRet(nil)
+
+	cursor = ir.NewParam("cursor", cursorPtr)
+	f = mod.NewFunc(SqlCursorFoundFuncName, types.I1, cursor)
+	b = f.NewBlock("entry")
+	b.NewCall(checkOpen, cursor)
+	b.NewRet(b.NewLoad(cursorField(b, cursor, cursorFoundField)))
+
+	cursor = ir.NewParam("cursor", cursorPtr)
+	f = mod.NewFunc(SqlCursorRowcountFuncName, types.I64, cursor)
+	b = f.NewBlock("entry")
+	b.NewCall(checkOpen, cursor)
+	b.NewRet(b.NewLoad(cursorField(b, cursor, cursorRowcountField)))
+
+	cursor = ir.NewParam("cursor", cursorPtr)
+	f = mod.NewFunc(SqlCursorIsOpenFuncName, types.I1, cursor)
+	entry = f.NewBlock("entry")
+	stateBlock := f.NewBlock("state")
+	nullBlock := f.NewBlock("null")
+	entry.NewCondBr(entry.NewICmp(enum.IPredEQ, cursor, constant.NewNull(cursorPtr)), nullBlock, stateBlock)
+	nullBlock.NewRet(constant.False)
+	stateBlock.NewRet(stateBlock.NewLoad(cursorField(stateBlock, cursor, cursorIsOpenField)))
+
+	// a cursor variable gets its cursor the first time it is opened
+	// opening it again closes the statement it was opened for before
+	slot := ir.NewParam("slot", types.NewPointer(cursorPtr))
+	stmt = ir.NewParam("stmt", i8Ptr)
+	f = mod.NewFunc(SqlOpenRefFuncName, types.Void, slot, stmt)
+	entry = f.NewBlock("entry")
+	newBlock := f.NewBlock("new")
+	reopenBlock := f.NewBlock("reopen")
+	closeBlock := f.NewBlock("close")
+	openBlock := f.NewBlock("open")
+	entry.NewCondBr(entry.NewICmp(enum.IPredEQ, entry.NewLoad(slot), constant.NewNull(cursorPtr)), newBlock, reopenBlock)
+
+	size := constant.NewPtrToInt(constant.NewGetElementPtr(constant.NewNull(cursorPtr), llvmOneI32), types.I64)
+	state := newBlock.NewBitCast(newBlock.NewCall(getFuncByName("malloc", mod), size), cursorPtr)
+	newBlock.NewStore(constant.NewZeroInitializer(CursorType), state)
+	newBlock.NewStore(state, slot)
+	newBlock.NewBr(openBlock)
+
+	current := reopenBlock.NewLoad(slot)
+	reopenBlock.NewCondBr(reopenBlock.NewLoad(cursorField(reopenBlock, current, cursorIsOpenField)), closeBlock, openBlock)
+	closeBlock.NewCall(getFuncByName(SqlCloseFuncName, mod), closeBlock.NewLoad(slot))
+	closeBlock.NewBr(openBlock)
+
+	openBlock.NewCall(getFuncByName(SqlOpenFuncName, mod), openBlock.NewLoad(slot), stmt)
+	openBlock.NewRet(nil)
+}
@@ -0,0 +1,581 @@
+/*
+ * Copyright 2019 Marco Helmich
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package runtime
+
+import (
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// the runtime implementations of the built-in string functions of PL/SQL
+// positions are 1-based like in Oracle
+// there is no NULL, empty strings are treated like any other string
+const (
+	LengthFuncName    = "_runtime.length"
+	SubstrFuncName    = "_runtime.substr"
+	InstrFuncName     = "_runtime.instr"
+	UpperFuncName     = "_runtime.upper"
+	LowerFuncName     = "_runtime.lower"
+	InitcapFuncName   = "_runtime.initcap"
+	TrimFuncName      = "_runtime.trim"
+	LtrimFuncName     = "_runtime.ltrim"
+	RtrimFuncName     = "_runtime.rtrim"
+	LpadFuncName      = "_runtime.lpad"
+	RpadFuncName      = "_runtime.rpad"
+	ReplaceFuncName   = "_runtime.replace"
+	TranslateFuncName = "_runtime.translate"
+	AsciiFuncName     = "_runtime.ascii"
+	ChrFuncName       = "_runtime.chr"
+
+	indexOfFuncName     = "_runtime._indexOf"
+	lastIndexOfFuncName = "_runtime._lastIndexOf"
+	fillPatternFuncName = "_runtime._fillPattern"
+
+	ArgumentOutOfRangeMessage = "ORA-01428: argument is out of range"
+)
+
+func generateStringFunctions(mod *ir.Module) {
+	generate_indexOf(mod)
+	generate_lastIndexOf(mod)
+	generate_fillPattern(mod)
+
+	generateLength(mod)
+	generateSubstr(mod)
+	generateInstr(mod)
+	generateCharMapping(mod, UpperFuncName, func(_ *ir.Block, b *ir.Block, c value.Value) value.Value {
+		return b.NewCall(getFuncByName("toupper", mod), c)
+	})
+	generateCharMapping(mod, LowerFuncName, func(_ *ir.Block, b *ir.Block, c value.Value) value.Value {
+		return b.NewCall(getFuncByName("tolower", mod), c)
+	})
+	generateInitcap(mod)
+	generateLtrim(mod)
+	generateRtrim(mod)
+	generateTrim(mod)
+	generatePad(mod, LpadFuncName, true)
+	generatePad(mod, RpadFuncName, false)
+	generateReplace(mod)
+	generateTranslate(mod)
+	generateAscii(mod)
+	generateChr(mod)
+}
+
+// argumentOutOfRange returns the message of the error for invalid arguments
+func argumentOutOfRange(mod *ir.Module) constant.Constant {
+	return sharedConstantString(mod, "_runtime.msg.argument_out_of_range", ArgumentOutOfRangeMessage)
+}
+
+// generate_indexOf returns the 0-based position of the first occurrence of sub in s
+// that starts at or after from or -1 if there is none
+func generate_indexOf(mod *ir.Module) {
+	memcmp := getFuncByName("memcmp", mod)
+
+	s := ir.NewParam("s", StringType)
+	sub := ir.NewParam("sub", StringType)
+	from := ir.NewParam("from", types.I64)
+	f := mod.NewFunc(indexOfFuncName, types.I64, s, sub, from)
+	entry := f.NewBlock("entry")
+	checkBlock := f.NewBlock("check")
+	compareBlock := f.NewBlock("compare")
+	nextBlock := f.NewBlock("next")
+	foundBlock := f.NewBlock("found")
+	notFoundBlock := f.NewBlock("not-found")
+
+	data := entry.NewExtractValue(s, 0)
+	subData := entry.NewExtractValue(sub, 0)
+	subLen := entry.NewExtractValue(sub, 1)
+	// the last position a match can start at
+	last := entry.NewSub(entry.NewExtractValue(s, 1), subLen)
+	idx := entry.NewAlloca(types.I64)
+	entry.NewStore(from, idx)
+	entry.NewBr(checkBlock)
+
+	i := checkBlock.NewLoad(idx)
+	checkBlock.NewCondBr(checkBlock.NewICmp(enum.IPredSLE, i, last), compareBlock, notFoundBlock)
+
+	cmp := compareBlock.NewCall(memcmp, compareBlock.NewGetElementPtr(data, i), subData, subLen)
+	compareBlock.NewCondBr(compareBlock.NewICmp(enum.IPredEQ, cmp, llvmZeroI32), foundBlock, nextBlock)
+
+	nextBlock.NewStore(nextBlock.NewAdd(i, llvmOneI64), idx)
+	nextBlock.NewBr(checkBlock)
+
+	foundBlock.NewRet(i)
+	notFoundBlock.NewRet(constant.NewInt(types.I64, -1))
+}
+
+// generate_lastIndexOf returns the 0-based position of the last occurrence of sub in s
+// that starts at or before from or -1 if there is none
+func generate_lastIndexOf(mod *ir.Module) {
+	memcmp := getFuncByName("memcmp", mod)
+
+	s := ir.NewParam("s", StringType)
+	sub := ir.NewParam("sub", StringType)
+	from := ir.NewParam("from", types.I64)
+	f := mod.NewFunc(lastIndexOfFuncName, types.I64, s, sub, from)
+	entry := f.NewBlock("entry")
+	checkBlock := f.NewBlock("check")
+	compareBlock := f.NewBlock("compare")
+	nextBlock := f.NewBlock("next")
+	foundBlock := f.NewBlock("found")
+	notFoundBlock := f.NewBlock("not-found")
+
+	data := entry.NewExtractValue(s, 0)
+	subData := entry.NewExtractValue(sub, 0)
+	subLen := entry.NewExtractValue(sub, 1)
+	last := entry.NewSub(entry.NewExtractValue(s, 1), subLen)
+	// matches can't start after the last position
+	start := entry.NewSelect(entry.NewICmp(enum.IPredSLT, from, last), from, last)
+	idx := entry.NewAlloca(types.I64)
+	entry.NewStore(start, idx)
+	entry.NewBr(checkBlock)
+
+	i := checkBlock.NewLoad(idx)
+	checkBlock.NewCondBr(checkBlock.NewICmp(enum.IPredSGE, i, llvmZeroI64), compareBlock, notFoundBlock)
+
+	cmp := compareBlock.NewCall(memcmp, compareBlock.NewGetElementPtr(data, i), subData, subLen)
+	compareBlock.NewCondBr(compareBlock.NewICmp(enum.IPredEQ, cmp, llvmZeroI32), foundBlock, nextBlock)
+
+	nextBlock.NewStore(nextBlock.NewSub(i, llvmOneI64), idx)
+	nextBlock.NewBr(checkBlock)
+
+	foundBlock.NewRet(i)
+	notFoundBlock.NewRet(constant.NewInt(types.I64, -1))
+}
+
+// generate_fillPattern writes n characters to dest by repeating the characters of pattern
+func generate_fillPattern(mod *ir.Module) {
+	dest := ir.NewParam("dest", i8Ptr)
+	n := ir.NewParam("n", types.I64)
+	pattern := ir.NewParam("pattern", StringType)
+	f := mod.NewFunc(fillPatternFuncName, types.Void, dest, n, pattern)
+	entry := f.NewBlock("entry")
+	checkBlock := f.NewBlock("check")
+	fillBlock := f.NewBlock("fill")
+	doneBlock := f.NewBlock("done")
+
+	data := entry.NewExtractValue(pattern, 0)
+	len := entry.NewExtractValue(pattern, 1)
+	idx := entry.NewAlloca(types.I64)
+	entry.NewStore(llvmZeroI64, idx)
+	entry.NewBr(checkBlock)
+
+	i := checkBlock.NewLoad(idx)
+	checkBlock.NewCondBr(checkBlock.NewICmp(enum.IPredSLT, i, n), fillBlock, doneBlock)
+
+	c := fillBlock.NewLoad(fillBlock.NewGetElementPtr(data, fillBlock.NewSRem(i, len)))
+	fillBlock.NewStore(c, fillBlock.NewGetElementPtr(dest, i))
+	fillBlock.NewStore(fillBlock.NewAdd(i, llvmOneI64), idx)
+	fillBlock.NewBr(checkBlock)
+
+	doneBlock.NewRet(nil)
+}
+
+func generateLength(mod *ir.Module) {
+	s := ir.NewParam("s", StringType)
+	f := mod.NewFunc(LengthFuncName, types.I64, s)
+	entry := f.NewBlock("entry")
+	entry.NewRet(entry.NewExtractValue(s, 1))
+}
+
+// generateSubstr returns up to n characters starting at pos
+// a position of 0 is treated as 1, negative positions count backwards from the end
+// positions outside of the string and lengths smaller than 1 result in an empty string
+// the characters aren't copied, the result points into the same memory
+func generateSubstr(mod *ir.Module) {
+	s := ir.NewParam("s", StringType)
+	pos := ir.NewParam("pos", types.I64)
+	n := ir.NewParam("n", types.I64)
+	f := mod.NewFunc(SubstrFuncName, StringType, s, pos, n)
+	entry := f.NewBlock("entry")
+
+	data := entry.NewExtractValue(s, 0)
+	len := entry.NewExtractValue(s, 1)
+	p := entry.NewSelect(entry.NewICmp(enum.IPredEQ, pos, llvmZeroI64), llvmOneI64, pos)
+	fromEnd := entry.NewAdd(entry.NewAdd(len, p), llvmOneI64)
+	p = entry.NewSelect(entry.NewICmp(enum.IPredSLT, p, llvmZeroI64), fromEnd, p)
+
+	valid := entry.NewAnd(entry.NewICmp(enum.IPredSGE, p, llvmOneI64), entry.NewICmp(enum.IPredSLE, p, len))
+	valid = entry.NewAnd(valid, entry.NewICmp(enum.IPredSGE, n, llvmOneI64))
+	start := entry.NewSub(p, llvmOneI64)
+	available := entry.NewSub(len, start)
+	subLen := entry.NewSelect(entry.NewICmp(enum.IPredSLT, n, available), n, available)
+
+	resultStart := entry.NewSelect(valid, start, llvmZeroI64)
+	resultLen := entry.NewSelect(valid, subLen, llvmZeroI64)
+	entry.NewRet(newString(entry, entry.NewGetElementPtr(data, resultStart), resultLen))
+}
+
+// generateInstr returns the 1-based position of the nth occurrence of sub in s or 0
+// the search starts at pos, for negative positions it starts
+// that many characters from the end and goes backwards
+func generateInstr(mod *ir.Module) {
+	indexOf := getFuncByName(indexOfFuncName, mod)
+	lastIndexOf := getFuncByName(lastIndexOfFuncName, mod)
+
+	s := ir.NewParam("s", StringType)
+	sub := ir.NewParam("sub", StringType)
+	pos := ir.NewParam("pos", types.I64)
+	nth := ir.NewParam("nth", types.I64)
+	f := mod.NewFunc(InstrFuncName, types.I64, s, sub, pos, nth)
+	entry := f.NewBlock("entry")
+	checkBlock := f.NewBlock("check")
+	searchBlock := f.NewBlock("find")
+	forwardBlock := f.NewBlock("forward")
+	backwardBlock := f.NewBlock("backward")
+	foundBlock := f.NewBlock("found")
+	doneBlock := f.NewBlock("done")
+	notFoundBlock := f.NewBlock("not-found")
+	errorBlock := f.NewBlock("error")
+
+	entry.NewCondBr(entry.NewICmp(enum.IPredSLT, nth, llvmOneI64), errorBlock, checkBlock)
+
+	isEmpty := checkBlock.NewICmp(enum.IPredEQ, checkBlock.NewExtractValue(sub, 1), llvmZeroI64)
+	isZero := checkBlock.NewICmp(enum.IPredEQ, pos, llvmZeroI64)
+	// the 0-based position the next search starts at and the number of matches that are still missing
+	from := checkBlock.NewAlloca(types.I64)
+	backwardStart := checkBlock.NewAdd(checkBlock.NewExtractValue(s, 1), pos)
+	forwardStart := checkBlock.NewSub(pos, llvmOneI64)
+	isBackward := checkBlock.NewICmp(enum.IPredSLT, pos, llvmZeroI64)
+	checkBlock.NewStore(checkBlock.NewSelect(isBackward, backwardStart, forwardStart), from)
+	missing := checkBlock.NewAlloca(types.I64)
+	checkBlock.NewStore(nth, missing)
+	checkBlock.NewCondBr(checkBlock.NewOr(isEmpty, isZero), notFoundBlock, searchBlock)
+
+	searchBlock.NewCondBr(isBackward, backwardBlock, forwardBlock)
+
+	forwardIdx := forwardBlock.NewCall(indexOf, s, sub, forwardBlock.NewLoad(from))
+	forwardBlock.NewStore(forwardBlock.NewAdd(forwardIdx, llvmOneI64), from)
+	forwardBlock.NewBr(foundBlock)
+
+	backwardIdx := backwardBlock.NewCall(lastIndexOf, s, sub, backwardBlock.NewLoad(from))
+	backwardBlock.NewStore(backwardBlock.NewSub(backwardIdx, llvmOneI64), from)
+	backwardBlock.NewBr(foundBlock)
+
+	idx := foundBlock.NewPhi(ir.NewIncoming(forwardIdx, forwardBlock), ir.NewIncoming(backwardIdx, backwardBlock))
+	left := foundBlock.NewSub(foundBlock.NewLoad(missing), llvmOneI64)
+	foundBlock.NewStore(left, missing)
+	foundBlock.NewCondBr(foundBlock.NewICmp(enum.IPredSLT, idx, llvmZeroI64), notFoundBlock, doneBlock)
+
+	resultBlock := f.NewBlock("result")
+	doneBlock.NewCondBr(doneBlock.NewICmp(enum.IPredEQ, left, llvmZeroI64), resultBlock, searchBlock)
+	resultBlock.NewRet(resultBlock.NewAdd(idx, llvmOneI64))
+
+	notFoundBlock.NewRet(llvmZeroI64)
+	Raise(mod, errorBlock, argumentOutOfRange(mod))
+}
+
+// generateCharMapping creates a function that returns a copy of a string
+// in which every character is replaced by the result of mapChar
+// mapChar can keep state between characters in allocas of the entry block
+func generateCharMapping(mod *ir.Module, name string, mapChar func(entry *ir.Block, b *ir.Block, c value.Value) value.Value) {
+	allocStr := getFuncByName(AllocStringFuncName, mod)
+
+	s := ir.NewParam("s", StringType)
+	f := mod.NewFunc(name, StringType, s)
+	entry := f.NewBlock("entry")
+	checkBlock := f.NewBlock("check")
+	mapBlock := f.NewBlock("map")
+	doneBlock := f.NewBlock("done")
+
+	data := entry.NewExtractValue(s, 0)
+	len := entry.NewExtractValue(s, 1)
+	buf := entry.NewCall(allocStr, len)
+	idx := entry.NewAlloca(types.I64)
+	entry.NewStore(llvmZeroI64, idx)
+	entry.NewBr(checkBlock)
+
+	i := checkBlock.NewLoad(idx)
+	checkBlock.NewCondBr(checkBlock.NewICmp(enum.IPredSLT, i, len), mapBlock, doneBlock)
+
+	c := mapBlock.NewZExt(mapBlock.NewLoad(mapBlock.NewGetElementPtr(data, i)), types.I32)
+	mapped := mapChar(entry, mapBlock, c)
+	mapBlock.NewStore(mapBlock.NewTrunc(mapped, types.I8), mapBlock.NewGetElementPtr(buf, i))
+	mapBlock.NewStore(mapBlock.NewAdd(i, llvmOneI64), idx)
+	mapBlock.NewBr(checkBlock)
+
+	doneBlock.NewRet(newString(doneBlock, buf, len))
+}
+
+// generateInitcap upper-cases the first letter of every word and lower-cases all other letters
+// words are separated by characters that aren't alphanumeric
+func generateInitcap(mod *ir.Module) {
+	toupper := getFuncByName("toupper", mod)
+	tolower := getFuncByName("tolower", mod)
+	isalnum := getFuncByName("isalnum", mod)
+
+	generateCharMapping(mod, InitcapFuncName, func(entry *ir.Block, b *ir.Block, c value.Value) value.Value {
+		// whether the previous character belongs to a word
+		inWord := entry.NewAlloca(types.I1)
+		entry.NewStore(constant.False, inWord)
+		mapped := b.NewSelect(b.NewLoad(inWord), b.NewCall(tolower, c), b.NewCall(toupper, c))
+		b.NewStore(b.NewICmp(enum.IPredNE, b.NewCall(isalnum, c), llvmZeroI32), inWord)
+		return mapped
+	})
+}
+
+// generateLtrim removes all characters from the start of s that are contained in set
+// the characters aren't copied, the result points into the same memory
+func generateLtrim(mod *ir.Module) {
+	memchr := getFuncByName("memchr", mod)
+
+	s := ir.NewParam("s", StringType)
+	set := ir.NewParam("set", StringType)
+	f := mod.NewFunc(LtrimFuncName, StringType, s, set)
+	entry := f.NewBlock("entry")
+	checkBlock := f.NewBlock("check")
+	testCharBlock := f.NewBlock("test-char")
+	trimBlock := f.NewBlock("trim")
+	doneBlock := f.NewBlock("done")
+
+	data := entry.NewExtractValue(s, 0)
+	len := entry.NewExtractValue(s, 1)
+	idx := entry.NewAlloca(types.I64)
+	entry.NewStore(llvmZeroI64, idx)
+	entry.NewBr(checkBlock)
+
+	i := checkBlock.NewLoad(idx)
+	checkBlock.NewCondBr(checkBlock.NewICmp(enum.IPredSLT, i, len), testCharBlock, doneBlock)
+
+	c := testCharBlock.NewZExt(testCharBlock.NewLoad(testCharBlock.NewGetElementPtr(data, i)), types.I32)
+	found := testCharBlock.NewCall(memchr, testCharBlock.NewExtractValue(set, 0), c, testCharBlock.NewExtractValue(set, 1))
+	testCharBlock.NewCondBr(testCharBlock.NewICmp(enum.IPredEQ, found, constant.NewNull(i8Ptr)), doneBlock, trimBlock)
+
+	trimBlock.NewStore(trimBlock.NewAdd(i, llvmOneI64), idx)
+	trimBlock.NewBr(checkBlock)
+
+	start := doneBlock.NewLoad(idx)
+	doneBlock.NewRet(newString(doneBlock, doneBlock.NewGetElementPtr(data, start), doneBlock.NewSub(len, start)))
+}
+
+// generateRtrim removes all characters from the end of s that are contained in set
+// the characters aren't copied, the result points into the same memory
+func generateRtrim(mod *ir.Module) {
+	memchr := getFuncByName("memchr", mod)
+
+	s := ir.NewParam("s", StringType)
+	set := ir.NewParam("set", StringType)
+	f := mod.NewFunc(RtrimFuncName, StringType, s, set)
+	entry := f.NewBlock("entry")
+	checkBlock := f.NewBlock("check")
+	testCharBlock := f.NewBlock("test-char")
+	trimBlock := f.NewBlock("trim")
+	doneBlock := f.NewBlock("done")
+
+	data := entry.NewExtractValue(s, 0)
+	len := entry.NewAlloca(types.I64)
+	entry.NewStore(entry.NewExtractValue(s, 1), len)
+	entry.NewBr(checkBlock)
+
+	l := checkBlock.NewLoad(len)
+	checkBlock.NewCondBr(checkBlock.NewICmp(enum.IPredSGT, l, llvmZeroI64), testCharBlock, doneBlock)
+
+	last := testCharBlock.NewSub(l, llvmOneI64)
+	c := testCharBlock.NewZExt(testCharBlock.NewLoad(testCharBlock.NewGetElementPtr(data, last)), types.I32)
+	found := testCharBlock.NewCall(memchr, testCharBlock.NewExtractValue(set, 0), c, testCharBlock.NewExtractValue(set, 1))
+	testCharBlock.NewCondBr(testCharBlock.NewICmp(enum.IPredEQ, found, constant.NewNull(i8Ptr)), doneBlock, trimBlock)
+
+	trimBlock.NewStore(last, len)
+	trimBlock.NewBr(checkBlock)
+
+	doneBlock.NewRet(newString(doneBlock, data, doneBlock.NewLoad(len)))
+}
+
+// generateTrim removes leading and trailing blanks
+func generateTrim(mod *ir.Module) {
+	ltrim := getFuncByName(LtrimFuncName, mod)
+	rtrim := getFuncByName(RtrimFuncName, mod)
+	blank := NewConstantString(mod, "_runtime.blank", " ")
+
+	s := ir.NewParam("s", StringType)
+	f := mod.NewFunc(TrimFuncName, StringType, s)
+	entry := f.NewBlock("entry")
+	entry.NewRet(entry.NewCall(ltrim, entry.NewCall(rtrim, s, blank), blank))
+}
+
+// generatePad pads s on the left (LPAD) or the right (RPAD) to n characters
+// by repeating the characters of pad, strings longer than n characters are cut off after n characters
+func generatePad(mod *ir.Module, name string, left bool) {
+	allocStr := getFuncByName(AllocStringFuncName, mod)
+	memcpy := getFuncByName("memcpy", mod)
+	fillPattern := getFuncByName(fillPatternFuncName, mod)
+
+	s := ir.NewParam("s", StringType)
+	n := ir.NewParam("n", types.I64)
+	pad := ir.NewParam("pad", StringType)
+	f := mod.NewFunc(name, StringType, s, n, pad)
+	entry := f.NewBlock("entry")
+	checkPadBlock := f.NewBlock("check-pad")
+	padBlock := f.NewBlock("fill")
+	cutBlock := f.NewBlock("cut")
+	unchangedBlock := f.NewBlock("unchanged")
+
+	data := entry.NewExtractValue(s, 0)
+	len := entry.NewExtractValue(s, 1)
+	// negative lengths result in an empty string
+	size := entry.NewSelect(entry.NewICmp(enum.IPredSLT, n, llvmZeroI64), llvmZeroI64, n)
+	entry.NewCondBr(entry.NewICmp(enum.IPredSGE, len, size), cutBlock, checkPadBlock)
+
+	cutBlock.NewRet(newString(cutBlock, data, size))
+
+	// without characters to pad with the string stays the way it is
+	isEmpty := checkPadBlock.NewICmp(enum.IPredEQ, checkPadBlock.NewExtractValue(pad, 1), llvmZeroI64)
+	checkPadBlock.NewCondBr(isEmpty, unchangedBlock, padBlock)
+	unchangedBlock.NewRet(s)
+
+	buf := padBlock.NewCall(allocStr, size)
+	padLen := padBlock.NewSub(size, len)
+	if left {
+		padBlock.NewCall(fillPattern, buf, padLen, pad)
+		padBlock.NewCall(memcpy, padBlock.NewGetElementPtr(buf, padLen), data, len)
+	} else {
+		padBlock.NewCall(memcpy, buf, data, len)
+		padBlock.NewCall(fillPattern, padBlock.NewGetElementPtr(buf, len), padLen, pad)
+	}
+	padBlock.NewRet(newString(padBlock, buf, size))
+}
+
+// generateReplace replaces every occurrence of search in s with replacement
+func generateReplace(mod *ir.Module) {
+	indexOf := getFuncByName(indexOfFuncName, mod)
+	concatStr := getFuncByName(ConcatStringFuncName, mod)
+
+	s := ir.NewParam("s", StringType)
+	search := ir.NewParam("search", StringType)
+	replacement := ir.NewParam("replacement", StringType)
+	f := mod.NewFunc(ReplaceFuncName, StringType, s, search, replacement)
+	entry := f.NewBlock("entry")
+	startBlock := f.NewBlock("start")
+	searchBlock := f.NewBlock("find")
+	replaceBlock := f.NewBlock("replace")
+	doneBlock := f.NewBlock("done")
+	unchangedBlock := f.NewBlock("unchanged")
+
+	data := entry.NewExtractValue(s, 0)
+	len := entry.NewExtractValue(s, 1)
+	searchLen := entry.NewExtractValue(search, 1)
+	entry.NewCondBr(entry.NewICmp(enum.IPredEQ, searchLen, llvmZeroI64), unchangedBlock, startBlock)
+
+	unchangedBlock.NewRet(s)
+
+	// the result is appended to piece by piece
+	from := startBlock.NewAlloca(types.I64)
+	startBlock.NewStore(llvmZeroI64, from)
+	result := startBlock.NewAlloca(StringType)
+	startBlock.NewStore(newString(startBlock, data, llvmZeroI64), result)
+	startBlock.NewBr(searchBlock)
+
+	start := searchBlock.NewLoad(from)
+	idx := searchBlock.NewCall(indexOf, s, search, start)
+	searchBlock.NewCondBr(searchBlock.NewICmp(enum.IPredSLT, idx, llvmZeroI64), doneBlock, replaceBlock)
+
+	before := newString(replaceBlock, replaceBlock.NewGetElementPtr(data, start), replaceBlock.NewSub(idx, start))
+	r := replaceBlock.NewCall(concatStr, replaceBlock.NewLoad(result), before)
+	replaceBlock.NewStore(replaceBlock.NewCall(concatStr, r, replacement), result)
+	replaceBlock.NewStore(replaceBlock.NewAdd(idx, searchLen), from)
+	replaceBlock.NewBr(searchBlock)
+
+	rest := newString(doneBlock, doneBlock.NewGetElementPtr(data, start), doneBlock.NewSub(len, start))
+	doneBlock.NewRet(doneBlock.NewCall(concatStr, doneBlock.NewLoad(result), rest))
+}
+
+// generateTranslate replaces every character of s that appears in from
+// with the character at the same position in to
+// characters of from without a counterpart in to are removed
+func generateTranslate(mod *ir.Module) {
+	allocStr := getFuncByName(AllocStringFuncName, mod)
+	memchr := getFuncByName("memchr", mod)
+
+	s := ir.NewParam("s", StringType)
+	from := ir.NewParam("from", StringType)
+	to := ir.NewParam("to", StringType)
+	f := mod.NewFunc(TranslateFuncName, StringType, s, from, to)
+	entry := f.NewBlock("entry")
+	checkBlock := f.NewBlock("check")
+	lookupBlock := f.NewBlock("lookup")
+	mapBlock := f.NewBlock("map")
+	appendBlock := f.NewBlock("append")
+	nextBlock := f.NewBlock("next")
+	doneBlock := f.NewBlock("done")
+
+	data := entry.NewExtractValue(s, 0)
+	len := entry.NewExtractValue(s, 1)
+	fromData := entry.NewExtractValue(from, 0)
+	fromLen := entry.NewExtractValue(from, 1)
+	toData := entry.NewExtractValue(to, 0)
+	toLen := entry.NewExtractValue(to, 1)
+	buf := entry.NewCall(allocStr, len)
+	idx := entry.NewAlloca(types.I64)
+	entry.NewStore(llvmZeroI64, idx)
+	// the number of characters in the result
+	size := entry.NewAlloca(types.I64)
+	entry.NewStore(llvmZeroI64, size)
+	entry.NewBr(checkBlock)
+
+	i := checkBlock.NewLoad(idx)
+	checkBlock.NewCondBr(checkBlock.NewICmp(enum.IPredSLT, i, len), lookupBlock, doneBlock)
+
+	c := lookupBlock.NewLoad(lookupBlock.NewGetElementPtr(data, i))
+	found := lookupBlock.NewCall(memchr, fromData, lookupBlock.NewZExt(c, types.I32), fromLen)
+	lookupBlock.NewCondBr(lookupBlock.NewICmp(enum.IPredEQ, found, constant.NewNull(i8Ptr)), appendBlock, mapBlock)
+
+	pos := mapBlock.NewSub(mapBlock.NewPtrToInt(found, types.I64), mapBlock.NewPtrToInt(fromData, types.I64))
+	hasCounterpart := mapBlock.NewICmp(enum.IPredSLT, pos, toLen)
+	mappedBlock := f.NewBlock("mapped")
+	mapBlock.NewCondBr(hasCounterpart, mappedBlock, nextBlock)
+	mapped := mappedBlock.NewLoad(mappedBlock.NewGetElementPtr(toData, pos))
+	mappedBlock.NewBr(appendBlock)
+
+	char := appendBlock.NewPhi(ir.NewIncoming(c, lookupBlock), ir.NewIncoming(mapped, mappedBlock))
+	n := appendBlock.NewLoad(size)
+	appendBlock.NewStore(char, appendBlock.NewGetElementPtr(buf, n))
+	appendBlock.NewStore(appendBlock.NewAdd(n, llvmOneI64), size)
+	appendBlock.NewBr(nextBlock)
+
+	nextBlock.NewStore(nextBlock.NewAdd(i, llvmOneI64), idx)
+	nextBlock.NewBr(checkBlock)
+
+	doneBlock.NewRet(newString(doneBlock, buf, doneBlock.NewLoad(size)))
+}
+
+// generateAscii returns the code of the first character of s or 0 for empty strings
+func generateAscii(mod *ir.Module) {
+	s := ir.NewParam("s", StringType)
+	f := mod.NewFunc(AsciiFuncName, types.I64, s)
+	entry := f.NewBlock("entry")
+	firstBlock := f.NewBlock("first")
+	emptyBlock := f.NewBlock("empty")
+
+	entry.NewCondBr(entry.NewICmp(enum.IPredEQ, entry.NewExtractValue(s, 1), llvmZeroI64), emptyBlock, firstBlock)
+	c := firstBlock.NewLoad(firstBlock.NewExtractValue(s, 0))
+	firstBlock.NewRet(firstBlock.NewZExt(c, types.I64))
+	emptyBlock.NewRet(llvmZeroI64)
+}
+
+// generateChr returns the character with the code n
+func generateChr(mod *ir.Module) {
+	allocStr := getFuncByName(AllocStringFuncName, mod)
+
+	n := ir.NewParam("n", types.I64)
+	f := mod.NewFunc(ChrFuncName, StringType, n)
+	entry := f.NewBlock("entry")
+	buf := entry.NewCall(allocStr, llvmOneI64)
+	entry.NewStore(entry.NewTrunc(n, types.I8), buf)
+	entry.NewRet(newString(entry, buf, llvmOneI64))
+}
@@ -0,0 +1,185 @@
+/*
+ * Copyright 2019 Marco Helmich
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package runtime
+
+import (
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+)
+
+const (
+	TrimTrailingBlanksFuncName = "_runtime._rtrimBlanks"
+	EqualPaddedStringFuncName  = "_runtime._equalStrPadded"
+	CheckStringLengthFuncName  = "_runtime._checkStrLength"
+	PadStringFuncName          = "_runtime._padStr"
+	ConcatStringFuncName       = "_runtime._concatStr"
+
+	BufferTooSmallMessage = "ORA-06502: PL/SQL: numeric or value error: character string buffer too small"
+)
+
+func generateStrings(mod *ir.Module) {
+	generate_rtrimBlanks(mod)
+	generate_equalStrPadded(mod)
+	generate_checkStrLength(mod)
+	generate_padStr(mod)
+	generate_concatStr(mod)
+}
+
+// generate_rtrimBlanks returns the string without its trailing blanks
+// the characters aren't copied, the result points into the same memory
+func generate_rtrimBlanks(mod *ir.Module) {
+	s := ir.NewParam("s", StringType)
+	f := mod.NewFunc(TrimTrailingBlanksFuncName, StringType, s)
+	entry := f.NewBlock("entry")
+	checkBlock := f.NewBlock("check")
+	testCharBlock := f.NewBlock("test-char")
+	trimBlock := f.NewBlock("trim")
+	doneBlock := f.NewBlock("done")
+
+	data := entry.NewExtractValue(s, 0)
+	len := entry.NewAlloca(types.I64)
+	entry.NewStore(entry.NewExtractValue(s, 1), len)
+	entry.NewBr(checkBlock)
+
+	isEmpty := checkBlock.NewICmp(enum.IPredEQ, checkBlock.NewLoad(len), llvmZeroI64)
+	checkBlock.NewCondBr(isEmpty, doneBlock, testCharBlock)
+
+	last := testCharBlock.NewSub(testCharBlock.NewLoad(len), llvmOneI64)
+	lastChar := testCharBlock.NewLoad(testCharBlock.NewGetElementPtr(data, last))
+	isBlank := testCharBlock.NewICmp(enum.IPredEQ, lastChar, constant.NewInt(types.I8, ' '))
+	testCharBlock.NewCondBr(isBlank, trimBlock, doneBlock)
+
+	trimBlock.NewStore(trimBlock.NewSub(trimBlock.NewLoad(len), llvmOneI64), len)
+	trimBlock.NewBr(checkBlock)
+
+	doneBlock.NewRet(newString(doneBlock, data, doneBlock.NewLoad(len)))
+}
+
+// generate_equalStrPadded compares two strings as if the shorter one was padded with blanks
+// that is how Oracle compares CHAR values
+func generate_equalStrPadded(mod *ir.Module) {
+	rtrim := getFuncByName(TrimTrailingBlanksFuncName, mod)
+	equalStr := getFuncByName(EqualStringFuncName, mod)
+
+	s1 := ir.NewParam("s1", StringType)
+	s2 := ir.NewParam("s2", StringType)
+	f := mod.NewFunc(EqualPaddedStringFuncName, types.I1, s1, s2)
+	entry := f.NewBlock("entry")
+	entry.NewRet(entry.NewCall(equalStr, entry.NewCall(rtrim, s1), entry.NewCall(rtrim, s2)))
+}
+
+// generate_checkStrLength raises VALUE_ERROR if a string is longer than n characters
+func generate_checkStrLength(mod *ir.Module) {
+	bufferTooSmall := NewConstantString(mod, "_runtime.msg.buffer_too_small", BufferTooSmallMessage)
+
+	s := ir.NewParam("s", StringType)
+	n := ir.NewParam("n", types.I64)
+	f := mod.NewFunc(CheckStringLengthFuncName, StringType, s, n)
+	entry := f.NewBlock("entry")
+	okBlock := f.NewBlock("ok")
+	errorBlock := f.NewBlock("error")
+
+	tooLong := entry.NewICmp(enum.IPredSGT, entry.NewExtractValue(s, 1), n)
+	entry.NewCondBr(tooLong, errorBlock, okBlock)
+	okBlock.NewRet(s)
+	Raise(mod, errorBlock, bufferTooSmall)
+}
+
+// generate_padStr blank-pads a string to exactly n characters
+// strings that are longer than n characters raise VALUE_ERROR
+func generate_padStr(mod *ir.Module) {
+	checkStrLength := getFuncByName(CheckStringLengthFuncName, mod)
+	allocStr := getFuncByName(AllocStringFuncName, mod)
+	memcpy := getFuncByName("memcpy", mod)
+	memset := getFuncByName("memset", mod)
+
+	s := ir.NewParam("s", StringType)
+	n := ir.NewParam("n", types.I64)
+	f := mod.NewFunc(PadStringFuncName, StringType, s, n)
+	entry := f.NewBlock("entry")
+	padBlock := f.NewBlock("pad")
+	doneBlock := f.NewBlock("done")
+
+	checked := entry.NewCall(checkStrLength, s, n)
+	len := entry.NewExtractValue(checked, 1)
+	entry.NewCondBr(entry.NewICmp(enum.IPredEQ, len, n), doneBlock, padBlock)
+
+	buf := padBlock.NewCall(allocStr, n)
+	padBlock.NewCall(memcpy, buf, padBlock.NewExtractValue(checked, 0), len)
+	padBlock.NewCall(memset, padBlock.NewGetElementPtr(buf, len), constant.NewInt(types.I32, ' '), padBlock.NewSub(n, len))
+	padBlock.NewRet(newString(padBlock, buf, n))
+
+	doneBlock.NewRet(checked)
+}
+
+// generate_concatStr returns a new string with the characters of s2 appended to s1
+// if s1 is the string that was allocated last, the characters of s2 are
+// appended in place (strings only ever see their own length)
+// if s2 was allocated last right behind s1 ('s || i'), the two already are the result
+// that way building a string in a loop doesn't copy it over and over again
+func generate_concatStr(mod *ir.Module) {
+	allocStr := getFuncByName(AllocStringFuncName, mod)
+	memcpy := getFuncByName("memcpy", mod)
+	heapNext := getGlobalByName(heapNextName, mod)
+
+	s1 := ir.NewParam("s1", StringType)
+	s2 := ir.NewParam("s2", StringType)
+	f := mod.NewFunc(ConcatStringFuncName, StringType, s1, s2)
+	entry := f.NewBlock("entry")
+	checkFirstBlock := f.NewBlock("check-first")
+	checkLastBlock := f.NewBlock("check-last")
+	appendBlock := f.NewBlock("append")
+	checkAdjacentBlock := f.NewBlock("check-adjacent")
+	adjacentBlock := f.NewBlock("adjacent")
+	copyBlock := f.NewBlock("copy")
+	firstBlock := f.NewBlock("first")
+	secondBlock := f.NewBlock("second")
+
+	data1 := entry.NewExtractValue(s1, 0)
+	len1 := entry.NewExtractValue(s1, 1)
+	data2 := entry.NewExtractValue(s2, 0)
+	len2 := entry.NewExtractValue(s2, 1)
+	entry.NewCondBr(entry.NewICmp(enum.IPredEQ, len2, llvmZeroI64), firstBlock, checkFirstBlock)
+
+	checkFirstBlock.NewCondBr(checkFirstBlock.NewICmp(enum.IPredEQ, len1, llvmZeroI64), secondBlock, checkLastBlock)
+
+	next, available := heapAvailable(mod, checkLastBlock)
+	isLast := checkLastBlock.NewICmp(enum.IPredEQ, checkLastBlock.NewGetElementPtr(data1, len1), next)
+	fits := checkLastBlock.NewICmp(enum.IPredULE, len2, available)
+	checkLastBlock.NewCondBr(checkLastBlock.NewAnd(isLast, fits), appendBlock, checkAdjacentBlock)
+
+	appendBlock.NewCall(memcpy, next, data2, len2)
+	appendBlock.NewStore(appendBlock.NewGetElementPtr(next, len2), heapNext)
+	appendBlock.NewRet(newString(appendBlock, data1, appendBlock.NewAdd(len1, len2)))
+
+	isBehind := checkAdjacentBlock.NewICmp(enum.IPredEQ, checkAdjacentBlock.NewGetElementPtr(data1, len1), data2)
+	isLast = checkAdjacentBlock.NewICmp(enum.IPredEQ, checkAdjacentBlock.NewGetElementPtr(data2, len2), next)
+	checkAdjacentBlock.NewCondBr(checkAdjacentBlock.NewAnd(isBehind, isLast), adjacentBlock, copyBlock)
+
+	adjacentBlock.NewRet(newString(adjacentBlock, data1, adjacentBlock.NewAdd(len1, len2)))
+
+	len := copyBlock.NewAdd(len1, len2)
+	buf := copyBlock.NewCall(allocStr, len)
+	copyBlock.NewCall(memcpy, buf, data1, len1)
+	copyBlock.NewCall(memcpy, copyBlock.NewGetElementPtr(buf, len1), data2, len2)
+	copyBlock.NewRet(newString(copyBlock, buf, len))
+
+	firstBlock.NewRet(s1)
+	secondBlock.NewRet(s2)
+}
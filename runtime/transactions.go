@@ -0,0 +1,212 @@
+/*
+ * Copyright 2019 Marco Helmich
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package runtime
+
+import (
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// the connection runs in a transaction from the moment it's opened
+// COMMIT and ROLLBACK end it and start the next one right away
+// a program that ends without an error commits, everything else is rolled back when the program exits
+//
+// an autonomous transaction runs on a connection of its own, what it commits stays committed whatever its caller does
+// SQLite only allows one writer per database file, a caller with changes that aren't committed holds the lock to write
+// the autonomous transaction can read then but writing is a deadlock, it's raised right away instead of waiting
+const (
+	SqlCommitFuncName          = "_runtime.sql.commit"
+	SqlRollbackFuncName        = "_runtime.sql.rollback"
+	SqlSavepointFuncName       = "_runtime.sql.savepoint"
+	SqlRollbackToFuncName      = "_runtime.sql.rollbackTo"
+	SqlBeginAutonomousFuncName = "_runtime.sql.beginAutonomous"
+	SqlEndAutonomousFuncName   = "_runtime.sql.endAutonomous"
+
+	sqlExecFuncName       = "_runtime._sqlExec"
+	sqlDisconnectFuncName = "_runtime._sqlDisconnect"
+
+	// the total changes of the current connection when its transaction started
+	sqlCommittedChangesName = "_runtime.sql.committedChanges"
+
+	NoSuchSavepointMessage  = "ORA-01086: savepoint '%s' never established in this session or is invalid"
+	ActiveAutonomousMessage = "ORA-06519: active autonomous transaction detected and rolled back"
+)
+
+// sqlCallerType is what an autonomous transaction needs to get back to its caller
+// the connection of the caller, its committed changes and whether it waits for a caller of its own
+var sqlCallerType = types.NewStruct(i8Ptr, types.I64, types.I1)
+
+// generate_sqlExec runs a statement without binds or rows on a connection
+// it returns DriverOk if the statement ran fine
+func generate_sqlExec(mod *ir.Module) {
+	db := ir.NewParam("db", i8Ptr)
+	sql := ir.NewParam("sql", i8Ptr)
+	n := ir.NewParam("n", types.I32)
+	f := mod.NewFunc(sqlExecFuncName, types.I32, db, sql, n)
+	entry := f.NewBlock("entry")
+	preparedBlock := f.NewBlock("prepared")
+	failedBlock := f.NewBlock("failed")
+
+	stmt := entry.NewAlloca(i8Ptr)
+	rc := entry.NewCall(getFuncByName("plsqlc_db_prepare", mod), db, sql, n, stmt)
+	entry.NewCondBr(entry.NewICmp(enum.IPredEQ, rc, llvmZeroI32), preparedBlock, failedBlock)
+	failedBlock.NewRet(rc)
+
+	prepared := preparedBlock.NewLoad(stmt)
+	rc = preparedBlock.NewCall(getFuncByName("plsqlc_db_step", mod), prepared)
+	preparedBlock.NewCall(getFuncByName("plsqlc_db_finalize", mod), prepared)
+	isDone := preparedBlock.NewICmp(enum.IPredEQ, rc, i32Constant(DriverDone))
+	preparedBlock.NewRet(preparedBlock.NewSelect(isDone, i32Constant(DriverOk), rc))
+}
+
+// execOrRaise runs statements the runtime needs and raises an internal error if one of them fails
+// the texts are named by the globals they are stored in
+func execOrRaise(mod *ir.Module, b *ir.Block, db value.Value, names ...string) *ir.Block {
+	for idx := range names {
+		text := sharedConstantCString(mod, "_runtime.sql.text."+names[idx], sqlTexts[names[idx]])
+		rc := b.NewCall(getFuncByName(sqlExecFuncName, mod), db, text, i32Constant(-1))
+		b = checkOrSqlRaise(mod, b, b.NewICmp(enum.IPredEQ, rc, llvmZeroI32))
+	}
+	return b
+}
+
+// the statements of the runtime by the names of their globals
+var sqlTexts = map[string]string{
+	"begin":    "BEGIN",
+	"commit":   "COMMIT",
+	"rollback": "ROLLBACK",
+}
+
+// checkOrSqlRaise works like checkOrRaise but the error contains the message of the database
+func checkOrSqlRaise(mod *ir.Module, b *ir.Block, isValid value.Value) *ir.Block {
+	f := b.Parent
+	okBlock := f.NewBlock("")
+	errorBlock := f.NewBlock("")
+	b.NewCondBr(isValid, okBlock, errorBlock)
+	internalError := sharedConstantCString(mod, "_runtime.format.sql_internal_error", SqlInternalErrorMessage)
+	errorBlock.NewCall(getFuncByName(sqlRaiseFuncName, mod), internalError)
+	errorBlock.NewUnreachable()
+	return okBlock
+}
+
+// generate_sqlDisconnect rolls back what hasn't been committed and closes the connection
+// it runs when the program exits, whether it ends fine or with an error
+func generate_sqlDisconnect(mod *ir.Module) {
+	f := mod.NewFunc(sqlDisconnectFuncName, types.Void)
+	b := f.NewBlock("entry")
+	db := b.NewLoad(getGlobalByName(sqlDbName, mod))
+	text := sharedConstantCString(mod, "_runtime.sql.text.rollback", sqlTexts["rollback"])
+	b.NewCall(getFuncByName(sqlExecFuncName, mod), db, text, i32Constant(-1))
+	b.NewCall(getFuncByName("plsqlc_db_close", mod), db)
+	b.NewRet(nil)
+}
+
+// generateSqlTransactions creates the functions behind COMMIT, ROLLBACK, SAVEPOINT and autonomous transactions
+func generateSqlTransactions(mod *ir.Module) {
+	dbGlobal := getGlobalByName(sqlDbName, mod)
+	waitsForCaller := getGlobalByName(sqlWaitsForCallerName, mod)
+	committed := mod.NewGlobalDef(sqlCommittedChangesName, llvmZeroI64)
+	connect := getFuncByName(sqlConnectFuncName, mod)
+	exec := getFuncByName(sqlExecFuncName, mod)
+	totalChanges := func(b *ir.Block, db value.Value) value.Value {
+		return b.NewCall(getFuncByName("plsqlc_db_total_changes", mod), db)
+	}
+
+	// endTransaction creates a function that ends the transaction of the current connection and starts the next one
+	endTransaction := func(name string, end string) {
+		f := mod.NewFunc(name, types.Void)
+		b := f.NewBlock("entry")
+		db := b.NewCall(connect)
+		b = execOrRaise(mod, b, db, end, "begin")
+		b.NewStore(totalChanges(b, db), committed)
+		b.NewRet(nil)
+	}
+	endTransaction(SqlCommitFuncName, "commit")
+	endTransaction(SqlRollbackFuncName, "rollback")
+
+	sql := ir.NewParam("sql", StringType)
+	f := mod.NewFunc(SqlSavepointFuncName, types.Void, sql)
+	b := f.NewBlock("entry")
+	db := b.NewCall(connect)
+	rc := b.NewCall(exec, db, b.NewExtractValue(sql, 0), b.NewTrunc(b.NewExtractValue(sql, 1), types.I32))
+	b = checkOrSqlRaise(mod, b, b.NewICmp(enum.IPredEQ, rc, llvmZeroI32))
+	b.NewRet(nil)
+
+	// the only way 'ROLLBACK TO' fails is a savepoint that doesn't exist
+	sql = ir.NewParam("sql", StringType)
+	message := ir.NewParam("message", StringType)
+	f = mod.NewFunc(SqlRollbackToFuncName, types.Void, sql, message)
+	b = f.NewBlock("entry")
+	okBlock := f.NewBlock("ok")
+	errorBlock := f.NewBlock("error")
+	db = b.NewCall(connect)
+	rc = b.NewCall(exec, db, b.NewExtractValue(sql, 0), b.NewTrunc(b.NewExtractValue(sql, 1), types.I32))
+	b.NewCondBr(b.NewICmp(enum.IPredEQ, rc, llvmZeroI32), okBlock, errorBlock)
+	okBlock.NewRet(nil)
+	errorBlock.NewCall(getFuncByName(RaiseFuncName, mod), message)
+	errorBlock.NewUnreachable()
+
+	// beginAutonomous makes a new connection the current one and returns its caller
+	// a caller without changes ends its transaction, it sees what the autonomous transaction commits and can still write afterwards
+	// that also releases its savepoints, there are no changes they could roll back
+	f = mod.NewFunc(SqlBeginAutonomousFuncName, sqlCallerType)
+	entry := f.NewBlock("entry")
+	refreshBlock := f.NewBlock("refresh")
+	openBlock := f.NewBlock("open")
+	caller := entry.NewCall(connect)
+	callerCommitted := entry.NewLoad(committed)
+	hasChanges := entry.NewICmp(enum.IPredNE, totalChanges(entry, caller), callerCommitted)
+	callerWaits := entry.NewLoad(waitsForCaller)
+	entry.NewCondBr(hasChanges, openBlock, refreshBlock)
+
+	b = execOrRaise(mod, refreshBlock, caller, "commit", "begin")
+	b.NewBr(openBlock)
+
+	db = openBlock.NewCall(getFuncByName(sqlOpenFuncName, mod))
+	waits := openBlock.NewOr(hasChanges, callerWaits)
+	timeout := openBlock.NewSelect(waits, llvmZeroI32, i32Constant(sqlBusyTimeout))
+	openBlock.NewCall(getFuncByName("plsqlc_db_busy_timeout", mod), db, timeout)
+	openBlock.NewStore(totalChanges(openBlock, db), committed)
+	openBlock.NewStore(waits, waitsForCaller)
+	var callerValue value.Value = constant.NewUndef(sqlCallerType)
+	for idx, v := range []value.Value{caller, callerCommitted, callerWaits} {
+		callerValue = openBlock.NewInsertValue(callerValue, v, uint64(idx))
+	}
+	openBlock.NewRet(callerValue)
+
+	// endAutonomous closes the connection of the autonomous transaction and makes the connection of its caller the current one again
+	// leaving an autonomous transaction with changes that haven't been committed is an error, they are rolled back
+	callerParam := ir.NewParam("caller", sqlCallerType)
+	f = mod.NewFunc(SqlEndAutonomousFuncName, types.Void, callerParam)
+	b = f.NewBlock("entry")
+	committedBlock := f.NewBlock("committed")
+	activeBlock := f.NewBlock("active")
+	current := b.NewLoad(dbGlobal)
+	isActive := b.NewICmp(enum.IPredNE, totalChanges(b, current), b.NewLoad(committed))
+	b = execOrRaise(mod, b, current, "rollback")
+	b.NewCall(getFuncByName("plsqlc_db_close", mod), current)
+	b.NewStore(b.NewExtractValue(callerParam, 0), dbGlobal)
+	b.NewStore(b.NewExtractValue(callerParam, 1), committed)
+	b.NewStore(b.NewExtractValue(callerParam, 2), waitsForCaller)
+	b.NewCondBr(isActive, activeBlock, committedBlock)
+
+	committedBlock.NewRet(nil)
+	Raise(mod, activeBlock, NewConstantString(mod, "_runtime.msg.active_autonomous", ActiveAutonomousMessage))
+}